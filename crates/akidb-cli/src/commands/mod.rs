@@ -1 +1,2 @@
 pub mod migrate;
+pub mod restore;
@@ -0,0 +1,207 @@
+//! Point-in-time tenant snapshots and restores.
+//!
+//! A snapshot is a consistent copy of the SQLite metadata store (tenants,
+//! databases, collections and persisted vectors) taken with `VACUUM INTO`.
+//! `restore_tenant` copies one tenant out of a snapshot into a metadata store
+//! under a fresh tenant ID, e.g. to stand up a staging clone of a production
+//! tenant. Users and API keys are not restored: the clone starts without
+//! credentials.
+
+use std::path::{Path, PathBuf};
+
+use akidb_core::{
+    CollectionDescriptor, CollectionId, CollectionRepository, DatabaseDescriptor, DatabaseId,
+    DatabaseRepository, TenantCatalog, TenantDescriptor, TenantId, TenantStatus,
+};
+use akidb_metadata::{
+    create_sqlite_pool, run_migrations, SqliteCollectionRepository, SqliteDatabaseRepository,
+    SqliteTenantCatalog, VectorPersistence,
+};
+use anyhow::{anyhow, Context, Result};
+use chrono::Utc;
+use serde_json::Value;
+use sqlx::sqlite::{SqliteConnectOptions, SqlitePoolOptions};
+use sqlx::SqlitePool;
+
+/// Takes a point-in-time snapshot of the metadata store at `database_url`
+/// into a new SQLite file at `path`.
+pub async fn snapshot_metadata(database_url: &str, path: &Path) -> Result<()> {
+    if path.exists() {
+        return Err(anyhow!("snapshot file {} already exists", path.display()));
+    }
+    let path = path
+        .to_str()
+        .ok_or_else(|| anyhow!("snapshot path {} is not valid UTF-8", path.display()))?;
+
+    let pool = create_sqlite_pool(database_url)
+        .await
+        .context("failed to create SQLite pool")?;
+    sqlx::query("VACUUM INTO ?1")
+        .bind(path)
+        .execute(&pool)
+        .await
+        .context("failed to write snapshot")?;
+    Ok(())
+}
+
+/// Input options for restoring a tenant from a snapshot.
+pub struct RestoreOptions {
+    /// Snapshot file written by `snapshot_metadata`.
+    pub snapshot_path: PathBuf,
+    /// Tenant to restore, as stored in the snapshot.
+    pub source_tenant_id: TenantId,
+    /// SQLite database URL of the metadata store to restore into.
+    pub database_url: String,
+    /// Slug of the restored tenant (must be unused in the target store).
+    pub slug: String,
+    /// Name of the restored tenant (default: the source tenant's name).
+    pub name: Option<String>,
+}
+
+/// Summary of a tenant restore.
+#[derive(Debug, Clone)]
+pub struct RestoreReport {
+    /// Fresh ID of the restored tenant.
+    pub tenant_id: TenantId,
+    pub databases: usize,
+    pub collections: usize,
+    pub vectors: usize,
+}
+
+/// Restores a tenant from a snapshot into a new tenant.
+///
+/// Databases and collections get fresh IDs; documents keep theirs. The tenant
+/// is created in the provisioning state and only becomes active once every
+/// collection's vectors are copied, so a failed restore is easy to spot and
+/// delete.
+pub async fn restore_tenant(options: RestoreOptions) -> Result<RestoreReport> {
+    let snapshot = open_snapshot(&options.snapshot_path).await?;
+    let source_tenants = SqliteTenantCatalog::new(snapshot.clone());
+    let source = source_tenants
+        .get(options.source_tenant_id)
+        .await
+        .map_err(|err| anyhow!("failed to read tenant from snapshot: {}", err))?
+        .ok_or_else(|| {
+            anyhow!(
+                "tenant {} not found in snapshot {}",
+                options.source_tenant_id,
+                options.snapshot_path.display()
+            )
+        })?;
+    let source_databases = SqliteDatabaseRepository::new(snapshot.clone())
+        .list_by_tenant(source.tenant_id)
+        .await
+        .map_err(|err| anyhow!("failed to read databases from snapshot: {}", err))?;
+    let source_collections = SqliteCollectionRepository::new(snapshot.clone());
+    let source_vectors = VectorPersistence::new(snapshot.clone());
+
+    let pool = create_sqlite_pool(&options.database_url)
+        .await
+        .context("failed to create SQLite pool")?;
+    run_migrations(&pool)
+        .await
+        .context("failed to apply metadata migrations")?;
+
+    let now = Utc::now();
+    let mut tenant = TenantDescriptor {
+        tenant_id: TenantId::new(),
+        name: options.name.unwrap_or_else(|| source.name.clone()),
+        slug: options.slug,
+        status: TenantStatus::Provisioning,
+        quotas: source.quotas,
+        metadata: source.metadata.clone(),
+        created_at: now,
+        updated_at: now,
+    };
+    if let Value::Object(ref mut obj) = tenant.metadata {
+        obj.insert(
+            "restored_from_tenant_id".to_string(),
+            Value::String(source.tenant_id.to_string()),
+        );
+        obj.insert("restored_at".to_string(), Value::String(now.to_rfc3339()));
+    }
+
+    // Tenant, databases and collections are created atomically
+    let mut tx = pool.begin().await.context("failed to open transaction")?;
+    SqliteTenantCatalog::create_with_executor(tx.as_mut(), &tenant)
+        .await
+        .map_err(|err| anyhow!("failed to insert tenant {}: {}", tenant.slug, err))?;
+    let mut collections: Vec<(CollectionId, CollectionId)> = Vec::new();
+    for source_database in &source_databases {
+        let database = DatabaseDescriptor {
+            database_id: DatabaseId::new(),
+            tenant_id: tenant.tenant_id,
+            created_at: now,
+            updated_at: now,
+            ..source_database.clone()
+        };
+        SqliteDatabaseRepository::create_with_executor(tx.as_mut(), &database)
+            .await
+            .map_err(|err| anyhow!("failed to insert database {}: {}", database.name, err))?;
+
+        let source_database_collections = source_collections
+            .list_by_database(source_database.database_id)
+            .await
+            .map_err(|err| anyhow!("failed to read collections from snapshot: {}", err))?;
+        for source_collection in source_database_collections {
+            let collection = CollectionDescriptor {
+                collection_id: CollectionId::new(),
+                database_id: database.database_id,
+                created_at: now,
+                updated_at: now,
+                ..source_collection.clone()
+            };
+            SqliteCollectionRepository::create_with_executor(tx.as_mut(), &collection)
+                .await
+                .map_err(|err| {
+                    anyhow!("failed to insert collection {}: {}", collection.name, err)
+                })?;
+            collections.push((source_collection.collection_id, collection.collection_id));
+        }
+    }
+    tx.commit()
+        .await
+        .context("restore transaction commit failed")?;
+
+    let vectors = VectorPersistence::new(pool.clone());
+    let mut copied = 0_usize;
+    for (source_collection_id, collection_id) in &collections {
+        let documents = source_vectors
+            .load_all_vectors(*source_collection_id)
+            .await
+            .map_err(|err| anyhow!("failed to read vectors from snapshot: {}", err))?;
+        vectors
+            .save_batch(*collection_id, &documents)
+            .await
+            .map_err(|err| anyhow!("failed to restore vectors of {}: {}", collection_id, err))?;
+        copied += documents.len();
+    }
+
+    tenant.transition_to(TenantStatus::Active);
+    SqliteTenantCatalog::update_with_executor(&pool, &tenant)
+        .await
+        .map_err(|err| anyhow!("failed to activate tenant {}: {}", tenant.tenant_id, err))?;
+
+    Ok(RestoreReport {
+        tenant_id: tenant.tenant_id,
+        databases: source_databases.len(),
+        collections: collections.len(),
+        vectors: copied,
+    })
+}
+
+/// Opens a snapshot read-only, so restoring never modifies it.
+async fn open_snapshot(path: &Path) -> Result<SqlitePool> {
+    if !path.exists() {
+        return Err(anyhow!("snapshot file {} does not exist", path.display()));
+    }
+    let options = SqliteConnectOptions::new()
+        .filename(path)
+        .read_only(true)
+        .foreign_keys(true);
+    SqlitePoolOptions::new()
+        .max_connections(1)
+        .connect_with(options)
+        .await
+        .with_context(|| format!("failed to open snapshot {}", path.display()))
+}
@@ -72,6 +72,22 @@ impl ApiKeyDescriptor {
     pub fn has_permission(&self, permission: &str) -> bool {
         self.permissions.iter().any(|p| p == permission)
     }
+
+    /// Creates the descriptor of a key replacing this one on rotation.
+    /// The replacement keeps the name, permissions and lifetime.
+    #[must_use]
+    pub fn rotated(&self) -> Self {
+        let expires_at = self
+            .expires_at
+            .map(|expires_at| Utc::now() + (expires_at - self.created_at));
+        Self::new(
+            self.tenant_id,
+            self.name.clone(),
+            self.permissions.clone(),
+            expires_at,
+            self.created_by,
+        )
+    }
 }
 
 /// Request to create a new API key.
@@ -100,6 +116,35 @@ pub struct CreateApiKeyResponse {
     pub api_key: String,
 }
 
+/// Longest grace period (30 days) during which a rotated API key stays valid.
+pub const MAX_KEY_ROTATION_GRACE_SECS: u64 = 2_592_000;
+
+/// Request to rotate an API key.
+#[derive(Clone, Debug, Default, Serialize, Deserialize)]
+pub struct RotateApiKeyRequest {
+    /// Seconds the old key stays valid (0 = revoked at once).
+    #[serde(default)]
+    pub grace_period_secs: u64,
+}
+
+/// Response containing the key that replaces a rotated one.
+/// The plaintext API key is only returned once, like on creation.
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct RotateApiKeyResponse {
+    /// Descriptor of the new key.
+    #[serde(flatten)]
+    pub descriptor: ApiKeyDescriptor,
+
+    /// Plaintext new API key.
+    pub api_key: String,
+
+    /// The rotated key.
+    pub previous_key_id: ApiKeyId,
+
+    /// When the rotated key stops working (None = revoked).
+    pub previous_expires_at: Option<DateTime<Utc>>,
+}
+
 /// List of API keys for a tenant.
 #[derive(Clone, Debug, Serialize, Deserialize)]
 pub struct ListApiKeysResponse {
@@ -190,6 +235,28 @@ mod tests {
         assert!(descriptor.is_expired());
     }
 
+    #[test]
+    fn test_rotated_api_key_keeps_lifetime() {
+        let created_by = UserId::new();
+        let mut descriptor = ApiKeyDescriptor::new(
+            TenantId::new(),
+            "ci".to_string(),
+            vec!["collection::read".to_string()],
+            None,
+            Some(created_by),
+        );
+        descriptor.created_at = Utc::now() - chrono::Duration::days(80);
+        descriptor.expires_at = Some(descriptor.created_at + chrono::Duration::days(90));
+
+        let rotated = descriptor.rotated();
+        assert_ne!(rotated.key_id, descriptor.key_id);
+        assert_eq!(rotated.name, "ci");
+        assert_eq!(rotated.permissions, descriptor.permissions);
+        assert_eq!(rotated.created_by, Some(created_by));
+        let lifetime = rotated.expires_at.unwrap() - rotated.created_at;
+        assert!((lifetime - chrono::Duration::days(90)).num_seconds().abs() < 5);
+    }
+
     #[test]
     fn test_api_key_never_expires() {
         let descriptor =
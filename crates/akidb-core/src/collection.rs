@@ -1,5 +1,6 @@
 use chrono::{DateTime, Utc};
 use serde::{Deserialize, Serialize};
+use serde_json::Value;
 use std::str::FromStr;
 
 use crate::ids::{CollectionId, DatabaseId};
@@ -47,6 +48,44 @@ impl FromStr for DistanceMetric {
     }
 }
 
+/// Vector index structure of a collection.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum IndexType {
+    /// Flat for collections limited to few documents, HNSW otherwise.
+    #[default]
+    Auto,
+    /// Exact brute-force search.
+    Flat,
+    /// Approximate HNSW graph search.
+    Hnsw,
+}
+
+impl IndexType {
+    /// Returns the canonical lowercase string stored in SQLite.
+    #[must_use]
+    pub const fn as_str(&self) -> &'static str {
+        match self {
+            Self::Auto => "auto",
+            Self::Flat => "flat",
+            Self::Hnsw => "hnsw",
+        }
+    }
+}
+
+impl FromStr for IndexType {
+    type Err = ();
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "auto" => Ok(Self::Auto),
+            "flat" => Ok(Self::Flat),
+            "hnsw" => Ok(Self::Hnsw),
+            _ => Err(()),
+        }
+    }
+}
+
 /// Configuration parameters for a vector collection.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CollectionDescriptor {
@@ -62,12 +101,36 @@ pub struct CollectionDescriptor {
     pub metric: DistanceMetric,
     /// Embedding model identifier (e.g., "qwen3-embed-8b").
     pub embedding_model: String,
+    /// Vector index structure, fixed at creation.
+    #[serde(default)]
+    pub index_type: IndexType,
     /// HNSW graph degree (M parameter).
     pub hnsw_m: u32,
     /// HNSW construction EF parameter.
     pub hnsw_ef_construction: u32,
     /// Maximum document count (guardrail).
     pub max_doc_count: u64,
+    /// Free-form description.
+    #[serde(default)]
+    pub description: Option<String>,
+    /// Arbitrary metadata stored as JSON.
+    #[serde(default)]
+    pub metadata: Option<Value>,
+    /// Writes, including deleting the collection, are rejected.
+    #[serde(default)]
+    pub read_only: bool,
+    /// Sparse index settings stored as JSON (none without a sparse index).
+    #[serde(default)]
+    pub sparse_index: Option<Value>,
+    /// Named vector spaces stored as JSON (none without named vectors).
+    #[serde(default)]
+    pub named_vectors: Option<Value>,
+    /// Default search parameters stored as JSON (none if unset).
+    #[serde(default)]
+    pub search_defaults: Option<Value>,
+    /// Text analysis settings stored as JSON (none uses the default analyzer).
+    #[serde(default)]
+    pub text_analysis: Option<Value>,
     /// Creation timestamp in UTC.
     pub created_at: DateTime<Utc>,
     /// Update timestamp in UTC.
@@ -103,9 +166,17 @@ impl CollectionDescriptor {
             dimension,
             metric: DistanceMetric::default(),
             embedding_model: embedding_model.into(),
+            index_type: IndexType::default(),
             hnsw_m: Self::DEFAULT_HNSW_M,
             hnsw_ef_construction: Self::DEFAULT_HNSW_EF_CONSTRUCTION,
             max_doc_count: Self::DEFAULT_MAX_DOC_COUNT,
+            description: None,
+            metadata: None,
+            read_only: false,
+            sparse_index: None,
+            named_vectors: None,
+            search_defaults: None,
+            text_analysis: None,
             created_at: now,
             updated_at: now,
         }
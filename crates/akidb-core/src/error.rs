@@ -1,5 +1,7 @@
 use thiserror::Error;
 
+use crate::ids::LegalHoldId;
+
 /// Canonical error type for core metadata operations.
 #[derive(Debug, Error)]
 pub enum CoreError {
@@ -21,6 +23,26 @@ pub enum CoreError {
         id: String,
     },
 
+    /// Entity is read-only and cannot be modified.
+    #[error("{entity} `{id}` is read-only")]
+    ReadOnly {
+        /// Entity type name (e.g. `"collection"`).
+        entity: &'static str,
+        /// Identifier of the read-only entity.
+        id: String,
+    },
+
+    /// Entity is covered by a legal hold and cannot be deleted.
+    #[error("{entity} `{id}` is under legal hold `{hold_id}`")]
+    UnderLegalHold {
+        /// Entity type name (e.g. `"Document"`).
+        entity: &'static str,
+        /// Identifier of the held entity.
+        id: String,
+        /// Hold covering the entity.
+        hold_id: LegalHoldId,
+    },
+
     /// Resource quotas prohibit the attempted operation.
     #[error("quota exceeded: {message}")]
     QuotaExceeded {
@@ -82,6 +104,37 @@ impl CoreError {
         }
     }
 
+    /// Creates a `ReadOnly` variant.
+    #[must_use]
+    pub fn read_only(entity: &'static str, id: impl Into<String>) -> Self {
+        Self::ReadOnly {
+            entity,
+            id: id.into(),
+        }
+    }
+
+    /// Creates an `UnderLegalHold` variant.
+    #[must_use]
+    pub fn under_legal_hold(
+        entity: &'static str,
+        id: impl Into<String>,
+        hold_id: LegalHoldId,
+    ) -> Self {
+        Self::UnderLegalHold {
+            entity,
+            id: id.into(),
+            hold_id,
+        }
+    }
+
+    /// Creates a `QuotaExceeded` variant.
+    #[must_use]
+    pub fn quota_exceeded(message: impl Into<String>) -> Self {
+        Self::QuotaExceeded {
+            message: message.into(),
+        }
+    }
+
     /// Creates an `InvalidState` variant.
     #[must_use]
     pub fn invalid_state(message: impl Into<String>) -> Self {
@@ -7,7 +7,11 @@ use uuid::Uuid;
 macro_rules! define_id {
     ($name:ident, $doc:literal) => {
         #[doc = $doc]
-        #[derive(Clone, Copy, Debug, PartialEq, Eq, Hash, Serialize, Deserialize)]
+        ///
+        /// IDs order like their string form.
+        #[derive(
+            Clone, Copy, Debug, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize,
+        )]
         #[serde(transparent)]
         pub struct $name(Uuid);
 
@@ -97,3 +101,12 @@ define_id!(
     ApiKeyId,
     "Unique identifier for an API key used for authentication."
 );
+define_id!(JobId, "Unique identifier for a background job.");
+define_id!(UploadId, "Unique identifier for a resumable upload.");
+define_id!(
+    SubscriptionId,
+    "Unique identifier for a standing query subscription."
+);
+define_id!(LegalHoldId, "Unique identifier for a legal hold.");
+define_id!(TransactionId, "Unique identifier for a write transaction.");
+define_id!(SnapshotId, "Unique identifier for a collection snapshot.");
@@ -14,16 +14,23 @@ pub mod vector;
 pub use audit::{AuditLogEntry, AuditResult};
 pub use auth::{
     generate_api_key, hash_api_key, is_valid_api_key_format, ApiKeyDescriptor, CreateApiKeyRequest,
-    CreateApiKeyResponse, ListApiKeysResponse,
+    CreateApiKeyResponse, ListApiKeysResponse, RotateApiKeyRequest, RotateApiKeyResponse,
+    MAX_KEY_ROTATION_GRACE_SECS,
 };
-pub use collection::{CollectionDescriptor, DistanceMetric};
+pub use collection::{CollectionDescriptor, DistanceMetric, IndexType};
 pub use database::{DatabaseDescriptor, DatabaseState};
 pub use error::{CoreError, CoreResult};
-pub use ids::{ApiKeyId, AuditLogId, CollectionId, DatabaseId, DocumentId, TenantId, UserId};
-pub use tenant::{TenantDescriptor, TenantQuota, TenantStatus};
+pub use ids::{
+    ApiKeyId, AuditLogId, CollectionId, DatabaseId, DocumentId, JobId, LegalHoldId, SnapshotId,
+    SubscriptionId, TenantId, TransactionId, UploadId, UserId,
+};
+pub use tenant::{
+    CollectionDefaults, CollectionLimits, CollectionPolicy, CreateTenantRequest, TenantDeletion,
+    TenantDescriptor, TenantQuota, TenantStatus, MAX_TENANT_SLUG_LEN, TENANT_DELETION_KEY,
+};
 pub use traits::{
     ApiKeyRepository, AuditLogRepository, CollectionRepository, DatabaseRepository, TenantCatalog,
     UserRepository, VectorIndex,
 };
 pub use user::{Action, Role, UserDescriptor, UserStatus};
-pub use vector::{SearchResult, VectorDocument};
+pub use vector::{SearchResult, SparseVector, VectorDocument};
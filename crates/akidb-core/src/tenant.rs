@@ -1,8 +1,10 @@
-use chrono::{DateTime, Utc};
+use chrono::{DateTime, Duration, Utc};
 use serde::{Deserialize, Serialize};
 use serde_json::Value;
 use std::str::FromStr;
 
+use crate::collection::DistanceMetric;
+use crate::error::{CoreError, CoreResult};
 use crate::ids::TenantId;
 
 /// Provisioning state of a tenant account.
@@ -106,6 +108,99 @@ impl Default for TenantQuota {
     }
 }
 
+/// Defaults applied to a tenant's collections and queries when a request
+/// leaves the setting out.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct CollectionDefaults {
+    /// Distance metric for new collections.
+    pub metric: Option<DistanceMetric>,
+    /// Number of results returned by queries that do not set `top_k`.
+    pub top_k: Option<u32>,
+}
+
+/// Hard limits enforced on a tenant's collections and queries.
+///
+/// `None` leaves the platform limit in place.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct CollectionLimits {
+    /// Maximum vector dimension of new collections.
+    pub max_dimension: Option<u32>,
+    /// Maximum results per query.
+    pub max_top_k: Option<u32>,
+    /// Maximum number of clauses in a query filter.
+    pub max_filter_clauses: Option<u32>,
+}
+
+impl CollectionLimits {
+    /// Rejects a collection dimension above the limit.
+    pub fn check_dimension(&self, dimension: u32) -> CoreResult<()> {
+        check_limit("dimension", u64::from(dimension), self.max_dimension)
+    }
+
+    /// Rejects a query `top_k` above the limit.
+    pub fn check_top_k(&self, top_k: usize) -> CoreResult<()> {
+        check_limit("top_k", top_k as u64, self.max_top_k)
+    }
+
+    /// Rejects a filter with more clauses than the limit.
+    pub fn check_filter_clauses(&self, clauses: usize) -> CoreResult<()> {
+        check_limit("filter clauses", clauses as u64, self.max_filter_clauses)
+    }
+}
+
+fn check_limit(name: &str, value: u64, limit: Option<u32>) -> CoreResult<()> {
+    match limit {
+        Some(limit) if value > u64::from(limit) => Err(CoreError::quota_exceeded(format!(
+            "{} {} exceeds the tenant limit of {}",
+            name, value, limit
+        ))),
+        _ => Ok(()),
+    }
+}
+
+/// Collection defaults and hard limits set by a tenant's platform team.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct CollectionPolicy {
+    /// Defaults applied when a request leaves a setting out.
+    pub defaults: CollectionDefaults,
+    /// Limits enforced on every request.
+    pub limits: CollectionLimits,
+}
+
+impl CollectionPolicy {
+    /// Checks that limits are non-zero and the defaults respect them.
+    pub fn validate(&self) -> CoreResult<()> {
+        let limits = &self.limits;
+        for (name, limit) in [
+            ("max_dimension", limits.max_dimension),
+            ("max_top_k", limits.max_top_k),
+            ("max_filter_clauses", limits.max_filter_clauses),
+        ] {
+            if limit == Some(0) {
+                return Err(CoreError::ValidationError(format!(
+                    "{} must be greater than 0",
+                    name
+                )));
+            }
+        }
+
+        if let Some(top_k) = self.defaults.top_k {
+            if top_k == 0 {
+                return Err(CoreError::ValidationError(
+                    "default top_k must be greater than 0".to_string(),
+                ));
+            }
+            limits
+                .check_top_k(top_k as usize)
+                .map_err(|e| CoreError::ValidationError(format!("default {}", e)))?;
+        }
+        Ok(())
+    }
+}
+
 /// Immutable tenant metadata persisted by the metadata service.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct TenantDescriptor {
@@ -165,4 +260,163 @@ impl TenantDescriptor {
     pub fn touch(&mut self) {
         self.updated_at = Utc::now();
     }
+
+    /// Soft-deletes the tenant: decommissions it and records when it may be
+    /// purged. The tenant can be restored with `undelete` until then.
+    pub fn soft_delete(&mut self, retention: Duration) -> CoreResult<TenantDeletion> {
+        if self.deletion().is_some() {
+            return Err(CoreError::invalid_state(format!(
+                "tenant {} is already deleted",
+                self.tenant_id
+            )));
+        }
+        let deleted_at = Utc::now();
+        let deletion = TenantDeletion {
+            deleted_at,
+            purge_after: deleted_at + retention,
+        };
+        let value =
+            serde_json::to_value(deletion).map_err(|err| CoreError::internal(err.to_string()))?;
+        self.metadata_object()
+            .insert(TENANT_DELETION_KEY.to_string(), value);
+        self.status = TenantStatus::Decommissioned;
+        self.updated_at = deleted_at;
+        Ok(deletion)
+    }
+
+    /// Pending deletion of a soft-deleted tenant.
+    #[must_use]
+    pub fn deletion(&self) -> Option<TenantDeletion> {
+        if self.status != TenantStatus::Decommissioned {
+            return None;
+        }
+        self.metadata
+            .get(TENANT_DELETION_KEY)
+            .and_then(|value| serde_json::from_value(value.clone()).ok())
+    }
+
+    /// Restores a soft-deleted tenant, active.
+    pub fn undelete(&mut self) -> CoreResult<()> {
+        if self.deletion().is_none() {
+            return Err(CoreError::invalid_state(format!(
+                "tenant {} is not deleted",
+                self.tenant_id
+            )));
+        }
+        self.metadata_object().remove(TENANT_DELETION_KEY);
+        self.transition_to(TenantStatus::Active);
+        Ok(())
+    }
+
+    /// Suspends the tenant, keeping its data. Decommissioned tenants cannot
+    /// be suspended.
+    pub fn suspend(&mut self) -> CoreResult<()> {
+        match self.status {
+            TenantStatus::Suspended | TenantStatus::Decommissioned => {
+                Err(CoreError::invalid_state(format!(
+                    "tenant {} is {}",
+                    self.tenant_id,
+                    self.status.as_str()
+                )))
+            }
+            _ => {
+                self.transition_to(TenantStatus::Suspended);
+                Ok(())
+            }
+        }
+    }
+
+    /// Reactivates a suspended tenant.
+    pub fn resume(&mut self) -> CoreResult<()> {
+        if self.status != TenantStatus::Suspended {
+            return Err(CoreError::invalid_state(format!(
+                "tenant {} is not suspended",
+                self.tenant_id
+            )));
+        }
+        self.transition_to(TenantStatus::Active);
+        Ok(())
+    }
+}
+
+/// Metadata key holding the `TenantDeletion` of a soft-deleted tenant.
+pub const TENANT_DELETION_KEY: &str = "_deletion";
+
+/// When a tenant was soft-deleted and when it may be purged.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TenantDeletion {
+    pub deleted_at: DateTime<Utc>,
+    /// The tenant is permanently deleted after this time.
+    pub purge_after: DateTime<Utc>,
+}
+
+/// Maximum length of a tenant slug.
+pub const MAX_TENANT_SLUG_LEN: usize = 63;
+
+/// Request to create a tenant.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct CreateTenantRequest {
+    /// Human-readable tenant name.
+    pub name: String,
+    /// URL-safe slug: lowercase letters, digits and hyphens.
+    pub slug: String,
+    /// Resource quotas (platform defaults when omitted).
+    #[serde(default)]
+    pub quotas: TenantQuota,
+    /// Arbitrary metadata (an empty object when omitted).
+    #[serde(default)]
+    pub metadata: Option<Value>,
+}
+
+impl CreateTenantRequest {
+    /// Checks the name and slug.
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.name.trim().is_empty() {
+            return Err(CoreError::ValidationError(
+                "tenant name must not be empty".to_string(),
+            ));
+        }
+        let slug = &self.slug;
+        let valid = !slug.is_empty()
+            && slug.len() <= MAX_TENANT_SLUG_LEN
+            && !slug.starts_with('-')
+            && !slug.ends_with('-')
+            && slug
+                .chars()
+                .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '-');
+        if !valid {
+            return Err(CoreError::ValidationError(format!(
+                "invalid tenant slug `{}`: use 1 to {} lowercase letters, digits and inner hyphens",
+                slug, MAX_TENANT_SLUG_LEN
+            )));
+        }
+        if matches!(&self.metadata, Some(metadata) if !metadata.is_object()) {
+            return Err(CoreError::ValidationError(
+                "tenant metadata must be a JSON object".to_string(),
+            ));
+        }
+        Ok(())
+    }
+
+    /// Builds the descriptor of the new tenant, active.
+    #[must_use]
+    pub fn into_descriptor(self) -> TenantDescriptor {
+        let mut tenant = TenantDescriptor::new(self.name, self.slug);
+        tenant.quotas = self.quotas;
+        if let Some(metadata) = self.metadata {
+            tenant.metadata = metadata;
+        }
+        tenant.status = TenantStatus::Active;
+        tenant
+    }
+
+    /// Whether `tenant` is the tenant this request creates, so creating it
+    /// again is a no-op. A soft-deleted tenant never matches.
+    #[must_use]
+    pub fn matches(&self, tenant: &TenantDescriptor) -> bool {
+        tenant.slug == self.slug
+            && tenant.name == self.name
+            && tenant.quotas == self.quotas
+            && tenant.deletion().is_none()
+    }
 }
@@ -1,4 +1,5 @@
 use async_trait::async_trait;
+use chrono::{DateTime, Utc};
 
 use crate::audit::AuditLogEntry;
 use crate::auth::ApiKeyDescriptor;
@@ -143,6 +144,13 @@ pub trait ApiKeyRepository: Send + Sync {
     /// Deletes an API key (revocation).
     async fn delete(&self, key_id: ApiKeyId) -> CoreResult<()>;
 
+    /// Sets when an API key expires (None = never expires).
+    async fn set_expires_at(
+        &self,
+        key_id: ApiKeyId,
+        expires_at: Option<DateTime<Utc>>,
+    ) -> CoreResult<()>;
+
     /// Updates the last_used_at timestamp for an API key.
     async fn update_last_used(&self, key_id: ApiKeyId) -> CoreResult<()>;
 }
@@ -153,6 +161,12 @@ pub trait VectorIndex: Send + Sync {
     /// Inserts a vector document into the index.
     async fn insert(&self, doc: VectorDocument) -> CoreResult<()>;
 
+    /// Inserts a document, replacing any document with the same ID.
+    ///
+    /// The replacement is atomic: searches find either the previous or the
+    /// new document, never neither.
+    async fn upsert(&self, doc: VectorDocument) -> CoreResult<()>;
+
     /// Inserts multiple documents in a batch.
     ///
     /// Default implementation calls `insert` for each document sequentially.
@@ -190,6 +204,27 @@ pub trait VectorIndex: Send + Sync {
     /// Retrieves a document by ID (for verification).
     async fn get(&self, doc_id: DocumentId) -> CoreResult<Option<VectorDocument>>;
 
+    /// Returns all (non-deleted) documents in the index, in unspecified order.
+    async fn list(&self) -> CoreResult<Vec<VectorDocument>>;
+
+    /// Returns up to `limit` documents with IDs after `after` (from the
+    /// first without one), in ID order.
+    ///
+    /// Default implementation sorts `list`. Implementations keeping their
+    /// documents by ID should override it with a range seek, so paging
+    /// through the index costs O(log n + limit) per page.
+    async fn list_after(
+        &self,
+        after: Option<DocumentId>,
+        limit: usize,
+    ) -> CoreResult<Vec<VectorDocument>> {
+        let mut docs = self.list().await?;
+        docs.retain(|doc| after.map_or(true, |after| doc.doc_id > after));
+        docs.sort_unstable_by_key(|doc| doc.doc_id);
+        docs.truncate(limit);
+        Ok(docs)
+    }
+
     /// Returns the total number of documents in the index.
     async fn count(&self) -> CoreResult<usize>;
 
@@ -3,7 +3,9 @@
 use chrono::{DateTime, Utc};
 use serde::{Deserialize, Serialize};
 use serde_json::Value as JsonValue;
+use std::collections::{BTreeMap, HashMap};
 
+use crate::error::{CoreError, CoreResult};
 use crate::ids::DocumentId;
 use crate::DistanceMetric;
 
@@ -24,6 +26,14 @@ pub struct VectorDocument {
 
     /// Timestamp when document was inserted
     pub inserted_at: DateTime<Utc>,
+
+    /// Sparse vector stored alongside the dense one (optional)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub sparse_vector: Option<SparseVector>,
+
+    /// Vectors in the collection's named vector spaces, by space (optional)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub named_vectors: Option<HashMap<String, Vec<f32>>>,
 }
 
 impl VectorDocument {
@@ -36,6 +46,8 @@ impl VectorDocument {
             vector,
             metadata: None,
             inserted_at: Utc::now(),
+            sparse_vector: None,
+            named_vectors: None,
         }
     }
 
@@ -63,6 +75,20 @@ impl VectorDocument {
         self
     }
 
+    /// Sets the sparse vector (builder pattern).
+    #[must_use]
+    pub fn with_sparse_vector(mut self, sparse_vector: SparseVector) -> Self {
+        self.sparse_vector = Some(sparse_vector);
+        self
+    }
+
+    /// Sets the named vectors (builder pattern).
+    #[must_use]
+    pub fn with_named_vectors(mut self, named_vectors: HashMap<String, Vec<f32>>) -> Self {
+        self.named_vectors = Some(named_vectors);
+        self
+    }
+
     /// Returns the dimension of the vector.
     #[must_use]
     pub fn dimension(&self) -> usize {
@@ -70,6 +96,76 @@ impl VectorDocument {
     }
 }
 
+/// Sparse vector: sorted, de-duplicated dimension indices with their weights.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct SparseVector {
+    pub indices: Vec<u32>,
+    pub values: Vec<f32>,
+}
+
+impl SparseVector {
+    /// Builds a sparse vector from (index, weight) pairs.
+    ///
+    /// Weights for repeated indices are summed; zero weights are dropped.
+    pub fn from_pairs(pairs: impl IntoIterator<Item = (u32, f32)>) -> Self {
+        let mut merged: BTreeMap<u32, f32> = BTreeMap::new();
+        for (index, value) in pairs {
+            *merged.entry(index).or_insert(0.0) += value;
+        }
+        let (indices, values) = merged.into_iter().filter(|(_, v)| *v != 0.0).unzip();
+        Self { indices, values }
+    }
+
+    /// Number of non-zero entries.
+    pub fn len(&self) -> usize {
+        self.indices.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.indices.is_empty()
+    }
+
+    /// Rejects vectors whose indices are not strictly ascending, whose
+    /// lengths differ or whose values are not finite.
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.indices.len() != self.values.len() {
+            return Err(CoreError::ValidationError(format!(
+                "sparse vector has {} indices but {} values",
+                self.indices.len(),
+                self.values.len()
+            )));
+        }
+        if self.indices.windows(2).any(|w| w[0] >= w[1]) {
+            return Err(CoreError::ValidationError(
+                "sparse vector indices must be strictly ascending".to_string(),
+            ));
+        }
+        if self.values.iter().any(|v| !v.is_finite()) {
+            return Err(CoreError::ValidationError(
+                "sparse vector values must be finite".to_string(),
+            ));
+        }
+        Ok(())
+    }
+
+    /// Dot product with another sparse vector.
+    pub fn dot(&self, other: &SparseVector) -> f32 {
+        let (mut i, mut j, mut sum) = (0, 0, 0.0);
+        while i < self.indices.len() && j < other.indices.len() {
+            match self.indices[i].cmp(&other.indices[j]) {
+                std::cmp::Ordering::Less => i += 1,
+                std::cmp::Ordering::Greater => j += 1,
+                std::cmp::Ordering::Equal => {
+                    sum += self.values[i] * other.values[j];
+                    i += 1;
+                    j += 1;
+                }
+            }
+        }
+        sum
+    }
+}
+
 /// Result of a vector search operation.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct SearchResult {
@@ -84,6 +180,10 @@ pub struct SearchResult {
 
     /// Document metadata (if requested)
     pub metadata: Option<JsonValue>,
+
+    /// Stored vector (if requested)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub vector: Option<Vec<f32>>,
 }
 
 impl SearchResult {
@@ -95,6 +195,7 @@ impl SearchResult {
             external_id: None,
             score,
             metadata: None,
+            vector: None,
         }
     }
 
@@ -111,6 +212,13 @@ impl SearchResult {
         self.metadata = Some(metadata);
         self
     }
+
+    /// Sets the stored vector (builder pattern).
+    #[must_use]
+    pub fn with_vector(mut self, vector: Vec<f32>) -> Self {
+        self.vector = Some(vector);
+        self
+    }
 }
 
 /// Computes the cosine similarity between two vectors.
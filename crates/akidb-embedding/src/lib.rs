@@ -17,6 +17,7 @@ mod onnx;
 mod python_bridge;
 mod mock;
 mod provider;
+mod rate_limit;
 mod types;
 
 #[cfg(feature = "mlx")]
@@ -27,6 +28,7 @@ pub use onnx::{ExecutionProviderConfig, OnnxConfig, OnnxEmbeddingProvider};
 pub use python_bridge::PythonBridgeProvider;
 pub use mock::MockEmbeddingProvider;
 pub use provider::EmbeddingProvider;
+pub use rate_limit::{estimate_tokens, RateLimitedProvider, RateLimits};
 pub use types::{
     BatchEmbeddingRequest, BatchEmbeddingResponse, EmbeddingError, EmbeddingResult, ModelInfo,
     Usage,
@@ -0,0 +1,485 @@
+//! Rate-limit aware batching for hosted embedding providers.
+//!
+//! Hosted embedding APIs limit requests and tokens per minute (RPM / TPM)
+//! and the inputs per request. `RateLimitedProvider` wraps a provider and
+//! splits batches to stay within its `RateLimits`: every sub-batch waits
+//! until a request and its estimated tokens are available in token buckets
+//! refilled continuously at the per-minute rates, so a large ingestion runs
+//! at the limits without exceeding them. Token usage reported by the
+//! provider corrects the estimates.
+//!
+//! When the provider still rejects a request with
+//! `EmbeddingError::RateLimited` (e.g. the limits are shared with other
+//! clients), the sub-batch is retried after the provider's `Retry-After`
+//! delay, or an exponential backoff without one, and the batch size is
+//! halved. It grows back by one input per accepted request.
+
+use std::time::{Duration, Instant};
+
+use async_trait::async_trait;
+use parking_lot::Mutex;
+use serde::{Deserialize, Serialize};
+
+use crate::provider::EmbeddingProvider;
+use crate::types::{
+    BatchEmbeddingRequest, BatchEmbeddingResponse, EmbeddingError, EmbeddingResult, ModelInfo,
+    Usage,
+};
+
+/// First backoff after a rate-limited request without `Retry-After`.
+const INITIAL_BACKOFF: Duration = Duration::from_secs(1);
+
+/// Longest backoff between retries.
+const MAX_BACKOFF: Duration = Duration::from_secs(60);
+
+/// Estimated tokens of an input (about four bytes per token for English
+/// text with common BPE tokenizers).
+#[must_use]
+pub fn estimate_tokens(text: &str) -> usize {
+    text.len().div_ceil(4).max(1)
+}
+
+/// Limits of an embedding provider account.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct RateLimits {
+    /// Requests per minute (None: unlimited).
+    pub requests_per_minute: Option<u32>,
+    /// Input tokens per minute (None: unlimited).
+    pub tokens_per_minute: Option<u32>,
+    /// Maximum inputs per request.
+    pub max_batch_inputs: usize,
+    /// Retries of a rate-limited request before giving up.
+    pub max_retries: u32,
+}
+
+impl RateLimits {
+    /// Default maximum inputs per request.
+    pub const DEFAULT_MAX_BATCH_INPUTS: usize = 256;
+    /// Default retries of a rate-limited request.
+    pub const DEFAULT_MAX_RETRIES: u32 = 5;
+
+    /// OpenAI embeddings with the account's RPM and TPM limits (which
+    /// depend on its usage tier), at most 2048 inputs per request.
+    #[must_use]
+    pub fn openai(requests_per_minute: u32, tokens_per_minute: u32) -> Self {
+        Self {
+            requests_per_minute: Some(requests_per_minute),
+            tokens_per_minute: Some(tokens_per_minute),
+            max_batch_inputs: 2048,
+            ..Self::default()
+        }
+    }
+
+    /// Cohere embed with the key's RPM limit, at most 96 inputs per request.
+    #[must_use]
+    pub fn cohere(requests_per_minute: u32) -> Self {
+        Self {
+            requests_per_minute: Some(requests_per_minute),
+            tokens_per_minute: None,
+            max_batch_inputs: 96,
+            ..Self::default()
+        }
+    }
+
+    /// Checks that every limit is non-zero.
+    ///
+    /// # Errors
+    ///
+    /// Returns `InvalidInput` naming the first zero limit.
+    pub fn validate(&self) -> EmbeddingResult<()> {
+        for (name, limit) in [
+            ("requests_per_minute", self.requests_per_minute),
+            ("tokens_per_minute", self.tokens_per_minute),
+            ("max_batch_inputs", Some(self.max_batch_inputs as u32)),
+        ] {
+            if limit == Some(0) {
+                return Err(EmbeddingError::InvalidInput(format!(
+                    "{name} must be greater than 0"
+                )));
+            }
+        }
+        Ok(())
+    }
+}
+
+impl Default for RateLimits {
+    fn default() -> Self {
+        Self {
+            requests_per_minute: None,
+            tokens_per_minute: None,
+            max_batch_inputs: Self::DEFAULT_MAX_BATCH_INPUTS,
+            max_retries: Self::DEFAULT_MAX_RETRIES,
+        }
+    }
+}
+
+/// Budget refilled continuously up to its capacity.
+#[derive(Debug)]
+struct TokenBucket {
+    capacity: f64,
+    per_second: f64,
+    available: f64,
+    updated: Instant,
+}
+
+impl TokenBucket {
+    fn per_minute(limit: u32, now: Instant) -> Self {
+        let capacity = f64::from(limit);
+        Self {
+            capacity,
+            per_second: capacity / 60.0,
+            available: capacity,
+            updated: now,
+        }
+    }
+
+    fn refill(&mut self, now: Instant) {
+        let elapsed = now.saturating_duration_since(self.updated).as_secs_f64();
+        self.available = (self.available + elapsed * self.per_second).min(self.capacity);
+        self.updated = now;
+    }
+
+    /// Time until `amount` is available (zero if it is now).
+    fn wait_time(&mut self, amount: f64, now: Instant) -> Duration {
+        self.refill(now);
+        if self.available >= amount {
+            Duration::ZERO
+        } else {
+            Duration::from_secs_f64((amount - self.available) / self.per_second)
+        }
+    }
+
+    /// Spends `amount`; a negative amount gives budget back.
+    fn take(&mut self, amount: f64) {
+        self.available = (self.available - amount).min(self.capacity);
+    }
+}
+
+#[derive(Debug)]
+struct LimiterState {
+    requests: Option<TokenBucket>,
+    tokens: Option<TokenBucket>,
+    batch_size: usize,
+}
+
+/// Provider wrapper keeping requests within `RateLimits`.
+pub struct RateLimitedProvider<P> {
+    inner: P,
+    limits: RateLimits,
+    state: Mutex<LimiterState>,
+}
+
+impl<P: EmbeddingProvider> RateLimitedProvider<P> {
+    /// Wraps `inner`, starting with full per-minute budgets.
+    ///
+    /// # Errors
+    ///
+    /// Returns `InvalidInput` if a limit is zero.
+    pub fn new(inner: P, limits: RateLimits) -> EmbeddingResult<Self> {
+        limits.validate()?;
+        let now = Instant::now();
+        Ok(Self {
+            inner,
+            limits,
+            state: Mutex::new(LimiterState {
+                requests: limits
+                    .requests_per_minute
+                    .map(|limit| TokenBucket::per_minute(limit, now)),
+                tokens: limits
+                    .tokens_per_minute
+                    .map(|limit| TokenBucket::per_minute(limit, now)),
+                batch_size: limits.max_batch_inputs,
+            }),
+        })
+    }
+
+    /// The wrapped provider.
+    pub fn inner(&self) -> &P {
+        &self.inner
+    }
+
+    pub fn limits(&self) -> RateLimits {
+        self.limits
+    }
+
+    /// Current inputs per request (lowered after rate-limited requests).
+    pub fn batch_size(&self) -> usize {
+        self.state.lock().batch_size
+    }
+
+    /// Number and estimated tokens of the inputs sent in the next request.
+    fn next_batch(&self, inputs: &[String]) -> EmbeddingResult<(usize, usize)> {
+        let state = self.state.lock();
+        let token_limit = self
+            .limits
+            .tokens_per_minute
+            .map_or(usize::MAX, |l| l as usize);
+        let (mut count, mut tokens) = (0, 0);
+        for input in inputs.iter().take(state.batch_size) {
+            let estimate = estimate_tokens(input);
+            if tokens + estimate > token_limit {
+                break;
+            }
+            count += 1;
+            tokens += estimate;
+        }
+        if count == 0 {
+            return Err(EmbeddingError::InvalidInput(format!(
+                "input of about {} tokens exceeds the limit of {} tokens per minute",
+                estimate_tokens(&inputs[0]),
+                token_limit
+            )));
+        }
+        Ok((count, tokens))
+    }
+
+    /// Waits until a request of `tokens` fits the per-minute budgets, then
+    /// spends them.
+    async fn acquire(&self, tokens: usize) {
+        loop {
+            let wait = {
+                let mut state = self.state.lock();
+                let now = Instant::now();
+                let wait = [
+                    state.requests.as_mut().map(|b| b.wait_time(1.0, now)),
+                    state
+                        .tokens
+                        .as_mut()
+                        .map(|b| b.wait_time(tokens as f64, now)),
+                ]
+                .into_iter()
+                .flatten()
+                .max()
+                .unwrap_or_default();
+                if wait.is_zero() {
+                    if let Some(bucket) = state.requests.as_mut() {
+                        bucket.take(1.0);
+                    }
+                    if let Some(bucket) = state.tokens.as_mut() {
+                        bucket.take(tokens as f64);
+                    }
+                    return;
+                }
+                wait
+            };
+            tokio::time::sleep(wait).await;
+        }
+    }
+
+    /// Corrects the token budget with the tokens a request actually used.
+    fn settle(&self, estimated: usize, used: usize) {
+        let mut state = self.state.lock();
+        if let Some(bucket) = state.tokens.as_mut() {
+            bucket.take(used as f64 - estimated as f64);
+        }
+        state.batch_size = (state.batch_size + 1).min(self.limits.max_batch_inputs);
+    }
+
+    /// Halves the batch size after a rate-limited request.
+    fn back_off(&self) {
+        let mut state = self.state.lock();
+        state.batch_size = (state.batch_size / 2).max(1);
+    }
+}
+
+#[async_trait]
+impl<P: EmbeddingProvider> EmbeddingProvider for RateLimitedProvider<P> {
+    async fn embed_batch(
+        &self,
+        request: BatchEmbeddingRequest,
+    ) -> EmbeddingResult<BatchEmbeddingResponse> {
+        if request.inputs.is_empty() {
+            return Err(EmbeddingError::InvalidInput(
+                "empty input batch".to_string(),
+            ));
+        }
+        let start = Instant::now();
+        let mut embeddings = Vec::with_capacity(request.inputs.len());
+        let mut total_tokens = 0;
+        let mut model = request.model.clone();
+        let mut offset = 0;
+        let mut retries = 0;
+
+        while offset < request.inputs.len() {
+            let (count, estimated) = self.next_batch(&request.inputs[offset..])?;
+            self.acquire(estimated).await;
+            let batch = BatchEmbeddingRequest {
+                model: request.model.clone(),
+                inputs: request.inputs[offset..offset + count].to_vec(),
+                normalize: request.normalize,
+            };
+            match self.inner.embed_batch(batch).await {
+                Ok(response) => {
+                    self.settle(estimated, response.usage.total_tokens);
+                    embeddings.extend(response.embeddings);
+                    total_tokens += response.usage.total_tokens;
+                    model = response.model;
+                    offset += count;
+                    retries = 0;
+                }
+                Err(EmbeddingError::RateLimited { retry_after })
+                    if retries < self.limits.max_retries =>
+                {
+                    self.back_off();
+                    let backoff = INITIAL_BACKOFF
+                        .saturating_mul(1 << retries.min(16))
+                        .min(MAX_BACKOFF);
+                    retries += 1;
+                    tokio::time::sleep(retry_after.unwrap_or(backoff)).await;
+                }
+                Err(e) => return Err(e),
+            }
+        }
+
+        Ok(BatchEmbeddingResponse {
+            model,
+            embeddings,
+            usage: Usage {
+                total_tokens,
+                duration_ms: start.elapsed().as_millis() as u64,
+            },
+        })
+    }
+
+    async fn model_info(&self) -> EmbeddingResult<ModelInfo> {
+        self.inner.model_info().await
+    }
+
+    async fn health_check(&self) -> EmbeddingResult<()> {
+        self.inner.health_check().await
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::mock::MockEmbeddingProvider;
+    use std::sync::atomic::{AtomicU32, Ordering};
+
+    /// Mock recording batch sizes, rate-limited for its first requests.
+    struct Throttled {
+        inner: MockEmbeddingProvider,
+        rejections: AtomicU32,
+        batches: Mutex<Vec<usize>>,
+    }
+
+    impl Throttled {
+        fn new(rejections: u32) -> Self {
+            Self {
+                inner: MockEmbeddingProvider::new().with_latency(0),
+                rejections: AtomicU32::new(rejections),
+                batches: Mutex::new(Vec::new()),
+            }
+        }
+    }
+
+    #[async_trait]
+    impl EmbeddingProvider for Throttled {
+        async fn embed_batch(
+            &self,
+            request: BatchEmbeddingRequest,
+        ) -> EmbeddingResult<BatchEmbeddingResponse> {
+            self.batches.lock().push(request.inputs.len());
+            let rejected = self
+                .rejections
+                .fetch_update(Ordering::SeqCst, Ordering::SeqCst, |n| n.checked_sub(1))
+                .is_ok();
+            if rejected {
+                return Err(EmbeddingError::RateLimited {
+                    retry_after: Some(Duration::from_millis(1)),
+                });
+            }
+            self.inner.embed_batch(request).await
+        }
+
+        async fn model_info(&self) -> EmbeddingResult<ModelInfo> {
+            self.inner.model_info().await
+        }
+
+        async fn health_check(&self) -> EmbeddingResult<()> {
+            Ok(())
+        }
+    }
+
+    fn request(inputs: usize) -> BatchEmbeddingRequest {
+        BatchEmbeddingRequest {
+            model: MockEmbeddingProvider::DEFAULT_MODEL.to_string(),
+            inputs: (0..inputs).map(|i| format!("input {i}")).collect(),
+            normalize: false,
+        }
+    }
+
+    #[tokio::test]
+    async fn test_batches_split_and_retried() {
+        let limits = RateLimits {
+            max_batch_inputs: 4,
+            ..RateLimits::default()
+        };
+        let provider = RateLimitedProvider::new(Throttled::new(1), limits).unwrap();
+        let response = provider.embed_batch(request(7)).await.unwrap();
+
+        let direct = MockEmbeddingProvider::new().with_latency(0);
+        let expected = direct.embed_batch(request(7)).await.unwrap();
+        assert_eq!(response.embeddings, expected.embeddings);
+        assert_eq!(response.usage.total_tokens, expected.usage.total_tokens);
+        // Rejected at 4, halved to 2, then growing back by one
+        assert_eq!(*provider.inner().batches.lock(), [4, 2, 3, 2]);
+        assert_eq!(provider.batch_size(), 4);
+
+        let limits = RateLimits {
+            max_retries: 0,
+            ..RateLimits::default()
+        };
+        let provider = RateLimitedProvider::new(Throttled::new(1), limits).unwrap();
+        assert!(matches!(
+            provider.embed_batch(request(1)).await,
+            Err(EmbeddingError::RateLimited { .. })
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_batches_fit_token_limit() {
+        let limits = RateLimits {
+            tokens_per_minute: Some(6),
+            ..RateLimits::default()
+        };
+        let provider = RateLimitedProvider::new(Throttled::new(0), limits).unwrap();
+        // "input N" is about 2 tokens: three inputs per minute at most
+        let (count, tokens) = provider.next_batch(&request(5).inputs).unwrap();
+        assert_eq!((count, tokens), (3, 6));
+
+        let long = vec!["x".repeat(100)];
+        assert!(provider.next_batch(&long).is_err());
+        assert!(RateLimitedProvider::new(
+            Throttled::new(0),
+            RateLimits {
+                requests_per_minute: Some(0),
+                ..RateLimits::default()
+            }
+        )
+        .is_err());
+    }
+
+    #[test]
+    fn test_token_bucket() {
+        let start = Instant::now();
+        let mut bucket = TokenBucket::per_minute(60, start);
+        assert_eq!(bucket.wait_time(60.0, start), Duration::ZERO);
+        bucket.take(60.0);
+        assert_eq!(bucket.wait_time(1.0, start), Duration::from_secs(1));
+        let later = start + Duration::from_secs(30);
+        assert_eq!(bucket.wait_time(30.0, later), Duration::ZERO);
+        // Refills up to capacity only
+        assert_eq!(
+            bucket.wait_time(61.0, start + Duration::from_secs(600)),
+            Duration::from_secs(1)
+        );
+        // Unused estimates are given back
+        bucket.take(60.0);
+        bucket.take(-30.0);
+        assert_eq!(
+            bucket.wait_time(30.0, start + Duration::from_secs(600)),
+            Duration::ZERO
+        );
+    }
+}
@@ -1,4 +1,5 @@
 use serde::{Deserialize, Serialize};
+use std::time::Duration;
 use thiserror::Error;
 
 /// Error type for embedding operations.
@@ -19,6 +20,13 @@ pub enum EmbeddingError {
     /// Internal error during embedding generation.
     #[error("Internal error: {0}")]
     Internal(String),
+
+    /// The provider's rate limit was exceeded (e.g. HTTP 429).
+    #[error("Rate limited by provider (retry after {retry_after:?})")]
+    RateLimited {
+        /// How long the provider asked to wait (`Retry-After`), if it said.
+        retry_after: Option<Duration>,
+    },
 }
 
 /// Result type for embedding operations.
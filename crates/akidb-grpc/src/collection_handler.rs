@@ -1,16 +1,18 @@
-use akidb_core::{CollectionId, DocumentId, VectorDocument};
+use akidb_core::{CollectionId, CoreError, DocumentId, VectorDocument};
 use akidb_proto::{
-    collection_service_server::CollectionService as GrpcCollectionService, DeleteRequest,
-    DeleteResponse, DescribeRequest, DescribeResponse, GetRequest, GetResponse, InsertRequest,
-    InsertResponse, QueryRequest, QueryResponse, VectorDocument as ProtoVectorDocument,
-    VectorMatch,
+    collection_service_server::CollectionService as GrpcCollectionService,
+    ApplyReplicationRequest, ApplyReplicationResponse, DeleteRequest, DeleteResponse,
+    DescribeRequest, DescribeResponse, GetRequest, GetResponse, InsertRequest, InsertResponse,
+    QueryRequest, QueryResponse, VectorDocument as ProtoVectorDocument, VectorMatch,
 };
-use akidb_service::CollectionService;
+use akidb_service::{CollectionService, PostProcessingPipeline};
 use std::str::FromStr;
 use std::sync::Arc;
 use std::time::Instant;
 use tonic::{Request, Response, Status};
 
+use crate::replication::from_proto_ops;
+
 pub struct CollectionHandler {
     service: Arc<CollectionService>,
 }
@@ -39,18 +41,48 @@ impl GrpcCollectionService for CollectionHandler {
             return Err(Status::invalid_argument("query_vector cannot be empty"));
         }
 
-        // Perform search
-        let results = self
+        // top_k = 0 uses the collection's default, then the tenant's
+        let defaults = self
             .service
-            .query(collection_id, req.query_vector, req.top_k as usize)
+            .search_defaults(collection_id)
             .await
-            .map_err(|e| {
-                if e.to_string().contains("not found") {
-                    Status::not_found(e.to_string())
-                } else {
-                    Status::internal(e.to_string())
-                }
-            })?;
+            .map_err(|e| Status::not_found(e.to_string()))?;
+        let requested = (req.top_k > 0).then_some(req.top_k as usize);
+        let top_k = self
+            .service
+            .resolve_top_k(requested.or(defaults.top_k))
+            .await
+            .map_err(|e| Status::invalid_argument(e.to_string()))?;
+
+        // Perform search
+        let mut results = match defaults.min_score {
+            Some(min_score) => {
+                let pipeline = PostProcessingPipeline::new().with_threshold(min_score);
+                self.service
+                    .query_with_pipeline(collection_id, req.query_vector, top_k, &pipeline)
+                    .await
+            }
+            None => {
+                self.service
+                    .query(collection_id, req.query_vector, top_k)
+                    .await
+            }
+        }
+        .map_err(|e| {
+            if let CoreError::QuotaExceeded { .. } = e {
+                Status::invalid_argument(e.to_string())
+            } else if e.to_string().contains("not found") {
+                Status::not_found(e.to_string())
+            } else {
+                Status::internal(e.to_string())
+            }
+        })?;
+        if req.include_vectors {
+            self.service
+                .attach_vectors(collection_id, &mut results)
+                .await
+                .map_err(|e| Status::internal(e.to_string()))?;
+        }
 
         // Convert to protobuf
         let matches = results
@@ -59,6 +91,7 @@ impl GrpcCollectionService for CollectionHandler {
                 doc_id: r.doc_id.to_string(),
                 external_id: r.external_id,
                 distance: r.score,
+                vector: r.vector.unwrap_or_default(),
             })
             .collect();
 
@@ -78,8 +111,13 @@ impl GrpcCollectionService for CollectionHandler {
         let collection_id = CollectionId::from_str(&req.collection_id)
             .map_err(|e| Status::invalid_argument(format!("Invalid collection_id: {}", e)))?;
 
-        let doc_id = DocumentId::from_str(&req.doc_id)
-            .map_err(|e| Status::invalid_argument(format!("Invalid doc_id: {}", e)))?;
+        // Assign an ID when the client leaves it empty
+        let doc_id = if req.doc_id.is_empty() {
+            DocumentId::new()
+        } else {
+            DocumentId::from_str(&req.doc_id)
+                .map_err(|e| Status::invalid_argument(format!("Invalid doc_id: {}", e)))?
+        };
 
         if req.vector.is_empty() {
             return Err(Status::invalid_argument("vector cannot be empty"));
@@ -90,13 +128,15 @@ impl GrpcCollectionService for CollectionHandler {
             doc = doc.with_external_id(external_id);
         }
 
-        let inserted_id = self.service.insert(collection_id, doc).await.map_err(|e| {
-            if e.to_string().contains("not found") {
-                Status::not_found(e.to_string())
-            } else {
-                Status::internal(e.to_string())
-            }
-        })?;
+        let inserted_id = self
+            .service
+            .insert(collection_id, doc)
+            .await
+            .map_err(|e| match e {
+                CoreError::ReadOnly { .. } => Status::failed_precondition(e.to_string()),
+                _ if e.to_string().contains("not found") => Status::not_found(e.to_string()),
+                _ => Status::internal(e.to_string()),
+            })?;
 
         Ok(Response::new(InsertResponse {
             doc_id: inserted_id.to_string(),
@@ -147,12 +187,12 @@ impl GrpcCollectionService for CollectionHandler {
         self.service
             .delete(collection_id, doc_id)
             .await
-            .map_err(|e| {
-                if e.to_string().contains("not found") {
-                    Status::not_found(e.to_string())
-                } else {
-                    Status::internal(e.to_string())
+            .map_err(|e| match e {
+                CoreError::ReadOnly { .. } | CoreError::UnderLegalHold { .. } => {
+                    Status::failed_precondition(e.to_string())
                 }
+                _ if e.to_string().contains("not found") => Status::not_found(e.to_string()),
+                _ => Status::internal(e.to_string()),
             })?;
 
         Ok(Response::new(DeleteResponse {
@@ -187,4 +227,30 @@ impl GrpcCollectionService for CollectionHandler {
             document_count: document_count as u64,
         }))
     }
+
+    async fn apply_replication(
+        &self,
+        request: Request<ApplyReplicationRequest>,
+    ) -> Result<Response<ApplyReplicationResponse>, Status> {
+        let req = request.into_inner();
+
+        let collection_id = CollectionId::from_str(&req.collection_id)
+            .map_err(|e| Status::invalid_argument(format!("Invalid collection_id: {}", e)))?;
+        let ops = from_proto_ops(req.ops)?;
+
+        let applied = self
+            .service
+            .apply_replicated(collection_id, ops)
+            .await
+            .map_err(|e| match e {
+                CoreError::ValidationError(_) => Status::invalid_argument(e.to_string()),
+                CoreError::ReadOnly { .. } => Status::failed_precondition(e.to_string()),
+                _ if e.to_string().contains("not found") => Status::not_found(e.to_string()),
+                _ => Status::internal(e.to_string()),
+            })?;
+
+        Ok(Response::new(ApplyReplicationResponse {
+            applied: applied as u64,
+        }))
+    }
 }
@@ -1,7 +1,9 @@
 mod collection_handler;
 mod embedding_handler;
 mod management_handler;
+mod replication;
 
 pub use collection_handler::CollectionHandler;
 pub use embedding_handler::EmbeddingHandler;
 pub use management_handler::CollectionManagementHandler;
+pub use replication::{GrpcReplicationTransport, REPLICATION_RPC_TIMEOUT};
@@ -3,7 +3,9 @@ use akidb_metadata::{SqliteCollectionRepository, VectorPersistence};
 use akidb_proto::collection_management_service_server::CollectionManagementServiceServer;
 use akidb_proto::collection_service_server::CollectionServiceServer;
 use akidb_proto::embedding::embedding_service_server::EmbeddingServiceServer;
-use akidb_service::{CollectionService, Config, EmbeddingManager};
+use akidb_service::{
+    CollectionService, Config, EmbeddingManager, GROWTH_SAMPLE_INTERVAL, MAX_REPLICATION_BODY_BYTES,
+};
 use sqlx::SqlitePool;
 use std::sync::Arc;
 use tonic::transport::Server;
@@ -112,6 +114,9 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
     let collection_count = service.list_collections().await?.len();
     tracing::info!("✅ Loaded {} collection(s)", collection_count);
 
+    // Sample collection sizes for capacity forecasting
+    service.spawn_growth_sampler(GROWTH_SAMPLE_INTERVAL);
+
     // Initialize EmbeddingManager from configuration
     let embedding_config = &config.embedding;
     tracing::info!(
@@ -154,7 +159,11 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
     tracing::info!("🚀 gRPC server listening on {}", addr);
 
     let mut server_builder = Server::builder()
-        .add_service(CollectionServiceServer::new(collection_handler))
+        .add_service(
+            // Replicated batches can exceed the default 4 MiB message limit
+            CollectionServiceServer::new(collection_handler)
+                .max_decoding_message_size(MAX_REPLICATION_BODY_BYTES),
+        )
         .add_service(CollectionManagementServiceServer::new(management_handler));
 
     // Conditionally add embedding service if manager is available
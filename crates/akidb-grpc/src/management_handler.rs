@@ -1,11 +1,13 @@
-use akidb_core::{CollectionId, DistanceMetric};
+use akidb_core::{CollectionId, CoreError, DistanceMetric};
 use akidb_proto::{
     collection_management_service_server::CollectionManagementService as GrpcCollectionManagementService,
     CollectionInfo, CreateCollectionRequest, CreateCollectionResponse, DeleteCollectionRequest,
-    DeleteCollectionResponse, GetCollectionRequest, GetCollectionResponse, ListCollectionsRequest,
+    DeleteCollectionResponse, EstimateImportCostRequest, EstimateImportCostResponse,
+    EstimateSearchCostRequest, EstimateSearchCostResponse, ForecastRequest, ForecastResponse,
+    GetCollectionRequest, GetCollectionResponse, GrowthSample, ListCollectionsRequest,
     ListCollectionsResponse,
 };
-use akidb_service::CollectionService;
+use akidb_service::{CollectionService, ForecastLimits, IndexKind};
 use std::str::FromStr;
 use std::sync::Arc;
 use tonic::{Request, Response, Status};
@@ -33,11 +35,12 @@ impl GrpcCollectionManagementService for CollectionManagementHandler {
             return Err(Status::invalid_argument("name cannot be empty"));
         }
 
-        // Parse metric
+        // Parse metric (empty = tenant default)
         let metric = match req.metric.to_lowercase().as_str() {
-            "cosine" => DistanceMetric::Cosine,
-            "l2" => DistanceMetric::L2,
-            "dot" => DistanceMetric::Dot,
+            "cosine" => Some(DistanceMetric::Cosine),
+            "l2" => Some(DistanceMetric::L2),
+            "dot" => Some(DistanceMetric::Dot),
+            "" => None,
             _ => {
                 return Err(Status::invalid_argument(format!(
                     "invalid metric: '{}', must be one of: cosine, l2, dot",
@@ -45,6 +48,11 @@ impl GrpcCollectionManagementService for CollectionManagementHandler {
                 )))
             }
         };
+        let metric = self
+            .service
+            .resolve_metric(metric)
+            .await
+            .map_err(|e| Status::invalid_argument(e.to_string()))?;
 
         // Create collection
         let collection_id = self
@@ -63,7 +71,7 @@ impl GrpcCollectionManagementService for CollectionManagementHandler {
             collection_id: collection_id.to_string(),
             name: req.name,
             dimension: req.dimension,
-            metric: req.metric,
+            metric: metric.as_str().to_string(),
         }))
     }
 
@@ -86,6 +94,7 @@ impl GrpcCollectionManagementService for CollectionManagementHandler {
                 metric: c.metric.as_str().to_string(),
                 document_count: 0, // TODO: Get actual count from service
                 created_at: c.created_at.to_rfc3339(),
+                updated_at: c.updated_at.to_rfc3339(),
             })
             .collect();
 
@@ -126,6 +135,7 @@ impl GrpcCollectionManagementService for CollectionManagementHandler {
                 metric: collection.metric.as_str().to_string(),
                 document_count,
                 created_at: collection.created_at.to_rfc3339(),
+                updated_at: collection.updated_at.to_rfc3339(),
             }),
         }))
     }
@@ -142,14 +152,130 @@ impl GrpcCollectionManagementService for CollectionManagementHandler {
         self.service
             .delete_collection(collection_id)
             .await
-            .map_err(|e| {
-                if e.to_string().contains("not found") {
-                    Status::not_found(e.to_string())
-                } else {
-                    Status::internal(e.to_string())
+            .map_err(|e| match e {
+                CoreError::ReadOnly { .. } | CoreError::UnderLegalHold { .. } => {
+                    Status::failed_precondition(e.to_string())
                 }
+                _ if e.to_string().contains("not found") => Status::not_found(e.to_string()),
+                _ => Status::internal(e.to_string()),
             })?;
 
         Ok(Response::new(DeleteCollectionResponse { success: true }))
     }
+
+    async fn forecast(
+        &self,
+        request: Request<ForecastRequest>,
+    ) -> Result<Response<ForecastResponse>, Status> {
+        let req = request.into_inner();
+        let horizon_days = req.horizon_days.unwrap_or(30.0);
+        let limits = ForecastLimits {
+            max_vectors: req.max_vectors,
+            memory_bytes: req.memory_limit_bytes,
+        };
+
+        let (forecast, history) = if req.collection_id.is_empty() {
+            let forecast = self
+                .service
+                .forecast_capacity(horizon_days, limits)
+                .await
+                .map_err(to_status)?;
+            (forecast, Vec::new())
+        } else {
+            let collection_id = CollectionId::from_str(&req.collection_id)
+                .map_err(|e| Status::invalid_argument(format!("Invalid collection_id: {}", e)))?;
+            let forecast = self
+                .service
+                .forecast_growth(collection_id, horizon_days, limits)
+                .await
+                .map_err(to_status)?;
+            let history = self
+                .service
+                .growth_history(collection_id)
+                .await
+                .map_err(to_status)?;
+            (forecast, history)
+        };
+
+        Ok(Response::new(ForecastResponse {
+            vector_count: forecast.trend.vector_count,
+            estimated_bytes: forecast.trend.estimated_bytes,
+            vectors_per_day: forecast.trend.vectors_per_day,
+            bytes_per_day: forecast.trend.bytes_per_day,
+            horizon_days: forecast.horizon_days,
+            projected_vector_count: forecast.projected_vector_count,
+            projected_bytes: forecast.projected_bytes,
+            days_until_vector_limit: forecast.days_until_vector_limit,
+            days_until_memory_limit: forecast.days_until_memory_limit,
+            history: history
+                .into_iter()
+                .map(|s| GrowthSample {
+                    timestamp: s.timestamp.to_rfc3339(),
+                    vector_count: s.vector_count,
+                    estimated_bytes: s.estimated_bytes,
+                })
+                .collect(),
+        }))
+    }
+
+    async fn estimate_search_cost(
+        &self,
+        request: Request<EstimateSearchCostRequest>,
+    ) -> Result<Response<EstimateSearchCostResponse>, Status> {
+        let req = request.into_inner();
+        let collection_id = CollectionId::from_str(&req.collection_id)
+            .map_err(|e| Status::invalid_argument(format!("Invalid collection_id: {}", e)))?;
+
+        let estimate = self
+            .service
+            .estimate_search_cost(collection_id, req.top_k as usize, req.queries.unwrap_or(1))
+            .await
+            .map_err(to_status)?;
+
+        Ok(Response::new(EstimateSearchCostResponse {
+            index: index_kind_name(estimate.index).to_string(),
+            queries: estimate.queries,
+            vectors_scanned: estimate.vectors_scanned,
+            compute_units: estimate.compute_units,
+            read_units: estimate.read_units,
+        }))
+    }
+
+    async fn estimate_import_cost(
+        &self,
+        request: Request<EstimateImportCostRequest>,
+    ) -> Result<Response<EstimateImportCostResponse>, Status> {
+        let req = request.into_inner();
+        let collection_id = CollectionId::from_str(&req.collection_id)
+            .map_err(|e| Status::invalid_argument(format!("Invalid collection_id: {}", e)))?;
+
+        let estimate = self
+            .service
+            .estimate_import_cost(collection_id, req.vectors, req.avg_metadata_bytes)
+            .await
+            .map_err(to_status)?;
+
+        Ok(Response::new(EstimateImportCostResponse {
+            index: index_kind_name(estimate.index).to_string(),
+            vectors: estimate.vectors,
+            bytes: estimate.bytes,
+            compute_units: estimate.compute_units,
+            write_units: estimate.write_units,
+        }))
+    }
+}
+
+fn to_status(e: CoreError) -> Status {
+    match e {
+        CoreError::NotFound { .. } => Status::not_found(e.to_string()),
+        CoreError::ValidationError(_) => Status::invalid_argument(e.to_string()),
+        _ => Status::internal(e.to_string()),
+    }
+}
+
+fn index_kind_name(kind: IndexKind) -> &'static str {
+    match kind {
+        IndexKind::BruteForce => "brute_force",
+        IndexKind::Hnsw => "hnsw",
+    }
 }
@@ -6,7 +6,8 @@
 //! - A viable option for small collections (< 10k vectors)
 //! - A testing baseline for recall validation
 
-use std::collections::HashMap;
+use std::collections::BTreeMap;
+use std::ops::Bound;
 
 use async_trait::async_trait;
 
@@ -50,8 +51,8 @@ pub struct BruteForceIndex {
     /// Distance metric
     metric: DistanceMetric,
 
-    /// In-memory document storage
-    documents: Arc<RwLock<HashMap<DocumentId, VectorDocument>>>,
+    /// In-memory document storage, by ID (for paging in ID order)
+    documents: Arc<RwLock<BTreeMap<DocumentId, VectorDocument>>>,
 }
 
 impl BruteForceIndex {
@@ -70,7 +71,7 @@ impl BruteForceIndex {
         Self {
             dim,
             metric,
-            documents: Arc::new(RwLock::new(HashMap::new())),
+            documents: Arc::new(RwLock::new(BTreeMap::new())),
         }
     }
 
@@ -85,11 +86,9 @@ impl BruteForceIndex {
     pub fn metric(&self) -> DistanceMetric {
         self.metric
     }
-}
 
-#[async_trait]
-impl VectorIndex for BruteForceIndex {
-    async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
+    /// Checks a document's vector before it is stored.
+    fn validate(&self, doc: &VectorDocument) -> CoreResult<()> {
         if doc.vector.len() != self.dim {
             return Err(CoreError::invalid_state(format!(
                 "Vector dimension mismatch: expected {}, got {}",
@@ -121,6 +120,14 @@ impl VectorIndex for BruteForceIndex {
                 ));
             }
         }
+        Ok(())
+    }
+}
+
+#[async_trait]
+impl VectorIndex for BruteForceIndex {
+    async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
+        self.validate(&doc)?;
 
         let mut docs = self.documents.write();
 
@@ -136,6 +143,12 @@ impl VectorIndex for BruteForceIndex {
         Ok(())
     }
 
+    async fn upsert(&self, doc: VectorDocument) -> CoreResult<()> {
+        self.validate(&doc)?;
+        self.documents.write().insert(doc.doc_id, doc);
+        Ok(())
+    }
+
     async fn search(
         &self,
         query: &[f32],
@@ -215,6 +228,25 @@ impl VectorIndex for BruteForceIndex {
         Ok(docs.get(&doc_id).cloned())
     }
 
+    async fn list(&self) -> CoreResult<Vec<VectorDocument>> {
+        let docs = self.documents.read();
+        Ok(docs.values().cloned().collect())
+    }
+
+    async fn list_after(
+        &self,
+        after: Option<DocumentId>,
+        limit: usize,
+    ) -> CoreResult<Vec<VectorDocument>> {
+        let start = after.map_or(Bound::Unbounded, Bound::Excluded);
+        let docs = self.documents.read();
+        Ok(docs
+            .range((start, Bound::Unbounded))
+            .take(limit)
+            .map(|(_, doc)| doc.clone())
+            .collect())
+    }
+
     async fn count(&self) -> CoreResult<usize> {
         let docs = self.documents.read();
         Ok(docs.len())
@@ -246,6 +278,70 @@ mod tests {
         assert_eq!(retrieved.vector, vec![1.0, 2.0, 3.0]);
     }
 
+    #[tokio::test]
+    async fn test_list_returns_all_documents() {
+        let index = BruteForceIndex::new(3, DistanceMetric::Cosine);
+        let doc_a = DocumentId::new();
+        let doc_b = DocumentId::new();
+        index
+            .insert(VectorDocument::new(doc_a, vec![1.0, 0.0, 0.0]))
+            .await
+            .unwrap();
+        index
+            .insert(VectorDocument::new(doc_b, vec![0.0, 1.0, 0.0]))
+            .await
+            .unwrap();
+        index.delete(doc_a).await.unwrap();
+
+        let docs = index.list().await.expect("list failed");
+        assert_eq!(docs.len(), 1);
+        assert_eq!(docs[0].doc_id, doc_b);
+    }
+
+    #[tokio::test]
+    async fn test_list_after_pages_in_id_order() {
+        let index = BruteForceIndex::new(3, DistanceMetric::Cosine);
+        let mut ids: Vec<DocumentId> = (0..5).map(|_| DocumentId::new()).collect();
+        for id in ids.iter().rev() {
+            index
+                .insert(VectorDocument::new(*id, vec![1.0, 0.0, 0.0]))
+                .await
+                .unwrap();
+        }
+        ids.sort_by_key(|id| id.to_string());
+
+        let first = index.list_after(None, 2).await.unwrap();
+        let first: Vec<_> = first.iter().map(|doc| doc.doc_id).collect();
+        assert_eq!(first, ids[..2]);
+        let rest = index.list_after(Some(ids[1]), 10).await.unwrap();
+        let rest: Vec<_> = rest.iter().map(|doc| doc.doc_id).collect();
+        assert_eq!(rest, ids[2..]);
+    }
+
+    #[tokio::test]
+    async fn test_upsert_replaces_document() {
+        let index = BruteForceIndex::new(3, DistanceMetric::Cosine);
+        let doc_id = DocumentId::new();
+        index
+            .upsert(VectorDocument::new(doc_id, vec![1.0, 0.0, 0.0]))
+            .await
+            .unwrap();
+        index
+            .upsert(VectorDocument::new(doc_id, vec![0.0, 1.0, 0.0]))
+            .await
+            .unwrap();
+
+        assert_eq!(index.count().await.unwrap(), 1);
+        let retrieved = index.get(doc_id).await.unwrap().unwrap();
+        assert_eq!(retrieved.vector, vec![0.0, 1.0, 0.0]);
+
+        // Validated like inserts
+        assert!(index
+            .upsert(VectorDocument::new(doc_id, vec![1.0, 2.0]))
+            .await
+            .is_err());
+    }
+
     #[tokio::test]
     async fn test_insert_dimension_mismatch() {
         let index = BruteForceIndex::new(3, DistanceMetric::Cosine);
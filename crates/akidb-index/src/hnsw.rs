@@ -463,16 +463,22 @@ impl HnswIndex {
     }
 
     /// Adds a bidirectional edge between two nodes at a specific layer.
+    ///
+    /// Self-edges and edges already present are skipped.
     fn add_edge(state: &mut HnswState, from: DocumentId, to: DocumentId, layer: usize) {
+        if from == to {
+            return;
+        }
+
         // Ensure layer exists
         while state.layers.len() <= layer {
             state.layers.push(HashMap::new());
         }
 
-        state.layers[layer]
-            .entry(from)
-            .or_insert_with(Vec::new)
-            .push(to);
+        let neighbors = state.layers[layer].entry(from).or_insert_with(Vec::new);
+        if !neighbors.contains(&to) {
+            neighbors.push(to);
+        }
     }
 
     /// Prunes connections if a node exceeds M neighbors.
@@ -530,11 +536,9 @@ impl HnswIndex {
             *neighbors = selected;
         }
     }
-}
 
-#[async_trait]
-impl VectorIndex for HnswIndex {
-    async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
+    /// Inserts a document into the graph under an already held write lock.
+    fn insert_locked(&self, state: &mut HnswState, doc: VectorDocument) -> CoreResult<()> {
         if doc.vector.len() != self.config.dim {
             return Err(CoreError::invalid_state(format!(
                 "Vector dimension mismatch: expected {}, got {}",
@@ -543,8 +547,6 @@ impl VectorIndex for HnswIndex {
             )));
         }
 
-        let mut state = self.state.write();
-
         // Assign random layer
         let target_layer = self.assign_layer();
 
@@ -571,7 +573,7 @@ impl VectorIndex for HnswIndex {
 
         // Search from top layer down to target layer
         for layer in ((target_layer + 1)..=state.max_layer).rev() {
-            let nearest = self.search_layer(&state, &doc.vector, &entry_points, 1, layer);
+            let nearest = self.search_layer(state, &doc.vector, &entry_points, 1, layer);
             if !nearest.is_empty() {
                 entry_points = vec![nearest[0].1];
             }
@@ -587,7 +589,7 @@ impl VectorIndex for HnswIndex {
 
             // Find candidates
             let candidates = self.search_layer(
-                &state,
+                state,
                 &doc.vector,
                 &entry_points,
                 self.config.ef_construction,
@@ -595,15 +597,15 @@ impl VectorIndex for HnswIndex {
             );
 
             // Select M neighbors using Algorithm 4 heuristic
-            let neighbors = self.select_neighbors(&state, &doc.vector, candidates.clone(), m);
+            let neighbors = self.select_neighbors(state, &doc.vector, candidates.clone(), m);
 
             // Add bidirectional edges
             for &neighbor_id in &neighbors {
-                Self::add_edge(&mut state, doc.doc_id, neighbor_id, layer);
-                Self::add_edge(&mut state, neighbor_id, doc.doc_id, layer);
+                Self::add_edge(state, doc.doc_id, neighbor_id, layer);
+                Self::add_edge(state, neighbor_id, doc.doc_id, layer);
 
                 // Prune neighbor's connections if needed
-                self.prune_connections(&mut state, neighbor_id, m, layer);
+                self.prune_connections(state, neighbor_id, m, layer);
             }
 
             // Update entry points for next layer (keep current if no neighbors found)
@@ -625,6 +627,55 @@ impl VectorIndex for HnswIndex {
         Ok(())
     }
 
+    /// Removes a node and the edges it holds from the graph.
+    ///
+    /// Edges other nodes kept after pruning still name the ID and lead to
+    /// whatever node is stored under it next.
+    fn unlink(state: &mut HnswState, doc_id: DocumentId) {
+        if state.nodes.remove(&doc_id).is_none() {
+            return;
+        }
+        for layer in state.layers.iter_mut() {
+            let Some(neighbors) = layer.remove(&doc_id) else {
+                continue;
+            };
+            for neighbor_id in neighbors {
+                if let Some(back) = layer.get_mut(&neighbor_id) {
+                    back.retain(|&id| id != doc_id);
+                }
+            }
+        }
+
+        // Hand the entry point to the highest remaining node
+        if state.entry_point == Some(doc_id) {
+            let next = state
+                .nodes
+                .values()
+                .max_by_key(|node| node.max_layer)
+                .map(|node| (node.doc_id, node.max_layer));
+            state.entry_point = next.map(|(id, _)| id);
+            state.max_layer = next.map_or(0, |(_, layer)| layer);
+        }
+    }
+}
+
+#[async_trait]
+impl VectorIndex for HnswIndex {
+    async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
+        let mut state = self.state.write();
+        self.insert_locked(&mut state, doc)
+    }
+
+    async fn upsert(&self, doc: VectorDocument) -> CoreResult<()> {
+        let mut state = self.state.write();
+        // Relink the node at its new position instead of keeping the old
+        // node's edges alongside the new ones
+        if state.nodes.contains_key(&doc.doc_id) && doc.vector.len() == self.config.dim {
+            Self::unlink(&mut state, doc.doc_id);
+        }
+        self.insert_locked(&mut state, doc)
+    }
+
     async fn search(
         &self,
         query: &[f32],
@@ -714,11 +765,32 @@ impl VectorIndex for HnswIndex {
                     vector: node.vector.clone(),
                     metadata: node.metadata.clone(),
                     inserted_at: chrono::Utc::now(), // Note: We don't store inserted_at in HNSW node
+                    sparse_vector: None,
+                    named_vectors: None,
                 })
             }
         }))
     }
 
+    async fn list(&self) -> CoreResult<Vec<VectorDocument>> {
+        let state = self.state.read();
+
+        Ok(state
+            .nodes
+            .values()
+            .filter(|node| !node.deleted)
+            .map(|node| VectorDocument {
+                doc_id: node.doc_id,
+                external_id: node.external_id.clone(),
+                vector: node.vector.clone(),
+                metadata: node.metadata.clone(),
+                inserted_at: chrono::Utc::now(), // Note: We don't store inserted_at in HNSW node
+                sparse_vector: None,
+                named_vectors: None,
+            })
+            .collect())
+    }
+
     async fn count(&self) -> CoreResult<usize> {
         let state = self.state.read();
         let count = state.nodes.values().filter(|n| !n.deleted).count();
@@ -853,6 +925,38 @@ mod tests {
         assert!(results[0].score < results[1].score);
     }
 
+    #[tokio::test]
+    async fn test_hnsw_upsert_relinks_node() {
+        let config = HnswConfig::balanced(2, DistanceMetric::L2);
+        let index = HnswIndex::new(config);
+
+        for i in 0..50 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![i as f32, 0.0]);
+            index.insert(doc).await.unwrap();
+        }
+        let moved = DocumentId::new();
+        for i in 0..10 {
+            let doc = VectorDocument::new(moved, vec![i as f32 * 5.0, 0.0]);
+            index.upsert(doc).await.unwrap();
+        }
+        assert_eq!(index.count().await.unwrap(), 51);
+
+        // No self-edges or repeated edges survive the moves
+        {
+            let state = index.state.read();
+            for layer in &state.layers {
+                for (node_id, neighbors) in layer {
+                    assert!(!neighbors.contains(node_id));
+                    let unique: HashSet<_> = neighbors.iter().collect();
+                    assert_eq!(unique.len(), neighbors.len());
+                }
+            }
+        }
+
+        let results = index.search(&[45.0, 0.0], 2, None).await.unwrap();
+        assert!(results.iter().any(|r| r.doc_id == moved));
+    }
+
     #[tokio::test]
     async fn test_hnsw_dimension_mismatch() {
         let config = HnswConfig::balanced(3, DistanceMetric::Cosine);
@@ -10,7 +10,8 @@ use akidb_core::{
 };
 use async_trait::async_trait;
 use instant_distance::{Builder, HnswMap, Point, Search};
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
+use std::ops::Bound;
 
 // Use crate-level sync module for conditional compilation (Loom vs production)
 use crate::{Arc, RwLock};
@@ -92,14 +93,28 @@ struct DocMetadata {
     inserted_at: chrono::DateTime<chrono::Utc>,
 }
 
+impl DocMetadata {
+    fn to_document(&self) -> VectorDocument {
+        let mut doc =
+            VectorDocument::new(self.doc_id, self.vector.clone()).with_timestamp(self.inserted_at);
+        if let Some(ref ext_id) = self.external_id {
+            doc = doc.with_external_id(ext_id.clone());
+        }
+        if let Some(ref meta_data) = self.metadata {
+            doc = doc.with_metadata(meta_data.clone());
+        }
+        doc
+    }
+}
+
 /// State for InstantDistanceIndex.
 struct InstantDistanceState {
     /// The HNSW index from instant-distance
     index: Option<HnswMap<VectorPoint, usize>>,
     /// Map from instant-distance ID to document metadata
     doc_map: HashMap<usize, DocMetadata>,
-    /// Map from DocumentId to instant-distance ID
-    id_map: HashMap<DocumentId, usize>,
+    /// Map from DocumentId to instant-distance ID (ordered, for paging in ID order)
+    id_map: BTreeMap<DocumentId, usize>,
     /// Next ID to assign
     next_id: usize,
     /// Whether the index needs rebuilding
@@ -171,7 +186,7 @@ impl InstantDistanceIndex {
             state: Arc::new(RwLock::new(InstantDistanceState {
                 index: None,
                 doc_map: HashMap::new(),
-                id_map: HashMap::new(),
+                id_map: BTreeMap::new(),
                 next_id: 0,
                 dirty: false,
             })),
@@ -292,11 +307,9 @@ impl InstantDistanceIndex {
             }
         }
     }
-}
 
-#[async_trait]
-impl VectorIndex for InstantDistanceIndex {
-    async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
+    /// Checks a document's vector before it is stored.
+    fn validate(&self, doc: &VectorDocument) -> CoreResult<()> {
         if doc.vector.len() != self.config.dim {
             return Err(CoreError::invalid_state(format!(
                 "Vector dimension mismatch: expected {}, got {}",
@@ -328,6 +341,14 @@ impl VectorIndex for InstantDistanceIndex {
                 ));
             }
         }
+        Ok(())
+    }
+}
+
+#[async_trait]
+impl VectorIndex for InstantDistanceIndex {
+    async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
+        self.validate(&doc)?;
 
         let mut state = self.state.write();
 
@@ -362,6 +383,36 @@ impl VectorIndex for InstantDistanceIndex {
         Ok(())
     }
 
+    async fn upsert(&self, doc: VectorDocument) -> CoreResult<()> {
+        self.validate(&doc)?;
+
+        let mut state = self.state.write();
+
+        // A replaced document keeps its instant ID
+        let instant_id = match state.id_map.get(&doc.doc_id) {
+            Some(&instant_id) => instant_id,
+            None => {
+                let instant_id = state.next_id;
+                state.next_id += 1;
+                state.id_map.insert(doc.doc_id, instant_id);
+                instant_id
+            }
+        };
+        let metadata = DocMetadata {
+            doc_id: doc.doc_id,
+            external_id: doc.external_id,
+            metadata: doc.metadata,
+            vector: doc.vector,
+            inserted_at: doc.inserted_at,
+        };
+        state.doc_map.insert(instant_id, metadata);
+
+        // ⚠️ BRITTLENESS WARNING: dirty flag MUST be set atomically with doc_map update
+        state.dirty = true;
+
+        Ok(())
+    }
+
     async fn search(
         &self,
         query: &[f32],
@@ -489,15 +540,32 @@ impl VectorIndex for InstantDistanceIndex {
             None => return Ok(None),
         };
 
-        let mut doc =
-            VectorDocument::new(doc_id, meta.vector.clone()).with_timestamp(meta.inserted_at);
-        if let Some(ref ext_id) = meta.external_id {
-            doc = doc.with_external_id(ext_id.clone());
-        }
-        if let Some(ref meta_data) = meta.metadata {
-            doc = doc.with_metadata(meta_data.clone());
-        }
-        Ok(Some(doc))
+        Ok(Some(meta.to_document()))
+    }
+
+    async fn list(&self) -> CoreResult<Vec<VectorDocument>> {
+        let state = self.state.read();
+        Ok(state
+            .doc_map
+            .values()
+            .map(DocMetadata::to_document)
+            .collect())
+    }
+
+    async fn list_after(
+        &self,
+        after: Option<DocumentId>,
+        limit: usize,
+    ) -> CoreResult<Vec<VectorDocument>> {
+        let start = after.map_or(Bound::Unbounded, Bound::Excluded);
+        let state = self.state.read();
+        Ok(state
+            .id_map
+            .range((start, Bound::Unbounded))
+            .filter_map(|(_, instant_id)| state.doc_map.get(instant_id))
+            .take(limit)
+            .map(DocMetadata::to_document)
+            .collect())
     }
 
     async fn count(&self) -> CoreResult<usize> {
@@ -566,6 +634,29 @@ mod tests {
         assert_eq!(results.len(), 5);
     }
 
+    #[tokio::test]
+    async fn test_instant_distance_upsert() {
+        let config = InstantDistanceConfig::balanced(128, DistanceMetric::Cosine);
+        let index = InstantDistanceIndex::new(config).unwrap();
+
+        let doc_id = DocumentId::new();
+        index
+            .upsert(VectorDocument::new(doc_id, vec![0.1; 128]))
+            .await
+            .unwrap();
+        index
+            .upsert(VectorDocument::new(doc_id, vec![0.2; 128]).with_external_id("v2".to_string()))
+            .await
+            .unwrap();
+
+        assert_eq!(index.count().await.unwrap(), 1);
+        let retrieved = index.get(doc_id).await.unwrap().unwrap();
+        assert_eq!(retrieved.vector, vec![0.2; 128]);
+        let results = index.search(&[0.2; 128], 5, None).await.unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].external_id.as_deref(), Some("v2"));
+    }
+
     #[tokio::test]
     async fn test_instant_distance_delete() {
         let config = InstantDistanceConfig::balanced(128, DistanceMetric::Cosine);
@@ -0,0 +1,9 @@
+-- Migration: Collection description and metadata
+-- Created: 2026-10-17
+--
+-- Collections can be described and labelled, and both can be changed in
+-- place along with the other mutable settings (HNSW parameters, document
+-- limit) instead of recreating the collection.
+
+ALTER TABLE collections ADD COLUMN description TEXT;
+ALTER TABLE collections ADD COLUMN metadata TEXT;
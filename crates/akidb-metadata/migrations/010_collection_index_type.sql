@@ -0,0 +1,7 @@
+-- Migration: Collection index type
+-- Created: 2026-10-17
+--
+-- The index structure (flat or HNSW) can be chosen when a collection is
+-- created. Existing collections keep the automatic choice by document limit.
+
+ALTER TABLE collections ADD COLUMN index_type TEXT NOT NULL DEFAULT 'auto';
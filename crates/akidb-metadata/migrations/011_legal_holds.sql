@@ -0,0 +1,17 @@
+-- Migration: Legal holds
+-- Created: 2026-10-17
+--
+-- Legal holds block deletes until they are released, so they must outlive
+-- restarts. Each hold (reason, metadata filter, placement time) is stored as
+-- JSON.
+
+CREATE TABLE IF NOT EXISTS legal_holds (
+    hold_id BLOB PRIMARY KEY,
+    collection_id BLOB NOT NULL,
+    hold TEXT NOT NULL,  -- JSON
+    created_at TEXT NOT NULL,
+    FOREIGN KEY (collection_id) REFERENCES collections(collection_id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS ix_legal_holds_collection
+    ON legal_holds(collection_id);
@@ -0,0 +1,12 @@
+-- Migration: IP allowlists
+-- Created: 2026-10-17
+--
+-- Source network allowlists of the tenant and of API keys, enforced on every
+-- request, must outlive restarts. The scope is 'tenant' or the API key ID;
+-- each allowlist (networks, description, update time) is stored as JSON.
+
+CREATE TABLE IF NOT EXISTS ip_allowlists (
+    scope TEXT PRIMARY KEY,
+    allowlist TEXT NOT NULL,  -- JSON
+    updated_at TEXT NOT NULL
+) STRICT;
@@ -0,0 +1,7 @@
+-- Migration: Collection read-only mode
+-- Created: 2026-10-17
+--
+-- Collections made read-only (e.g. frozen for an audit) must stay read-only
+-- across restarts. 0 = writable, 1 = read-only.
+
+ALTER TABLE collections ADD COLUMN read_only INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+-- Migration: Metadata field indexes
+-- Created: 2026-10-17
+--
+-- Field index definitions (field name and value type) of each collection.
+-- The indexes themselves are rebuilt from the documents at startup.
+
+CREATE TABLE IF NOT EXISTS field_indexes (
+    collection_id BLOB NOT NULL,
+    field TEXT NOT NULL,
+    field_type TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (collection_id, field),
+    FOREIGN KEY (collection_id) REFERENCES collections(collection_id) ON DELETE CASCADE
+) STRICT;
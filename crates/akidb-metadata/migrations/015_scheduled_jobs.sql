@@ -0,0 +1,18 @@
+-- Migration: Scheduled jobs
+-- Created: 2026-10-17
+--
+-- Scheduled housekeeping jobs must keep running after a restart. Each job
+-- (definition, next run and recent run history) is stored as JSON and
+-- rewritten whenever it changes.
+
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    job_id BLOB PRIMARY KEY,
+    collection_id BLOB NOT NULL,
+    job TEXT NOT NULL,  -- JSON
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (collection_id) REFERENCES collections(collection_id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS ix_scheduled_jobs_collection
+    ON scheduled_jobs(collection_id);
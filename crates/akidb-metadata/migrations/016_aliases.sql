@@ -0,0 +1,16 @@
+-- Migration: Collection aliases
+-- Created: 2026-10-17
+--
+-- Aliases name the collection clients should use (e.g. `docs` pointing at
+-- `docs_v2` after a reindex). They are stored next to the collections so a
+-- restart keeps routing clients to the same collection.
+
+CREATE TABLE IF NOT EXISTS aliases (
+    alias TEXT PRIMARY KEY,
+    collection_id BLOB NOT NULL,
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (collection_id) REFERENCES collections(collection_id) ON DELETE CASCADE
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS ix_aliases_collection
+    ON aliases(collection_id);
@@ -0,0 +1,7 @@
+-- Migration: Collection sparse index settings
+-- Created: 2026-10-17
+--
+-- A collection's sparse index settings must survive restarts so its stored
+-- sparse vectors can be re-indexed at startup. NULL = no sparse index.
+
+ALTER TABLE collections ADD COLUMN sparse_index TEXT; -- JSON
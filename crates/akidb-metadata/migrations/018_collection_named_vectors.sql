@@ -0,0 +1,8 @@
+-- Migration: Collection named vector spaces
+-- Created: 2026-10-17
+--
+-- The named vector spaces declared on a collection must survive restarts
+-- so the named vectors stored with its documents can be re-indexed at
+-- startup. NULL = no named vector spaces.
+
+ALTER TABLE collections ADD COLUMN named_vectors TEXT; -- JSON
@@ -0,0 +1,11 @@
+-- Migration: Collection policy
+-- Created: 2026-10-17
+--
+-- The collection defaults and limits set by the platform team must survive
+-- restarts. The policy is a single row (id = 1) holding the policy as JSON.
+
+CREATE TABLE IF NOT EXISTS collection_policy (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    policy TEXT NOT NULL, -- JSON
+    updated_at TEXT NOT NULL
+) STRICT;
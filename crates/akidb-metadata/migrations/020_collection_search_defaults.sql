@@ -0,0 +1,8 @@
+-- Migration: Collection search defaults
+-- Created: 2026-10-17
+--
+-- Default search parameters (including the ef_search chosen at creation)
+-- are collection settings and must survive restarts.
+-- NULL = no defaults.
+
+ALTER TABLE collections ADD COLUMN search_defaults TEXT; -- JSON
@@ -0,0 +1,7 @@
+-- Migration: Collection text analysis
+-- Created: 2026-10-17
+--
+-- Stopwords, synonyms and per-field analyzers are collection settings and
+-- must survive restarts. NULL = default analyzer.
+
+ALTER TABLE collections ADD COLUMN text_analysis TEXT; -- JSON
@@ -0,0 +1,13 @@
+-- Migration: Replication targets
+-- Created: 2026-10-17
+--
+-- A collection's cross-region replication target must survive restarts so
+-- replication resumes. Writes not yet shipped are not stored; a resumed
+-- target starts with a full copy of its collection.
+
+CREATE TABLE IF NOT EXISTS replication_targets (
+    collection_id BLOB PRIMARY KEY,
+    config TEXT NOT NULL,  -- JSON
+    updated_at TEXT NOT NULL,
+    FOREIGN KEY (collection_id) REFERENCES collections(collection_id) ON DELETE CASCADE
+) STRICT;
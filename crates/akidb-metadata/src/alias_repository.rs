@@ -0,0 +1,160 @@
+//! Collection alias persistence.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::Utc;
+use sqlx::SqlitePool;
+
+/// Repository for collection aliases.
+pub struct AliasRepository {
+    pool: SqlitePool,
+}
+
+impl AliasRepository {
+    /// Creates a new alias repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Points an alias at a collection, creating or replacing it.
+    pub async fn set(&self, alias: &str, collection_id: CollectionId) -> CoreResult<()> {
+        sqlx::query(
+            r#"
+            INSERT INTO aliases (alias, collection_id, updated_at)
+            VALUES (?1, ?2, ?3)
+            ON CONFLICT(alias) DO UPDATE SET
+                collection_id = excluded.collection_id,
+                updated_at = excluded.updated_at
+            "#,
+        )
+        .bind(alias)
+        .bind(&collection_id.to_bytes()[..])
+        .bind(Utc::now().to_rfc3339())
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save alias: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Deletes an alias.
+    ///
+    /// Returns `Ok(())` even if the alias didn't exist (idempotent).
+    pub async fn delete(&self, alias: &str) -> CoreResult<()> {
+        sqlx::query("DELETE FROM aliases WHERE alias = ?1")
+            .bind(alias)
+            .execute(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to delete alias: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Lists every alias and the collection it points at, by alias.
+    pub async fn list_all(&self) -> CoreResult<Vec<(String, CollectionId)>> {
+        let rows: Vec<(String, Vec<u8>)> =
+            sqlx::query_as("SELECT alias, collection_id FROM aliases ORDER BY alias")
+                .fetch_all(&self.pool)
+                .await
+                .map_err(|e| CoreError::internal(format!("Failed to list aliases: {}", e)))?;
+
+        rows.into_iter()
+            .map(|(alias, collection_id)| {
+                let collection_id = CollectionId::from_bytes(&collection_id)
+                    .map_err(|e| CoreError::internal(e.to_string()))?;
+                Ok((alias, collection_id))
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    async fn create_test_collection(pool: &SqlitePool) -> CollectionId {
+        let tenant_id = akidb_core::TenantId::new();
+        let database_id = akidb_core::DatabaseId::new();
+        let collection_id = CollectionId::new();
+
+        sqlx::query(
+            r#"
+            INSERT INTO tenants (tenant_id, name, slug, status, created_at, updated_at)
+            VALUES (?1, 'test_tenant', 'test', 'active', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO databases (database_id, tenant_id, name, state, created_at, updated_at)
+            VALUES (?1, ?2, 'test_db', 'ready', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&database_id.to_bytes()[..])
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO collections (collection_id, database_id, name, dimension, metric, embedding_model, created_at, updated_at)
+            VALUES (?1, ?2, 'test_collection', 128, 'cosine', 'test', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&collection_id.to_bytes()[..])
+        .bind(&database_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        collection_id
+    }
+
+    #[tokio::test]
+    async fn test_set_list_delete() {
+        let pool = create_test_pool().await;
+        let repository = AliasRepository::new(pool.clone());
+        let blue = create_test_collection(&pool).await;
+
+        repository.set("docs", blue).await.unwrap();
+        repository.set("articles", blue).await.unwrap();
+        // Setting an alias again replaces it
+        repository.set("docs", blue).await.unwrap();
+        assert_eq!(
+            repository.list_all().await.unwrap(),
+            vec![("articles".to_string(), blue), ("docs".to_string(), blue)]
+        );
+
+        repository.delete("articles").await.unwrap();
+        repository.delete("articles").await.unwrap();
+        assert_eq!(
+            repository.list_all().await.unwrap(),
+            vec![("docs".to_string(), blue)]
+        );
+
+        // Aliases go with the collection they point at
+        sqlx::query("DELETE FROM collections WHERE collection_id = ?1")
+            .bind(&blue.to_bytes()[..])
+            .execute(&pool)
+            .await
+            .unwrap();
+        assert!(repository.list_all().await.unwrap().is_empty());
+    }
+}
@@ -167,6 +167,28 @@ impl ApiKeyRepository for SqliteApiKeyRepository {
         self.delete_with_executor(key_id, &self.pool).await
     }
 
+    async fn set_expires_at(
+        &self,
+        key_id: ApiKeyId,
+        expires_at: Option<DateTime<Utc>>,
+    ) -> CoreResult<()> {
+        let key_id_bytes = key_id.to_bytes().to_vec();
+        let expires_at = expires_at.map(|t| t.to_rfc3339());
+
+        let result = query("UPDATE api_keys SET expires_at = ?1 WHERE key_id = ?2")
+            .bind(expires_at)
+            .bind(key_id_bytes)
+            .execute(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(e.to_string()))?;
+
+        if result.rows_affected() == 0 {
+            return Err(CoreError::not_found("api_key", key_id.to_string()));
+        }
+
+        Ok(())
+    }
+
     async fn update_last_used(&self, key_id: ApiKeyId) -> CoreResult<()> {
         let key_id_bytes = key_id.to_bytes().to_vec();
         let now = Utc::now().to_rfc3339();
@@ -0,0 +1,95 @@
+//! Collection policy persistence.
+
+use akidb_core::{CollectionPolicy, CoreError, CoreResult};
+use chrono::Utc;
+use sqlx::SqlitePool;
+
+/// Repository for the collection defaults and limits.
+pub struct CollectionPolicyRepository {
+    pool: SqlitePool,
+}
+
+impl CollectionPolicyRepository {
+    /// Creates a new collection policy repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Stores the policy, replacing the previous one.
+    pub async fn set(&self, policy: &CollectionPolicy) -> CoreResult<()> {
+        let json = serde_json::to_string(policy).map_err(|e| {
+            CoreError::internal(format!("Failed to serialize collection policy: {}", e))
+        })?;
+
+        sqlx::query(
+            r#"
+            INSERT INTO collection_policy (id, policy, updated_at)
+            VALUES (1, ?1, ?2)
+            ON CONFLICT(id) DO UPDATE SET
+                policy = excluded.policy,
+                updated_at = excluded.updated_at
+            "#,
+        )
+        .bind(json)
+        .bind(Utc::now().to_rfc3339())
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save collection policy: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Gets the stored policy (none if it was never set).
+    pub async fn get(&self) -> CoreResult<Option<CollectionPolicy>> {
+        let row: Option<(String,)> =
+            sqlx::query_as("SELECT policy FROM collection_policy WHERE id = 1")
+                .fetch_optional(&self.pool)
+                .await
+                .map_err(|e| {
+                    CoreError::internal(format!("Failed to load collection policy: {}", e))
+                })?;
+
+        row.map(|(json,)| {
+            serde_json::from_str(&json).map_err(|e| {
+                CoreError::internal(format!("Failed to deserialize collection policy: {}", e))
+            })
+        })
+        .transpose()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    #[tokio::test]
+    async fn test_set_and_get() {
+        let pool = create_test_pool().await;
+        let repository = CollectionPolicyRepository::new(pool);
+        assert!(repository.get().await.unwrap().is_none());
+
+        let mut policy = CollectionPolicy::default();
+        policy.defaults.top_k = Some(20);
+        policy.limits.max_top_k = Some(100);
+        repository.set(&policy).await.unwrap();
+        assert_eq!(repository.get().await.unwrap(), Some(policy.clone()));
+
+        // Setting it again replaces it
+        policy.limits.max_dimension = Some(1024);
+        repository.set(&policy).await.unwrap();
+        assert_eq!(repository.get().await.unwrap(), Some(policy));
+    }
+}
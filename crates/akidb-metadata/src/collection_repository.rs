@@ -2,8 +2,10 @@ use std::str::FromStr;
 
 use akidb_core::{
     CollectionDescriptor, CollectionId, CoreError, CoreResult, DatabaseId, DistanceMetric,
+    IndexType,
 };
 use chrono::{DateTime, SecondsFormat, Utc};
+use serde_json::Value;
 use sqlx::sqlite::SqliteRow;
 use sqlx::{query, Executor, Row, Sqlite, SqlitePool};
 
@@ -42,10 +44,18 @@ impl SqliteCollectionRepository {
         let dimension = i64::from(collection.dimension);
         let metric = collection.metric.as_str();
         let embedding_model = &collection.embedding_model;
+        let index_type = collection.index_type.as_str();
+        let read_only = i64::from(collection.read_only);
         let hnsw_m = i64::from(collection.hnsw_m);
         let hnsw_ef_construction = i64::from(collection.hnsw_ef_construction);
         let max_doc_count = i64::try_from(collection.max_doc_count)
             .map_err(|_| CoreError::invalid_state("max_doc_count exceeds 63-bit range"))?;
+        let description = &collection.description;
+        let metadata = encode_json("metadata", collection.metadata.as_ref())?;
+        let sparse_index = encode_json("sparse index", collection.sparse_index.as_ref())?;
+        let named_vectors = encode_json("named vectors", collection.named_vectors.as_ref())?;
+        let search_defaults = encode_json("search defaults", collection.search_defaults.as_ref())?;
+        let text_analysis = encode_json("text analysis", collection.text_analysis.as_ref())?;
         let created_at = collection
             .created_at
             .to_rfc3339_opts(SecondsFormat::Millis, true);
@@ -65,10 +75,18 @@ impl SqliteCollectionRepository {
                 hnsw_m,
                 hnsw_ef_construction,
                 max_doc_count,
+                description,
+                metadata,
                 created_at,
-                updated_at
+                updated_at,
+                index_type,
+                read_only,
+                sparse_index,
+                named_vectors,
+                search_defaults,
+                text_analysis
             )
-            VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+            VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17, ?18, ?19)
             "#,
         )
         .bind(collection_id)
@@ -80,8 +98,16 @@ impl SqliteCollectionRepository {
         .bind(hnsw_m)
         .bind(hnsw_ef_construction)
         .bind(max_doc_count)
+        .bind(description)
+        .bind(metadata)
         .bind(created_at)
         .bind(updated_at)
+        .bind(index_type)
+        .bind(read_only)
+        .bind(sparse_index)
+        .bind(named_vectors)
+        .bind(search_defaults)
+        .bind(text_analysis)
         .execute(executor)
         .await
         .map(|_| ())
@@ -107,10 +133,18 @@ impl SqliteCollectionRepository {
         let dimension = i64::from(collection.dimension);
         let metric = collection.metric.as_str();
         let embedding_model = &collection.embedding_model;
+        let index_type = collection.index_type.as_str();
+        let read_only = i64::from(collection.read_only);
         let hnsw_m = i64::from(collection.hnsw_m);
         let hnsw_ef_construction = i64::from(collection.hnsw_ef_construction);
         let max_doc_count = i64::try_from(collection.max_doc_count)
             .map_err(|_| CoreError::invalid_state("max_doc_count exceeds 63-bit range"))?;
+        let description = &collection.description;
+        let metadata = encode_json("metadata", collection.metadata.as_ref())?;
+        let sparse_index = encode_json("sparse index", collection.sparse_index.as_ref())?;
+        let named_vectors = encode_json("named vectors", collection.named_vectors.as_ref())?;
+        let search_defaults = encode_json("search defaults", collection.search_defaults.as_ref())?;
+        let text_analysis = encode_json("text analysis", collection.text_analysis.as_ref())?;
         let updated_at = collection
             .updated_at
             .to_rfc3339_opts(SecondsFormat::Millis, true);
@@ -126,7 +160,15 @@ impl SqliteCollectionRepository {
                    hnsw_m = ?7,
                    hnsw_ef_construction = ?8,
                    max_doc_count = ?9,
-                   updated_at = ?10
+                   description = ?10,
+                   metadata = ?11,
+                   updated_at = ?12,
+                   index_type = ?13,
+                   read_only = ?14,
+                   sparse_index = ?15,
+                   named_vectors = ?16,
+                   search_defaults = ?17,
+                   text_analysis = ?18
              WHERE collection_id = ?1
             "#,
         )
@@ -139,7 +181,15 @@ impl SqliteCollectionRepository {
         .bind(hnsw_m)
         .bind(hnsw_ef_construction)
         .bind(max_doc_count)
+        .bind(description)
+        .bind(metadata)
         .bind(updated_at)
+        .bind(index_type)
+        .bind(read_only)
+        .bind(sparse_index)
+        .bind(named_vectors)
+        .bind(search_defaults)
+        .bind(text_analysis)
         .execute(executor)
         .await
         .map_err(|err| map_sqlx_error("collection", collection.collection_id.to_string(), err))?;
@@ -195,9 +245,19 @@ impl SqliteCollectionRepository {
         let metric = DistanceMetric::from_str(&metric)
             .map_err(|_| CoreError::invalid_state(format!("unknown distance metric `{metric}`")))?;
         let embedding_model: String = row.get("embedding_model");
+        let index_type: String = row.get("index_type");
+        let index_type = IndexType::from_str(&index_type)
+            .map_err(|_| CoreError::invalid_state(format!("unknown index type `{index_type}`")))?;
         let hnsw_m: i64 = row.get("hnsw_m");
         let hnsw_ef_construction: i64 = row.get("hnsw_ef_construction");
         let max_doc_count: i64 = row.get("max_doc_count");
+        let description: Option<String> = row.get("description");
+        let metadata: Option<String> = row.get("metadata");
+        let read_only: i64 = row.get("read_only");
+        let sparse_index: Option<String> = row.get("sparse_index");
+        let named_vectors: Option<String> = row.get("named_vectors");
+        let search_defaults: Option<String> = row.get("search_defaults");
+        let text_analysis: Option<String> = row.get("text_analysis");
         let created_at: String = row.get("created_at");
         let updated_at: String = row.get("updated_at");
 
@@ -209,6 +269,11 @@ impl SqliteCollectionRepository {
             .map_err(|_| CoreError::invalid_state("hnsw_ef_construction stored negative value"))?;
         let max_doc_count = u64::try_from(max_doc_count)
             .map_err(|_| CoreError::invalid_state("max_doc_count stored negative value"))?;
+        let metadata = decode_json("metadata", metadata)?;
+        let sparse_index = decode_json("sparse index", sparse_index)?;
+        let named_vectors = decode_json("named vectors", named_vectors)?;
+        let search_defaults = decode_json("search defaults", search_defaults)?;
+        let text_analysis = decode_json("text analysis", text_analysis)?;
 
         let created_at = DateTime::parse_from_rfc3339(&created_at)
             .map_err(|err| CoreError::internal(format!("invalid created_at: {err}")))?
@@ -224,15 +289,38 @@ impl SqliteCollectionRepository {
             dimension,
             metric,
             embedding_model,
+            index_type,
             hnsw_m,
             hnsw_ef_construction,
             max_doc_count,
+            description,
+            metadata,
+            read_only: read_only != 0,
+            sparse_index,
+            named_vectors,
+            search_defaults,
+            text_analysis,
             created_at,
             updated_at,
         })
     }
 }
 
+/// JSON text value of a collection column (metadata and stored settings).
+fn encode_json(column: &str, value: Option<&Value>) -> CoreResult<Option<String>> {
+    value
+        .map(serde_json::to_string)
+        .transpose()
+        .map_err(|err| CoreError::internal(format!("failed to encode collection {column}: {err}")))
+}
+
+/// Parses a JSON text column written by `encode_json`.
+fn decode_json(column: &str, json: Option<String>) -> CoreResult<Option<Value>> {
+    json.map(|json| serde_json::from_str(&json))
+        .transpose()
+        .map_err(|err| CoreError::internal(format!("invalid collection {column}: {err}")))
+}
+
 #[async_trait::async_trait]
 impl akidb_core::CollectionRepository for SqliteCollectionRepository {
     async fn create(&self, collection: &CollectionDescriptor) -> CoreResult<()> {
@@ -248,9 +336,17 @@ impl akidb_core::CollectionRepository for SqliteCollectionRepository {
                    dimension,
                    metric,
                    embedding_model,
+                   index_type,
+                   read_only,
+                   sparse_index,
+                   named_vectors,
+                   search_defaults,
+                   text_analysis,
                    hnsw_m,
                    hnsw_ef_construction,
                    max_doc_count,
+                   description,
+                   metadata,
                    created_at,
                    updated_at
               FROM collections
@@ -280,9 +376,17 @@ impl akidb_core::CollectionRepository for SqliteCollectionRepository {
                    dimension,
                    metric,
                    embedding_model,
+                   index_type,
+                   read_only,
+                   sparse_index,
+                   named_vectors,
+                   search_defaults,
+                   text_analysis,
                    hnsw_m,
                    hnsw_ef_construction,
                    max_doc_count,
+                   description,
+                   metadata,
                    created_at,
                    updated_at
               FROM collections
@@ -307,9 +411,17 @@ impl akidb_core::CollectionRepository for SqliteCollectionRepository {
                    dimension,
                    metric,
                    embedding_model,
+                   index_type,
+                   read_only,
+                   sparse_index,
+                   named_vectors,
+                   search_defaults,
+                   text_analysis,
                    hnsw_m,
                    hnsw_ef_construction,
                    max_doc_count,
+                   description,
+                   metadata,
                    created_at,
                    updated_at
               FROM collections
@@ -0,0 +1,178 @@
+//! Field index persistence.
+//!
+//! Only the definitions (field and value type) are stored; the service
+//! rebuilds the indexes from the documents at startup.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::Utc;
+use sqlx::SqlitePool;
+
+/// A stored field index definition.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FieldIndexRecord {
+    pub collection_id: CollectionId,
+    pub field: String,
+    /// Value type, as named by the service (e.g. `"keyword"`).
+    pub field_type: String,
+}
+
+/// Repository for field index definitions.
+pub struct FieldIndexRepository {
+    pool: SqlitePool,
+}
+
+impl FieldIndexRepository {
+    /// Creates a new field index repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Stores a field index definition.
+    pub async fn insert(&self, record: &FieldIndexRecord) -> CoreResult<()> {
+        sqlx::query(
+            r#"
+            INSERT INTO field_indexes (collection_id, field, field_type, created_at)
+            VALUES (?1, ?2, ?3, ?4)
+            "#,
+        )
+        .bind(&record.collection_id.to_bytes()[..])
+        .bind(&record.field)
+        .bind(&record.field_type)
+        .bind(Utc::now().to_rfc3339())
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save field index: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Deletes the definition of a collection's field index.
+    ///
+    /// Returns `Ok(())` even if it didn't exist (idempotent).
+    pub async fn delete(&self, collection_id: CollectionId, field: &str) -> CoreResult<()> {
+        sqlx::query("DELETE FROM field_indexes WHERE collection_id = ?1 AND field = ?2")
+            .bind(&collection_id.to_bytes()[..])
+            .bind(field)
+            .execute(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to delete field index: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Lists every stored definition, oldest first.
+    pub async fn list_all(&self) -> CoreResult<Vec<FieldIndexRecord>> {
+        let rows: Vec<(Vec<u8>, String, String)> = sqlx::query_as(
+            "SELECT collection_id, field, field_type FROM field_indexes ORDER BY created_at, rowid",
+        )
+        .fetch_all(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to list field indexes: {}", e)))?;
+
+        rows.into_iter()
+            .map(|(collection_id, field, field_type)| {
+                Ok(FieldIndexRecord {
+                    collection_id: CollectionId::from_bytes(&collection_id)
+                        .map_err(|e| CoreError::internal(e.to_string()))?,
+                    field,
+                    field_type,
+                })
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    async fn create_test_collection(pool: &SqlitePool) -> CollectionId {
+        let tenant_id = akidb_core::TenantId::new();
+        let database_id = akidb_core::DatabaseId::new();
+        let collection_id = CollectionId::new();
+
+        sqlx::query(
+            r#"
+            INSERT INTO tenants (tenant_id, name, slug, status, created_at, updated_at)
+            VALUES (?1, 'test_tenant', 'test', 'active', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO databases (database_id, tenant_id, name, state, created_at, updated_at)
+            VALUES (?1, ?2, 'test_db', 'ready', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&database_id.to_bytes()[..])
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO collections (collection_id, database_id, name, dimension, metric, embedding_model, created_at, updated_at)
+            VALUES (?1, ?2, 'test_collection', 128, 'cosine', 'test', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&collection_id.to_bytes()[..])
+        .bind(&database_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        collection_id
+    }
+
+    #[tokio::test]
+    async fn test_insert_list_delete() {
+        let pool = create_test_pool().await;
+        let repository = FieldIndexRepository::new(pool.clone());
+        let collection_id = create_test_collection(&pool).await;
+
+        let record = |field: &str, field_type: &str| FieldIndexRecord {
+            collection_id,
+            field: field.to_string(),
+            field_type: field_type.to_string(),
+        };
+        repository
+            .insert(&record("user_id", "keyword"))
+            .await
+            .unwrap();
+        repository.insert(&record("year", "integer")).await.unwrap();
+        // One index per field
+        assert!(repository
+            .insert(&record("user_id", "integer"))
+            .await
+            .is_err());
+        assert_eq!(
+            repository.list_all().await.unwrap(),
+            vec![record("user_id", "keyword"), record("year", "integer")]
+        );
+
+        repository.delete(collection_id, "user_id").await.unwrap();
+        repository.delete(collection_id, "user_id").await.unwrap();
+        assert_eq!(
+            repository.list_all().await.unwrap(),
+            vec![record("year", "integer")]
+        );
+    }
+}
@@ -0,0 +1,127 @@
+//! IP allowlist persistence.
+//!
+//! Allowlists are defined by the service layer; they are stored here as JSON,
+//! keyed by their scope (the tenant, or an API key), so they survive
+//! restarts.
+
+use akidb_core::{ApiKeyId, CoreError, CoreResult};
+use chrono::Utc;
+use serde_json::Value as JsonValue;
+use sqlx::SqlitePool;
+
+/// Repository for the tenant's and API keys' IP allowlists.
+pub struct IpAllowlistRepository {
+    pool: SqlitePool,
+}
+
+impl IpAllowlistRepository {
+    /// Creates a new IP allowlist repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Stores the allowlist of an API key, or of the tenant when `key_id` is
+    /// None, replacing any stored one.
+    pub async fn save(&self, key_id: Option<ApiKeyId>, allowlist: &JsonValue) -> CoreResult<()> {
+        sqlx::query(
+            r#"
+            INSERT OR REPLACE INTO ip_allowlists (scope, allowlist, updated_at)
+            VALUES (?1, ?2, ?3)
+            "#,
+        )
+        .bind(scope(key_id))
+        .bind(allowlist.to_string())
+        .bind(Utc::now().to_rfc3339())
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save IP allowlist: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Deletes the allowlist of an API key, or of the tenant when `key_id` is
+    /// None.
+    ///
+    /// Returns `Ok(())` even if no allowlist was stored (idempotent).
+    pub async fn delete(&self, key_id: Option<ApiKeyId>) -> CoreResult<()> {
+        sqlx::query("DELETE FROM ip_allowlists WHERE scope = ?1")
+            .bind(scope(key_id))
+            .execute(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to delete IP allowlist: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Lists every stored allowlist.
+    pub async fn list_all(&self) -> CoreResult<Vec<JsonValue>> {
+        let rows: Vec<(String,)> = sqlx::query_as("SELECT allowlist FROM ip_allowlists")
+            .fetch_all(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to list IP allowlists: {}", e)))?;
+
+        rows.into_iter()
+            .map(|(allowlist,)| {
+                serde_json::from_str(&allowlist).map_err(|e| {
+                    CoreError::internal(format!("Failed to deserialize IP allowlist: {}", e))
+                })
+            })
+            .collect()
+    }
+}
+
+fn scope(key_id: Option<ApiKeyId>) -> String {
+    key_id.map_or_else(|| "tenant".to_string(), |key_id| key_id.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    #[tokio::test]
+    async fn test_save_replaces_per_scope() {
+        let repository = IpAllowlistRepository::new(create_test_pool().await);
+        let key_id = ApiKeyId::new();
+
+        repository
+            .save(None, &json!({"networks": ["10.0.0.0/8"]}))
+            .await
+            .unwrap();
+        repository
+            .save(None, &json!({"networks": ["10.1.0.0/16"]}))
+            .await
+            .unwrap();
+        repository
+            .save(Some(key_id), &json!({"networks": ["10.9.0.0/16"]}))
+            .await
+            .unwrap();
+        let mut allowlists = repository.list_all().await.unwrap();
+        allowlists.sort_by_key(|a| a["networks"][0].as_str().unwrap().to_string());
+        assert_eq!(
+            allowlists,
+            vec![
+                json!({"networks": ["10.1.0.0/16"]}),
+                json!({"networks": ["10.9.0.0/16"]})
+            ]
+        );
+
+        repository.delete(Some(key_id)).await.unwrap();
+        repository.delete(Some(key_id)).await.unwrap();
+        let allowlists = repository.list_all().await.unwrap();
+        assert_eq!(allowlists, vec![json!({"networks": ["10.1.0.0/16"]})]);
+    }
+}
@@ -0,0 +1,173 @@
+//! Legal hold persistence.
+//!
+//! Holds are defined by the service layer; they are stored here as JSON so
+//! they survive restarts.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, LegalHoldId};
+use chrono::Utc;
+use serde_json::Value as JsonValue;
+use sqlx::SqlitePool;
+
+/// Repository for legal holds.
+pub struct LegalHoldRepository {
+    pool: SqlitePool,
+}
+
+impl LegalHoldRepository {
+    /// Creates a new legal hold repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Stores a hold placed on a collection.
+    pub async fn insert(
+        &self,
+        hold_id: LegalHoldId,
+        collection_id: CollectionId,
+        hold: &JsonValue,
+    ) -> CoreResult<()> {
+        sqlx::query(
+            r#"
+            INSERT INTO legal_holds (hold_id, collection_id, hold, created_at)
+            VALUES (?1, ?2, ?3, ?4)
+            "#,
+        )
+        .bind(&hold_id.to_bytes()[..])
+        .bind(&collection_id.to_bytes()[..])
+        .bind(hold.to_string())
+        .bind(Utc::now().to_rfc3339())
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save legal hold: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Deletes a released hold.
+    ///
+    /// Returns `Ok(())` even if the hold didn't exist (idempotent).
+    pub async fn delete(&self, hold_id: LegalHoldId) -> CoreResult<()> {
+        sqlx::query("DELETE FROM legal_holds WHERE hold_id = ?1")
+            .bind(&hold_id.to_bytes()[..])
+            .execute(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to delete legal hold: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Lists every stored hold, oldest first.
+    pub async fn list_all(&self) -> CoreResult<Vec<JsonValue>> {
+        let rows: Vec<(String,)> =
+            sqlx::query_as("SELECT hold FROM legal_holds ORDER BY created_at, rowid")
+                .fetch_all(&self.pool)
+                .await
+                .map_err(|e| CoreError::internal(format!("Failed to list legal holds: {}", e)))?;
+
+        rows.into_iter()
+            .map(|(hold,)| {
+                serde_json::from_str(&hold).map_err(|e| {
+                    CoreError::internal(format!("Failed to deserialize legal hold: {}", e))
+                })
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    async fn create_test_collection(pool: &SqlitePool) -> CollectionId {
+        let tenant_id = akidb_core::TenantId::new();
+        let database_id = akidb_core::DatabaseId::new();
+        let collection_id = CollectionId::new();
+
+        sqlx::query(
+            r#"
+            INSERT INTO tenants (tenant_id, name, slug, status, created_at, updated_at)
+            VALUES (?1, 'test_tenant', 'test', 'active', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO databases (database_id, tenant_id, name, state, created_at, updated_at)
+            VALUES (?1, ?2, 'test_db', 'ready', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&database_id.to_bytes()[..])
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO collections (collection_id, database_id, name, dimension, metric, embedding_model, created_at, updated_at)
+            VALUES (?1, ?2, 'test_collection', 128, 'cosine', 'test', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&collection_id.to_bytes()[..])
+        .bind(&database_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        collection_id
+    }
+
+    #[tokio::test]
+    async fn test_insert_list_delete() {
+        let pool = create_test_pool().await;
+        let repository = LegalHoldRepository::new(pool.clone());
+        let collection_id = create_test_collection(&pool).await;
+
+        let first = LegalHoldId::new();
+        let second = LegalHoldId::new();
+        repository
+            .insert(first, collection_id, &json!({"reason": "case 1"}))
+            .await
+            .unwrap();
+        repository
+            .insert(second, collection_id, &json!({"reason": "case 2"}))
+            .await
+            .unwrap();
+        let holds = repository.list_all().await.unwrap();
+        assert_eq!(
+            holds,
+            vec![json!({"reason": "case 1"}), json!({"reason": "case 2"})]
+        );
+
+        repository.delete(first).await.unwrap();
+        repository.delete(first).await.unwrap();
+        let holds = repository.list_all().await.unwrap();
+        assert_eq!(holds, vec![json!({"reason": "case 2"})]);
+
+        // Holds go with their collection
+        sqlx::query("DELETE FROM collections WHERE collection_id = ?1")
+            .bind(&collection_id.to_bytes()[..])
+            .execute(&pool)
+            .await
+            .unwrap();
+        assert!(repository.list_all().await.unwrap().is_empty());
+    }
+}
@@ -1,20 +1,34 @@
 //! SQLite metadata adapters for the AkiDB 2.0 control plane.
 
+mod alias_repository;
 mod api_key_repository;
 mod audit_repository;
+mod collection_policy_repository;
 mod collection_repository;
+mod field_index_repository;
+mod ip_allowlist_repository;
+mod legal_hold_repository;
 pub mod password;
+mod replication_target_repository;
 mod repository;
+mod scheduled_job_repository;
 mod tenant_catalog;
 mod tier_state_repository;
 mod user_repository;
 mod util;
 mod vector_persistence;
 
+pub use alias_repository::AliasRepository;
 pub use api_key_repository::SqliteApiKeyRepository;
 pub use audit_repository::SqliteAuditLogRepository;
+pub use collection_policy_repository::CollectionPolicyRepository;
 pub use collection_repository::SqliteCollectionRepository;
+pub use field_index_repository::{FieldIndexRecord, FieldIndexRepository};
+pub use ip_allowlist_repository::IpAllowlistRepository;
+pub use legal_hold_repository::LegalHoldRepository;
+pub use replication_target_repository::ReplicationTargetRepository;
 pub use repository::SqliteDatabaseRepository;
+pub use scheduled_job_repository::ScheduledJobRepository;
 pub use tenant_catalog::SqliteTenantCatalog;
 pub use tier_state_repository::{Tier, TierState, TierStateRepository};
 pub use user_repository::SqliteUserRepository;
@@ -0,0 +1,179 @@
+//! Replication target persistence.
+//!
+//! Targets are configured by the service layer; their settings are stored
+//! here as JSON so replication resumes after a restart.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::Utc;
+use serde_json::Value as JsonValue;
+use sqlx::SqlitePool;
+
+/// Repository for cross-region replication targets.
+pub struct ReplicationTargetRepository {
+    pool: SqlitePool,
+}
+
+impl ReplicationTargetRepository {
+    /// Creates a new replication target repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Stores a collection's target, replacing the previous one.
+    pub async fn save(&self, collection_id: CollectionId, config: &JsonValue) -> CoreResult<()> {
+        sqlx::query(
+            r#"
+            INSERT INTO replication_targets (collection_id, config, updated_at)
+            VALUES (?1, ?2, ?3)
+            ON CONFLICT(collection_id) DO UPDATE SET
+                config = excluded.config,
+                updated_at = excluded.updated_at
+            "#,
+        )
+        .bind(&collection_id.to_bytes()[..])
+        .bind(config.to_string())
+        .bind(Utc::now().to_rfc3339())
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save replication target: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Deletes a collection's target.
+    ///
+    /// Returns `Ok(())` even if the collection had no target (idempotent).
+    pub async fn delete(&self, collection_id: CollectionId) -> CoreResult<()> {
+        sqlx::query("DELETE FROM replication_targets WHERE collection_id = ?1")
+            .bind(&collection_id.to_bytes()[..])
+            .execute(&self.pool)
+            .await
+            .map_err(|e| {
+                CoreError::internal(format!("Failed to delete replication target: {}", e))
+            })?;
+
+        Ok(())
+    }
+
+    /// Lists every stored target and its collection.
+    pub async fn list_all(&self) -> CoreResult<Vec<(CollectionId, JsonValue)>> {
+        let rows: Vec<(Vec<u8>, String)> =
+            sqlx::query_as("SELECT collection_id, config FROM replication_targets")
+                .fetch_all(&self.pool)
+                .await
+                .map_err(|e| {
+                    CoreError::internal(format!("Failed to list replication targets: {}", e))
+                })?;
+
+        rows.into_iter()
+            .map(|(collection_id, config)| {
+                let collection_id = CollectionId::from_bytes(&collection_id)
+                    .map_err(|e| CoreError::internal(e.to_string()))?;
+                let config = serde_json::from_str(&config).map_err(|e| {
+                    CoreError::internal(format!("Failed to deserialize replication target: {}", e))
+                })?;
+                Ok((collection_id, config))
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    async fn create_test_collection(pool: &SqlitePool) -> CollectionId {
+        let tenant_id = akidb_core::TenantId::new();
+        let database_id = akidb_core::DatabaseId::new();
+        let collection_id = CollectionId::new();
+
+        sqlx::query(
+            r#"
+            INSERT INTO tenants (tenant_id, name, slug, status, created_at, updated_at)
+            VALUES (?1, 'test_tenant', 'test', 'active', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO databases (database_id, tenant_id, name, state, created_at, updated_at)
+            VALUES (?1, ?2, 'test_db', 'ready', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&database_id.to_bytes()[..])
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO collections (collection_id, database_id, name, dimension, metric, embedding_model, created_at, updated_at)
+            VALUES (?1, ?2, 'test_collection', 128, 'cosine', 'test', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&collection_id.to_bytes()[..])
+        .bind(&database_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        collection_id
+    }
+
+    #[tokio::test]
+    async fn test_save_list_delete() {
+        let pool = create_test_pool().await;
+        let repository = ReplicationTargetRepository::new(pool.clone());
+        let collection_id = create_test_collection(&pool).await;
+
+        repository
+            .save(collection_id, &json!({"target_region": "eu-west-1"}))
+            .await
+            .unwrap();
+        // Saving again replaces the target
+        repository
+            .save(collection_id, &json!({"target_region": "us-east-1"}))
+            .await
+            .unwrap();
+        let targets = repository.list_all().await.unwrap();
+        assert_eq!(
+            targets,
+            vec![(collection_id, json!({"target_region": "us-east-1"}))]
+        );
+
+        repository.delete(collection_id).await.unwrap();
+        repository.delete(collection_id).await.unwrap();
+        assert!(repository.list_all().await.unwrap().is_empty());
+
+        // Targets go with their collection
+        repository
+            .save(collection_id, &json!({"target_region": "eu-west-1"}))
+            .await
+            .unwrap();
+        sqlx::query("DELETE FROM collections WHERE collection_id = ?1")
+            .bind(&collection_id.to_bytes()[..])
+            .execute(&pool)
+            .await
+            .unwrap();
+        assert!(repository.list_all().await.unwrap().is_empty());
+    }
+}
@@ -0,0 +1,189 @@
+//! Scheduled job persistence.
+//!
+//! Jobs are defined by the service layer; they are stored here as JSON and
+//! rewritten after every change (including each run) so their schedule and
+//! history survive restarts.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, JobId};
+use chrono::Utc;
+use serde_json::Value as JsonValue;
+use sqlx::SqlitePool;
+
+/// Repository for scheduled jobs.
+pub struct ScheduledJobRepository {
+    pool: SqlitePool,
+}
+
+impl ScheduledJobRepository {
+    /// Creates a new scheduled job repository.
+    pub fn new(pool: SqlitePool) -> Self {
+        Self { pool }
+    }
+
+    /// Stores a job, replacing its previous state.
+    pub async fn save(
+        &self,
+        job_id: JobId,
+        collection_id: CollectionId,
+        job: &JsonValue,
+    ) -> CoreResult<()> {
+        let now = Utc::now().to_rfc3339();
+        sqlx::query(
+            r#"
+            INSERT INTO scheduled_jobs (job_id, collection_id, job, created_at, updated_at)
+            VALUES (?1, ?2, ?3, ?4, ?4)
+            ON CONFLICT(job_id) DO UPDATE SET
+                collection_id = excluded.collection_id,
+                job = excluded.job,
+                updated_at = excluded.updated_at
+            "#,
+        )
+        .bind(&job_id.to_bytes()[..])
+        .bind(&collection_id.to_bytes()[..])
+        .bind(job.to_string())
+        .bind(now)
+        .execute(&self.pool)
+        .await
+        .map_err(|e| CoreError::internal(format!("Failed to save scheduled job: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Deletes a job.
+    ///
+    /// Returns `Ok(())` even if the job didn't exist (idempotent).
+    pub async fn delete(&self, job_id: JobId) -> CoreResult<()> {
+        sqlx::query("DELETE FROM scheduled_jobs WHERE job_id = ?1")
+            .bind(&job_id.to_bytes()[..])
+            .execute(&self.pool)
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to delete scheduled job: {}", e)))?;
+
+        Ok(())
+    }
+
+    /// Lists every stored job, oldest first.
+    pub async fn list_all(&self) -> CoreResult<Vec<JsonValue>> {
+        let rows: Vec<(String,)> =
+            sqlx::query_as("SELECT job FROM scheduled_jobs ORDER BY created_at, rowid")
+                .fetch_all(&self.pool)
+                .await
+                .map_err(|e| {
+                    CoreError::internal(format!("Failed to list scheduled jobs: {}", e))
+                })?;
+
+        rows.into_iter()
+            .map(|(job,)| {
+                serde_json::from_str(&job).map_err(|e| {
+                    CoreError::internal(format!("Failed to deserialize scheduled job: {}", e))
+                })
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    async fn create_test_pool() -> SqlitePool {
+        let pool = SqlitePool::connect(":memory:")
+            .await
+            .expect("Failed to create in-memory database");
+
+        sqlx::migrate!("./migrations")
+            .run(&pool)
+            .await
+            .expect("Failed to run migrations");
+
+        pool
+    }
+
+    async fn create_test_collection(pool: &SqlitePool) -> CollectionId {
+        let tenant_id = akidb_core::TenantId::new();
+        let database_id = akidb_core::DatabaseId::new();
+        let collection_id = CollectionId::new();
+
+        sqlx::query(
+            r#"
+            INSERT INTO tenants (tenant_id, name, slug, status, created_at, updated_at)
+            VALUES (?1, 'test_tenant', 'test', 'active', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO databases (database_id, tenant_id, name, state, created_at, updated_at)
+            VALUES (?1, ?2, 'test_db', 'ready', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&database_id.to_bytes()[..])
+        .bind(&tenant_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        sqlx::query(
+            r#"
+            INSERT INTO collections (collection_id, database_id, name, dimension, metric, embedding_model, created_at, updated_at)
+            VALUES (?1, ?2, 'test_collection', 128, 'cosine', 'test', datetime('now'), datetime('now'))
+            "#,
+        )
+        .bind(&collection_id.to_bytes()[..])
+        .bind(&database_id.to_bytes()[..])
+        .execute(pool)
+        .await
+        .unwrap();
+
+        collection_id
+    }
+
+    #[tokio::test]
+    async fn test_save_list_delete() {
+        let pool = create_test_pool().await;
+        let repository = ScheduledJobRepository::new(pool.clone());
+        let collection_id = create_test_collection(&pool).await;
+
+        let first = JobId::new();
+        let second = JobId::new();
+        repository
+            .save(first, collection_id, &json!({"name": "expire"}))
+            .await
+            .unwrap();
+        repository
+            .save(second, collection_id, &json!({"name": "compact"}))
+            .await
+            .unwrap();
+        // Saving again replaces the job in place
+        repository
+            .save(first, collection_id, &json!({"name": "expire", "runs": 1}))
+            .await
+            .unwrap();
+        let jobs = repository.list_all().await.unwrap();
+        assert_eq!(
+            jobs,
+            vec![
+                json!({"name": "expire", "runs": 1}),
+                json!({"name": "compact"})
+            ]
+        );
+
+        repository.delete(first).await.unwrap();
+        repository.delete(first).await.unwrap();
+        let jobs = repository.list_all().await.unwrap();
+        assert_eq!(jobs, vec![json!({"name": "compact"})]);
+
+        // Jobs go with their collection
+        sqlx::query("DELETE FROM collections WHERE collection_id = ?1")
+            .bind(&collection_id.to_bytes()[..])
+            .execute(&pool)
+            .await
+            .unwrap();
+        assert!(repository.list_all().await.unwrap().is_empty());
+    }
+}
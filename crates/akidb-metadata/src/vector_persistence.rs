@@ -1,7 +1,7 @@
 //! Vector persistence layer for storing vector documents in SQLite.
 //!
-//! This module provides CRUD operations for vector documents with binary
-//! serialization using bincode for efficient storage.
+//! This module provides CRUD operations for vector documents with vectors
+//! stored as compact binary blobs.
 
 use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, VectorDocument};
 use chrono::Utc;
@@ -9,7 +9,9 @@ use sqlx::SqlitePool;
 
 /// Repository for persisting vector documents to SQLite.
 ///
-/// Vectors are serialized as binary blobs using bincode for efficient storage.
+/// Vectors are stored as binary blobs: a little-endian `u64` length followed
+/// by the little-endian `f32` values (the layout bincode 1.x produced for
+/// `Vec<f32>`, so existing rows stay readable).
 /// Supports batch operations for high-throughput ingestion.
 pub struct VectorPersistence {
     pool: SqlitePool,
@@ -33,9 +35,7 @@ impl VectorPersistence {
         let collection_id_bytes = collection_id.to_bytes();
         let doc_id_bytes = doc.doc_id.to_bytes();
 
-        // Serialize vector as binary (bincode)
-        let vector_bytes = bincode::serialize(&doc.vector)
-            .map_err(|e| CoreError::internal(format!("Failed to serialize vector: {}", e)))?;
+        let vector_bytes = encode_vector(&doc.vector);
 
         // Serialize metadata as JSON (if present)
         let metadata_json = doc
@@ -99,8 +99,7 @@ impl VectorPersistence {
         };
 
         // Deserialize vector
-        let vector: Vec<f32> = bincode::deserialize(&vector_bytes)
-            .map_err(|e| CoreError::internal(format!("Failed to deserialize vector: {}", e)))?;
+        let vector = decode_vector(&vector_bytes)?;
 
         // Deserialize metadata
         let metadata = metadata_json
@@ -182,8 +181,7 @@ impl VectorPersistence {
                 .map_err(|e| CoreError::internal(format!("Failed to parse document ID: {}", e)))?;
 
             // Deserialize vector
-            let vector: Vec<f32> = bincode::deserialize(&vector_bytes)
-                .map_err(|e| CoreError::internal(format!("Failed to deserialize vector: {}", e)))?;
+            let vector = decode_vector(&vector_bytes)?;
 
             // Deserialize metadata
             let metadata = metadata_json
@@ -238,8 +236,7 @@ impl VectorPersistence {
         for doc in documents {
             let doc_id_bytes = doc.doc_id.to_bytes();
 
-            let vector_bytes = bincode::serialize(&doc.vector)
-                .map_err(|e| CoreError::internal(format!("Failed to serialize vector: {}", e)))?;
+            let vector_bytes = encode_vector(&doc.vector);
 
             let metadata_json = doc
                 .metadata
@@ -316,6 +313,34 @@ impl VectorPersistence {
     }
 }
 
+/// Encodes a vector as its length (`u64`) and values (`f32`), little-endian.
+fn encode_vector(vector: &[f32]) -> Vec<u8> {
+    let mut bytes = Vec::with_capacity(8 + vector.len() * 4);
+    bytes.extend_from_slice(&(vector.len() as u64).to_le_bytes());
+    for value in vector {
+        bytes.extend_from_slice(&value.to_le_bytes());
+    }
+    bytes
+}
+
+/// Decodes a vector written by [`encode_vector`].
+fn decode_vector(bytes: &[u8]) -> CoreResult<Vec<f32>> {
+    let invalid = || CoreError::internal("Failed to deserialize vector: truncated or corrupt blob");
+    if bytes.len() < 8 {
+        return Err(invalid());
+    }
+    let (len, values) = bytes.split_at(8);
+    let len = u64::from_le_bytes(len.try_into().map_err(|_| invalid())?);
+    let len = usize::try_from(len).map_err(|_| invalid())?;
+    if values.len() / 4 != len || values.len() % 4 != 0 {
+        return Err(invalid());
+    }
+    Ok(values
+        .chunks_exact(4)
+        .map(|b| f32::from_le_bytes([b[0], b[1], b[2], b[3]]))
+        .collect())
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -335,6 +360,19 @@ mod tests {
         pool
     }
 
+    #[test]
+    fn test_vector_blob_layout() {
+        let bytes = encode_vector(&[1.0, -0.5]);
+        assert_eq!(
+            bytes,
+            [2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x80, 0x3f, 0, 0, 0, 0xbf]
+        );
+        assert_eq!(decode_vector(&bytes).unwrap(), [1.0, -0.5]);
+        assert!(decode_vector(&encode_vector(&[])).unwrap().is_empty());
+        assert!(decode_vector(&bytes[..bytes.len() - 1]).is_err());
+        assert!(decode_vector(&bytes[..4]).is_err());
+    }
+
     #[tokio::test]
     async fn test_save_and_load_vector() {
         let pool = create_test_pool().await;
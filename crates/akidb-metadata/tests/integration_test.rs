@@ -311,9 +311,14 @@ async fn update_collection_parameters() {
         CollectionDescriptor::new(database.database_id, "updates", 512, "old-model");
     ctx.collections.create(&collection).await.expect("create");
 
-    // Update HNSW parameters and model
+    // Update HNSW parameters, model, read-only mode and vector settings
     collection.hnsw_m = 48;
     collection.embedding_model = "new-model".to_string();
+    collection.read_only = true;
+    collection.sparse_index = Some(serde_json::json!({"max_nnz": 64}));
+    collection.named_vectors = Some(serde_json::json!({"title": {"dimension": 16}}));
+    collection.search_defaults = Some(serde_json::json!({"top_k": 5, "ef_search": 256}));
+    collection.text_analysis = Some(serde_json::json!({"stopwords": ["the"]}));
     collection.touch();
     ctx.collections.update(&collection).await.expect("update");
 
@@ -325,6 +330,11 @@ async fn update_collection_parameters() {
         .expect("exists");
     assert_eq!(updated.hnsw_m, 48);
     assert_eq!(updated.embedding_model, "new-model");
+    assert!(updated.read_only);
+    assert_eq!(updated.sparse_index, collection.sparse_index);
+    assert_eq!(updated.named_vectors, collection.named_vectors);
+    assert_eq!(updated.search_defaults, collection.search_defaults);
+    assert_eq!(updated.text_analysis, collection.text_analysis);
 }
 
 #[tokio::test]
@@ -977,6 +987,53 @@ async fn update_last_used_timestamp() {
     assert!(updated.last_used_at.is_some());
 }
 
+#[tokio::test]
+async fn set_api_key_expiry() {
+    let ctx = setup_context().await;
+    let tenant = TenantDescriptor::new("Rotation Corp", "rotation");
+    ctx.catalog.create(&tenant).await.expect("create tenant");
+
+    let key_hash = hash_api_key(&generate_api_key());
+    let descriptor = ApiKeyDescriptor::new(
+        tenant.tenant_id,
+        "rotating-key".to_string(),
+        vec!["collection::read".to_string()],
+        None,
+        None,
+    );
+    ctx.api_keys
+        .create(&descriptor, &key_hash)
+        .await
+        .expect("create API key");
+
+    let expires_at = chrono::Utc::now() + chrono::Duration::hours(1);
+    ctx.api_keys
+        .set_expires_at(descriptor.key_id, Some(expires_at))
+        .await
+        .expect("set expiry");
+    let fetched = ctx
+        .api_keys
+        .get(descriptor.key_id)
+        .await
+        .expect("get key")
+        .expect("key exists");
+    assert_eq!(
+        fetched.expires_at.map(|t| t.timestamp()),
+        Some(expires_at.timestamp())
+    );
+
+    ctx.api_keys
+        .delete(descriptor.key_id)
+        .await
+        .expect("delete key");
+    let err = ctx
+        .api_keys
+        .set_expires_at(descriptor.key_id, None)
+        .await
+        .expect_err("missing key");
+    assert!(matches!(err, CoreError::NotFound { .. }));
+}
+
 #[tokio::test]
 async fn cascade_delete_tenant_removes_api_keys() {
     let ctx = setup_context().await;
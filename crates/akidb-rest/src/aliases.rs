@@ -0,0 +1,109 @@
+//! Collection aliases in request paths
+//!
+//! An alias (e.g. `docs`) points at a collection (e.g. `docs_v2`) and can be
+//! switched atomically, so clients keep addressing `docs` while a new version
+//! is built next to it (blue-green reindexing). Every collection endpoint
+//! accepts an alias in place of the collection ID: `resolve_aliases` rewrites
+//! `/api/v1/collections/docs/query` to the ID the alias points at before the
+//! request is routed, so handlers only ever see IDs.
+//!
+//! Unknown aliases are left as they are and fail like any invalid collection
+//! ID.
+//!
+//! Middleware added with `Router::layer` runs after routing, so this one
+//! wraps the whole router:
+//! ```ignore
+//! let app = middleware::from_fn_with_state(service, aliases::resolve_aliases).layer(app);
+//! ```
+
+use akidb_core::CollectionId;
+use akidb_service::CollectionService;
+use axum::{
+    body::Body,
+    extract::State,
+    http::{Request, Uri},
+    middleware::Next,
+    response::Response,
+};
+use std::str::FromStr;
+use std::sync::Arc;
+
+/// Path prefix of the collection endpoints.
+pub const COLLECTIONS_PATH: &str = "/api/v1/collections/";
+
+/// Replace an alias in the collection segment of the path by the ID of its
+/// collection.
+pub async fn resolve_aliases(
+    State(service): State<Arc<CollectionService>>,
+    mut req: Request<Body>,
+    next: Next<Body>,
+) -> Response {
+    if let Some(alias) = alias_segment(req.uri().path()) {
+        if let Ok(collection_id) = service.resolve_alias(alias).await {
+            if let Some(uri) = with_collection_id(req.uri(), collection_id) {
+                *req.uri_mut() = uri;
+            }
+        }
+    }
+    next.run(req).await
+}
+
+/// The collection segment of a collection endpoint path, unless it is a
+/// collection ID.
+fn alias_segment(path: &str) -> Option<&str> {
+    let rest = path.strip_prefix(COLLECTIONS_PATH)?;
+    let segment = rest.split('/').next().unwrap_or_default();
+    if segment.is_empty() || CollectionId::from_str(segment).is_ok() {
+        return None;
+    }
+    Some(segment)
+}
+
+/// `uri` with its collection segment replaced by `collection_id`.
+fn with_collection_id(uri: &Uri, collection_id: CollectionId) -> Option<Uri> {
+    let rest = uri.path().strip_prefix(COLLECTIONS_PATH)?;
+    let tail = rest.find('/').map_or("", |i| &rest[i..]);
+    let mut path = format!("{}{}{}", COLLECTIONS_PATH, collection_id, tail);
+    if let Some(query) = uri.query() {
+        path = format!("{}?{}", path, query);
+    }
+    let mut parts = uri.clone().into_parts();
+    parts.path_and_query = Some(path.parse().ok()?);
+    Uri::from_parts(parts).ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_rewrites_alias_segment() {
+        let collection_id = CollectionId::new();
+        let id_path = format!("/api/v1/collections/{}/query", collection_id);
+        assert_eq!(alias_segment(&id_path), None);
+        assert_eq!(alias_segment("/api/v1/collections"), None);
+        assert_eq!(alias_segment("/api/v1/aliases/docs"), None);
+        assert_eq!(
+            alias_segment("/api/v1/collections/docs/query"),
+            Some("docs")
+        );
+        assert_eq!(alias_segment("/api/v1/collections/docs"), Some("docs"));
+
+        let uri: Uri = "/api/v1/collections/docs/docs/42?include_vector=false"
+            .parse()
+            .unwrap();
+        let rewritten = with_collection_id(&uri, collection_id).unwrap();
+        assert_eq!(
+            rewritten.to_string(),
+            format!(
+                "/api/v1/collections/{}/docs/42?include_vector=false",
+                collection_id
+            )
+        );
+        let uri: Uri = "/api/v1/collections/docs".parse().unwrap();
+        assert_eq!(
+            with_collection_id(&uri, collection_id).unwrap().path(),
+            format!("/api/v1/collections/{}", collection_id)
+        );
+    }
+}
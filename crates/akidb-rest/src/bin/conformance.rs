@@ -0,0 +1,20 @@
+//! Runs the conformance suite against a deployed server.
+//!
+//! Usage: `akidb-conformance [ENDPOINT]` (default: `$AKIDB_ENDPOINT`, then
+//! `http://localhost:8080`). Exits with status 1 if a check fails.
+
+use akidb_rest::conformance;
+
+#[tokio::main]
+async fn main() {
+    let endpoint = std::env::args()
+        .nth(1)
+        .or_else(|| std::env::var("AKIDB_ENDPOINT").ok())
+        .unwrap_or_else(|| "http://localhost:8080".to_string());
+
+    let report = conformance::run(&endpoint).await;
+    println!("{}", report);
+    if !report.passed() {
+        std::process::exit(1);
+    }
+}
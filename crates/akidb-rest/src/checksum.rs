@@ -0,0 +1,226 @@
+//! End-to-end body checksums
+//!
+//! Detects transfers corrupted by flaky networks instead of silently ingesting
+//! (or handing out) bad data:
+//! - Requests may carry `Content-MD5` (base64 MD5, RFC 1864) and/or
+//!   `X-Checksum-XXH64` (hex XXH64, seed 0). The body is verified before the
+//!   handler runs; a mismatch is rejected with 400.
+//! - Requests with `X-Want-Checksum: md5` or `X-Want-Checksum: xxh64` get the
+//!   matching header on the response, so downloads (exports, snapshots) can be
+//!   verified by the client.
+//!
+//! Requests without these headers are passed through unbuffered.
+//!
+//! # Example
+//! ```no_run
+//! use akidb_rest::checksum;
+//! use axum::{middleware, routing::get, Router};
+//!
+//! let app: Router = Router::new()
+//!     .route("/", get(|| async { "ok" }))
+//!     .layer(middleware::from_fn(checksum::verify_checksums));
+//! ```
+
+use axum::{
+    body::{self, Body},
+    http::{HeaderMap, HeaderValue, Request, StatusCode},
+    middleware::Next,
+    response::Response,
+};
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use twox_hash::XxHash64;
+
+/// Request header carrying the base64 MD5 of the body.
+pub const CONTENT_MD5: &str = "content-md5";
+
+/// Request/response header carrying the hex XXH64 (seed 0) of the body.
+pub const CHECKSUM_XXH64: &str = "x-checksum-xxh64";
+
+/// Request header asking for a checksum of the response body.
+pub const WANT_CHECKSUM: &str = "x-want-checksum";
+
+/// Supported body checksum algorithms.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ChecksumAlgorithm {
+    Md5,
+    Xxh64,
+}
+
+impl ChecksumAlgorithm {
+    /// Header carrying this checksum.
+    pub fn header(&self) -> &'static str {
+        match self {
+            Self::Md5 => CONTENT_MD5,
+            Self::Xxh64 => CHECKSUM_XXH64,
+        }
+    }
+
+    /// Encoded checksum of `data` (base64 for MD5, lowercase hex for XXH64).
+    pub fn compute(&self, data: &[u8]) -> String {
+        match self {
+            Self::Md5 => STANDARD.encode(md5::compute(data).0),
+            Self::Xxh64 => format!("{:016x}", XxHash64::oneshot(0, data)),
+        }
+    }
+
+    /// Returns true if `expected` is the checksum of `data`.
+    pub fn matches(&self, expected: &str, data: &[u8]) -> bool {
+        let actual = self.compute(data);
+        match self {
+            Self::Md5 => actual == expected.trim(),
+            Self::Xxh64 => actual.eq_ignore_ascii_case(expected.trim()),
+        }
+    }
+
+    fn from_name(name: &str) -> Option<Self> {
+        match name.trim().to_ascii_lowercase().as_str() {
+            "md5" => Some(Self::Md5),
+            "xxh64" | "xxhash64" => Some(Self::Xxh64),
+            _ => None,
+        }
+    }
+}
+
+/// Checksums sent with a request.
+fn expected_checksums(
+    headers: &HeaderMap,
+) -> Result<Vec<(ChecksumAlgorithm, String)>, (StatusCode, String)> {
+    let mut expected = Vec::new();
+    for algorithm in [ChecksumAlgorithm::Md5, ChecksumAlgorithm::Xxh64] {
+        if let Some(value) = headers.get(algorithm.header()) {
+            let value = value.to_str().map_err(|_| {
+                (
+                    StatusCode::BAD_REQUEST,
+                    format!("Invalid {} header", algorithm.header()),
+                )
+            })?;
+            expected.push((algorithm, value.to_string()));
+        }
+    }
+    Ok(expected)
+}
+
+/// Middleware verifying request body checksums and adding response checksums.
+pub async fn verify_checksums(
+    req: Request<Body>,
+    next: Next<Body>,
+) -> Result<Response, (StatusCode, String)> {
+    let want = match req.headers().get(WANT_CHECKSUM) {
+        Some(value) => {
+            let algorithm = value
+                .to_str()
+                .ok()
+                .and_then(ChecksumAlgorithm::from_name)
+                .ok_or_else(|| {
+                    (
+                        StatusCode::BAD_REQUEST,
+                        format!("Invalid {} header, must be md5 or xxh64", WANT_CHECKSUM),
+                    )
+                })?;
+            Some(algorithm)
+        }
+        None => None,
+    };
+
+    let expected = expected_checksums(req.headers())?;
+    let req = if expected.is_empty() {
+        req
+    } else {
+        let (parts, body) = req.into_parts();
+        let bytes = hyper::body::to_bytes(body).await.map_err(|e| {
+            (
+                StatusCode::BAD_REQUEST,
+                format!("Failed to read request body: {}", e),
+            )
+        })?;
+        for (algorithm, value) in &expected {
+            if !algorithm.matches(value, &bytes) {
+                return Err((
+                    StatusCode::BAD_REQUEST,
+                    format!(
+                        "{} mismatch: expected {}, got {}",
+                        algorithm.header(),
+                        value,
+                        algorithm.compute(&bytes)
+                    ),
+                ));
+            }
+        }
+        Request::from_parts(parts, Body::from(bytes))
+    };
+
+    let response = next.run(req).await;
+    let Some(algorithm) = want else {
+        return Ok(response);
+    };
+
+    let (mut parts, body) = response.into_parts();
+    let bytes = hyper::body::to_bytes(body).await.map_err(|e| {
+        (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            format!("Failed to read response body: {}", e),
+        )
+    })?;
+    let checksum = HeaderValue::from_str(&algorithm.compute(&bytes))
+        .expect("base64 and hex are valid header values");
+    parts.headers.insert(algorithm.header(), checksum);
+    Ok(Response::from_parts(
+        parts,
+        body::boxed(body::Full::from(bytes)),
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{middleware, routing::post, Router};
+    use tower::ServiceExt;
+
+    fn app() -> Router {
+        Router::new()
+            .route("/", post(|body: String| async move { body }))
+            .layer(middleware::from_fn(verify_checksums))
+    }
+
+    #[test]
+    fn test_known_checksums() {
+        assert_eq!(
+            ChecksumAlgorithm::Md5.compute(b""),
+            "1B2M2Y8AsgTpgAmY7PhCfg=="
+        );
+        assert_eq!(ChecksumAlgorithm::Xxh64.compute(b""), "ef46db3751d8e999");
+        assert!(ChecksumAlgorithm::Xxh64.matches("EF46DB3751D8E999", b""));
+    }
+
+    #[tokio::test]
+    async fn test_rejects_corrupt_body() {
+        let body = "{\"vector\": [0.1, 0.2]}";
+        let checksum = ChecksumAlgorithm::Xxh64.compute(body.as_bytes());
+
+        let request = Request::post("/")
+            .header(CHECKSUM_XXH64, &checksum)
+            .body(Body::from(body))
+            .unwrap();
+        let response = app().oneshot(request).await.unwrap();
+        assert_eq!(response.status(), StatusCode::OK);
+
+        let request = Request::post("/")
+            .header(CHECKSUM_XXH64, &checksum)
+            .body(Body::from("{\"vector\": [0.1, 0.3]}"))
+            .unwrap();
+        let response = app().oneshot(request).await.unwrap();
+        assert_eq!(response.status(), StatusCode::BAD_REQUEST);
+    }
+
+    #[tokio::test]
+    async fn test_response_checksum() {
+        let request = Request::post("/")
+            .header(WANT_CHECKSUM, "md5")
+            .body(Body::from("snapshot"))
+            .unwrap();
+        let response = app().oneshot(request).await.unwrap();
+
+        let expected = ChecksumAlgorithm::Md5.compute(b"snapshot");
+        assert_eq!(response.headers()[CONTENT_MD5], expected.as_str());
+    }
+}
@@ -0,0 +1,154 @@
+//! Request and response compression
+//!
+//! Bulk writes of high-dimensional vectors are bandwidth-bound over WAN
+//! links. Clients may compress request bodies with gzip or zstd and say so
+//! in `Content-Encoding`; bodies are decompressed before any other
+//! middleware or handler sees them. Responses are compressed with the best
+//! encoding listed in the request's `Accept-Encoding` (see
+//! `tower_http::compression::CompressionLayer`).
+//!
+//! Body checksums (`Content-MD5`, `X-Checksum-XXH64`) and body size limits
+//! apply to the decompressed bodies. Decompressed bodies are capped at
+//! `MAX_DECOMPRESSED_BODY_BYTES` so a small compressed body cannot expand
+//! without bound.
+
+use akidb_service::MAX_REPLICATION_BODY_BYTES;
+use axum::{
+    body::Body,
+    http::{header, HeaderValue, Request, StatusCode},
+    middleware::Next,
+    response::Response,
+};
+use std::io::Read;
+
+/// Maximum size of a decompressed request body (the largest body limit of
+/// any endpoint).
+pub const MAX_DECOMPRESSED_BODY_BYTES: usize = MAX_REPLICATION_BODY_BYTES;
+
+/// Content encodings accepted on request bodies.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ContentEncoding {
+    Gzip,
+    Zstd,
+}
+
+impl ContentEncoding {
+    /// Encoding named by a `Content-Encoding` header (None: identity).
+    pub fn from_header(value: &HeaderValue) -> Result<Option<Self>, (StatusCode, String)> {
+        match value.to_str().map(|v| v.trim().to_ascii_lowercase()).as_deref() {
+            Ok("gzip") | Ok("x-gzip") => Ok(Some(Self::Gzip)),
+            Ok("zstd") => Ok(Some(Self::Zstd)),
+            Ok("identity") | Ok("") => Ok(None),
+            _ => Err((
+                StatusCode::UNSUPPORTED_MEDIA_TYPE,
+                format!(
+                    "Unsupported Content-Encoding {:?}, must be gzip or zstd",
+                    value
+                ),
+            )),
+        }
+    }
+
+    /// Decompresses `data`, rejecting bodies larger than `limit` once
+    /// decompressed.
+    pub fn decode(&self, data: &[u8], limit: usize) -> Result<Vec<u8>, (StatusCode, String)> {
+        let reader: Box<dyn Read + '_> = match self {
+            Self::Gzip => Box::new(flate2::read::GzDecoder::new(data)),
+            Self::Zstd => Box::new(zstd::stream::read::Decoder::new(data).map_err(|e| {
+                (
+                    StatusCode::INTERNAL_SERVER_ERROR,
+                    format!("Failed to start zstd decoder: {}", e),
+                )
+            })?),
+        };
+        let mut decoded = Vec::new();
+        reader
+            .take(limit as u64 + 1)
+            .read_to_end(&mut decoded)
+            .map_err(|e| {
+                (
+                    StatusCode::BAD_REQUEST,
+                    format!("Failed to decompress request body: {}", e),
+                )
+            })?;
+        if decoded.len() > limit {
+            return Err((
+                StatusCode::PAYLOAD_TOO_LARGE,
+                format!("Decompressed request body exceeds {} bytes", limit),
+            ));
+        }
+        Ok(decoded)
+    }
+}
+
+/// Middleware decompressing gzip and zstd request bodies.
+pub async fn decompress_requests(
+    req: Request<Body>,
+    next: Next<Body>,
+) -> Result<Response, (StatusCode, String)> {
+    let encoding = match req.headers().get(header::CONTENT_ENCODING) {
+        Some(value) => ContentEncoding::from_header(value)?,
+        None => None,
+    };
+    let Some(encoding) = encoding else {
+        return Ok(next.run(req).await);
+    };
+
+    let (mut parts, body) = req.into_parts();
+    let data = hyper::body::to_bytes(body).await.map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Failed to read request body: {}", e),
+        )
+    })?;
+    let decoded = tokio::task::spawn_blocking(move || {
+        encoding.decode(&data, MAX_DECOMPRESSED_BODY_BYTES)
+    })
+    .await
+    .map_err(|e| (StatusCode::INTERNAL_SERVER_ERROR, e.to_string()))??;
+
+    parts.headers.remove(header::CONTENT_ENCODING);
+    parts
+        .headers
+        .insert(header::CONTENT_LENGTH, HeaderValue::from(decoded.len()));
+    Ok(next.run(Request::from_parts(parts, Body::from(decoded))).await)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Write;
+
+    fn gzip(data: &[u8]) -> Vec<u8> {
+        let mut encoder = flate2::write::GzEncoder::new(Vec::new(), flate2::Compression::default());
+        encoder.write_all(data).unwrap();
+        encoder.finish().unwrap()
+    }
+
+    #[test]
+    fn test_decode_request_bodies() {
+        let body = br#"{"vector": [0.1, 0.2, 0.3, 0.4]}"#.repeat(100);
+        let encoding = |value| ContentEncoding::from_header(&HeaderValue::from_static(value));
+
+        let gzip_encoding = encoding("GZIP").unwrap().unwrap();
+        assert_eq!(gzip_encoding.decode(&gzip(&body), body.len()).unwrap(), body);
+
+        let zstd_encoding = encoding("zstd").unwrap().unwrap();
+        let compressed = zstd::encode_all(&body[..], 3).unwrap();
+        assert!(compressed.len() < body.len() / 10);
+        assert_eq!(zstd_encoding.decode(&compressed, body.len()).unwrap(), body);
+
+        assert_eq!(encoding("identity").unwrap(), None);
+        let (status, _) = encoding("br").unwrap_err();
+        assert_eq!(status, StatusCode::UNSUPPORTED_MEDIA_TYPE);
+
+        // Bodies expanding past the limit are rejected
+        let (status, _) = gzip_encoding
+            .decode(&gzip(&body), body.len() - 1)
+            .unwrap_err();
+        assert_eq!(status, StatusCode::PAYLOAD_TOO_LARGE);
+
+        let (status, _) = gzip_encoding.decode(b"not gzip", 1024).unwrap_err();
+        assert_eq!(status, StatusCode::BAD_REQUEST);
+    }
+}
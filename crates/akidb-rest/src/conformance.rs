@@ -0,0 +1,484 @@
+//! Conformance suite for deployed servers
+//!
+//! Exercises the core REST API (collections, writes in every encoding,
+//! reads, search and deletes) against a running server and checks the
+//! responses the way a client relies on them: status codes, round-tripped
+//! vectors and metadata, result order. Self-hosters run it against their
+//! deployment, e.g. behind a proxy or gateway, to catch layers that
+//! rewrite bodies, drop headers or change status codes:
+//!
+//! ```text
+//! akidb-conformance http://akidb.internal:8080
+//! ```
+//!
+//! The suite works in a collection of its own, deleted at the end, and
+//! leaves the rest of the server's data alone. Only plain `http://`
+//! endpoints are supported.
+
+use crate::vector_encoding::{encode_vector, OCTET_STREAM};
+use akidb_core::{CollectionId, DocumentId};
+use hyper::{client::HttpConnector, Body, Client, Method, Request, StatusCode};
+use serde_json::{json, Value};
+use std::fmt;
+use std::time::Duration;
+
+/// Timeout of each request of the suite.
+pub const CONFORMANCE_REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Dimension of the suite's collection.
+const DIMENSION: usize = 4;
+
+/// Outcome of one check.
+#[derive(Debug, Clone)]
+pub struct CheckResult {
+    pub name: &'static str,
+    /// Why the check failed (None if it passed).
+    pub error: Option<String>,
+}
+
+/// Outcome of a conformance run, in check order.
+#[derive(Debug, Clone)]
+pub struct ConformanceReport {
+    pub endpoint: String,
+    pub checks: Vec<CheckResult>,
+}
+
+impl ConformanceReport {
+    /// Whether every check passed.
+    pub fn passed(&self) -> bool {
+        self.checks.iter().all(|check| check.error.is_none())
+    }
+}
+
+impl fmt::Display for ConformanceReport {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        writeln!(f, "Conformance of {}", self.endpoint)?;
+        for check in &self.checks {
+            match &check.error {
+                None => writeln!(f, "  PASS {}", check.name)?,
+                Some(error) => writeln!(f, "  FAIL {}: {}", check.name, error)?,
+            }
+        }
+        let failed = self.checks.iter().filter(|c| c.error.is_some()).count();
+        write!(
+            f,
+            "{} checks, {} passed, {} failed",
+            self.checks.len(),
+            self.checks.len() - failed,
+            failed
+        )
+    }
+}
+
+struct Response {
+    status: StatusCode,
+    body: Value,
+}
+
+impl Response {
+    fn expect(self, status: StatusCode) -> Result<Value, String> {
+        if self.status != status {
+            return Err(format!(
+                "expected {}, got {}: {}",
+                status, self.status, self.body
+            ));
+        }
+        Ok(self.body)
+    }
+}
+
+struct ConformanceClient {
+    client: Client<HttpConnector>,
+    endpoint: String,
+}
+
+impl ConformanceClient {
+    async fn request(
+        &self,
+        method: Method,
+        path: &str,
+        content_type: &str,
+        body: Vec<u8>,
+    ) -> Result<Response, String> {
+        let uri = format!("{}{}", self.endpoint, path);
+        let request = Request::builder()
+            .method(method)
+            .uri(&uri)
+            .header("content-type", content_type)
+            .body(Body::from(body))
+            .map_err(|e| format!("invalid endpoint: {}", e))?;
+        let response =
+            tokio::time::timeout(CONFORMANCE_REQUEST_TIMEOUT, self.client.request(request))
+                .await
+                .map_err(|_| format!("{} timed out", uri))?
+                .map_err(|e| format!("{}: {}", uri, e))?;
+        let status = response.status();
+        let data = hyper::body::to_bytes(response.into_body())
+            .await
+            .map_err(|e| format!("{}: {}", uri, e))?;
+        // Error bodies are plain text
+        let body = serde_json::from_slice(&data)
+            .unwrap_or_else(|_| Value::String(String::from_utf8_lossy(&data).into_owned()));
+        Ok(Response { status, body })
+    }
+
+    async fn json(&self, method: Method, path: &str, body: Value) -> Result<Response, String> {
+        self.request(
+            method,
+            path,
+            "application/json",
+            body.to_string().into_bytes(),
+        )
+        .await
+    }
+
+    async fn get(&self, path: &str) -> Result<Response, String> {
+        self.request(Method::GET, path, "application/json", Vec::new())
+            .await
+    }
+
+    async fn delete(&self, path: &str) -> Result<Response, String> {
+        self.request(Method::DELETE, path, "application/json", Vec::new())
+            .await
+    }
+}
+
+fn field<'a>(value: &'a Value, pointer: &str) -> Result<&'a Value, String> {
+    value
+        .pointer(pointer)
+        .ok_or_else(|| format!("response has no {}: {}", pointer, value))
+}
+
+fn check_eq(what: &str, actual: &Value, expected: &Value) -> Result<(), String> {
+    if actual != expected {
+        return Err(format!("{} is {}, expected {}", what, actual, expected));
+    }
+    Ok(())
+}
+
+/// Run the suite against the server at `endpoint` (e.g.
+/// `http://localhost:8080`).
+pub async fn run(endpoint: &str) -> ConformanceReport {
+    let client = ConformanceClient {
+        client: Client::new(),
+        endpoint: endpoint.trim_end_matches('/').to_string(),
+    };
+    let mut report = ConformanceReport {
+        endpoint: client.endpoint.clone(),
+        checks: Vec::new(),
+    };
+    let mut record = |name: &'static str, result: Result<(), String>| {
+        let passed = result.is_ok();
+        report.checks.push(CheckResult {
+            name,
+            error: result.err(),
+        });
+        passed
+    };
+
+    record("health", check_health(&client).await);
+
+    let collection_id = match create_collection(&client).await {
+        Ok(collection_id) => {
+            record("create collection", Ok(()));
+            collection_id
+        }
+        Err(e) => {
+            record("create collection", Err(e));
+            return report;
+        }
+    };
+    let collection = format!("/api/v1/collections/{}", collection_id);
+    let doc_id = DocumentId::new().to_string();
+
+    record(
+        "get collection",
+        check_get_collection(&client, &collection).await,
+    );
+    if record("insert", check_insert(&client, &collection, &doc_id).await) {
+        record(
+            "get document",
+            check_get_document(&client, &collection, &doc_id).await,
+        );
+    }
+    record(
+        "batch insert",
+        check_batch_insert(&client, &collection).await,
+    );
+    record("query", check_query(&client, &collection, &doc_id).await);
+    record(
+        "binary vectors",
+        check_binary_vectors(&client, &collection).await,
+    );
+    record("upsert", check_upsert(&client, &collection, &doc_id).await);
+    record(
+        "delete document",
+        check_delete_document(&client, &collection, &doc_id).await,
+    );
+    record(
+        "malformed request",
+        check_malformed_request(&client, &collection).await,
+    );
+    record(
+        "unknown collection",
+        check_unknown_collection(&client).await,
+    );
+    record(
+        "delete collection",
+        check_delete_collection(&client, &collection).await,
+    );
+    report
+}
+
+async fn check_health(client: &ConformanceClient) -> Result<(), String> {
+    client.get("/health").await?.expect(StatusCode::OK)?;
+    Ok(())
+}
+
+async fn create_collection(client: &ConformanceClient) -> Result<String, String> {
+    let body = client
+        .json(
+            Method::POST,
+            "/api/v1/collections",
+            json!({
+                "name": format!("conformance-{}", CollectionId::new()),
+                "dimension": DIMENSION,
+                "metric": "cosine",
+            }),
+        )
+        .await?
+        .expect(StatusCode::CREATED)?;
+    check_eq("dimension", field(&body, "/dimension")?, &json!(DIMENSION))?;
+    field(&body, "/collection_id")?
+        .as_str()
+        .map(str::to_string)
+        .ok_or_else(|| format!("collection_id is not a string: {}", body))
+}
+
+async fn check_get_collection(client: &ConformanceClient, collection: &str) -> Result<(), String> {
+    client.get(collection).await?.expect(StatusCode::OK)?;
+    Ok(())
+}
+
+async fn check_insert(
+    client: &ConformanceClient,
+    collection: &str,
+    doc_id: &str,
+) -> Result<(), String> {
+    let body = client
+        .json(
+            Method::POST,
+            &format!("{}/insert", collection),
+            json!({
+                "doc_id": doc_id,
+                "external_id": "conformance-a",
+                "vector": [1.0, 0.0, 0.0, 0.0],
+                "metadata": {"version": 1},
+            }),
+        )
+        .await?
+        .expect(StatusCode::OK)?;
+    check_eq("doc_id", field(&body, "/doc_id")?, &json!(doc_id))
+}
+
+async fn check_get_document(
+    client: &ConformanceClient,
+    collection: &str,
+    doc_id: &str,
+) -> Result<(), String> {
+    let body = client
+        .get(&format!("{}/docs/{}", collection, doc_id))
+        .await?
+        .expect(StatusCode::OK)?;
+    check_eq(
+        "external_id",
+        field(&body, "/document/external_id")?,
+        &json!("conformance-a"),
+    )?;
+    check_eq(
+        "vector",
+        field(&body, "/document/vector")?,
+        &json!([1.0, 0.0, 0.0, 0.0]),
+    )?;
+    check_eq(
+        "metadata",
+        field(&body, "/document/metadata")?,
+        &json!({"version": 1}),
+    )
+}
+
+async fn check_batch_insert(client: &ConformanceClient, collection: &str) -> Result<(), String> {
+    client
+        .json(
+            Method::POST,
+            &format!("{}/insert/batch", collection),
+            json!({
+                "documents": [
+                    {"vector": [0.9, 0.1, 0.0, 0.0]},
+                    {"vector": [0.0, 1.0, 0.0, 0.0]},
+                    {"vector": [0.0, 0.0, 1.0, 0.0]},
+                ],
+            }),
+        )
+        .await?
+        .expect(StatusCode::OK)?;
+    Ok(())
+}
+
+async fn check_query(
+    client: &ConformanceClient,
+    collection: &str,
+    doc_id: &str,
+) -> Result<(), String> {
+    let body = client
+        .json(
+            Method::POST,
+            &format!("{}/query", collection),
+            json!({"query_vector": [1.0, 0.0, 0.0, 0.0], "top_k": 2}),
+        )
+        .await?
+        .expect(StatusCode::OK)?;
+    let matches = field(&body, "/matches")?
+        .as_array()
+        .ok_or_else(|| format!("matches is not an array: {}", body))?;
+    if matches.len() != 2 {
+        return Err(format!("expected 2 matches, got {}", matches.len()));
+    }
+    check_eq(
+        "best match",
+        field(&body, "/matches/0/doc_id")?,
+        &json!(doc_id),
+    )
+}
+
+async fn check_binary_vectors(client: &ConformanceClient, collection: &str) -> Result<(), String> {
+    let vector = [0.0, 0.0, 0.0, 1.0];
+    let body = client
+        .request(
+            Method::POST,
+            &format!("{}/insert?external_id=conformance-binary", collection),
+            OCTET_STREAM,
+            encode_vector(&vector),
+        )
+        .await?
+        .expect(StatusCode::OK)?;
+    let doc_id = field(&body, "/doc_id")?.clone();
+
+    let body = client
+        .request(
+            Method::POST,
+            &format!("{}/query?top_k=1", collection),
+            OCTET_STREAM,
+            encode_vector(&vector),
+        )
+        .await?
+        .expect(StatusCode::OK)?;
+    check_eq("best match", field(&body, "/matches/0/doc_id")?, &doc_id)
+}
+
+async fn check_upsert(
+    client: &ConformanceClient,
+    collection: &str,
+    doc_id: &str,
+) -> Result<(), String> {
+    let body = client
+        .json(
+            Method::POST,
+            &format!("{}/upsert", collection),
+            json!({
+                "doc_id": doc_id,
+                "external_id": "conformance-a",
+                "vector": [1.0, 0.0, 0.0, 0.0],
+                "metadata": {"version": 2},
+            }),
+        )
+        .await?
+        .expect(StatusCode::OK)?;
+    check_eq("created", field(&body, "/created")?, &json!(false))?;
+
+    let body = client
+        .get(&format!("{}/docs/{}", collection, doc_id))
+        .await?
+        .expect(StatusCode::OK)?;
+    check_eq(
+        "metadata",
+        field(&body, "/document/metadata")?,
+        &json!({"version": 2}),
+    )
+}
+
+async fn check_delete_document(
+    client: &ConformanceClient,
+    collection: &str,
+    doc_id: &str,
+) -> Result<(), String> {
+    let path = format!("{}/docs/{}", collection, doc_id);
+    client.delete(&path).await?.expect(StatusCode::OK)?;
+    let body = client.get(&path).await?.expect(StatusCode::OK)?;
+    check_eq("deleted document", field(&body, "/document")?, &Value::Null)
+}
+
+async fn check_malformed_request(
+    client: &ConformanceClient,
+    collection: &str,
+) -> Result<(), String> {
+    client
+        .request(
+            Method::POST,
+            &format!("{}/insert", collection),
+            "application/json",
+            b"{\"vector\": [1.0,".to_vec(),
+        )
+        .await?
+        .expect(StatusCode::BAD_REQUEST)?;
+    Ok(())
+}
+
+async fn check_unknown_collection(client: &ConformanceClient) -> Result<(), String> {
+    client
+        .get(&format!("/api/v1/collections/{}", CollectionId::new()))
+        .await?
+        .expect(StatusCode::NOT_FOUND)?;
+    Ok(())
+}
+
+async fn check_delete_collection(
+    client: &ConformanceClient,
+    collection: &str,
+) -> Result<(), String> {
+    client
+        .delete(collection)
+        .await?
+        .expect(StatusCode::NO_CONTENT)?;
+    client
+        .get(collection)
+        .await?
+        .expect(StatusCode::NOT_FOUND)?;
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_conformance_report() {
+        let mut report = ConformanceReport {
+            endpoint: "http://localhost:8080".to_string(),
+            checks: vec![CheckResult {
+                name: "health",
+                error: None,
+            }],
+        };
+        assert!(report.passed());
+
+        report.checks.push(CheckResult {
+            name: "query",
+            error: Some("expected 2 matches, got 1".to_string()),
+        });
+        assert!(!report.passed());
+        let text = report.to_string();
+        assert!(text.contains("FAIL query: expected 2 matches, got 1"));
+        assert!(text.ends_with("2 checks, 1 passed, 1 failed"));
+    }
+}
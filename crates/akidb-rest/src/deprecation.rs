@@ -0,0 +1,157 @@
+//! Deprecation and sunset notices from services we call
+//!
+//! Servers announce a deprecated endpoint with a `Deprecation` header
+//! (RFC 9745) and its removal date with a `Sunset` header (RFC 8594), often
+//! alongside `Link: <...>; rel="deprecation"` pointing at migration docs.
+//! Outgoing HTTP calls (e.g. replication shipments) pass their responses to a
+//! `DeprecationTracker`, which
+//! - logs a warning the first time an endpoint is found deprecated,
+//! - counts every deprecated response in
+//!   `akidb_upstream_deprecated_responses_total`,
+//!
+//! so a breaking change on the other side shows up before it breaks us.
+
+use akidb_service::metrics::UPSTREAM_DEPRECATED_RESPONSES_TOTAL;
+use axum::http::HeaderMap;
+use std::collections::HashSet;
+use std::sync::Mutex;
+
+pub const DEPRECATION_HEADER: &str = "deprecation";
+pub const SUNSET_HEADER: &str = "sunset";
+
+/// Deprecation announced by a response.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct DeprecationNotice {
+    /// `Deprecation` header: a date (`@<unix seconds>` or an HTTP date), or
+    /// `true` from older servers.
+    pub deprecation: Option<String>,
+
+    /// `Sunset` header: HTTP date the endpoint goes away.
+    pub sunset: Option<String>,
+
+    /// Target of `Link` headers with `rel="deprecation"` or `rel="sunset"`.
+    pub link: Option<String>,
+}
+
+impl DeprecationNotice {
+    /// The notice in `headers`, if they carry `Deprecation` or `Sunset`.
+    pub fn from_headers(headers: &HeaderMap) -> Option<Self> {
+        let value = |name: &str| {
+            headers
+                .get(name)
+                .and_then(|value| value.to_str().ok())
+                .map(|value| value.trim().to_string())
+                .filter(|value| !value.is_empty())
+        };
+        let deprecation = value(DEPRECATION_HEADER);
+        let sunset = value(SUNSET_HEADER);
+        if deprecation.is_none() && sunset.is_none() {
+            return None;
+        }
+        let link = headers
+            .get_all("link")
+            .iter()
+            .filter_map(|value| value.to_str().ok())
+            .flat_map(|value| value.split(','))
+            .find_map(deprecation_link);
+        Some(Self {
+            deprecation,
+            sunset,
+            link,
+        })
+    }
+}
+
+/// Target of a `Link` header entry with a deprecation or sunset relation.
+fn deprecation_link(entry: &str) -> Option<String> {
+    let (target, params) = entry.trim().split_once('>')?;
+    let relevant = params.split(';').any(|param| {
+        let param = param.trim().replace(' ', "").to_ascii_lowercase();
+        matches!(
+            param.as_str(),
+            "rel=deprecation" | "rel=\"deprecation\"" | "rel=sunset" | "rel=\"sunset\""
+        )
+    });
+    relevant.then(|| target.trim_start_matches('<').to_string())
+}
+
+/// Surfaces deprecation notices of called endpoints, warning once per
+/// endpoint.
+#[derive(Debug, Default)]
+pub struct DeprecationTracker {
+    warned: Mutex<HashSet<String>>,
+}
+
+impl DeprecationTracker {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Check a response of `endpoint` (e.g. `POST /api/v1/...`, without IDs
+    /// so the metric stays bounded). Returns the notice if this is the first
+    /// deprecated response of the endpoint, after logging it.
+    pub fn observe(&self, endpoint: &str, headers: &HeaderMap) -> Option<DeprecationNotice> {
+        let notice = DeprecationNotice::from_headers(headers)?;
+        let sunset = if notice.sunset.is_some() { "yes" } else { "no" };
+        UPSTREAM_DEPRECATED_RESPONSES_TOTAL
+            .with_label_values(&[endpoint, sunset])
+            .inc();
+
+        if !self.warned.lock().unwrap().insert(endpoint.to_string()) {
+            return None;
+        }
+        tracing::warn!(
+            endpoint,
+            deprecation = notice.deprecation.as_deref().unwrap_or("-"),
+            sunset = notice.sunset.as_deref().unwrap_or("-"),
+            link = notice.link.as_deref().unwrap_or("-"),
+            "Called endpoint is deprecated{}",
+            notice
+                .sunset
+                .as_ref()
+                .map(|sunset| format!(" and will be removed after {}", sunset))
+                .unwrap_or_default()
+        );
+        Some(notice)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+
+    #[test]
+    fn test_warns_once_per_endpoint() {
+        let tracker = DeprecationTracker::new();
+        assert!(tracker.observe("POST /a", &HeaderMap::new()).is_none());
+
+        let mut headers = HeaderMap::new();
+        headers.insert(DEPRECATION_HEADER, HeaderValue::from_static("@1767225600"));
+        headers.insert(
+            SUNSET_HEADER,
+            HeaderValue::from_static("Wed, 30 Jun 2027 23:59:59 GMT"),
+        );
+        headers.insert(
+            "link",
+            HeaderValue::from_static(
+                "<https://example.com/v2>; rel=\"successor-version\", \
+                 <https://example.com/migrate>; rel=\"deprecation\"",
+            ),
+        );
+        let notice = tracker.observe("POST /a", &headers).unwrap();
+        assert_eq!(notice.deprecation.as_deref(), Some("@1767225600"));
+        assert_eq!(
+            notice.sunset.as_deref(),
+            Some("Wed, 30 Jun 2027 23:59:59 GMT")
+        );
+        assert_eq!(notice.link.as_deref(), Some("https://example.com/migrate"));
+
+        assert!(tracker.observe("POST /a", &headers).is_none());
+        assert!(tracker.observe("POST /b", &headers).is_some());
+        let counted = UPSTREAM_DEPRECATED_RESPONSES_TOTAL
+            .with_label_values(&["POST /a", "yes"])
+            .get();
+        assert_eq!(counted, 2.0);
+    }
+}
@@ -1,18 +1,36 @@
 //! Admin REST endpoints for operational management (Phase 7 Week 4)
 //!
-//! Provides 3 critical operational endpoints:
-//! 1. GET /admin/health - Comprehensive health check
+//! Provides 5 critical operational endpoints:
+//! 1. GET /admin/health - Comprehensive health check (including flagged access anomalies)
 //! 2. POST /admin/collections/{id}/dlq/retry - DLQ retry (clear)
 //! 3. POST /admin/circuit-breaker/reset - Circuit breaker reset
-
-use akidb_core::CollectionId;
-use akidb_service::CollectionService;
+//! 4. GET /admin/impersonations - Impersonation audit trail
+//! 5. POST /admin/tenants/batch - Batch tenant provisioning
+//! 6. GET /admin/tenants - Filtered tenant listing
+//! 7. DELETE /admin/tenants/{id} - Tenant deletion (soft by default)
+//! 8. POST /admin/tenants/{id}/undelete - Restore a soft-deleted tenant
+//! 9. POST /admin/tenants/{id}/api-keys/{key_id}/rotate - API key rotation
+//! 10. GET /admin/tenants/{id}/usage - Tenant usage history
+//! 11. POST /admin/tenants/{id}/suspend - Tenant suspension
+//! 12. POST /admin/tenants/{id}/resume - Resume a suspended tenant
+
+use akidb_core::{
+    ApiKeyId, CollectionId, CoreError, CreateTenantRequest, RotateApiKeyRequest,
+    RotateApiKeyResponse, TenantDeletion, TenantDescriptor, TenantId, TenantStatus,
+};
+use akidb_service::{
+    BatchCreateTenantsReport, CollectionService, DeleteTenantOptions, ImpersonationRecord,
+    ListOrder, SortDirection, SortField, TenantDeleteMode, TenantFilter, UsageGranularity,
+    UsageHistory, DEFAULT_PAGE_SIZE,
+};
 use axum::{
-    extract::{Path, State},
+    extract::{Path, Query, State},
     http::StatusCode,
     Json,
 };
-use serde::Serialize;
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value};
 use std::str::FromStr;
 use std::sync::Arc;
 
@@ -33,6 +51,7 @@ pub struct HealthComponents {
     pub database: ComponentHealth,
     pub storage: ComponentHealth,
     pub memory: ComponentHealth,
+    pub security: ComponentHealth,
 }
 
 #[derive(Debug, Serialize)]
@@ -147,6 +166,23 @@ pub async fn health_check(
         Err(e) => ComponentHealth::unhealthy(format!("Memory stats error: {}", e)),
     };
 
+    // Check for recently flagged access anomalies (never unhealthy: the
+    // service still works, but someone should look)
+    let recent_anomalies = service
+        .recent_anomalies(std::time::Duration::from_secs(3600))
+        .len();
+    let security_health = if recent_anomalies > 0 {
+        ComponentHealth::degraded(format!(
+            "{} access anomalies flagged in the last hour (see /api/v1/anomalies)",
+            recent_anomalies
+        ))
+    } else {
+        ComponentHealth::healthy()
+    }
+    .with_details(serde_json::json!({
+        "recent_anomalies": recent_anomalies,
+    }));
+
     // Overall status
     let overall_status = if database_health.status == "unhealthy"
         || storage_health.status == "unhealthy"
@@ -156,6 +192,7 @@ pub async fn health_check(
     } else if database_health.status == "degraded"
         || storage_health.status == "degraded"
         || memory_health.status == "degraded"
+        || security_health.status == "degraded"
     {
         "degraded"
     } else {
@@ -172,6 +209,7 @@ pub async fn health_check(
             database: database_health,
             storage: storage_health,
             memory: memory_health,
+            security: security_health,
         },
     };
 
@@ -258,6 +296,297 @@ pub async fn reset_circuit_breaker(
     }
 }
 
+// ============================================================================
+// Impersonation Audit
+// ============================================================================
+
+/// Query parameters for listing impersonation records
+#[derive(Debug, Deserialize)]
+pub struct ListImpersonationsParams {
+    /// Only return records with a greater ID (the last ID seen when polling)
+    pub after: Option<u64>,
+}
+
+#[derive(Debug, Serialize)]
+pub struct ListImpersonationsResponse {
+    pub impersonations: Vec<ImpersonationRecord>,
+}
+
+/// GET /admin/impersonations
+///
+/// Impersonation attempts (granted and refused), oldest first
+pub async fn list_impersonations(
+    Query(params): Query<ListImpersonationsParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListImpersonationsResponse> {
+    Json(ListImpersonationsResponse {
+        impersonations: service.list_impersonations(params.after),
+    })
+}
+
+// ============================================================================
+// Tenant Provisioning
+// ============================================================================
+
+#[derive(Debug, Deserialize)]
+pub struct BatchCreateTenantsRequest {
+    pub tenants: Vec<CreateTenantRequest>,
+}
+
+/// POST /admin/tenants/batch
+///
+/// Create up to 1,000 tenants; each succeeds or fails on its own. Tenants
+/// that already exist with the same name and quotas are reported as
+/// `existing`, so a batch can be sent again after a partial failure.
+#[tracing::instrument(skip(service, req), fields(tenants = req.tenants.len()))]
+pub async fn batch_create_tenants(
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<BatchCreateTenantsRequest>,
+) -> Result<Json<BatchCreateTenantsReport>, (StatusCode, String)> {
+    let report = service
+        .batch_create_tenants(req.tenants)
+        .await
+        .map_err(|e| match e {
+            CoreError::ValidationError(_) => (StatusCode::BAD_REQUEST, e.to_string()),
+            CoreError::InvalidState { .. } => (StatusCode::CONFLICT, e.to_string()),
+            _ => (StatusCode::INTERNAL_SERVER_ERROR, e.to_string()),
+        })?;
+
+    Ok(Json(report))
+}
+
+#[derive(Debug, Deserialize)]
+pub struct ListTenantsParams {
+    pub status: Option<TenantStatus>,
+    /// Comma-separated `key:value` pairs the tenant metadata must contain
+    pub metadata: Option<String>,
+    /// Only tenants created after this time (RFC 3339)
+    pub created_after: Option<DateTime<Utc>>,
+    #[serde(default)]
+    pub sort: SortField,
+    #[serde(default)]
+    pub direction: SortDirection,
+    pub limit: Option<usize>,
+    pub cursor: Option<String>,
+}
+
+#[derive(Debug, Serialize)]
+pub struct ListTenantsResponse {
+    pub tenants: Vec<TenantDescriptor>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub next_cursor: Option<String>,
+}
+
+/// Parse `tier:enterprise,seats:10` into metadata values. Values that are
+/// valid JSON (numbers, booleans) match as such, others as strings.
+fn parse_metadata_filter(param: &str) -> Result<Map<String, Value>, String> {
+    param
+        .split(',')
+        .map(|pair| {
+            let (key, value) = pair
+                .split_once(':')
+                .filter(|(key, _)| !key.is_empty())
+                .ok_or_else(|| format!("invalid metadata filter `{}`: use key:value", pair))?;
+            let value = serde_json::from_str(value).unwrap_or_else(|_| Value::from(value));
+            Ok((key.to_string(), value))
+        })
+        .collect()
+}
+
+/// GET /admin/tenants
+///
+/// Tenants matching every filter given, sorted by `sort` in `direction` and
+/// paged like collections (pass `next_cursor` back as `cursor`).
+pub async fn list_tenants(
+    Query(params): Query<ListTenantsParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ListTenantsResponse>, (StatusCode, String)> {
+    let filter = TenantFilter {
+        status: params.status,
+        metadata: match params.metadata.as_deref() {
+            Some(metadata) => {
+                parse_metadata_filter(metadata).map_err(|e| (StatusCode::BAD_REQUEST, e))?
+            }
+            None => Map::new(),
+        },
+        created_after: params.created_after,
+    };
+    let order = ListOrder {
+        sort: params.sort,
+        direction: params.direction,
+    };
+    let page = service
+        .list_tenants(
+            &filter,
+            order,
+            params.cursor.as_deref(),
+            params.limit.unwrap_or(DEFAULT_PAGE_SIZE),
+        )
+        .await
+        .map_err(|e| match e {
+            CoreError::ValidationError(_) => (StatusCode::BAD_REQUEST, e.to_string()),
+            CoreError::InvalidState { .. } => (StatusCode::CONFLICT, e.to_string()),
+            _ => (StatusCode::INTERNAL_SERVER_ERROR, e.to_string()),
+        })?;
+
+    Ok(Json(ListTenantsResponse {
+        tenants: page.items,
+        next_cursor: page.next_cursor,
+    }))
+}
+
+fn parse_tenant_id(tenant_id: &str) -> Result<TenantId, (StatusCode, String)> {
+    TenantId::from_str(tenant_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid tenant_id: {}", e)))
+}
+
+fn tenant_error(e: CoreError) -> (StatusCode, String) {
+    match e {
+        CoreError::NotFound { .. } => (StatusCode::NOT_FOUND, e.to_string()),
+        CoreError::ValidationError(_) => (StatusCode::BAD_REQUEST, e.to_string()),
+        CoreError::InvalidState { .. } => (StatusCode::CONFLICT, e.to_string()),
+        _ => (StatusCode::INTERNAL_SERVER_ERROR, e.to_string()),
+    }
+}
+
+#[derive(Debug, Serialize)]
+pub struct DeleteTenantResponse {
+    pub tenant_id: TenantId,
+    pub mode: TenantDeleteMode,
+    /// When the tenant will be purged (soft deletes)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub deletion: Option<TenantDeletion>,
+    /// The kept tenant's new `updated_at`, its deletion time (soft deletes)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub updated_at: Option<DateTime<Utc>>,
+}
+
+/// DELETE /admin/tenants/{id}
+///
+/// Soft by default: the tenant is decommissioned and kept for
+/// `retention_days` (default 30), during which it can be undeleted. With
+/// `mode=hard` it is removed at once.
+#[tracing::instrument(skip(service))]
+pub async fn delete_tenant(
+    Path(tenant_id): Path<String>,
+    Query(options): Query<DeleteTenantOptions>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<DeleteTenantResponse>, (StatusCode, String)> {
+    let tenant_id = parse_tenant_id(&tenant_id)?;
+    let deletion = service
+        .delete_tenant(tenant_id, &options)
+        .await
+        .map_err(tenant_error)?;
+
+    Ok(Json(DeleteTenantResponse {
+        tenant_id,
+        mode: options.mode,
+        updated_at: deletion.map(|d| d.deleted_at),
+        deletion,
+    }))
+}
+
+/// POST /admin/tenants/{id}/undelete
+///
+/// Restore a soft-deleted tenant before it is purged; it becomes active
+#[tracing::instrument(skip(service))]
+pub async fn undelete_tenant(
+    Path(tenant_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TenantDescriptor>, (StatusCode, String)> {
+    let tenant_id = parse_tenant_id(&tenant_id)?;
+    let tenant = service
+        .undelete_tenant(tenant_id)
+        .await
+        .map_err(tenant_error)?;
+
+    Ok(Json(tenant))
+}
+
+/// POST /admin/tenants/{id}/suspend
+///
+/// Soft-disable a tenant (e.g. a delinquent account): its data is kept but
+/// its API requests are rejected until it is resumed
+#[tracing::instrument(skip(service))]
+pub async fn suspend_tenant(
+    Path(tenant_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TenantDescriptor>, (StatusCode, String)> {
+    let tenant_id = parse_tenant_id(&tenant_id)?;
+    let tenant = service
+        .suspend_tenant(tenant_id)
+        .await
+        .map_err(tenant_error)?;
+
+    Ok(Json(tenant))
+}
+
+/// POST /admin/tenants/{id}/resume
+///
+/// Reactivate a suspended tenant
+#[tracing::instrument(skip(service))]
+pub async fn resume_tenant(
+    Path(tenant_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TenantDescriptor>, (StatusCode, String)> {
+    let tenant_id = parse_tenant_id(&tenant_id)?;
+    let tenant = service
+        .resume_tenant(tenant_id)
+        .await
+        .map_err(tenant_error)?;
+
+    Ok(Json(tenant))
+}
+
+/// POST /admin/tenants/{id}/api-keys/{key_id}/rotate
+///
+/// Replace an API key with a new one (returned once). The old key stays
+/// valid for `grace_period_secs`, or is revoked at once without one.
+#[tracing::instrument(skip(service, req))]
+pub async fn rotate_api_key(
+    Path((tenant_id, key_id)): Path<(String, String)>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<RotateApiKeyRequest>,
+) -> Result<(StatusCode, Json<RotateApiKeyResponse>), (StatusCode, String)> {
+    let tenant_id = parse_tenant_id(&tenant_id)?;
+    let key_id = ApiKeyId::from_str(&key_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid key_id: {}", e)))?;
+    let rotated = service
+        .rotate_api_key(tenant_id, key_id, &req)
+        .await
+        .map_err(tenant_error)?;
+
+    Ok((StatusCode::CREATED, Json(rotated)))
+}
+
+#[derive(Debug, Deserialize)]
+pub struct UsageHistoryParams {
+    pub from: DateTime<Utc>,
+    pub to: DateTime<Utc>,
+    /// hour (default) or day
+    #[serde(default)]
+    pub granularity: UsageGranularity,
+}
+
+/// GET /admin/tenants/{id}/usage
+///
+/// Time series of the tenant's searches, ingestion and storage between
+/// `from` and `to`, by hour or day
+#[tracing::instrument(skip(service))]
+pub async fn get_tenant_usage_history(
+    Path(tenant_id): Path<String>,
+    Query(params): Query<UsageHistoryParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<UsageHistory>, (StatusCode, String)> {
+    let tenant_id = parse_tenant_id(&tenant_id)?;
+    let history = service
+        .usage_history(tenant_id, params.from, params.to, params.granularity)
+        .await
+        .map_err(tenant_error)?;
+
+    Ok(Json(history))
+}
+
 // ============================================================================
 // Tests
 // ============================================================================
@@ -325,4 +654,15 @@ mod tests {
         assert_eq!(response.previous_state, "Open");
         assert_eq!(response.new_state, "Closed");
     }
+
+    #[test]
+    fn test_parse_metadata_filter() {
+        let metadata = parse_metadata_filter("tier:enterprise,seats:10,region:eu:west").unwrap();
+        assert_eq!(metadata["tier"], "enterprise");
+        assert_eq!(metadata["seats"], 10);
+        assert_eq!(metadata["region"], "eu:west");
+
+        assert!(parse_metadata_filter("tier").is_err());
+        assert!(parse_metadata_filter(":enterprise").is_err());
+    }
 }
@@ -0,0 +1,120 @@
+//! Collection alias API handlers
+//!
+//! An alias names a collection and can be switched to another one
+//! atomically, e.g. to `docs_v2` once it is built and validated. Collection
+//! endpoints accept an alias wherever they take a collection ID (see
+//! `crate::aliases`):
+//! - GET /aliases - List aliases
+//! - GET /aliases/{alias} - Get the collection an alias points at
+//! - PUT /aliases/{alias} - Create an alias or switch it to another collection
+//! - DELETE /aliases/{alias} - Delete an alias (the collection is kept)
+
+use akidb_core::CollectionId;
+use akidb_service::CollectionService;
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    Json,
+};
+use serde::{Deserialize, Serialize};
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::error_response;
+
+/// Alias create or switch request
+#[derive(Deserialize)]
+pub struct SetAliasRequest {
+    pub collection_id: String,
+}
+
+#[derive(Serialize)]
+pub struct AliasResponse {
+    pub alias: String,
+    pub collection_id: String,
+    /// Collection the alias pointed at before a switch
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub previous_collection_id: Option<String>,
+}
+
+/// List aliases response
+#[derive(Serialize)]
+pub struct ListAliasesResponse {
+    pub aliases: Vec<AliasResponse>,
+}
+
+/// List aliases, by name
+#[tracing::instrument(skip(service))]
+pub async fn list_aliases(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListAliasesResponse> {
+    let aliases = service
+        .list_aliases()
+        .await
+        .into_iter()
+        .map(|(alias, collection_id)| AliasResponse {
+            alias,
+            collection_id: collection_id.to_string(),
+            previous_collection_id: None,
+        })
+        .collect();
+    Json(ListAliasesResponse { aliases })
+}
+
+/// Get the collection an alias points at
+#[tracing::instrument(skip(service), fields(alias = %alias))]
+pub async fn get_alias(
+    Path(alias): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<AliasResponse>, (StatusCode, String)> {
+    let collection_id = service
+        .resolve_alias(&alias)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(AliasResponse {
+        alias,
+        collection_id: collection_id.to_string(),
+        previous_collection_id: None,
+    }))
+}
+
+/// Create an alias or atomically switch it to another collection
+///
+/// Requests addressing the alias are served by the new collection from the
+/// moment this returns; none see a missing collection in between.
+#[tracing::instrument(skip(service, req), fields(alias = %alias, collection_id = %req.collection_id))]
+pub async fn set_alias(
+    Path(alias): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<SetAliasRequest>,
+) -> Result<Json<AliasResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&req.collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let previous = service
+        .set_alias(&alias, collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(AliasResponse {
+        alias,
+        collection_id: collection_id.to_string(),
+        previous_collection_id: previous.map(|id| id.to_string()),
+    }))
+}
+
+/// Delete an alias; the collection it pointed at is kept
+#[tracing::instrument(skip(service), fields(alias = %alias))]
+pub async fn delete_alias(
+    Path(alias): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    service.delete_alias(&alias).await.map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
@@ -0,0 +1,182 @@
+//! IP allowlist API handlers
+//!
+//! Source networks allowed for the tenant and for individual API keys:
+//! - GET /ip-allowlists - List all allowlists
+//! - GET /ip-allowlists/check - Evaluate the caller's source address
+//! - GET /tenant/ip-allowlist - Get the tenant's allowlist
+//! - PUT /tenant/ip-allowlist - Replace the tenant's allowlist
+//! - DELETE /tenant/ip-allowlist - Remove the tenant's allowlist (allow all)
+//! - GET /api-keys/{key_id}/ip-allowlist - Get an API key's allowlist
+//! - PUT /api-keys/{key_id}/ip-allowlist - Replace an API key's allowlist
+//! - DELETE /api-keys/{key_id}/ip-allowlist - Remove an API key's allowlist
+//!
+//! The tenant's allowlist, and the allowlist of the API key a request
+//! carries, are enforced on every request (see `ip_filter`).
+
+use akidb_core::ApiKeyId;
+use akidb_service::{
+    AllowlistEvaluation, AllowlistScope, AllowlistSpec, CollectionService, IpAllowlist,
+};
+use axum::{
+    extract::{ConnectInfo, Path, Query, State},
+    http::{HeaderMap, StatusCode},
+    Json,
+};
+use serde::{Deserialize, Serialize};
+use std::net::SocketAddr;
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::error_response;
+use crate::ip_filter::request_api_key;
+
+/// List allowlists response
+#[derive(Serialize)]
+pub struct ListAllowlistsResponse {
+    pub allowlists: Vec<IpAllowlist>,
+}
+
+/// Query parameters for checking the caller's address
+#[derive(Deserialize)]
+pub struct CheckAllowlistParams {
+    /// Evaluate this API key's allowlist instead of the one of the key in
+    /// the `X-API-Key` header
+    pub api_key_id: Option<String>,
+}
+
+fn parse_key_id(key_id: &str) -> Result<ApiKeyId, (StatusCode, String)> {
+    ApiKeyId::from_str(key_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid key_id: {}", e)))
+}
+
+/// List the tenant's and API keys' allowlists
+#[tracing::instrument(skip(service))]
+pub async fn list_ip_allowlists(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListAllowlistsResponse> {
+    Json(ListAllowlistsResponse {
+        allowlists: service.list_ip_allowlists().await,
+    })
+}
+
+/// Evaluate the caller's source address against the allowlists
+///
+/// Shows the address as the server sees it and which network (if any) it
+/// matched, to verify an allowlist before relying on it.
+#[tracing::instrument(skip(service, headers, params))]
+pub async fn check_ip_allowlist(
+    ConnectInfo(peer): ConnectInfo<SocketAddr>,
+    headers: HeaderMap,
+    Query(params): Query<CheckAllowlistParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<AllowlistEvaluation>, (StatusCode, String)> {
+    let api_key = match params.api_key_id.as_deref() {
+        Some(key_id) => Some(parse_key_id(key_id)?),
+        None => request_api_key(&service, &headers).await?,
+    };
+
+    Ok(Json(service.evaluate_source_ip(peer.ip(), api_key).await))
+}
+
+/// Get the tenant's allowlist
+#[tracing::instrument(skip(service))]
+pub async fn get_tenant_ip_allowlist(
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<IpAllowlist>, (StatusCode, String)> {
+    let allowlist = service
+        .get_ip_allowlist(AllowlistScope::Tenant)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(allowlist))
+}
+
+/// Replace the tenant's allowlist
+///
+/// Rejected with 409 if the new allowlist would not allow the caller's own
+/// address, so an administrator cannot lock themselves out.
+#[tracing::instrument(skip(service, spec))]
+pub async fn update_tenant_ip_allowlist(
+    ConnectInfo(peer): ConnectInfo<SocketAddr>,
+    State(service): State<Arc<CollectionService>>,
+    Json(spec): Json<AllowlistSpec>,
+) -> Result<Json<IpAllowlist>, (StatusCode, String)> {
+    if !spec.networks.iter().any(|n| n.contains(peer.ip())) {
+        return Err((
+            StatusCode::CONFLICT,
+            format!(
+                "Allowlist does not include the caller's address {}; add it to avoid a lockout",
+                peer.ip()
+            ),
+        ));
+    }
+
+    let allowlist = service
+        .set_ip_allowlist(AllowlistScope::Tenant, spec)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(allowlist))
+}
+
+/// Remove the tenant's allowlist, allowing all addresses
+#[tracing::instrument(skip(service))]
+pub async fn delete_tenant_ip_allowlist(
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    service
+        .delete_ip_allowlist(AllowlistScope::Tenant)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// Get an API key's allowlist
+#[tracing::instrument(skip(service), fields(key_id = %key_id))]
+pub async fn get_key_ip_allowlist(
+    Path(key_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<IpAllowlist>, (StatusCode, String)> {
+    let key_id = parse_key_id(&key_id)?;
+
+    let allowlist = service
+        .get_ip_allowlist(AllowlistScope::ApiKey { key_id })
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(allowlist))
+}
+
+/// Replace an API key's allowlist
+#[tracing::instrument(skip(service, spec), fields(key_id = %key_id))]
+pub async fn update_key_ip_allowlist(
+    Path(key_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(spec): Json<AllowlistSpec>,
+) -> Result<Json<IpAllowlist>, (StatusCode, String)> {
+    let key_id = parse_key_id(&key_id)?;
+
+    let allowlist = service
+        .set_ip_allowlist(AllowlistScope::ApiKey { key_id }, spec)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(allowlist))
+}
+
+/// Remove an API key's allowlist
+#[tracing::instrument(skip(service), fields(key_id = %key_id))]
+pub async fn delete_key_ip_allowlist(
+    Path(key_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let key_id = parse_key_id(&key_id)?;
+
+    service
+        .delete_ip_allowlist(AllowlistScope::ApiKey { key_id })
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
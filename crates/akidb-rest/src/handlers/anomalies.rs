@@ -0,0 +1,56 @@
+//! Access anomaly API handlers
+//!
+//! Access patterns flagged as possible data exfiltration (mass scans of a
+//! collection, exports of every document):
+//! - GET /anomalies - List flagged events (optionally only those after an event ID)
+//! - GET /anomalies/events - Stream events as they are flagged (server-sent events)
+//!
+//! The number of recent events is also reported by `GET /admin/health`.
+
+use akidb_service::{AnomalyEvent, CollectionService};
+use axum::{
+    extract::{Query, State},
+    response::sse::{Event, Sse},
+    Json,
+};
+use futures::stream::Stream;
+use serde::{Deserialize, Serialize};
+use std::convert::Infallible;
+use std::sync::Arc;
+
+use super::sse::alert_events;
+
+/// Query parameters for listing anomalies
+#[derive(Deserialize)]
+pub struct ListAnomaliesParams {
+    /// Only return events with a greater ID (the last ID seen when polling)
+    pub after: Option<u64>,
+}
+
+/// List anomalies response
+#[derive(Serialize)]
+pub struct ListAnomaliesResponse {
+    pub anomalies: Vec<AnomalyEvent>,
+}
+
+/// List flagged access anomalies, oldest first
+#[tracing::instrument(skip(service, params))]
+pub async fn list_anomalies(
+    Query(params): Query<ListAnomaliesParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListAnomaliesResponse> {
+    Json(ListAnomaliesResponse {
+        anomalies: service.list_anomalies(params.after),
+    })
+}
+
+/// Stream access anomalies as they are flagged
+///
+/// Sends one `anomaly` event per flagged pattern; fetch earlier events with
+/// `GET /anomalies`.
+#[tracing::instrument(skip(service))]
+pub async fn anomaly_events(
+    State(service): State<Arc<CollectionService>>,
+) -> Sse<impl Stream<Item = Result<Event, Infallible>>> {
+    alert_events("anomaly", service.subscribe_anomalies())
+}
@@ -0,0 +1,204 @@
+//! Backfill job API handlers
+//!
+//! Lets a worker pool compute a new vector for existing records:
+//! - POST /backfill-jobs - Create a job (source -> target collection)
+//! - GET /backfill-jobs - List jobs
+//! - GET /backfill-jobs/{id} - Get job progress
+//! - GET /backfill-jobs/{id}/events - Stream job progress (server-sent events)
+//! - POST /backfill-jobs/{id}/batch - Take the next batch of records missing the vector
+//! - POST /backfill-jobs/{id}/patch - Write computed vectors
+//! - POST /backfill-jobs/{id}/resume - Re-stream records that were never patched
+//! - POST /backfill-jobs/{id}/cancel - Cancel a job
+
+use akidb_core::JobId;
+use akidb_service::{
+    BackfillBatch, BackfillJob, BackfillPatch, BackfillProgress, CollectionService,
+};
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    response::sse::{Event, Sse},
+    Json,
+};
+use futures::stream::Stream;
+use serde::{Deserialize, Serialize};
+use std::convert::Infallible;
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::sse::progress_events;
+use super::{error_response, parse_collection_id};
+
+/// Create backfill job request
+#[derive(Deserialize)]
+pub struct CreateBackfillJobRequest {
+    /// Collection whose records need the new vector
+    pub source: String,
+    /// Collection receiving the new vectors (same document IDs)
+    pub target: String,
+}
+
+/// List backfill jobs response
+#[derive(Serialize)]
+pub struct ListBackfillJobsResponse {
+    pub jobs: Vec<BackfillJob>,
+}
+
+/// Next batch request
+#[derive(Deserialize)]
+pub struct BackfillBatchRequest {
+    /// Maximum records to return (default: 100)
+    #[serde(default = "default_batch_limit")]
+    pub limit: usize,
+}
+
+fn default_batch_limit() -> usize {
+    100
+}
+
+/// Patch request
+#[derive(Deserialize)]
+pub struct BackfillPatchRequest {
+    pub patches: Vec<BackfillPatch>,
+}
+
+fn parse_job_id(job_id: &str) -> Result<JobId, (StatusCode, String)> {
+    JobId::from_str(job_id).map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid job_id: {}", e)))
+}
+
+/// Create a backfill job
+#[tracing::instrument(skip(service, req))]
+pub async fn create_backfill_job(
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<CreateBackfillJobRequest>,
+) -> Result<(StatusCode, Json<BackfillJob>), (StatusCode, String)> {
+    let source = parse_collection_id(&req.source)?;
+    let target = parse_collection_id(&req.target)?;
+
+    let job = service
+        .create_backfill_job(source, target)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(job)))
+}
+
+/// List backfill jobs
+#[tracing::instrument(skip(service))]
+pub async fn list_backfill_jobs(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListBackfillJobsResponse> {
+    Json(ListBackfillJobsResponse {
+        jobs: service.list_backfill_jobs().await,
+    })
+}
+
+/// Get backfill progress
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn get_backfill_progress(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<BackfillProgress>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let progress = service
+        .backfill_progress(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(progress))
+}
+
+/// Stream backfill progress as server-sent events
+///
+/// Sends the current progress, then an update after every patch, resume or
+/// cancel, until the job completes.
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn backfill_progress_events(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Sse<impl Stream<Item = Result<Event, Infallible>>>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    // Subscribe first so no update between the snapshot and the stream is lost
+    let updates = service
+        .subscribe_backfill_progress(job_id)
+        .await
+        .map_err(error_response)?;
+    let current = service
+        .backfill_progress(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(progress_events(current, updates))
+}
+
+/// Take the next batch of records missing the new vector
+///
+/// Concurrent workers receive disjoint batches.
+#[tracing::instrument(skip(service, req), fields(job_id = %job_id))]
+pub async fn next_backfill_batch(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<BackfillBatchRequest>,
+) -> Result<Json<BackfillBatch>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let batch = service
+        .backfill_next_batch(job_id, req.limit)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(batch))
+}
+
+/// Write computed vectors into the target collection
+///
+/// Retrying a patch is safe; records already backfilled are ignored.
+#[tracing::instrument(skip(service, req), fields(job_id = %job_id))]
+pub async fn patch_backfill(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<BackfillPatchRequest>,
+) -> Result<Json<BackfillProgress>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let progress = service
+        .backfill_patch(job_id, req.patches)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(progress))
+}
+
+/// Resume a job, re-streaming records that were handed out but never patched
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn resume_backfill(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<BackfillJob>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let job = service
+        .resume_backfill(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(job))
+}
+
+/// Cancel a job (vectors already written are kept)
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn cancel_backfill(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<BackfillJob>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let job = service
+        .cancel_backfill(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(job))
+}
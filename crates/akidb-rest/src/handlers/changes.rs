@@ -0,0 +1,63 @@
+//! Change stream API handlers
+//!
+//! - GET /collections/{id}/changes - Stream the collection's writes (server-sent events)
+//!
+//! Each `change` event is a JSON `ChangeEvent` whose SSE `id` is its cursor.
+//! Resume after an event by passing its cursor as `since`, or as the
+//! `Last-Event-ID` header, which `EventSource` clients send by themselves
+//! when they reconnect.
+
+use akidb_core::CoreError;
+use akidb_service::CollectionService;
+use axum::{
+    extract::{Path, Query, State},
+    http::{HeaderMap, StatusCode},
+    response::sse::{Event, Sse},
+};
+use futures::stream::Stream;
+use serde::Deserialize;
+use std::convert::Infallible;
+use std::sync::Arc;
+
+use super::sse::change_events;
+use super::{error_response, parse_collection_id};
+
+/// Header of the last event an SSE client received before reconnecting
+pub const LAST_EVENT_ID_HEADER: &str = "last-event-id";
+
+#[derive(Deserialize)]
+pub struct ChangeStreamParams {
+    /// Cursor of the last event processed
+    #[serde(default)]
+    since: Option<String>,
+}
+
+/// Stream a collection's writes as they happen
+///
+/// Sends the logged writes after the `since` cursor (or `Last-Event-ID`,
+/// which takes precedence), then one `change` event per write. Cursors too
+/// old to resume from are rejected with 410 Gone: resync the collection
+/// (e.g. with an export) and subscribe without a cursor.
+#[tracing::instrument(skip(service, params, headers), fields(collection_id = %collection_id))]
+pub async fn change_stream(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Query(params): Query<ChangeStreamParams>,
+    headers: HeaderMap,
+) -> Result<Sse<impl Stream<Item = Result<Event, Infallible>>>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+    let since = headers
+        .get(LAST_EVENT_ID_HEADER)
+        .and_then(|v| v.to_str().ok())
+        .map(str::to_string)
+        .or(params.since);
+
+    let subscription = service
+        .subscribe_changes(collection_id, since.as_deref())
+        .await
+        .map_err(|e| match e {
+            CoreError::InvalidState { .. } => (StatusCode::GONE, e.to_string()),
+            _ => error_response(e),
+        })?;
+    Ok(change_events(subscription))
+}
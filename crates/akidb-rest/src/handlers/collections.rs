@@ -1,38 +1,211 @@
-use akidb_core::{CollectionId, DocumentId, VectorDocument};
-use akidb_service::CollectionService;
+use akidb_core::{CollectionId, CoreError, CoreResult, DistanceMetric, DocumentId, VectorDocument};
+use akidb_service::{
+    check_batch_size, check_delete_batch_size, validate_column_fields, BatchInsertReport,
+    BatchQuery, BatchSearchOptions, CollectionService, ContentIdSpec, DeleteFailure, DeleteReport,
+    ExportRecord, GroupBy, HybridQuery, ListOrder, MetadataFilter, MetadataPatch, MetadataSort,
+    ParentSearchOptions, Partition, PostProcessingPipeline, QueryComposition, RangeQuery,
+    RecommendRequest, ResultColumns, ResultLayout, ScoreModifier, ScoreNormalization, SparseVector,
+    VectorCodec, DEFAULT_BATCH_SEARCH_CONCURRENCY, DEFAULT_PAGE_SIZE,
+};
 use axum::{
-    extract::{Path, State},
-    http::StatusCode,
+    body::{Bytes, StreamBody},
+    extract::{Path, Query, State},
+    http::{header, StatusCode},
+    response::IntoResponse,
     Json,
 };
 use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap};
 use std::str::FromStr;
 use std::sync::Arc;
 
-#[derive(Deserialize)]
+use super::error_response;
+use crate::vector_encoding::{deserialize_vector, FromBinaryVectors, VectorBody};
+
+#[derive(Default, Deserialize)]
 pub struct QueryRequest {
+    /// Query vector (required unless `compose` or `sparse_vector` is set);
+    /// an array of numbers or base64 of raw float32 values
+    #[serde(default, deserialize_with = "deserialize_vector")]
     query_vector: Vec<f32>,
-    top_k: usize,
+    /// Search the collection's sparse index instead of the dense index
+    #[serde(default)]
+    sparse_vector: Option<SparseVector>,
+    /// Named vector space to search with `query_vector` instead of the
+    /// primary vectors
+    #[serde(default)]
+    using: Option<String>,
+    /// Build the query vector from stored documents instead
+    #[serde(default)]
+    compose: Option<QueryComposition>,
+    /// Fuse the dense results with BM25 keyword results
+    #[serde(default)]
+    hybrid: Option<HybridQuery>,
+    /// Results to return (default: the collection's, then the tenant's default top_k)
+    #[serde(default)]
+    top_k: Option<usize>,
+    /// Min-max normalize scores into [0, 1] (1.0 = best match)
+    #[serde(default)]
+    normalize_scores: bool,
+    /// Normalization method (replaces `normalize_scores`)
+    #[serde(default)]
+    score_normalization: Option<ScoreNormalization>,
+    /// Drop matches below this score (above it for raw L2 distances)
+    #[serde(default)]
+    min_score: Option<f32>,
+    /// Also report each match's raw score in these metrics
+    #[serde(default)]
+    score_metrics: Vec<DistanceMetric>,
+    /// Return each match's stored vector
+    #[serde(default)]
+    include_vectors: bool,
+    /// Multiply scores by field boosts and decay functions (after `min_score`)
+    #[serde(default)]
+    score_modifiers: Vec<ScoreModifier>,
+    /// Keep only the best match per value of this metadata field
+    #[serde(default)]
+    dedupe_by: Option<String>,
+    /// Return `top_k` groups of matches sharing a metadata field value
+    /// instead of individual matches
+    #[serde(default)]
+    group_by: Option<GroupBy>,
+    /// Return every match within a radius instead of `top_k` matches
+    #[serde(default)]
+    range: Option<RangeQuery>,
+    /// Reorder the best matches by a metadata field (after `min_score`)
+    #[serde(default)]
+    sort_by: Option<MetadataSort>,
+    /// Matches retrieved before re-ranking by `score_modifiers`, `dedupe_by`
+    /// or `sort_by` (default: 4 * top_k)
+    #[serde(default)]
+    sort_candidates: Option<usize>,
+    /// Return matches as objects (`rows`) or parallel arrays (`columns`)
+    #[serde(default)]
+    layout: ResultLayout,
+    /// Metadata fields returned as columns (with `layout: columns` only)
+    #[serde(default)]
+    fields: Vec<String>,
+}
+
+impl QueryRequest {
+    /// Builds the per-call post-processing pipeline, if any option is set.
+    fn pipeline(
+        &self,
+        top_k: usize,
+    ) -> Result<Option<PostProcessingPipeline>, (StatusCode, String)> {
+        let mut pipeline = PostProcessingPipeline::new();
+        match (self.normalize_scores, self.score_normalization) {
+            (true, Some(_)) => {
+                return Err((
+                    StatusCode::BAD_REQUEST,
+                    "set either normalize_scores or score_normalization, not both".to_string(),
+                ));
+            }
+            (true, None) => pipeline = pipeline.with_normalization(),
+            (false, Some(method)) => {
+                pipeline = pipeline
+                    .with_score_normalization(method)
+                    .map_err(error_response)?;
+            }
+            (false, None) => {}
+        }
+        if let Some(min_score) = self.min_score {
+            pipeline = pipeline.with_threshold(min_score);
+        }
+        if !self.score_modifiers.is_empty() {
+            pipeline = pipeline
+                .with_score_modifiers(self.score_modifiers.clone(), top_k)
+                .map_err(error_response)?;
+        }
+        if let Some(field) = &self.dedupe_by {
+            // A later sort picks from every distinct match and cuts to top_k
+            let keep = if self.sort_by.is_some() {
+                usize::MAX
+            } else {
+                top_k
+            };
+            pipeline = pipeline.with_dedupe(field.clone(), keep);
+        }
+        if let Some(sort) = &self.sort_by {
+            pipeline = pipeline.with_metadata_sort(sort.clone(), top_k);
+        }
+        Ok((!pipeline.is_empty()).then_some(pipeline))
+    }
+}
+
+/// Query parameters of a search with a raw query vector body.
+#[derive(Deserialize)]
+pub struct BinaryQueryParams {
+    #[serde(default)]
+    top_k: Option<usize>,
+    #[serde(default)]
+    min_score: Option<f32>,
+    #[serde(default)]
+    include_vectors: bool,
+    #[serde(default)]
+    using: Option<String>,
+}
+
+impl FromBinaryVectors for QueryRequest {
+    type Params = BinaryQueryParams;
+
+    fn from_binary(query_vector: Vec<f32>, params: BinaryQueryParams) -> Result<Self, String> {
+        Ok(Self {
+            query_vector,
+            top_k: params.top_k,
+            min_score: params.min_score,
+            include_vectors: params.include_vectors,
+            using: params.using,
+            ..Self::default()
+        })
+    }
 }
 
 #[derive(Serialize)]
 pub struct QueryResponse {
+    /// Matches, best first (empty with `group_by` or `layout: columns`)
     matches: Vec<MatchResult>,
+    /// Groups of matches, best first (with `group_by` only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    groups: Option<Vec<MatchGroup>>,
+    /// Matches as parallel arrays, best first (with `layout: columns` only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    columns: Option<ResultColumns>,
+    /// Whether the range `limit` cut off further matches (with `range` only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    truncated: Option<bool>,
     latency_ms: f64,
 }
 
+#[derive(Serialize)]
+pub struct MatchGroup {
+    /// Value of the `group_by` field shared by the group's matches
+    key: String,
+    /// Score of the group's best match
+    score: f32,
+    matches: Vec<MatchResult>,
+}
+
 #[derive(Serialize)]
 pub struct MatchResult {
     doc_id: String,
     external_id: Option<String>,
     distance: f32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    metadata: Option<serde_json::Value>,
+    /// Raw scores in the requested `score_metrics`, keyed by metric
+    #[serde(skip_serializing_if = "Option::is_none")]
+    scores: Option<BTreeMap<&'static str, f32>>,
+    /// Stored vector (with `include_vectors` only)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    vector: Option<Vec<f32>>,
 }
 
-#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, top_k = req.top_k))]
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, top_k = ?req.top_k))]
 pub async fn query_vectors(
     Path(collection_id): Path<String>,
     State(service): State<Arc<CollectionService>>,
-    Json(req): Json<QueryRequest>,
+    VectorBody(mut req): VectorBody<QueryRequest>,
 ) -> Result<Json<QueryResponse>, (StatusCode, String)> {
     let start = std::time::Instant::now();
 
@@ -43,6 +216,311 @@ pub async fn query_vectors(
         )
     })?;
 
+    if req.sparse_vector.is_some()
+        && (!req.query_vector.is_empty()
+            || req.compose.is_some()
+            || req.hybrid.is_some()
+            || !req.score_metrics.is_empty())
+    {
+        return Err((
+            StatusCode::BAD_REQUEST,
+            "sparse_vector cannot be combined with query_vector, compose, hybrid or \
+             score_metrics (use hybrid.sparse_vector to fuse with a dense query)"
+                .to_string(),
+        ));
+    }
+    if req.using.is_some()
+        && (req.sparse_vector.is_some()
+            || req.compose.is_some()
+            || req.hybrid.is_some()
+            || !req.score_metrics.is_empty())
+    {
+        return Err((
+            StatusCode::BAD_REQUEST,
+            "using cannot be combined with sparse_vector, compose, hybrid or score_metrics"
+                .to_string(),
+        ));
+    }
+
+    if let Some(group_by) = &req.group_by {
+        group_by.validate().map_err(error_response)?;
+        if req.dedupe_by.is_some() || req.sort_by.is_some() || !req.score_modifiers.is_empty() {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "group_by cannot be combined with dedupe_by, sort_by or score_modifiers"
+                    .to_string(),
+            ));
+        }
+    }
+    if req.layout == ResultLayout::Columns {
+        if req.group_by.is_some() || !req.score_metrics.is_empty() {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "layout columns cannot be combined with group_by or score_metrics".to_string(),
+            ));
+        }
+        validate_column_fields(&req.fields).map_err(error_response)?;
+    } else if !req.fields.is_empty() {
+        return Err((
+            StatusCode::BAD_REQUEST,
+            "fields requires layout columns".to_string(),
+        ));
+    }
+    if req.range.is_some()
+        && (req.sparse_vector.is_some()
+            || req.using.is_some()
+            || req.hybrid.is_some()
+            || req.group_by.is_some()
+            || req.normalize_scores
+            || req.score_normalization.is_some()
+            || req.min_score.is_some()
+            || req.dedupe_by.is_some()
+            || req.sort_by.is_some()
+            || !req.score_modifiers.is_empty())
+    {
+        return Err((
+            StatusCode::BAD_REQUEST,
+            "range cannot be combined with sparse_vector, using, hybrid, group_by or score \
+             post-processing options"
+                .to_string(),
+        ));
+    }
+
+    let query_vector = match &req.compose {
+        Some(_) if !req.query_vector.is_empty() => {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "set either query_vector or compose, not both".to_string(),
+            ));
+        }
+        Some(composition) => service
+            .compose_query_vector(collection_id, composition)
+            .await
+            .map_err(error_response)?,
+        None if req.sparse_vector.is_some() => Vec::new(),
+        None if req.query_vector.is_empty() => {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "query_vector cannot be empty".to_string(),
+            ));
+        }
+        None => req.query_vector.clone(),
+    };
+    let score_metrics = req.score_metrics.clone();
+    let scored_query = (!score_metrics.is_empty()).then(|| query_vector.clone());
+
+    // Unset parameters fall back to the collection's defaults
+    let defaults = service
+        .search_defaults(collection_id)
+        .await
+        .map_err(error_response)?;
+    if req.hybrid.is_none() && req.range.is_none() {
+        req.min_score = req.min_score.or(defaults.min_score);
+    }
+    let top_k = service
+        .resolve_top_k(req.top_k.or(defaults.top_k))
+        .await
+        .map_err(error_response)?;
+    // Re-ranking reorders a wider candidate set, cut back to top_k
+    let reranked =
+        req.sort_by.is_some() || req.dedupe_by.is_some() || !req.score_modifiers.is_empty();
+    let search_k = match &req.group_by {
+        Some(group_by) => group_by.candidates(top_k),
+        None if reranked => req.sort_candidates.unwrap_or(top_k * 4).max(top_k),
+        None => top_k,
+    };
+
+    let mut truncated = None;
+    let mut results = match (&req.sparse_vector, &req.hybrid, req.pipeline(top_k)?) {
+        (None, None, None) if req.range.is_some() => {
+            let range = req.range.as_ref().expect("checked above");
+            service
+                .range_search(collection_id, query_vector, range)
+                .await
+                .map(|found| {
+                    truncated = Some(found.truncated);
+                    found.results
+                })
+        }
+        (Some(sparse_vector), _, pipeline) => service
+            .sparse_search(collection_id, sparse_vector, search_k)
+            .await
+            .map(|results| match pipeline {
+                // Sparse scores are dot products
+                Some(pipeline) => pipeline.apply(results, DistanceMetric::Dot),
+                None => results,
+            }),
+        (None, None, pipeline) if req.using.is_some() => {
+            let name = req.using.as_deref().unwrap_or_default();
+            service
+                .named_vector_search(
+                    collection_id,
+                    name,
+                    &query_vector,
+                    search_k,
+                    pipeline.as_ref(),
+                )
+                .await
+        }
+        (None, Some(_), Some(_)) => {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "hybrid search does not support score post-processing options".to_string(),
+            ));
+        }
+        (None, Some(hybrid), None) => {
+            service
+                .hybrid_search(collection_id, query_vector, hybrid, search_k)
+                .await
+        }
+        (None, None, Some(pipeline)) => {
+            service
+                .query_with_pipeline(collection_id, query_vector, search_k, &pipeline)
+                .await
+        }
+        (None, None, None) => service.query(collection_id, query_vector, search_k).await,
+    }
+    .map_err(error_response)?;
+
+    if req.include_vectors {
+        service
+            .attach_vectors(collection_id, &mut results)
+            .await
+            .map_err(error_response)?;
+    }
+    if req.layout == ResultLayout::Columns {
+        return Ok(Json(QueryResponse {
+            matches: Vec::new(),
+            groups: None,
+            columns: Some(ResultColumns::from_results(
+                results,
+                &req.fields,
+                req.include_vectors,
+            )),
+            truncated,
+            latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+        }));
+    }
+    let grouped = req
+        .group_by
+        .as_ref()
+        .map(|group_by| group_by.group(results.clone(), top_k));
+    let mut scores = match &scored_query {
+        Some(query_vector) => service
+            .score_in_metrics(collection_id, query_vector, &results, &score_metrics)
+            .await
+            .map_err(error_response)?,
+        None => Vec::new(),
+    }
+    .into_iter();
+
+    let mut matches: Vec<MatchResult> = results
+        .into_iter()
+        .map(|r| MatchResult {
+            doc_id: r.doc_id.to_string(),
+            external_id: r.external_id,
+            distance: r.score,
+            metadata: r.metadata,
+            scores: scores.next().flatten().map(|scores| {
+                score_metrics
+                    .iter()
+                    .map(DistanceMetric::as_str)
+                    .zip(scores)
+                    .collect()
+            }),
+            vector: r.vector,
+        })
+        .collect();
+
+    let groups = grouped.map(|grouped| {
+        // Move each match into its group
+        let mut hits: HashMap<String, MatchResult> =
+            matches.drain(..).map(|m| (m.doc_id.clone(), m)).collect();
+        grouped
+            .into_iter()
+            .map(|group| MatchGroup {
+                key: group.parent_id,
+                score: group.score,
+                matches: group
+                    .chunks
+                    .iter()
+                    .filter_map(|hit| hits.remove(&hit.doc_id.to_string()))
+                    .collect(),
+            })
+            .collect()
+    });
+
+    Ok(Json(QueryResponse {
+        matches,
+        groups,
+        columns: None,
+        truncated,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct ParentQueryRequest {
+    query_vector: Vec<f32>,
+    /// Maximum number of parent documents to return
+    top_k: usize,
+    /// Chunk hits retrieved before grouping (default: 4 * top_k)
+    #[serde(default)]
+    chunk_top_k: Option<usize>,
+    /// Maximum chunks returned per parent (default: 3)
+    #[serde(default)]
+    chunks_per_parent: Option<usize>,
+    /// Chunk metadata field holding the parent ID (default: "parent_id")
+    #[serde(default)]
+    parent_key: Option<String>,
+    /// Collection holding parent documents (default: the chunk collection)
+    #[serde(default)]
+    parent_collection_id: Option<String>,
+}
+
+#[derive(Serialize)]
+pub struct ParentQueryResponse {
+    parents: Vec<ParentMatch>,
+    latency_ms: f64,
+}
+
+#[derive(Serialize)]
+pub struct ParentMatch {
+    parent_id: String,
+    score: f32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    metadata: Option<serde_json::Value>,
+    chunks: Vec<MatchResult>,
+}
+
+/// Search chunk vectors and return hits grouped by parent document.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, top_k = req.top_k))]
+pub async fn query_parents(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<ParentQueryRequest>,
+) -> Result<Json<ParentQueryResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let parent_collection_id = req
+        .parent_collection_id
+        .as_deref()
+        .map(CollectionId::from_str)
+        .transpose()
+        .map_err(|e| {
+            (
+                StatusCode::BAD_REQUEST,
+                format!("Invalid parent_collection_id: {}", e),
+            )
+        })?;
+
     if req.query_vector.is_empty() {
         return Err((
             StatusCode::BAD_REQUEST,
@@ -50,16 +528,238 @@ pub async fn query_vectors(
         ));
     }
 
+    let mut options = ParentSearchOptions::new(req.top_k);
+    if let Some(chunk_top_k) = req.chunk_top_k {
+        options = options.with_chunk_top_k(chunk_top_k);
+    }
+    if let Some(chunks_per_parent) = req.chunks_per_parent {
+        options = options.with_chunks_per_parent(chunks_per_parent);
+    }
+    if let Some(parent_key) = req.parent_key {
+        options = options.with_parent_key(parent_key);
+    }
+
     let results = service
-        .query(collection_id, req.query_vector, req.top_k)
+        .search_parents(
+            collection_id,
+            req.query_vector,
+            &options,
+            parent_collection_id,
+        )
         .await
-        .map_err(|e| {
-            if e.to_string().contains("not found") {
-                (StatusCode::NOT_FOUND, e.to_string())
-            } else {
-                (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-            }
-        })?;
+        .map_err(error_response)?;
+
+    let parents = results
+        .into_iter()
+        .map(|p| ParentMatch {
+            parent_id: p.parent_id,
+            score: p.score,
+            metadata: p.metadata,
+            chunks: p
+                .chunks
+                .into_iter()
+                .map(|r| MatchResult {
+                    doc_id: r.doc_id.to_string(),
+                    external_id: r.external_id,
+                    distance: r.score,
+                    metadata: r.metadata,
+                    scores: None,
+                    vector: None,
+                })
+                .collect(),
+        })
+        .collect();
+
+    Ok(Json(ParentQueryResponse {
+        parents,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+/// Parses document IDs given as strings.
+fn parse_doc_ids(ids: &[String]) -> Result<Vec<DocumentId>, (StatusCode, String)> {
+    ids.iter()
+        .map(|id| {
+            DocumentId::from_str(id).map_err(|e| {
+                (
+                    StatusCode::BAD_REQUEST,
+                    format!("Invalid doc_id {}: {}", id, e),
+                )
+            })
+        })
+        .collect()
+}
+
+#[derive(Deserialize)]
+pub struct BatchQueryItem {
+    query_vector: Vec<f32>,
+    /// Results to return (default: the collection's, then the tenant's default top_k)
+    #[serde(default)]
+    top_k: Option<usize>,
+}
+
+#[derive(Deserialize)]
+pub struct BatchQueryRequest {
+    queries: Vec<BatchQueryItem>,
+    /// Queries running at the same time
+    #[serde(default = "default_batch_concurrency")]
+    concurrency: usize,
+}
+
+fn default_batch_concurrency() -> usize {
+    DEFAULT_BATCH_SEARCH_CONCURRENCY
+}
+
+/// Matches of one query of a batch, or why it failed
+#[derive(Serialize)]
+pub struct BatchQueryResult {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    matches: Option<Vec<MatchResult>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    error: Option<String>,
+}
+
+#[derive(Serialize)]
+pub struct BatchQueryResponse {
+    /// One entry per query, in request order
+    results: Vec<BatchQueryResult>,
+    failed: usize,
+    latency_ms: f64,
+}
+
+/// Run many k-NN queries in one request
+///
+/// Up to 10,000 queries run on `concurrency` workers (default 8, max 64).
+/// Results keep the order of `queries`; a failing query reports its `error`
+/// without failing the others.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, queries = req.queries.len()))]
+pub async fn query_batch(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<BatchQueryRequest>,
+) -> Result<Json<BatchQueryResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let defaults = service
+        .search_defaults(collection_id)
+        .await
+        .map_err(error_response)?;
+    let mut queries = Vec::with_capacity(req.queries.len());
+    for (i, query) in req.queries.into_iter().enumerate() {
+        let top_k = service
+            .resolve_top_k(query.top_k.or(defaults.top_k))
+            .await
+            .map_err(|e| {
+                let (status, e) = error_response(e);
+                (status, format!("Query {}: {}", i, e))
+            })?;
+        queries.push(BatchQuery {
+            vector: query.query_vector,
+            top_k,
+        });
+    }
+    let options = BatchSearchOptions {
+        concurrency: req.concurrency,
+    };
+
+    let results = service
+        .batch_search(collection_id, queries, &options)
+        .await
+        .map_err(error_response)?;
+
+    let results: Vec<BatchQueryResult> = results
+        .into_iter()
+        .map(|result| match result {
+            Ok(results) => BatchQueryResult {
+                matches: Some(
+                    results
+                        .into_iter()
+                        .map(|r| MatchResult {
+                            doc_id: r.doc_id.to_string(),
+                            external_id: r.external_id,
+                            distance: r.score,
+                            metadata: r.metadata,
+                            scores: None,
+                            vector: None,
+                        })
+                        .collect(),
+                ),
+                error: None,
+            },
+            Err(e) => BatchQueryResult {
+                matches: None,
+                error: Some(e.to_string()),
+            },
+        })
+        .collect();
+
+    Ok(Json(BatchQueryResponse {
+        failed: results.iter().filter(|r| r.error.is_some()).count(),
+        results,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct RecommendQueryRequest {
+    /// Documents to find more of
+    positive_ids: Vec<String>,
+    /// Documents to steer away from
+    #[serde(default)]
+    negative_ids: Vec<String>,
+    /// Results to return (default: the collection's, then the tenant's default top_k)
+    #[serde(default)]
+    top_k: Option<usize>,
+    /// Metadata recommended documents must match
+    #[serde(default)]
+    filter: Option<MetadataFilter>,
+}
+
+/// Recommend documents like the positive examples and unlike the negative ones
+///
+/// The query vector is built from the examples' stored vectors (see
+/// `RecommendRequest`); the examples themselves are not returned.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, top_k = ?req.top_k))]
+pub async fn recommend_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<RecommendQueryRequest>,
+) -> Result<Json<QueryResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let defaults = service
+        .search_defaults(collection_id)
+        .await
+        .map_err(error_response)?;
+    let top_k = service
+        .resolve_top_k(req.top_k.or(defaults.top_k))
+        .await
+        .map_err(error_response)?;
+    let request = RecommendRequest {
+        positive_ids: parse_doc_ids(&req.positive_ids)?,
+        negative_ids: parse_doc_ids(&req.negative_ids)?,
+        top_k,
+        filter: req.filter,
+    };
+
+    let results = service
+        .recommend(collection_id, &request)
+        .await
+        .map_err(error_response)?;
 
     let matches = results
         .into_iter()
@@ -67,20 +767,116 @@ pub async fn query_vectors(
             doc_id: r.doc_id.to_string(),
             external_id: r.external_id,
             distance: r.score,
+            metadata: r.metadata,
+            scores: None,
+            vector: None,
         })
         .collect();
 
     Ok(Json(QueryResponse {
         matches,
+        groups: None,
+        columns: None,
+        truncated: None,
         latency_ms: start.elapsed().as_secs_f64() * 1000.0,
     }))
 }
 
-#[derive(Deserialize)]
+#[derive(Default, Deserialize)]
 pub struct InsertRequest {
-    doc_id: String,
+    /// UUID v7 of the document (assigned by the server if omitted)
+    #[serde(default)]
+    doc_id: Option<String>,
     external_id: Option<String>,
+    /// An array of numbers or base64 of raw float32 values
+    #[serde(deserialize_with = "deserialize_vector")]
     vector: Vec<f32>,
+    #[serde(default)]
+    metadata: Option<serde_json::Value>,
+    /// Stored with the document and indexed in the collection's sparse index
+    /// (single-document writes only)
+    #[serde(default)]
+    sparse_vector: Option<SparseVector>,
+    /// Vectors in the collection's named vector spaces, stored with the
+    /// document (single-document writes only)
+    #[serde(default)]
+    named_vectors: Option<HashMap<String, Vec<f32>>>,
+}
+
+/// Rejects sparse and named vectors in batch writes (only single-document
+/// writes store them).
+fn reject_batch_extra_vectors(documents: &[InsertRequest]) -> Result<(), (StatusCode, String)> {
+    let extra = |d: &InsertRequest| d.sparse_vector.is_some() || d.named_vectors.is_some();
+    match documents.iter().position(extra) {
+        Some(index) => Err((
+            StatusCode::BAD_REQUEST,
+            format!(
+                "documents[{}]: sparse_vector and named_vectors are only supported by \
+                 single-document insert and upsert",
+                index
+            ),
+        )),
+        None => Ok(()),
+    }
+}
+
+impl InsertRequest {
+    fn into_document(self) -> Result<VectorDocument, (StatusCode, String)> {
+        let doc_id = match self.doc_id.as_deref() {
+            Some(doc_id) => DocumentId::from_str(doc_id)
+                .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid doc_id: {}", e)))?,
+            None => DocumentId::new(),
+        };
+
+        if self.vector.is_empty() {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "vector cannot be empty".to_string(),
+            ));
+        }
+
+        let mut doc = VectorDocument::new(doc_id, self.vector);
+        if let Some(external_id) = self.external_id {
+            doc = doc.with_external_id(external_id);
+        }
+        if let Some(metadata) = self.metadata {
+            doc = doc.with_metadata(metadata);
+        }
+        doc.sparse_vector = self.sparse_vector;
+        doc.named_vectors = self.named_vectors;
+        Ok(doc)
+    }
+}
+
+/// Query parameters of an insert with a raw vector body.
+#[derive(Deserialize)]
+pub struct BinaryInsertParams {
+    #[serde(default)]
+    doc_id: Option<String>,
+    #[serde(default)]
+    external_id: Option<String>,
+    /// Metadata as a JSON object
+    #[serde(default)]
+    metadata: Option<String>,
+}
+
+impl FromBinaryVectors for InsertRequest {
+    type Params = BinaryInsertParams;
+
+    fn from_binary(vector: Vec<f32>, params: BinaryInsertParams) -> Result<Self, String> {
+        let metadata = params
+            .metadata
+            .map(|metadata| serde_json::from_str(&metadata))
+            .transpose()
+            .map_err(|e| format!("Invalid metadata: {}", e))?;
+        Ok(Self {
+            doc_id: params.doc_id,
+            external_id: params.external_id,
+            vector,
+            metadata,
+            ..Self::default()
+        })
+    }
 }
 
 #[derive(Serialize)]
@@ -89,11 +885,11 @@ pub struct InsertResponse {
     latency_ms: f64,
 }
 
-#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, doc_id = %req.doc_id))]
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, doc_id = ?req.doc_id))]
 pub async fn insert_vector(
     Path(collection_id): Path<String>,
     State(service): State<Arc<CollectionService>>,
-    Json(req): Json<InsertRequest>,
+    VectorBody(req): VectorBody<InsertRequest>,
 ) -> Result<Json<InsertResponse>, (StatusCode, String)> {
     let start = std::time::Instant::now();
 
@@ -104,31 +900,221 @@ pub async fn insert_vector(
         )
     })?;
 
-    let doc_id = DocumentId::from_str(&req.doc_id)
-        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid doc_id: {}", e)))?;
+    let doc = req.into_document()?;
+
+    let inserted_id = service
+        .insert(collection_id, doc)
+        .await
+        .map_err(error_response)?;
 
-    if req.vector.is_empty() {
+    Ok(Json(InsertResponse {
+        doc_id: inserted_id.to_string(),
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct BatchInsertRequest {
+    documents: Vec<InsertRequest>,
+    /// Derive each document's ID from these fields (upsert only)
+    #[serde(default)]
+    id_from: Vec<String>,
+}
+
+/// Query parameters of a batch write with a raw vectors body.
+#[derive(Deserialize)]
+pub struct BinaryBatchParams {
+    /// Values per vector; the body holds the vectors back to back
+    dimension: usize,
+}
+
+impl FromBinaryVectors for BatchInsertRequest {
+    type Params = BinaryBatchParams;
+
+    fn from_binary(values: Vec<f32>, params: BinaryBatchParams) -> Result<Self, String> {
+        if params.dimension == 0 || values.len() % params.dimension != 0 {
+            return Err(format!(
+                "body of {} values is not a whole number of {}-dimensional vectors",
+                values.len(),
+                params.dimension
+            ));
+        }
+        let documents = values
+            .chunks_exact(params.dimension)
+            .map(|vector| InsertRequest {
+                vector: vector.to_vec(),
+                ..InsertRequest::default()
+            })
+            .collect();
+        Ok(Self {
+            documents,
+            id_from: Vec::new(),
+        })
+    }
+}
+
+#[derive(Serialize)]
+pub struct BatchInsertResponse {
+    #[serde(flatten)]
+    report: BatchInsertReport,
+    latency_ms: f64,
+}
+
+/// Insert a batch of documents
+///
+/// Documents are written one by one; failures are reported per document.
+/// Documents without a `doc_id` get one assigned; `ids` lists every
+/// document's ID in request order.
+/// Duplicate IDs (repeated in the batch or already stored) are listed with
+/// their positions and what was done: repeats are written once and stored
+/// documents are kept.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, documents = req.documents.len()))]
+pub async fn insert_batch(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    VectorBody(req): VectorBody<BatchInsertRequest>,
+) -> Result<Json<BatchInsertResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    if !req.id_from.is_empty() {
         return Err((
             StatusCode::BAD_REQUEST,
-            "vector cannot be empty".to_string(),
+            "id_from is only supported by batch upsert".to_string(),
         ));
     }
+    reject_batch_extra_vectors(&req.documents)?;
 
-    let mut doc = VectorDocument::new(doc_id, req.vector);
-    if let Some(external_id) = req.external_id {
-        doc = doc.with_external_id(external_id);
-    }
+    let docs = req
+        .documents
+        .into_iter()
+        .enumerate()
+        .map(|(index, doc)| {
+            doc.into_document()
+                .map_err(|(status, e)| (status, format!("documents[{}]: {}", index, e)))
+        })
+        .collect::<Result<Vec<_>, _>>()?;
 
-    let inserted_id = service.insert(collection_id, doc).await.map_err(|e| {
-        if e.to_string().contains("not found") {
-            (StatusCode::NOT_FOUND, e.to_string())
-        } else {
-            (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-        }
+    let report = service
+        .insert_batch(collection_id, docs)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(BatchInsertResponse {
+        report,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+#[derive(Serialize)]
+pub struct UpsertResponse {
+    doc_id: String,
+    /// False if a stored document was replaced
+    created: bool,
+    latency_ms: f64,
+}
+
+/// Insert a document, replacing any stored document with the same ID
+///
+/// Readers see either the old or the new document, never neither. The
+/// sparse and named vectors are replaced too (omitted ones are removed).
+/// Returns 409 if the stored document is under legal hold.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, doc_id = ?req.doc_id))]
+pub async fn upsert_vector(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    VectorBody(req): VectorBody<InsertRequest>,
+) -> Result<Json<UpsertResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
     })?;
 
-    Ok(Json(InsertResponse {
-        doc_id: inserted_id.to_string(),
+    let doc = req.into_document()?;
+    let doc_id = doc.doc_id;
+
+    let created = service
+        .upsert(collection_id, doc)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(UpsertResponse {
+        doc_id: doc_id.to_string(),
+        created,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+/// Upsert a batch of documents
+///
+/// Documents are written in order; `inserted` counts created documents and
+/// `updated` replaced ones. IDs repeated in the batch are listed in
+/// `duplicates`; the last occurrence is stored.
+///
+/// With `id_from`, each document's ID is derived from a hash of those fields
+/// (metadata keys or `external_id`), so re-ingesting the same content
+/// replaces it. Documents must then omit `doc_id`. A document whose derived
+/// ID is taken by different content is not written and counts in
+/// `collisions`.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, documents = req.documents.len()))]
+pub async fn upsert_batch(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    VectorBody(req): VectorBody<BatchInsertRequest>,
+) -> Result<Json<BatchInsertResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    if !req.id_from.is_empty() {
+        if let Some(index) = req.documents.iter().position(|d| d.doc_id.is_some()) {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                format!("documents[{}]: doc_id cannot be set with id_from", index),
+            ));
+        }
+    }
+    reject_batch_extra_vectors(&req.documents)?;
+
+    let docs = req
+        .documents
+        .into_iter()
+        .enumerate()
+        .map(|(index, doc)| {
+            doc.into_document()
+                .map_err(|(status, e)| (status, format!("documents[{}]: {}", index, e)))
+        })
+        .collect::<Result<Vec<_>, _>>()?;
+
+    let report = if req.id_from.is_empty() {
+        service.upsert_batch(collection_id, docs).await
+    } else {
+        let spec = ContentIdSpec {
+            fields: req.id_from,
+        };
+        service
+            .upsert_batch_by_content(collection_id, docs, &spec)
+            .await
+    };
+    let report = report.map_err(error_response)?;
+
+    Ok(Json(BatchInsertResponse {
+        report,
         latency_ms: start.elapsed().as_secs_f64() * 1000.0,
     }))
 }
@@ -142,10 +1128,25 @@ pub struct GetResponse {
 pub struct VectorDocumentResponse {
     doc_id: String,
     external_id: Option<String>,
-    vector: Vec<f32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    vector: Option<Vec<f32>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    metadata: Option<serde_json::Value>,
     inserted_at: String,
 }
 
+impl VectorDocumentResponse {
+    fn new(doc: VectorDocument, include_vector: bool) -> Self {
+        Self {
+            doc_id: doc.doc_id.to_string(),
+            external_id: doc.external_id,
+            vector: include_vector.then_some(doc.vector),
+            metadata: doc.metadata,
+            inserted_at: doc.inserted_at.to_rfc3339(),
+        }
+    }
+}
+
 pub async fn get_vector(
     Path((collection_id, doc_id)): Path<(String, String)>,
     State(service): State<Arc<CollectionService>>,
@@ -160,22 +1161,384 @@ pub async fn get_vector(
     let doc_id = DocumentId::from_str(&doc_id)
         .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid doc_id: {}", e)))?;
 
-    let doc = service.get(collection_id, doc_id).await.map_err(|e| {
-        if e.to_string().contains("not found") {
-            (StatusCode::NOT_FOUND, e.to_string())
-        } else {
-            (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-        }
+    let doc = service
+        .get(collection_id, doc_id)
+        .await
+        .map_err(error_response)?;
+
+    let document = doc.map(|d| VectorDocumentResponse::new(d, true));
+
+    Ok(Json(GetResponse { document }))
+}
+
+/// Patch a document's metadata without resending its vector
+///
+/// The body is a JSON Merge Patch (RFC 7396): listed fields replace stored
+/// ones, nested objects are merged and `null` removes a field. Returns the
+/// updated document without its vector; 409 if it is under legal hold.
+#[tracing::instrument(skip(service, patch), fields(collection_id = %collection_id, doc_id = %doc_id))]
+pub async fn update_metadata(
+    Path((collection_id, doc_id)): Path<(String, String)>,
+    State(service): State<Arc<CollectionService>>,
+    Json(patch): Json<serde_json::Map<String, serde_json::Value>>,
+) -> Result<Json<GetResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let doc_id = DocumentId::from_str(&doc_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid doc_id: {}", e)))?;
+
+    let doc = service
+        .update_metadata(collection_id, doc_id, &patch)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(GetResponse {
+        document: Some(VectorDocumentResponse::new(doc, false)),
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct UpdateMetadataBatchRequest {
+    updates: Vec<MetadataPatch>,
+}
+
+/// Patch the metadata of up to 1000 documents
+///
+/// Patches are applied in order; `updated` counts patched documents and
+/// failures (e.g. unknown IDs) are listed in `errors`.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, updates = req.updates.len()))]
+pub async fn update_metadata_batch(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<UpdateMetadataBatchRequest>,
+) -> Result<Json<BatchInsertResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let report = service
+        .update_metadata_batch(collection_id, req.updates)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(BatchInsertResponse {
+        report,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct FetchRequest {
+    ids: Vec<String>,
+    #[serde(default = "default_include_vector")]
+    include_vector: bool,
+}
+
+fn default_include_vector() -> bool {
+    true
+}
+
+#[derive(Serialize)]
+pub struct FetchResponse {
+    /// Found documents, in request order
+    documents: Vec<VectorDocumentResponse>,
+    /// Requested IDs with no stored document
+    missing: Vec<String>,
+}
+
+#[derive(Deserialize)]
+pub struct ScrollRequest {
+    #[serde(default = "default_page_limit")]
+    limit: usize,
+    #[serde(default)]
+    cursor: Option<String>,
+    #[serde(default)]
+    filter: Option<MetadataFilter>,
+    #[serde(default, flatten)]
+    order: ListOrder,
+    /// Only walk this part of the collection
+    #[serde(default)]
+    partition: Option<Partition>,
+    #[serde(default)]
+    include_vectors: bool,
+}
+
+#[derive(Serialize)]
+pub struct ScrollResponse {
+    documents: Vec<VectorDocumentResponse>,
+    /// Cursor for the next page; absent on the last page
+    next_cursor: Option<String>,
+}
+
+/// Page through every document of a collection
+///
+/// Documents come in a stable order (`sort` and `direction`, ID by default)
+/// with keyset cursors: pass `next_cursor` back as `cursor` until it is
+/// null. Every document present for the whole walk is returned exactly
+/// once, even while documents are inserted or deleted.
+///
+/// Exports of large collections can pass `partition: {index, count}` to
+/// walk `count` disjoint parts concurrently, each with its own cursor.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, limit = req.limit))]
+pub async fn scroll_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<ScrollRequest>,
+) -> Result<Json<ScrollResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
     })?;
 
-    let document = doc.map(|d| VectorDocumentResponse {
-        doc_id: d.doc_id.to_string(),
-        external_id: d.external_id,
-        vector: d.vector,
-        inserted_at: d.inserted_at.to_rfc3339(),
+    let page = service
+        .scroll_documents(
+            collection_id,
+            req.order,
+            req.filter.as_ref(),
+            req.partition,
+            req.cursor.as_deref(),
+            req.limit,
+        )
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(ScrollResponse {
+        documents: page
+            .items
+            .into_iter()
+            .map(|doc| VectorDocumentResponse::new(doc, req.include_vectors))
+            .collect(),
+        next_cursor: page.next_cursor,
+    }))
+}
+
+/// Records read and serialized per chunk of an export response.
+pub const EXPORT_CHUNK_RECORDS: usize = 1_000;
+
+#[derive(Deserialize)]
+pub struct ExportParams {
+    /// Encoding of the exported vectors
+    #[serde(default)]
+    vector_codec: VectorCodec,
+}
+
+/// Stream a whole collection as newline-delimited JSON
+///
+/// One `ExportRecord` per line, in ID order, as in compliance exports and
+/// snapshots, so the output can be imported as it is. The collection is
+/// scrolled `EXPORT_CHUNK_RECORDS` documents at a time as the response is
+/// sent, so neither the collection nor the response is held whole in
+/// memory; every document present for the whole export is written exactly
+/// once.
+#[tracing::instrument(skip(service, params), fields(collection_id = %collection_id))]
+pub async fn export_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Query(params): Query<ExportParams>,
+) -> Result<impl IntoResponse, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let collection = service
+        .get_collection(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    // The state is the cursor of the next page (None once the last page is sent)
+    let codec = params.vector_codec;
+    let chunks = futures::stream::try_unfold(Some(None), move |cursor: Option<Option<String>>| {
+        let service = service.clone();
+        let name = collection.name.clone();
+        async move {
+            let Some(cursor) = cursor else {
+                return Ok(None);
+            };
+            let page = service
+                .scroll_documents(
+                    collection_id,
+                    ListOrder::default(),
+                    None,
+                    None,
+                    cursor.as_deref(),
+                    EXPORT_CHUNK_RECORDS,
+                )
+                .await?;
+            let mut data = Vec::new();
+            for doc in page.items {
+                let record = ExportRecord::new(collection_id, &name, doc, codec);
+                serde_json::to_writer(&mut data, &record).map_err(|e| {
+                    CoreError::internal(format!("Failed to serialize record: {}", e))
+                })?;
+                data.push(b'\n');
+            }
+            Ok::<_, CoreError>(Some((Bytes::from(data), page.next_cursor.map(Some))))
+        }
     });
 
-    Ok(Json(GetResponse { document }))
+    Ok((
+        [(header::CONTENT_TYPE, "application/x-ndjson")],
+        StreamBody::new(chunks),
+    ))
+}
+
+#[derive(Deserialize)]
+pub struct CountRequest {
+    #[serde(default)]
+    filter: Option<MetadataFilter>,
+    #[serde(default = "default_exact_count")]
+    exact: bool,
+}
+
+fn default_exact_count() -> bool {
+    true
+}
+
+#[derive(Serialize)]
+pub struct CountResponse {
+    count: usize,
+    exact: bool,
+    latency_ms: f64,
+}
+
+/// Count documents, optionally only those matching a metadata filter
+///
+/// Counts the index, so the result reflects writes as soon as they are
+/// acknowledged (unlike the collection's `vector_count`). With
+/// `"exact": false` a filtered count of a large collection is estimated from
+/// a sample.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn count_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<CountRequest>,
+) -> Result<Json<CountResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let count = service
+        .count_documents(collection_id, req.filter.as_ref(), req.exact)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(CountResponse {
+        count: count.count,
+        exact: count.exact,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct LookupRequest {
+    filter: MetadataFilter,
+    #[serde(default)]
+    sort: Option<MetadataSort>,
+    #[serde(default = "default_page_limit")]
+    limit: usize,
+    #[serde(default)]
+    include_vector: bool,
+}
+
+fn default_page_limit() -> usize {
+    DEFAULT_PAGE_SIZE
+}
+
+#[derive(Serialize)]
+pub struct LookupResponse {
+    documents: Vec<VectorDocumentResponse>,
+    latency_ms: f64,
+}
+
+/// Retrieve documents by metadata filter, without a query vector
+///
+/// Returns up to `limit` (max 1000) documents whose metadata matches
+/// `filter`, sorted by the metadata field `sort.field` if given (ID
+/// order otherwise), e.g. all chunks of a document by chunk index.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn lookup_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<LookupRequest>,
+) -> Result<Json<LookupResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let docs = service
+        .find_documents(collection_id, &req.filter, req.sort.as_ref(), req.limit)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(LookupResponse {
+        documents: docs
+            .into_iter()
+            .map(|doc| VectorDocumentResponse::new(doc, req.include_vector))
+            .collect(),
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+/// Fetch documents by ID
+///
+/// Returns the stored documents for up to 1000 IDs in one call, with or
+/// without their vectors. IDs not stored are listed in `missing`.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, ids = req.ids.len()))]
+pub async fn fetch_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<FetchRequest>,
+) -> Result<Json<FetchResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    check_batch_size(req.ids.len()).map_err(error_response)?;
+    let doc_ids = parse_doc_ids(&req.ids)?;
+
+    let docs = service
+        .get_many(collection_id, &doc_ids)
+        .await
+        .map_err(error_response)?;
+
+    let mut documents = Vec::new();
+    let mut missing = Vec::new();
+    for (id, doc) in req.ids.into_iter().zip(docs) {
+        match doc {
+            Some(doc) => documents.push(VectorDocumentResponse::new(doc, req.include_vector)),
+            None => missing.push(id),
+        }
+    }
+
+    Ok(Json(FetchResponse { documents, missing }))
 }
 
 #[derive(Serialize)]
@@ -199,19 +1562,97 @@ pub async fn delete_vector(
     let doc_id = DocumentId::from_str(&doc_id)
         .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid doc_id: {}", e)))?;
 
-    service.delete(collection_id, doc_id).await.map_err(|e| {
-        if e.to_string().contains("not found") {
-            (StatusCode::NOT_FOUND, e.to_string())
-        } else {
-            (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-        }
-    })?;
+    service
+        .delete(collection_id, doc_id)
+        .await
+        .map_err(error_response)?;
 
     Ok(Json(DeleteResponse {
         latency_ms: start.elapsed().as_secs_f64() * 1000.0,
     }))
 }
 
+/// Delete documents by ID or by metadata filter (exactly one)
+#[derive(Deserialize)]
+pub struct DeleteVectorsRequest {
+    #[serde(default)]
+    ids: Vec<String>,
+    #[serde(default)]
+    filter: Option<MetadataFilter>,
+}
+
+#[derive(Serialize)]
+pub struct DeleteVectorsResponse {
+    #[serde(flatten)]
+    report: DeleteReport,
+    latency_ms: f64,
+}
+
+/// Delete several documents
+///
+/// By `ids` (up to 10,000) or by `filter`. Unknown IDs are listed in
+/// `missing`, documents under legal hold in `held` and malformed IDs or
+/// failed deletes in `failed`; none of them fails the request.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, ids = req.ids.len()))]
+pub async fn delete_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<DeleteVectorsRequest>,
+) -> Result<Json<DeleteVectorsResponse>, (StatusCode, String)> {
+    let start = std::time::Instant::now();
+
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let report = match (req.ids.is_empty(), req.filter) {
+        (false, None) => delete_by_ids(&service, collection_id, &req.ids).await,
+        (true, Some(filter)) => service.delete_by_filter(collection_id, &filter).await,
+        _ => {
+            return Err((
+                StatusCode::BAD_REQUEST,
+                "Set exactly one of ids and filter".to_string(),
+            ))
+        }
+    };
+    let report = report.map_err(error_response)?;
+
+    Ok(Json(DeleteVectorsResponse {
+        report,
+        latency_ms: start.elapsed().as_secs_f64() * 1000.0,
+    }))
+}
+
+/// Delete the documents of `ids`, reporting malformed IDs as failures.
+async fn delete_by_ids(
+    service: &CollectionService,
+    collection_id: CollectionId,
+    ids: &[String],
+) -> CoreResult<DeleteReport> {
+    check_delete_batch_size(ids.len())?;
+    let mut doc_ids = Vec::with_capacity(ids.len());
+    let mut invalid = Vec::new();
+    for id in ids {
+        match DocumentId::from_str(id) {
+            Ok(doc_id) => doc_ids.push(doc_id),
+            Err(e) => invalid.push(DeleteFailure {
+                id: id.clone(),
+                message: format!("Invalid doc_id: {}", e),
+            }),
+        }
+    }
+    let mut report = if doc_ids.is_empty() {
+        DeleteReport::default()
+    } else {
+        service.delete_many(collection_id, &doc_ids).await?
+    };
+    report.failed.extend(invalid);
+    Ok(report)
+}
+
 #[derive(Serialize)]
 pub struct HealthResponse {
     status: String,
@@ -0,0 +1,112 @@
+//! Compliance job API handlers
+//!
+//! Data subject exports and purges across all of the tenant's collections:
+//! - POST /compliance-jobs - Start an export or purge job
+//! - GET /compliance-jobs - List jobs
+//! - GET /compliance-jobs/{job_id} - Get a job and its signed report
+//! - GET /compliance-jobs/{job_id}/export - Download an export (NDJSON)
+//! - POST /compliance/verify - Check a report's signature
+
+use akidb_core::JobId;
+use akidb_service::{CollectionService, ComplianceJob, ComplianceReport, ComplianceRequest};
+use axum::{
+    extract::{Path, State},
+    http::{header, StatusCode},
+    response::IntoResponse,
+    Json,
+};
+use serde::Serialize;
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::error_response;
+
+/// List compliance jobs response
+#[derive(Serialize)]
+pub struct ListComplianceJobsResponse {
+    pub jobs: Vec<ComplianceJob>,
+}
+
+/// Report verification response
+#[derive(Serialize)]
+pub struct VerifyReportResponse {
+    /// True if the report was signed by this server and has not been altered
+    pub valid: bool,
+}
+
+fn parse_job_id(job_id: &str) -> Result<JobId, (StatusCode, String)> {
+    JobId::from_str(job_id).map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid job_id: {}", e)))
+}
+
+/// Start a data subject export or purge job
+#[tracing::instrument(skip(service, req))]
+pub async fn create_compliance_job(
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<ComplianceRequest>,
+) -> Result<(StatusCode, Json<ComplianceJob>), (StatusCode, String)> {
+    let job = service
+        .start_compliance_job(req)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::ACCEPTED, Json(job)))
+}
+
+/// List compliance jobs
+#[tracing::instrument(skip(service))]
+pub async fn list_compliance_jobs(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListComplianceJobsResponse> {
+    Json(ListComplianceJobsResponse {
+        jobs: service.list_compliance_jobs().await,
+    })
+}
+
+/// Get a compliance job
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn get_compliance_job(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ComplianceJob>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let job = service
+        .get_compliance_job(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(job))
+}
+
+/// Download a completed export job's NDJSON file
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn download_compliance_export(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<impl IntoResponse, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let path = service
+        .compliance_export_file(job_id)
+        .await
+        .map_err(error_response)?;
+    let data = tokio::fs::read(&path).await.map_err(|e| {
+        (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            format!("Failed to read export: {}", e),
+        )
+    })?;
+
+    Ok(([(header::CONTENT_TYPE, "application/x-ndjson")], data))
+}
+
+/// Check that a compliance report was signed by this server
+#[tracing::instrument(skip(service, report))]
+pub async fn verify_compliance_report(
+    State(service): State<Arc<CollectionService>>,
+    Json(report): Json<ComplianceReport>,
+) -> Json<VerifyReportResponse> {
+    Json(VerifyReportResponse {
+        valid: service.verify_compliance_report(&report).await,
+    })
+}
@@ -0,0 +1,57 @@
+//! Service event API handlers
+//!
+//! Problems of background work (replication, scheduled and compliance jobs,
+//! growth sampling, tenant purges and suspension refreshes):
+//! - GET /admin/events - List recent events (optionally only those after an event ID)
+//! - GET /admin/events/stream - Stream events as they are published (server-sent events)
+
+use akidb_service::{CollectionService, EventLevel, ServiceEvent};
+use axum::{
+    extract::{Query, State},
+    response::sse::{Event, Sse},
+    Json,
+};
+use futures::stream::Stream;
+use serde::{Deserialize, Serialize};
+use std::convert::Infallible;
+use std::sync::Arc;
+
+use super::sse::alert_events;
+
+/// Query parameters for listing events
+#[derive(Deserialize)]
+pub struct ListEventsParams {
+    /// Only return events with a greater ID (the last ID seen when polling)
+    pub after: Option<u64>,
+    /// Only return events of at least this level (default: info)
+    pub level: Option<EventLevel>,
+}
+
+/// List events response
+#[derive(Serialize)]
+pub struct ListEventsResponse {
+    pub events: Vec<ServiceEvent>,
+}
+
+/// List recent events of background components, oldest first
+#[tracing::instrument(skip(service, params))]
+pub async fn list_events(
+    Query(params): Query<ListEventsParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListEventsResponse> {
+    let min_level = params.level.unwrap_or(EventLevel::Info);
+    Json(ListEventsResponse {
+        events: service.list_events(params.after, min_level),
+    })
+}
+
+/// Stream events of background components as they are published
+///
+/// Sends one `service_event` event per published event, of every level;
+/// fetch earlier events with `GET /admin/events`.
+#[tracing::instrument(skip(service))]
+pub async fn service_events(
+    State(service): State<Arc<CollectionService>>,
+) -> Sse<impl Stream<Item = Result<Event, Infallible>>> {
+    alert_events("service_event", service.subscribe_events())
+}
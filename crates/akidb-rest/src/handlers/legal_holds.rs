@@ -0,0 +1,94 @@
+//! Legal hold API handlers
+//!
+//! Held documents cannot be deleted (directly, by expiry jobs or by purges),
+//! and a collection with holds cannot be deleted, until the holds are released:
+//! - POST /collections/{id}/legal-holds - Place a hold on a collection or a filtered subset
+//! - GET /collections/{id}/legal-holds - List a collection's holds
+//! - GET /legal-holds/{hold_id} - Get a hold
+//! - DELETE /legal-holds/{hold_id} - Release a hold
+
+use akidb_core::LegalHoldId;
+use akidb_service::{CollectionService, LegalHold, LegalHoldSpec};
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    Json,
+};
+use serde::Serialize;
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::{error_response, parse_collection_id};
+
+/// List legal holds response
+#[derive(Serialize)]
+pub struct ListLegalHoldsResponse {
+    pub holds: Vec<LegalHold>,
+}
+
+fn parse_hold_id(hold_id: &str) -> Result<LegalHoldId, (StatusCode, String)> {
+    LegalHoldId::from_str(hold_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid hold_id: {}", e)))
+}
+
+/// Place a legal hold on a collection
+#[tracing::instrument(skip(service, spec), fields(collection_id = %collection_id))]
+pub async fn place_legal_hold(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(spec): Json<LegalHoldSpec>,
+) -> Result<(StatusCode, Json<LegalHold>), (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let hold = service
+        .place_legal_hold(collection_id, spec)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(hold)))
+}
+
+/// List a collection's legal holds
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn list_legal_holds(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ListLegalHoldsResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    Ok(Json(ListLegalHoldsResponse {
+        holds: service.list_legal_holds(Some(collection_id)).await,
+    }))
+}
+
+/// Get a legal hold
+#[tracing::instrument(skip(service), fields(hold_id = %hold_id))]
+pub async fn get_legal_hold(
+    Path(hold_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<LegalHold>, (StatusCode, String)> {
+    let hold_id = parse_hold_id(&hold_id)?;
+
+    let hold = service
+        .get_legal_hold(hold_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(hold))
+}
+
+/// Release a legal hold
+#[tracing::instrument(skip(service), fields(hold_id = %hold_id))]
+pub async fn release_legal_hold(
+    Path(hold_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let hold_id = parse_hold_id(&hold_id)?;
+
+    service
+        .release_legal_hold(hold_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
@@ -1,21 +1,37 @@
-use akidb_core::{CollectionId, DistanceMetric};
-use akidb_service::CollectionService;
+use akidb_core::{CollectionDescriptor, CollectionId, DistanceMetric};
+use akidb_service::{
+    validate_named_vectors, CloneOptions, CloneReport, CollectionService, CollectionUpdate,
+    FieldIndexInfo, FieldIndexType, IndexOptions, ListOrder, NamedVectorConfig, ReindexPlan,
+    SearchDefaults, SortDirection, SortField, SparseIndexConfig, TransformSpec, DEFAULT_PAGE_SIZE,
+};
 use axum::{
-    extract::{Path, State},
+    extract::{Path, Query, State},
     http::StatusCode,
     Json,
 };
 use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
 use std::str::FromStr;
 use std::sync::Arc;
 
+use super::error_response;
+
 #[derive(Deserialize)]
 pub struct CreateCollectionRequest {
     name: String,
     dimension: u32,
-    metric: String,
+    /// Distance metric (default: the tenant's default metric)
+    #[serde(default)]
+    metric: Option<String>,
     #[serde(skip_serializing_if = "Option::is_none")]
     embedding_model: Option<String>,
+    /// Extra vector spaces by name, e.g. "title" and "body" embeddings
+    #[serde(default)]
+    named_vectors: BTreeMap<String, NamedVectorConfig>,
+    /// Index type (`auto`, `flat` or `hnsw`), `hnsw_m`, `hnsw_ef_construction`
+    /// and `ef_search`
+    #[serde(flatten)]
+    index: IndexOptions,
 }
 
 #[derive(Serialize)]
@@ -24,9 +40,11 @@ pub struct CreateCollectionResponse {
     name: String,
     dimension: u32,
     metric: String,
+    #[serde(skip_serializing_if = "BTreeMap::is_empty")]
+    named_vectors: BTreeMap<String, NamedVectorConfig>,
 }
 
-#[tracing::instrument(skip(service, req), fields(name = %req.name, dimension = req.dimension, metric = %req.metric))]
+#[tracing::instrument(skip(service, req), fields(name = %req.name, dimension = req.dimension, metric = ?req.metric))]
 pub async fn create_collection(
     State(service): State<Arc<CollectionService>>,
     Json(req): Json<CreateCollectionRequest>,
@@ -37,32 +55,46 @@ pub async fn create_collection(
     }
 
     // Parse metric
-    let metric = match req.metric.to_lowercase().as_str() {
-        "cosine" => DistanceMetric::Cosine,
-        "l2" => DistanceMetric::L2,
-        "dot" => DistanceMetric::Dot,
-        _ => {
+    let metric = match req.metric.as_deref().map(str::to_lowercase).as_deref() {
+        Some("cosine") => Some(DistanceMetric::Cosine),
+        Some("l2") => Some(DistanceMetric::L2),
+        Some("dot") => Some(DistanceMetric::Dot),
+        Some(other) => {
             return Err((
                 StatusCode::BAD_REQUEST,
                 format!(
                     "invalid metric: '{}', must be one of: cosine, l2, dot",
-                    req.metric
+                    other
                 ),
             ))
         }
+        None => None,
     };
+    let metric = service
+        .resolve_metric(metric)
+        .await
+        .map_err(error_response)?;
+    validate_named_vectors(&req.named_vectors).map_err(error_response)?;
 
     // Create collection
     let collection_id = service
-        .create_collection(req.name.clone(), req.dimension, metric, req.embedding_model)
+        .create_collection_with_index(
+            req.name.clone(),
+            req.dimension,
+            metric,
+            req.embedding_model,
+            req.index,
+        )
         .await
-        .map_err(|e| {
-            if e.to_string().contains("dimension") {
-                (StatusCode::BAD_REQUEST, e.to_string())
-            } else {
-                (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-            }
-        })?;
+        .map_err(error_response)?;
+    let named_vectors = if req.named_vectors.is_empty() {
+        BTreeMap::new()
+    } else {
+        service
+            .set_named_vector_configs(collection_id, req.named_vectors)
+            .await
+            .map_err(error_response)?
+    };
 
     Ok((
         StatusCode::CREATED,
@@ -70,14 +102,27 @@ pub async fn create_collection(
             collection_id: collection_id.to_string(),
             name: req.name,
             dimension: req.dimension,
-            metric: req.metric,
+            metric: metric.as_str().to_string(),
+            named_vectors,
         }),
     ))
 }
 
+#[derive(Deserialize)]
+pub struct ListCollectionsParams {
+    #[serde(default)]
+    sort: SortField,
+    #[serde(default)]
+    direction: SortDirection,
+    limit: Option<usize>,
+    cursor: Option<String>,
+}
+
 #[derive(Serialize)]
 pub struct ListCollectionsResponse {
     collections: Vec<CollectionInfo>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    next_cursor: Option<String>,
 }
 
 #[derive(Serialize)]
@@ -87,31 +132,78 @@ pub struct CollectionInfo {
     dimension: u32,
     metric: String,
     document_count: u64,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    description: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    metadata: Option<serde_json::Value>,
+    index_type: String,
+    hnsw_m: u32,
+    hnsw_ef_construction: u32,
+    max_doc_count: u64,
+    /// RFC 3339 timestamps
     created_at: String,
+    updated_at: String,
+}
+
+impl CollectionInfo {
+    fn new(collection: CollectionDescriptor, document_count: u64) -> Self {
+        Self {
+            collection_id: collection.collection_id.to_string(),
+            name: collection.name,
+            dimension: collection.dimension,
+            metric: collection.metric.as_str().to_string(),
+            document_count,
+            description: collection.description,
+            metadata: collection.metadata,
+            index_type: collection.index_type.as_str().to_string(),
+            hnsw_m: collection.hnsw_m,
+            hnsw_ef_construction: collection.hnsw_ef_construction,
+            max_doc_count: collection.max_doc_count,
+            created_at: collection.created_at.to_rfc3339(),
+            updated_at: collection.updated_at.to_rfc3339(),
+        }
+    }
 }
 
+/// GET /api/v1/collections - List collections
+///
+/// Sorted by `sort` (`id`, `created_at` or `name`) in `direction` (`asc` or
+/// `desc`), ties broken by ID. With `limit` or `cursor` the listing is paged:
+/// pass `next_cursor` back as `cursor` with the same order to get the next
+/// page. Pages are keyset-based, so collections created or deleted meanwhile
+/// cause neither duplicates nor gaps.
 pub async fn list_collections(
+    Query(params): Query<ListCollectionsParams>,
     State(service): State<Arc<CollectionService>>,
 ) -> Result<Json<ListCollectionsResponse>, (StatusCode, String)> {
-    let collections = service
-        .list_collections()
-        .await
-        .map_err(|e| (StatusCode::INTERNAL_SERVER_ERROR, e.to_string()))?;
+    let order = ListOrder {
+        sort: params.sort,
+        direction: params.direction,
+    };
+    let (collections, next_cursor) = if params.limit.is_some() || params.cursor.is_some() {
+        let page = service
+            .list_collections_page(
+                order,
+                params.cursor.as_deref(),
+                params.limit.unwrap_or(DEFAULT_PAGE_SIZE),
+            )
+            .await
+            .map_err(error_response)?;
+        (page.items, page.next_cursor)
+    } else {
+        let mut collections = service.list_collections().await.map_err(error_response)?;
+        order.sort(&mut collections);
+        (collections, None)
+    };
 
     let collection_infos = collections
         .into_iter()
-        .map(|c| CollectionInfo {
-            collection_id: c.collection_id.to_string(),
-            name: c.name,
-            dimension: c.dimension,
-            metric: c.metric.as_str().to_string(),
-            document_count: 0, // TODO: Get actual count from service
-            created_at: c.created_at.to_rfc3339(),
-        })
+        .map(|c| CollectionInfo::new(c, 0)) // TODO: Get actual count from service
         .collect();
 
     Ok(Json(ListCollectionsResponse {
         collections: collection_infos,
+        next_cursor,
     }))
 }
 
@@ -131,26 +223,16 @@ pub async fn get_collection(
         )
     })?;
 
-    let collection = service.get_collection(collection_id).await.map_err(|e| {
-        if e.to_string().contains("not found") {
-            (StatusCode::NOT_FOUND, e.to_string())
-        } else {
-            (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-        }
-    })?;
+    let collection = service
+        .get_collection(collection_id)
+        .await
+        .map_err(error_response)?;
 
     // Get document count
     let document_count = service.get_count(collection_id).await.unwrap_or(0) as u64;
 
     Ok(Json(GetCollectionResponse {
-        collection: CollectionInfo {
-            collection_id: collection.collection_id.to_string(),
-            name: collection.name,
-            dimension: collection.dimension,
-            metric: collection.metric.as_str().to_string(),
-            document_count,
-            created_at: collection.created_at.to_rfc3339(),
-        },
+        collection: CollectionInfo::new(collection, document_count),
     }))
 }
 
@@ -168,17 +250,492 @@ pub async fn delete_collection(
     service
         .delete_collection(collection_id)
         .await
-        .map_err(|e| {
-            if e.to_string().contains("not found") {
-                (StatusCode::NOT_FOUND, e.to_string())
-            } else {
-                (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
-            }
-        })?;
+        .map_err(error_response)?;
 
     Ok(StatusCode::NO_CONTENT)
 }
 
+#[derive(Deserialize)]
+pub struct RenameCollectionRequest {
+    name: String,
+}
+
+/// POST /api/v1/collections/:id/rename - Rename a collection in place
+///
+/// Nothing is copied: documents, aliases and in-flight writes are keyed by
+/// collection ID and are unaffected. Fails with 409 if another collection
+/// has the name.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, name = %req.name))]
+pub async fn rename_collection(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<RenameCollectionRequest>,
+) -> Result<Json<GetCollectionResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let collection = service
+        .rename_collection(collection_id, req.name)
+        .await
+        .map_err(error_response)?;
+
+    let document_count = service.get_count(collection_id).await.unwrap_or(0) as u64;
+
+    Ok(Json(GetCollectionResponse {
+        collection: CollectionInfo::new(collection, document_count),
+    }))
+}
+
+#[derive(Deserialize)]
+pub struct CloneCollectionRequest {
+    /// Name of the new collection
+    name: String,
+    /// `filter` (copy only matching documents) and `schema_only`
+    #[serde(flatten)]
+    options: CloneOptions,
+}
+
+/// POST /api/v1/collections/:id/clone - Copy a collection server-side
+///
+/// Creates a new collection with the source's schema and copies its
+/// documents (or only those matching `filter`, or none with `schema_only`),
+/// keeping document IDs. The source is left as it is.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, name = %req.name))]
+pub async fn clone_collection(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<CloneCollectionRequest>,
+) -> Result<(StatusCode, Json<CloneReport>), (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let report = service
+        .clone_collection(collection_id, req.name, req.options)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(report)))
+}
+
+/// PATCH /api/v1/collections/:id - Update a collection's settings in place
+///
+/// Changes the description, metadata, HNSW parameters, document limit or
+/// default search parameters; fields left out are unchanged. Dimension and
+/// metric cannot change. Documents and indexes are untouched, so nothing
+/// has to be re-ingested.
+#[tracing::instrument(skip(service, update), fields(collection_id = %collection_id))]
+pub async fn update_collection(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(update): Json<CollectionUpdate>,
+) -> Result<Json<GetCollectionResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let collection = service
+        .update_collection(collection_id, update)
+        .await
+        .map_err(error_response)?;
+
+    let document_count = service.get_count(collection_id).await.unwrap_or(0) as u64;
+
+    Ok(Json(GetCollectionResponse {
+        collection: CollectionInfo::new(collection, document_count),
+    }))
+}
+
+/// GET /api/v1/collections/:id/search-defaults - Default search parameters
+pub async fn get_search_defaults(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<SearchDefaults>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let defaults = service
+        .search_defaults(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(defaults))
+}
+
+/// PUT /api/v1/collections/:id/search-defaults - Replace default search parameters
+///
+/// Searches of the collection use `top_k` and `min_score` when the request
+/// omits them; `ef_search` and `filter` apply to every search. An empty
+/// object clears the defaults.
+#[tracing::instrument(skip(service, defaults), fields(collection_id = %collection_id))]
+pub async fn update_search_defaults(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(defaults): Json<SearchDefaults>,
+) -> Result<Json<SearchDefaults>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let defaults = service
+        .set_search_defaults(collection_id, defaults)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(defaults))
+}
+
+/// Read-only mode of a collection
+#[derive(Serialize, Deserialize)]
+pub struct ReadOnlyMode {
+    read_only: bool,
+}
+
+/// GET /api/v1/collections/:id/read-only - Whether writes are refused
+pub async fn get_read_only(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ReadOnlyMode>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    service
+        .get_collection(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(ReadOnlyMode {
+        read_only: service.is_read_only(collection_id).await,
+    }))
+}
+
+/// PUT /api/v1/collections/:id/read-only - Freeze or unfreeze a collection
+///
+/// While read-only, writes to the collection (inserts, updates, deletes and
+/// deleting the collection) fail with 409; searches and reads are served as
+/// usual. Use it to freeze a collection during a migration or an incident
+/// investigation. The mode is kept in memory and ends with a restart.
+#[tracing::instrument(skip(service, mode), fields(collection_id = %collection_id, read_only = mode.read_only))]
+pub async fn set_read_only(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(mode): Json<ReadOnlyMode>,
+) -> Result<Json<ReadOnlyMode>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    service
+        .set_read_only(collection_id, mode.read_only)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(mode))
+}
+
+/// Create field index request
+#[derive(Deserialize)]
+pub struct CreateFieldIndexRequest {
+    /// Top-level metadata field, e.g. "user_id"
+    field: String,
+    /// Value type: `keyword`, `integer`, `float` or `bool`
+    field_type: FieldIndexType,
+}
+
+/// List field indexes response
+#[derive(Serialize)]
+pub struct ListFieldIndexesResponse {
+    field_indexes: Vec<FieldIndexInfo>,
+}
+
+/// POST /api/v1/collections/:id/field-indexes - Index a metadata field
+///
+/// `$eq` and `$in` filters on an indexed field select their documents from
+/// the index instead of checking every document. Indexes are kept in memory
+/// and are recreated after a restart.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, field = %req.field))]
+pub async fn create_field_index(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<CreateFieldIndexRequest>,
+) -> Result<(StatusCode, Json<FieldIndexInfo>), (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let info = service
+        .create_field_index(collection_id, &req.field, req.field_type)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(info)))
+}
+
+/// GET /api/v1/collections/:id/field-indexes - List a collection's field indexes
+pub async fn list_field_indexes(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ListFieldIndexesResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let field_indexes = service
+        .list_field_indexes(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(ListFieldIndexesResponse { field_indexes }))
+}
+
+/// DELETE /api/v1/collections/:id/field-indexes/:field - Drop a field index
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id, field = %field))]
+pub async fn delete_field_index(
+    Path((collection_id, field)): Path<(String, String)>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    service
+        .delete_field_index(collection_id, &field)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// GET /api/v1/collections/:id/sparse-index - Sparse index settings
+pub async fn get_sparse_index(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<SparseIndexConfig>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let config = service
+        .sparse_index_config(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    config.map(Json).ok_or_else(|| {
+        (
+            StatusCode::NOT_FOUND,
+            format!("Collection {} has no sparse index", collection_id),
+        )
+    })
+}
+
+/// PUT /api/v1/collections/:id/sparse-index - Create or reconfigure the sparse index
+///
+/// Documents written with a `sparse_vector` store it in this index; queries
+/// with a `sparse_vector` search it. Stored vectors must satisfy new settings.
+#[tracing::instrument(skip(service, config), fields(collection_id = %collection_id))]
+pub async fn update_sparse_index(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(config): Json<SparseIndexConfig>,
+) -> Result<Json<SparseIndexConfig>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let config = service
+        .set_sparse_index(collection_id, config)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(config))
+}
+
+/// DELETE /api/v1/collections/:id/sparse-index - Drop the sparse index and its vectors
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn delete_sparse_index(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    service
+        .drop_sparse_index(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// GET /api/v1/collections/:id/named-vectors - Named vector spaces
+pub async fn get_named_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<BTreeMap<String, NamedVectorConfig>>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let configs = service
+        .named_vector_configs(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(configs))
+}
+
+/// PUT /api/v1/collections/:id/named-vectors - Replace the named vector spaces
+///
+/// Spaces left out are dropped with their stored vectors; a space holding
+/// vectors cannot change its dimension or metric.
+#[tracing::instrument(skip(service, configs), fields(collection_id = %collection_id))]
+pub async fn update_named_vectors(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(configs): Json<BTreeMap<String, NamedVectorConfig>>,
+) -> Result<Json<BTreeMap<String, NamedVectorConfig>>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let configs = service
+        .set_named_vector_configs(collection_id, configs)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(configs))
+}
+
+#[derive(Deserialize)]
+pub struct ReindexRequest {
+    /// Alias to swap to the new collection (must be unset or point at this collection)
+    alias: String,
+    /// Name of the new collection
+    target_name: String,
+    /// New dimension (defaults to the source's, or the truncated dimension)
+    #[serde(default)]
+    dimension: Option<u32>,
+    /// New metric (defaults to the source's)
+    #[serde(default)]
+    metric: Option<DistanceMetric>,
+    #[serde(default)]
+    embedding_model: Option<String>,
+    /// Declarative transforms applied to each document during the copy
+    #[serde(default)]
+    transforms: TransformSpec,
+    /// Delete this collection after the alias swap
+    #[serde(default)]
+    delete_source: bool,
+}
+
+#[derive(Serialize)]
+pub struct ReindexResponse {
+    alias: String,
+    source_collection_id: String,
+    target_collection_id: String,
+    copied: usize,
+    skipped: usize,
+    source_deleted: bool,
+    duration_ms: f64,
+}
+
+/// POST /api/v1/collections/:id/reindex - Copy into a new collection and swap an alias
+///
+/// Copies every document (applying `transforms`) into a new collection,
+/// validates document counts, then atomically points `alias` at it.
+/// On failure the new collection is removed and the alias is unchanged.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, alias = %req.alias))]
+pub async fn reindex_collection(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<ReindexRequest>,
+) -> Result<Json<ReindexResponse>, (StatusCode, String)> {
+    let collection_id = CollectionId::from_str(&collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })?;
+
+    let mut plan = ReindexPlan::new(req.alias, collection_id, req.target_name);
+    if let Some(dimension) = req.dimension {
+        plan = plan.with_dimension(dimension);
+    }
+    if let Some(metric) = req.metric {
+        plan = plan.with_metric(metric);
+    }
+    if let Some(embedding_model) = req.embedding_model {
+        plan = plan.with_embedding_model(embedding_model);
+    }
+    if !req.transforms.is_empty() {
+        plan = plan.with_transforms(req.transforms);
+    }
+    if req.delete_source {
+        plan = plan.delete_source_after_swap();
+    }
+
+    let report = service.reindex(plan).await.map_err(error_response)?;
+
+    Ok(Json(ReindexResponse {
+        alias: report.alias,
+        source_collection_id: report.source.to_string(),
+        target_collection_id: report.target.to_string(),
+        copied: report.copied,
+        skipped: report.skipped,
+        source_deleted: report.source_deleted,
+        duration_ms: report.duration.as_secs_f64() * 1000.0,
+    }))
+}
+
 /// GET /metrics - Prometheus metrics endpoint
 ///
 /// Returns metrics in Prometheus text format for scraping.
@@ -1,15 +1,129 @@
 pub mod admin;
+pub mod aliases;
+pub mod allowlists;
+pub mod anomalies;
+pub mod backfill;
+pub mod changes;
 pub mod collections;
+pub mod compliance;
 pub mod embedding;
+pub mod events;
 pub mod health; // Kubernetes health and readiness probes
+pub mod legal_holds;
 pub mod management;
+pub mod monitoring;
+pub mod replication;
+pub mod schedules;
+pub mod snapshots;
+mod sse;
+pub mod subscriptions;
+pub mod tenant;
+pub mod text;
 pub mod tier; // Phase 10 Week 3: Tier control endpoints
+pub mod transactions;
+pub mod uploads;
 
-pub use admin::{health_check, reset_circuit_breaker, retry_dlq};
-pub use collections::{delete_vector, get_vector, insert_vector, query_vectors};
+use akidb_core::{CollectionId, CoreError};
+use axum::http::StatusCode;
+use std::str::FromStr;
+
+/// Parse a collection ID path segment, answering 400 when invalid.
+pub(crate) fn parse_collection_id(
+    collection_id: &str,
+) -> Result<CollectionId, (StatusCode, String)> {
+    CollectionId::from_str(collection_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid collection_id: {}", e),
+        )
+    })
+}
+
+/// Map a service error to its HTTP status.
+pub(crate) fn error_response(e: CoreError) -> (StatusCode, String) {
+    match e {
+        CoreError::NotFound { .. } => (StatusCode::NOT_FOUND, e.to_string()),
+        CoreError::ValidationError(_) | CoreError::QuotaExceeded { .. } => {
+            (StatusCode::BAD_REQUEST, e.to_string())
+        }
+        CoreError::AlreadyExists { .. }
+        | CoreError::InvalidState { .. }
+        | CoreError::ReadOnly { .. }
+        | CoreError::UnderLegalHold { .. } => (StatusCode::CONFLICT, e.to_string()),
+        _ => (StatusCode::INTERNAL_SERVER_ERROR, e.to_string()),
+    }
+}
+
+pub use admin::{
+    batch_create_tenants, delete_tenant, get_tenant_usage_history, health_check,
+    list_impersonations, list_tenants, reset_circuit_breaker, resume_tenant, retry_dlq,
+    rotate_api_key, suspend_tenant, undelete_tenant,
+};
+pub use aliases::{delete_alias, get_alias, list_aliases, set_alias};
+pub use allowlists::{
+    check_ip_allowlist, delete_key_ip_allowlist, delete_tenant_ip_allowlist, get_key_ip_allowlist,
+    get_tenant_ip_allowlist, list_ip_allowlists, update_key_ip_allowlist,
+    update_tenant_ip_allowlist,
+};
+pub use anomalies::{anomaly_events, list_anomalies};
+pub use backfill::{
+    backfill_progress_events, cancel_backfill, create_backfill_job, get_backfill_progress,
+    list_backfill_jobs, next_backfill_batch, patch_backfill, resume_backfill,
+};
+pub use changes::change_stream;
+pub use collections::{
+    count_vectors, delete_vector, delete_vectors, export_vectors, fetch_vectors, get_vector,
+    insert_batch, insert_vector, lookup_vectors, query_batch, query_parents, query_vectors,
+    recommend_vectors, scroll_vectors, update_metadata, update_metadata_batch, upsert_batch,
+    upsert_vector,
+};
+pub use compliance::{
+    create_compliance_job, download_compliance_export, get_compliance_job, list_compliance_jobs,
+    verify_compliance_report,
+};
 pub use embedding::{embed_handler, AppState as EmbeddingAppState};
+pub use events::{list_events, service_events};
 pub use health::{health_handler, ready_handler};
+pub use legal_holds::{get_legal_hold, list_legal_holds, place_legal_hold, release_legal_hold};
 pub use management::{
-    create_collection, delete_collection, get_collection, list_collections, metrics,
+    clone_collection, create_collection, create_field_index, delete_collection, delete_field_index,
+    delete_sparse_index, get_collection, get_named_vectors, get_read_only, get_search_defaults,
+    get_sparse_index, list_collections, list_field_indexes, metrics, reindex_collection,
+    rename_collection, set_read_only, update_collection, update_named_vectors,
+    update_search_defaults, update_sparse_index,
+};
+pub use monitoring::{
+    disable_adaptive_search, disable_drift_monitoring, enable_adaptive_search,
+    enable_drift_monitoring, estimate_import_cost, estimate_search_cost, get_adaptive_search,
+    get_capacity_forecast, get_drift_report, get_growth_forecast, get_growth_history,
+    get_query_patterns, prime_collection,
+};
+pub use replication::{
+    apply_replicated, configure_replication, delete_replication, get_replication_status,
+    list_replication,
+};
+pub use schedules::{
+    create_scheduled_job, delete_scheduled_job, get_scheduled_job, list_scheduled_jobs,
+    run_scheduled_job, update_scheduled_job,
+};
+pub use snapshots::{
+    create_snapshot, delete_snapshot, download_snapshot, get_snapshot, list_collection_snapshots,
+    list_snapshots, restore_snapshot,
+};
+pub use subscriptions::{
+    create_subscription, delete_subscription, get_subscription, list_subscriptions, subscription_ws,
+};
+pub use tenant::{get_collection_policy, get_usage_report, update_collection_policy, whoami};
+pub use text::{
+    analyze_text, delete_field_analyzer, get_analysis, get_idf_stats, update_field_analyzer,
+    update_stopwords, update_synonyms,
 };
 pub use tier::{get_collection_tier, get_tier_metrics, update_collection_tier};
+pub use transactions::{
+    begin_transaction, commit_transaction, get_transaction, rollback_transaction,
+    transaction_delete, transaction_insert,
+};
+pub use uploads::{
+    abort_upload, complete_upload, create_upload, create_upload_url, get_upload,
+    import_progress_events, list_uploads, upload_part,
+};
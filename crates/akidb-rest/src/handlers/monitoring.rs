@@ -0,0 +1,359 @@
+//! Collection monitoring API handlers
+//!
+//! - PUT /collections/{id}/drift - Enable drift monitoring (baseline = current stored vectors)
+//! - GET /collections/{id}/drift - Get the drift report
+//! - DELETE /collections/{id}/drift - Disable drift monitoring
+//! - PUT /collections/{id}/adaptive-search - Let ef_search follow a latency SLO
+//! - GET /collections/{id}/adaptive-search - Get the current ef_search and p95 latency
+//! - DELETE /collections/{id}/adaptive-search - Disable adaptive search
+//! - GET /collections/{id}/growth - Get the size history
+//! - GET /collections/{id}/forecast - Forecast a collection's growth
+//! - GET /capacity/forecast - Forecast the growth of all collections on this node
+//! - POST /collections/{id}/cost/search - Estimate the cost of searches
+//! - POST /collections/{id}/cost/import - Estimate the cost of an import
+//! - GET /collections/{id}/query-patterns - Get the most frequent recent queries
+//! - POST /collections/{id}/prime - Replay query patterns to warm the collection
+
+use akidb_service::{
+    AdaptiveSearchConfig, AdaptiveSearchStatus, CollectionService, DriftConfig, DriftReport,
+    ForecastLimits, GrowthForecast, GrowthSample, ImportCostEstimate, PrimeReport, QueryPattern,
+    SearchCostEstimate, VectorStats,
+};
+use axum::{
+    extract::{Path, Query, State},
+    http::StatusCode,
+    Json,
+};
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+
+use super::{error_response, parse_collection_id};
+
+/// Enable drift monitoring response
+#[derive(Serialize)]
+pub struct EnableDriftResponse {
+    pub config: DriftConfig,
+    /// Stored vector stats captured as the baseline
+    pub baseline: VectorStats,
+}
+
+/// Growth history response
+#[derive(Serialize)]
+pub struct GrowthHistoryResponse {
+    pub collection_id: String,
+    /// Size samples, oldest first
+    pub samples: Vec<GrowthSample>,
+}
+
+/// Query parameters for forecasts
+#[derive(Deserialize)]
+pub struct ForecastParams {
+    /// Days to project ahead (default: 30)
+    #[serde(default = "default_horizon_days")]
+    pub horizon_days: f64,
+    /// Vector quota to forecast against
+    pub max_vectors: Option<u64>,
+    /// Memory limit in bytes to forecast against
+    pub memory_limit_bytes: Option<u64>,
+}
+
+impl ForecastParams {
+    fn limits(&self) -> ForecastLimits {
+        ForecastLimits {
+            max_vectors: self.max_vectors,
+            memory_bytes: self.memory_limit_bytes,
+        }
+    }
+}
+
+fn default_horizon_days() -> f64 {
+    30.0
+}
+
+/// Search cost estimate request
+#[derive(Deserialize)]
+pub struct EstimateSearchCostRequest {
+    /// Results per query
+    pub top_k: usize,
+    /// Number of queries (default: 1)
+    #[serde(default = "default_queries")]
+    pub queries: u64,
+}
+
+fn default_queries() -> u64 {
+    1
+}
+
+/// Import cost estimate request
+#[derive(Deserialize)]
+pub struct EstimateImportCostRequest {
+    /// Number of vectors to import
+    pub vectors: u64,
+    /// Average serialized metadata size per vector (default: 0)
+    #[serde(default)]
+    pub avg_metadata_bytes: u64,
+}
+
+/// Query parameters for query patterns
+#[derive(Deserialize)]
+pub struct QueryPatternsParams {
+    /// Maximum number of patterns (default: 100)
+    #[serde(default = "default_prime_limit")]
+    pub limit: usize,
+}
+
+/// Query patterns response
+#[derive(Serialize)]
+pub struct QueryPatternsResponse {
+    /// Most frequent first
+    pub patterns: Vec<QueryPattern>,
+}
+
+/// Prime request
+#[derive(Deserialize)]
+pub struct PrimeRequest {
+    /// Patterns to replay, e.g. saved before a restart (default: the query log)
+    #[serde(default)]
+    pub patterns: Option<Vec<QueryPattern>>,
+    /// Maximum number of patterns taken from the query log (default: 100)
+    #[serde(default = "default_prime_limit")]
+    pub limit: usize,
+}
+
+fn default_prime_limit() -> usize {
+    100
+}
+
+/// Enable drift monitoring
+///
+/// Starts sampling query vectors; replaces any existing monitor.
+#[tracing::instrument(skip(service, config), fields(collection_id = %collection_id))]
+pub async fn enable_drift_monitoring(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(config): Json<DriftConfig>,
+) -> Result<Json<EnableDriftResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let baseline = service
+        .enable_drift_monitoring(collection_id, config.clone())
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(EnableDriftResponse { config, baseline }))
+}
+
+/// Get the drift report
+///
+/// Compares recent query vectors with stored vectors, and stored vectors with
+/// the baseline captured when monitoring was enabled.
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn get_drift_report(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<DriftReport>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let report = service
+        .drift_report(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(report))
+}
+
+/// Disable drift monitoring
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn disable_drift_monitoring(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    service
+        .disable_drift_monitoring(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// Enable adaptive search
+///
+/// Lowers the collection's ef_search while the p95 search latency breaches
+/// the SLO and raises it when there is headroom.
+#[tracing::instrument(skip(service, config), fields(collection_id = %collection_id))]
+pub async fn enable_adaptive_search(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(config): Json<AdaptiveSearchConfig>,
+) -> Result<Json<AdaptiveSearchStatus>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let status = service
+        .enable_adaptive_search(collection_id, config)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(status))
+}
+
+/// Get the current operating point of adaptive search
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn get_adaptive_search(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<AdaptiveSearchStatus>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let status = service
+        .adaptive_search_status(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(status))
+}
+
+/// Disable adaptive search
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn disable_adaptive_search(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    service
+        .disable_adaptive_search(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// Get a collection's size history
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn get_growth_history(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<GrowthHistoryResponse>, (StatusCode, String)> {
+    let id = parse_collection_id(&collection_id)?;
+
+    let samples = service.growth_history(id).await.map_err(error_response)?;
+
+    Ok(Json(GrowthHistoryResponse {
+        collection_id,
+        samples,
+    }))
+}
+
+/// Forecast a collection's growth
+///
+/// Fits a linear trend to the size history and projects it `horizon_days` ahead,
+/// including the days until the given vector quota or memory limit is reached.
+#[tracing::instrument(skip(service, params), fields(collection_id = %collection_id))]
+pub async fn get_growth_forecast(
+    Path(collection_id): Path<String>,
+    Query(params): Query<ForecastParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<GrowthForecast>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let forecast = service
+        .forecast_growth(collection_id, params.horizon_days, params.limits())
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(forecast))
+}
+
+/// Forecast the combined growth of all collections on this node
+#[tracing::instrument(skip(service, params))]
+pub async fn get_capacity_forecast(
+    Query(params): Query<ForecastParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<GrowthForecast>, (StatusCode, String)> {
+    let forecast = service
+        .forecast_capacity(params.horizon_days, params.limits())
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(forecast))
+}
+
+/// Estimate the cost of searches before running them
+///
+/// Returns expected compute units (distance computations) and read units.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn estimate_search_cost(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<EstimateSearchCostRequest>,
+) -> Result<Json<SearchCostEstimate>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let estimate = service
+        .estimate_search_cost(collection_id, req.top_k, req.queries)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(estimate))
+}
+
+/// Estimate the cost of an import before running it
+///
+/// Returns expected compute units (index construction) and write units.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn estimate_import_cost(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<EstimateImportCostRequest>,
+) -> Result<Json<ImportCostEstimate>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let estimate = service
+        .estimate_import_cost(collection_id, req.vectors, req.avg_metadata_bytes)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(estimate))
+}
+
+/// Get a collection's most frequent recent query patterns
+///
+/// Save them before a deploy or restore and pass them to `prime` afterwards:
+/// the query log starts empty on a new server.
+#[tracing::instrument(skip(service, params), fields(collection_id = %collection_id))]
+pub async fn get_query_patterns(
+    Path(collection_id): Path<String>,
+    Query(params): Query<QueryPatternsParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<QueryPatternsResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let patterns = service
+        .query_patterns(collection_id, params.limit)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(QueryPatternsResponse { patterns }))
+}
+
+/// Replay query patterns to warm a collection
+///
+/// Loads the tier and index pages recent queries touch, so the first queries
+/// after a deploy or restore do not pay for cold reads.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn prime_collection(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<PrimeRequest>,
+) -> Result<Json<PrimeReport>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let report = service
+        .prime(collection_id, req.patterns, req.limit)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(report))
+}
@@ -0,0 +1,114 @@
+//! Cross-region replication API handlers
+//!
+//! A replicated collection ships its writes to a collection on another
+//! region's cluster, which receives them through the apply endpoint:
+//! - GET /collections/{id}/replication - Replication status and health
+//! - PUT /collections/{id}/replication - Set the target region, endpoint and lag SLO
+//! - DELETE /collections/{id}/replication - Stop replicating
+//! - GET /replication - Status of every replicated collection
+//! - POST /collections/{id}/replication/apply - Apply writes shipped from another region
+
+use akidb_service::{CollectionService, ReplicationConfig, ReplicationOp, ReplicationStatus};
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    Json,
+};
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+
+use super::{error_response, parse_collection_id};
+
+/// List replication response
+#[derive(Serialize)]
+pub struct ListReplicationResponse {
+    pub replications: Vec<ReplicationStatus>,
+}
+
+/// Apply replicated writes request
+#[derive(Deserialize)]
+pub struct ApplyReplicatedRequest {
+    pub ops: Vec<ReplicationOp>,
+}
+
+/// Apply replicated writes response
+#[derive(Serialize)]
+pub struct ApplyReplicatedResponse {
+    pub applied: usize,
+}
+
+/// Get a collection's replication status
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn get_replication_status(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ReplicationStatus>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let status = service
+        .replication_status(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(status))
+}
+
+/// Replicate a collection to another region
+#[tracing::instrument(skip(service, config), fields(collection_id = %collection_id))]
+pub async fn configure_replication(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(config): Json<ReplicationConfig>,
+) -> Result<Json<ReplicationStatus>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let status = service
+        .configure_replication(collection_id, config)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(status))
+}
+
+/// Stop replicating a collection
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn delete_replication(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    service
+        .remove_replication(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// List the replication status of every replicated collection
+#[tracing::instrument(skip(service))]
+pub async fn list_replication(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListReplicationResponse> {
+    Json(ListReplicationResponse {
+        replications: service.list_replication_statuses(),
+    })
+}
+
+/// Apply writes shipped from another region
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id, ops = req.ops.len()))]
+pub async fn apply_replicated(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<ApplyReplicatedRequest>,
+) -> Result<Json<ApplyReplicatedResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let applied = service
+        .apply_replicated(collection_id, req.ops)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(ApplyReplicatedResponse { applied }))
+}
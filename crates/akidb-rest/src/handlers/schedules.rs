@@ -0,0 +1,124 @@
+//! Scheduled job API handlers
+//!
+//! Housekeeping actions run on a cron schedule (UTC):
+//! - POST /scheduled-jobs - Create a job
+//! - GET /scheduled-jobs - List jobs
+//! - GET /scheduled-jobs/{job_id} - Get a job and its recent runs
+//! - PUT /scheduled-jobs/{job_id} - Replace a job's definition
+//! - DELETE /scheduled-jobs/{job_id} - Delete a job
+//! - POST /scheduled-jobs/{job_id}/run - Run a job now
+
+use akidb_core::JobId;
+use akidb_service::{CollectionService, JobRun, ScheduledJob, ScheduledJobSpec};
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    Json,
+};
+use serde::Serialize;
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::error_response;
+
+/// List scheduled jobs response
+#[derive(Serialize)]
+pub struct ListScheduledJobsResponse {
+    pub jobs: Vec<ScheduledJob>,
+}
+
+fn parse_job_id(job_id: &str) -> Result<JobId, (StatusCode, String)> {
+    JobId::from_str(job_id).map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid job_id: {}", e)))
+}
+
+/// Create a scheduled job
+#[tracing::instrument(skip(service, spec))]
+pub async fn create_scheduled_job(
+    State(service): State<Arc<CollectionService>>,
+    Json(spec): Json<ScheduledJobSpec>,
+) -> Result<(StatusCode, Json<ScheduledJob>), (StatusCode, String)> {
+    let job = service
+        .create_scheduled_job(spec)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(job)))
+}
+
+/// List scheduled jobs
+#[tracing::instrument(skip(service))]
+pub async fn list_scheduled_jobs(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListScheduledJobsResponse> {
+    Json(ListScheduledJobsResponse {
+        jobs: service.list_scheduled_jobs().await,
+    })
+}
+
+/// Get a scheduled job, including its last runs
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn get_scheduled_job(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ScheduledJob>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let job = service
+        .get_scheduled_job(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(job))
+}
+
+/// Replace a scheduled job's definition (its history is kept)
+#[tracing::instrument(skip(service, spec), fields(job_id = %job_id))]
+pub async fn update_scheduled_job(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(spec): Json<ScheduledJobSpec>,
+) -> Result<Json<ScheduledJob>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let job = service
+        .update_scheduled_job(job_id, spec)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(job))
+}
+
+/// Delete a scheduled job
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn delete_scheduled_job(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    service
+        .delete_scheduled_job(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// Run a scheduled job now
+///
+/// A failed run is reported in the returned run (and the job's history), not
+/// as an error status.
+#[tracing::instrument(skip(service), fields(job_id = %job_id))]
+pub async fn run_scheduled_job(
+    Path(job_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<JobRun>, (StatusCode, String)> {
+    let job_id = parse_job_id(&job_id)?;
+
+    let run = service
+        .run_scheduled_job(job_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(run))
+}
@@ -0,0 +1,203 @@
+//! Collection snapshot API handlers
+//!
+//! Backups for disaster recovery drills and environment cloning:
+//! - POST /collections/{id}/snapshots - Snapshot a collection
+//! - GET /collections/{id}/snapshots - List a collection's snapshots
+//! - GET /snapshots - List all snapshots (including those of deleted collections)
+//! - GET /snapshots/{snapshot_id} - Get a snapshot
+//! - GET /snapshots/{snapshot_id}/download - Download the snapshot's NDJSON file
+//! - POST /snapshots/{snapshot_id}/restore - Restore a snapshot into a new collection
+//! - DELETE /snapshots/{snapshot_id} - Delete a snapshot
+
+use akidb_core::SnapshotId;
+use akidb_service::{CollectionService, CollectionSnapshot, RestoreReport};
+use axum::{
+    body::{Bytes, StreamBody},
+    extract::{Path, State},
+    http::{header, StatusCode},
+    response::IntoResponse,
+    Json,
+};
+use futures::stream;
+use serde::{Deserialize, Serialize};
+use std::str::FromStr;
+use std::sync::Arc;
+use tokio::io::AsyncReadExt;
+
+use super::{error_response, parse_collection_id};
+
+/// Bytes read from the snapshot file per chunk of a download.
+const DOWNLOAD_CHUNK_BYTES: usize = 64 * 1024;
+
+/// List snapshots response
+#[derive(Serialize)]
+pub struct ListSnapshotsResponse {
+    /// Oldest first
+    pub snapshots: Vec<CollectionSnapshot>,
+}
+
+/// Restore request
+#[derive(Deserialize, Default)]
+pub struct RestoreSnapshotRequest {
+    /// Name of the new collection (default: the snapshot's collection name
+    /// and time, e.g. `docs-20260101-120000`)
+    #[serde(default)]
+    pub name: Option<String>,
+}
+
+fn parse_snapshot_id(snapshot_id: &str) -> Result<SnapshotId, (StatusCode, String)> {
+    SnapshotId::from_str(snapshot_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid snapshot_id: {}", e),
+        )
+    })
+}
+
+/// Snapshot a collection
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn create_snapshot(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<(StatusCode, Json<CollectionSnapshot>), (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let snapshot = service
+        .create_snapshot(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(snapshot)))
+}
+
+/// List a collection's snapshots
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn list_collection_snapshots(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ListSnapshotsResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    Ok(Json(ListSnapshotsResponse {
+        snapshots: service.list_snapshots(Some(collection_id)).await,
+    }))
+}
+
+/// List all snapshots
+#[tracing::instrument(skip(service))]
+pub async fn list_snapshots(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<ListSnapshotsResponse> {
+    Json(ListSnapshotsResponse {
+        snapshots: service.list_snapshots(None).await,
+    })
+}
+
+/// Get a snapshot
+#[tracing::instrument(skip(service), fields(snapshot_id = %snapshot_id))]
+pub async fn get_snapshot(
+    Path(snapshot_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<CollectionSnapshot>, (StatusCode, String)> {
+    let snapshot_id = parse_snapshot_id(&snapshot_id)?;
+
+    let snapshot = service
+        .get_snapshot(snapshot_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(snapshot))
+}
+
+/// Download a snapshot's NDJSON file
+///
+/// The file is streamed; its size and SHA-256 are in the snapshot manifest.
+#[tracing::instrument(skip(service), fields(snapshot_id = %snapshot_id))]
+pub async fn download_snapshot(
+    Path(snapshot_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<impl IntoResponse, (StatusCode, String)> {
+    let snapshot_id = parse_snapshot_id(&snapshot_id)?;
+
+    let path = service
+        .snapshot_file(snapshot_id)
+        .await
+        .map_err(error_response)?;
+    let file = tokio::fs::File::open(&path).await.map_err(|e| {
+        (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            format!("Failed to open snapshot: {}", e),
+        )
+    })?;
+
+    let chunks = stream::unfold(Some(file), |file| async move {
+        let mut file = file?;
+        let mut buf = vec![0u8; DOWNLOAD_CHUNK_BYTES];
+        match file.read(&mut buf).await {
+            Ok(0) => None,
+            Ok(n) => {
+                buf.truncate(n);
+                Some((Ok(Bytes::from(buf)), Some(file)))
+            }
+            // Ends the stream after the error
+            Err(e) => Some((Err(e), None)),
+        }
+    });
+
+    Ok((
+        [
+            (header::CONTENT_TYPE, "application/x-ndjson".to_string()),
+            (
+                header::CONTENT_DISPOSITION,
+                format!("attachment; filename=\"{}.ndjson\"", snapshot_id),
+            ),
+        ],
+        StreamBody::new(chunks),
+    ))
+}
+
+/// Restore a snapshot into a new collection
+///
+/// The collection the snapshot was taken of is left as it is; switch an alias
+/// to the restored collection to serve it in its place.
+#[tracing::instrument(skip(service, body), fields(snapshot_id = %snapshot_id))]
+pub async fn restore_snapshot(
+    Path(snapshot_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    body: Bytes,
+) -> Result<(StatusCode, Json<RestoreReport>), (StatusCode, String)> {
+    let snapshot_id = parse_snapshot_id(&snapshot_id)?;
+    let req: RestoreSnapshotRequest = if body.is_empty() {
+        RestoreSnapshotRequest::default()
+    } else {
+        serde_json::from_slice(&body).map_err(|e| {
+            (
+                StatusCode::BAD_REQUEST,
+                format!("Invalid request body: {}", e),
+            )
+        })?
+    };
+
+    let report = service
+        .restore_snapshot(snapshot_id, req.name)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(report)))
+}
+
+/// Delete a snapshot
+#[tracing::instrument(skip(service), fields(snapshot_id = %snapshot_id))]
+pub async fn delete_snapshot(
+    Path(snapshot_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let snapshot_id = parse_snapshot_id(&snapshot_id)?;
+
+    service
+        .delete_snapshot(snapshot_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
@@ -0,0 +1,87 @@
+//! Server-sent event streams
+//!
+//! A progress stream starts with a `progress` event holding the current state,
+//! followed by one `progress` event per update, and ends when the operation
+//! finishes. An alert stream sends one `anomaly` event per flagged access
+//! pattern. A subscriber too slow to keep up receives a `lagged` event with
+//! the number of updates it missed.
+//!
+//! A change stream sends one `change` event per write, with the event's
+//! cursor as its SSE `id`. A subscriber too slow to keep up is disconnected
+//! instead, and resumes from its last cursor when it reconnects.
+
+use akidb_service::{ChangeEvent, ChangeSubscription};
+use axum::response::sse::{Event, KeepAlive, Sse};
+use futures::stream::{self, Stream, StreamExt};
+use serde::Serialize;
+use std::convert::Infallible;
+use tokio::sync::broadcast::{error::RecvError, Receiver};
+
+/// Stream `current` followed by the updates from `updates`
+pub(crate) fn progress_events<T>(
+    current: T,
+    updates: Receiver<T>,
+) -> Sse<impl Stream<Item = Result<Event, Infallible>>>
+where
+    T: Serialize + Clone + Send + 'static,
+{
+    let events = stream::iter([Ok(json_event("progress", &current))])
+        .chain(updates_stream("progress", updates));
+
+    Sse::new(events).keep_alive(KeepAlive::default())
+}
+
+/// Stream each update from `updates` as an `event` event
+pub(crate) fn alert_events<T>(
+    event: &'static str,
+    updates: Receiver<T>,
+) -> Sse<impl Stream<Item = Result<Event, Infallible>>>
+where
+    T: Serialize + Clone + Send + 'static,
+{
+    Sse::new(updates_stream(event, updates)).keep_alive(KeepAlive::default())
+}
+
+fn updates_stream<T>(
+    event: &'static str,
+    updates: Receiver<T>,
+) -> impl Stream<Item = Result<Event, Infallible>>
+where
+    T: Serialize + Clone + Send + 'static,
+{
+    stream::unfold(updates, move |mut updates| async move {
+        let sse_event = match updates.recv().await {
+            Ok(update) => json_event(event, &update),
+            Err(RecvError::Lagged(missed)) => {
+                Event::default().event("lagged").data(missed.to_string())
+            }
+            Err(RecvError::Closed) => return None,
+        };
+        Some((Ok::<_, Infallible>(sse_event), updates))
+    })
+}
+
+/// Stream the backlog, then the updates, of a change stream subscription
+pub(crate) fn change_events(
+    subscription: ChangeSubscription,
+) -> Sse<impl Stream<Item = Result<Event, Infallible>>> {
+    let change_event =
+        |change: ChangeEvent| Ok::<_, Infallible>(json_event("change", &change).id(change.cursor));
+    let updates = stream::unfold(subscription.updates, |mut updates| async move {
+        // Lagging ends the stream: the subscriber resumes from its cursor
+        let change = updates.recv().await.ok()?;
+        Some((change, updates))
+    });
+    let events = stream::iter(subscription.backlog)
+        .chain(updates)
+        .map(change_event);
+
+    Sse::new(events).keep_alive(KeepAlive::default())
+}
+
+fn json_event<T: Serialize>(event: &str, data: &T) -> Event {
+    Event::default()
+        .event(event)
+        .json_data(data)
+        .unwrap_or_else(|e| Event::default().event("error").data(e.to_string()))
+}
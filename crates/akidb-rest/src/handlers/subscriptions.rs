@@ -0,0 +1,151 @@
+//! Standing query (continuous query subscription) API handlers
+//!
+//! - POST /collections/{id}/subscriptions - Register a standing query
+//! - GET /collections/{id}/subscriptions - List a collection's standing queries
+//! - GET /subscriptions/{subscription_id} - Get a standing query
+//! - DELETE /subscriptions/{subscription_id} - Delete a standing query
+//! - GET /subscriptions/{subscription_id}/ws - WebSocket stream of matches
+//!
+//! Each WebSocket text message is a JSON `StandingQueryMatch`. A subscriber
+//! too slow to keep up receives `{"lagged": n}` with the number of matches it
+//! missed. The server closes the socket when the standing query is deleted.
+
+use akidb_core::SubscriptionId;
+use akidb_service::{CollectionService, StandingQueryInfo, StandingQuerySpec};
+use axum::{
+    extract::{
+        ws::{Message, WebSocket, WebSocketUpgrade},
+        Path, State,
+    },
+    http::StatusCode,
+    response::Response,
+    Json,
+};
+use serde::Serialize;
+use std::str::FromStr;
+use std::sync::Arc;
+use tokio::sync::broadcast::{error::RecvError, Receiver};
+
+use super::{error_response, parse_collection_id};
+
+/// List standing queries response
+#[derive(Serialize)]
+pub struct ListSubscriptionsResponse {
+    pub subscriptions: Vec<StandingQueryInfo>,
+}
+
+fn parse_subscription_id(subscription_id: &str) -> Result<SubscriptionId, (StatusCode, String)> {
+    SubscriptionId::from_str(subscription_id).map_err(|e| {
+        (
+            StatusCode::BAD_REQUEST,
+            format!("Invalid subscription_id: {}", e),
+        )
+    })
+}
+
+/// Register a standing query
+#[tracing::instrument(skip(service, spec), fields(collection_id = %collection_id))]
+pub async fn create_subscription(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(spec): Json<StandingQuerySpec>,
+) -> Result<(StatusCode, Json<StandingQueryInfo>), (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let info = service
+        .create_standing_query(collection_id, spec)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(info)))
+}
+
+/// List a collection's standing queries
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn list_subscriptions(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ListSubscriptionsResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    Ok(Json(ListSubscriptionsResponse {
+        subscriptions: service.list_standing_queries(Some(collection_id)).await,
+    }))
+}
+
+/// Get a standing query
+#[tracing::instrument(skip(service), fields(subscription_id = %subscription_id))]
+pub async fn get_subscription(
+    Path(subscription_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<StandingQueryInfo>, (StatusCode, String)> {
+    let subscription_id = parse_subscription_id(&subscription_id)?;
+
+    let info = service
+        .get_standing_query(subscription_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(info))
+}
+
+/// Delete a standing query
+#[tracing::instrument(skip(service), fields(subscription_id = %subscription_id))]
+pub async fn delete_subscription(
+    Path(subscription_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let subscription_id = parse_subscription_id(&subscription_id)?;
+
+    service
+        .delete_standing_query(subscription_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// Stream a standing query's matches over a WebSocket
+#[tracing::instrument(skip(service, ws), fields(subscription_id = %subscription_id))]
+pub async fn subscription_ws(
+    Path(subscription_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    ws: WebSocketUpgrade,
+) -> Result<Response, (StatusCode, String)> {
+    let subscription_id = parse_subscription_id(&subscription_id)?;
+
+    // Subscribe before upgrading so an unknown ID is a plain 404
+    let matches = service
+        .subscribe_standing_query(subscription_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(ws.on_upgrade(move |socket| forward_matches(socket, matches)))
+}
+
+async fn forward_matches<T: Serialize + Clone>(mut socket: WebSocket, mut matches: Receiver<T>) {
+    loop {
+        tokio::select! {
+            received = matches.recv() => {
+                let text = match received {
+                    Ok(matched) => serde_json::to_string(&matched),
+                    Err(RecvError::Lagged(missed)) => {
+                        serde_json::to_string(&serde_json::json!({ "lagged": missed }))
+                    }
+                    // Standing query deleted
+                    Err(RecvError::Closed) => break,
+                };
+                let Ok(text) = text else { continue };
+                if socket.send(Message::Text(text)).await.is_err() {
+                    return;
+                }
+            }
+            incoming = socket.recv() => match incoming {
+                // Client messages (pings are answered by axum) are ignored
+                Some(Ok(Message::Close(_))) | Some(Err(_)) | None => return,
+                Some(Ok(_)) => {}
+            },
+        }
+    }
+    let _ = socket.send(Message::Close(None)).await;
+}
@@ -0,0 +1,86 @@
+//! Tenant collection policy API handlers
+//!
+//! - GET /tenant/collection-policy - Get collection defaults and hard limits
+//! - PUT /tenant/collection-policy - Replace collection defaults and hard limits
+//! - GET /tenant/usage-reports/{month} - Download a monthly usage report (CSV)
+//! - GET /whoami - Describe the caller and its permissions
+
+use akidb_core::CollectionPolicy;
+use akidb_service::{CollectionService, Identity, ImpersonationRecord, UsageMonth};
+use axum::{
+    extract::{Extension, Path, State},
+    http::{header, StatusCode},
+    response::IntoResponse,
+    Json,
+};
+use std::str::FromStr;
+use std::sync::Arc;
+
+use super::error_response;
+
+/// Get the tenant's collection defaults and limits
+#[tracing::instrument(skip(service))]
+pub async fn get_collection_policy(
+    State(service): State<Arc<CollectionService>>,
+) -> Json<CollectionPolicy> {
+    Json(service.collection_policy().await)
+}
+
+/// Replace the tenant's collection defaults and limits
+///
+/// Limits are enforced on collection creation (dimension) and queries (top_k,
+/// filter clauses); existing collections are not changed.
+#[tracing::instrument(skip(service, policy))]
+pub async fn update_collection_policy(
+    State(service): State<Arc<CollectionService>>,
+    Json(policy): Json<CollectionPolicy>,
+) -> Result<Json<CollectionPolicy>, (StatusCode, String)> {
+    service
+        .set_collection_policy(policy.clone())
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(policy))
+}
+
+/// Download the usage report of a month (`YYYY-MM`, UTC) as CSV
+///
+/// One line per collection with its searches, ingestion and storage in the
+/// month, for the finance pipeline.
+#[tracing::instrument(skip(service))]
+pub async fn get_usage_report(
+    Path(month): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<impl IntoResponse, (StatusCode, String)> {
+    let month = UsageMonth::from_str(&month).map_err(error_response)?;
+
+    let report = service.usage_report(month).await.map_err(error_response)?;
+    let disposition = format!("attachment; filename=\"akidb-usage-{}.csv\"", month);
+
+    Ok((
+        [
+            (header::CONTENT_TYPE, "text/csv; charset=utf-8".to_string()),
+            (header::CONTENT_DISPOSITION, disposition),
+        ],
+        report.to_csv(),
+    ))
+}
+
+/// Describe the caller: principal, tenant, scopes, collection permissions
+/// and limits
+///
+/// Lets services check at startup that they were deployed with the right
+/// credentials.
+#[tracing::instrument(skip(service, impersonation))]
+pub async fn whoami(
+    State(service): State<Arc<CollectionService>>,
+    impersonation: Option<Extension<ImpersonationRecord>>,
+) -> Result<Json<Identity>, (StatusCode, String)> {
+    let impersonation = impersonation.map(|Extension(record)| record.request);
+    let identity = service
+        .whoami(impersonation.as_ref())
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(identity))
+}
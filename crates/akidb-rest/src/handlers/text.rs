@@ -0,0 +1,186 @@
+//! Text analysis API handlers
+//!
+//! Provides endpoints for text analysis settings and client-side sparse (BM25) encoding:
+//! - GET /collections/{id}/sparse/idf - Download IDF stats for a text field
+//! - GET /collections/{id}/analysis - Get analyzer settings
+//! - PUT /collections/{id}/analysis/stopwords - Replace the stopword list
+//! - PUT /collections/{id}/analysis/synonyms - Replace the synonym sets
+//! - PUT /collections/{id}/analysis/fields/{field} - Set a text field's analyzer
+//! - DELETE /collections/{id}/analysis/fields/{field} - Reset a text field's analyzer
+//! - POST /collections/{id}/analyze - Show how text tokenizes
+
+use akidb_service::{
+    AnalyzedToken, AnalyzerSettings, CollectionService, IdfStats, TextAnalysis, DEFAULT_TEXT_FIELD,
+};
+use axum::{
+    extract::{Path, Query, State},
+    http::StatusCode,
+    Json,
+};
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+
+use super::{error_response, parse_collection_id};
+
+/// Query parameters for IDF stats
+#[derive(Deserialize)]
+pub struct IdfStatsParams {
+    /// Metadata field holding document text (default: "text")
+    pub field: Option<String>,
+}
+
+/// Replace stopwords request
+#[derive(Deserialize)]
+pub struct StopwordsRequest {
+    pub stopwords: Vec<String>,
+}
+
+/// Replace synonyms request
+#[derive(Deserialize)]
+pub struct SynonymsRequest {
+    /// Synonym sets; every term in a set is indexed as the set's first term
+    pub synonyms: Vec<Vec<String>>,
+}
+
+/// Analyze request
+#[derive(Deserialize)]
+pub struct AnalyzeRequest {
+    pub text: String,
+    /// Text field whose analyzer to use (collection defaults if omitted)
+    #[serde(default)]
+    pub field: Option<String>,
+    /// Analyzer settings overriding the field's for this request
+    #[serde(default)]
+    pub analyzer: Option<AnalyzerSettings>,
+}
+
+/// Analyze response
+#[derive(Serialize)]
+pub struct AnalyzeResponse {
+    pub tokens: Vec<AnalyzedToken>,
+}
+
+/// Get IDF stats for a collection's text field
+///
+/// Returns the analyzer settings, document count, average document length and
+/// per-token document frequencies needed to build BM25 sparse vectors.
+#[tracing::instrument(skip(service, params), fields(collection_id = %collection_id))]
+pub async fn get_idf_stats(
+    Path(collection_id): Path<String>,
+    Query(params): Query<IdfStatsParams>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<IdfStats>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let field = params.field.as_deref().unwrap_or(DEFAULT_TEXT_FIELD);
+    let stats = service
+        .idf_stats(collection_id, field)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(stats))
+}
+
+/// Get analyzer settings (stopwords, synonyms, collection and per-field settings)
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn get_analysis(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TextAnalysis>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let analysis = service
+        .text_analysis(collection_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(analysis))
+}
+
+/// Replace the stopword list
+///
+/// Takes effect for subsequent analysis (IDF stats, analyze).
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn update_stopwords(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<StopwordsRequest>,
+) -> Result<Json<TextAnalysis>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let analysis = service
+        .set_stopwords(collection_id, req.stopwords)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(analysis))
+}
+
+/// Replace the synonym sets
+///
+/// Each set needs at least two single-token terms.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn update_synonyms(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<SynonymsRequest>,
+) -> Result<Json<TextAnalysis>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let analysis = service
+        .set_synonyms(collection_id, req.synonyms)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(analysis))
+}
+
+/// Set a text field's analyzer (language stemming, case folding, n-grams, CJK handling)
+#[tracing::instrument(skip(service, settings), fields(collection_id = %collection_id, field = %field))]
+pub async fn update_field_analyzer(
+    Path((collection_id, field)): Path<(String, String)>,
+    State(service): State<Arc<CollectionService>>,
+    Json(settings): Json<AnalyzerSettings>,
+) -> Result<Json<TextAnalysis>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let analysis = service
+        .set_field_analyzer(collection_id, &field, Some(settings))
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(analysis))
+}
+
+/// Reset a text field to the collection's default analyzer settings
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id, field = %field))]
+pub async fn delete_field_analyzer(
+    Path((collection_id, field)): Path<(String, String)>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TextAnalysis>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let analysis = service
+        .set_field_analyzer(collection_id, &field, None)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(analysis))
+}
+
+/// Show how a string tokenizes with the collection's analyzer
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn analyze_text(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<AnalyzeRequest>,
+) -> Result<Json<AnalyzeResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let tokens = service
+        .analyze(collection_id, req.field.as_deref(), &req.text, req.analyzer)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(AnalyzeResponse { tokens }))
+}
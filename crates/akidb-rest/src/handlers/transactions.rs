@@ -0,0 +1,176 @@
+//! Write transaction API handlers
+//!
+//! Buffer inserts and deletes across several calls, then apply them at once:
+//! - POST /transactions - Begin a transaction
+//! - GET /transactions/{tx_id} - Get an open transaction
+//! - POST /transactions/{tx_id}/collections/{id}/insert - Buffer an insert
+//! - DELETE /transactions/{tx_id}/collections/{id}/docs/{doc_id} - Buffer a delete
+//! - POST /transactions/{tx_id}/commit - Apply the buffered writes
+//! - POST /transactions/{tx_id}/rollback - Discard the buffered writes
+//!
+//! Buffered writes are invisible to readers until commit. A transaction not
+//! committed within its timeout expires and its writes are discarded.
+
+use akidb_core::{DocumentId, TransactionId, VectorDocument};
+use akidb_service::{CollectionService, CommitReport, TransactionInfo};
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    Json,
+};
+use serde::Deserialize;
+use std::str::FromStr;
+use std::sync::Arc;
+use std::time::Duration;
+
+use super::{error_response, parse_collection_id};
+
+/// Begin transaction request
+#[derive(Deserialize, Default)]
+pub struct BeginTransactionRequest {
+    /// Seconds before the transaction expires (default 60, max 900)
+    pub timeout_secs: Option<u64>,
+}
+
+/// Buffered insert request (same fields as a direct insert)
+#[derive(Deserialize)]
+pub struct TransactionInsertRequest {
+    pub doc_id: String,
+    pub external_id: Option<String>,
+    pub vector: Vec<f32>,
+    #[serde(default)]
+    pub metadata: Option<serde_json::Value>,
+}
+
+fn parse_transaction_id(tx_id: &str) -> Result<TransactionId, (StatusCode, String)> {
+    TransactionId::from_str(tx_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid tx_id: {}", e)))
+}
+
+fn parse_doc_id(doc_id: &str) -> Result<DocumentId, (StatusCode, String)> {
+    DocumentId::from_str(doc_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid doc_id: {}", e)))
+}
+
+/// Begin a write transaction
+#[tracing::instrument(skip(service, req))]
+pub async fn begin_transaction(
+    State(service): State<Arc<CollectionService>>,
+    req: Option<Json<BeginTransactionRequest>>,
+) -> Result<(StatusCode, Json<TransactionInfo>), (StatusCode, String)> {
+    let req = req.map(|Json(req)| req).unwrap_or_default();
+
+    let info = service
+        .begin_transaction(req.timeout_secs.map(Duration::from_secs))
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(info)))
+}
+
+/// Get an open transaction
+#[tracing::instrument(skip(service), fields(tx_id = %tx_id))]
+pub async fn get_transaction(
+    Path(tx_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TransactionInfo>, (StatusCode, String)> {
+    let tx_id = parse_transaction_id(&tx_id)?;
+
+    let info = service
+        .get_transaction(tx_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(info))
+}
+
+/// Buffer an insert in a transaction
+#[tracing::instrument(skip(service, req), fields(tx_id = %tx_id, collection_id = %collection_id, doc_id = %req.doc_id))]
+pub async fn transaction_insert(
+    Path((tx_id, collection_id)): Path<(String, String)>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<TransactionInsertRequest>,
+) -> Result<Json<TransactionInfo>, (StatusCode, String)> {
+    let tx_id = parse_transaction_id(&tx_id)?;
+    let collection_id = parse_collection_id(&collection_id)?;
+    let doc_id = parse_doc_id(&req.doc_id)?;
+
+    if req.vector.is_empty() {
+        return Err((
+            StatusCode::BAD_REQUEST,
+            "vector cannot be empty".to_string(),
+        ));
+    }
+
+    let mut doc = VectorDocument::new(doc_id, req.vector);
+    if let Some(external_id) = req.external_id {
+        doc = doc.with_external_id(external_id);
+    }
+    if let Some(metadata) = req.metadata {
+        doc = doc.with_metadata(metadata);
+    }
+
+    let info = service
+        .transaction_insert(tx_id, collection_id, doc)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(info))
+}
+
+/// Buffer a delete in a transaction
+#[tracing::instrument(skip(service), fields(tx_id = %tx_id, collection_id = %collection_id, doc_id = %doc_id))]
+pub async fn transaction_delete(
+    Path((tx_id, collection_id, doc_id)): Path<(String, String, String)>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<TransactionInfo>, (StatusCode, String)> {
+    let tx_id = parse_transaction_id(&tx_id)?;
+    let collection_id = parse_collection_id(&collection_id)?;
+    let doc_id = parse_doc_id(&doc_id)?;
+
+    let info = service
+        .transaction_delete(tx_id, collection_id, doc_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(info))
+}
+
+/// Commit a transaction
+///
+/// Every write is checked before any is applied. Fails with 404 if a
+/// buffered delete targets a missing document and 409 if the transaction
+/// expired or a write conflicts (e.g. an ID already stored or a legal hold);
+/// the transaction is closed and none of its writes are applied. A storage
+/// failure while applying the writes fails with 500 and can leave the first
+/// ones applied.
+#[tracing::instrument(skip(service), fields(tx_id = %tx_id))]
+pub async fn commit_transaction(
+    Path(tx_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<CommitReport>, (StatusCode, String)> {
+    let tx_id = parse_transaction_id(&tx_id)?;
+
+    let report = service
+        .commit_transaction(tx_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(report))
+}
+
+/// Roll back a transaction, discarding its buffered writes
+#[tracing::instrument(skip(service), fields(tx_id = %tx_id))]
+pub async fn rollback_transaction(
+    Path(tx_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let tx_id = parse_transaction_id(&tx_id)?;
+
+    service
+        .rollback_transaction(tx_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
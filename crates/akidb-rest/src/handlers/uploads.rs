@@ -0,0 +1,222 @@
+//! Resumable upload API handlers
+//!
+//! Imports NDJSON files too large for a single request:
+//! - POST /collections/{id}/uploads - Start an upload
+//! - POST /collections/{id}/upload-url - Start an upload sent straight to object
+//!   storage with a signed URL
+//! - GET /collections/{id}/uploads - List a collection's uploads
+//! - GET /uploads/{upload_id} - Get an upload and the parts received so far
+//! - GET /uploads/{upload_id}/events - Stream import progress (server-sent events)
+//! - PUT /uploads/{upload_id}/parts/{part_number} - Send (or re-send) a part
+//! - POST /uploads/{upload_id}/complete - Import all parts
+//! - DELETE /uploads/{upload_id} - Abort an upload and discard its parts
+
+use akidb_core::UploadId;
+use akidb_service::{
+    CollectionService, ExportManifest, ImportProgress, ImportReport, SignedUploadUrl, Upload,
+    UploadPart,
+};
+use axum::{
+    body::Bytes,
+    extract::{Path, State},
+    http::StatusCode,
+    response::sse::{Event, Sse},
+    Json,
+};
+use futures::stream::Stream;
+use serde::{Deserialize, Serialize};
+use std::convert::Infallible;
+use std::str::FromStr;
+use std::sync::Arc;
+use std::time::Duration;
+
+use super::sse::progress_events;
+use super::{error_response, parse_collection_id};
+
+/// Upload request (the body is optional)
+#[derive(Deserialize, Default)]
+pub struct CreateUploadRequest {
+    /// Manifest of the export file being uploaded; completion refuses parts
+    /// that do not match it
+    #[serde(default)]
+    pub manifest: Option<ExportManifest>,
+}
+
+/// Signed upload URL request
+#[derive(Deserialize)]
+pub struct CreateUploadUrlRequest {
+    /// URL validity in seconds (default: 3600, max: 7 days)
+    pub expires_in_seconds: Option<u64>,
+
+    /// Manifest of the export file being uploaded
+    #[serde(default)]
+    pub manifest: Option<ExportManifest>,
+}
+
+/// List uploads response
+#[derive(Serialize)]
+pub struct ListUploadsResponse {
+    pub uploads: Vec<Upload>,
+}
+
+fn parse_upload_id(upload_id: &str) -> Result<UploadId, (StatusCode, String)> {
+    UploadId::from_str(upload_id)
+        .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid upload_id: {}", e)))
+}
+
+/// Start a resumable upload into a collection
+///
+/// To restore an export, send its manifest (from the export report) so
+/// completion imports nothing unless every part matches it.
+#[tracing::instrument(skip(service, body), fields(collection_id = %collection_id))]
+pub async fn create_upload(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    body: Bytes,
+) -> Result<(StatusCode, Json<Upload>), (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+    // An empty body starts a plain upload
+    let req: CreateUploadRequest = if body.is_empty() {
+        CreateUploadRequest::default()
+    } else {
+        serde_json::from_slice(&body)
+            .map_err(|e| (StatusCode::BAD_REQUEST, format!("Invalid request: {}", e)))?
+    };
+
+    let upload = service
+        .create_upload(collection_id, req.manifest)
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(upload)))
+}
+
+/// Start an upload the client sends straight to object storage
+///
+/// The client PUTs the NDJSON file to the returned URL, then completes the
+/// upload with `POST /uploads/{upload_id}/complete`.
+#[tracing::instrument(skip(service, req), fields(collection_id = %collection_id))]
+pub async fn create_upload_url(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+    Json(req): Json<CreateUploadUrlRequest>,
+) -> Result<(StatusCode, Json<SignedUploadUrl>), (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    let signed = service
+        .create_upload_url(
+            collection_id,
+            req.expires_in_seconds.map(Duration::from_secs),
+            req.manifest,
+        )
+        .await
+        .map_err(error_response)?;
+
+    Ok((StatusCode::CREATED, Json(signed)))
+}
+
+/// List a collection's uploads
+#[tracing::instrument(skip(service), fields(collection_id = %collection_id))]
+pub async fn list_uploads(
+    Path(collection_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ListUploadsResponse>, (StatusCode, String)> {
+    let collection_id = parse_collection_id(&collection_id)?;
+
+    Ok(Json(ListUploadsResponse {
+        uploads: service.list_uploads(Some(collection_id)).await,
+    }))
+}
+
+/// Get an upload
+///
+/// Clients resuming an interrupted upload use `parts` to find the parts they
+/// still need to send.
+#[tracing::instrument(skip(service), fields(upload_id = %upload_id))]
+pub async fn get_upload(
+    Path(upload_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<Upload>, (StatusCode, String)> {
+    let upload_id = parse_upload_id(&upload_id)?;
+
+    let upload = service
+        .get_upload(upload_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(upload))
+}
+
+/// Send one part of an upload (raw bytes of the NDJSON file)
+#[tracing::instrument(skip(service, body), fields(upload_id = %upload_id))]
+pub async fn upload_part(
+    Path((upload_id, part_number)): Path<(String, u32)>,
+    State(service): State<Arc<CollectionService>>,
+    body: Bytes,
+) -> Result<Json<UploadPart>, (StatusCode, String)> {
+    let upload_id = parse_upload_id(&upload_id)?;
+
+    let part = service
+        .upload_part(upload_id, part_number, body.to_vec())
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(part))
+}
+
+/// Import all parts of an upload
+#[tracing::instrument(skip(service), fields(upload_id = %upload_id))]
+pub async fn complete_upload(
+    Path(upload_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Json<ImportReport>, (StatusCode, String)> {
+    let upload_id = parse_upload_id(&upload_id)?;
+
+    let report = service
+        .complete_upload(upload_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(Json(report))
+}
+
+/// Stream an upload's import progress as server-sent events
+///
+/// Sends the current state, then updates while `complete` imports the file,
+/// until the upload is completed or aborted.
+#[tracing::instrument(skip(service), fields(upload_id = %upload_id))]
+pub async fn import_progress_events(
+    Path(upload_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<Sse<impl Stream<Item = Result<Event, Infallible>>>, (StatusCode, String)> {
+    let upload_id = parse_upload_id(&upload_id)?;
+
+    // Subscribe first so no update between the snapshot and the stream is lost
+    let updates = service
+        .subscribe_import_progress(upload_id)
+        .await
+        .map_err(error_response)?;
+    let upload = service
+        .get_upload(upload_id)
+        .await
+        .map_err(error_response)?;
+    let current = ImportProgress::new(upload.id, upload.status, upload.report.as_ref());
+
+    Ok(progress_events(current, updates))
+}
+
+/// Abort an upload
+#[tracing::instrument(skip(service), fields(upload_id = %upload_id))]
+pub async fn abort_upload(
+    Path(upload_id): Path<String>,
+    State(service): State<Arc<CollectionService>>,
+) -> Result<StatusCode, (StatusCode, String)> {
+    let upload_id = parse_upload_id(&upload_id)?;
+
+    service
+        .abort_upload(upload_id)
+        .await
+        .map_err(error_response)?;
+
+    Ok(StatusCode::NO_CONTENT)
+}
@@ -0,0 +1,138 @@
+//! Admin impersonation for support tooling
+//!
+//! A request carrying `X-Act-As-Tenant` is served as the named tenant when it
+//! also carries the admin key in `X-Admin-Key`, so support tooling can
+//! reproduce a customer's query results without handling their API key.
+//! Impersonated requests are read-only: GET requests and the POST endpoints
+//! that only read (queries, recommendations, fetches, cost estimates).
+//! Optional `X-Impersonation-Actor` and `X-Impersonation-Reason` headers are
+//! recorded in the audit trail (`GET /admin/impersonations`), which lists
+//! refused attempts too. Granted responses carry `X-Acting-As-Tenant`.
+//!
+//! Requests without `X-Act-As-Tenant` are unaffected.
+
+use akidb_core::CoreError;
+use akidb_service::{CollectionService, ImpersonationRecord, ImpersonationRequest};
+use axum::{
+    body::Body,
+    extract::{ConnectInfo, State},
+    http::{HeaderMap, HeaderValue, Method, Request, StatusCode},
+    middleware::Next,
+    response::Response,
+};
+use std::net::SocketAddr;
+use std::sync::Arc;
+
+/// Tenant to act as.
+pub const ACT_AS_TENANT_HEADER: &str = "x-act-as-tenant";
+
+/// Admin key authorizing the impersonation.
+pub const ADMIN_KEY_HEADER: &str = "x-admin-key";
+
+/// Who is impersonating, for the audit trail.
+pub const ACTOR_HEADER: &str = "x-impersonation-actor";
+
+/// Why, e.g. a ticket reference, for the audit trail.
+pub const REASON_HEADER: &str = "x-impersonation-reason";
+
+/// Set on responses to granted impersonated requests.
+pub const ACTING_AS_TENANT_HEADER: &str = "x-acting-as-tenant";
+
+/// Path suffixes of POST endpoints that only read.
+pub const READ_ONLY_POST_SUFFIXES: [&str; 12] = [
+    "/query",
+    "/query/batch",
+    "/query/parents",
+    "/recommend",
+    "/fetch",
+    "/lookup",
+    "/count",
+    "/scroll",
+    "/analyze",
+    "/cost/search",
+    "/cost/import",
+    "/prime",
+];
+
+fn header(headers: &HeaderMap, name: &str) -> Option<String> {
+    headers
+        .get(name)
+        .and_then(|value| value.to_str().ok())
+        .map(str::to_string)
+}
+
+fn is_read_only(method: &Method, path: &str) -> bool {
+    match *method {
+        Method::GET | Method::HEAD => true,
+        Method::POST => READ_ONLY_POST_SUFFIXES
+            .iter()
+            .any(|suffix| path.ends_with(suffix)),
+        _ => false,
+    }
+}
+
+/// Middleware checking and auditing impersonated requests
+pub async fn enforce_impersonation(
+    State(service): State<Arc<CollectionService>>,
+    mut req: Request<Body>,
+    next: Next<Body>,
+) -> Result<Response, (StatusCode, String)> {
+    let Some(tenant_id) = header(req.headers(), ACT_AS_TENANT_HEADER) else {
+        return Ok(next.run(req).await);
+    };
+
+    let path = req.uri().path().to_string();
+    let request = ImpersonationRequest {
+        tenant_id,
+        actor: header(req.headers(), ACTOR_HEADER),
+        reason: header(req.headers(), REASON_HEADER),
+        method: req.method().to_string(),
+        read_only: is_read_only(req.method(), &path),
+        path,
+        source_ip: req
+            .extensions()
+            .get::<ConnectInfo<SocketAddr>>()
+            .map(|ConnectInfo(addr)| addr.ip().to_string()),
+    };
+    let admin_key = header(req.headers(), ADMIN_KEY_HEADER);
+
+    let record = service
+        .authorize_impersonation(admin_key.as_deref(), request)
+        .map_err(|e| match e {
+            CoreError::ValidationError(_) => (StatusCode::UNAUTHORIZED, e.to_string()),
+            CoreError::NotFound { .. } => (StatusCode::NOT_FOUND, e.to_string()),
+            _ => (StatusCode::FORBIDDEN, e.to_string()),
+        })?;
+
+    // The admin key must not reach handlers or logs downstream
+    req.headers_mut().remove(ADMIN_KEY_HEADER);
+    let tenant_id = record.request.tenant_id.clone();
+    req.extensions_mut().insert::<ImpersonationRecord>(record);
+
+    let mut response = next.run(req).await;
+    if let Ok(value) = HeaderValue::from_str(&tenant_id) {
+        response
+            .headers_mut()
+            .insert(ACTING_AS_TENANT_HEADER, value);
+    }
+    Ok(response)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_read_only_requests() {
+        assert!(is_read_only(&Method::GET, "/api/v1/collections"));
+        assert!(is_read_only(
+            &Method::POST,
+            "/api/v1/collections/abc/query/parents"
+        ));
+        assert!(!is_read_only(
+            &Method::POST,
+            "/api/v1/collections/abc/insert"
+        ));
+        assert!(!is_read_only(&Method::DELETE, "/api/v1/collections/abc"));
+    }
+}
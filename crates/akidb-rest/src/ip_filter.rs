@@ -0,0 +1,106 @@
+//! Source IP allowlist enforcement
+//!
+//! Rejects requests whose peer address is outside the tenant's IP allowlist
+//! with 403, so credentials leaked outside the trusted network are useless.
+//! Requests carrying a stored API key in the `X-API-Key` header must also be
+//! inside that key's allowlist. Without allowlists every address is allowed.
+//! Health probes are always allowed.
+//!
+//! The peer address is the TCP connection's: behind a load balancer or proxy,
+//! allowlist the proxy's addresses.
+//!
+//! The server must be started with
+//! `into_make_service_with_connect_info::<SocketAddr>()`; requests without a
+//! known peer address are rejected while an allowlist applies to them.
+
+use akidb_core::ApiKeyId;
+use akidb_service::{AllowlistScope, CollectionService};
+use axum::{
+    body::Body,
+    extract::{ConnectInfo, State},
+    http::{HeaderMap, Request, StatusCode},
+    middleware::Next,
+    response::Response,
+};
+use std::net::SocketAddr;
+use std::sync::Arc;
+
+/// Paths served regardless of the allowlist (Kubernetes probes).
+pub const EXEMPT_PATHS: [&str; 2] = ["/health", "/ready"];
+
+/// Header carrying the caller's API key.
+pub const API_KEY_HEADER: &str = "x-api-key";
+
+/// ID of the stored API key a request carries, if any.
+pub(crate) async fn request_api_key(
+    service: &CollectionService,
+    headers: &HeaderMap,
+) -> Result<Option<ApiKeyId>, (StatusCode, String)> {
+    let Some(value) = headers.get(API_KEY_HEADER) else {
+        return Ok(None);
+    };
+    let api_key = value.to_str().map_err(|_| {
+        (
+            StatusCode::BAD_REQUEST,
+            "Invalid X-API-Key header".to_string(),
+        )
+    })?;
+    service.find_api_key(api_key).await.map_err(|e| {
+        tracing::error!("Failed to look up API key: {}", e);
+        (StatusCode::INTERNAL_SERVER_ERROR, e.to_string())
+    })
+}
+
+/// Middleware enforcing the tenant's and the API key's IP allowlists
+pub async fn enforce_ip_allowlist(
+    State(service): State<Arc<CollectionService>>,
+    req: Request<Body>,
+    next: Next<Body>,
+) -> Result<Response, (StatusCode, String)> {
+    if EXEMPT_PATHS.contains(&req.uri().path()) {
+        return Ok(next.run(req).await);
+    }
+
+    let api_key = request_api_key(&service, req.headers()).await?;
+    let peer = req
+        .extensions()
+        .get::<ConnectInfo<SocketAddr>>()
+        .map(|ConnectInfo(addr)| addr.ip());
+    match peer {
+        Some(ip) => {
+            let evaluation = service.evaluate_source_ip(ip, api_key).await;
+            if !evaluation.allowed {
+                let scope = match evaluation.tenant {
+                    Some(tenant) if !tenant.allowed => "tenant's",
+                    _ => "API key's",
+                };
+                tracing::warn!(
+                    "Rejected request from {} (not in {} IP allowlist)",
+                    ip,
+                    scope
+                );
+                return Err((
+                    StatusCode::FORBIDDEN,
+                    format!("Source address {} is not in the {} IP allowlist", ip, scope),
+                ));
+            }
+        }
+        None => {
+            let mut scopes = vec![AllowlistScope::Tenant];
+            scopes.extend(api_key.map(|key_id| AllowlistScope::ApiKey { key_id }));
+            for scope in scopes {
+                if service.get_ip_allowlist(scope).await.is_ok() {
+                    return Err((
+                        StatusCode::FORBIDDEN,
+                        format!(
+                            "Source address unknown; cannot check the {} IP allowlist",
+                            scope
+                        ),
+                    ));
+                }
+            }
+        }
+    }
+
+    Ok(next.run(req).await)
+}
@@ -1,2 +1,13 @@
+pub mod aliases;
+pub mod checksum;
+pub mod compression;
+pub mod conformance;
+pub mod deprecation;
 pub mod handlers;
+pub mod impersonation;
+pub mod ip_filter;
+pub mod replication;
+pub mod request_id;
+pub mod suspension;
 pub mod tracing_init;
+pub mod vector_encoding;
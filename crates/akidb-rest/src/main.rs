@@ -1,12 +1,31 @@
-use akidb_metadata::{SqliteCollectionRepository, VectorPersistence};
-use akidb_rest::handlers;
-use akidb_service::{CollectionService, Config, EmbeddingManager};
+use akidb_grpc::GrpcReplicationTransport;
+use akidb_metadata::{
+    AliasRepository, CollectionPolicyRepository, FieldIndexRepository, IpAllowlistRepository,
+    LegalHoldRepository, ReplicationTargetRepository, ScheduledJobRepository,
+    SqliteApiKeyRepository, SqliteCollectionRepository, SqliteTenantCatalog, VectorPersistence,
+};
+use akidb_rest::{
+    aliases, checksum, compression, handlers, impersonation, ip_filter,
+    replication::HttpReplicationTransport, request_id, suspension,
+};
+use akidb_service::{
+    CollectionService, Config, EmbeddingManager, GROWTH_SAMPLE_INTERVAL,
+    MAX_BATCH_SEARCH_BODY_BYTES, MAX_REPLICATION_BODY_BYTES, MAX_UPLOAD_PART_BYTES,
+    ReplicationProtocol, REPLICATION_TICK, SCHEDULER_TICK, TENANT_PURGE_INTERVAL,
+    TENANT_SUSPENSION_REFRESH_INTERVAL,
+};
+use akidb_storage::object_store::{S3Config, S3ObjectStore};
 use axum::{
-    routing::{delete, get, post},
-    Router,
+    extract::DefaultBodyLimit,
+    middleware,
+    routing::{delete, get, patch, post, put},
+    Router, ServiceExt,
 };
 use sqlx::SqlitePool;
+use std::net::SocketAddr;
 use std::sync::Arc;
+use tower::Layer;
+use tower_http::compression::CompressionLayer;
 
 #[tokio::main]
 async fn main() -> Result<(), Box<dyn std::error::Error>> {
@@ -134,14 +153,154 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
     };
 
     service.set_default_database_id(database_id).await;
+    service.set_tenant_id(tenant_id).await;
     tracing::info!("✅ Using default database_id: {}", database_id);
 
+    // Tenant provisioning and listing (/admin/tenants)
+    service
+        .set_tenant_catalog(Some(Arc::new(SqliteTenantCatalog::new(pool.clone()))))
+        .await;
+    service.spawn_tenant_purger(TENANT_PURGE_INTERVAL);
+    service.spawn_suspension_refresher(TENANT_SUSPENSION_REFRESH_INTERVAL);
+    service
+        .set_api_key_repository(Some(Arc::new(SqliteApiKeyRepository::new(pool.clone()))))
+        .await;
+
+    // Source IP allowlists of the tenant and API keys
+    service
+        .set_ip_allowlist_repository(Some(Arc::new(IpAllowlistRepository::new(pool.clone()))))
+        .await;
+    let allowlist_count = service.load_ip_allowlists().await?;
+    tracing::info!("✅ Loaded {} IP allowlist(s)", allowlist_count);
+
+    // Collection defaults and limits set by the platform team
+    service
+        .set_collection_policy_repository(Some(Arc::new(CollectionPolicyRepository::new(
+            pool.clone(),
+        ))))
+        .await;
+    if service.load_collection_policy().await? {
+        tracing::info!("✅ Loaded collection policy");
+    }
+
     // Load existing collections from database
     tracing::info!("🔄 Loading collections from database...");
     service.load_all_collections().await?;
     let collection_count = service.list_collections().await?.len();
     tracing::info!("✅ Loaded {} collection(s)", collection_count);
 
+    // Snapshot files taken before the restart are listed again
+    let snapshot_count = service.load_snapshots().await?;
+    tracing::info!("✅ Loaded {} snapshot(s)", snapshot_count);
+
+    // Aliases are stored next to the collections they point at
+    service
+        .set_alias_repository(Some(Arc::new(AliasRepository::new(pool.clone()))))
+        .await;
+    let alias_count = service.load_aliases().await?;
+    tracing::info!("✅ Loaded {} alias(es)", alias_count);
+
+    // Legal holds outlive restarts
+    service
+        .set_legal_hold_repository(Some(Arc::new(LegalHoldRepository::new(pool.clone()))))
+        .await;
+    let hold_count = service.load_legal_holds().await?;
+    tracing::info!("✅ Loaded {} legal hold(s)", hold_count);
+
+    // Field indexes are rebuilt from the loaded documents
+    service
+        .set_field_index_repository(Some(Arc::new(FieldIndexRepository::new(pool.clone()))))
+        .await;
+    let field_index_count = service.load_field_indexes().await?;
+    tracing::info!("✅ Rebuilt {} field index(es)", field_index_count);
+
+    // Scheduled jobs resume where they left off
+    service
+        .set_scheduled_job_repository(Some(Arc::new(ScheduledJobRepository::new(pool.clone()))))
+        .await;
+    let job_count = service.load_scheduled_jobs().await?;
+    tracing::info!("✅ Loaded {} scheduled job(s)", job_count);
+
+    // Resume cross-region replication (each target starts with a full copy)
+    service
+        .set_replication_repository(Some(Arc::new(ReplicationTargetRepository::new(
+            pool.clone(),
+        ))))
+        .await;
+    let target_count = service.load_replication_targets().await?;
+    tracing::info!("✅ Loaded {} replication target(s)", target_count);
+
+    // Sample collection sizes for capacity forecasting
+    service.spawn_growth_sampler(GROWTH_SAMPLE_INTERVAL);
+
+    // Run scheduled housekeeping jobs
+    service.spawn_job_scheduler(SCHEDULER_TICK);
+
+    // Ship writes of replicated collections to their target regions
+    service.set_replication_transport(
+        ReplicationProtocol::Http,
+        Arc::new(HttpReplicationTransport::with_config(
+            &config.replication_client,
+        )),
+    );
+    service.set_replication_transport(
+        ReplicationProtocol::Grpc,
+        Arc::new(GrpcReplicationTransport::new()),
+    );
+    service.spawn_replicator(REPLICATION_TICK);
+
+    // Bucket for direct-to-storage imports (signed upload URLs)
+    let imports = &config.imports;
+    if let Some(bucket) = &imports.s3_bucket {
+        let mut s3_config = match (
+            &imports.s3_endpoint,
+            &imports.s3_access_key,
+            &imports.s3_secret_key,
+        ) {
+            (Some(endpoint), Some(access_key), Some(secret_key)) => {
+                S3Config::custom(bucket, &imports.s3_region, endpoint, access_key, secret_key)
+            }
+            _ => S3Config::aws(bucket, &imports.s3_region),
+        };
+        if let Some(prefix) = &imports.s3_prefix {
+            s3_config = s3_config.with_prefix(prefix);
+        }
+        let store = S3ObjectStore::new(s3_config).await?;
+        service.set_import_store(Some(Arc::new(store))).await;
+        tracing::info!("✅ Signed upload URLs enabled (bucket: {})", bucket);
+    }
+
+    // Key signing compliance (export/purge) reports
+    match &config.compliance.signing_key {
+        Some(key) => {
+            service
+                .set_compliance_signing_key(key.as_bytes().to_vec())
+                .await?
+        }
+        None => tracing::warn!(
+            "⚠️  No compliance signing key configured; reports cannot be verified after a restart"
+        ),
+    }
+
+    // Thresholds for flagging mass scans
+    service.set_anomaly_config(config.anomaly)?;
+
+    // Batching of concurrent Gets
+    service.set_get_coalescing(config.coalescing)?;
+    if config.coalescing.enabled {
+        tracing::info!(
+            "✅ Get coalescing enabled ({}ms window)",
+            config.coalescing.window_ms
+        );
+    }
+
+    // Admin impersonation of the default tenant (support tooling)
+    let impersonation_key = config.admin.impersonation_key.as_deref();
+    service.configure_impersonation(tenant_id, impersonation_key)?;
+    if impersonation_key.is_some() {
+        tracing::info!("✅ Admin impersonation enabled (audit: GET /admin/impersonations)");
+    }
+
     // Initialize EmbeddingManager from configuration
     let embedding_config = &config.embedding;
     tracing::info!(
@@ -196,25 +355,486 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
             "/api/v1/collections/:id",
             delete(handlers::delete_collection),
         )
+        .route(
+            "/api/v1/collections/:id",
+            patch(handlers::update_collection),
+        )
+        .route(
+            "/api/v1/collections/:id/reindex",
+            post(handlers::reindex_collection),
+        )
+        // Collection alias endpoints
+        .route("/api/v1/aliases", get(handlers::list_aliases))
+        .route("/api/v1/aliases/:alias", get(handlers::get_alias))
+        .route("/api/v1/aliases/:alias", put(handlers::set_alias))
+        .route("/api/v1/aliases/:alias", delete(handlers::delete_alias))
+        .route(
+            "/api/v1/collections/:id/rename",
+            post(handlers::rename_collection),
+        )
+        .route(
+            "/api/v1/collections/:id/clone",
+            post(handlers::clone_collection),
+        )
+        .route(
+            "/api/v1/collections/:id/read-only",
+            get(handlers::get_read_only),
+        )
+        .route(
+            "/api/v1/collections/:id/read-only",
+            put(handlers::set_read_only),
+        )
+        .route(
+            "/api/v1/collections/:id/field-indexes",
+            get(handlers::list_field_indexes),
+        )
+        .route(
+            "/api/v1/collections/:id/field-indexes",
+            post(handlers::create_field_index),
+        )
+        .route(
+            "/api/v1/collections/:id/field-indexes/:field",
+            delete(handlers::delete_field_index),
+        )
+        .route(
+            "/api/v1/collections/:id/search-defaults",
+            get(handlers::get_search_defaults),
+        )
+        .route(
+            "/api/v1/collections/:id/search-defaults",
+            put(handlers::update_search_defaults),
+        )
+        .route(
+            "/api/v1/collections/:id/sparse-index",
+            get(handlers::get_sparse_index),
+        )
+        .route(
+            "/api/v1/collections/:id/sparse-index",
+            put(handlers::update_sparse_index),
+        )
+        .route(
+            "/api/v1/collections/:id/sparse-index",
+            delete(handlers::delete_sparse_index),
+        )
+        .route(
+            "/api/v1/collections/:id/named-vectors",
+            get(handlers::get_named_vectors),
+        )
+        .route(
+            "/api/v1/collections/:id/named-vectors",
+            put(handlers::update_named_vectors),
+        )
         // Vector operation endpoints
         .route(
             "/api/v1/collections/:id/query",
             post(handlers::query_vectors),
         )
+        .route(
+            "/api/v1/collections/:id/query/batch",
+            post(handlers::query_batch).layer(DefaultBodyLimit::max(MAX_BATCH_SEARCH_BODY_BYTES)),
+        )
+        .route(
+            "/api/v1/collections/:id/query/parents",
+            post(handlers::query_parents),
+        )
+        .route(
+            "/api/v1/collections/:id/recommend",
+            post(handlers::recommend_vectors),
+        )
         .route(
             "/api/v1/collections/:id/insert",
             post(handlers::insert_vector),
         )
+        .route(
+            "/api/v1/collections/:id/insert/batch",
+            post(handlers::insert_batch),
+        )
+        .route(
+            "/api/v1/collections/:id/upsert",
+            post(handlers::upsert_vector),
+        )
+        .route(
+            "/api/v1/collections/:id/upsert/batch",
+            post(handlers::upsert_batch),
+        )
         .route(
             "/api/v1/collections/:id/docs/:doc_id",
             get(handlers::get_vector),
         )
+        .route(
+            "/api/v1/collections/:id/docs/:doc_id/metadata",
+            patch(handlers::update_metadata),
+        )
+        .route(
+            "/api/v1/collections/:id/metadata/batch",
+            post(handlers::update_metadata_batch),
+        )
+        .route(
+            "/api/v1/collections/:id/fetch",
+            post(handlers::fetch_vectors),
+        )
+        .route(
+            "/api/v1/collections/:id/lookup",
+            post(handlers::lookup_vectors),
+        )
+        .route(
+            "/api/v1/collections/:id/count",
+            post(handlers::count_vectors),
+        )
+        .route(
+            "/api/v1/collections/:id/scroll",
+            post(handlers::scroll_vectors),
+        )
+        .route(
+            "/api/v1/collections/:id/export",
+            get(handlers::export_vectors),
+        )
+        .route(
+            "/api/v1/collections/:id/changes",
+            get(handlers::change_stream),
+        )
+        .route(
+            "/api/v1/collections/:id/delete",
+            post(handlers::delete_vectors),
+        )
         .route(
             "/api/v1/collections/:id/docs/:doc_id",
             delete(handlers::delete_vector),
         )
+        // Text analysis endpoints
+        .route(
+            "/api/v1/collections/:id/sparse/idf",
+            get(handlers::get_idf_stats),
+        )
+        .route(
+            "/api/v1/collections/:id/analysis",
+            get(handlers::get_analysis),
+        )
+        .route(
+            "/api/v1/collections/:id/analysis/stopwords",
+            put(handlers::update_stopwords),
+        )
+        .route(
+            "/api/v1/collections/:id/analysis/synonyms",
+            put(handlers::update_synonyms),
+        )
+        .route(
+            "/api/v1/collections/:id/analysis/fields/:field",
+            put(handlers::update_field_analyzer),
+        )
+        .route(
+            "/api/v1/collections/:id/analysis/fields/:field",
+            delete(handlers::delete_field_analyzer),
+        )
+        .route(
+            "/api/v1/collections/:id/analyze",
+            post(handlers::analyze_text),
+        )
+        // Monitoring endpoints
+        .route(
+            "/api/v1/collections/:id/drift",
+            get(handlers::get_drift_report),
+        )
+        .route(
+            "/api/v1/collections/:id/drift",
+            put(handlers::enable_drift_monitoring),
+        )
+        .route(
+            "/api/v1/collections/:id/drift",
+            delete(handlers::disable_drift_monitoring),
+        )
+        .route(
+            "/api/v1/collections/:id/adaptive-search",
+            get(handlers::get_adaptive_search),
+        )
+        .route(
+            "/api/v1/collections/:id/adaptive-search",
+            put(handlers::enable_adaptive_search),
+        )
+        .route(
+            "/api/v1/collections/:id/adaptive-search",
+            delete(handlers::disable_adaptive_search),
+        )
+        .route(
+            "/api/v1/collections/:id/growth",
+            get(handlers::get_growth_history),
+        )
+        .route(
+            "/api/v1/collections/:id/forecast",
+            get(handlers::get_growth_forecast),
+        )
+        .route(
+            "/api/v1/capacity/forecast",
+            get(handlers::get_capacity_forecast),
+        )
+        .route(
+            "/api/v1/collections/:id/cost/search",
+            post(handlers::estimate_search_cost),
+        )
+        .route(
+            "/api/v1/collections/:id/cost/import",
+            post(handlers::estimate_import_cost),
+        )
+        .route(
+            "/api/v1/collections/:id/query-patterns",
+            get(handlers::get_query_patterns),
+        )
+        .route(
+            "/api/v1/collections/:id/prime",
+            post(handlers::prime_collection),
+        )
+        .route(
+            "/api/v1/tenant/collection-policy",
+            get(handlers::get_collection_policy),
+        )
+        .route(
+            "/api/v1/tenant/collection-policy",
+            put(handlers::update_collection_policy),
+        )
+        .route(
+            "/api/v1/tenant/usage-reports/:month",
+            get(handlers::get_usage_report),
+        )
+        .route("/api/v1/whoami", get(handlers::whoami))
+        // Resumable upload endpoints
+        .route(
+            "/api/v1/collections/:id/uploads",
+            post(handlers::create_upload),
+        )
+        .route(
+            "/api/v1/collections/:id/uploads",
+            get(handlers::list_uploads),
+        )
+        .route(
+            "/api/v1/collections/:id/upload-url",
+            post(handlers::create_upload_url),
+        )
+        .route("/api/v1/uploads/:upload_id", get(handlers::get_upload))
+        .route("/api/v1/uploads/:upload_id", delete(handlers::abort_upload))
+        .route(
+            "/api/v1/uploads/:upload_id/parts/:part_number",
+            put(handlers::upload_part).layer(DefaultBodyLimit::max(MAX_UPLOAD_PART_BYTES)),
+        )
+        .route(
+            "/api/v1/uploads/:upload_id/complete",
+            post(handlers::complete_upload),
+        )
+        .route(
+            "/api/v1/uploads/:upload_id/events",
+            get(handlers::import_progress_events),
+        )
+        // Standing query endpoints
+        .route(
+            "/api/v1/collections/:id/subscriptions",
+            post(handlers::create_subscription),
+        )
+        .route(
+            "/api/v1/collections/:id/subscriptions",
+            get(handlers::list_subscriptions),
+        )
+        .route(
+            "/api/v1/subscriptions/:subscription_id",
+            get(handlers::get_subscription),
+        )
+        .route(
+            "/api/v1/subscriptions/:subscription_id",
+            delete(handlers::delete_subscription),
+        )
+        .route(
+            "/api/v1/subscriptions/:subscription_id/ws",
+            get(handlers::subscription_ws),
+        )
+        // Scheduled job endpoints
+        .route(
+            "/api/v1/scheduled-jobs",
+            post(handlers::create_scheduled_job),
+        )
+        .route("/api/v1/scheduled-jobs", get(handlers::list_scheduled_jobs))
+        .route(
+            "/api/v1/scheduled-jobs/:job_id",
+            get(handlers::get_scheduled_job),
+        )
+        .route(
+            "/api/v1/scheduled-jobs/:job_id",
+            put(handlers::update_scheduled_job),
+        )
+        .route(
+            "/api/v1/scheduled-jobs/:job_id",
+            delete(handlers::delete_scheduled_job),
+        )
+        .route(
+            "/api/v1/scheduled-jobs/:job_id/run",
+            post(handlers::run_scheduled_job),
+        )
+        // Compliance (data subject export/purge) endpoints
+        .route(
+            "/api/v1/compliance-jobs",
+            post(handlers::create_compliance_job),
+        )
+        .route(
+            "/api/v1/compliance-jobs",
+            get(handlers::list_compliance_jobs),
+        )
+        .route(
+            "/api/v1/compliance-jobs/:job_id",
+            get(handlers::get_compliance_job),
+        )
+        .route(
+            "/api/v1/compliance-jobs/:job_id/export",
+            get(handlers::download_compliance_export),
+        )
+        .route(
+            "/api/v1/compliance/verify",
+            post(handlers::verify_compliance_report),
+        )
+        // Legal hold endpoints
+        .route(
+            "/api/v1/collections/:id/legal-holds",
+            post(handlers::place_legal_hold),
+        )
+        .route(
+            "/api/v1/collections/:id/legal-holds",
+            get(handlers::list_legal_holds),
+        )
+        .route(
+            "/api/v1/legal-holds/:hold_id",
+            get(handlers::get_legal_hold),
+        )
+        .route(
+            "/api/v1/legal-holds/:hold_id",
+            delete(handlers::release_legal_hold),
+        )
+        // Snapshot endpoints
+        .route(
+            "/api/v1/collections/:id/snapshots",
+            post(handlers::create_snapshot),
+        )
+        .route(
+            "/api/v1/collections/:id/snapshots",
+            get(handlers::list_collection_snapshots),
+        )
+        .route("/api/v1/snapshots", get(handlers::list_snapshots))
+        .route(
+            "/api/v1/snapshots/:snapshot_id",
+            get(handlers::get_snapshot),
+        )
+        .route(
+            "/api/v1/snapshots/:snapshot_id",
+            delete(handlers::delete_snapshot),
+        )
+        .route(
+            "/api/v1/snapshots/:snapshot_id/download",
+            get(handlers::download_snapshot),
+        )
+        .route(
+            "/api/v1/snapshots/:snapshot_id/restore",
+            post(handlers::restore_snapshot),
+        )
+        // Cross-region replication endpoints
+        .route(
+            "/api/v1/collections/:id/replication",
+            get(handlers::get_replication_status),
+        )
+        .route(
+            "/api/v1/collections/:id/replication",
+            put(handlers::configure_replication),
+        )
+        .route(
+            "/api/v1/collections/:id/replication",
+            delete(handlers::delete_replication),
+        )
+        .route(
+            "/api/v1/collections/:id/replication/apply",
+            post(handlers::apply_replicated)
+                .layer(DefaultBodyLimit::max(MAX_REPLICATION_BODY_BYTES)),
+        )
+        .route("/api/v1/replication", get(handlers::list_replication))
+        // Access anomaly endpoints
+        .route("/api/v1/anomalies", get(handlers::list_anomalies))
+        .route("/api/v1/anomalies/events", get(handlers::anomaly_events))
+        // IP allowlist endpoints
+        .route("/api/v1/ip-allowlists", get(handlers::list_ip_allowlists))
+        .route(
+            "/api/v1/ip-allowlists/check",
+            get(handlers::check_ip_allowlist),
+        )
+        .route(
+            "/api/v1/tenant/ip-allowlist",
+            get(handlers::get_tenant_ip_allowlist),
+        )
+        .route(
+            "/api/v1/tenant/ip-allowlist",
+            put(handlers::update_tenant_ip_allowlist),
+        )
+        .route(
+            "/api/v1/tenant/ip-allowlist",
+            delete(handlers::delete_tenant_ip_allowlist),
+        )
+        .route(
+            "/api/v1/api-keys/:key_id/ip-allowlist",
+            get(handlers::get_key_ip_allowlist),
+        )
+        .route(
+            "/api/v1/api-keys/:key_id/ip-allowlist",
+            put(handlers::update_key_ip_allowlist),
+        )
+        .route(
+            "/api/v1/api-keys/:key_id/ip-allowlist",
+            delete(handlers::delete_key_ip_allowlist),
+        )
+        // Write transaction endpoints
+        .route("/api/v1/transactions", post(handlers::begin_transaction))
+        .route(
+            "/api/v1/transactions/:tx_id",
+            get(handlers::get_transaction),
+        )
+        .route(
+            "/api/v1/transactions/:tx_id/collections/:id/insert",
+            post(handlers::transaction_insert),
+        )
+        .route(
+            "/api/v1/transactions/:tx_id/collections/:id/docs/:doc_id",
+            delete(handlers::transaction_delete),
+        )
+        .route(
+            "/api/v1/transactions/:tx_id/commit",
+            post(handlers::commit_transaction),
+        )
+        .route(
+            "/api/v1/transactions/:tx_id/rollback",
+            post(handlers::rollback_transaction),
+        )
+        // Backfill job endpoints
+        .route("/api/v1/backfill-jobs", post(handlers::create_backfill_job))
+        .route("/api/v1/backfill-jobs", get(handlers::list_backfill_jobs))
+        .route(
+            "/api/v1/backfill-jobs/:job_id",
+            get(handlers::get_backfill_progress),
+        )
+        .route(
+            "/api/v1/backfill-jobs/:job_id/events",
+            get(handlers::backfill_progress_events),
+        )
+        .route(
+            "/api/v1/backfill-jobs/:job_id/batch",
+            post(handlers::next_backfill_batch),
+        )
+        .route(
+            "/api/v1/backfill-jobs/:job_id/patch",
+            post(handlers::patch_backfill),
+        )
+        .route(
+            "/api/v1/backfill-jobs/:job_id/resume",
+            post(handlers::resume_backfill),
+        )
+        .route(
+            "/api/v1/backfill-jobs/:job_id/cancel",
+            post(handlers::cancel_backfill),
+        )
         // Admin/Operations endpoints (Phase 7 Week 4)
         .route("/admin/health", get(handlers::health_check))
+        .route("/admin/events", get(handlers::list_events))
+        .route("/admin/events/stream", get(handlers::service_events))
         .route(
             "/admin/collections/:id/dlq/retry",
             post(handlers::retry_dlq),
@@ -223,6 +843,24 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
             "/admin/circuit-breaker/reset",
             post(handlers::reset_circuit_breaker),
         )
+        .route("/admin/impersonations", get(handlers::list_impersonations))
+        .route("/admin/tenants", get(handlers::list_tenants))
+        .route("/admin/tenants/batch", post(handlers::batch_create_tenants))
+        .route("/admin/tenants/:id", delete(handlers::delete_tenant))
+        .route(
+            "/admin/tenants/:id/undelete",
+            post(handlers::undelete_tenant),
+        )
+        .route("/admin/tenants/:id/suspend", post(handlers::suspend_tenant))
+        .route("/admin/tenants/:id/resume", post(handlers::resume_tenant))
+        .route(
+            "/admin/tenants/:id/api-keys/:key_id/rotate",
+            post(handlers::rotate_api_key),
+        )
+        .route(
+            "/admin/tenants/:id/usage",
+            get(handlers::get_tenant_usage_history),
+        )
         // Tier management endpoints (Phase 10 Week 3)
         .route(
             "/api/v1/collections/:id/tier",
@@ -250,13 +888,51 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         app
     };
 
+    // Verify Content-MD5 / X-Checksum-XXH64 on request bodies, add response checksums on request
+    let app = app.layer(middleware::from_fn(checksum::verify_checksums));
+
+    // Decompress gzip/zstd request bodies and compress responses, outside the
+    // checksums so they cover the uncompressed bodies
+    let app = if config.server.compression {
+        app.layer(middleware::from_fn(compression::decompress_requests))
+            .layer(CompressionLayer::new())
+    } else {
+        app
+    };
+
+    // Reject the tenant's API requests while it is suspended
+    let app = app.layer(middleware::from_fn_with_state(
+        Arc::clone(&service),
+        suspension::reject_suspended_tenant,
+    ));
+
+    // Check and audit requests acting as a tenant with the admin key
+    let app = app.layer(middleware::from_fn_with_state(
+        Arc::clone(&service),
+        impersonation::enforce_impersonation,
+    ));
+
+    // Reject requests from outside the tenant's IP allowlist (checked first)
+    let app = app.layer(middleware::from_fn_with_state(
+        Arc::clone(&service),
+        ip_filter::enforce_ip_allowlist,
+    ));
+
+    // Tag every request, its log lines and its response with a request ID
+    let app = app.layer(middleware::from_fn(request_id::propagate_request_id));
+
+    // Accept aliases in place of collection IDs; wraps the router because
+    // paths must be rewritten before routing
+    let app =
+        middleware::from_fn_with_state(Arc::clone(&service), aliases::resolve_aliases).layer(app);
+
     let addr = format!("{}:{}", config.server.host, config.server.rest_port).parse()?;
 
     tracing::info!("🌐 REST server listening on {}", addr);
 
     // Setup graceful shutdown
     axum::Server::bind(&addr)
-        .serve(app.into_make_service())
+        .serve(app.into_make_service_with_connect_info::<SocketAddr>())
         .with_graceful_shutdown(shutdown_signal(service_for_shutdown))
         .await?;
 
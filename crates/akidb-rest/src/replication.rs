@@ -0,0 +1,130 @@
+//! HTTP transport for cross-region replication
+//!
+//! Ships a collection's pending writes to the target cluster's
+//! `POST /api/v1/collections/{id}/replication/apply` endpoint. Only plain
+//! `http://` endpoints are supported; reach TLS targets through a
+//! TLS-terminating proxy or service mesh.
+//!
+//! Each shipment carries an `X-Request-ID`, named in its errors, so a failed
+//! shipment can be found in the target's logs. Deprecation notices of the
+//! target are surfaced through a `DeprecationTracker`.
+//!
+//! The client's connection pool and sockets are tuned with a
+//! `ReplicationClientConfig`, or a prebuilt client can be passed in. Forward
+//! proxies are not supported; point the target endpoint at a reverse proxy
+//! instead.
+
+use crate::deprecation::DeprecationTracker;
+use crate::request_id::{RequestId, REQUEST_ID_HEADER};
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use akidb_service::{ReplicationClientConfig, ReplicationOp, ReplicationTransport};
+use async_trait::async_trait;
+use hyper::{client::HttpConnector, Body, Client, Method, Request};
+use serde::Serialize;
+use std::time::Duration;
+
+/// Timeout of a shipment to the target.
+pub const REPLICATION_REQUEST_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Endpoint shipments are sent to, as named in deprecation warnings.
+const APPLY_ENDPOINT: &str = "POST /api/v1/collections/{id}/replication/apply";
+
+#[derive(Serialize)]
+struct ApplyRequest<'a> {
+    ops: &'a [ReplicationOp],
+}
+
+/// Ships replicated writes over HTTP.
+#[derive(Default)]
+pub struct HttpReplicationTransport {
+    client: Client<HttpConnector>,
+    deprecations: DeprecationTracker,
+}
+
+impl HttpReplicationTransport {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Ship through a client tuned by `config`.
+    pub fn with_config(config: &ReplicationClientConfig) -> Self {
+        let mut connector = HttpConnector::new();
+        connector.set_connect_timeout(Some(Duration::from_secs(config.connect_timeout_secs)));
+        connector.set_keepalive(
+            (config.tcp_keepalive_secs > 0).then(|| Duration::from_secs(config.tcp_keepalive_secs)),
+        );
+        let client = Client::builder()
+            .pool_max_idle_per_host(config.max_idle_per_host)
+            .pool_idle_timeout(Duration::from_secs(config.idle_timeout_secs))
+            .http2_only(config.http2_only)
+            .build(connector);
+        Self::with_client(client)
+    }
+
+    /// Ship through `client`, e.g. one shared with other components.
+    pub fn with_client(client: Client<HttpConnector>) -> Self {
+        Self {
+            client,
+            deprecations: DeprecationTracker::default(),
+        }
+    }
+}
+
+#[async_trait]
+impl ReplicationTransport for HttpReplicationTransport {
+    async fn ship(
+        &self,
+        endpoint: &str,
+        collection_id: CollectionId,
+        ops: &[ReplicationOp],
+    ) -> CoreResult<()> {
+        if !endpoint.starts_with("http://") {
+            return Err(CoreError::ValidationError(format!(
+                "unsupported replication endpoint '{}': use an http:// endpoint \
+                 (e.g. a TLS-terminating proxy)",
+                endpoint
+            )));
+        }
+        let uri = format!(
+            "{}/api/v1/collections/{}/replication/apply",
+            endpoint.trim_end_matches('/'),
+            collection_id
+        );
+        let body = serde_json::to_vec(&ApplyRequest { ops })
+            .map_err(|e| CoreError::internal(e.to_string()))?;
+        let request_id = RequestId::current_or_new();
+        let request = Request::builder()
+            .method(Method::POST)
+            .uri(&uri)
+            .header("content-type", "application/json")
+            .header(REQUEST_ID_HEADER, request_id.as_str())
+            .body(Body::from(body))
+            .map_err(|e| CoreError::ValidationError(format!("invalid endpoint: {}", e)))?;
+
+        let response =
+            tokio::time::timeout(REPLICATION_REQUEST_TIMEOUT, self.client.request(request))
+                .await
+                .map_err(|_| {
+                    CoreError::internal(format!("{} timed out (request {})", uri, request_id))
+                })?
+                .map_err(|e| {
+                    CoreError::internal(format!("{}: {} (request {})", uri, e, request_id))
+                })?;
+        self.deprecations
+            .observe(APPLY_ENDPOINT, response.headers());
+        let status = response.status();
+        if !status.is_success() {
+            let body = hyper::body::to_bytes(response.into_body())
+                .await
+                .unwrap_or_default();
+            return Err(CoreError::internal(format!(
+                "{} returned {}: {} (request {})",
+                uri,
+                status,
+                String::from_utf8_lossy(&body),
+                request_id
+            )));
+        }
+        Ok(())
+    }
+}
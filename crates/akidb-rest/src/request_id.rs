@@ -0,0 +1,162 @@
+//! Request IDs for correlating calls with server logs
+//!
+//! Every request gets an ID: the caller's `X-Request-ID` if it sent a valid
+//! one, otherwise a fresh UUID v7. The ID is
+//! - attached to every log line of the request (a `request` span),
+//! - echoed in the `X-Request-ID` response header, errors included, so a
+//!   support ticket quoting it leads straight to the server logs,
+//! - available to handlers as a request extension and to outgoing calls made
+//!   while serving the request through `RequestId::current`.
+//!
+//! Outgoing HTTP calls (e.g. replication shipments) send the ID of the request
+//! they serve, or a fresh one from background tasks, and name it in their
+//! errors.
+//!
+//! # Example
+//! ```no_run
+//! use akidb_rest::request_id;
+//! use axum::{middleware, routing::get, Router};
+//!
+//! let app: Router = Router::new()
+//!     .route("/", get(|| async { "ok" }))
+//!     .layer(middleware::from_fn(request_id::propagate_request_id));
+//! ```
+
+use axum::{
+    body::Body,
+    http::{HeaderMap, HeaderValue, Request},
+    middleware::Next,
+    response::Response,
+};
+use std::fmt;
+use tracing::Instrument;
+
+/// Request/response header carrying the request ID.
+pub const REQUEST_ID_HEADER: &str = "x-request-id";
+
+/// Longest request ID accepted from callers; longer ones are replaced.
+pub const MAX_REQUEST_ID_LEN: usize = 128;
+
+tokio::task_local! {
+    static CURRENT_REQUEST_ID: RequestId;
+}
+
+/// ID correlating a request with its log lines.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct RequestId(String);
+
+impl RequestId {
+    /// A fresh ID (UUID v7, so IDs sort by time).
+    pub fn new() -> Self {
+        Self(uuid::Uuid::now_v7().to_string())
+    }
+
+    /// The caller's ID, if it sent one of at most `MAX_REQUEST_ID_LEN`
+    /// printable ASCII characters.
+    pub fn from_headers(headers: &HeaderMap) -> Option<Self> {
+        let value = headers.get(REQUEST_ID_HEADER)?.to_str().ok()?.trim();
+        let valid = !value.is_empty()
+            && value.len() <= MAX_REQUEST_ID_LEN
+            && value.bytes().all(|b| b.is_ascii_graphic());
+        valid.then(|| Self(value.to_string()))
+    }
+
+    /// ID of the request being served by the current task, if any.
+    pub fn current() -> Option<Self> {
+        CURRENT_REQUEST_ID.try_with(Clone::clone).ok()
+    }
+
+    /// ID of the request being served, or a fresh one outside requests.
+    pub fn current_or_new() -> Self {
+        Self::current().unwrap_or_default()
+    }
+
+    pub fn as_str(&self) -> &str {
+        &self.0
+    }
+}
+
+impl Default for RequestId {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl fmt::Display for RequestId {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(&self.0)
+    }
+}
+
+/// Middleware assigning each request an ID, logging under it and echoing it
+/// in the response.
+pub async fn propagate_request_id(mut req: Request<Body>, next: Next<Body>) -> Response {
+    let request_id = RequestId::from_headers(req.headers()).unwrap_or_default();
+    req.extensions_mut().insert(request_id.clone());
+
+    let span = tracing::info_span!(
+        "request",
+        request_id = %request_id,
+        method = %req.method(),
+        path = %req.uri().path(),
+    );
+    let mut response = CURRENT_REQUEST_ID
+        .scope(request_id.clone(), next.run(req))
+        .instrument(span.clone())
+        .await;
+
+    let status = response.status();
+    if status.is_server_error() {
+        span.in_scope(|| tracing::warn!("Request failed with {}", status));
+    }
+    let value =
+        HeaderValue::from_str(request_id.as_str()).expect("request IDs are printable ASCII");
+    response.headers_mut().insert(REQUEST_ID_HEADER, value);
+    response
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{http::StatusCode, middleware, routing::get, Router};
+    use tower::ServiceExt;
+
+    fn app() -> Router {
+        Router::new()
+            .route(
+                "/",
+                get(|| async { RequestId::current().unwrap().to_string() }),
+            )
+            .route(
+                "/fail",
+                get(|| async { (StatusCode::INTERNAL_SERVER_ERROR, "boom") }),
+            )
+            .layer(middleware::from_fn(propagate_request_id))
+    }
+
+    #[tokio::test]
+    async fn test_reuses_caller_request_id() {
+        let request = Request::get("/")
+            .header(REQUEST_ID_HEADER, "ticket-1234")
+            .body(Body::empty())
+            .unwrap();
+        let response = app().oneshot(request).await.unwrap();
+        assert_eq!(response.headers()[REQUEST_ID_HEADER], "ticket-1234");
+        let body = hyper::body::to_bytes(response.into_body()).await.unwrap();
+        assert_eq!(&body[..], b"ticket-1234");
+    }
+
+    #[tokio::test]
+    async fn test_generates_request_id() {
+        let invalid = "x".repeat(MAX_REQUEST_ID_LEN + 1);
+        let request = Request::get("/fail")
+            .header(REQUEST_ID_HEADER, invalid.as_str())
+            .body(Body::empty())
+            .unwrap();
+        let response = app().oneshot(request).await.unwrap();
+        assert_eq!(response.status(), StatusCode::INTERNAL_SERVER_ERROR);
+        let request_id = response.headers()[REQUEST_ID_HEADER].to_str().unwrap();
+        assert!(uuid::Uuid::parse_str(request_id).is_ok());
+        assert!(RequestId::current().is_none());
+    }
+}
@@ -0,0 +1,35 @@
+//! Tenant suspension enforcement
+//!
+//! While the tenant this server serves is suspended (`POST
+//! /admin/tenants/{id}/suspend`), its API requests, reads included, are
+//! rejected with 403 until it is resumed; its data is kept. Admin endpoints
+//! and health probes stay available so the tenant can be resumed.
+
+use akidb_service::CollectionService;
+use axum::{
+    body::Body,
+    extract::State,
+    http::{Request, StatusCode},
+    middleware::Next,
+    response::Response,
+};
+use std::sync::Arc;
+
+/// Prefix of the tenant API paths rejected while the tenant is suspended.
+pub const TENANT_API_PREFIX: &str = "/api/";
+
+/// Middleware rejecting the tenant's API requests while it is suspended
+pub async fn reject_suspended_tenant(
+    State(service): State<Arc<CollectionService>>,
+    req: Request<Body>,
+    next: Next<Body>,
+) -> Result<Response, (StatusCode, String)> {
+    if req.uri().path().starts_with(TENANT_API_PREFIX) && service.is_tenant_suspended().await {
+        return Err((
+            StatusCode::FORBIDDEN,
+            "Tenant is suspended; contact support to resume it".to_string(),
+        ));
+    }
+
+    Ok(next.run(req).await)
+}
@@ -0,0 +1,195 @@
+//! Compact vector encodings
+//!
+//! JSON numbers take about four times the space of the floats they carry and
+//! are slow to print and parse, which dominates the cost of bulk ingest and
+//! search with high-dimensional vectors. Two compact encodings are accepted
+//! besides JSON arrays:
+//! - In JSON bodies, `vector` and `query_vector` may be a base64 string of
+//!   the raw vector (see below) instead of an array of numbers.
+//! - Insert and upsert (single and batch) and search accept a body of the
+//!   raw vector(s) with `Content-Type: application/octet-stream`. The other
+//!   fields of the request are then passed as query parameters.
+//!
+//! Raw vectors are little-endian IEEE 754 float32 values, back to back.
+
+use axum::{
+    async_trait,
+    body::{Body, Bytes},
+    extract::{FromRequest, FromRequestParts, Query},
+    http::{header, HeaderMap, Request, StatusCode},
+    response::{IntoResponse, Response},
+    Json,
+};
+use base64::{engine::general_purpose::STANDARD, Engine as _};
+use serde::{
+    de::{self, DeserializeOwned, SeqAccess, Visitor},
+    Deserializer,
+};
+use std::fmt;
+
+/// Content type of raw vector bodies.
+pub const OCTET_STREAM: &str = "application/octet-stream";
+
+/// Decodes raw little-endian float32 values.
+pub fn decode_vector(data: &[u8]) -> Result<Vec<f32>, String> {
+    if data.len() % 4 != 0 {
+        return Err(format!(
+            "binary vector of {} bytes is not a whole number of float32 values",
+            data.len()
+        ));
+    }
+    let vector: Vec<f32> = data
+        .chunks_exact(4)
+        .map(|b| f32::from_le_bytes([b[0], b[1], b[2], b[3]]))
+        .collect();
+    if !vector.iter().all(|v| v.is_finite()) {
+        return Err("binary vector contains NaN or infinite values".to_string());
+    }
+    Ok(vector)
+}
+
+/// Encodes a vector as raw little-endian float32 values.
+pub fn encode_vector(vector: &[f32]) -> Vec<u8> {
+    vector.iter().flat_map(|v| v.to_le_bytes()).collect()
+}
+
+/// Deserializes a vector given as an array of numbers or a base64 string
+/// of raw float32 values (`#[serde(deserialize_with = ...)]`).
+///
+/// The encoding is picked from the first token, so the array is read
+/// straight into the vector and errors point at the offending element.
+pub fn deserialize_vector<'de, D>(deserializer: D) -> Result<Vec<f32>, D::Error>
+where
+    D: Deserializer<'de>,
+{
+    deserializer.deserialize_any(VectorVisitor)
+}
+
+struct VectorVisitor;
+
+impl<'de> Visitor<'de> for VectorVisitor {
+    type Value = Vec<f32>;
+
+    fn expecting(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        f.write_str("an array of numbers or a base64 string of float32 values")
+    }
+
+    fn visit_seq<A>(self, mut seq: A) -> Result<Self::Value, A::Error>
+    where
+        A: SeqAccess<'de>,
+    {
+        let mut vector = Vec::with_capacity(seq.size_hint().unwrap_or(0).min(65_536));
+        while let Some(value) = seq.next_element()? {
+            vector.push(value);
+        }
+        Ok(vector)
+    }
+
+    fn visit_str<E>(self, data: &str) -> Result<Self::Value, E>
+    where
+        E: de::Error,
+    {
+        let data = STANDARD
+            .decode(data)
+            .map_err(|e| E::custom(format!("invalid base64 vector: {}", e)))?;
+        decode_vector(&data).map_err(E::custom)
+    }
+}
+
+/// Whether the request body is of the `application/octet-stream` type.
+pub fn is_octet_stream(headers: &HeaderMap) -> bool {
+    headers
+        .get(header::CONTENT_TYPE)
+        .and_then(|v| v.to_str().ok())
+        .and_then(|v| v.split(';').next())
+        .map_or(false, |mime| mime.trim().eq_ignore_ascii_case(OCTET_STREAM))
+}
+
+/// A request that can be sent as a raw vector body.
+pub trait FromBinaryVectors: Sized {
+    /// Fields of the request passed as query parameters.
+    type Params: DeserializeOwned;
+
+    /// Builds the request from the decoded body and query parameters.
+    fn from_binary(values: Vec<f32>, params: Self::Params) -> Result<Self, String>;
+}
+
+/// Extractor of a request sent as JSON or, with `Content-Type:
+/// application/octet-stream`, as a raw vector body and query parameters.
+pub struct VectorBody<T>(pub T);
+
+#[async_trait]
+impl<S, T> FromRequest<S, Body> for VectorBody<T>
+where
+    S: Send + Sync,
+    T: DeserializeOwned + FromBinaryVectors,
+{
+    type Rejection = Response;
+
+    async fn from_request(req: Request<Body>, state: &S) -> Result<Self, Self::Rejection> {
+        if !is_octet_stream(req.headers()) {
+            let Json(value) = Json::<T>::from_request(req, state)
+                .await
+                .map_err(IntoResponse::into_response)?;
+            return Ok(Self(value));
+        }
+
+        let (mut parts, body) = req.into_parts();
+        let Query(params) = Query::<T::Params>::from_request_parts(&mut parts, state)
+            .await
+            .map_err(IntoResponse::into_response)?;
+        let data = Bytes::from_request(Request::from_parts(parts, body), state)
+            .await
+            .map_err(IntoResponse::into_response)?;
+        let bad_request = |e: String| (StatusCode::BAD_REQUEST, e).into_response();
+        let values = decode_vector(&data).map_err(bad_request)?;
+        T::from_binary(values, params)
+            .map(Self)
+            .map_err(bad_request)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::http::HeaderValue;
+    use serde::Deserialize;
+
+    #[test]
+    fn test_binary_vectors() {
+        let vector = vec![0.5, -1.25, 3.0e10];
+        let data = encode_vector(&vector);
+        assert_eq!(data.len(), 12);
+        assert_eq!(&data[..4], &[0x00, 0x00, 0x00, 0x3f]);
+        assert_eq!(decode_vector(&data).unwrap(), vector);
+
+        assert!(decode_vector(&data[..5]).is_err());
+        assert!(decode_vector(&f32::NAN.to_le_bytes()).is_err());
+
+        #[derive(Deserialize)]
+        struct Doc {
+            #[serde(deserialize_with = "deserialize_vector")]
+            vector: Vec<f32>,
+        }
+        let base64 = serde_json::json!({ "vector": STANDARD.encode(&data) });
+        let doc: Doc = serde_json::from_value(base64).unwrap();
+        assert_eq!(doc.vector, vector);
+        let numbers: Doc = serde_json::from_str(r#"{"vector": [1, 2.5]}"#).unwrap();
+        assert_eq!(numbers.vector, [1.0, 2.5]);
+        assert!(serde_json::from_str::<Doc>(r#"{"vector": "not base64!"}"#).is_err());
+        let err = serde_json::from_str::<Doc>(r#"{"vector": [1, "x"]}"#)
+            .err()
+            .unwrap()
+            .to_string();
+        assert!(err.contains("expected f32"), "{}", err);
+        assert!(serde_json::from_str::<Doc>(r#"{"vector": {}}"#).is_err());
+
+        let mut headers = HeaderMap::new();
+        assert!(!is_octet_stream(&headers));
+        headers.insert(
+            header::CONTENT_TYPE,
+            HeaderValue::from_static("Application/Octet-Stream; charset=binary"),
+        );
+        assert!(is_octet_stream(&headers));
+    }
+}
@@ -0,0 +1,223 @@
+//! Latency SLO-aware adaptive search breadth.
+//!
+//! With adaptive search enabled, a collection's HNSW `ef_search` follows its
+//! search latency: after every window of searches, the p95 latency is
+//! compared with the configured SLO. Above the SLO, `ef_search` is lowered
+//! (trading recall for latency); well below it, `ef_search` is raised back to
+//! recover recall. The current operating point is reported by
+//! `CollectionService::adaptive_search_status`. Adaptive search overrides the
+//! `ef_search` search default of the collection.
+
+use akidb_core::{CoreError, CoreResult};
+use serde::{Deserialize, Serialize};
+use std::sync::Mutex;
+use std::time::Duration;
+
+/// `ef_search` used by HNSW indexes when none is set.
+pub const DEFAULT_EF_SEARCH: usize = 128;
+
+/// `ef_search` is raised when the p95 latency is below this fraction of the
+/// SLO.
+pub const HEADROOM_FRACTION: f64 = 0.5;
+
+/// Adaptive search configuration.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub struct AdaptiveSearchConfig {
+    /// Target p95 search latency in milliseconds.
+    pub latency_slo_ms: f64,
+
+    /// Lowest `ef_search` used (default: 16).
+    #[serde(default = "default_min_ef_search")]
+    pub min_ef_search: usize,
+
+    /// Highest `ef_search` used (default: 512).
+    #[serde(default = "default_max_ef_search")]
+    pub max_ef_search: usize,
+
+    /// Searches between adjustments (default: 100).
+    #[serde(default = "default_window")]
+    pub window: usize,
+}
+
+fn default_min_ef_search() -> usize {
+    16
+}
+
+fn default_max_ef_search() -> usize {
+    512
+}
+
+fn default_window() -> usize {
+    100
+}
+
+impl AdaptiveSearchConfig {
+    pub fn validate(&self) -> CoreResult<()> {
+        let invalid = |message: String| Err(CoreError::ValidationError(message));
+        if !self.latency_slo_ms.is_finite() || self.latency_slo_ms <= 0.0 {
+            return invalid(format!(
+                "latency_slo_ms must be a positive number (got {})",
+                self.latency_slo_ms
+            ));
+        }
+        if self.min_ef_search == 0 || self.min_ef_search > self.max_ef_search {
+            return invalid(format!(
+                "min_ef_search must be between 1 and max_ef_search (got {} and {})",
+                self.min_ef_search, self.max_ef_search
+            ));
+        }
+        if self.window < 20 {
+            return invalid(format!(
+                "window must be at least 20 searches (got {})",
+                self.window
+            ));
+        }
+        Ok(())
+    }
+}
+
+/// Current operating point of adaptive search.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct AdaptiveSearchStatus {
+    pub config: AdaptiveSearchConfig,
+
+    /// `ef_search` currently used.
+    pub ef_search: usize,
+
+    /// p95 latency of the last complete window, in milliseconds.
+    pub p95_latency_ms: Option<f64>,
+
+    /// Searches recorded in the current window.
+    pub samples: usize,
+
+    /// Times `ef_search` was changed.
+    pub adjustments: u64,
+}
+
+/// Adjusts a collection's `ef_search` to its search latency.
+#[derive(Debug)]
+pub struct AdaptiveSearch {
+    config: AdaptiveSearchConfig,
+    state: Mutex<AdaptiveState>,
+}
+
+#[derive(Debug)]
+struct AdaptiveState {
+    ef_search: usize,
+    latencies_ms: Vec<f64>,
+    p95_latency_ms: Option<f64>,
+    adjustments: u64,
+}
+
+impl AdaptiveSearch {
+    /// Starts at `ef_search`, clamped to the configured range.
+    pub fn new(config: AdaptiveSearchConfig, ef_search: usize) -> Self {
+        Self {
+            config,
+            state: Mutex::new(AdaptiveState {
+                ef_search: ef_search.clamp(config.min_ef_search, config.max_ef_search),
+                latencies_ms: Vec::with_capacity(config.window),
+                p95_latency_ms: None,
+                adjustments: 0,
+            }),
+        }
+    }
+
+    /// `ef_search` to use for the next search.
+    pub fn ef_search(&self) -> usize {
+        self.state.lock().unwrap().ef_search
+    }
+
+    /// Records the latency of a search, adjusting `ef_search` at the end of
+    /// a window.
+    pub fn record(&self, latency: Duration) {
+        let mut state = self.state.lock().unwrap();
+        state.latencies_ms.push(latency.as_secs_f64() * 1000.0);
+        if state.latencies_ms.len() < self.config.window {
+            return;
+        }
+
+        let mut latencies = std::mem::take(&mut state.latencies_ms);
+        latencies.sort_by(|a, b| a.total_cmp(b));
+        let rank = ((latencies.len() as f64 * 0.95).ceil() as usize).max(1);
+        let p95 = latencies[rank - 1];
+        state.p95_latency_ms = Some(p95);
+
+        let current = state.ef_search;
+        let next = if p95 > self.config.latency_slo_ms {
+            (current * 3 / 4).max(self.config.min_ef_search)
+        } else if p95 < self.config.latency_slo_ms * HEADROOM_FRACTION {
+            (current * 5 / 4)
+                .max(current + 1)
+                .min(self.config.max_ef_search)
+        } else {
+            current
+        };
+        if next != current {
+            state.ef_search = next;
+            state.adjustments += 1;
+        }
+    }
+
+    pub fn status(&self) -> AdaptiveSearchStatus {
+        let state = self.state.lock().unwrap();
+        AdaptiveSearchStatus {
+            config: self.config,
+            ef_search: state.ef_search,
+            p95_latency_ms: state.p95_latency_ms,
+            samples: state.latencies_ms.len(),
+            adjustments: state.adjustments,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_ef_search_follows_latency() {
+        let config = AdaptiveSearchConfig {
+            latency_slo_ms: 10.0,
+            min_ef_search: 32,
+            max_ef_search: 200,
+            window: 20,
+        };
+        config.validate().unwrap();
+        let adaptive = AdaptiveSearch::new(config, 128);
+        let record = |ms: u64| {
+            for _ in 0..config.window {
+                adaptive.record(Duration::from_millis(ms));
+            }
+        };
+
+        // SLO breached: lowered down to the minimum
+        record(20);
+        assert_eq!(adaptive.ef_search(), 96);
+        for _ in 0..10 {
+            record(20);
+        }
+        assert_eq!(adaptive.ef_search(), 32);
+
+        // Within the SLO, without headroom: unchanged
+        record(8);
+        assert_eq!(adaptive.ef_search(), 32);
+
+        // Headroom: raised up to the maximum
+        record(1);
+        assert_eq!(adaptive.ef_search(), 40);
+        for _ in 0..20 {
+            record(1);
+        }
+        let status = adaptive.status();
+        assert_eq!(status.ef_search, 200);
+        assert_eq!(status.p95_latency_ms, Some(1.0));
+        assert_eq!(status.samples, 0);
+
+        let invalid = AdaptiveSearchConfig {
+            min_ef_search: 300,
+            ..config
+        };
+        assert!(invalid.validate().is_err());
+    }
+}
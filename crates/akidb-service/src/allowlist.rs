@@ -0,0 +1,311 @@
+//! Network allowlists for tenants and API keys.
+//!
+//! An allowlist restricts the source addresses requests may come from, so
+//! credentials leaked outside the trusted network are useless. A tenant
+//! allowlist applies to every request; an API key allowlist additionally
+//! applies to requests made with that key. Without any allowlist, every
+//! address is allowed. See `CollectionService::set_ip_allowlist`.
+
+use akidb_core::{ApiKeyId, CoreError, CoreResult};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::fmt;
+use std::net::IpAddr;
+use std::str::FromStr;
+
+/// Maximum networks per allowlist.
+pub const MAX_ALLOWLIST_NETWORKS: usize = 256;
+
+/// An IP network in CIDR notation, e.g. `10.0.0.0/8` or `2001:db8::/32`.
+///
+/// A bare address is a single-host network.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(try_from = "String", into = "String")]
+pub struct IpNetwork {
+    addr: IpAddr,
+    prefix: u8,
+}
+
+impl IpNetwork {
+    pub fn new(addr: IpAddr, prefix: u8) -> CoreResult<Self> {
+        let max = max_prefix(&addr);
+        if prefix > max {
+            return Err(CoreError::ValidationError(format!(
+                "prefix length {} exceeds {} for {}",
+                prefix, max, addr
+            )));
+        }
+        Ok(Self {
+            addr: mask(addr, prefix),
+            prefix,
+        })
+    }
+
+    /// Returns true if `ip` is in the network. IPv4-mapped IPv6 addresses
+    /// (`::ffff:a.b.c.d`) match IPv4 networks.
+    pub fn contains(&self, ip: IpAddr) -> bool {
+        let ip = canonical(ip);
+        ip.is_ipv4() == self.addr.is_ipv4() && mask(ip, self.prefix) == self.addr
+    }
+}
+
+impl FromStr for IpNetwork {
+    type Err = CoreError;
+
+    fn from_str(s: &str) -> CoreResult<Self> {
+        let invalid = || CoreError::ValidationError(format!("invalid IP network: {:?}", s));
+        let (addr, prefix) = match s.trim().split_once('/') {
+            Some((addr, prefix)) => (addr, Some(prefix)),
+            None => (s.trim(), None),
+        };
+        let addr = canonical(IpAddr::from_str(addr).map_err(|_| invalid())?);
+        let prefix = match prefix {
+            Some(prefix) => prefix.parse().map_err(|_| invalid())?,
+            None => max_prefix(&addr),
+        };
+        Self::new(addr, prefix)
+    }
+}
+
+impl fmt::Display for IpNetwork {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{}/{}", self.addr, self.prefix)
+    }
+}
+
+impl TryFrom<String> for IpNetwork {
+    type Error = CoreError;
+
+    fn try_from(s: String) -> CoreResult<Self> {
+        s.parse()
+    }
+}
+
+impl From<IpNetwork> for String {
+    fn from(network: IpNetwork) -> Self {
+        network.to_string()
+    }
+}
+
+fn max_prefix(addr: &IpAddr) -> u8 {
+    if addr.is_ipv4() {
+        32
+    } else {
+        128
+    }
+}
+
+fn canonical(ip: IpAddr) -> IpAddr {
+    match ip {
+        IpAddr::V6(v6) => v6.to_ipv4_mapped().map_or(ip, IpAddr::V4),
+        IpAddr::V4(_) => ip,
+    }
+}
+
+fn mask(ip: IpAddr, prefix: u8) -> IpAddr {
+    match ip {
+        IpAddr::V4(v4) => {
+            let bits = u32::from(v4);
+            let mask = u32::MAX.checked_shl(32 - prefix as u32).unwrap_or(0);
+            IpAddr::V4((bits & mask).into())
+        }
+        IpAddr::V6(v6) => {
+            let bits = u128::from(v6);
+            let mask = u128::MAX.checked_shl(128 - prefix as u32).unwrap_or(0);
+            IpAddr::V6((bits & mask).into())
+        }
+    }
+}
+
+/// What an allowlist is attached to.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "snake_case")]
+pub enum AllowlistScope {
+    Tenant,
+    ApiKey { key_id: ApiKeyId },
+}
+
+impl AllowlistScope {
+    /// The API key, for API key allowlists.
+    pub fn key_id(&self) -> Option<ApiKeyId> {
+        match self {
+            Self::Tenant => None,
+            Self::ApiKey { key_id } => Some(*key_id),
+        }
+    }
+}
+
+impl fmt::Display for AllowlistScope {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Self::Tenant => write!(f, "tenant"),
+            Self::ApiKey { key_id } => write!(f, "API key {}", key_id),
+        }
+    }
+}
+
+/// Allowlist definition.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct AllowlistSpec {
+    /// Networks requests may come from. Delete the allowlist to allow all.
+    pub networks: Vec<IpNetwork>,
+
+    #[serde(default)]
+    pub description: Option<String>,
+}
+
+impl AllowlistSpec {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.networks.is_empty() {
+            return Err(CoreError::ValidationError(
+                "allowlist must contain at least one network; delete it to allow all addresses"
+                    .to_string(),
+            ));
+        }
+        if self.networks.len() > MAX_ALLOWLIST_NETWORKS {
+            return Err(CoreError::ValidationError(format!(
+                "allowlist must contain at most {} networks (got {})",
+                MAX_ALLOWLIST_NETWORKS,
+                self.networks.len()
+            )));
+        }
+        Ok(())
+    }
+}
+
+/// An allowlist attached to a tenant or API key.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct IpAllowlist {
+    pub scope: AllowlistScope,
+
+    #[serde(flatten)]
+    pub spec: AllowlistSpec,
+
+    pub updated_at: DateTime<Utc>,
+}
+
+impl IpAllowlist {
+    pub fn new(scope: AllowlistScope, spec: AllowlistSpec) -> CoreResult<Self> {
+        spec.validate()?;
+        Ok(Self {
+            scope,
+            spec,
+            updated_at: Utc::now(),
+        })
+    }
+
+    /// First network containing `ip`, if any.
+    pub fn matching_network(&self, ip: IpAddr) -> Option<IpNetwork> {
+        self.spec.networks.iter().copied().find(|n| n.contains(ip))
+    }
+
+    /// Evaluate `ip` against this allowlist.
+    pub fn evaluate(&self, ip: IpAddr) -> ScopeEvaluation {
+        let matched = self.matching_network(ip);
+        ScopeEvaluation {
+            allowed: matched.is_some(),
+            matched,
+        }
+    }
+}
+
+/// Result of checking an address against one allowlist.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ScopeEvaluation {
+    pub allowed: bool,
+
+    /// Network the address matched.
+    pub matched: Option<IpNetwork>,
+}
+
+/// Result of checking an address against the tenant's and an API key's
+/// allowlists. `tenant`/`api_key` are None when no allowlist is set.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct AllowlistEvaluation {
+    pub ip: IpAddr,
+
+    /// True if every applicable allowlist allows the address.
+    pub allowed: bool,
+
+    pub tenant: Option<ScopeEvaluation>,
+    pub api_key: Option<ScopeEvaluation>,
+}
+
+impl AllowlistEvaluation {
+    pub fn new(ip: IpAddr, tenant: Option<&IpAllowlist>, api_key: Option<&IpAllowlist>) -> Self {
+        let tenant = tenant.map(|list| list.evaluate(ip));
+        let api_key = api_key.map(|list| list.evaluate(ip));
+        Self {
+            ip,
+            allowed: [&tenant, &api_key]
+                .iter()
+                .all(|e| e.as_ref().map_or(true, |e| e.allowed)),
+            tenant,
+            api_key,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn ip(s: &str) -> IpAddr {
+        s.parse().unwrap()
+    }
+
+    fn allowlist(scope: AllowlistScope, networks: &[&str]) -> IpAllowlist {
+        let spec = AllowlistSpec {
+            networks: networks.iter().map(|n| n.parse().unwrap()).collect(),
+            description: None,
+        };
+        IpAllowlist::new(scope, spec).unwrap()
+    }
+
+    #[test]
+    fn test_parse_network() {
+        let network: IpNetwork = "10.1.2.3/8".parse().unwrap();
+        assert_eq!(network.to_string(), "10.0.0.0/8");
+        assert!(network.contains(ip("10.200.0.1")));
+        assert!(network.contains(ip("::ffff:10.0.0.1")));
+        assert!(!network.contains(ip("11.0.0.1")));
+
+        let host: IpNetwork = "2001:db8::1".parse().unwrap();
+        assert_eq!(host.to_string(), "2001:db8::1/128");
+        assert!(!host.contains(ip("2001:db8::2")));
+
+        let any: IpNetwork = "0.0.0.0/0".parse().unwrap();
+        assert!(any.contains(ip("203.0.113.7")));
+        assert!(!any.contains(ip("2001:db8::1")));
+
+        assert!("10.0.0.0/33".parse::<IpNetwork>().is_err());
+        assert!("vpc".parse::<IpNetwork>().is_err());
+    }
+
+    #[test]
+    fn test_evaluation_requires_every_allowlist() {
+        let tenant = allowlist(AllowlistScope::Tenant, &["10.0.0.0/8"]);
+        let key = allowlist(
+            AllowlistScope::ApiKey {
+                key_id: ApiKeyId::new(),
+            },
+            &["10.1.0.0/16"],
+        );
+
+        let evaluation = AllowlistEvaluation::new(ip("10.1.0.5"), Some(&tenant), Some(&key));
+        assert!(evaluation.allowed);
+        let evaluation = AllowlistEvaluation::new(ip("10.2.0.5"), Some(&tenant), Some(&key));
+        assert!(!evaluation.allowed);
+        assert!(evaluation.tenant.unwrap().allowed);
+        assert!(AllowlistEvaluation::new(ip("192.0.2.1"), None, None).allowed);
+    }
+
+    #[test]
+    fn test_empty_allowlist_rejected() {
+        let spec = AllowlistSpec {
+            networks: vec![],
+            description: None,
+        };
+        assert!(IpAllowlist::new(AllowlistScope::Tenant, spec).is_err());
+    }
+}
@@ -0,0 +1,599 @@
+//! Text analysis for text indexes.
+//!
+//! An [`Analyzer`] turns text into index terms: the [`Tokenizer`] splits and
+//! case-folds words (CJK runs become overlapping bigrams), stopwords are
+//! dropped, the language stemmer runs, synonyms are mapped to the first term
+//! of their synonym set, and optional n-gram expansion is applied last. The
+//! same analyzer is applied when building IDF stats and when encoding
+//! documents and queries, so synonyms and stems match in both directions.
+//!
+//! Stopwords and synonyms are configured per collection; language, tokenizer
+//! and n-gram settings ([`AnalyzerSettings`]) can be overridden per text field
+//! and per query.
+
+use akidb_core::{CoreError, CoreResult};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+
+/// Word tokenizer shared by the server and clients.
+///
+/// Splits on any non-alphanumeric character and optionally case-folds.
+/// CJK text has no word separators, so CJK runs are split into overlapping
+/// character bigrams instead.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct Tokenizer {
+    /// Case folding: lowercase tokens before hashing.
+    pub lowercase: bool,
+
+    /// Tokens shorter than this (in chars) are dropped (not applied to CJK bigrams).
+    pub min_token_len: usize,
+
+    /// Tokens longer than this (in chars) are dropped (not applied to CJK bigrams).
+    pub max_token_len: usize,
+
+    /// Split CJK runs into overlapping bigrams.
+    pub cjk_bigrams: bool,
+}
+
+impl Default for Tokenizer {
+    fn default() -> Self {
+        Self {
+            lowercase: true,
+            min_token_len: 1,
+            max_token_len: 64,
+            cjk_bigrams: true,
+        }
+    }
+}
+
+impl Tokenizer {
+    /// Splits text into tokens.
+    pub fn tokenize(&self, text: &str) -> Vec<String> {
+        self.tokenize_with_offsets(text)
+            .into_iter()
+            .map(|(_, _, token)| token)
+            .collect()
+    }
+
+    /// Splits text into tokens, returning each token's byte range in `text`.
+    pub fn tokenize_with_offsets(&self, text: &str) -> Vec<(usize, usize, String)> {
+        let mut tokens = Vec::new();
+        // Current run: (start offset, is CJK)
+        let mut run: Option<(usize, bool)> = None;
+        for (i, c) in text
+            .char_indices()
+            .chain(std::iter::once((text.len(), ' ')))
+        {
+            let kind = if self.cjk_bigrams && is_cjk(c) {
+                Some(true)
+            } else if c.is_alphanumeric() {
+                Some(false)
+            } else {
+                None
+            };
+
+            match (run, kind) {
+                (Some((_, cjk)), Some(k)) if cjk == k => {}
+                _ => {
+                    if let Some((start, cjk)) = run.take() {
+                        if cjk {
+                            self.push_bigrams(&mut tokens, text, start, i);
+                        } else {
+                            self.push_token(&mut tokens, text, start, i);
+                        }
+                    }
+                    run = kind.map(|k| (i, k));
+                }
+            }
+        }
+        tokens
+    }
+
+    fn push_token(
+        &self,
+        tokens: &mut Vec<(usize, usize, String)>,
+        text: &str,
+        start: usize,
+        end: usize,
+    ) {
+        let raw = &text[start..end];
+        let len = raw.chars().count();
+        if len < self.min_token_len || len > self.max_token_len {
+            return;
+        }
+        tokens.push((start, end, self.fold(raw)));
+    }
+
+    fn push_bigrams(
+        &self,
+        tokens: &mut Vec<(usize, usize, String)>,
+        text: &str,
+        start: usize,
+        end: usize,
+    ) {
+        let bounds: Vec<usize> = text[start..end]
+            .char_indices()
+            .map(|(i, _)| start + i)
+            .chain(std::iter::once(end))
+            .collect();
+        if bounds.len() == 2 {
+            tokens.push((start, end, self.fold(&text[start..end])));
+            return;
+        }
+        for w in bounds.windows(3) {
+            tokens.push((w[0], w[2], self.fold(&text[w[0]..w[2]])));
+        }
+    }
+
+    fn fold(&self, raw: &str) -> String {
+        if self.lowercase {
+            raw.to_lowercase()
+        } else {
+            raw.to_string()
+        }
+    }
+
+    /// Stable token ID (32-bit FNV-1a hash of the UTF-8 bytes).
+    pub fn token_id(token: &str) -> u32 {
+        token.bytes().fold(0x811c_9dc5u32, |hash, byte| {
+            (hash ^ byte as u32).wrapping_mul(0x0100_0193)
+        })
+    }
+}
+
+/// Han, Hiragana, Katakana and Hangul characters.
+fn is_cjk(c: char) -> bool {
+    matches!(c,
+        '\u{3040}'..='\u{30FF}'
+        | '\u{3400}'..='\u{4DBF}'
+        | '\u{4E00}'..='\u{9FFF}'
+        | '\u{AC00}'..='\u{D7AF}'
+        | '\u{F900}'..='\u{FAFF}'
+        | '\u{20000}'..='\u{2A6DF}')
+}
+
+/// Stemming language for a text field.
+///
+/// Stemmers are light suffix strippers (plural and common inflection
+/// suffixes), not full Snowball implementations.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Language {
+    /// No stemming.
+    #[default]
+    None,
+    English,
+    French,
+    German,
+    Spanish,
+}
+
+impl Language {
+    /// Stems a (case-folded) token.
+    pub fn stem(&self, token: &str) -> String {
+        match self {
+            Self::None => token.to_string(),
+            Self::English => stem_english(token),
+            Self::French => strip_suffix(
+                token,
+                &[
+                    "ements", "ement", "ations", "ation", "euses", "euse", "eux", "ments", "ment",
+                    "ives", "ive", "es", "s", "x", "e",
+                ],
+            ),
+            Self::German => strip_suffix(token, &["ern", "em", "en", "er", "es", "e", "s"]),
+            Self::Spanish => strip_suffix(
+                token,
+                &[
+                    "amientos", "imientos", "amiento", "imiento", "aciones", "ación", "mente",
+                    "idades", "idad", "ces", "es", "os", "as", "o", "a", "s",
+                ],
+            ),
+        }
+    }
+}
+
+/// Minimum number of chars kept by the suffix strippers.
+const MIN_STEM_LEN: usize = 3;
+
+/// Strips the first (longest-listed-first) matching suffix that leaves a stem
+/// of at least `MIN_STEM_LEN` chars.
+fn strip_suffix(token: &str, suffixes: &[&str]) -> String {
+    for suffix in suffixes {
+        if let Some(stem) = token.strip_suffix(suffix) {
+            if stem.chars().count() >= MIN_STEM_LEN {
+                return stem.to_string();
+            }
+        }
+    }
+    token.to_string()
+}
+
+fn stem_english(token: &str) -> String {
+    let len = token.chars().count();
+    if len <= MIN_STEM_LEN {
+        return token.to_string();
+    }
+
+    // Plurals
+    let word = if let Some(stem) = token.strip_suffix("ies") {
+        format!("{}y", stem)
+    } else if token.ends_with("sses") || token.ends_with("xes") || token.ends_with("ches") {
+        token[..token.len() - 2].to_string()
+    } else if token.ends_with('s') && !token.ends_with("ss") && !token.ends_with("us") {
+        token[..token.len() - 1].to_string()
+    } else {
+        token.to_string()
+    };
+
+    // Inflections: -ing, -ed, -ly (only if a vowel remains in the stem)
+    for suffix in ["ing", "ed", "ly"] {
+        if let Some(stem) = word.strip_suffix(suffix) {
+            if stem.chars().count() >= MIN_STEM_LEN && stem.chars().any(is_vowel) {
+                return undouble(stem);
+            }
+        }
+    }
+    word
+}
+
+fn is_vowel(c: char) -> bool {
+    matches!(c, 'a' | 'e' | 'i' | 'o' | 'u' | 'y')
+}
+
+/// "runn" -> "run", but keeps "ll", "ss" and "zz" ("fall", "miss", "buzz").
+fn undouble(stem: &str) -> String {
+    let mut chars = stem.chars().rev();
+    match (chars.next(), chars.next()) {
+        (Some(a), Some(b)) if a == b && !is_vowel(a) && !matches!(a, 'l' | 's' | 'z') => {
+            stem[..stem.len() - a.len_utf8()].to_string()
+        }
+        _ => stem.to_string(),
+    }
+}
+
+/// Character n-gram expansion (for partial and fuzzy matching).
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct NgramConfig {
+    pub min: usize,
+    pub max: usize,
+}
+
+/// Analysis options that can be set per text field or overridden per query.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct AnalyzerSettings {
+    pub tokenizer: Tokenizer,
+
+    /// Stemming language.
+    pub language: Language,
+
+    /// Expand each term into character n-grams (None = whole terms).
+    pub ngram: Option<NgramConfig>,
+}
+
+impl AnalyzerSettings {
+    /// Validates tokenizer and n-gram bounds.
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.tokenizer.min_token_len > self.tokenizer.max_token_len {
+            return Err(CoreError::ValidationError(
+                "min_token_len must not exceed max_token_len".to_string(),
+            ));
+        }
+        if let Some(ngram) = self.ngram {
+            if ngram.min == 0 || ngram.min > ngram.max {
+                return Err(CoreError::ValidationError(
+                    "ngram bounds must satisfy 1 <= min <= max".to_string(),
+                ));
+            }
+        }
+        Ok(())
+    }
+}
+
+/// A single term produced by [`Analyzer::analyze`].
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct AnalyzedToken {
+    /// Index term after stopword, synonym, stemming and n-gram processing.
+    pub token: String,
+
+    /// Source text of the token (before normalization).
+    pub original: String,
+
+    /// Position in the token stream (stopwords leave gaps; n-grams share
+    /// the position of their term).
+    pub position: usize,
+
+    /// Byte offsets of `original` in the input text.
+    pub start_offset: usize,
+    pub end_offset: usize,
+}
+
+/// Text analyzer: field settings plus the collection's stopwords and synonyms.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct Analyzer {
+    #[serde(flatten)]
+    pub settings: AnalyzerSettings,
+
+    /// Terms removed from the token stream (normalized by the tokenizer).
+    #[serde(default)]
+    pub stopwords: BTreeSet<String>,
+
+    /// Synonym sets; every term in a set is indexed as the set's first term.
+    #[serde(default)]
+    pub synonyms: Vec<Vec<String>>,
+}
+
+impl Analyzer {
+    /// Replaces the stopword list.
+    ///
+    /// Stopwords are normalized with the tokenizer so they match analyzed text.
+    pub fn with_stopwords(mut self, stopwords: impl IntoIterator<Item = impl AsRef<str>>) -> Self {
+        self.stopwords = stopwords
+            .into_iter()
+            .flat_map(|word| self.settings.tokenizer.tokenize(word.as_ref()))
+            .collect();
+        self
+    }
+
+    /// Replaces the synonym sets.
+    ///
+    /// # Errors
+    ///
+    /// Returns a validation error if a set has fewer than two terms, or a term
+    /// does not analyze to exactly one token (multi-word synonyms are not supported).
+    pub fn with_synonyms(mut self, synonyms: Vec<Vec<String>>) -> CoreResult<Self> {
+        let mut normalized = Vec::with_capacity(synonyms.len());
+        for set in synonyms {
+            if set.len() < 2 {
+                return Err(CoreError::ValidationError(format!(
+                    "synonym set must contain at least 2 terms: {:?}",
+                    set
+                )));
+            }
+            let mut terms = Vec::with_capacity(set.len());
+            for term in &set {
+                match self.settings.tokenizer.tokenize(term).as_slice() {
+                    [token] => terms.push(token.clone()),
+                    _ => {
+                        return Err(CoreError::ValidationError(format!(
+                            "synonym must be a single token: {:?}",
+                            term
+                        )))
+                    }
+                }
+            }
+            normalized.push(terms);
+        }
+        self.synonyms = normalized;
+        Ok(self)
+    }
+
+    /// Replaces the field-level settings, keeping stopwords and synonyms.
+    pub fn with_settings(mut self, settings: AnalyzerSettings) -> Self {
+        self.settings = settings;
+        self
+    }
+
+    /// Analyzes text, returning each emitted term with its source and position.
+    pub fn analyze(&self, text: &str) -> Vec<AnalyzedToken> {
+        let synonyms = self.synonym_map();
+        let mut tokens = Vec::new();
+        for (position, (start, end, token)) in self
+            .settings
+            .tokenizer
+            .tokenize_with_offsets(text)
+            .into_iter()
+            .enumerate()
+        {
+            if self.stopwords.contains(&token) {
+                continue;
+            }
+            let stemmed = self.settings.language.stem(&token);
+            let term = synonyms.get(&stemmed).cloned().unwrap_or(stemmed);
+
+            for gram in self.expand_ngrams(term) {
+                tokens.push(AnalyzedToken {
+                    token: gram,
+                    original: text[start..end].to_string(),
+                    position,
+                    start_offset: start,
+                    end_offset: end,
+                });
+            }
+        }
+        tokens
+    }
+
+    /// Analyzes text into index terms.
+    pub fn terms(&self, text: &str) -> Vec<String> {
+        self.analyze(text).into_iter().map(|t| t.token).collect()
+    }
+
+    fn expand_ngrams(&self, term: String) -> Vec<String> {
+        let Some(ngram) = self.settings.ngram else {
+            return vec![term];
+        };
+        let chars: Vec<char> = term.chars().collect();
+        if chars.len() < ngram.min {
+            return vec![term];
+        }
+        let mut grams = Vec::new();
+        for n in ngram.min..=ngram.max.min(chars.len()) {
+            grams.extend(chars.windows(n).map(|w| w.iter().collect::<String>()));
+        }
+        grams
+    }
+
+    /// Maps each (stemmed) synonym to its set's (stemmed) canonical term.
+    fn synonym_map(&self) -> HashMap<String, String> {
+        let language = self.settings.language;
+        let mut map = HashMap::new();
+        for set in &self.synonyms {
+            if let Some((canonical, rest)) = set.split_first() {
+                let canonical = language.stem(canonical);
+                for term in rest {
+                    map.insert(language.stem(term), canonical.clone());
+                }
+            }
+        }
+        map
+    }
+}
+
+/// Text analysis configuration for a collection.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct TextAnalysis {
+    /// Collection-wide analyzer (stopwords, synonyms and default settings).
+    #[serde(flatten)]
+    pub analyzer: Analyzer,
+
+    /// Per-field settings overriding the collection defaults.
+    #[serde(default)]
+    pub fields: BTreeMap<String, AnalyzerSettings>,
+}
+
+impl TextAnalysis {
+    /// Resolves the analyzer for a text field.
+    pub fn analyzer_for(&self, field: &str) -> Analyzer {
+        match self.fields.get(field) {
+            Some(settings) => self.analyzer.clone().with_settings(settings.clone()),
+            None => self.analyzer.clone(),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_tokenizer() {
+        let tokenizer = Tokenizer {
+            min_token_len: 2,
+            ..Default::default()
+        };
+        assert_eq!(
+            tokenizer.tokenize("Hello, World! a-b c3po"),
+            vec!["hello", "world", "c3po"]
+        );
+        assert_eq!(Tokenizer::token_id("hello"), Tokenizer::token_id("hello"));
+        assert_ne!(Tokenizer::token_id("hello"), Tokenizer::token_id("world"));
+    }
+
+    #[test]
+    fn test_cjk_bigrams() {
+        let tokenizer = Tokenizer::default();
+        assert_eq!(
+            tokenizer.tokenize("東京都 in Tokyo、日"),
+            vec!["東京", "京都", "in", "tokyo", "日"]
+        );
+
+        let spans = tokenizer.tokenize_with_offsets("東京都");
+        assert_eq!((spans[1].0, spans[1].1), (3, 9));
+
+        let whole = Tokenizer {
+            cjk_bigrams: false,
+            ..Default::default()
+        };
+        assert_eq!(whole.tokenize("東京都"), vec!["東京都"]);
+    }
+
+    #[test]
+    fn test_stopwords_leave_position_gaps() {
+        let analyzer = Analyzer::default().with_stopwords(["The", "of"]);
+        let tokens = analyzer.analyze("The Lord of the Rings");
+
+        let terms: Vec<_> = tokens.iter().map(|t| t.token.as_str()).collect();
+        assert_eq!(terms, vec!["lord", "rings"]);
+        assert_eq!(tokens[0].position, 1);
+        assert_eq!(tokens[1].position, 4);
+        assert_eq!(tokens[1].original, "Rings");
+        assert_eq!((tokens[1].start_offset, tokens[1].end_offset), (16, 21));
+    }
+
+    #[test]
+    fn test_synonyms_map_to_canonical_term() {
+        let analyzer = Analyzer::default()
+            .with_synonyms(vec![vec!["car".to_string(), "Automobile".to_string()]])
+            .unwrap();
+        assert_eq!(analyzer.terms("fast automobile"), vec!["fast", "car"]);
+        assert_eq!(analyzer.terms("fast car"), vec!["fast", "car"]);
+    }
+
+    #[test]
+    fn test_invalid_synonyms_rejected() {
+        assert!(Analyzer::default()
+            .with_synonyms(vec![vec!["solo".to_string()]])
+            .is_err());
+        assert!(Analyzer::default()
+            .with_synonyms(vec![vec!["nyc".to_string(), "new york".to_string()]])
+            .is_err());
+    }
+
+    #[test]
+    fn test_language_stemming() {
+        let english = Language::English;
+        for (word, stem) in [
+            ("running", "run"),
+            ("runs", "run"),
+            ("cities", "city"),
+            ("jumped", "jump"),
+            ("boxes", "box"),
+            ("falling", "fall"),
+            ("glass", "glass"),
+            ("sing", "sing"),
+        ] {
+            assert_eq!(english.stem(word), stem, "{}", word);
+        }
+
+        assert_eq!(Language::French.stem("rapidement"), "rapid");
+        assert_eq!(Language::German.stem("häusern"), "häus");
+        assert_eq!(Language::Spanish.stem("canciones"), "cancion");
+        assert_eq!(Language::None.stem("running"), "running");
+    }
+
+    #[test]
+    fn test_ngrams() {
+        let analyzer = Analyzer::default().with_settings(AnalyzerSettings {
+            ngram: Some(NgramConfig { min: 2, max: 3 }),
+            ..Default::default()
+        });
+        let tokens = analyzer.analyze("Rust a");
+        let terms: Vec<_> = tokens.iter().map(|t| t.token.as_str()).collect();
+        assert_eq!(terms, vec!["ru", "us", "st", "rus", "ust", "a"]);
+        assert!(tokens[..5].iter().all(|t| t.position == 0));
+
+        assert!(AnalyzerSettings {
+            ngram: Some(NgramConfig { min: 3, max: 2 }),
+            ..Default::default()
+        }
+        .validate()
+        .is_err());
+    }
+
+    #[test]
+    fn test_field_settings_override_collection_defaults() {
+        let mut analysis = TextAnalysis {
+            analyzer: Analyzer::default().with_stopwords(["the"]),
+            ..Default::default()
+        };
+        analysis.fields.insert(
+            "title_en".to_string(),
+            AnalyzerSettings {
+                language: Language::English,
+                ..Default::default()
+            },
+        );
+
+        assert_eq!(
+            analysis.analyzer_for("title_en").terms("the cats"),
+            vec!["cat"]
+        );
+        assert_eq!(
+            analysis.analyzer_for("body").terms("the cats"),
+            vec!["cats"]
+        );
+    }
+}
@@ -0,0 +1,348 @@
+//! Access anomaly detection.
+//!
+//! Flags access patterns that may indicate data exfiltration so operators can
+//! alert on them:
+//! - mass scans: the documents read from a collection (fetched by ID or
+//!   returned by queries) within a short window add up to a large share of
+//!   the collection, as when a client pages through all of it;
+//! - bulk exports: a compliance export of every document of the tenant.
+//!
+//! Detection is a per-server heuristic. Flagged events are kept in memory (the
+//! last `ANOMALY_HISTORY_LIMIT`) and pushed to subscribers as they happen.
+//! Requests are not authenticated, so patterns tied to an API key (such as a
+//! key reaching unusual tenants) cannot be detected yet.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, VecDeque};
+use std::sync::Mutex;
+use std::time::{Duration, Instant};
+use tokio::sync::broadcast;
+
+/// Flagged events kept for the events API.
+pub const ANOMALY_HISTORY_LIMIT: usize = 500;
+
+/// Events buffered per subscriber before slow subscribers start lagging.
+pub const ANOMALY_BUFFER: usize = 64;
+
+/// Mass scan detection thresholds.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub struct AnomalyConfig {
+    /// Window over which a collection's reads are added up (default: 60).
+    #[serde(default = "default_window_secs")]
+    pub window_secs: u64,
+
+    /// Share of a collection read within the window that counts as a scan
+    /// (default: 0.5).
+    #[serde(default = "default_scan_fraction")]
+    pub scan_fraction: f64,
+
+    /// Reads within the window below which nothing is flagged, so small
+    /// collections are not flagged on every listing (default: 1000).
+    #[serde(default = "default_min_documents")]
+    pub min_documents: u64,
+}
+
+fn default_window_secs() -> u64 {
+    60
+}
+
+fn default_scan_fraction() -> f64 {
+    0.5
+}
+
+fn default_min_documents() -> u64 {
+    1000
+}
+
+impl Default for AnomalyConfig {
+    fn default() -> Self {
+        Self {
+            window_secs: default_window_secs(),
+            scan_fraction: default_scan_fraction(),
+            min_documents: default_min_documents(),
+        }
+    }
+}
+
+impl AnomalyConfig {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.window_secs == 0 {
+            return Err(CoreError::ValidationError(
+                "anomaly window_secs must be greater than 0".to_string(),
+            ));
+        }
+        if !(self.scan_fraction > 0.0 && self.scan_fraction <= 1.0) {
+            return Err(CoreError::ValidationError(format!(
+                "anomaly scan_fraction must be in (0, 1] (got {})",
+                self.scan_fraction
+            )));
+        }
+        Ok(())
+    }
+
+    /// Reads within the window that flag a collection of `collection_size`.
+    fn scan_threshold(&self, collection_size: u64) -> u64 {
+        let share = (collection_size as f64 * self.scan_fraction).ceil() as u64;
+        share.max(self.min_documents)
+    }
+}
+
+/// Kind of a flagged access pattern.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum AnomalyKind {
+    MassScan,
+    BulkExport,
+}
+
+/// A flagged access pattern.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct AnomalyEvent {
+    /// Increasing sequence number; pass as `after` to fetch newer events.
+    pub id: u64,
+
+    pub kind: AnomalyKind,
+    pub collection_id: Option<CollectionId>,
+    pub message: String,
+
+    /// Documents read within the window (mass scans).
+    pub documents_read: Option<u64>,
+
+    pub detected_at: DateTime<Utc>,
+}
+
+#[derive(Default)]
+struct ReadWindow {
+    reads: VecDeque<(Instant, u64)>,
+    total: u64,
+    flagged_at: Option<Instant>,
+}
+
+struct MonitorState {
+    config: AnomalyConfig,
+    windows: HashMap<CollectionId, ReadWindow>,
+    events: VecDeque<AnomalyEvent>,
+    next_id: u64,
+}
+
+/// Tracks reads per collection and keeps the flagged events.
+pub struct AccessMonitor {
+    state: Mutex<MonitorState>,
+    sender: broadcast::Sender<AnomalyEvent>,
+}
+
+impl AccessMonitor {
+    pub fn new(config: AnomalyConfig) -> Self {
+        let (sender, _) = broadcast::channel(ANOMALY_BUFFER);
+        Self {
+            state: Mutex::new(MonitorState {
+                config,
+                windows: HashMap::new(),
+                events: VecDeque::new(),
+                next_id: 1,
+            }),
+            sender,
+        }
+    }
+
+    pub fn config(&self) -> AnomalyConfig {
+        self.state.lock().unwrap().config
+    }
+
+    pub fn set_config(&self, config: AnomalyConfig) -> CoreResult<()> {
+        config.validate()?;
+        self.state.lock().unwrap().config = config;
+        Ok(())
+    }
+
+    /// Record `documents` read from a collection holding `collection_size`
+    /// documents, flagging a mass scan if the window's reads reach the
+    /// threshold. A collection is flagged at most once per window.
+    pub fn record_reads(
+        &self,
+        collection_id: CollectionId,
+        documents: u64,
+        collection_size: u64,
+        now: Instant,
+    ) -> Option<AnomalyEvent> {
+        if documents == 0 {
+            return None;
+        }
+        let mut state = self.state.lock().unwrap();
+        let config = state.config;
+        let window_len = Duration::from_secs(config.window_secs);
+
+        let window = state.windows.entry(collection_id).or_default();
+        while let Some(&(at, count)) = window.reads.front() {
+            if now.duration_since(at) < window_len {
+                break;
+            }
+            window.total -= count;
+            window.reads.pop_front();
+        }
+        window.reads.push_back((now, documents));
+        window.total += documents;
+
+        if window.total < config.scan_threshold(collection_size)
+            || window
+                .flagged_at
+                .map_or(false, |at| now.duration_since(at) < window_len)
+        {
+            return None;
+        }
+        window.flagged_at = Some(now);
+        let total = window.total;
+
+        let message = format!(
+            "{} documents read from collection {} ({} total) within {}s",
+            total, collection_id, collection_size, config.window_secs
+        );
+        Some(self.push(
+            &mut state,
+            AnomalyKind::MassScan,
+            Some(collection_id),
+            message,
+            Some(total),
+        ))
+    }
+
+    /// Flag an access pattern detected elsewhere.
+    pub fn flag(
+        &self,
+        kind: AnomalyKind,
+        collection_id: Option<CollectionId>,
+        message: String,
+    ) -> AnomalyEvent {
+        let mut state = self.state.lock().unwrap();
+        self.push(&mut state, kind, collection_id, message, None)
+    }
+
+    /// Flagged events with an ID greater than `after` (all kept events when
+    /// None), oldest first.
+    pub fn events(&self, after: Option<u64>) -> Vec<AnomalyEvent> {
+        let state = self.state.lock().unwrap();
+        state
+            .events
+            .iter()
+            .filter(|event| after.map_or(true, |after| event.id > after))
+            .cloned()
+            .collect()
+    }
+
+    /// Stream of events flagged from now on.
+    pub fn subscribe(&self) -> broadcast::Receiver<AnomalyEvent> {
+        self.sender.subscribe()
+    }
+
+    /// Drop a deleted collection's read window (its events are kept).
+    pub fn forget(&self, collection_id: CollectionId) {
+        self.state.lock().unwrap().windows.remove(&collection_id);
+    }
+
+    fn push(
+        &self,
+        state: &mut MonitorState,
+        kind: AnomalyKind,
+        collection_id: Option<CollectionId>,
+        message: String,
+        documents_read: Option<u64>,
+    ) -> AnomalyEvent {
+        let event = AnomalyEvent {
+            id: state.next_id,
+            kind,
+            collection_id,
+            message,
+            documents_read,
+            detected_at: Utc::now(),
+        };
+        state.next_id += 1;
+        tracing::warn!("Access anomaly ({:?}): {}", kind, event.message);
+
+        state.events.push_back(event.clone());
+        while state.events.len() > ANOMALY_HISTORY_LIMIT {
+            state.events.pop_front();
+        }
+        // No subscribers is fine
+        let _ = self.sender.send(event.clone());
+        event
+    }
+}
+
+impl Default for AccessMonitor {
+    fn default() -> Self {
+        Self::new(AnomalyConfig::default())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn monitor() -> AccessMonitor {
+        AccessMonitor::new(AnomalyConfig {
+            window_secs: 60,
+            scan_fraction: 0.5,
+            min_documents: 10,
+        })
+    }
+
+    #[test]
+    fn test_mass_scan_flagged_once_per_window() {
+        let monitor = monitor();
+        let collection_id = CollectionId::new();
+        let start = Instant::now();
+
+        // 40 of 100 documents: below the 50% threshold
+        assert!(monitor
+            .record_reads(collection_id, 40, 100, start)
+            .is_none());
+        let event = monitor
+            .record_reads(collection_id, 10, 100, start + Duration::from_secs(10))
+            .unwrap();
+        assert_eq!(event.kind, AnomalyKind::MassScan);
+        assert_eq!(event.documents_read, Some(50));
+
+        // Still scanning in the same window: not flagged again
+        assert!(monitor
+            .record_reads(collection_id, 50, 100, start + Duration::from_secs(20))
+            .is_none());
+        assert_eq!(monitor.events(None).len(), 1);
+        assert!(monitor.events(Some(event.id)).is_empty());
+    }
+
+    #[test]
+    fn test_old_reads_leave_the_window() {
+        let monitor = monitor();
+        let collection_id = CollectionId::new();
+        let start = Instant::now();
+
+        assert!(monitor
+            .record_reads(collection_id, 40, 100, start)
+            .is_none());
+        assert!(monitor
+            .record_reads(collection_id, 40, 100, start + Duration::from_secs(61))
+            .is_none());
+        // Small collections need min_documents reads
+        assert!(monitor
+            .record_reads(CollectionId::new(), 9, 10, start)
+            .is_none());
+    }
+
+    #[test]
+    fn test_subscribers_receive_flags() {
+        let monitor = monitor();
+        let mut events = monitor.subscribe();
+        monitor.flag(AnomalyKind::BulkExport, None, "export".to_string());
+        assert_eq!(events.try_recv().unwrap().kind, AnomalyKind::BulkExport);
+    }
+
+    #[test]
+    fn test_config_validation() {
+        let mut config = AnomalyConfig::default();
+        assert!(config.validate().is_ok());
+        config.scan_fraction = 1.5;
+        assert!(config.validate().is_err());
+    }
+}
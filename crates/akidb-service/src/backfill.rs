@@ -0,0 +1,126 @@
+//! Backfill jobs for adding a new vector to an existing collection.
+//!
+//! The new vector (e.g. an embedding from a second model) lives in a target
+//! collection keyed by the same document IDs as the source. A backfill job
+//! streams source records that have no vector in the target yet, in document
+//! ID order, so a pool of workers can compute vectors and patch them back.
+//! Batches handed out to concurrent workers never overlap.
+//!
+//! Progress is measured against the target collection rather than the job
+//! cursor, so a crashed worker never loses records: resuming a job rewinds
+//! the cursor and re-streams only the records that are still missing. See
+//! `CollectionService::create_backfill_job`.
+
+use akidb_core::{CollectionId, DocumentId, JobId};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+
+/// Maximum number of records returned by one batch request.
+pub const MAX_BACKFILL_BATCH: usize = 1000;
+
+/// Lifecycle state of a backfill job.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum BackfillStatus {
+    /// Handing out batches and accepting patches.
+    Running,
+
+    /// Every source record has a vector in the target.
+    Completed,
+
+    /// Stopped by a caller; can be resumed.
+    Cancelled,
+}
+
+/// A backfill job copying records from `source` into `target` with new vectors.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BackfillJob {
+    pub id: JobId,
+
+    /// Collection holding the records to backfill.
+    pub source: CollectionId,
+
+    /// Collection receiving the new vectors under the source document IDs.
+    pub target: CollectionId,
+
+    pub status: BackfillStatus,
+
+    /// Last document ID handed out (None = start of the collection).
+    pub cursor: Option<DocumentId>,
+
+    /// True once the cursor has passed the last source record.
+    pub exhausted: bool,
+
+    pub created_at: DateTime<Utc>,
+    pub updated_at: DateTime<Utc>,
+}
+
+impl BackfillJob {
+    /// Creates a running job starting at the beginning of the source.
+    pub fn new(source: CollectionId, target: CollectionId) -> Self {
+        let now = Utc::now();
+        Self {
+            id: JobId::new(),
+            source,
+            target,
+            status: BackfillStatus::Running,
+            cursor: None,
+            exhausted: false,
+            created_at: now,
+            updated_at: now,
+        }
+    }
+
+    /// Rewinds the cursor so the next batches re-stream records still missing a vector.
+    pub fn rewind(&mut self) {
+        self.cursor = None;
+        self.exhausted = false;
+        self.status = BackfillStatus::Running;
+        self.updated_at = Utc::now();
+    }
+}
+
+/// A source record that needs a vector computed.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BackfillRecord {
+    pub doc_id: DocumentId,
+    pub external_id: Option<String>,
+
+    /// Source metadata (typically holds the text to embed).
+    pub metadata: Option<JsonValue>,
+}
+
+/// A batch of records for one worker.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BackfillBatch {
+    pub job_id: JobId,
+    pub records: Vec<BackfillRecord>,
+
+    /// True when the cursor reached the end of the source. Records handed out
+    /// but never patched are streamed again after `resume_backfill`.
+    pub exhausted: bool,
+}
+
+/// A computed vector for one source record.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BackfillPatch {
+    pub doc_id: DocumentId,
+    pub vector: Vec<f32>,
+}
+
+/// Backfill progress, computed from the target collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BackfillProgress {
+    pub job_id: JobId,
+    pub status: BackfillStatus,
+
+    /// Records in the source collection.
+    pub total: usize,
+
+    /// Source records that have a vector in the target.
+    pub completed: usize,
+
+    /// Source records still missing a vector.
+    pub remaining: usize,
+}
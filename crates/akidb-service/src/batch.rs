@@ -0,0 +1,293 @@
+//! Batch inserts, upserts and deletes.
+//!
+//! A batch is written document by document; one failing document does not
+//! stop the others. Duplicate IDs are reported explicitly instead of showing
+//! up as anonymous failures. On insert, IDs repeated within the batch are
+//! written once (first occurrence) and IDs already stored are left untouched;
+//! on upsert, every occurrence is written in order, so the last one wins.
+//! See `CollectionService::insert_batch` and `CollectionService::upsert_batch`.
+//!
+//! An upsert batch can also derive each document's ID from a hash of chosen
+//! fields (`ContentIdSpec`), e.g. text and source, so re-ingesting the same
+//! content replaces the stored document instead of adding a copy.
+
+use akidb_core::{CoreError, CoreResult, DocumentId, VectorDocument};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::HashMap;
+
+/// Maximum documents per batch.
+pub const MAX_BATCH_SIZE: usize = 1_000;
+
+/// Maximum IDs per batch delete (deletes carry no vectors, so they can be
+/// larger).
+pub const MAX_DELETE_BATCH_SIZE: usize = 10_000;
+
+/// Maximum errors listed in a batch report.
+pub const MAX_BATCH_ERRORS: usize = 100;
+
+/// Maximum fields hashed into a content-derived ID.
+pub const MAX_ID_FIELDS: usize = 16;
+
+/// Field name selecting the document's external ID rather than a metadata
+/// field.
+pub const EXTERNAL_ID_FIELD: &str = "external_id";
+
+/// What the server did with a duplicate ID.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum DuplicateAction {
+    /// Repeated within the batch: the first occurrence was written and the
+    /// later ones skipped.
+    SkippedRepeats,
+    /// Already stored: the stored document was kept and every occurrence in
+    /// the batch skipped.
+    KeptExisting,
+    /// Repeated within an upsert batch: every occurrence was written in
+    /// order, so the last one is stored.
+    LastWriteWins,
+}
+
+/// A document ID that appeared more than once in a batch or was already
+/// stored.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DuplicateId {
+    pub doc_id: DocumentId,
+
+    /// 0-based positions of the ID in the batch.
+    pub positions: Vec<usize>,
+
+    pub action: DuplicateAction,
+}
+
+/// A document that could not be written.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BatchError {
+    /// 0-based position in the batch.
+    pub index: usize,
+    pub doc_id: DocumentId,
+    pub message: String,
+}
+
+/// Outcome of a batch insert or upsert.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct BatchInsertReport {
+    /// IDs of the batch's documents, in batch order (including any assigned
+    /// by the server).
+    pub ids: Vec<DocumentId>,
+
+    /// Documents created.
+    pub inserted: usize,
+
+    /// Stored documents replaced (upserts and metadata patches).
+    #[serde(default)]
+    pub updated: usize,
+
+    /// Occurrences not written because of a duplicate ID (see `duplicates`).
+    pub skipped: usize,
+
+    /// Documents that failed to insert (see `errors`).
+    pub failed: usize,
+
+    /// Documents not written because their content-derived ID is already
+    /// used by different content (counted in `failed`).
+    #[serde(default)]
+    pub collisions: usize,
+
+    /// Duplicate IDs, in order of first position.
+    pub duplicates: Vec<DuplicateId>,
+
+    /// First `MAX_BATCH_ERRORS` failures.
+    pub errors: Vec<BatchError>,
+}
+
+impl BatchInsertReport {
+    pub(crate) fn record_error(&mut self, index: usize, doc_id: DocumentId, e: CoreError) {
+        self.failed += 1;
+        if self.errors.len() < MAX_BATCH_ERRORS {
+            self.errors.push(BatchError {
+                index,
+                doc_id,
+                message: e.to_string(),
+            });
+        }
+    }
+}
+
+/// Outcome of deleting several documents.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct DeleteReport {
+    pub deleted: usize,
+
+    /// Requested IDs with no stored document.
+    pub missing: Vec<DocumentId>,
+
+    /// Documents kept because a legal hold covers them.
+    pub held: Vec<DocumentId>,
+
+    /// Requested IDs that could not be deleted, e.g. malformed IDs or
+    /// storage errors.
+    #[serde(default)]
+    pub failed: Vec<DeleteFailure>,
+}
+
+/// A requested ID that could not be deleted.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DeleteFailure {
+    /// The ID as requested.
+    pub id: String,
+    pub message: String,
+}
+
+/// Fields from which document IDs are derived.
+///
+/// The ID is a UUID (version 8) made from the SHA-256 of the fields' values,
+/// so documents agreeing on every field get the same ID. Fields are
+/// top-level metadata keys, or `external_id`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ContentIdSpec {
+    pub fields: Vec<String>,
+}
+
+impl ContentIdSpec {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.fields.is_empty() || self.fields.len() > MAX_ID_FIELDS {
+            return Err(CoreError::ValidationError(format!(
+                "content ID needs between 1 and {} fields (got {})",
+                MAX_ID_FIELDS,
+                self.fields.len()
+            )));
+        }
+        for (i, field) in self.fields.iter().enumerate() {
+            if field.is_empty() || self.fields[..i].contains(field) {
+                return Err(CoreError::ValidationError(format!(
+                    "content ID field names must be non-empty and distinct (got '{}')",
+                    field
+                )));
+            }
+        }
+        Ok(())
+    }
+
+    /// The hashed content of a document: each field's name and JSON value.
+    /// Fails if the document lacks one of the fields.
+    pub fn key(&self, doc: &VectorDocument) -> CoreResult<Vec<u8>> {
+        let mut key = Vec::new();
+        for field in &self.fields {
+            let value = if field == EXTERNAL_ID_FIELD {
+                doc.external_id.clone().map(serde_json::Value::String)
+            } else {
+                doc.metadata.as_ref().and_then(|m| m.get(field)).cloned()
+            };
+            let value = value.filter(|v| !v.is_null()).ok_or_else(|| {
+                CoreError::ValidationError(format!("document has no '{}' field", field))
+            })?;
+            key.extend_from_slice(field.as_bytes());
+            key.push(0);
+            key.extend_from_slice(value.to_string().as_bytes());
+            key.push(0);
+        }
+        Ok(key)
+    }
+
+    /// The ID derived from a key returned by `key`.
+    pub fn doc_id(key: &[u8]) -> DocumentId {
+        let digest = Sha256::digest(key);
+        let mut bytes = [0u8; 16];
+        bytes.copy_from_slice(&digest[..16]);
+        bytes[6] = (bytes[6] & 0x0f) | 0x80; // version 8
+        bytes[8] = (bytes[8] & 0x3f) | 0x80; // RFC 4122 variant
+        DocumentId::from_bytes(&bytes).expect("16 bytes form a UUID")
+    }
+}
+
+/// Reject empty and oversized batches.
+pub fn check_batch_size(size: usize) -> CoreResult<()> {
+    if size == 0 || size > MAX_BATCH_SIZE {
+        return Err(CoreError::ValidationError(format!(
+            "batch must contain between 1 and {} documents (got {})",
+            MAX_BATCH_SIZE, size
+        )));
+    }
+    Ok(())
+}
+
+/// Reject empty batch deletes and those over `MAX_DELETE_BATCH_SIZE` IDs.
+pub fn check_delete_batch_size(size: usize) -> CoreResult<()> {
+    if size == 0 || size > MAX_DELETE_BATCH_SIZE {
+        return Err(CoreError::ValidationError(format!(
+            "batch delete must contain between 1 and {} IDs (got {})",
+            MAX_DELETE_BATCH_SIZE, size
+        )));
+    }
+    Ok(())
+}
+
+/// Positions of every ID occurring more than once, in order of first
+/// occurrence.
+pub fn repeated_ids(doc_ids: &[DocumentId]) -> Vec<(DocumentId, Vec<usize>)> {
+    let mut positions: HashMap<DocumentId, Vec<usize>> = HashMap::new();
+    for (index, doc_id) in doc_ids.iter().enumerate() {
+        positions.entry(*doc_id).or_default().push(index);
+    }
+    let mut repeated: Vec<_> = positions
+        .into_iter()
+        .filter(|(_, positions)| positions.len() > 1)
+        .collect();
+    repeated.sort_by_key(|(_, positions)| positions[0]);
+    repeated
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_repeated_ids() {
+        let (a, b, c) = (DocumentId::new(), DocumentId::new(), DocumentId::new());
+        let repeated = repeated_ids(&[b, a, c, a, b, a]);
+        assert_eq!(repeated, vec![(b, vec![0, 4]), (a, vec![1, 3, 5])]);
+        assert!(repeated_ids(&[a, b, c]).is_empty());
+    }
+
+    #[test]
+    fn test_content_id_is_stable() {
+        let spec = ContentIdSpec {
+            fields: vec!["text".to_string(), "source".to_string()],
+        };
+        spec.validate().unwrap();
+        let doc = |metadata: serde_json::Value| {
+            VectorDocument::new(DocumentId::new(), vec![1.0; 4]).with_metadata(metadata)
+        };
+
+        let a = spec
+            .key(&doc(
+                serde_json::json!({"text": "hi", "source": "a.md", "page": 1}),
+            ))
+            .unwrap();
+        let b = spec
+            .key(&doc(
+                serde_json::json!({"source": "a.md", "text": "hi", "page": 2}),
+            ))
+            .unwrap();
+        let c = spec
+            .key(&doc(serde_json::json!({"text": "hi", "source": "b.md"})))
+            .unwrap();
+        assert_eq!(ContentIdSpec::doc_id(&a), ContentIdSpec::doc_id(&b));
+        assert_ne!(ContentIdSpec::doc_id(&a), ContentIdSpec::doc_id(&c));
+        assert_eq!(ContentIdSpec::doc_id(&a).as_uuid().get_version_num(), 8);
+
+        assert!(spec.key(&doc(serde_json::json!({"text": "hi"}))).is_err());
+        let repeated = ContentIdSpec {
+            fields: vec!["text".to_string(), "text".to_string()],
+        };
+        assert!(repeated.validate().is_err());
+    }
+
+    #[test]
+    fn test_batch_size_bounds() {
+        assert!(check_batch_size(0).is_err());
+        assert!(check_batch_size(1).is_ok());
+        assert!(check_batch_size(MAX_BATCH_SIZE + 1).is_err());
+    }
+}
@@ -0,0 +1,62 @@
+//! Batch searches: many k-NN queries against one collection in one call.
+//!
+//! Queries run concurrently on a bounded number of workers and results come
+//! back in query order. A failing query (e.g. a wrong dimension) is reported
+//! in its slot without failing the others. See
+//! `CollectionService::batch_search`.
+
+use akidb_core::{CoreError, CoreResult};
+use serde::{Deserialize, Serialize};
+
+/// Maximum queries per batch search.
+pub const MAX_BATCH_QUERIES: usize = 10_000;
+
+/// Default concurrent queries of a batch search.
+pub const DEFAULT_BATCH_SEARCH_CONCURRENCY: usize = 8;
+
+/// Maximum concurrent queries of a batch search.
+pub const MAX_BATCH_SEARCH_CONCURRENCY: usize = 64;
+
+/// Maximum size of a batch search request body (room for thousands of
+/// high-dimensional query vectors).
+pub const MAX_BATCH_SEARCH_BODY_BYTES: usize = 64 * 1024 * 1024;
+
+/// One query of a batch search.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BatchQuery {
+    pub vector: Vec<f32>,
+    pub top_k: usize,
+}
+
+/// How a batch search runs.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BatchSearchOptions {
+    /// Queries running at the same time.
+    pub concurrency: usize,
+}
+
+impl Default for BatchSearchOptions {
+    fn default() -> Self {
+        Self {
+            concurrency: DEFAULT_BATCH_SEARCH_CONCURRENCY,
+        }
+    }
+}
+
+impl BatchSearchOptions {
+    pub fn validate(&self, queries: usize) -> CoreResult<()> {
+        if queries == 0 || queries > MAX_BATCH_QUERIES {
+            return Err(CoreError::ValidationError(format!(
+                "batch search must contain between 1 and {} queries (got {})",
+                MAX_BATCH_QUERIES, queries
+            )));
+        }
+        if self.concurrency == 0 || self.concurrency > MAX_BATCH_SEARCH_CONCURRENCY {
+            return Err(CoreError::ValidationError(format!(
+                "concurrency must be between 1 and {} (got {})",
+                MAX_BATCH_SEARCH_CONCURRENCY, self.concurrency
+            )));
+        }
+        Ok(())
+    }
+}
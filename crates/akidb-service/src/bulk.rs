@@ -0,0 +1,496 @@
+//! Streaming bulk ingestion.
+//!
+//! [`BulkWriter`] accepts documents one at a time (`add`, or a channel from
+//! `sender`), groups them into batches that are flushed when full or when
+//! `flush_interval` has passed since the batch's first document, and writes
+//! up to `concurrency` batches at once. The input buffer is bounded, so
+//! producers wait while the writer is saturated instead of piling documents
+//! up in memory.
+//!
+//! Each document is checked against the collection's dimension (read when
+//! the writer starts) before it joins a batch, and rejected if any component
+//! is NaN or infinite, so one bad vector fails alone instead of after a
+//! batch round-trip. With `normalize_vectors`, vectors written to a cosine
+//! collection are scaled to unit length first.
+//!
+//! Documents are upserted, so retrying is safe: records failing with a
+//! transient error (internal, I/O or storage) are retried with exponential
+//! backoff, and records that still fail are passed to the error callback.
+//! `close` flushes what is left and returns the totals.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DistanceMetric, DocumentId, VectorDocument};
+use serde::{Deserialize, Serialize};
+use std::mem;
+use std::sync::Arc;
+use std::time::Duration;
+use tokio::sync::mpsc;
+use tokio::task::{JoinHandle, JoinSet};
+use tokio::time::Instant;
+
+use crate::batch::MAX_BATCH_SIZE;
+use crate::collection_service::CollectionService;
+
+/// Callback receiving each record that could not be written.
+pub type BulkErrorFn = Arc<dyn Fn(&BulkRecordError) + Send + Sync>;
+
+/// Bulk writer settings.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BulkWriterConfig {
+    /// Documents per batch (at most `MAX_BATCH_SIZE`).
+    pub batch_size: usize,
+
+    /// Flush a partial batch this long after its first document arrived.
+    pub flush_interval: Duration,
+
+    /// Batches written at once.
+    pub concurrency: usize,
+
+    /// Retries of a record failing with a transient error.
+    pub max_retries: u32,
+
+    /// Delay before the first retry; doubled for each further one.
+    pub retry_backoff: Duration,
+
+    /// Documents buffered before `add` waits.
+    pub buffer: usize,
+
+    /// Scale vectors to unit length when the collection's metric is cosine.
+    #[serde(default)]
+    pub normalize_vectors: bool,
+}
+
+impl Default for BulkWriterConfig {
+    fn default() -> Self {
+        Self {
+            batch_size: 500,
+            flush_interval: Duration::from_secs(1),
+            concurrency: 4,
+            max_retries: 3,
+            retry_backoff: Duration::from_millis(100),
+            buffer: 2_000,
+            normalize_vectors: false,
+        }
+    }
+}
+
+impl BulkWriterConfig {
+    pub fn with_batch_size(mut self, batch_size: usize) -> Self {
+        self.batch_size = batch_size;
+        self
+    }
+
+    pub fn with_flush_interval(mut self, flush_interval: Duration) -> Self {
+        self.flush_interval = flush_interval;
+        self
+    }
+
+    pub fn with_concurrency(mut self, concurrency: usize) -> Self {
+        self.concurrency = concurrency;
+        self
+    }
+
+    pub fn with_retries(mut self, max_retries: u32, retry_backoff: Duration) -> Self {
+        self.max_retries = max_retries;
+        self.retry_backoff = retry_backoff;
+        self
+    }
+
+    pub fn with_buffer(mut self, buffer: usize) -> Self {
+        self.buffer = buffer;
+        self
+    }
+
+    pub fn with_normalize_vectors(mut self, normalize_vectors: bool) -> Self {
+        self.normalize_vectors = normalize_vectors;
+        self
+    }
+
+    fn validate(&self) -> CoreResult<()> {
+        if self.batch_size == 0 || self.batch_size > MAX_BATCH_SIZE {
+            return Err(CoreError::ValidationError(format!(
+                "batch_size must be between 1 and {} (got {})",
+                MAX_BATCH_SIZE, self.batch_size
+            )));
+        }
+        if self.concurrency == 0 || self.buffer == 0 || self.flush_interval.is_zero() {
+            return Err(CoreError::ValidationError(
+                "concurrency, buffer and flush_interval must be greater than 0".to_string(),
+            ));
+        }
+        Ok(())
+    }
+}
+
+/// A record that could not be written.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct BulkRecordError {
+    pub doc_id: DocumentId,
+    pub message: String,
+
+    /// Write attempts made, including the first (0 when the document was
+    /// rejected before writing).
+    pub attempts: u32,
+}
+
+/// Totals of a bulk load.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BulkWriterReport {
+    /// Documents accepted by the writer.
+    pub added: usize,
+
+    /// Documents created.
+    pub inserted: usize,
+
+    /// Stored documents replaced.
+    pub updated: usize,
+
+    /// Documents not written (each passed to the error callback).
+    pub failed: usize,
+
+    /// Batches flushed.
+    pub batches: usize,
+
+    /// Record writes retried after a transient error.
+    pub retries: usize,
+}
+
+impl BulkWriterReport {
+    fn merge(&mut self, batch: BulkWriterReport) {
+        self.inserted += batch.inserted;
+        self.updated += batch.updated;
+        self.failed += batch.failed;
+        self.batches += batch.batches;
+        self.retries += batch.retries;
+    }
+}
+
+/// Checks (and optionally normalizes) vectors before they are batched.
+struct VectorCheck {
+    dimension: usize,
+    normalize: bool,
+}
+
+impl VectorCheck {
+    /// Reads the collection's dimension and metric; None if the collection
+    /// cannot be read, leaving the checks to the writes.
+    async fn for_collection(
+        service: &CollectionService,
+        collection_id: CollectionId,
+        normalize_vectors: bool,
+    ) -> Option<Self> {
+        let collection = service.get_collection(collection_id).await.ok()?;
+        Some(Self {
+            dimension: collection.dimension as usize,
+            normalize: normalize_vectors && collection.metric == DistanceMetric::Cosine,
+        })
+    }
+
+    fn apply(&self, doc: &mut VectorDocument) -> CoreResult<()> {
+        if doc.vector.len() != self.dimension {
+            return Err(CoreError::ValidationError(format!(
+                "Vector dimension mismatch: expected {}, got {}",
+                self.dimension,
+                doc.vector.len()
+            )));
+        }
+        if let Some(i) = doc.vector.iter().position(|v| !v.is_finite()) {
+            return Err(CoreError::ValidationError(format!(
+                "Vector component {} is {}; only finite numbers are allowed",
+                i, doc.vector[i]
+            )));
+        }
+        if self.normalize {
+            let norm = doc.vector.iter().map(|v| v * v).sum::<f32>().sqrt();
+            if norm == 0.0 {
+                return Err(CoreError::ValidationError(
+                    "Cannot normalize a zero vector".to_string(),
+                ));
+            }
+            doc.vector.iter_mut().for_each(|v| *v /= norm);
+        }
+        Ok(())
+    }
+}
+
+/// Batching, concurrent, retrying writer into one collection.
+pub struct BulkWriter {
+    sender: mpsc::Sender<VectorDocument>,
+    task: JoinHandle<BulkWriterReport>,
+}
+
+impl BulkWriter {
+    /// Starts a writer into `collection_id`; failed records are passed to
+    /// `on_error`, if set.
+    pub fn start(
+        service: Arc<CollectionService>,
+        collection_id: CollectionId,
+        config: BulkWriterConfig,
+        on_error: Option<BulkErrorFn>,
+    ) -> CoreResult<Self> {
+        config.validate()?;
+        let (sender, receiver) = mpsc::channel(config.buffer);
+        let task = tokio::spawn(run(service, collection_id, config, on_error, receiver));
+        Ok(Self { sender, task })
+    }
+
+    /// Queues a document, waiting while the buffer is full.
+    pub async fn add(&self, doc: VectorDocument) -> CoreResult<()> {
+        self.sender
+            .send(doc)
+            .await
+            .map_err(|_| CoreError::invalid_state("bulk writer has stopped"))
+    }
+
+    /// A channel feeding the writer, e.g. for several producers. `close`
+    /// waits until every sender has been dropped.
+    pub fn sender(&self) -> mpsc::Sender<VectorDocument> {
+        self.sender.clone()
+    }
+
+    /// Flushes the remaining documents and waits for every batch.
+    pub async fn close(self) -> CoreResult<BulkWriterReport> {
+        drop(self.sender);
+        self.task
+            .await
+            .map_err(|e| CoreError::internal(format!("bulk writer task failed: {}", e)))
+    }
+}
+
+async fn run(
+    service: Arc<CollectionService>,
+    collection_id: CollectionId,
+    config: BulkWriterConfig,
+    on_error: Option<BulkErrorFn>,
+    mut receiver: mpsc::Receiver<VectorDocument>,
+) -> BulkWriterReport {
+    let mut report = BulkWriterReport::default();
+    let mut tasks = JoinSet::new();
+    let mut batch = Vec::with_capacity(config.batch_size);
+    let mut deadline = Instant::now();
+    let check =
+        VectorCheck::for_collection(&service, collection_id, config.normalize_vectors).await;
+
+    loop {
+        let doc = tokio::select! {
+            doc = receiver.recv() => match doc {
+                Some(doc) => Some(doc),
+                None => break,
+            },
+            _ = tokio::time::sleep_until(deadline), if !batch.is_empty() => None,
+        };
+        if let Some(mut doc) = doc {
+            report.added += 1;
+            if let Some(Err(e)) = check.as_ref().map(|check| check.apply(&mut doc)) {
+                report.failed += 1;
+                if let Some(on_error) = &on_error {
+                    on_error(&BulkRecordError {
+                        doc_id: doc.doc_id,
+                        message: e.to_string(),
+                        attempts: 0,
+                    });
+                }
+                continue;
+            }
+            if batch.is_empty() {
+                deadline = Instant::now() + config.flush_interval;
+            }
+            batch.push(doc);
+            if batch.len() < config.batch_size {
+                continue;
+            }
+        }
+
+        // Not reading the channel while every slot is busy is the backpressure
+        while tasks.len() >= config.concurrency {
+            if let Some(joined) = tasks.join_next().await {
+                collect(&mut report, joined, collection_id);
+            }
+        }
+        tasks.spawn(write_batch(
+            Arc::clone(&service),
+            collection_id,
+            mem::take(&mut batch),
+            config.clone(),
+            on_error.clone(),
+        ));
+    }
+
+    if !batch.is_empty() {
+        tasks.spawn(write_batch(
+            Arc::clone(&service),
+            collection_id,
+            batch,
+            config.clone(),
+            on_error.clone(),
+        ));
+    }
+    while let Some(joined) = tasks.join_next().await {
+        collect(&mut report, joined, collection_id);
+    }
+
+    tracing::info!(
+        "Bulk load into {}: {} added, {} created, {} updated, {} failed in {} batches",
+        collection_id,
+        report.added,
+        report.inserted,
+        report.updated,
+        report.failed,
+        report.batches
+    );
+    report
+}
+
+fn collect(
+    report: &mut BulkWriterReport,
+    joined: Result<BulkWriterReport, tokio::task::JoinError>,
+    collection_id: CollectionId,
+) {
+    match joined {
+        Ok(written) => report.merge(written),
+        Err(e) => tracing::error!("Bulk write task into {} failed: {}", collection_id, e),
+    }
+}
+
+async fn write_batch(
+    service: Arc<CollectionService>,
+    collection_id: CollectionId,
+    docs: Vec<VectorDocument>,
+    config: BulkWriterConfig,
+    on_error: Option<BulkErrorFn>,
+) -> BulkWriterReport {
+    let mut report = BulkWriterReport {
+        batches: 1,
+        ..Default::default()
+    };
+    let mut pending = docs;
+    let mut attempt = 0;
+    loop {
+        let mut retry = Vec::new();
+        for doc in pending {
+            let doc_id = doc.doc_id;
+            let copy = (attempt < config.max_retries).then(|| doc.clone());
+            match service.upsert(collection_id, doc).await {
+                Ok(true) => report.inserted += 1,
+                Ok(false) => report.updated += 1,
+                Err(e) if is_transient(&e) && copy.is_some() => retry.extend(copy),
+                Err(e) => {
+                    report.failed += 1;
+                    if let Some(on_error) = &on_error {
+                        on_error(&BulkRecordError {
+                            doc_id,
+                            message: e.to_string(),
+                            attempts: attempt + 1,
+                        });
+                    }
+                }
+            }
+        }
+        if retry.is_empty() {
+            return report;
+        }
+        report.retries += retry.len();
+        tokio::time::sleep(config.retry_backoff * 2u32.saturating_pow(attempt)).await;
+        attempt += 1;
+        pending = retry;
+    }
+}
+
+pub(crate) fn is_transient(e: &CoreError) -> bool {
+    matches!(
+        e,
+        CoreError::Internal { .. } | CoreError::IoError(_) | CoreError::StorageError(_)
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DistanceMetric;
+    use std::sync::Mutex;
+
+    #[tokio::test]
+    async fn test_bulk_load_reports_failures() {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("bulk".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+
+        let failures = Arc::new(Mutex::new(Vec::new()));
+        let sink = Arc::clone(&failures);
+        let on_error: BulkErrorFn =
+            Arc::new(move |e: &BulkRecordError| sink.lock().unwrap().push(e.doc_id));
+        let config = BulkWriterConfig::default()
+            .with_batch_size(8)
+            .with_concurrency(2)
+            .with_buffer(4)
+            .with_flush_interval(Duration::from_millis(20));
+        let writer =
+            BulkWriter::start(Arc::clone(&service), collection_id, config, Some(on_error)).unwrap();
+
+        let bad = DocumentId::new();
+        let producer = writer.sender();
+        tokio::spawn(async move {
+            for _ in 0..20 {
+                let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+                producer.send(doc).await.unwrap();
+            }
+        });
+        writer
+            .add(VectorDocument::new(bad, vec![1.0; 3]))
+            .await
+            .unwrap();
+        // A partial batch is flushed after the interval
+        tokio::time::sleep(Duration::from_millis(100)).await;
+
+        let report = writer.close().await.unwrap();
+        assert_eq!(report.added, 21);
+        assert_eq!(report.inserted, 20);
+        assert_eq!(report.failed, 1);
+        assert!(report.batches >= 3);
+        assert_eq!(*failures.lock().unwrap(), vec![bad]);
+        assert_eq!(service.get_count(collection_id).await.unwrap(), 20);
+
+        let oversized = BulkWriterConfig::default().with_batch_size(MAX_BATCH_SIZE + 1);
+        assert!(BulkWriter::start(service, collection_id, oversized, None).is_err());
+    }
+
+    #[tokio::test]
+    async fn test_vectors_checked_before_batching() {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("normalized".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+
+        let failures = Arc::new(Mutex::new(Vec::new()));
+        let sink = Arc::clone(&failures);
+        let on_error: BulkErrorFn =
+            Arc::new(move |e: &BulkRecordError| sink.lock().unwrap().push(e.clone()));
+        let config = BulkWriterConfig::default().with_normalize_vectors(true);
+        let writer =
+            BulkWriter::start(Arc::clone(&service), collection_id, config, Some(on_error)).unwrap();
+
+        let good = DocumentId::new();
+        writer
+            .add(VectorDocument::new(good, vec![2.0; 16]))
+            .await
+            .unwrap();
+        let mut invalid = vec![1.0; 16];
+        invalid[5] = f32::NAN;
+        for vector in [invalid, vec![0.0; 16], vec![1.0; 8]] {
+            writer
+                .add(VectorDocument::new(DocumentId::new(), vector))
+                .await
+                .unwrap();
+        }
+        let report = writer.close().await.unwrap();
+        assert_eq!((report.inserted, report.failed, report.batches), (1, 3, 1));
+
+        let failures = failures.lock().unwrap();
+        assert!(failures.iter().all(|e| e.attempts == 0));
+        assert!(failures[0].message.contains("component 5"));
+        let stored = service.get(collection_id, good).await.unwrap().unwrap();
+        assert!(stored.vector.iter().all(|v| (v - 0.25).abs() < 1e-6));
+    }
+}
@@ -0,0 +1,196 @@
+//! Collection growth history and capacity forecasting.
+//!
+//! The service periodically samples each collection's vector count and
+//! estimated memory footprint (see `CollectionService::record_growth_samples`).
+//! A least-squares linear fit over the samples gives a growth rate, which is
+//! projected forward to predict when a collection (or the whole node) reaches
+//! a vector quota or memory limit.
+
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+
+/// Maximum samples kept per collection (about a year at the default interval).
+pub const GROWTH_HISTORY_LIMIT: usize = 9000;
+
+/// Default interval between growth samples.
+pub const GROWTH_SAMPLE_INTERVAL: std::time::Duration = std::time::Duration::from_secs(3600);
+
+/// Estimated per-vector overhead beyond the raw f32 components (IDs, metadata
+/// pointers, index links).
+pub const PER_VECTOR_OVERHEAD_BYTES: u64 = 128;
+
+const SECONDS_PER_DAY: f64 = 86_400.0;
+
+/// Estimated in-memory footprint of `count` vectors of `dimension` components.
+pub fn estimate_memory_bytes(count: u64, dimension: u32) -> u64 {
+    count * (u64::from(dimension) * 4 + PER_VECTOR_OVERHEAD_BYTES)
+}
+
+/// Size of a collection at a point in time.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub struct GrowthSample {
+    pub timestamp: DateTime<Utc>,
+    pub vector_count: u64,
+    pub estimated_bytes: u64,
+}
+
+/// Limits to forecast against.
+#[derive(Debug, Clone, Copy, Default, Serialize, Deserialize)]
+pub struct ForecastLimits {
+    /// Vector quota.
+    pub max_vectors: Option<u64>,
+
+    /// Memory limit in bytes (e.g. tenant memory quota or node RAM).
+    pub memory_bytes: Option<u64>,
+}
+
+/// Current size and linear growth rate.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Serialize, Deserialize)]
+pub struct GrowthTrend {
+    pub vector_count: u64,
+    pub estimated_bytes: u64,
+    pub vectors_per_day: f64,
+    pub bytes_per_day: f64,
+}
+
+impl GrowthTrend {
+    /// Fits a growth rate to `samples`, anchored at the current size.
+    ///
+    /// Fewer than two samples (or samples at a single instant) give a zero rate.
+    pub fn fit(samples: &[GrowthSample], vector_count: u64, estimated_bytes: u64) -> Self {
+        Self {
+            vector_count,
+            estimated_bytes,
+            vectors_per_day: slope_per_day(samples, |s| s.vector_count as f64),
+            bytes_per_day: slope_per_day(samples, |s| s.estimated_bytes as f64),
+        }
+    }
+
+    /// Sums trends (e.g. all collections on a node).
+    pub fn combine(trends: impl IntoIterator<Item = GrowthTrend>) -> Self {
+        trends.into_iter().fold(Self::default(), |acc, t| Self {
+            vector_count: acc.vector_count + t.vector_count,
+            estimated_bytes: acc.estimated_bytes + t.estimated_bytes,
+            vectors_per_day: acc.vectors_per_day + t.vectors_per_day,
+            bytes_per_day: acc.bytes_per_day + t.bytes_per_day,
+        })
+    }
+
+    /// Projects the trend `horizon_days` ahead and computes time to each limit.
+    pub fn forecast(&self, horizon_days: f64, limits: ForecastLimits) -> GrowthForecast {
+        let project = |current: u64, rate: f64| (current as f64 + rate * horizon_days).max(0.0);
+        GrowthForecast {
+            trend: *self,
+            horizon_days,
+            projected_vector_count: project(self.vector_count, self.vectors_per_day) as u64,
+            projected_bytes: project(self.estimated_bytes, self.bytes_per_day) as u64,
+            limits,
+            days_until_vector_limit: limits
+                .max_vectors
+                .and_then(|limit| days_until(self.vector_count, self.vectors_per_day, limit)),
+            days_until_memory_limit: limits
+                .memory_bytes
+                .and_then(|limit| days_until(self.estimated_bytes, self.bytes_per_day, limit)),
+        }
+    }
+}
+
+/// Growth projection over a horizon.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GrowthForecast {
+    pub trend: GrowthTrend,
+    pub horizon_days: f64,
+    pub projected_vector_count: u64,
+    pub projected_bytes: u64,
+    pub limits: ForecastLimits,
+
+    /// Days until the vector quota is reached (0 if already reached; None if
+    /// no limit was given or the collection is not growing).
+    pub days_until_vector_limit: Option<f64>,
+
+    /// Days until the memory limit is reached (same conventions).
+    pub days_until_memory_limit: Option<f64>,
+}
+
+fn days_until(current: u64, rate_per_day: f64, limit: u64) -> Option<f64> {
+    if current >= limit {
+        Some(0.0)
+    } else if rate_per_day > 0.0 {
+        Some((limit - current) as f64 / rate_per_day)
+    } else {
+        None
+    }
+}
+
+/// Least-squares slope of `value` over sample time, in units per day.
+fn slope_per_day(samples: &[GrowthSample], value: impl Fn(&GrowthSample) -> f64) -> f64 {
+    let Some(first) = samples.first() else {
+        return 0.0;
+    };
+    let points: Vec<(f64, f64)> = samples
+        .iter()
+        .map(|s| {
+            let elapsed = (s.timestamp - first.timestamp).num_milliseconds();
+            (elapsed as f64 / 1000.0 / SECONDS_PER_DAY, value(s))
+        })
+        .collect();
+
+    let n = points.len() as f64;
+    let mean_x = points.iter().map(|(x, _)| x).sum::<f64>() / n;
+    let mean_y = points.iter().map(|(_, y)| y).sum::<f64>() / n;
+    let (mut cov, mut var) = (0.0, 0.0);
+    for (x, y) in &points {
+        cov += (x - mean_x) * (y - mean_y);
+        var += (x - mean_x) * (x - mean_x);
+    }
+    if var == 0.0 {
+        0.0
+    } else {
+        cov / var
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::Duration;
+
+    fn samples() -> Vec<GrowthSample> {
+        let start = Utc::now() - Duration::days(10);
+        (0..=10)
+            .map(|day| GrowthSample {
+                timestamp: start + Duration::days(day),
+                vector_count: 1000 + 100 * day as u64,
+                estimated_bytes: estimate_memory_bytes(1000 + 100 * day as u64, 128),
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_fit_and_forecast() {
+        let trend = GrowthTrend::fit(&samples(), 2000, estimate_memory_bytes(2000, 128));
+        assert!((trend.vectors_per_day - 100.0).abs() < 1e-6);
+
+        let limits = ForecastLimits {
+            max_vectors: Some(5000),
+            memory_bytes: Some(estimate_memory_bytes(2500, 128)),
+        };
+        let forecast = trend.forecast(30.0, limits);
+        assert_eq!(forecast.projected_vector_count, 5000);
+        assert!((forecast.days_until_vector_limit.unwrap() - 30.0).abs() < 1e-6);
+        assert!((forecast.days_until_memory_limit.unwrap() - 5.0).abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_flat_or_missing_history() {
+        let trend = GrowthTrend::fit(&samples()[..1], 1000, 0);
+        assert_eq!(trend.vectors_per_day, 0.0);
+        let limits = ForecastLimits {
+            max_vectors: Some(500),
+            memory_bytes: Some(1),
+        };
+        let forecast = trend.forecast(30.0, limits);
+        assert_eq!(forecast.days_until_vector_limit, Some(0.0));
+        assert_eq!(forecast.days_until_memory_limit, None);
+    }
+}
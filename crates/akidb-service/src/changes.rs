@@ -0,0 +1,232 @@
+//! Change streams: the writes to a collection, in order.
+//!
+//! Subscribers mirroring a collection (into a cache or an analytics
+//! pipeline) receive a `ChangeEvent` per document written (`upsert`, with
+//! the document, also when it replaces a stored one) or deleted (`delete`).
+//! See `CollectionService::subscribe_changes`.
+//!
+//! Every event carries a cursor. Subscribing with the cursor of the last
+//! event processed resumes right after it, as long as that event is still
+//! among the last `CHANGE_LOG_CAPACITY` of the collection. Older cursors,
+//! and cursors from before a restart, are rejected: the subscriber must
+//! resync (e.g. from an export) and subscribe without a cursor.
+//!
+//! A collection's writes are logged from its first subscription on, so
+//! collections nobody follows cost nothing.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, VecDeque};
+use std::sync::Mutex;
+use tokio::sync::broadcast;
+
+use crate::replication::ReplicationOp;
+
+/// Events kept per collection for subscribers resuming from a cursor.
+pub const CHANGE_LOG_CAPACITY: usize = 10_000;
+
+/// Events buffered per subscriber before slow subscribers start lagging.
+pub const CHANGE_STREAM_BUFFER: usize = 1_024;
+
+/// A write to a collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ChangeEvent {
+    /// Subscribe with this cursor to resume after the event.
+    pub cursor: String,
+    pub collection_id: CollectionId,
+    #[serde(flatten)]
+    pub op: ReplicationOp,
+    pub at: DateTime<Utc>,
+}
+
+/// A subscription to a change stream.
+pub struct ChangeSubscription {
+    /// Logged events after the cursor subscribed with, oldest first.
+    pub backlog: Vec<ChangeEvent>,
+
+    /// Events following the backlog. Closed when the collection is deleted.
+    pub updates: broadcast::Receiver<ChangeEvent>,
+}
+
+struct ChangeLog {
+    /// Random ID distinguishing this log's cursors from those of earlier
+    /// logs of the collection
+    stream: u32,
+    last_seq: u64,
+    events: VecDeque<(u64, ChangeEvent)>,
+    sender: broadcast::Sender<ChangeEvent>,
+}
+
+impl ChangeLog {
+    fn new() -> Self {
+        Self {
+            stream: rand::random(),
+            last_seq: 0,
+            events: VecDeque::new(),
+            sender: broadcast::channel(CHANGE_STREAM_BUFFER).0,
+        }
+    }
+
+    fn cursor(&self, seq: u64) -> String {
+        format!("{:08x}-{}", self.stream, seq)
+    }
+
+    /// Sequence number of the event with this cursor.
+    fn seq(&self, cursor: &str) -> CoreResult<u64> {
+        let parsed = cursor.split_once('-').and_then(|(stream, seq)| {
+            Some((
+                u32::from_str_radix(stream, 16).ok()?,
+                seq.parse::<u64>().ok()?,
+            ))
+        });
+        let expired = || {
+            CoreError::invalid_state(format!(
+                "Change stream cursor {} has expired; resync and subscribe without a cursor",
+                cursor
+            ))
+        };
+        match parsed {
+            Some((stream, _)) if stream != self.stream => Err(expired()),
+            Some((_, seq)) if seq > self.last_seq => Err(CoreError::ValidationError(format!(
+                "Change stream cursor {} is ahead of the stream",
+                cursor
+            ))),
+            Some((_, seq)) => {
+                let oldest = self.events.front().map_or(self.last_seq + 1, |(s, _)| *s);
+                if seq + 1 < oldest {
+                    return Err(expired());
+                }
+                Ok(seq)
+            }
+            None => Err(CoreError::ValidationError(format!(
+                "Invalid change stream cursor: {}",
+                cursor
+            ))),
+        }
+    }
+}
+
+/// Change logs of the collections with subscribers.
+#[derive(Default)]
+pub(crate) struct ChangeFeeds {
+    logs: Mutex<HashMap<CollectionId, ChangeLog>>,
+}
+
+impl ChangeFeeds {
+    /// Returns true if the collection's writes are logged.
+    pub fn is_followed(&self, collection_id: CollectionId) -> bool {
+        self.logs.lock().unwrap().contains_key(&collection_id)
+    }
+
+    /// Log a write and send it to subscribers.
+    pub fn record(&self, collection_id: CollectionId, op: ReplicationOp) {
+        let mut logs = self.logs.lock().unwrap();
+        let Some(log) = logs.get_mut(&collection_id) else {
+            return;
+        };
+        log.last_seq += 1;
+        let event = ChangeEvent {
+            cursor: log.cursor(log.last_seq),
+            collection_id,
+            op,
+            at: Utc::now(),
+        };
+        if log.events.len() >= CHANGE_LOG_CAPACITY {
+            log.events.pop_front();
+        }
+        log.events.push_back((log.last_seq, event.clone()));
+        // No subscribers left is fine: the log keeps the event
+        let _ = log.sender.send(event);
+    }
+
+    /// Subscribe to the writes after the event with cursor `since`, or from
+    /// now on without one.
+    pub fn subscribe(
+        &self,
+        collection_id: CollectionId,
+        since: Option<&str>,
+    ) -> CoreResult<ChangeSubscription> {
+        let mut logs = self.logs.lock().unwrap();
+        let log = logs.entry(collection_id).or_insert_with(ChangeLog::new);
+        let backlog = match since {
+            Some(cursor) => {
+                let seq = log.seq(cursor)?;
+                log.events
+                    .iter()
+                    .filter(|(s, _)| *s > seq)
+                    .map(|(_, event)| event.clone())
+                    .collect()
+            }
+            None => Vec::new(),
+        };
+        Ok(ChangeSubscription {
+            backlog,
+            updates: log.sender.subscribe(),
+        })
+    }
+
+    /// Drop a collection's log, closing its subscriptions.
+    pub fn remove(&self, collection_id: CollectionId) {
+        self.logs.lock().unwrap().remove(&collection_id);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DocumentId;
+
+    fn delete() -> ReplicationOp {
+        ReplicationOp::Delete {
+            doc_id: DocumentId::new(),
+        }
+    }
+
+    #[test]
+    fn test_change_feed_resume() {
+        let feeds = ChangeFeeds::default();
+        let collection_id = CollectionId::new();
+
+        // Not logged before the first subscription
+        feeds.record(collection_id, delete());
+        assert!(!feeds.is_followed(collection_id));
+
+        let mut first = feeds.subscribe(collection_id, None).unwrap();
+        assert!(first.backlog.is_empty());
+        for _ in 0..3 {
+            feeds.record(collection_id, delete());
+        }
+        let received: Vec<ChangeEvent> =
+            (0..3).map(|_| first.updates.try_recv().unwrap()).collect();
+
+        // Resume after the first event
+        let resumed = feeds
+            .subscribe(collection_id, Some(&received[0].cursor))
+            .unwrap();
+        let cursors: Vec<&str> = resumed.backlog.iter().map(|e| e.cursor.as_str()).collect();
+        assert_eq!(cursors, [&received[1].cursor, &received[2].cursor]);
+
+        // Cursors evicted from the log, or of an earlier log, have expired
+        for _ in 0..CHANGE_LOG_CAPACITY {
+            feeds.record(collection_id, delete());
+        }
+        let err = feeds
+            .subscribe(collection_id, Some(&received[0].cursor))
+            .err()
+            .unwrap();
+        assert!(matches!(err, CoreError::InvalidState { .. }));
+        feeds.remove(collection_id);
+        assert!(feeds
+            .subscribe(collection_id, Some(&received[2].cursor))
+            .is_err());
+
+        assert!(matches!(
+            feeds
+                .subscribe(collection_id, Some("nonsense"))
+                .err()
+                .unwrap(),
+            CoreError::ValidationError(_)
+        ));
+    }
+}
@@ -0,0 +1,362 @@
+//! Fault injection for replication tests.
+//!
+//! `ChaosTransport` wraps a [`ReplicationTransport`] and makes shipments
+//! fail the way a flaky network or an overloaded target does: outright
+//! errors, added latency and timeouts, partial deliveries (a prefix of the
+//! writes reaches the target before the shipment fails) and bursts of 429
+//! rejections. Tests and staging clusters use it to check that replication
+//! retries, keeps its queue and catches up once the faults stop.
+//!
+//! Faults are drawn from a seeded generator, so a `FaultProfile` with a
+//! `seed` injects the same faults in the same order on every run.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use async_trait::async_trait;
+use rand::{rngs::StdRng, Rng, SeedableRng};
+use serde::{Deserialize, Serialize};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use crate::replication::{ReplicationOp, ReplicationTransport};
+
+/// Latency added to each shipment.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "kind", rename_all = "snake_case")]
+pub enum LatencyDistribution {
+    /// No added latency.
+    #[default]
+    None,
+    /// The same latency for every shipment.
+    Fixed { ms: u64 },
+    /// Uniformly distributed between the bounds (inclusive).
+    Uniform { min_ms: u64, max_ms: u64 },
+    /// Exponentially distributed: mostly short, with a long tail.
+    Exponential { mean_ms: u64 },
+}
+
+impl LatencyDistribution {
+    fn sample(&self, rng: &mut StdRng) -> Duration {
+        let ms = match *self {
+            Self::None => 0,
+            Self::Fixed { ms } => ms,
+            Self::Uniform { min_ms, max_ms } => rng.gen_range(min_ms..=max_ms),
+            Self::Exponential { mean_ms } => {
+                let u: f64 = rng.gen();
+                (-(mean_ms as f64) * (1.0 - u).ln()) as u64
+            }
+        };
+        Duration::from_millis(ms)
+    }
+}
+
+/// Faults injected by a `ChaosTransport`. Rates are probabilities per
+/// shipment, in [0, 1].
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct FaultProfile {
+    /// Shipments failing without reaching the target.
+    pub error_rate: f64,
+
+    /// Shipments of several writes failing after only a prefix of them
+    /// reached the target.
+    pub partial_rate: f64,
+
+    /// Shipments starting a burst of 429 rejections.
+    pub rate_limit_rate: f64,
+
+    /// Consecutive shipments rejected per burst (at least 1).
+    pub rate_limit_burst: u32,
+
+    pub latency: LatencyDistribution,
+
+    /// Shipments whose latency exceeds this fail as timed out after it.
+    pub timeout_ms: Option<u64>,
+
+    /// Seed of the fault generator (None: a random seed).
+    pub seed: Option<u64>,
+}
+
+impl FaultProfile {
+    pub fn validate(&self) -> CoreResult<()> {
+        for (name, rate) in [
+            ("error_rate", self.error_rate),
+            ("partial_rate", self.partial_rate),
+            ("rate_limit_rate", self.rate_limit_rate),
+        ] {
+            if !(0.0..=1.0).contains(&rate) {
+                return Err(CoreError::ValidationError(format!(
+                    "{} must be between 0 and 1, got {}",
+                    name, rate
+                )));
+            }
+        }
+        if self.rate_limit_rate > 0.0 && self.rate_limit_burst == 0 {
+            return Err(CoreError::ValidationError(
+                "rate_limit_burst must be at least 1".to_string(),
+            ));
+        }
+        if let LatencyDistribution::Uniform { min_ms, max_ms } = self.latency {
+            if min_ms > max_ms {
+                return Err(CoreError::ValidationError(format!(
+                    "latency min_ms ({}) exceeds max_ms ({})",
+                    min_ms, max_ms
+                )));
+            }
+        }
+        Ok(())
+    }
+}
+
+/// Shipments seen by a `ChaosTransport`, by outcome.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize)]
+pub struct ChaosStats {
+    pub shipments: u64,
+    pub errors: u64,
+    pub partial: u64,
+    pub rate_limited: u64,
+    pub timeouts: u64,
+}
+
+enum Fault {
+    None,
+    Error,
+    /// Deliver this many writes, then fail
+    Partial(usize),
+    RateLimited,
+    Timeout(Duration),
+}
+
+struct ChaosState {
+    rng: StdRng,
+    burst_left: u32,
+    stats: ChaosStats,
+}
+
+/// Transport injecting faults into the shipments of another.
+pub struct ChaosTransport {
+    inner: Arc<dyn ReplicationTransport>,
+    profile: FaultProfile,
+    state: Mutex<ChaosState>,
+}
+
+impl ChaosTransport {
+    pub fn new(inner: Arc<dyn ReplicationTransport>, profile: FaultProfile) -> CoreResult<Self> {
+        profile.validate()?;
+        let rng = StdRng::seed_from_u64(profile.seed.unwrap_or_else(rand::random));
+        Ok(Self {
+            inner,
+            profile,
+            state: Mutex::new(ChaosState {
+                rng,
+                burst_left: 0,
+                stats: ChaosStats::default(),
+            }),
+        })
+    }
+
+    pub fn stats(&self) -> ChaosStats {
+        self.state.lock().unwrap().stats
+    }
+
+    /// Draws the fault and latency of a shipment of `len` writes.
+    fn draw(&self, len: usize) -> (Fault, Duration) {
+        let mut state = self.state.lock().unwrap();
+        let ChaosState {
+            rng,
+            burst_left,
+            stats,
+        } = &mut *state;
+        stats.shipments += 1;
+
+        let latency = self.profile.latency.sample(rng);
+        let timeout = self.profile.timeout_ms.map(Duration::from_millis);
+        let fault = if *burst_left > 0 {
+            *burst_left -= 1;
+            Fault::RateLimited
+        } else if rng.gen_bool(self.profile.rate_limit_rate) {
+            *burst_left = self.profile.rate_limit_burst - 1;
+            Fault::RateLimited
+        } else if let Some(timeout) = timeout.filter(|&timeout| latency > timeout) {
+            Fault::Timeout(timeout)
+        } else if rng.gen_bool(self.profile.error_rate) {
+            Fault::Error
+        } else if len > 1 && rng.gen_bool(self.profile.partial_rate) {
+            Fault::Partial(rng.gen_range(1..len))
+        } else {
+            Fault::None
+        };
+        match fault {
+            Fault::None => {}
+            Fault::Error => stats.errors += 1,
+            Fault::Partial(_) => stats.partial += 1,
+            Fault::RateLimited => stats.rate_limited += 1,
+            Fault::Timeout(_) => stats.timeouts += 1,
+        }
+        (fault, latency)
+    }
+}
+
+#[async_trait]
+impl ReplicationTransport for ChaosTransport {
+    async fn ship(
+        &self,
+        endpoint: &str,
+        collection_id: CollectionId,
+        ops: &[ReplicationOp],
+    ) -> CoreResult<()> {
+        let (fault, latency) = self.draw(ops.len());
+        if let Fault::Timeout(timeout) = fault {
+            tokio::time::sleep(timeout).await;
+            return Err(CoreError::internal(format!(
+                "{} timed out (injected fault)",
+                endpoint
+            )));
+        }
+        if !latency.is_zero() {
+            tokio::time::sleep(latency).await;
+        }
+        match fault {
+            Fault::None => self.inner.ship(endpoint, collection_id, ops).await,
+            Fault::Error => Err(CoreError::internal(format!(
+                "{} returned 503 Service Unavailable: injected fault",
+                endpoint
+            ))),
+            Fault::Partial(len) => {
+                self.inner
+                    .ship(endpoint, collection_id, &ops[..len])
+                    .await?;
+                Err(CoreError::internal(format!(
+                    "{} closed the connection after {} of {} writes: injected fault",
+                    endpoint,
+                    len,
+                    ops.len()
+                )))
+            }
+            Fault::RateLimited => Err(CoreError::internal(format!(
+                "{} returned 429 Too Many Requests: injected fault",
+                endpoint
+            ))),
+            Fault::Timeout(_) => unreachable!("timeouts return before the latency"),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DocumentId;
+
+    #[derive(Default)]
+    struct CountingTarget {
+        received: Mutex<Vec<usize>>,
+    }
+
+    #[async_trait]
+    impl ReplicationTransport for CountingTarget {
+        async fn ship(
+            &self,
+            _endpoint: &str,
+            _collection_id: CollectionId,
+            ops: &[ReplicationOp],
+        ) -> CoreResult<()> {
+            self.received.lock().unwrap().push(ops.len());
+            Ok(())
+        }
+    }
+
+    fn deletes(n: usize) -> Vec<ReplicationOp> {
+        (0..n)
+            .map(|_| ReplicationOp::Delete {
+                doc_id: DocumentId::new(),
+            })
+            .collect()
+    }
+
+    async fn ship_all(chaos: &ChaosTransport, times: usize) -> Vec<CoreResult<()>> {
+        let mut results = Vec::new();
+        for _ in 0..times {
+            let result = chaos
+                .ship("http://eu.example.com", CollectionId::new(), &deletes(4))
+                .await;
+            results.push(result);
+        }
+        results
+    }
+
+    #[tokio::test]
+    async fn test_chaos_transport_faults() {
+        let target = Arc::new(CountingTarget::default());
+        let profile = |profile: FaultProfile| FaultProfile {
+            seed: Some(7),
+            ..profile
+        };
+
+        // Bursts of 429s
+        let chaos = ChaosTransport::new(
+            target.clone(),
+            profile(FaultProfile {
+                rate_limit_rate: 1.0,
+                rate_limit_burst: 3,
+                ..FaultProfile::default()
+            }),
+        )
+        .unwrap();
+        let results = ship_all(&chaos, 3).await;
+        assert!(results
+            .iter()
+            .all(|r| r.as_ref().unwrap_err().to_string().contains("429")));
+        assert_eq!(chaos.stats().rate_limited, 3);
+        assert!(target.received.lock().unwrap().is_empty());
+
+        // Partial deliveries reach the target with a prefix of the writes
+        let chaos = ChaosTransport::new(
+            target.clone(),
+            profile(FaultProfile {
+                partial_rate: 1.0,
+                ..FaultProfile::default()
+            }),
+        )
+        .unwrap();
+        let results = ship_all(&chaos, 5).await;
+        assert!(results.iter().all(Result::is_err));
+        let received = target.received.lock().unwrap().clone();
+        assert_eq!(received.len(), 5);
+        assert!(received.iter().all(|&len| (1..4).contains(&len)));
+
+        // Latency beyond the timeout
+        let chaos = ChaosTransport::new(
+            target.clone(),
+            profile(FaultProfile {
+                latency: LatencyDistribution::Fixed { ms: 60_000 },
+                timeout_ms: Some(1),
+                ..FaultProfile::default()
+            }),
+        )
+        .unwrap();
+        let err = ship_all(&chaos, 1).await.remove(0).unwrap_err();
+        assert!(err.to_string().contains("timed out"));
+
+        // Same seed, same faults
+        let half = FaultProfile {
+            error_rate: 0.5,
+            ..profile(FaultProfile::default())
+        };
+        let outcomes = |results: Vec<CoreResult<()>>| -> Vec<bool> {
+            results.iter().map(Result::is_ok).collect()
+        };
+        let a = ChaosTransport::new(target.clone(), half.clone()).unwrap();
+        let b = ChaosTransport::new(target.clone(), half).unwrap();
+        let first = outcomes(ship_all(&a, 20).await);
+        assert_eq!(first, outcomes(ship_all(&b, 20).await));
+        assert!(first.contains(&true) && first.contains(&false));
+
+        assert!(ChaosTransport::new(
+            target,
+            FaultProfile {
+                error_rate: 1.5,
+                ..FaultProfile::default()
+            }
+        )
+        .is_err());
+    }
+}
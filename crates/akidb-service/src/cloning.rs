@@ -0,0 +1,51 @@
+//! Server-side collection copies.
+//!
+//! Cloning creates a new collection with the source's schema (dimension,
+//! metric, embedding model, index parameters, description and metadata,
+//! search defaults, text analysis, named vector spaces, sparse index and
+//! field indexes) and copies its documents, or only those matching a
+//! filter, without a scroll and insert round-trip through a client. Staging
+//! copies of production collections are the typical use.
+//!
+//! The copy is not a point-in-time view: writes to the source while it is
+//! being cloned may or may not be carried over. See
+//! `CollectionService::clone_collection`.
+
+use akidb_core::{CollectionId, CoreResult};
+use serde::{Deserialize, Serialize};
+
+use crate::filter::MetadataFilter;
+
+/// What a clone copies.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct CloneOptions {
+    /// Only copy documents matching this filter (default: every document).
+    #[serde(default)]
+    pub filter: Option<MetadataFilter>,
+
+    /// Copy the schema without documents.
+    #[serde(default)]
+    pub schema_only: bool,
+}
+
+impl CloneOptions {
+    pub fn validate(&self) -> CoreResult<()> {
+        match &self.filter {
+            Some(filter) => filter.validate(),
+            None => Ok(()),
+        }
+    }
+}
+
+/// Outcome of a clone.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CloneReport {
+    pub source: CollectionId,
+
+    /// The collection created by the clone.
+    pub collection_id: CollectionId,
+    pub name: String,
+
+    /// Documents copied.
+    pub documents: u64,
+}
@@ -0,0 +1,321 @@
+//! Coalescing of Get-by-ID requests.
+//!
+//! Pages that render one document per request send many small lookups at
+//! once. With coalescing enabled, Gets of the same collection that arrive
+//! within a short window are answered by one batched Get: the first request
+//! of a window waits for the window to close, fetches every requested ID and
+//! hands each waiting request its document.
+//!
+//! Coalescing adds up to one window of latency to every Get, so it is off by
+//! default. If a batch fails (or its first request is cancelled), the other
+//! requests of the batch fall back to their own Get.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, VectorDocument};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Mutex;
+use std::time::Duration;
+use tokio::sync::oneshot;
+
+/// Upper bound for the coalescing window.
+pub const MAX_COALESCING_WINDOW_MS: u64 = 100;
+
+/// Get coalescing configuration.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CoalescingConfig {
+    /// Coalesce Gets (default: false).
+    #[serde(default)]
+    pub enabled: bool,
+
+    /// Time a batch stays open for more Gets, in milliseconds (default: 2).
+    #[serde(default = "default_window_ms")]
+    pub window_ms: u64,
+
+    /// IDs per batch; a full batch is closed and the next Get opens a new one
+    /// (default: 256).
+    #[serde(default = "default_max_batch")]
+    pub max_batch: usize,
+}
+
+fn default_window_ms() -> u64 {
+    2
+}
+
+fn default_max_batch() -> usize {
+    256
+}
+
+impl Default for CoalescingConfig {
+    fn default() -> Self {
+        Self {
+            enabled: false,
+            window_ms: default_window_ms(),
+            max_batch: default_max_batch(),
+        }
+    }
+}
+
+impl CoalescingConfig {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.window_ms == 0 || self.window_ms > MAX_COALESCING_WINDOW_MS {
+            return Err(CoreError::ValidationError(format!(
+                "coalescing window_ms must be between 1 and {} (got {})",
+                MAX_COALESCING_WINDOW_MS, self.window_ms
+            )));
+        }
+        if self.max_batch < 2 {
+            return Err(CoreError::ValidationError(format!(
+                "coalescing max_batch must be at least 2 (got {})",
+                self.max_batch
+            )));
+        }
+        Ok(())
+    }
+}
+
+/// Coalesced Get counters.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CoalescingStats {
+    /// Gets answered from a batch.
+    pub requests: u64,
+
+    /// Batched Gets run.
+    pub batches: u64,
+}
+
+/// A Get's place in a batch.
+pub enum Ticket<'a> {
+    /// First Get of the batch: waits for the window, then runs the batch.
+    Leader(BatchLease<'a>),
+
+    /// Later Get: receives its document from the leader (an error when the
+    /// batch failed).
+    Follower(oneshot::Receiver<Option<VectorDocument>>),
+}
+
+/// IDs of a batch, the leader's first, and the waiting followers.
+#[derive(Default)]
+pub struct Batch {
+    pub doc_ids: Vec<DocumentId>,
+    waiters: Vec<oneshot::Sender<Option<VectorDocument>>>,
+}
+
+struct OpenBatch {
+    id: u64,
+    batch: Batch,
+}
+
+#[derive(Default)]
+struct Batches {
+    /// Batch accepting Gets, per collection.
+    open: HashMap<CollectionId, OpenBatch>,
+
+    /// Full batches whose leader has not taken them yet.
+    closed: HashMap<u64, Batch>,
+}
+
+/// Groups concurrent Gets into batches.
+pub struct GetCoalescer {
+    config: Mutex<CoalescingConfig>,
+    batches: Mutex<Batches>,
+    next_batch: AtomicU64,
+    requests: AtomicU64,
+    batch_count: AtomicU64,
+}
+
+impl Default for GetCoalescer {
+    fn default() -> Self {
+        Self {
+            config: Mutex::new(CoalescingConfig::default()),
+            batches: Mutex::new(Batches::default()),
+            next_batch: AtomicU64::new(0),
+            requests: AtomicU64::new(0),
+            batch_count: AtomicU64::new(0),
+        }
+    }
+}
+
+impl GetCoalescer {
+    pub fn set_config(&self, config: CoalescingConfig) -> CoreResult<()> {
+        config.validate()?;
+        *self.config.lock().unwrap() = config;
+        Ok(())
+    }
+
+    pub fn config(&self) -> CoalescingConfig {
+        *self.config.lock().unwrap()
+    }
+
+    /// Coalescing window, or None when coalescing is disabled.
+    pub fn window(&self) -> Option<Duration> {
+        let config = self.config();
+        config
+            .enabled
+            .then(|| Duration::from_millis(config.window_ms))
+    }
+
+    /// Adds a Get to the collection's open batch, opening one if needed.
+    pub fn join(&self, collection_id: CollectionId, doc_id: DocumentId) -> Ticket<'_> {
+        let max_batch = self.config().max_batch;
+        let mut batches = self.batches.lock().unwrap();
+        let Some(open) = batches.open.get_mut(&collection_id) else {
+            let id = self.next_batch.fetch_add(1, Ordering::Relaxed);
+            batches.open.insert(
+                collection_id,
+                OpenBatch {
+                    id,
+                    batch: Batch {
+                        doc_ids: vec![doc_id],
+                        waiters: Vec::new(),
+                    },
+                },
+            );
+            return Ticket::Leader(BatchLease {
+                coalescer: self,
+                collection_id,
+                id,
+                taken: false,
+            });
+        };
+        let (tx, rx) = oneshot::channel();
+        open.batch.doc_ids.push(doc_id);
+        open.batch.waiters.push(tx);
+        if open.batch.doc_ids.len() >= max_batch {
+            if let Some(full) = batches.open.remove(&collection_id) {
+                batches.closed.insert(full.id, full.batch);
+            }
+        }
+        Ticket::Follower(rx)
+    }
+
+    fn take(&self, collection_id: CollectionId, id: u64) -> Batch {
+        let mut batches = self.batches.lock().unwrap();
+        if let Some(batch) = batches.closed.remove(&id) {
+            return batch;
+        }
+        match batches.open.get(&collection_id) {
+            Some(open) if open.id == id => batches.open.remove(&collection_id).unwrap().batch,
+            _ => Batch::default(),
+        }
+    }
+
+    pub fn stats(&self) -> CoalescingStats {
+        CoalescingStats {
+            requests: self.requests.load(Ordering::Relaxed),
+            batches: self.batch_count.load(Ordering::Relaxed),
+        }
+    }
+}
+
+/// The leader's claim on its batch. Dropping it before taking the batch (e.g.
+/// when the leading request is cancelled) releases the other Gets, which then
+/// fall back to their own Get.
+pub struct BatchLease<'a> {
+    coalescer: &'a GetCoalescer,
+    collection_id: CollectionId,
+    id: u64,
+    taken: bool,
+}
+
+impl BatchLease<'_> {
+    /// Closes the batch so no more Gets join it.
+    pub fn take(&mut self) -> Batch {
+        self.taken = true;
+        self.coalescer.take(self.collection_id, self.id)
+    }
+
+    /// Hands the documents of a batch (in `doc_ids` order) to the followers,
+    /// returning the leader's.
+    pub fn complete(
+        &self,
+        batch: Batch,
+        docs: Vec<Option<VectorDocument>>,
+    ) -> Option<VectorDocument> {
+        let coalescer = self.coalescer;
+        coalescer
+            .requests
+            .fetch_add(batch.doc_ids.len() as u64, Ordering::Relaxed);
+        coalescer.batch_count.fetch_add(1, Ordering::Relaxed);
+        let mut docs = docs.into_iter();
+        let own = docs.next().flatten();
+        for (waiter, doc) in batch.waiters.into_iter().zip(docs) {
+            // The waiting Get may have been cancelled
+            let _ = waiter.send(doc);
+        }
+        own
+    }
+}
+
+impl Drop for BatchLease<'_> {
+    fn drop(&mut self) {
+        if !self.taken {
+            drop(self.coalescer.take(self.collection_id, self.id));
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_join_groups_gets_into_batches() {
+        let coalescer = GetCoalescer::default();
+        coalescer
+            .set_config(CoalescingConfig {
+                enabled: true,
+                window_ms: 5,
+                max_batch: 3,
+            })
+            .unwrap();
+        assert_eq!(coalescer.window(), Some(Duration::from_millis(5)));
+        let collection_id = CollectionId::new();
+
+        let Ticket::Leader(mut lease) = coalescer.join(collection_id, DocumentId::new()) else {
+            panic!("first Get should lead");
+        };
+        assert!(matches!(
+            coalescer.join(collection_id, DocumentId::new()),
+            Ticket::Follower(_)
+        ));
+        // Fills the batch, so the next Get leads a new one
+        let Ticket::Follower(mut rx) = coalescer.join(collection_id, DocumentId::new()) else {
+            panic!("batch should still be open");
+        };
+        let Ticket::Leader(next) = coalescer.join(collection_id, DocumentId::new()) else {
+            panic!("full batch should be closed");
+        };
+
+        let batch = lease.take();
+        assert_eq!(batch.doc_ids.len(), 3);
+        let doc = VectorDocument::new(batch.doc_ids[2], vec![1.0; 16]);
+        assert!(lease.complete(batch, vec![None, None, Some(doc)]).is_none());
+        assert!(rx.try_recv().unwrap().is_some());
+        assert_eq!(
+            coalescer.stats(),
+            CoalescingStats {
+                requests: 3,
+                batches: 1
+            }
+        );
+
+        // An abandoned batch releases its followers
+        let Ticket::Follower(mut rx) = coalescer.join(collection_id, DocumentId::new()) else {
+            panic!("second batch should be open");
+        };
+        drop(next);
+        assert!(rx.try_recv().is_err());
+        assert!(matches!(
+            coalescer.join(collection_id, DocumentId::new()),
+            Ticket::Leader(_)
+        ));
+
+        assert!(CoalescingConfig {
+            window_ms: 0,
+            ..Default::default()
+        }
+        .validate()
+        .is_err());
+    }
+}
@@ -0,0 +1,161 @@
+//! Collection aliases: alternative names pointing at a collection, swapped
+//! atomically by reindexing.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use std::collections::{BTreeMap, HashMap};
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+
+/// Collection aliases, and where they are stored.
+#[derive(Default)]
+pub(super) struct Aliases {
+    names: RwLock<HashMap<String, CollectionId>>,
+
+    // Kept in memory only when None
+    repository: RwLock<Option<Arc<akidb_metadata::AliasRepository>>>,
+}
+
+impl Aliases {
+    /// The collection an alias points at.
+    pub(super) async fn get(&self, alias: &str) -> Option<CollectionId> {
+        self.names.read().await.get(alias).copied()
+    }
+
+    /// Point an alias at `target` if it still points at `expected`. Returns
+    /// false, leaving the alias untouched, if it was changed meanwhile.
+    pub(super) async fn compare_and_set(
+        &self,
+        alias: &str,
+        expected: Option<CollectionId>,
+        target: CollectionId,
+    ) -> CoreResult<bool> {
+        let mut names = self.names.write().await;
+        if names.get(alias).copied() != expected {
+            return Ok(false);
+        }
+        if let Some(repository) = self.repository.read().await.clone() {
+            repository.set(alias, target).await?;
+        }
+        names.insert(alias.to_string(), target);
+        Ok(true)
+    }
+
+    /// Drop the aliases of a deleted collection, which would otherwise dangle.
+    pub(super) async fn forget(&self, collection_id: CollectionId) {
+        self.names
+            .write()
+            .await
+            .retain(|_, target| *target != collection_id);
+    }
+}
+
+impl CollectionService {
+    /// Point an alias at a collection, creating or replacing it atomically.
+    ///
+    /// Returns the collection the alias previously pointed at (if any).
+    pub async fn set_alias(
+        &self,
+        alias: &str,
+        collection_id: CollectionId,
+    ) -> CoreResult<Option<CollectionId>> {
+        validate_alias(alias)?;
+        self.get_collection(collection_id).await?;
+
+        let mut aliases = self.aliases.names.write().await;
+        if let Some(repository) = self.aliases.repository.read().await.clone() {
+            repository.set(alias, collection_id).await?;
+        }
+        Ok(aliases.insert(alias.to_string(), collection_id))
+    }
+
+    /// Resolve an alias to its collection ID.
+    pub async fn resolve_alias(&self, alias: &str) -> CoreResult<CollectionId> {
+        let aliases = self.aliases.names.read().await;
+        aliases
+            .get(alias)
+            .copied()
+            .ok_or_else(|| CoreError::not_found("Alias", alias))
+    }
+
+    /// All aliases and the collections they point at.
+    pub async fn list_aliases(&self) -> BTreeMap<String, CollectionId> {
+        let aliases = self.aliases.names.read().await;
+        aliases
+            .iter()
+            .map(|(alias, collection_id)| (alias.clone(), *collection_id))
+            .collect()
+    }
+
+    /// Remove an alias. Returns the collection it pointed at.
+    pub async fn delete_alias(&self, alias: &str) -> CoreResult<CollectionId> {
+        let mut aliases = self.aliases.names.write().await;
+        if !aliases.contains_key(alias) {
+            return Err(CoreError::not_found("Alias", alias));
+        }
+        if let Some(repository) = self.aliases.repository.read().await.clone() {
+            repository.delete(alias).await?;
+        }
+        aliases
+            .remove(alias)
+            .ok_or_else(|| CoreError::not_found("Alias", alias))
+    }
+
+    /// Set the repository aliases are stored in.
+    ///
+    /// Pass `None` to keep aliases in memory only.
+    pub async fn set_alias_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::AliasRepository>>,
+    ) {
+        *self.aliases.repository.write().await = repository;
+    }
+
+    /// Load the aliases stored in the repository (called on startup, after
+    /// the collections are loaded). Returns the number of aliases loaded.
+    pub async fn load_aliases(&self) -> CoreResult<usize> {
+        let Some(repository) = self.aliases.repository.read().await.clone() else {
+            return Ok(0);
+        };
+        let stored = repository.list_all().await?;
+        let count = stored.len();
+        self.aliases.names.write().await.extend(stored);
+        Ok(count)
+    }
+}
+
+/// Aliases stand in for collection IDs in request paths, so they must be
+/// plain path segments that cannot be mistaken for an ID.
+pub(super) fn validate_alias(alias: &str) -> CoreResult<()> {
+    const MAX_ALIAS_LEN: usize = 255;
+
+    if alias.is_empty() {
+        return Err(CoreError::ValidationError(
+            "alias cannot be empty".to_string(),
+        ));
+    }
+    if alias.len() > MAX_ALIAS_LEN {
+        return Err(CoreError::ValidationError(format!(
+            "alias must be <= {} characters (got {})",
+            MAX_ALIAS_LEN,
+            alias.len()
+        )));
+    }
+    let valid_chars = alias
+        .chars()
+        .all(|c| c.is_ascii_alphanumeric() || matches!(c, '-' | '_' | '.'));
+    if !valid_chars || alias.chars().all(|c| c == '.') {
+        return Err(CoreError::ValidationError(format!(
+            "alias '{}' may only contain letters, digits, '-', '_' and '.'",
+            alias
+        )));
+    }
+    if alias.parse::<CollectionId>().is_ok() {
+        return Err(CoreError::ValidationError(format!(
+            "alias '{}' cannot be a collection ID",
+            alias
+        )));
+    }
+    Ok(())
+}
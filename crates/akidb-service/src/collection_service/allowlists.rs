@@ -0,0 +1,119 @@
+//! Network allowlists: source networks allowed to reach the served tenant, or
+//! to use one of its API keys.
+
+use akidb_core::{ApiKeyId, CoreError, CoreResult};
+use std::collections::HashMap;
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::allowlist::{AllowlistEvaluation, AllowlistScope, AllowlistSpec, IpAllowlist};
+
+/// Source networks allowed per tenant or API key, and where they are stored.
+#[derive(Default)]
+pub(super) struct Allowlists {
+    networks: RwLock<HashMap<AllowlistScope, IpAllowlist>>,
+
+    // Kept in memory only when None
+    repository: RwLock<Option<Arc<akidb_metadata::IpAllowlistRepository>>>,
+}
+
+impl CollectionService {
+    /// Restrict the source networks requests may come from, for the tenant or
+    /// one API key. Replaces any existing allowlist of the scope.
+    pub async fn set_ip_allowlist(
+        &self,
+        scope: AllowlistScope,
+        spec: AllowlistSpec,
+    ) -> CoreResult<IpAllowlist> {
+        let allowlist = IpAllowlist::new(scope, spec)?;
+        if let Some(repository) = self.ip_allowlists.repository.read().await.clone() {
+            repository
+                .save(scope.key_id(), &serde_json::to_value(&allowlist)?)
+                .await?;
+        }
+        self.ip_allowlists
+            .networks
+            .write()
+            .await
+            .insert(scope, allowlist.clone());
+        tracing::info!(
+            "Set {} IP allowlist ({} networks)",
+            scope,
+            allowlist.spec.networks.len()
+        );
+        Ok(allowlist)
+    }
+
+    /// Get the allowlist of a tenant or API key.
+    pub async fn get_ip_allowlist(&self, scope: AllowlistScope) -> CoreResult<IpAllowlist> {
+        self.ip_allowlists
+            .networks
+            .read()
+            .await
+            .get(&scope)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("IpAllowlist", scope.to_string()))
+    }
+
+    /// List all allowlists (the tenant's first, then API keys').
+    pub async fn list_ip_allowlists(&self) -> Vec<IpAllowlist> {
+        let allowlists = self.ip_allowlists.networks.read().await;
+        let mut allowlists: Vec<IpAllowlist> = allowlists.values().cloned().collect();
+        allowlists.sort_by_key(|a| (a.scope != AllowlistScope::Tenant, a.updated_at));
+        allowlists
+    }
+
+    /// Remove the allowlist of a tenant or API key, allowing all addresses.
+    pub async fn delete_ip_allowlist(&self, scope: AllowlistScope) -> CoreResult<()> {
+        let mut allowlists = self.ip_allowlists.networks.write().await;
+        if !allowlists.contains_key(&scope) {
+            return Err(CoreError::not_found("IpAllowlist", scope.to_string()));
+        }
+        if let Some(repository) = self.ip_allowlists.repository.read().await.clone() {
+            repository.delete(scope.key_id()).await?;
+        }
+        allowlists.remove(&scope);
+        tracing::info!("Removed {} IP allowlist", scope);
+        Ok(())
+    }
+
+    /// Check a source address against the tenant's allowlist and, for
+    /// requests made with an API key, the key's allowlist.
+    pub async fn evaluate_source_ip(
+        &self,
+        ip: std::net::IpAddr,
+        api_key: Option<ApiKeyId>,
+    ) -> AllowlistEvaluation {
+        let allowlists = self.ip_allowlists.networks.read().await;
+        let key_allowlist =
+            api_key.and_then(|key_id| allowlists.get(&AllowlistScope::ApiKey { key_id }));
+        AllowlistEvaluation::new(ip, allowlists.get(&AllowlistScope::Tenant), key_allowlist)
+    }
+
+    /// Set the repository allowlists are stored in.
+    ///
+    /// Pass `None` to keep allowlists in memory only.
+    pub async fn set_ip_allowlist_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::IpAllowlistRepository>>,
+    ) {
+        *self.ip_allowlists.repository.write().await = repository;
+    }
+
+    /// Load the allowlists stored in the repository (called on startup).
+    /// Returns the number of allowlists loaded.
+    pub async fn load_ip_allowlists(&self) -> CoreResult<usize> {
+        let Some(repository) = self.ip_allowlists.repository.read().await.clone() else {
+            return Ok(0);
+        };
+        let stored = repository.list_all().await?;
+        let count = stored.len();
+        let mut allowlists = self.ip_allowlists.networks.write().await;
+        for allowlist in stored {
+            let allowlist: IpAllowlist = serde_json::from_value(allowlist)?;
+            allowlists.insert(allowlist.scope, allowlist);
+        }
+        Ok(count)
+    }
+}
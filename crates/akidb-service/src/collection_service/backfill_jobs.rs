@@ -0,0 +1,283 @@
+//! Backfill jobs: stream a collection's records that lack a vector in another
+//! collection, so a new embedding can be computed and patched in.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, JobId, VectorDocument};
+use chrono::Utc;
+use std::collections::{HashMap, HashSet};
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::backfill::{
+    BackfillBatch, BackfillJob, BackfillPatch, BackfillProgress, BackfillRecord, BackfillStatus,
+    MAX_BACKFILL_BATCH,
+};
+use crate::progress::ProgressChannels;
+
+/// Backfill jobs (in memory) and the progress streams of their watchers.
+#[derive(Default)]
+pub(super) struct BackfillJobs {
+    jobs: RwLock<HashMap<JobId, BackfillJob>>,
+
+    // Progress events for dashboards watching backfills
+    events: ProgressChannels<JobId, BackfillProgress>,
+}
+
+impl BackfillJobs {
+    /// Drop the jobs reading or writing a deleted collection, closing their
+    /// progress streams.
+    pub(super) async fn forget(&self, collection_id: CollectionId) {
+        self.jobs.write().await.retain(|id, job| {
+            let keep = job.source != collection_id && job.target != collection_id;
+            if !keep {
+                self.events.close(id);
+            }
+            keep
+        });
+    }
+}
+
+impl CollectionService {
+    /// Start a backfill job streaming `source` records that have no vector in `target`.
+    ///
+    /// The target holds the new vector under the source document IDs; patched
+    /// records get the source's external ID and metadata.
+    pub async fn create_backfill_job(
+        &self,
+        source: CollectionId,
+        target: CollectionId,
+    ) -> CoreResult<BackfillJob> {
+        if source == target {
+            return Err(CoreError::ValidationError(
+                "backfill source and target must be different collections".to_string(),
+            ));
+        }
+        self.get_collection(source).await?;
+        self.get_collection(target).await?;
+
+        let job = BackfillJob::new(source, target);
+        self.backfill_jobs
+            .jobs
+            .write()
+            .await
+            .insert(job.id, job.clone());
+
+        tracing::info!("Created backfill job {} ({} -> {})", job.id, source, target);
+        Ok(job)
+    }
+
+    /// List backfill jobs.
+    pub async fn list_backfill_jobs(&self) -> Vec<BackfillJob> {
+        let jobs = self.backfill_jobs.jobs.read().await;
+        let mut jobs: Vec<BackfillJob> = jobs.values().cloned().collect();
+        jobs.sort_by_key(|job| job.created_at);
+        jobs
+    }
+
+    /// Get a backfill job.
+    pub async fn get_backfill_job(&self, job_id: JobId) -> CoreResult<BackfillJob> {
+        let jobs = self.backfill_jobs.jobs.read().await;
+        jobs.get(&job_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("BackfillJob", job_id.to_string()))
+    }
+
+    /// Hand out the next batch of records missing a vector, advancing the job cursor.
+    ///
+    /// Concurrent callers receive disjoint batches. An empty batch with
+    /// `exhausted` set means the cursor reached the end of the source.
+    pub async fn backfill_next_batch(
+        &self,
+        job_id: JobId,
+        limit: usize,
+    ) -> CoreResult<BackfillBatch> {
+        if limit == 0 || limit > MAX_BACKFILL_BATCH {
+            return Err(CoreError::ValidationError(format!(
+                "batch limit must be between 1 and {} (got {})",
+                MAX_BACKFILL_BATCH, limit
+            )));
+        }
+
+        // Hold the job lock while scanning so concurrent workers get disjoint batches
+        let mut jobs = self.backfill_jobs.jobs.write().await;
+        let job = jobs
+            .get_mut(&job_id)
+            .ok_or_else(|| CoreError::not_found("BackfillJob", job_id.to_string()))?;
+        if job.status != BackfillStatus::Running {
+            return Err(CoreError::invalid_state(format!(
+                "backfill job {} is {:?}",
+                job_id, job.status
+            )));
+        }
+
+        let mut records = Vec::new();
+        if !job.exhausted {
+            let present = self.document_ids(job.target).await?;
+            let mut docs = self.list_documents(job.source).await?;
+            docs.sort_by_key(|doc| doc.doc_id.as_uuid());
+
+            let cursor = job.cursor.map(|id| id.as_uuid());
+            let mut pending = docs
+                .into_iter()
+                .filter(|doc| cursor.map_or(true, |c| doc.doc_id.as_uuid() > c))
+                .filter(|doc| !present.contains(&doc.doc_id))
+                .peekable();
+
+            records.extend(pending.by_ref().take(limit).map(|doc| BackfillRecord {
+                doc_id: doc.doc_id,
+                external_id: doc.external_id,
+                metadata: doc.metadata,
+            }));
+            if pending.peek().is_none() {
+                job.exhausted = true;
+            }
+            if let Some(last) = records.last() {
+                job.cursor = Some(last.doc_id);
+            }
+            job.updated_at = Utc::now();
+        }
+
+        Ok(BackfillBatch {
+            job_id,
+            records,
+            exhausted: job.exhausted,
+        })
+    }
+
+    /// Write computed vectors into the target collection.
+    ///
+    /// Patches for records already present in the target or deleted from the
+    /// source are ignored, so retrying a batch is safe. Marks the job completed
+    /// once no source record is missing a vector.
+    pub async fn backfill_patch(
+        &self,
+        job_id: JobId,
+        patches: Vec<BackfillPatch>,
+    ) -> CoreResult<BackfillProgress> {
+        let job = self.get_backfill_job(job_id).await?;
+        if job.status == BackfillStatus::Cancelled {
+            return Err(CoreError::invalid_state(format!(
+                "backfill job {} is cancelled",
+                job_id
+            )));
+        }
+
+        let doc_ids: Vec<DocumentId> = patches.iter().map(|p| p.doc_id).collect();
+        let sources = self.get_many(job.source, &doc_ids).await?;
+        let existing = self.get_many(job.target, &doc_ids).await?;
+
+        for ((patch, source), existing) in patches.into_iter().zip(sources).zip(existing) {
+            let Some(source) = source else { continue };
+            if existing.is_some() {
+                continue;
+            }
+
+            let mut doc = VectorDocument::new(patch.doc_id, patch.vector);
+            doc.external_id = source.external_id;
+            doc.metadata = source.metadata;
+            self.insert(job.target, doc).await?;
+        }
+
+        let progress = self.backfill_progress(job_id).await?;
+        self.publish_backfill_progress(&progress);
+        Ok(progress)
+    }
+
+    /// Get backfill progress, marking the job completed if nothing is missing.
+    pub async fn backfill_progress(&self, job_id: JobId) -> CoreResult<BackfillProgress> {
+        let job = self.get_backfill_job(job_id).await?;
+        let present = self.document_ids(job.target).await?;
+        let source = self.document_ids(job.source).await?;
+        let total = source.len();
+        let completed = source.iter().filter(|id| present.contains(id)).count();
+
+        let mut progress = BackfillProgress {
+            job_id,
+            status: job.status,
+            total,
+            completed,
+            remaining: total - completed,
+        };
+        if progress.status == BackfillStatus::Running && completed == total {
+            progress.status = BackfillStatus::Completed;
+            if let Some(job) = self.backfill_jobs.jobs.write().await.get_mut(&job_id) {
+                job.status = progress.status;
+                job.updated_at = Utc::now();
+            }
+            self.publish_backfill_progress(&progress);
+        }
+        Ok(progress)
+    }
+
+    /// Resume a job: rewind its cursor so records handed out but never patched
+    /// (e.g. by a crashed worker) are streamed again.
+    pub async fn resume_backfill(&self, job_id: JobId) -> CoreResult<BackfillJob> {
+        let mut jobs = self.backfill_jobs.jobs.write().await;
+        let job = jobs
+            .get_mut(&job_id)
+            .ok_or_else(|| CoreError::not_found("BackfillJob", job_id.to_string()))?;
+        job.rewind();
+        let job = job.clone();
+        drop(jobs);
+
+        self.publish_backfill_progress(&self.backfill_progress(job_id).await?);
+        Ok(job)
+    }
+
+    /// Cancel a job. Vectors already patched stay in the target.
+    pub async fn cancel_backfill(&self, job_id: JobId) -> CoreResult<BackfillJob> {
+        let mut jobs = self.backfill_jobs.jobs.write().await;
+        let job = jobs
+            .get_mut(&job_id)
+            .ok_or_else(|| CoreError::not_found("BackfillJob", job_id.to_string()))?;
+        job.status = BackfillStatus::Cancelled;
+        job.updated_at = Utc::now();
+        let job = job.clone();
+        drop(jobs);
+
+        self.publish_backfill_progress(&self.backfill_progress(job_id).await?);
+        Ok(job)
+    }
+
+    /// Receive a backfill job's progress from now on, after every patch,
+    /// resume or cancel.
+    ///
+    /// The stream ends when the job completes (or its collections are
+    /// deleted); for a completed job it is already closed.
+    pub async fn subscribe_backfill_progress(
+        &self,
+        job_id: JobId,
+    ) -> CoreResult<tokio::sync::broadcast::Receiver<BackfillProgress>> {
+        let receiver = self.backfill_jobs.events.subscribe(job_id);
+        // Checked after subscribing so a completion in between is not missed
+        let status = match self.get_backfill_job(job_id).await {
+            Ok(job) => job.status,
+            Err(e) => {
+                self.backfill_jobs.events.close(&job_id);
+                return Err(e);
+            }
+        };
+        if status == BackfillStatus::Completed {
+            self.backfill_jobs.events.close(&job_id);
+        }
+        Ok(receiver)
+    }
+
+    fn publish_backfill_progress(&self, progress: &BackfillProgress) {
+        self.backfill_jobs
+            .events
+            .publish(&progress.job_id, progress.clone());
+        if progress.status == BackfillStatus::Completed {
+            self.backfill_jobs.events.close(&progress.job_id);
+        }
+    }
+
+    /// IDs of all documents in a collection.
+    async fn document_ids(&self, collection_id: CollectionId) -> CoreResult<HashSet<DocumentId>> {
+        Ok(self
+            .list_documents(collection_id)
+            .await?
+            .into_iter()
+            .map(|doc| doc.doc_id)
+            .collect())
+    }
+}
@@ -0,0 +1,79 @@
+//! Cache priming: replay of a collection's recent distinct searches to warm
+//! its caches, e.g. after a restart.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use std::time::Instant;
+
+use super::{CollectionService, MAX_TOP_K};
+use crate::query_log::{validate_prime_limit, PrimeReport, QueryPattern};
+
+impl CollectionService {
+    /// Up to `limit` recent query patterns of a collection, most frequent
+    /// first.
+    pub async fn query_patterns(
+        &self,
+        collection_id: CollectionId,
+        limit: usize,
+    ) -> CoreResult<Vec<QueryPattern>> {
+        validate_prime_limit(limit)?;
+        self.get_collection(collection_id).await?;
+        Ok(self.query_log.top(collection_id, limit))
+    }
+
+    /// Replay query patterns to warm a collection after a deploy or restore.
+    ///
+    /// Replays `patterns` if given (e.g. saved from `query_patterns` before a
+    /// restart), else up to `limit` patterns from the query log. Replayed
+    /// searches are not metered nor logged; a failing one is counted and
+    /// skipped.
+    pub async fn prime(
+        &self,
+        collection_id: CollectionId,
+        patterns: Option<Vec<QueryPattern>>,
+        limit: usize,
+    ) -> CoreResult<PrimeReport> {
+        validate_prime_limit(limit)?;
+        let patterns = match patterns {
+            Some(patterns) => {
+                validate_prime_limit(patterns.len().max(1))?;
+                patterns
+            }
+            None => self.query_log.top(collection_id, limit),
+        };
+        self.get_collection(collection_id).await?;
+        let start = Instant::now();
+
+        // Bring the collection to the hot tier before replaying
+        if let Some(tiering_manager) = &self.tiering_manager {
+            let _ = tiering_manager.record_access(collection_id).await;
+        }
+
+        let defaults = self.search_defaults(collection_id).await?;
+        let _gate = self.commit_gate.read().await;
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        let mut failed = 0;
+        for pattern in &patterns {
+            if pattern.top_k == 0 || pattern.top_k > MAX_TOP_K {
+                failed += 1;
+                continue;
+            }
+            if index
+                .search(&pattern.query_vector, pattern.top_k, defaults.ef_search)
+                .await
+                .is_err()
+            {
+                failed += 1;
+            }
+        }
+
+        Ok(PrimeReport {
+            queries: patterns.len(),
+            failed,
+            duration_ms: start.elapsed().as_millis() as u64,
+        })
+    }
+}
@@ -0,0 +1,278 @@
+//! Copies of a collection: reindexing into a shadow collection behind an
+//! alias, and cloning into a new collection.
+
+use akidb_core::{CollectionDescriptor, CollectionId, CoreError, CoreResult, IndexType};
+use std::time::Instant;
+
+use super::aliases::validate_alias;
+use super::CollectionService;
+use crate::cloning::{CloneOptions, CloneReport};
+use crate::collection_update::CollectionUpdate;
+use crate::index_options::IndexOptions;
+use crate::reindex::{ReindexPlan, ReindexProgress, ReindexReport, REINDEX_PROGRESS_INTERVAL};
+
+impl CollectionService {
+    /// Reindex a collection into a new shadow collection and swap an alias to it.
+    ///
+    /// Steps: create the shadow collection (copying the source's text analysis
+    /// settings), copy every document through the plan's transform, validate that
+    /// the shadow holds exactly the copied documents and that the source count did
+    /// not change during the copy, then atomically point the alias at the shadow.
+    /// On any failure the shadow collection is deleted and the alias is untouched.
+    ///
+    /// Writes to the source during the copy are not carried over; they are
+    /// detected by the count check and fail the reindex.
+    pub async fn reindex(&self, plan: ReindexPlan) -> CoreResult<ReindexReport> {
+        let start = Instant::now();
+        validate_alias(&plan.alias)?;
+        let source = self.get_collection(plan.source).await?;
+
+        // The alias must be unset or already point at the source
+        let previous = self.aliases.get(&plan.alias).await;
+        if let Some(current) = previous {
+            if current != plan.source {
+                return Err(CoreError::ValidationError(format!(
+                    "alias '{}' points at collection {}, not the reindex source {}",
+                    plan.alias, current, plan.source
+                )));
+            }
+        }
+
+        let target = self
+            .create_collection(
+                plan.target_name.clone(),
+                plan.dimension.unwrap_or(source.dimension),
+                plan.metric.unwrap_or(source.metric),
+                Some(
+                    plan.embedding_model
+                        .clone()
+                        .unwrap_or_else(|| source.embedding_model.clone()),
+                ),
+            )
+            .await?;
+
+        let (copied, skipped) = match self.copy_and_swap(&plan, target, previous).await {
+            Ok(counts) => counts,
+            Err(e) => {
+                if let Err(cleanup) = self.delete_collection(target).await {
+                    tracing::warn!(
+                        "Failed to delete shadow collection {} after reindex failure: {}",
+                        target,
+                        cleanup
+                    );
+                }
+                return Err(e);
+            }
+        };
+
+        let source_deleted = if plan.delete_source {
+            self.delete_collection(plan.source).await?;
+            true
+        } else {
+            false
+        };
+
+        tracing::info!(
+            "Reindexed collection {} into {} (alias '{}', {} copied, {} skipped)",
+            plan.source,
+            target,
+            plan.alias,
+            copied,
+            skipped
+        );
+
+        Ok(ReindexReport {
+            alias: plan.alias,
+            source: plan.source,
+            target,
+            copied,
+            skipped,
+            source_deleted,
+            duration: start.elapsed(),
+        })
+    }
+
+    /// Copy documents into the shadow collection, validate counts and swap the alias.
+    async fn copy_and_swap(
+        &self,
+        plan: &ReindexPlan,
+        target: CollectionId,
+        previous: Option<CollectionId>,
+    ) -> CoreResult<(usize, usize)> {
+        self.copy_text_analysis(plan.source, target).await?;
+        let defaults = self.search_defaults(plan.source).await?;
+        if !defaults.is_empty() {
+            self.set_search_defaults(target, defaults).await?;
+        }
+        if let Some(config) = self.sparse_index_config(plan.source).await? {
+            self.set_sparse_index(target, config).await?;
+        }
+        let named = self.named_vector_configs(plan.source).await?;
+        if !named.is_empty() {
+            self.set_named_vector_configs(target, named).await?;
+        }
+
+        let docs = self.list_documents(plan.source).await?;
+        let total = docs.len();
+        let mut progress = ReindexProgress {
+            copied: 0,
+            skipped: 0,
+            total,
+        };
+
+        for doc in docs {
+            let doc = self.with_stored_vectors(plan.source, doc).await;
+            let doc = match &plan.transform {
+                Some(transform) => transform(doc)?,
+                None => Some(doc),
+            };
+            match doc {
+                Some(doc) => {
+                    self.insert(target, doc).await?;
+                    progress.copied += 1;
+                }
+                None => {
+                    progress.skipped += 1;
+                }
+            }
+
+            if let Some(callback) = &plan.progress {
+                if (progress.copied + progress.skipped) % REINDEX_PROGRESS_INTERVAL == 0 {
+                    callback(progress);
+                }
+            }
+        }
+        if let Some(callback) = &plan.progress {
+            callback(progress);
+        }
+        // Validate counts before exposing the new collection
+        let target_count = self.get_count(target).await?;
+        if target_count != progress.copied {
+            return Err(CoreError::invalid_state(format!(
+                "reindex validation failed: shadow collection has {} documents, expected {}",
+                target_count, progress.copied
+            )));
+        }
+        let source_count = self.get_count(plan.source).await?;
+        if source_count != total {
+            return Err(CoreError::invalid_state(format!(
+                "reindex validation failed: source collection changed during copy ({} -> {} documents)",
+                total, source_count
+            )));
+        }
+
+        // Atomic swap (compare-and-set against the alias state seen at the start)
+        if !self
+            .aliases
+            .compare_and_set(&plan.alias, previous, target)
+            .await?
+        {
+            return Err(CoreError::invalid_state(format!(
+                "alias '{}' was modified during reindex",
+                plan.alias
+            )));
+        }
+
+        Ok((progress.copied, progress.skipped))
+    }
+
+    /// Copy a collection's schema and documents (all, or those matching
+    /// `options.filter`) into a new collection named `name`.
+    ///
+    /// Document IDs are kept. On failure the new collection is deleted.
+    pub async fn clone_collection(
+        &self,
+        source_id: CollectionId,
+        name: String,
+        options: CloneOptions,
+    ) -> CoreResult<CloneReport> {
+        options.validate()?;
+        let source = self.get_collection(source_id).await?;
+        let hnsw = source.index_type != IndexType::Flat;
+        let collection_id = self
+            .create_collection_with_index(
+                name.clone(),
+                source.dimension,
+                source.metric,
+                Some(source.embedding_model.clone()),
+                IndexOptions {
+                    index_type: source.index_type,
+                    hnsw_m: hnsw.then_some(source.hnsw_m),
+                    hnsw_ef_construction: hnsw.then_some(source.hnsw_ef_construction),
+                    ef_search: None,
+                },
+            )
+            .await?;
+
+        match self.clone_into(&source, collection_id, &options).await {
+            Ok(documents) => {
+                tracing::info!(
+                    "Cloned collection {} into {} ({} documents)",
+                    source_id,
+                    collection_id,
+                    documents
+                );
+                Ok(CloneReport {
+                    source: source_id,
+                    collection_id,
+                    name,
+                    documents,
+                })
+            }
+            Err(e) => {
+                if let Err(cleanup) = self.delete_collection(collection_id).await {
+                    tracing::error!(
+                        "Failed to delete collection {} after a failed clone: {}",
+                        collection_id,
+                        cleanup
+                    );
+                }
+                Err(e)
+            }
+        }
+    }
+
+    async fn clone_into(
+        &self,
+        source: &CollectionDescriptor,
+        target: CollectionId,
+        options: &CloneOptions,
+    ) -> CoreResult<u64> {
+        let source_id = source.collection_id;
+        let settings = CollectionUpdate {
+            description: source.description.clone(),
+            metadata: source.metadata.clone(),
+            max_doc_count: Some(source.max_doc_count),
+            search_defaults: Some(self.search_defaults(source_id).await?),
+            ..Default::default()
+        };
+        self.update_collection(target, settings).await?;
+        self.copy_text_analysis(source_id, target).await?;
+        if let Some(config) = self.sparse_index_config(source_id).await? {
+            self.set_sparse_index(target, config).await?;
+        }
+        let named = self.named_vector_configs(source_id).await?;
+        if !named.is_empty() {
+            self.set_named_vector_configs(target, named).await?;
+        }
+
+        let mut documents = 0;
+        for doc in self.list_documents(source_id).await? {
+            let copy = !options.schema_only
+                && options
+                    .filter
+                    .as_ref()
+                    .map_or(true, |filter| filter.matches(doc.metadata.as_ref()));
+            if copy {
+                let doc = self.with_stored_vectors(source_id, doc).await;
+                self.insert(target, doc).await?;
+                documents += 1;
+            }
+        }
+        for index in self.list_field_indexes(source_id).await? {
+            self.create_field_index(target, &index.field, index.field_type)
+                .await?;
+        }
+        Ok(documents)
+    }
+}
@@ -0,0 +1,262 @@
+//! Data subject requests: jobs exporting or purging a subject's documents
+//! across collections, with a signed report.
+
+use akidb_core::{CoreError, CoreResult, JobId, VectorDocument};
+use chrono::Utc;
+use std::collections::HashMap;
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::anomaly::AnomalyKind;
+use crate::compliance::{
+    export_path, random_signing_key, CollectionTally, ComplianceAction, ComplianceJob,
+    ComplianceReport, ComplianceRequest, ComplianceStatus, ExportRecord,
+};
+use crate::events::{EventComponent, EventLevel};
+use crate::legal_hold::held_by;
+use crate::manifest::{ManifestBuilder, EXPORT_PART_BYTES};
+
+/// Data subject export/purge jobs and the key signing their reports.
+pub(super) struct ComplianceJobs {
+    jobs: RwLock<HashMap<JobId, ComplianceJob>>,
+    signing_key: RwLock<Vec<u8>>,
+}
+
+impl Default for ComplianceJobs {
+    fn default() -> Self {
+        Self {
+            jobs: RwLock::new(HashMap::new()),
+            signing_key: RwLock::new(random_signing_key()),
+        }
+    }
+}
+
+impl CollectionService {
+    /// Set the key signing compliance reports.
+    ///
+    /// Without one, a random key is generated at startup and reports cannot
+    /// be verified after a restart.
+    pub async fn set_compliance_signing_key(&self, key: Vec<u8>) -> CoreResult<()> {
+        if key.is_empty() {
+            return Err(CoreError::ValidationError(
+                "signing key must not be empty".to_string(),
+            ));
+        }
+        *self.compliance.signing_key.write().await = key;
+        Ok(())
+    }
+
+    /// Start a job exporting or purging a data subject's documents across all
+    /// collections of the tenant.
+    ///
+    /// The job runs in the background; poll `get_compliance_job` for its
+    /// signed report.
+    pub async fn start_compliance_job(
+        self: &Arc<Self>,
+        request: ComplianceRequest,
+    ) -> CoreResult<ComplianceJob> {
+        request.validate()?;
+        let job = ComplianceJob::new(request);
+        if job.request.action == ComplianceAction::Export && job.request.subject.is_none() {
+            self.access_monitor.flag(
+                AnomalyKind::BulkExport,
+                None,
+                format!(
+                    "compliance job {} exports every document of the tenant",
+                    job.id
+                ),
+            );
+        }
+        self.compliance
+            .jobs
+            .write()
+            .await
+            .insert(job.id, job.clone());
+        tracing::info!(
+            "Started compliance job {} ({:?})",
+            job.id,
+            job.request.action
+        );
+
+        let service = Arc::clone(self);
+        let job_id = job.id;
+        tokio::spawn(async move { service.run_compliance_job(job_id).await });
+        Ok(job)
+    }
+
+    /// List compliance jobs, oldest first.
+    pub async fn list_compliance_jobs(&self) -> Vec<ComplianceJob> {
+        let jobs = self.compliance.jobs.read().await;
+        let mut jobs: Vec<ComplianceJob> = jobs.values().cloned().collect();
+        jobs.sort_by_key(|job| job.created_at);
+        jobs
+    }
+
+    /// Get a compliance job, including its report once completed.
+    pub async fn get_compliance_job(&self, job_id: JobId) -> CoreResult<ComplianceJob> {
+        self.compliance
+            .jobs
+            .read()
+            .await
+            .get(&job_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("ComplianceJob", job_id.to_string()))
+    }
+
+    /// Path of a completed export job's NDJSON file.
+    pub async fn compliance_export_file(&self, job_id: JobId) -> CoreResult<std::path::PathBuf> {
+        let job = self.get_compliance_job(job_id).await?;
+        if job.request.action != ComplianceAction::Export {
+            return Err(CoreError::invalid_state(format!(
+                "compliance job {} is not an export",
+                job_id
+            )));
+        }
+        if job.status != ComplianceStatus::Completed {
+            return Err(CoreError::invalid_state(format!(
+                "compliance job {} is {:?}",
+                job_id, job.status
+            )));
+        }
+        Ok(export_path(job_id))
+    }
+
+    /// Returns true if a report was signed by this server's signing key.
+    pub async fn verify_compliance_report(&self, report: &ComplianceReport) -> bool {
+        report.verify(&self.compliance.signing_key.read().await)
+    }
+
+    async fn run_compliance_job(&self, job_id: JobId) {
+        let Ok(job) = self.get_compliance_job(job_id).await else {
+            return;
+        };
+        let result = self.execute_compliance_job(job_id, &job.request).await;
+
+        let mut jobs = self.compliance.jobs.write().await;
+        let Some(job) = jobs.get_mut(&job_id) else {
+            return;
+        };
+        job.updated_at = Utc::now();
+        match result {
+            Ok(report) => {
+                tracing::info!(
+                    "Compliance job {} completed ({} documents)",
+                    job_id,
+                    report.total_documents
+                );
+                job.status = ComplianceStatus::Completed;
+                job.report = Some(report);
+            }
+            Err(e) => {
+                self.events.publish(
+                    EventLevel::Error,
+                    EventComponent::Compliance,
+                    None,
+                    format!("Compliance job {} failed: {}", job_id, e),
+                );
+                job.status = ComplianceStatus::Failed;
+                job.error = Some(e.to_string());
+            }
+        }
+    }
+
+    async fn execute_compliance_job(
+        &self,
+        job_id: JobId,
+        request: &ComplianceRequest,
+    ) -> CoreResult<ComplianceReport> {
+        use tokio::io::AsyncWriteExt;
+
+        let started_at = Utc::now();
+        let io_err = |e: std::io::Error| CoreError::internal(format!("Export failed: {}", e));
+        let mut export = match request.action {
+            ComplianceAction::Export => {
+                let path = export_path(job_id);
+                if let Some(dir) = path.parent() {
+                    tokio::fs::create_dir_all(dir).await.map_err(io_err)?;
+                }
+                let file = tokio::fs::File::create(&path).await.map_err(io_err)?;
+                Some((
+                    tokio::io::BufWriter::new(file),
+                    ManifestBuilder::new(EXPORT_PART_BYTES),
+                ))
+            }
+            ComplianceAction::Purge => None,
+        };
+
+        let mut collections = Vec::new();
+        let mut held_documents = 0;
+        for collection in self.list_collections().await? {
+            let mut documents: Vec<VectorDocument> = self
+                .list_documents(collection.collection_id)
+                .await?
+                .into_iter()
+                .filter(|doc| request.subject.as_ref().map_or(true, |s| s.matches(doc)))
+                .collect();
+            if request.action == ComplianceAction::Purge {
+                // Held documents are kept until the hold is released
+                let holds = self.legal_holds_on(collection.collection_id).await;
+                let before = documents.len();
+                documents.retain(|doc| held_by(&holds, doc.metadata.as_ref()).is_none());
+                held_documents += (before - documents.len()) as u64;
+            }
+            if documents.is_empty() {
+                continue;
+            }
+            let tally = CollectionTally {
+                collection_id: collection.collection_id,
+                name: collection.name.clone(),
+                documents: documents.len() as u64,
+            };
+
+            match &mut export {
+                Some((writer, manifest)) => {
+                    for doc in documents {
+                        let record = ExportRecord::new(
+                            collection.collection_id,
+                            &collection.name,
+                            doc,
+                            request.vector_codec,
+                        );
+                        let mut line = serde_json::to_vec(&record).map_err(|e| {
+                            CoreError::internal(format!("Failed to serialize record: {}", e))
+                        })?;
+                        line.push(b'\n');
+                        manifest.push(&line);
+                        writer.write_all(&line).await.map_err(io_err)?;
+                    }
+                }
+                None => {
+                    for doc in &documents {
+                        self.delete(collection.collection_id, doc.doc_id).await?;
+                    }
+                }
+            }
+            collections.push(tally);
+        }
+
+        let export_manifest = match export {
+            Some((mut writer, manifest)) => {
+                writer.flush().await.map_err(io_err)?;
+                Some(manifest.finish())
+            }
+            None => None,
+        };
+        let mut report = ComplianceReport {
+            job_id,
+            action: request.action,
+            subject: request.subject.clone(),
+            total_documents: collections.iter().map(|c| c.documents).sum(),
+            collections,
+            held_documents,
+            export_sha256: export_manifest.as_ref().map(|m| m.sha256.clone()),
+            export_manifest,
+            started_at,
+            completed_at: Utc::now(),
+            signature: String::new(),
+        };
+        report.sign(&self.compliance.signing_key.read().await);
+        Ok(report)
+    }
+}
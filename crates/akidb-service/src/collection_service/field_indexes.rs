@@ -0,0 +1,244 @@
+//! Metadata field indexes: per-collection indexes of metadata fields that
+//! narrow filtered reads and searches to the matching documents.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, VectorDocument};
+use serde_json::Value as JsonValue;
+use std::collections::{HashMap, HashSet};
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::field_index::{FieldIndexInfo, FieldIndexType, FieldIndexes};
+use crate::filter::MetadataFilter;
+
+/// Metadata field indexes of each collection, and where their definitions
+/// are stored.
+#[derive(Default)]
+pub(super) struct FieldIndexState {
+    indexes: RwLock<HashMap<CollectionId, FieldIndexes>>,
+
+    // Where definitions are stored (kept in memory only when None)
+    repository: RwLock<Option<Arc<akidb_metadata::FieldIndexRepository>>>,
+}
+
+impl FieldIndexState {
+    /// Index a written document's metadata, replacing its previous entries.
+    pub(super) async fn insert(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+        metadata: Option<&JsonValue>,
+        replaced: bool,
+    ) {
+        if let Some(indexes) = self.indexes.write().await.get_mut(&collection_id) {
+            if replaced {
+                indexes.remove(doc_id);
+            }
+            indexes.insert(doc_id, metadata);
+        }
+    }
+
+    /// Remove a deleted document from the collection's indexes.
+    pub(super) async fn remove(&self, collection_id: CollectionId, doc_id: DocumentId) {
+        if let Some(indexes) = self.indexes.write().await.get_mut(&collection_id) {
+            indexes.remove(doc_id);
+        }
+    }
+
+    /// Drop the indexes of a deleted collection.
+    pub(super) async fn forget(&self, collection_id: CollectionId) {
+        self.indexes.write().await.remove(&collection_id);
+    }
+}
+
+impl CollectionService {
+    /// Index a metadata field of a collection, so filters with `$eq` or `$in`
+    /// conditions on it only check the matching documents (see
+    /// `field_index`). Indexes the documents already stored.
+    pub async fn create_field_index(
+        &self,
+        collection_id: CollectionId,
+        field: &str,
+        field_type: FieldIndexType,
+    ) -> CoreResult<FieldIndexInfo> {
+        let info = self
+            .build_field_index(collection_id, field, field_type)
+            .await?;
+        if let Some(repository) = self.field_indexes.repository.read().await.clone() {
+            let record = akidb_metadata::FieldIndexRecord {
+                collection_id,
+                field: field.to_string(),
+                field_type: field_type.as_str().to_string(),
+            };
+            if let Err(e) = repository.insert(&record).await {
+                self.drop_field_index(collection_id, field).await;
+                return Err(e);
+            }
+        }
+        tracing::info!(
+            "Indexed field '{}' of collection {} ({} values)",
+            field,
+            collection_id,
+            info.values
+        );
+        Ok(info)
+    }
+
+    /// Index the documents already stored under a new field index.
+    async fn build_field_index(
+        &self,
+        collection_id: CollectionId,
+        field: &str,
+        field_type: FieldIndexType,
+    ) -> CoreResult<FieldIndexInfo> {
+        self.get_collection(collection_id).await?;
+        let docs = self.list_documents(collection_id).await?;
+        self.field_indexes
+            .indexes
+            .write()
+            .await
+            .entry(collection_id)
+            .or_default()
+            .create(field, field_type, &docs)
+    }
+
+    /// Remove a field index from memory. Returns false if there was none.
+    async fn drop_field_index(&self, collection_id: CollectionId, field: &str) -> bool {
+        let mut field_indexes = self.field_indexes.indexes.write().await;
+        let Some(indexes) = field_indexes.get_mut(&collection_id) else {
+            return false;
+        };
+        let removed = indexes.remove_index(field);
+        if indexes.is_empty() {
+            field_indexes.remove(&collection_id);
+        }
+        removed
+    }
+
+    pub async fn delete_field_index(
+        &self,
+        collection_id: CollectionId,
+        field: &str,
+    ) -> CoreResult<()> {
+        self.get_collection(collection_id).await?;
+        let exists = self
+            .field_indexes
+            .indexes
+            .read()
+            .await
+            .get(&collection_id)
+            .map_or(false, |indexes| indexes.contains(field));
+        if !exists {
+            return Err(CoreError::not_found("Field index", field));
+        }
+        if let Some(repository) = self.field_indexes.repository.read().await.clone() {
+            repository.delete(collection_id, field).await?;
+        }
+        if !self.drop_field_index(collection_id, field).await {
+            return Err(CoreError::not_found("Field index", field));
+        }
+        Ok(())
+    }
+
+    /// Set the repository field index definitions are stored in.
+    ///
+    /// Pass `None` to keep field indexes in memory only.
+    pub async fn set_field_index_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::FieldIndexRepository>>,
+    ) {
+        *self.field_indexes.repository.write().await = repository;
+    }
+
+    /// Rebuild the field indexes stored in the repository (called on
+    /// startup, after the collections are loaded). Indexes that cannot be
+    /// rebuilt are logged and skipped. Returns the number rebuilt.
+    pub async fn load_field_indexes(&self) -> CoreResult<usize> {
+        let Some(repository) = self.field_indexes.repository.read().await.clone() else {
+            return Ok(0);
+        };
+        let mut count = 0;
+        for record in repository.list_all().await? {
+            let Ok(field_type) = record.field_type.parse::<FieldIndexType>() else {
+                tracing::warn!(
+                    "Skipping field index '{}' of collection {}: unknown type '{}'",
+                    record.field,
+                    record.collection_id,
+                    record.field_type
+                );
+                continue;
+            };
+            match self
+                .build_field_index(record.collection_id, &record.field, field_type)
+                .await
+            {
+                Ok(_) => count += 1,
+                Err(e) => tracing::warn!(
+                    "Failed to rebuild field index '{}' of collection {}: {}",
+                    record.field,
+                    record.collection_id,
+                    e
+                ),
+            }
+        }
+        Ok(count)
+    }
+
+    /// The field indexes of a collection, by field name.
+    pub async fn list_field_indexes(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<Vec<FieldIndexInfo>> {
+        self.get_collection(collection_id).await?;
+        Ok(self
+            .field_indexes
+            .indexes
+            .read()
+            .await
+            .get(&collection_id)
+            .map(FieldIndexes::list)
+            .unwrap_or_default())
+    }
+
+    pub(super) async fn has_field_indexes(&self, collection_id: CollectionId) -> bool {
+        self.field_indexes
+            .indexes
+            .read()
+            .await
+            .contains_key(&collection_id)
+    }
+
+    /// A superset of the documents matching `filter` selected by the field
+    /// indexes, or None when they cannot narrow it down.
+    pub(super) async fn indexed_candidates(
+        &self,
+        collection_id: CollectionId,
+        filter: &MetadataFilter,
+    ) -> Option<HashSet<DocumentId>> {
+        self.field_indexes
+            .indexes
+            .read()
+            .await
+            .get(&collection_id)?
+            .candidates(filter)
+    }
+
+    /// Documents to check against `filter`: the indexed candidates, or every
+    /// document.
+    pub(super) async fn filter_candidates(
+        &self,
+        collection_id: CollectionId,
+        filter: &MetadataFilter,
+    ) -> CoreResult<Vec<VectorDocument>> {
+        let Some(candidates) = self.indexed_candidates(collection_id, filter).await else {
+            return self.list_documents(collection_id).await;
+        };
+        let doc_ids: Vec<DocumentId> = candidates.into_iter().collect();
+        Ok(self
+            .get_many(collection_id, &doc_ids)
+            .await?
+            .into_iter()
+            .flatten()
+            .collect())
+    }
+}
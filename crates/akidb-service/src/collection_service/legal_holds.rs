@@ -0,0 +1,154 @@
+//! Legal holds blocking deletes of collections and documents.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, LegalHoldId};
+use std::collections::HashMap;
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::legal_hold::{held_by, LegalHold, LegalHoldSpec};
+
+/// Legal holds placed on collections, and where they are stored.
+#[derive(Default)]
+pub(super) struct LegalHolds {
+    holds: RwLock<HashMap<LegalHoldId, LegalHold>>,
+
+    // Kept in memory only when None
+    repository: RwLock<Option<Arc<akidb_metadata::LegalHoldRepository>>>,
+}
+
+impl CollectionService {
+    /// Place a legal hold on a collection, or on the documents matching the
+    /// spec's metadata filter.
+    ///
+    /// Held documents cannot be deleted, and the collection cannot be deleted,
+    /// until the hold is released.
+    pub async fn place_legal_hold(
+        &self,
+        collection_id: CollectionId,
+        spec: LegalHoldSpec,
+    ) -> CoreResult<LegalHold> {
+        self.get_collection(collection_id).await?;
+        let hold = LegalHold::new(collection_id, spec)?;
+        if let Some(repository) = self.legal_holds.repository.read().await.clone() {
+            repository
+                .insert(hold.id, collection_id, &serde_json::to_value(&hold)?)
+                .await?;
+        }
+        self.legal_holds
+            .holds
+            .write()
+            .await
+            .insert(hold.id, hold.clone());
+        tracing::info!(
+            "Placed legal hold {} on collection {}: {}",
+            hold.id,
+            collection_id,
+            hold.spec.reason
+        );
+        Ok(hold)
+    }
+
+    /// List legal holds, optionally only those on one collection, oldest first.
+    pub async fn list_legal_holds(&self, collection_id: Option<CollectionId>) -> Vec<LegalHold> {
+        let holds = self.legal_holds.holds.read().await;
+        let mut holds: Vec<LegalHold> = holds
+            .values()
+            .filter(|h| collection_id.map_or(true, |cid| h.collection_id == cid))
+            .cloned()
+            .collect();
+        holds.sort_by_key(|h| h.placed_at);
+        holds
+    }
+
+    /// Get a legal hold.
+    pub async fn get_legal_hold(&self, hold_id: LegalHoldId) -> CoreResult<LegalHold> {
+        self.legal_holds
+            .holds
+            .read()
+            .await
+            .get(&hold_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("LegalHold", hold_id.to_string()))
+    }
+
+    /// Release a legal hold. Documents it covered can be deleted again unless
+    /// another hold covers them.
+    pub async fn release_legal_hold(&self, hold_id: LegalHoldId) -> CoreResult<()> {
+        let mut holds = self.legal_holds.holds.write().await;
+        let hold = holds
+            .get(&hold_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("LegalHold", hold_id.to_string()))?;
+        if let Some(repository) = self.legal_holds.repository.read().await.clone() {
+            repository.delete(hold_id).await?;
+        }
+        holds.remove(&hold_id);
+        tracing::info!(
+            "Released legal hold {} on collection {}",
+            hold_id,
+            hold.collection_id
+        );
+        Ok(())
+    }
+
+    /// Set the repository legal holds are stored in.
+    ///
+    /// Pass `None` to keep holds in memory only.
+    pub async fn set_legal_hold_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::LegalHoldRepository>>,
+    ) {
+        *self.legal_holds.repository.write().await = repository;
+    }
+
+    /// Load the legal holds stored in the repository (called on startup,
+    /// after the collections are loaded). Returns the number of holds loaded.
+    pub async fn load_legal_holds(&self) -> CoreResult<usize> {
+        let Some(repository) = self.legal_holds.repository.read().await.clone() else {
+            return Ok(0);
+        };
+        let stored = repository.list_all().await?;
+        let count = stored.len();
+        let mut holds = self.legal_holds.holds.write().await;
+        for hold in stored {
+            let hold: LegalHold = serde_json::from_value(hold)?;
+            holds.insert(hold.id, hold);
+        }
+        Ok(count)
+    }
+
+    pub(super) async fn legal_holds_on(&self, collection_id: CollectionId) -> Vec<LegalHold> {
+        self.list_legal_holds(Some(collection_id)).await
+    }
+
+    /// Fail with `UnderLegalHold` if a legal hold covers the document.
+    pub(super) async fn check_legal_holds(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> CoreResult<()> {
+        let holds = self.legal_holds_on(collection_id).await;
+        if holds.is_empty() {
+            return Ok(());
+        }
+        // Only filtered holds need the document's metadata
+        let metadata = if holds.iter().any(LegalHold::is_collection_wide) {
+            None
+        } else {
+            // Read the index directly: this is not a client read
+            match self.read_document(collection_id, doc_id).await? {
+                Some(doc) => doc.metadata,
+                None => return Ok(()),
+            }
+        };
+        match held_by(&holds, metadata.as_ref()) {
+            Some(hold) => Err(CoreError::under_legal_hold(
+                "Document",
+                doc_id.to_string(),
+                hold.id,
+            )),
+            None => Ok(()),
+        }
+    }
+}
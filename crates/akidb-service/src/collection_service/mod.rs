@@ -0,0 +1,7314 @@
+//! Service layer for collection operations.
+//! Shared by gRPC and REST APIs.
+
+mod aliases;
+mod allowlists;
+mod backfill_jobs;
+mod cache_priming;
+mod collection_copy;
+mod compliance_jobs;
+mod field_indexes;
+mod legal_holds;
+mod monitoring;
+mod named_vector_spaces;
+mod operations;
+mod policy;
+mod replication_targets;
+mod scheduler;
+mod snapshots;
+mod sparse_vectors;
+mod subscriptions;
+mod tenants;
+mod text_analysis;
+mod transactions;
+mod uploads;
+mod usage_reports;
+
+use akidb_core::{
+    CollectionDescriptor, CollectionId, CollectionRepository, CoreError, CoreResult, DatabaseId,
+    DistanceMetric, DocumentId, SearchResult, SnapshotId, SubscriptionId, TransactionId,
+    VectorDocument, VectorIndex,
+};
+use akidb_index::{BruteForceIndex, InstantDistanceConfig, InstantDistanceIndex};
+use akidb_storage::{
+    CacheStats, CircuitBreakerState, StorageBackend, StorageConfig, StorageMetrics,
+};
+use chrono::Utc;
+use serde_json::{Map, Value as JsonValue};
+use std::collections::{HashMap, HashSet};
+use std::sync::Arc;
+use std::time::Instant;
+use tokio::sync::RwLock;
+use tokio::task::{JoinError, JoinSet};
+
+// Import metrics for instrumentation
+use crate::metrics::*;
+
+// Phase 10 Week 3: Tiering manager integration
+use akidb_storage::tiering_manager::TieringManager;
+
+use crate::analysis::TextAnalysis;
+use crate::anomaly::AccessMonitor;
+use crate::batch::{
+    check_batch_size, check_delete_batch_size, repeated_ids, BatchInsertReport, ContentIdSpec,
+    DeleteFailure, DeleteReport, DuplicateAction, DuplicateId,
+};
+use crate::batch_search::{BatchQuery, BatchSearchOptions};
+use crate::changes::ChangeFeeds;
+use crate::coalesce::{GetCoalescer, Ticket};
+use crate::collection_update::CollectionUpdate;
+use crate::composition::QueryComposition;
+use crate::cost::IndexKind;
+use crate::events::EventLog;
+use crate::field_index::{search_candidates, MAX_EXACT_CANDIDATES};
+use crate::filter::MetadataFilter;
+use crate::handle::CollectionHandle;
+use crate::impersonation::Impersonation;
+use crate::index_options::IndexOptions;
+use crate::named_vectors::NamedVectors;
+use crate::ordering::{check_page_limit, ListOrder, MetadataSort, Page, Partition, MAX_PAGE_SIZE};
+use crate::parent_retrieval::{
+    group_by_parent, parent_document_ids, ParentResult, ParentSearchOptions,
+};
+use crate::patch::{apply_patch, MetadataPatch};
+use crate::post_processing::PostProcessingPipeline;
+use crate::query_log::QueryLog;
+use crate::range::{RangeQuery, RangeSearchResult, RANGE_INITIAL_K};
+use crate::recommend::RecommendRequest;
+use crate::replication::ReplicationOp;
+use crate::search_defaults::{SearchDefaults, FILTER_OVERFETCH};
+use crate::snapshot::{snapshot_dir, CollectionSnapshot};
+use crate::sparse::SparseEncoder;
+use crate::sparse_index::{SparseIndex, SparseIndexConfig};
+use crate::standing::StandingQuery;
+use crate::transaction::Transaction;
+use crate::usage::UsageMeter;
+use crate::write_locks::WriteLocks;
+use aliases::Aliases;
+use allowlists::Allowlists;
+use backfill_jobs::BackfillJobs;
+use compliance_jobs::ComplianceJobs;
+use field_indexes::FieldIndexState;
+use legal_holds::LegalHolds;
+use monitoring::Monitors;
+use policy::PolicyState;
+use replication_targets::ReplicationState;
+use scheduler::Scheduler;
+use tenants::TenantState;
+use uploads::Uploads;
+
+/// Maximum `top_k` of a search. Reasonable limit: 10,000 results (prevents
+/// usize::MAX attacks)
+pub(crate) const MAX_TOP_K: usize = 10_000;
+
+/// Documents sampled by an approximate count.
+pub const COUNT_SAMPLE_SIZE: usize = 10_000;
+
+/// Number of documents matching a filter.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct DocumentCount {
+    pub count: usize,
+
+    /// False if `count` was extrapolated from a sample.
+    pub exact: bool,
+}
+
+/// Result of DLQ retry operation
+#[derive(Debug, Clone)]
+pub struct DLQRetryResult {
+    pub total: usize,
+    pub succeeded: usize,
+    pub failed: usize,
+}
+
+/// Service-level metrics for collections, vectors, and operations
+#[derive(Debug, Clone)]
+pub struct ServiceMetrics {
+    pub total_collections: usize,
+    pub total_vectors: usize,
+    pub total_searches: u64,
+    pub total_inserts: u64,
+    pub uptime_seconds: u64,
+}
+
+impl ServiceMetrics {
+    /// Export metrics in Prometheus text format
+    pub async fn export_prometheus(&self) -> String {
+        let mut output = String::new();
+
+        output.push_str("# HELP akidb_total_collections Total number of collections\n");
+        output.push_str("# TYPE akidb_total_collections gauge\n");
+        output.push_str(&format!(
+            "akidb_total_collections {}\n",
+            self.total_collections
+        ));
+        output.push('\n');
+
+        output.push_str(
+            "# HELP akidb_total_vectors Total number of vectors across all collections\n",
+        );
+        output.push_str("# TYPE akidb_total_vectors gauge\n");
+        output.push_str(&format!("akidb_total_vectors {}\n", self.total_vectors));
+        output.push('\n');
+
+        output.push_str("# HELP akidb_uptime_seconds Server uptime in seconds\n");
+        output.push_str("# TYPE akidb_uptime_seconds counter\n");
+        output.push_str(&format!("akidb_uptime_seconds {}\n", self.uptime_seconds));
+        output.push('\n');
+
+        output
+    }
+
+    /// Get total collections created
+    pub fn collections_created(&self) -> usize {
+        self.total_collections
+    }
+
+    /// Get total vectors inserted
+    pub fn vectors_inserted(&self) -> usize {
+        self.total_vectors
+    }
+
+    /// Get total searches performed
+    pub fn searches_performed(&self) -> u64 {
+        self.total_searches
+    }
+
+    /// Get total collections deleted (placeholder for now)
+    pub fn collections_deleted(&self) -> usize {
+        0 // TODO: Track deletions separately
+    }
+}
+
+/// Service layer for collection operations.
+/// Shared by gRPC and REST APIs.
+pub struct CollectionService {
+    // SQLite repository for persistence (optional for testing)
+    repository: Option<Arc<dyn CollectionRepository>>,
+
+    // Vector persistence (optional, for Week 2+ vector durability)
+    vector_persistence: Option<Arc<akidb_metadata::VectorPersistence>>,
+
+    // In-memory cache for fast reads (synced with repository)
+    collections: Arc<RwLock<HashMap<CollectionId, CollectionDescriptor>>>,
+
+    // In-memory vector indexes (collection_id -> VectorIndex)
+    indexes: Arc<RwLock<HashMap<CollectionId, Box<dyn VectorIndex>>>>,
+
+    // Default database_id for RC1 (single-database mode)
+    default_database_id: Arc<RwLock<Option<DatabaseId>>>,
+
+    // Served tenant (single-tenant mode) and tenant provisioning
+    tenant: TenantState,
+
+    // Storage backends (Phase 6 Week 5+: per-collection tiered storage)
+    storage_backends: Arc<RwLock<HashMap<CollectionId, Arc<StorageBackend>>>>,
+
+    // Storage configuration (used when creating new storage backends)
+    storage_config: StorageConfig,
+
+    // Server start time for uptime tracking (Phase 7 Week 4)
+    start_time: Instant,
+
+    // Tiering manager for hot/warm/cold tier management (Phase 10 Week 3)
+    // Optional: If None, tiering is disabled (backward compatible)
+    tiering_manager: Option<Arc<TieringManager>>,
+
+    // Default post-processing pipeline applied by query() (None = raw index results)
+    post_processing: Arc<RwLock<Option<PostProcessingPipeline>>>,
+
+    // Per-collection text analysis settings (stopwords, synonyms, per-field analyzers);
+    // default analyzer if unset
+    text_analysis: Arc<RwLock<HashMap<CollectionId, TextAnalysis>>>,
+
+    // Per-collection sparse vector indexes (none if the collection has no sparse index)
+    sparse_indexes: Arc<RwLock<HashMap<CollectionId, SparseIndex>>>,
+
+    // Per-collection metadata field indexes (none if the collection has none)
+    field_indexes: FieldIndexState,
+
+    // Per-collection named vector spaces (none if the collection declares none)
+    named_vectors: Arc<RwLock<HashMap<CollectionId, NamedVectors>>>,
+
+    // Collection aliases (alias name -> collection_id)
+    aliases: Aliases,
+
+    // Backfill jobs for adding a new vector to existing records
+    backfill_jobs: BackfillJobs,
+
+    // Drift monitors, adaptive search controllers and growth samples
+    monitors: Monitors,
+
+    // Monthly searches, ingestion and storage per collection, for billing
+    usage: Arc<UsageMeter>,
+
+    // Recent distinct searches per collection, replayed to prime caches
+    query_log: Arc<QueryLog>,
+
+    // Tenant collection defaults and hard limits (single-tenant mode: applies to all collections)
+    collection_policy: PolicyState,
+
+    // Resumable import uploads (parts are spooled to disk)
+    uploads: Uploads,
+
+    // Standing queries checked against every insert
+    standing_queries: Arc<RwLock<HashMap<SubscriptionId, Arc<StandingQuery>>>>,
+
+    // Cron-scheduled housekeeping jobs
+    scheduler: Scheduler,
+
+    // Data subject export/purge jobs
+    compliance: ComplianceJobs,
+
+    // Collection snapshots (files in the temp directory, listed again on startup)
+    snapshots: Arc<RwLock<HashMap<SnapshotId, CollectionSnapshot>>>,
+
+    // Legal holds blocking deletes
+    legal_holds: LegalHolds,
+
+    // Flags access patterns that may indicate data exfiltration
+    access_monitor: Arc<AccessMonitor>,
+
+    // Problems of background work, for operators to alert on
+    events: Arc<EventLog>,
+
+    // Batches concurrent Gets of a collection (disabled by default)
+    get_coalescer: Arc<GetCoalescer>,
+
+    // Admin impersonation of the served tenant (disabled without an admin key)
+    impersonation: Arc<Impersonation>,
+
+    // Cross-region replication targets and their pending writes
+    replication: ReplicationState,
+
+    // Change logs of the collections with change stream subscribers
+    changes: Arc<ChangeFeeds>,
+
+    // Source networks allowed per tenant / API key
+    ip_allowlists: Allowlists,
+
+    // Open write transactions. Readers hold the commit gate shared and commits
+    // hold it exclusively while applying logged writes, so they appear at once
+    transactions: Arc<RwLock<HashMap<TransactionId, Transaction>>>,
+    commit_gate: Arc<RwLock<()>>,
+
+    // Serialize writes to the same document
+    write_locks: Arc<WriteLocks>,
+}
+
+impl CollectionService {
+    /// Creates a new collection service with in-memory storage only.
+    /// For testing purposes. Production should use `with_repository()`.
+    pub fn new() -> Self {
+        Self {
+            repository: None,
+            vector_persistence: None,
+            collections: Arc::new(RwLock::new(HashMap::new())),
+            indexes: Arc::new(RwLock::new(HashMap::new())),
+            default_database_id: Arc::new(RwLock::new(None)),
+            tenant: TenantState::default(),
+            storage_backends: Arc::new(RwLock::new(HashMap::new())),
+            storage_config: StorageConfig::default(),
+            start_time: Instant::now(),
+            tiering_manager: None,
+            post_processing: Arc::new(RwLock::new(None)),
+            text_analysis: Arc::new(RwLock::new(HashMap::new())),
+            sparse_indexes: Arc::new(RwLock::new(HashMap::new())),
+            field_indexes: FieldIndexState::default(),
+            named_vectors: Arc::new(RwLock::new(HashMap::new())),
+            aliases: Aliases::default(),
+            backfill_jobs: BackfillJobs::default(),
+            monitors: Monitors::default(),
+            usage: Arc::new(UsageMeter::new()),
+            query_log: Arc::new(QueryLog::default()),
+            collection_policy: PolicyState::default(),
+            uploads: Uploads::default(),
+            standing_queries: Arc::new(RwLock::new(HashMap::new())),
+            scheduler: Scheduler::default(),
+            compliance: ComplianceJobs::default(),
+            snapshots: Arc::new(RwLock::new(HashMap::new())),
+            legal_holds: LegalHolds::default(),
+            access_monitor: Arc::new(AccessMonitor::default()),
+            events: Arc::new(EventLog::default()),
+            get_coalescer: Arc::new(GetCoalescer::default()),
+            impersonation: Arc::new(Impersonation::default()),
+            replication: ReplicationState::default(),
+            changes: Arc::new(ChangeFeeds::default()),
+            ip_allowlists: Allowlists::default(),
+            transactions: Arc::new(RwLock::new(HashMap::new())),
+            commit_gate: Arc::new(RwLock::new(())),
+            write_locks: Arc::new(WriteLocks::default()),
+        }
+    }
+
+    /// Creates a new collection service with SQLite persistence.
+    /// Collections are persisted to the database and loaded on startup.
+    pub fn with_repository(repository: Arc<dyn CollectionRepository>) -> Self {
+        Self {
+            repository: Some(repository),
+            ..Self::new()
+        }
+    }
+
+    /// Creates a new collection service with full persistence (collections + vectors).
+    /// This is the recommended constructor for production use (Phase 5 Week 2+).
+    pub fn with_full_persistence(
+        repository: Arc<dyn CollectionRepository>,
+        vector_persistence: Arc<akidb_metadata::VectorPersistence>,
+    ) -> Self {
+        Self {
+            vector_persistence: Some(vector_persistence),
+            ..Self::with_repository(repository)
+        }
+    }
+
+    /// Creates a new collection service with tiered storage configuration.
+    /// This constructor allows custom storage policies (Memory, MemoryS3, S3Only).
+    /// (Phase 6 Week 5+: Integration with StorageBackend for each collection).
+    pub fn with_storage(
+        repository: Arc<dyn CollectionRepository>,
+        vector_persistence: Arc<akidb_metadata::VectorPersistence>,
+        storage_config: StorageConfig,
+    ) -> Self {
+        Self {
+            storage_config,
+            ..Self::with_full_persistence(repository, vector_persistence)
+        }
+    }
+
+    /// Creates a new collection service with hot/warm/cold tiering manager.
+    /// This is the full-featured constructor for production use with automatic tiering.
+    /// (Phase 10 Week 3: Integration with TieringManager).
+    pub fn with_tiering(
+        repository: Arc<dyn CollectionRepository>,
+        vector_persistence: Arc<akidb_metadata::VectorPersistence>,
+        storage_config: StorageConfig,
+        tiering_manager: Arc<TieringManager>,
+    ) -> Self {
+        Self {
+            tiering_manager: Some(tiering_manager),
+            ..Self::with_storage(repository, vector_persistence, storage_config)
+        }
+    }
+
+    /// Gets a reference to the tiering manager (if enabled).
+    /// (Phase 10 Week 3: Tiering manager integration).
+    pub fn tiering_manager(&self) -> Option<Arc<TieringManager>> {
+        self.tiering_manager.clone()
+    }
+
+    /// Gets aggregated storage metrics from all storage backends.
+    ///
+    /// Returns `None` if no storage backends are configured (e.g., in-memory only mode).
+    /// Aggregates metrics across all collections' storage backends.
+    pub async fn storage_metrics(&self) -> Option<akidb_storage::StorageMetrics> {
+        let backends = self.storage_backends.read().await;
+
+        if backends.is_empty() {
+            return None;
+        }
+
+        // Aggregate metrics from all storage backends
+        let mut aggregated = akidb_storage::StorageMetrics::default();
+
+        for backend in backends.values() {
+            let backend_metrics = backend.metrics();
+
+            // FIX BUG #11: Use saturating_add to prevent integer overflow
+            // In debug mode, += would panic on overflow
+            // In release mode, += would wrap around (incorrect metrics)
+            aggregated.inserts = aggregated.inserts.saturating_add(backend_metrics.inserts);
+            aggregated.queries = aggregated.queries.saturating_add(backend_metrics.queries);
+            aggregated.deletes = aggregated.deletes.saturating_add(backend_metrics.deletes);
+            aggregated.s3_uploads = aggregated
+                .s3_uploads
+                .saturating_add(backend_metrics.s3_uploads);
+            aggregated.s3_downloads = aggregated
+                .s3_downloads
+                .saturating_add(backend_metrics.s3_downloads);
+            aggregated.cache_hits = aggregated
+                .cache_hits
+                .saturating_add(backend_metrics.cache_hits);
+            aggregated.cache_misses = aggregated
+                .cache_misses
+                .saturating_add(backend_metrics.cache_misses);
+            aggregated.wal_size_bytes = aggregated
+                .wal_size_bytes
+                .saturating_add(backend_metrics.wal_size_bytes);
+            aggregated.compactions = aggregated
+                .compactions
+                .saturating_add(backend_metrics.compactions);
+            aggregated.s3_retries = aggregated
+                .s3_retries
+                .saturating_add(backend_metrics.s3_retries);
+            aggregated.s3_permanent_failures = aggregated
+                .s3_permanent_failures
+                .saturating_add(backend_metrics.s3_permanent_failures);
+            aggregated.dlq_size = aggregated.dlq_size.saturating_add(backend_metrics.dlq_size);
+
+            // Take the highest error rate and breaker state across all backends
+            if backend_metrics.circuit_breaker_error_rate > aggregated.circuit_breaker_error_rate {
+                aggregated.circuit_breaker_error_rate = backend_metrics.circuit_breaker_error_rate;
+            }
+
+            // If any circuit breaker is open (1), use that state
+            if backend_metrics.circuit_breaker_state > aggregated.circuit_breaker_state {
+                aggregated.circuit_breaker_state = backend_metrics.circuit_breaker_state;
+            }
+
+            // Use the most recent snapshot time
+            if backend_metrics.last_snapshot_at.is_some() {
+                match (
+                    aggregated.last_snapshot_at,
+                    backend_metrics.last_snapshot_at,
+                ) {
+                    (None, Some(ts)) => aggregated.last_snapshot_at = Some(ts),
+                    (Some(existing), Some(new)) if new > existing => {
+                        aggregated.last_snapshot_at = Some(new);
+                    }
+                    _ => {}
+                }
+            }
+        }
+
+        Some(aggregated)
+    }
+
+    /// Set the default database_id for RC1 single-database mode.
+    pub async fn set_default_database_id(&self, database_id: DatabaseId) {
+        let mut default_db = self.default_database_id.write().await;
+        *default_db = Some(database_id);
+    }
+
+    /// Set the default post-processing pipeline applied by `query()`.
+    /// Pass `None` to return raw index results.
+    pub async fn set_post_processing(&self, pipeline: Option<PostProcessingPipeline>) {
+        let mut post_processing = self.post_processing.write().await;
+        *post_processing = pipeline;
+    }
+
+    /// Get the default post-processing pipeline (if any).
+    pub async fn post_processing(&self) -> Option<PostProcessingPipeline> {
+        self.post_processing.read().await.clone()
+    }
+
+    /// Get the default database_id, or create a new one if not set (for in-memory mode).
+    async fn get_or_create_database_id(&self) -> DatabaseId {
+        let default_db = self.default_database_id.read().await;
+        match *default_db {
+            Some(id) => id,
+            None => DatabaseId::new(), // Fallback for in-memory mode
+        }
+    }
+
+    // ========== Collection Management Operations ==========
+
+    /// Create per-collection storage configuration.
+    /// Each collection gets its own WAL and snapshot directories.
+    fn create_storage_backend_for_collection(
+        &self,
+        collection: &CollectionDescriptor,
+    ) -> CoreResult<StorageConfig> {
+        // Create per-collection WAL directory: {base_wal_path}/collections/{collection_id}/wal
+        let collection_wal_path = self
+            .storage_config
+            .wal_path
+            .parent()
+            .unwrap_or_else(|| std::path::Path::new("."))
+            .join("collections")
+            .join(collection.collection_id.to_string())
+            .join("wal");
+
+        // Create per-collection snapshot directory: {base_snapshot_path}/collections/{collection_id}/snapshots
+        let collection_snapshot_dir = self
+            .storage_config
+            .snapshot_dir
+            .parent()
+            .unwrap_or_else(|| std::path::Path::new("."))
+            .join("collections")
+            .join(collection.collection_id.to_string())
+            .join("snapshots");
+
+        // Create directories if they don't exist
+        if let Some(parent) = collection_wal_path.parent() {
+            std::fs::create_dir_all(parent).map_err(|e| {
+                CoreError::invalid_state(format!("Failed to create WAL directory: {}", e))
+            })?;
+        }
+        std::fs::create_dir_all(&collection_snapshot_dir).map_err(|e| {
+            CoreError::invalid_state(format!("Failed to create snapshot directory: {}", e))
+        })?;
+
+        // Clone base config and update paths
+        let mut config = self.storage_config.clone();
+        config.wal_path = collection_wal_path;
+        config.snapshot_dir = collection_snapshot_dir;
+
+        // FIX BUG #16: Set the real collection_id for WAL entries and S3 keys
+        config.collection_id = collection.collection_id;
+
+        Ok(config)
+    }
+
+    /// Load all collections from repository on startup.
+    /// Only works if service was created with `with_repository()`.
+    pub async fn load_all_collections(&self) -> CoreResult<()> {
+        let Some(repo) = &self.repository else {
+            // No repository = in-memory mode, nothing to load
+            return Ok(());
+        };
+
+        // Load all collections from SQLite
+        let descriptors = repo.list_all().await?;
+
+        // Populate cache and load indexes
+        for descriptor in descriptors {
+            {
+                let mut collections = self.collections.write().await;
+                collections.insert(descriptor.collection_id, descriptor.clone());
+            }
+            // Load index (ignoring errors for individual collections)
+            if let Err(e) = self.load_collection(&descriptor).await {
+                tracing::warn!(
+                    "Failed to load index for collection {}: {}",
+                    descriptor.collection_id,
+                    e
+                );
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Create a new collection.
+    pub async fn create_collection(
+        &self,
+        name: String,
+        dimension: u32,
+        metric: DistanceMetric,
+        embedding_model: Option<String>,
+    ) -> CoreResult<CollectionId> {
+        self.create_collection_with_index(
+            name,
+            dimension,
+            metric,
+            embedding_model,
+            IndexOptions::default(),
+        )
+        .await
+    }
+
+    /// Create a new collection with the given index type and parameters.
+    pub async fn create_collection_with_index(
+        &self,
+        name: String,
+        dimension: u32,
+        metric: DistanceMetric,
+        embedding_model: Option<String>,
+        index: IndexOptions,
+    ) -> CoreResult<CollectionId> {
+        validate_collection_name(&name)?;
+        index.validate()?;
+
+        // Validate dimension
+        if !(16..=4096).contains(&dimension) {
+            return Err(CoreError::invalid_state(format!(
+                "dimension must be between 16 and 4096, got {}",
+                dimension
+            )));
+        }
+
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_dimension(dimension)?;
+
+        // FIX BUG #13: Validate embedding_model length (prevent DoS via unbounded strings)
+        const MAX_EMBEDDING_MODEL_LEN: usize = 256;
+        let embedding_model_validated = match embedding_model {
+            Some(model) if !model.is_empty() && model.len() <= MAX_EMBEDDING_MODEL_LEN => model,
+            Some(ref model) if model.is_empty() => {
+                return Err(CoreError::ValidationError(
+                    "embedding_model cannot be empty".to_string(),
+                ))
+            }
+            Some(ref model) => {
+                return Err(CoreError::ValidationError(format!(
+                    "embedding_model must be <= {} characters (got {})",
+                    MAX_EMBEDDING_MODEL_LEN,
+                    model.len()
+                )))
+            }
+            None => "none".to_string(),
+        };
+
+        // Get database_id for RC1 single-database mode
+        let database_id = self.get_or_create_database_id().await;
+
+        // Index options such as ef_search become the collection's search defaults
+        let search_defaults = index.search_defaults();
+        let search_defaults = if search_defaults.is_empty() {
+            None
+        } else {
+            Some(encode_setting("search defaults", &search_defaults)?)
+        };
+
+        // Create collection descriptor
+        let collection_id = CollectionId::new();
+        let collection = CollectionDescriptor {
+            collection_id,
+            database_id,
+            name: name.clone(),
+            dimension,
+            metric,
+            embedding_model: embedding_model_validated,
+            index_type: index.index_type,
+            hnsw_m: index.hnsw_m.unwrap_or(CollectionDescriptor::DEFAULT_HNSW_M),
+            hnsw_ef_construction: index
+                .hnsw_ef_construction
+                .unwrap_or(CollectionDescriptor::DEFAULT_HNSW_EF_CONSTRUCTION),
+            max_doc_count: CollectionDescriptor::DEFAULT_MAX_DOC_COUNT,
+            description: None,
+            metadata: None,
+            read_only: false,
+            sparse_index: None,
+            named_vectors: None,
+            search_defaults,
+            text_analysis: None,
+            created_at: Utc::now(),
+            updated_at: Utc::now(),
+        };
+
+        // FIX BUG #7: Atomic creation with rollback on failure
+        // Use early-return pattern to ensure all steps succeed or rollback
+
+        // Step 1: Persist to SQLite if repository exists
+        if let Some(repo) = &self.repository {
+            repo.create(&collection).await?;
+        }
+
+        // Step 2: Store in cache
+        {
+            let mut collections = self.collections.write().await;
+            collections.insert(collection_id, collection.clone());
+        }
+
+        // FIX BUG #15: load_collection creates AND stores the StorageBackend
+        // Removed duplicate StorageBackend creation to prevent data loss on restart
+        //
+        // BEFORE (BROKEN):
+        // 1. load_collection creates StorageBackend #1 and loads legacy SQLite vectors into it
+        // 2. load_collection stores backend #1 in storage_backends map
+        // 3. create_collection creates StorageBackend #2 (empty, new WAL)
+        // 4. create_collection OVERWRITES backend #1 with backend #2 in map
+        // → Backend #1 (with migrated data) is dropped without shutdown
+        // → Migrated data exists only in RAM, lost on restart
+        // → Legacy SQLite vectors are loaded again on next startup (infinite migration loop)
+        //
+        // AFTER (FIXED):
+        // 1. load_collection creates StorageBackend and loads legacy SQLite vectors into it
+        // 2. load_collection stores backend in storage_backends map
+        // 3. Done! No duplicate creation, no data loss
+
+        // Step 3: Create and load index + storage backend (with rollback on failure)
+        if let Err(e) = self.load_collection(&collection).await {
+            // Rollback: Remove from cache
+            self.collections.write().await.remove(&collection_id);
+            // Rollback: Remove from SQLite
+            if let Some(repo) = &self.repository {
+                let _ = repo.delete(collection_id).await; // Best effort
+            }
+            return Err(e);
+        }
+
+        Ok(collection_id)
+    }
+
+    /// List all collections, in ID order.
+    pub async fn list_collections(&self) -> CoreResult<Vec<CollectionDescriptor>> {
+        let mut collections: Vec<CollectionDescriptor> =
+            self.collections.read().await.values().cloned().collect();
+        // Stable order (IDs are time-ordered UUID v7)
+        ListOrder::default().sort(&mut collections);
+        Ok(collections)
+    }
+
+    /// One page of collections in a deterministic order (see `ListOrder`).
+    pub async fn list_collections_page(
+        &self,
+        order: ListOrder,
+        cursor: Option<&str>,
+        limit: usize,
+    ) -> CoreResult<Page<CollectionDescriptor>> {
+        let collections = self.list_collections().await?;
+        order.paginate(collections, cursor, limit)
+    }
+
+    /// Get a specific collection by ID.
+    pub async fn get_collection(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<CollectionDescriptor> {
+        let collections = self.collections.read().await;
+        collections
+            .get(&collection_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))
+    }
+
+    /// Delete a collection.
+    pub async fn delete_collection(&self, collection_id: CollectionId) -> CoreResult<()> {
+        self.check_writable(collection_id).await?;
+        if let Some(hold) = self.legal_holds_on(collection_id).await.first() {
+            return Err(CoreError::under_legal_hold(
+                "Collection",
+                collection_id.to_string(),
+                hold.id,
+            ));
+        }
+
+        // Delete from SQLite if repository exists
+        if let Some(repo) = &self.repository {
+            repo.delete(collection_id).await?;
+        }
+
+        // Remove from cache
+        {
+            let mut collections = self.collections.write().await;
+            collections
+                .remove(&collection_id)
+                .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        }
+
+        // Unload index
+        self.unload_collection(collection_id).await?;
+
+        self.text_analysis.write().await.remove(&collection_id);
+        self.sparse_indexes.write().await.remove(&collection_id);
+        self.field_indexes.forget(collection_id).await;
+        self.named_vectors.write().await.remove(&collection_id);
+        self.replication.forget(collection_id);
+        self.changes.remove(collection_id);
+
+        self.aliases.forget(collection_id).await;
+        self.backfill_jobs.forget(collection_id).await;
+        self.monitors.forget(collection_id).await;
+        self.access_monitor.forget(collection_id);
+        self.query_log.forget(collection_id);
+        self.forget_uploads(collection_id).await;
+        self.scheduler.forget(collection_id).await;
+        // Dropping a standing query closes its subscribers' streams
+        self.standing_queries
+            .write()
+            .await
+            .retain(|_, query| query.collection_id() != collection_id);
+
+        // FIX BUG #2: Shutdown storage backend BEFORE removing to prevent resource leaks
+        // This ensures background tasks (S3 uploader, retry worker, compaction, DLQ cleanup) are stopped
+        // and WAL buffers are flushed to prevent data loss
+        {
+            let mut backends = self.storage_backends.write().await;
+            if let Some(backend) = backends.remove(&collection_id) {
+                // Shutdown gracefully (aborts tasks, flushes WAL)
+                if let Err(e) = backend.shutdown().await {
+                    tracing::warn!(
+                        "Failed to shutdown storage backend for collection {}: {}",
+                        collection_id,
+                        e
+                    );
+                    // Continue with deletion even if shutdown fails
+                }
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Rename a collection in place.
+    ///
+    /// Documents, indexes, aliases and in-flight writes are keyed by collection
+    /// ID, so nothing is copied and no writes are lost. The rename has two
+    /// phases: the new name is checked against the other collections and
+    /// persisted while holding the collection cache lock (so a concurrent
+    /// rename cannot claim it), and only then is the cached descriptor swapped.
+    /// If persisting fails, the collection keeps its old name.
+    pub async fn rename_collection(
+        &self,
+        collection_id: CollectionId,
+        new_name: String,
+    ) -> CoreResult<CollectionDescriptor> {
+        validate_collection_name(&new_name)?;
+
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        if current.name == new_name {
+            return Ok(current.clone());
+        }
+        if collections
+            .values()
+            .any(|c| c.collection_id != collection_id && c.name == new_name)
+        {
+            return Err(CoreError::already_exists("Collection", new_name));
+        }
+
+        // Phase 1: persist the new name
+        let mut renamed = current.clone();
+        let old_name = std::mem::replace(&mut renamed.name, new_name);
+        renamed.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&renamed).await?;
+        }
+
+        // Phase 2: swap the cached descriptor
+        collections.insert(collection_id, renamed.clone());
+        tracing::info!(
+            "Renamed collection {} from '{}' to '{}'",
+            collection_id,
+            old_name,
+            renamed.name
+        );
+        Ok(renamed)
+    }
+
+    /// Update a collection's mutable settings in place (see
+    /// `CollectionUpdate`); settings left out are unchanged.
+    ///
+    /// Documents and indexes are untouched. As with renames, the descriptor
+    /// is persisted before the cached copy is swapped, so if persisting fails
+    /// the collection keeps its old settings.
+    pub async fn update_collection(
+        &self,
+        collection_id: CollectionId,
+        update: CollectionUpdate,
+    ) -> CoreResult<CollectionDescriptor> {
+        update.validate()?;
+        if let Some(max_doc_count) = update.max_doc_count {
+            let count = self.get_count(collection_id).await? as u64;
+            if max_doc_count < count {
+                return Err(CoreError::ValidationError(format!(
+                    "max_doc_count {} is below the current document count {}",
+                    max_doc_count, count
+                )));
+            }
+        }
+        if let Some(defaults) = &update.search_defaults {
+            self.set_search_defaults(collection_id, defaults.clone())
+                .await?;
+        }
+
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        let mut updated = current.clone();
+        update.apply(&mut updated);
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated.clone());
+        tracing::info!("Updated settings of collection {}", collection_id);
+        Ok(updated)
+    }
+
+    /// Handle of a collection, for calls without its ID.
+    pub fn collection(self: &Arc<Self>, collection_id: CollectionId) -> CollectionHandle {
+        CollectionHandle::new(Arc::clone(self), collection_id)
+    }
+
+    /// Handle of the collection with this alias or, failing that, this name.
+    pub async fn collection_named(self: &Arc<Self>, name: &str) -> CoreResult<CollectionHandle> {
+        let collection_id = match self.resolve_alias(name).await {
+            Ok(collection_id) => collection_id,
+            Err(_) => self
+                .collections
+                .read()
+                .await
+                .values()
+                .find(|collection| collection.name == name)
+                .map(|collection| collection.collection_id)
+                .ok_or_else(|| CoreError::not_found("Collection", name))?,
+        };
+        Ok(self.collection(collection_id))
+    }
+
+    // ========== Vector Operations ==========
+
+    /// Query vectors (k-NN search).
+    ///
+    /// Applies the default post-processing pipeline, if one is configured.
+    pub async fn query(
+        &self,
+        collection_id: CollectionId,
+        query_vector: Vec<f32>,
+        top_k: usize,
+    ) -> CoreResult<Vec<SearchResult>> {
+        let results = self
+            .search_index(collection_id, query_vector, top_k)
+            .await?;
+
+        match self.post_processing().await {
+            Some(pipeline) if !pipeline.is_empty() => {
+                self.apply_pipeline(collection_id, results, &pipeline).await
+            }
+            _ => Ok(results),
+        }
+    }
+
+    /// Query vectors with a per-call post-processing pipeline.
+    ///
+    /// The supplied pipeline replaces the default one for this call.
+    pub async fn query_with_pipeline(
+        &self,
+        collection_id: CollectionId,
+        query_vector: Vec<f32>,
+        top_k: usize,
+        pipeline: &PostProcessingPipeline,
+    ) -> CoreResult<Vec<SearchResult>> {
+        let results = self
+            .search_index(collection_id, query_vector, top_k)
+            .await?;
+        self.apply_pipeline(collection_id, results, pipeline).await
+    }
+
+    /// Run many queries (see `query`) on up to `options.concurrency` workers.
+    ///
+    /// Results are in query order; each query succeeds or fails on its own.
+    pub async fn batch_search(
+        self: &Arc<Self>,
+        collection_id: CollectionId,
+        queries: Vec<BatchQuery>,
+        options: &BatchSearchOptions,
+    ) -> CoreResult<Vec<CoreResult<Vec<SearchResult>>>> {
+        options.validate(queries.len())?;
+        self.get_collection(collection_id).await?;
+
+        let mut results: Vec<Option<CoreResult<Vec<SearchResult>>>> =
+            queries.iter().map(|_| None).collect();
+        let mut tasks = JoinSet::new();
+        let joined = |joined: Result<(usize, CoreResult<Vec<SearchResult>>), JoinError>| {
+            joined.map_err(|e| CoreError::internal(format!("batch search task failed: {}", e)))
+        };
+        for (i, query) in queries.into_iter().enumerate() {
+            if tasks.len() >= options.concurrency {
+                if let Some(result) = tasks.join_next().await {
+                    let (i, result) = joined(result)?;
+                    results[i] = Some(result);
+                }
+            }
+            let service = Arc::clone(self);
+            tasks.spawn(async move {
+                let result = service
+                    .query(collection_id, query.vector, query.top_k)
+                    .await;
+                (i, result)
+            });
+        }
+        while let Some(result) = tasks.join_next().await {
+            let (i, result) = joined(result)?;
+            results[i] = Some(result);
+        }
+        Ok(results
+            .into_iter()
+            .map(|result| result.expect("every query ran"))
+            .collect())
+    }
+
+    /// Range search: every document within `range` of the query vector, best
+    /// first, up to `range.limit` matches (see `RangeQuery`).
+    ///
+    /// The tenant's top_k limit also caps the matches. Scores are raw: the
+    /// default post-processing pipeline does not apply.
+    pub async fn range_search(
+        &self,
+        collection_id: CollectionId,
+        query_vector: Vec<f32>,
+        range: &RangeQuery,
+    ) -> CoreResult<RangeSearchResult> {
+        let metric = self.get_collection(collection_id).await?.metric;
+        range.validate(metric)?;
+        let cap = match self.collection_policy.read().await.limits.max_top_k {
+            Some(max_top_k) => (max_top_k as usize).min(MAX_TOP_K),
+            None => MAX_TOP_K,
+        };
+        let limit = range.limit.min(cap);
+
+        // Widen k until a neighbour falls outside the radius; one neighbour
+        // beyond k tells whether more lie within
+        let mut k = RANGE_INITIAL_K.min(limit);
+        loop {
+            let fetch_k = (k + 1).min(cap);
+            let mut results = self
+                .search_index(collection_id, query_vector.clone(), fetch_k)
+                .await?;
+            let exhausted = results.len() < fetch_k;
+            let within = results
+                .iter()
+                .take_while(|r| range.contains(r.score))
+                .count();
+            if within < results.len() || exhausted {
+                results.truncate(within.min(limit));
+                return Ok(RangeSearchResult {
+                    results,
+                    truncated: within > limit,
+                });
+            }
+            if k >= limit {
+                results.truncate(limit);
+                return Ok(RangeSearchResult {
+                    results,
+                    truncated: true,
+                });
+            }
+            k = (k * 2).min(limit);
+        }
+    }
+
+    /// Score search results in additional metrics, e.g. the dot product for a
+    /// cosine collection, for downstream score calibration.
+    ///
+    /// Returns, per result, the raw score of its stored vector against
+    /// `query_vector` in each of `metrics` (cosine similarity, dot product or
+    /// L2 distance), in order. None for documents deleted since the search.
+    pub async fn score_in_metrics(
+        &self,
+        collection_id: CollectionId,
+        query_vector: &[f32],
+        results: &[SearchResult],
+        metrics: &[DistanceMetric],
+    ) -> CoreResult<Vec<Option<Vec<f32>>>> {
+        let doc_ids: Vec<DocumentId> = results.iter().map(|r| r.doc_id).collect();
+        let docs = self.get_many(collection_id, &doc_ids).await?;
+        Ok(docs
+            .into_iter()
+            .map(|doc| {
+                let doc = doc.filter(|doc| doc.vector.len() == query_vector.len())?;
+                Some(
+                    metrics
+                        .iter()
+                        .map(|metric| metric.compute(query_vector, &doc.vector))
+                        .collect(),
+                )
+            })
+            .collect())
+    }
+
+    /// Attach each result's stored vector, for clients that rerank or cluster
+    /// the matches without fetching every document. Results deleted since
+    /// the search keep `vector` unset.
+    pub async fn attach_vectors(
+        &self,
+        collection_id: CollectionId,
+        results: &mut [SearchResult],
+    ) -> CoreResult<()> {
+        let doc_ids: Vec<DocumentId> = results.iter().map(|r| r.doc_id).collect();
+        let docs = self.get_many(collection_id, &doc_ids).await?;
+        for (result, doc) in results.iter_mut().zip(docs) {
+            result.vector = doc.map(|doc| doc.vector);
+        }
+        Ok(())
+    }
+
+    /// Build a query vector from stored documents of the collection and an
+    /// optional literal vector (see `QueryComposition`).
+    pub async fn compose_query_vector(
+        &self,
+        collection_id: CollectionId,
+        composition: &QueryComposition,
+    ) -> CoreResult<Vec<f32>> {
+        composition.validate()?;
+        let dimension = self.get_collection(collection_id).await?.dimension as usize;
+        let doc_ids: Vec<DocumentId> = composition.terms.iter().map(|t| t.doc_id).collect();
+        let vectors = self
+            .get_many(collection_id, &doc_ids)
+            .await?
+            .into_iter()
+            .zip(&doc_ids)
+            .map(|(doc, doc_id)| {
+                doc.map(|doc| doc.vector)
+                    .ok_or_else(|| CoreError::not_found("Document", doc_id.to_string()))
+            })
+            .collect::<CoreResult<Vec<_>>>()?;
+        composition.compose(&vectors, dimension)
+    }
+
+    /// Recommend documents like the positive examples and unlike the
+    /// negative ones (see `RecommendRequest`), best first. The examples
+    /// themselves are left out.
+    ///
+    /// Applies the default post-processing pipeline, if one is configured.
+    pub async fn recommend(
+        &self,
+        collection_id: CollectionId,
+        request: &RecommendRequest,
+    ) -> CoreResult<Vec<SearchResult>> {
+        request.validate()?;
+        let max_top_k = {
+            let policy = self.collection_policy.read().await;
+            policy.limits.check_top_k(request.top_k)?;
+            if let Some(filter) = &request.filter {
+                policy.limits.check_filter_clauses(filter.clauses())?;
+            }
+            policy
+                .limits
+                .max_top_k
+                .map_or(MAX_TOP_K, |max| max as usize)
+        };
+        let query_vector = self
+            .compose_query_vector(collection_id, &request.composition())
+            .await?;
+
+        // The examples rank near the query and filtered-out matches are
+        // dropped, so fetch enough candidates to still fill top_k
+        let examples = request.examples();
+        let mut fetch_k = request.top_k + examples.len();
+        if request.filter.is_some() {
+            fetch_k *= FILTER_OVERFETCH;
+        }
+        let fetch_k = fetch_k.min(max_top_k.min(MAX_TOP_K)).max(request.top_k);
+        let mut results = self
+            .search_index(collection_id, query_vector, fetch_k)
+            .await?;
+        results.retain(|r| {
+            !examples.contains(&r.doc_id)
+                && request
+                    .filter
+                    .as_ref()
+                    .map_or(true, |filter| filter.matches(r.metadata.as_ref()))
+        });
+        results.truncate(request.top_k);
+
+        match self.post_processing().await {
+            Some(pipeline) if !pipeline.is_empty() => {
+                self.apply_pipeline(collection_id, results, &pipeline).await
+            }
+            _ => Ok(results),
+        }
+    }
+
+    /// Run a post-processing pipeline using the collection's distance metric.
+    async fn apply_pipeline(
+        &self,
+        collection_id: CollectionId,
+        results: Vec<SearchResult>,
+        pipeline: &PostProcessingPipeline,
+    ) -> CoreResult<Vec<SearchResult>> {
+        let metric = self.get_collection(collection_id).await?.metric;
+        Ok(pipeline.apply(results, metric))
+    }
+
+    /// Raw k-NN search against the collection index (no post-processing).
+    pub(crate) async fn search_index(
+        &self,
+        collection_id: CollectionId,
+        query_vector: Vec<f32>,
+        top_k: usize,
+    ) -> CoreResult<Vec<SearchResult>> {
+        let start = Instant::now();
+
+        // FIX BUG #8: Validate top_k to prevent DoS via memory exhaustion
+        if top_k == 0 {
+            return Err(CoreError::ValidationError(
+                "top_k must be greater than 0".to_string(),
+            ));
+        }
+        if top_k > MAX_TOP_K {
+            return Err(CoreError::ValidationError(format!(
+                "top_k must be <= {} (got {})",
+                MAX_TOP_K, top_k
+            )));
+        }
+
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_top_k(top_k)?;
+
+        // Record access for tiering (Phase 10 Week 3)
+        if let Some(tiering_manager) = &self.tiering_manager {
+            // Ignore errors from access tracking (non-critical)
+            let _ = tiering_manager.record_access(collection_id).await;
+        }
+
+        if let Some(monitor) = self.monitors.drift_monitor(collection_id).await {
+            monitor.record(&query_vector);
+        }
+        let defaults = self.search_defaults(collection_id).await?;
+        let fetch_k = match &defaults.filter {
+            Some(_) => (top_k * FILTER_OVERFETCH).min(MAX_TOP_K),
+            None => top_k,
+        };
+        // Documents selected by an indexed filter are few enough to score
+        // exactly, which also finds matches the over-fetch would miss
+        let candidates = match &defaults.filter {
+            Some(filter) => match self.indexed_candidates(collection_id, filter).await {
+                Some(candidates) if candidates.len() <= MAX_EXACT_CANDIDATES => {
+                    let metric = self.get_collection(collection_id).await?.metric;
+                    Some((candidates, metric))
+                }
+                _ => None,
+            },
+            None => None,
+        };
+        let adaptive = self.monitors.adaptive_search(collection_id).await;
+        let ef_search = match &adaptive {
+            Some(adaptive) => Some(adaptive.ef_search()),
+            None => defaults.ef_search,
+        };
+
+        // Get index
+        let _gate = self.commit_gate.read().await;
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        // Perform search
+        let result = match (candidates, &defaults.filter) {
+            (Some((candidates, metric)), Some(filter)) => {
+                search_candidates(
+                    index.as_ref(),
+                    candidates,
+                    &query_vector,
+                    top_k,
+                    metric,
+                    filter,
+                )
+                .await
+            }
+            _ => index
+                .search(&query_vector, fetch_k, ef_search)
+                .await
+                .map(|mut results| {
+                    if let Some(filter) = &defaults.filter {
+                        results.retain(|r| filter.matches(r.metadata.as_ref()));
+                        results.truncate(top_k);
+                    }
+                    results
+                }),
+        };
+        if let Ok(results) = &result {
+            let size = index.count().await.unwrap_or_default();
+            self.record_reads(collection_id, results.len(), size);
+            self.usage.record_query(collection_id, Utc::now());
+            self.query_log
+                .record(collection_id, &query_vector, top_k, Utc::now());
+        }
+
+        // Record metrics
+        let duration = start.elapsed().as_secs_f64();
+        VECTOR_SEARCH_DURATION_SECONDS
+            .with_label_values(&["hot"]) // TODO: Get actual tier from TieringManager
+            .observe(duration);
+        if let (Some(adaptive), Ok(_)) = (&adaptive, &result) {
+            adaptive.record(start.elapsed());
+        }
+
+        result
+    }
+
+    /// Insert single vector.
+    pub async fn insert(
+        &self,
+        collection_id: CollectionId,
+        doc: VectorDocument,
+    ) -> CoreResult<DocumentId> {
+        let _lock = self.write_locks.lock(collection_id, doc.doc_id).await;
+        self.write_document(collection_id, doc, None).await
+    }
+
+    /// Write a document: a new one, or the replacement of `previous`, the
+    /// stored document with the same ID. A replacement is a single upsert
+    /// of the index and the WAL, published as one upsert. The caller holds
+    /// the document's write lock.
+    ///
+    /// The document's sparse and named vectors are stored with it and
+    /// replace any previous ones (none, or empty ones, remove them).
+    async fn write_document(
+        &self,
+        collection_id: CollectionId,
+        doc: VectorDocument,
+        previous: Option<VectorDocument>,
+    ) -> CoreResult<DocumentId> {
+        self.apply_write(collection_id, doc, previous, false).await
+    }
+
+    /// Write a document as `write_document` does, skipping storage when
+    /// `logged` says the caller already stored it.
+    async fn apply_write(
+        &self,
+        collection_id: CollectionId,
+        mut doc: VectorDocument,
+        previous: Option<VectorDocument>,
+        logged: bool,
+    ) -> CoreResult<DocumentId> {
+        let start = Instant::now();
+        self.check_writable(collection_id).await?;
+        if previous.is_some() {
+            self.check_legal_holds(collection_id, doc.doc_id).await?;
+        }
+
+        // Record access for tiering (Phase 10 Week 3)
+        if let Some(tiering_manager) = &self.tiering_manager {
+            // Ignore errors from access tracking (non-critical)
+            let _ = tiering_manager.record_access(collection_id).await;
+        }
+
+        // Validate vector dimension matches collection's expected dimension
+        {
+            let collections = self.collections.read().await;
+            let collection = collections
+                .get(&collection_id)
+                .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+            let expected_dim = collection.dimension as usize;
+            let actual_dim = doc.vector.len();
+
+            if actual_dim != expected_dim {
+                return Err(CoreError::ValidationError(format!(
+                    "Vector dimension mismatch: expected {}, got {}",
+                    expected_dim, actual_dim
+                )));
+            }
+        }
+        doc.sparse_vector = doc.sparse_vector.filter(|vector| !vector.is_empty());
+        if let Some(vector) = &doc.sparse_vector {
+            self.check_sparse_vector(collection_id, vector).await?;
+        }
+        doc.named_vectors = doc.named_vectors.filter(|vectors| !vectors.is_empty());
+        if let Some(vectors) = &doc.named_vectors {
+            self.check_named_vectors(collection_id, vectors).await?;
+        }
+
+        // Billed ingestion: vector components plus serialized metadata
+        let ingested_bytes = (doc.vector.len() * 4) as u64
+            + doc
+                .metadata
+                .as_ref()
+                .map_or(0, |metadata| metadata.to_string().len() as u64);
+
+        // Keep copies for standing queries, replication and change streams
+        // (persistence consumes the document)
+        let standing_queries = self.standing_queries_for(collection_id).await;
+        let inserted = (!standing_queries.is_empty()).then(|| doc.clone());
+        let replicated = self
+            .replication
+            .is_replicated(collection_id)
+            .then(|| doc.clone());
+        let changed = self.changes.is_followed(collection_id).then(|| doc.clone());
+        let indexed = self
+            .has_field_indexes(collection_id)
+            .await
+            .then(|| doc.metadata.clone());
+
+        // FIX BUG #1 & #6: Insert into index FIRST, then persist to WAL
+        // Hold BOTH locks simultaneously to prevent collection deletion race condition
+        //
+        // RACE CONDITION FIX: If we release the index lock before acquiring the backend lock,
+        // another thread could delete the collection in between, causing:
+        // - Document in index but not in WAL → data loss on restart
+        //
+        // By holding both locks, we ensure atomic insert across index + WAL
+        let doc_id = doc.doc_id;
+        let replaced = previous.is_some();
+        let sparse_vector = doc.sparse_vector.clone();
+        let named_vectors = doc.named_vectors.clone();
+        {
+            // Acquire BOTH locks before any mutations (prevents delete_collection race)
+            let indexes = self.indexes.read().await;
+            let backends = self.storage_backends.read().await;
+
+            // Get index reference
+            let index = indexes
+                .get(&collection_id)
+                .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+            // Insert into in-memory index FIRST
+            // If this fails, we return error WITHOUT persisting to WAL
+            match &previous {
+                Some(_) => index.upsert(doc.clone()).await?,
+                None => index.insert(doc.clone()).await?,
+            }
+
+            // Only persist to StorageBackend AFTER successful index insert
+            // This prevents WAL/index inconsistency on index failures (Bug #1).
+            // Persisting a replacement overwrites the stored document.
+            //
+            // BUG FIX #2 COMPLETE: If persistence fails, rollback index insert to maintain consistency
+            let persisted = if logged {
+                Ok(())
+            } else if let Some(storage_backend) = backends.get(&collection_id) {
+                // Use insert_with_auto_compact for automatic WAL management
+                storage_backend.insert_with_auto_compact(doc).await
+            } else {
+                // Fallback: Legacy persistence (Phase 5 compatibility)
+                match &self.vector_persistence {
+                    Some(persistence) => persistence.save_vector(collection_id, &doc).await,
+                    None => Ok(()),
+                }
+            };
+            if let Err(e) = persisted {
+                // Rollback: Restore the index since persistence failed
+                let rollback = match previous {
+                    Some(previous) => index.upsert(previous).await,
+                    None => index.delete(doc_id).await,
+                };
+                if let Err(rollback_err) = rollback {
+                    tracing::error!(
+                        "Failed to rollback index write after persistence failure for doc {}: {}. Index may be inconsistent.",
+                        doc_id, rollback_err
+                    );
+                }
+                return Err(e);
+            }
+
+            if let Some(sparse) = self.sparse_indexes.write().await.get_mut(&collection_id) {
+                match sparse_vector {
+                    // Checked above; only a concurrent settings change fails
+                    Some(vector) => {
+                        if let Err(e) = sparse.insert(doc_id, vector) {
+                            tracing::warn!(
+                                "Sparse vector of doc {} stored but not indexed: {}",
+                                doc_id,
+                                e
+                            );
+                        }
+                    }
+                    None => {
+                        sparse.remove(doc_id);
+                    }
+                }
+            }
+            if let Some(named) = self.named_vectors.write().await.get_mut(&collection_id) {
+                match named_vectors {
+                    Some(vectors) => {
+                        if let Err(e) = named.insert(doc_id, vectors) {
+                            tracing::warn!(
+                                "Named vectors of doc {} stored but not indexed: {}",
+                                doc_id,
+                                e
+                            );
+                        }
+                    }
+                    None => named.remove(doc_id),
+                }
+            }
+
+            // Both locks released here - collection cannot be deleted during insert
+        }
+
+        // Record metrics
+        let duration = start.elapsed().as_secs_f64();
+        VECTOR_INSERT_DURATION_SECONDS
+            .with_label_values(&[&collection_id.to_string()])
+            .observe(duration);
+
+        if !replaced {
+            COLLECTION_SIZE_VECTORS
+                .with_label_values(&[&collection_id.to_string()])
+                .inc();
+        }
+        self.usage
+            .record_ingest(collection_id, ingested_bytes, Utc::now());
+
+        if let Some(doc) = inserted {
+            for query in &standing_queries {
+                query.check(&doc);
+            }
+        }
+        if let Some(document) = replicated {
+            self.replication
+                .record(collection_id, ReplicationOp::Upsert { document });
+        }
+        if let Some(document) = changed {
+            self.changes
+                .record(collection_id, ReplicationOp::Upsert { document });
+        }
+        if let Some(metadata) = indexed {
+            self.field_indexes
+                .insert(collection_id, doc_id, metadata.as_ref(), replaced)
+                .await;
+        }
+
+        Ok(doc_id)
+    }
+
+    /// Insert a batch of documents, reporting duplicate IDs.
+    ///
+    /// IDs repeated within the batch are written once (first occurrence) and
+    /// IDs already stored keep the stored document; both are listed in the
+    /// report with the positions involved. Other failures are reported per
+    /// document and do not stop the batch.
+    pub async fn insert_batch(
+        &self,
+        collection_id: CollectionId,
+        docs: Vec<VectorDocument>,
+    ) -> CoreResult<BatchInsertReport> {
+        check_batch_size(docs.len())?;
+        self.check_writable(collection_id).await?;
+        let doc_ids: Vec<DocumentId> = docs.iter().map(|d| d.doc_id).collect();
+        let stored = self.get_many(collection_id, &doc_ids).await?;
+
+        let mut report = BatchInsertReport {
+            ids: doc_ids.clone(),
+            ..Default::default()
+        };
+        let repeats = repeated_ids(&doc_ids);
+        let mut positions: HashMap<DocumentId, Vec<usize>> = repeats.iter().cloned().collect();
+        for (index, doc_id) in doc_ids.iter().enumerate() {
+            if stored[index].is_some() {
+                positions.entry(*doc_id).or_insert_with(|| vec![index]);
+            }
+        }
+        let mut duplicates: Vec<DuplicateId> = positions
+            .into_iter()
+            .map(|(doc_id, positions)| {
+                let action = if stored[positions[0]].is_some() {
+                    DuplicateAction::KeptExisting
+                } else {
+                    DuplicateAction::SkippedRepeats
+                };
+                DuplicateId {
+                    doc_id,
+                    positions,
+                    action,
+                }
+            })
+            .collect();
+        duplicates.sort_by_key(|d| d.positions[0]);
+
+        for (index, doc) in docs.into_iter().enumerate() {
+            let doc_id = doc.doc_id;
+            let duplicate = duplicates.iter().find(|d| d.doc_id == doc_id);
+            let skip = match duplicate {
+                Some(d) if d.action == DuplicateAction::KeptExisting => true,
+                Some(d) => d.positions[0] != index,
+                None => false,
+            };
+            if skip {
+                report.skipped += 1;
+                continue;
+            }
+            match self.insert(collection_id, doc).await {
+                Ok(_) => report.inserted += 1,
+                Err(e) => report.record_error(index, doc_id, e),
+            }
+        }
+        report.duplicates = duplicates;
+
+        tracing::debug!(
+            "Batch insert into {}: {} inserted, {} skipped, {} failed",
+            collection_id,
+            report.inserted,
+            report.skipped,
+            report.failed
+        );
+        Ok(report)
+    }
+
+    /// Insert a document, replacing any stored document with the same ID.
+    ///
+    /// Returns true if the document was created, false if it replaced one.
+    /// The replacement is atomic: readers see the old or the new document,
+    /// and replication and change streams receive a single upsert. Sparse
+    /// and named vectors belong to the ID, so they are kept. Documents under
+    /// legal hold cannot be replaced.
+    pub async fn upsert(
+        &self,
+        collection_id: CollectionId,
+        doc: VectorDocument,
+    ) -> CoreResult<bool> {
+        let _lock = self.write_locks.lock(collection_id, doc.doc_id).await;
+        let previous = self.read_document(collection_id, doc.doc_id).await?;
+        let created = previous.is_none();
+        self.write_document(collection_id, doc, previous).await?;
+        Ok(created)
+    }
+
+    /// Patch a document's metadata (JSON Merge Patch, see `apply_patch`)
+    /// without touching its vector. Returns the updated document.
+    ///
+    /// Like `upsert`, the document is replaced atomically and published as
+    /// one upsert. Documents under legal hold cannot be updated.
+    pub async fn update_metadata(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+        patch: &Map<String, JsonValue>,
+    ) -> CoreResult<VectorDocument> {
+        let _lock = self.write_locks.lock(collection_id, doc_id).await;
+        let previous = self
+            .read_document(collection_id, doc_id)
+            .await?
+            .ok_or_else(|| CoreError::not_found("Document", doc_id.to_string()))?;
+
+        let mut doc = previous.clone();
+        doc.metadata = apply_patch(previous.metadata.clone(), patch)?;
+        self.write_document(collection_id, doc.clone(), Some(previous))
+            .await?;
+        Ok(doc)
+    }
+
+    /// Patch the metadata of several documents in order. Failures, including
+    /// missing documents, are reported per document and do not stop the
+    /// batch.
+    pub async fn update_metadata_batch(
+        &self,
+        collection_id: CollectionId,
+        patches: Vec<MetadataPatch>,
+    ) -> CoreResult<BatchInsertReport> {
+        check_batch_size(patches.len())?;
+        self.get_collection(collection_id).await?;
+        self.check_writable(collection_id).await?;
+
+        let mut report = BatchInsertReport {
+            ids: patches.iter().map(|p| p.doc_id).collect(),
+            ..Default::default()
+        };
+        for (index, patch) in patches.into_iter().enumerate() {
+            match self
+                .update_metadata(collection_id, patch.doc_id, &patch.patch)
+                .await
+            {
+                Ok(_) => report.updated += 1,
+                Err(e) => report.record_error(index, patch.doc_id, e),
+            }
+        }
+        Ok(report)
+    }
+
+    /// Upsert a batch of documents in order, counting created and replaced
+    /// documents. IDs repeated within the batch are reported; the last
+    /// occurrence is stored. Failures are reported per document and do not
+    /// stop the batch.
+    pub async fn upsert_batch(
+        &self,
+        collection_id: CollectionId,
+        docs: Vec<VectorDocument>,
+    ) -> CoreResult<BatchInsertReport> {
+        check_batch_size(docs.len())?;
+        self.get_collection(collection_id).await?;
+        self.check_writable(collection_id).await?;
+        self.upsert_all(collection_id, docs, HashMap::new()).await
+    }
+
+    /// Upsert a batch of documents whose IDs are derived from their content
+    /// (see `ContentIdSpec`), so re-ingesting a document replaces it.
+    ///
+    /// A document whose derived ID is already used by different content,
+    /// stored or earlier in the batch, is a collision: it is not written and
+    /// is reported as a failure. Fails as a whole if a document lacks one of
+    /// the fields.
+    pub async fn upsert_batch_by_content(
+        &self,
+        collection_id: CollectionId,
+        mut docs: Vec<VectorDocument>,
+        spec: &ContentIdSpec,
+    ) -> CoreResult<BatchInsertReport> {
+        check_batch_size(docs.len())?;
+        spec.validate()?;
+        self.check_writable(collection_id).await?;
+        let mut keys = Vec::with_capacity(docs.len());
+        for (index, doc) in docs.iter_mut().enumerate() {
+            let key = spec
+                .key(doc)
+                .map_err(|e| CoreError::ValidationError(format!("documents[{}]: {}", index, e)))?;
+            doc.doc_id = ContentIdSpec::doc_id(&key);
+            keys.push(key);
+        }
+
+        let doc_ids: Vec<DocumentId> = docs.iter().map(|d| d.doc_id).collect();
+        let stored = self.get_many(collection_id, &doc_ids).await?;
+        let mut first_keys: HashMap<DocumentId, &[u8]> = HashMap::new();
+        let mut collisions = HashMap::new();
+        for (index, doc_id) in doc_ids.iter().enumerate() {
+            let stored_key = stored[index].as_ref().map(|doc| spec.key(doc).ok());
+            let first_key = *first_keys.entry(*doc_id).or_insert(&keys[index]);
+            let with = match stored_key {
+                Some(stored_key) if stored_key.as_deref() != Some(&keys[index][..]) => {
+                    "stored document"
+                }
+                _ if first_key != &keys[index][..] => "earlier document in the batch",
+                _ => continue,
+            };
+            collisions.insert(
+                index,
+                format!("content ID {} collides with {}", doc_id, with),
+            );
+        }
+        if !collisions.is_empty() {
+            tracing::warn!(
+                "Batch upsert into {}: {} content ID collisions",
+                collection_id,
+                collisions.len()
+            );
+        }
+        self.upsert_all(collection_id, docs, collisions).await
+    }
+
+    /// Upsert documents in order, skipping and reporting `collisions`
+    /// (position to message).
+    async fn upsert_all(
+        &self,
+        collection_id: CollectionId,
+        docs: Vec<VectorDocument>,
+        collisions: HashMap<usize, String>,
+    ) -> CoreResult<BatchInsertReport> {
+        let doc_ids: Vec<DocumentId> = docs.iter().map(|d| d.doc_id).collect();
+
+        let mut report = BatchInsertReport {
+            duplicates: repeated_ids(&doc_ids)
+                .into_iter()
+                .map(|(doc_id, positions)| DuplicateId {
+                    doc_id,
+                    positions,
+                    action: DuplicateAction::LastWriteWins,
+                })
+                .collect(),
+            ids: doc_ids,
+            ..Default::default()
+        };
+        for (index, doc) in docs.into_iter().enumerate() {
+            let doc_id = doc.doc_id;
+            if let Some(collision) = collisions.get(&index) {
+                report.collisions += 1;
+                report.record_error(index, doc_id, CoreError::invalid_state(collision.clone()));
+                continue;
+            }
+            match self.upsert(collection_id, doc).await {
+                Ok(true) => report.inserted += 1,
+                Ok(false) => report.updated += 1,
+                Err(e) => report.record_error(index, doc_id, e),
+            }
+        }
+
+        tracing::debug!(
+            "Batch upsert into {}: {} created, {} updated, {} failed",
+            collection_id,
+            report.inserted,
+            report.updated,
+            report.failed
+        );
+        Ok(report)
+    }
+
+    /// Get vector by ID.
+    ///
+    /// With Get coalescing enabled, concurrent Gets of the collection are
+    /// answered by one batched Get (see `set_get_coalescing`).
+    pub async fn get(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> CoreResult<Option<VectorDocument>> {
+        if let Some(window) = self.get_coalescer.window() {
+            return self.get_coalesced(collection_id, doc_id, window).await;
+        }
+        self.get_one(collection_id, doc_id).await
+    }
+
+    async fn get_one(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> CoreResult<Option<VectorDocument>> {
+        // Record access for tiering (Phase 10 Week 3)
+        if let Some(tiering_manager) = &self.tiering_manager {
+            // Ignore errors from access tracking (non-critical)
+            let _ = tiering_manager.record_access(collection_id).await;
+        }
+
+        let _gate = self.commit_gate.read().await;
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        let doc = index.get(doc_id).await?;
+        if doc.is_some() {
+            let size = index.count().await.unwrap_or_default();
+            self.record_reads(collection_id, 1, size);
+        }
+        Ok(doc)
+    }
+
+    /// List all documents in a collection (unspecified order).
+    pub async fn list_documents(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<Vec<VectorDocument>> {
+        let _gate = self.commit_gate.read().await;
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        index.list().await
+    }
+
+    /// One page of a collection's documents, optionally only those matching
+    /// `filter`, in a deterministic order (see `ListOrder`). Walking the
+    /// pages visits every document present throughout exactly once, even
+    /// with concurrent writes. With a `partition`, only that part of the
+    /// collection is walked, so large exports can scroll all partitions in
+    /// parallel and resume each from its own cursor.
+    pub async fn scroll_documents(
+        &self,
+        collection_id: CollectionId,
+        order: ListOrder,
+        filter: Option<&MetadataFilter>,
+        partition: Option<Partition>,
+        cursor: Option<&str>,
+        limit: usize,
+    ) -> CoreResult<Page<VectorDocument>> {
+        if let Some(partition) = &partition {
+            partition.validate()?;
+        }
+        if let Some(filter) = filter {
+            filter.validate()?;
+            self.collection_policy
+                .read()
+                .await
+                .limits
+                .check_filter_clauses(filter.clauses())?;
+        }
+
+        let page = match filter {
+            None if order.is_id_order() => {
+                self.scroll_by_id(collection_id, partition, cursor, limit)
+                    .await?
+            }
+            _ => {
+                let docs = match filter {
+                    Some(filter) => self.filter_candidates(collection_id, filter).await?,
+                    None => self.list_documents(collection_id).await?,
+                };
+                let docs: Vec<VectorDocument> = docs
+                    .into_iter()
+                    .filter(|doc| filter.map_or(true, |f| f.matches(doc.metadata.as_ref())))
+                    .filter(|doc| partition.map_or(true, |p| p.contains(doc)))
+                    .collect();
+                order.paginate(docs, cursor, limit)?
+            }
+        };
+        let size = self.get_count(collection_id).await.unwrap_or_default();
+        self.record_reads(collection_id, page.items.len(), size);
+        Ok(page)
+    }
+
+    /// `scroll_documents` in ID order without a filter: the index is read
+    /// from the cursor on, so a page costs O(log n + limit) instead of a
+    /// pass over the whole collection.
+    async fn scroll_by_id(
+        &self,
+        collection_id: CollectionId,
+        partition: Option<Partition>,
+        cursor: Option<&str>,
+        limit: usize,
+    ) -> CoreResult<Page<VectorDocument>> {
+        check_page_limit(limit)?;
+        let order = ListOrder::default();
+        let mut after = match cursor {
+            Some(cursor) => {
+                let id = order.cursor_id(cursor)?;
+                Some(id.parse::<DocumentId>().map_err(|_| {
+                    CoreError::ValidationError(format!("invalid cursor '{}'", cursor))
+                })?)
+            }
+            None => None,
+        };
+
+        let _gate = self.commit_gate.read().await;
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        // One more than a page tells whether another page follows
+        let mut docs = Vec::new();
+        while docs.len() <= limit {
+            let batch = index.list_after(after, limit + 1).await?;
+            let exhausted = batch.len() <= limit;
+            after = batch.last().map(|doc| doc.doc_id).or(after);
+            docs.extend(
+                batch
+                    .into_iter()
+                    .filter(|doc| partition.map_or(true, |p| p.contains(doc))),
+            );
+            if exhausted {
+                break;
+            }
+        }
+        Ok(order.page(docs, limit))
+    }
+
+    /// Documents whose metadata matches `filter`, optionally sorted by a
+    /// metadata field (ID order otherwise), up to `limit`. No query vector
+    /// is involved.
+    pub async fn find_documents(
+        &self,
+        collection_id: CollectionId,
+        filter: &MetadataFilter,
+        sort: Option<&MetadataSort>,
+        limit: usize,
+    ) -> CoreResult<Vec<VectorDocument>> {
+        filter.validate()?;
+        if limit == 0 || limit > MAX_PAGE_SIZE {
+            return Err(CoreError::ValidationError(format!(
+                "limit must be between 1 and {} (got {})",
+                MAX_PAGE_SIZE, limit
+            )));
+        }
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_filter_clauses(filter.clauses())?;
+
+        let mut docs: Vec<VectorDocument> = self
+            .filter_candidates(collection_id, filter)
+            .await?
+            .into_iter()
+            .filter(|doc| filter.matches(doc.metadata.as_ref()))
+            .collect();
+        match sort {
+            Some(sort) => sort.sort(&mut docs),
+            None => ListOrder::default().sort(&mut docs),
+        }
+        docs.truncate(limit);
+        let size = self.get_count(collection_id).await.unwrap_or_default();
+        self.record_reads(collection_id, docs.len(), size);
+        Ok(docs)
+    }
+
+    /// Number of documents, optionally only those matching `filter`. Counts
+    /// the index, so writes are reflected as soon as they return (unlike the
+    /// collection's stored `vector_count`). Unless `exact`, a filtered count
+    /// of a large collection is extrapolated from `COUNT_SAMPLE_SIZE` evenly
+    /// spread documents.
+    pub async fn count_documents(
+        &self,
+        collection_id: CollectionId,
+        filter: Option<&MetadataFilter>,
+        exact: bool,
+    ) -> CoreResult<DocumentCount> {
+        let Some(filter) = filter else {
+            return Ok(DocumentCount {
+                count: self.get_count(collection_id).await?,
+                exact: true,
+            });
+        };
+        filter.validate()?;
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_filter_clauses(filter.clauses())?;
+
+        let docs = self.filter_candidates(collection_id, filter).await?;
+        let matching = |docs: &mut dyn Iterator<Item = &VectorDocument>| {
+            docs.filter(|doc| filter.matches(doc.metadata.as_ref()))
+                .count()
+        };
+        if exact || docs.len() <= COUNT_SAMPLE_SIZE {
+            return Ok(DocumentCount {
+                count: matching(&mut docs.iter()),
+                exact: true,
+            });
+        }
+        let step = docs.len() as f64 / COUNT_SAMPLE_SIZE as f64;
+        let mut sample = (0..COUNT_SAMPLE_SIZE).map(|i| &docs[(i as f64 * step) as usize]);
+        let sampled = matching(&mut sample);
+        Ok(DocumentCount {
+            count: (sampled as f64 * step).round() as usize,
+            exact: false,
+        })
+    }
+
+    /// Read a document straight from the index: no access tracking and no
+    /// commit gate (safe to call while committing a transaction). The
+    /// document carries its sparse and named vectors, so it can be written
+    /// back whole.
+    async fn read_document(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> CoreResult<Option<VectorDocument>> {
+        let doc = {
+            let indexes = self.indexes.read().await;
+            let index = indexes
+                .get(&collection_id)
+                .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+            index.get(doc_id).await?
+        };
+        Ok(match doc {
+            Some(doc) => Some(self.with_stored_vectors(collection_id, doc).await),
+            None => None,
+        })
+    }
+
+    /// Attach the vectors kept outside the dense index (the sparse vector
+    /// and named vectors) to a document read from it.
+    async fn with_stored_vectors(
+        &self,
+        collection_id: CollectionId,
+        mut doc: VectorDocument,
+    ) -> VectorDocument {
+        if let Some(index) = self.sparse_indexes.read().await.get(&collection_id) {
+            doc.sparse_vector = index.get(doc.doc_id).cloned();
+        }
+        if let Some(named) = self.named_vectors.read().await.get(&collection_id) {
+            let vectors = named.get(doc.doc_id);
+            doc.named_vectors = (!vectors.is_empty()).then_some(vectors);
+        }
+        doc
+    }
+
+    /// Get multiple vectors by ID in one call (batched Get).
+    ///
+    /// Results are returned in the same order as `doc_ids`; missing documents are `None`.
+    pub async fn get_many(
+        &self,
+        collection_id: CollectionId,
+        doc_ids: &[DocumentId],
+    ) -> CoreResult<Vec<Option<VectorDocument>>> {
+        // Record access for tiering (Phase 10 Week 3)
+        if let Some(tiering_manager) = &self.tiering_manager {
+            // Ignore errors from access tracking (non-critical)
+            let _ = tiering_manager.record_access(collection_id).await;
+        }
+
+        let _gate = self.commit_gate.read().await;
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        let mut docs = Vec::with_capacity(doc_ids.len());
+        for doc_id in doc_ids {
+            docs.push(index.get(*doc_id).await?);
+        }
+        let found = docs.iter().flatten().count();
+        if found > 0 {
+            let size = index.count().await.unwrap_or_default();
+            self.record_reads(collection_id, found, size);
+        }
+        Ok(docs)
+    }
+
+    /// Get answered by the collection's current batch of Gets.
+    async fn get_coalesced(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+        window: std::time::Duration,
+    ) -> CoreResult<Option<VectorDocument>> {
+        match self.get_coalescer.join(collection_id, doc_id) {
+            Ticket::Leader(mut lease) => {
+                tokio::time::sleep(window).await;
+                let batch = lease.take();
+                // On error the batch is dropped and the followers retry on their own
+                let docs = self.get_many(collection_id, &batch.doc_ids).await?;
+                Ok(lease.complete(batch, docs))
+            }
+            Ticket::Follower(receiver) => match receiver.await {
+                Ok(doc) => Ok(doc),
+                Err(_) => self.get_one(collection_id, doc_id).await,
+            },
+        }
+    }
+
+    /// Search chunk vectors and return parent-level results.
+    ///
+    /// Chunk hits are grouped by the parent ID stored in their metadata, then the
+    /// parents' metadata is fetched with one batched Get from `parent_collection_id`
+    /// (defaults to the chunk collection itself).
+    pub async fn search_parents(
+        &self,
+        collection_id: CollectionId,
+        query_vector: Vec<f32>,
+        options: &ParentSearchOptions,
+        parent_collection_id: Option<CollectionId>,
+    ) -> CoreResult<Vec<ParentResult>> {
+        if options.top_parents == 0 {
+            return Err(CoreError::ValidationError(
+                "top_parents must be greater than 0".to_string(),
+            ));
+        }
+
+        let metric = self.get_collection(collection_id).await?.metric;
+        let chunk_top_k = options.chunk_top_k.max(options.top_parents);
+        let chunks = self.query(collection_id, query_vector, chunk_top_k).await?;
+        let mut parents = group_by_parent(chunks, options, metric);
+
+        // Batched Get for parents whose IDs are document IDs
+        let parent_collection_id = parent_collection_id.unwrap_or(collection_id);
+        let lookups = parent_document_ids(&parents);
+        let doc_ids: Vec<DocumentId> = lookups.iter().flatten().copied().collect();
+        if !doc_ids.is_empty() {
+            let mut docs = self
+                .get_many(parent_collection_id, &doc_ids)
+                .await?
+                .into_iter();
+            for (parent, lookup) in parents.iter_mut().zip(&lookups) {
+                if lookup.is_some() {
+                    parent.metadata = docs.next().flatten().and_then(|d| d.metadata);
+                }
+            }
+        }
+
+        Ok(parents)
+    }
+
+    /// Default search parameters of a collection (empty if none are set).
+    pub async fn search_defaults(&self, collection_id: CollectionId) -> CoreResult<SearchDefaults> {
+        let collection = self.get_collection(collection_id).await?;
+        match &collection.search_defaults {
+            Some(defaults) => decode_setting("search defaults", defaults),
+            None => Ok(SearchDefaults::default()),
+        }
+    }
+
+    /// Replace a collection's default search parameters (empty defaults clear
+    /// them). The defaults are stored with the collection.
+    pub async fn set_search_defaults(
+        &self,
+        collection_id: CollectionId,
+        defaults: SearchDefaults,
+    ) -> CoreResult<SearchDefaults> {
+        defaults.validate()?;
+        {
+            let policy = self.collection_policy.read().await;
+            if let Some(filter) = &defaults.filter {
+                policy.limits.check_filter_clauses(filter.clauses())?;
+            }
+            if let Some(top_k) = defaults.top_k {
+                policy.limits.check_top_k(top_k)?;
+            }
+        }
+
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        let mut updated = current.clone();
+        updated.search_defaults = if defaults.is_empty() {
+            None
+        } else {
+            Some(encode_setting("search defaults", &defaults)?)
+        };
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated);
+        Ok(defaults)
+    }
+
+    /// Delete vector by ID.
+    pub async fn delete(&self, collection_id: CollectionId, doc_id: DocumentId) -> CoreResult<()> {
+        let _lock = self.write_locks.lock(collection_id, doc_id).await;
+        self.delete_document(collection_id, doc_id).await
+    }
+
+    /// Delete a document. The caller holds the document's write lock.
+    async fn delete_document(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> CoreResult<()> {
+        self.apply_delete(collection_id, doc_id, false).await
+    }
+
+    /// Delete a document as `delete_document` does, skipping storage when
+    /// `logged` says the caller already deleted it there.
+    async fn apply_delete(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+        logged: bool,
+    ) -> CoreResult<()> {
+        self.check_writable(collection_id).await?;
+        self.check_legal_holds(collection_id, doc_id).await?;
+
+        // Record access for tiering (Phase 10 Week 3)
+        if let Some(tiering_manager) = &self.tiering_manager {
+            // Ignore errors from access tracking (non-critical)
+            let _ = tiering_manager.record_access(collection_id).await;
+        }
+
+        // FIX BUG #6: Delete from WAL first, then index
+        // Hold BOTH locks simultaneously to prevent collection deletion race condition
+        //
+        // RACE CONDITION FIX: If we release the backend lock before acquiring the index lock,
+        // another thread could delete the collection in between, causing:
+        // - Document deleted from WAL but not from index → stale data in index
+        //
+        // By holding both locks, we ensure atomic delete across WAL + index
+        {
+            // Acquire BOTH locks before any mutations (prevents delete_collection race)
+            let backends = self.storage_backends.read().await;
+            let indexes = self.indexes.read().await;
+
+            // Delete from WAL-backed storage FIRST (durability first)
+            if logged {
+                // Already deleted from storage by the caller
+            } else if let Some(storage_backend) = backends.get(&collection_id) {
+                storage_backend.delete(&doc_id).await?;
+            } else {
+                // Fallback: Legacy persistence (Phase 5 compatibility)
+                if let Some(persistence) = &self.vector_persistence {
+                    persistence.delete_vector(collection_id, doc_id).await?;
+                }
+            }
+
+            // Delete from index AFTER successful WAL delete
+            let index = indexes
+                .get(&collection_id)
+                .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+            index.delete(doc_id).await?;
+
+            // Both locks released here - collection cannot be deleted during delete operation
+        }
+        if let Some(index) = self.sparse_indexes.write().await.get_mut(&collection_id) {
+            index.remove(doc_id);
+        }
+        self.field_indexes.remove(collection_id, doc_id).await;
+        if let Some(spaces) = self.named_vectors.write().await.get_mut(&collection_id) {
+            spaces.remove(doc_id);
+        }
+        self.replication
+            .record(collection_id, ReplicationOp::Delete { doc_id });
+        self.changes
+            .record(collection_id, ReplicationOp::Delete { doc_id });
+
+        Ok(())
+    }
+
+    /// Delete up to `MAX_DELETE_BATCH_SIZE` documents by ID.
+    ///
+    /// IDs with no stored document, documents under legal hold and documents
+    /// failing to delete are reported instead of failing the call.
+    pub async fn delete_many(
+        &self,
+        collection_id: CollectionId,
+        doc_ids: &[DocumentId],
+    ) -> CoreResult<DeleteReport> {
+        check_delete_batch_size(doc_ids.len())?;
+        self.check_writable(collection_id).await?;
+        let mut unique = HashSet::new();
+        let doc_ids: Vec<DocumentId> = doc_ids
+            .iter()
+            .copied()
+            .filter(|id| unique.insert(*id))
+            .collect();
+
+        let stored = self.get_many(collection_id, &doc_ids).await?;
+        let mut report = DeleteReport::default();
+        let mut present = Vec::with_capacity(doc_ids.len());
+        for (doc_id, doc) in doc_ids.into_iter().zip(stored) {
+            match doc {
+                Some(_) => present.push(doc_id),
+                None => report.missing.push(doc_id),
+            }
+        }
+        self.delete_each(collection_id, present, &mut report)
+            .await?;
+        Ok(report)
+    }
+
+    /// Delete every document whose metadata matches `filter`.
+    ///
+    /// Documents under legal hold are kept and reported.
+    pub async fn delete_by_filter(
+        &self,
+        collection_id: CollectionId,
+        filter: &MetadataFilter,
+    ) -> CoreResult<DeleteReport> {
+        filter.validate()?;
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_filter_clauses(filter.clauses())?;
+        self.check_writable(collection_id).await?;
+
+        let doc_ids: Vec<DocumentId> = self
+            .filter_candidates(collection_id, filter)
+            .await?
+            .into_iter()
+            .filter(|doc| filter.matches(doc.metadata.as_ref()))
+            .map(|doc| doc.doc_id)
+            .collect();
+        let mut report = DeleteReport::default();
+        self.delete_each(collection_id, doc_ids, &mut report)
+            .await?;
+        tracing::info!(
+            "Deleted {} documents from {} by filter ({} held)",
+            report.deleted,
+            collection_id,
+            report.held.len()
+        );
+        Ok(report)
+    }
+
+    async fn delete_each(
+        &self,
+        collection_id: CollectionId,
+        doc_ids: Vec<DocumentId>,
+        report: &mut DeleteReport,
+    ) -> CoreResult<()> {
+        for doc_id in doc_ids {
+            match self.delete(collection_id, doc_id).await {
+                Ok(()) => report.deleted += 1,
+                Err(CoreError::UnderLegalHold { .. }) => report.held.push(doc_id),
+                // Deleted concurrently
+                Err(CoreError::NotFound { .. }) => report.missing.push(doc_id),
+                Err(e) => report.failed.push(DeleteFailure {
+                    id: doc_id.to_string(),
+                    message: e.to_string(),
+                }),
+            }
+        }
+        Ok(())
+    }
+
+    /// Load collection into memory (called on startup or creation).
+    /// Creates appropriate index based on collection config.
+    /// If vector persistence is enabled, loads all vectors from SQLite.
+    pub async fn load_collection(&self, collection: &CollectionDescriptor) -> CoreResult<()> {
+        // Create appropriate index based on collection config
+        let index: Box<dyn VectorIndex> = match IndexKind::for_collection(collection) {
+            // BruteForce for flat and small collections
+            IndexKind::BruteForce => Box::new(BruteForceIndex::new(
+                collection.dimension as usize,
+                collection.metric,
+            )),
+            // InstantDistance for HNSW and large collections
+            IndexKind::Hnsw => {
+                let config = InstantDistanceConfig {
+                    m: collection.hnsw_m as usize,
+                    ef_construction: collection.hnsw_ef_construction as usize,
+                    ..InstantDistanceConfig::balanced(
+                        collection.dimension as usize,
+                        collection.metric,
+                    )
+                };
+                Box::new(InstantDistanceIndex::new(config)?)
+            }
+        };
+
+        // The sparse index is rebuilt from the sparse vectors stored with documents
+        let mut sparse_index = match &collection.sparse_index {
+            Some(config) => match decode_setting::<SparseIndexConfig>("sparse index", config) {
+                Ok(config) => Some(SparseIndex::new(config)),
+                Err(e) => {
+                    tracing::warn!(
+                        "Skipping sparse index of collection {}: {}",
+                        collection.collection_id,
+                        e
+                    );
+                    None
+                }
+            },
+            None => None,
+        };
+        let mut named_vectors = match &collection.named_vectors {
+            Some(configs) => {
+                match decode_setting("named vectors", configs).and_then(NamedVectors::new) {
+                    Ok(named) => Some(named),
+                    Err(e) => {
+                        tracing::warn!(
+                            "Skipping named vectors of collection {}: {}",
+                            collection.collection_id,
+                            e
+                        );
+                        None
+                    }
+                }
+            }
+            None => None,
+        };
+        let text_analysis = match &collection.text_analysis {
+            Some(analysis) => match decode_setting::<TextAnalysis>("text analysis", analysis) {
+                Ok(analysis) => Some(analysis),
+                Err(e) => {
+                    tracing::warn!(
+                        "Skipping text analysis of collection {}: {}",
+                        collection.collection_id,
+                        e
+                    );
+                    None
+                }
+            },
+            None => None,
+        };
+
+        // Phase 6 Week 5 Day 3: Create StorageBackend FIRST to enable WAL recovery
+        let storage_config = self.create_storage_backend_for_collection(collection)?;
+        let storage_backend = Arc::new(StorageBackend::new(storage_config).await?);
+
+        // Load vectors from StorageBackend (recovered from WAL)
+        let recovered_vectors = storage_backend.all_vectors();
+        if !recovered_vectors.is_empty() {
+            tracing::info!(
+                "Loading {} vector(s) from StorageBackend for collection {}",
+                recovered_vectors.len(),
+                collection.collection_id
+            );
+
+            // FIX BUG #12: Validate dimension before inserting into index
+            // Corrupted WAL data could have wrong dimension, causing index corruption
+            let expected_dim = collection.dimension as usize;
+            let mut skipped_count = 0;
+
+            for doc in recovered_vectors {
+                // Validate dimension matches collection's expected dimension
+                if doc.vector.len() != expected_dim {
+                    tracing::error!(
+                        "Skipping corrupted vector {} from WAL: expected dimension {}, got {}",
+                        doc.doc_id,
+                        expected_dim,
+                        doc.vector.len()
+                    );
+                    skipped_count += 1;
+                    continue; // Skip corrupted vector, don't insert into index
+                }
+
+                if let (Some(sparse), Some(vector)) = (&mut sparse_index, &doc.sparse_vector) {
+                    if let Err(e) = sparse.insert(doc.doc_id, vector.clone()) {
+                        tracing::warn!("Skipping sparse vector of doc {}: {}", doc.doc_id, e);
+                    }
+                }
+                if let (Some(named), Some(vectors)) = (&mut named_vectors, &doc.named_vectors) {
+                    if let Err(e) = named.insert(doc.doc_id, vectors.clone()) {
+                        tracing::warn!("Skipping named vectors of doc {}: {}", doc.doc_id, e);
+                    }
+                }
+
+                // Insert validated vector into the VectorIndex
+                index.insert(doc).await?;
+            }
+
+            if skipped_count > 0 {
+                tracing::warn!(
+                    "Skipped {} corrupted vector(s) during WAL recovery for collection {}",
+                    skipped_count,
+                    collection.collection_id
+                );
+            }
+        } else {
+            // Fallback: Load vectors from legacy SQLite persistence (Phase 5 compatibility)
+            if let Some(persistence) = &self.vector_persistence {
+                let vectors = persistence
+                    .load_all_vectors(collection.collection_id)
+                    .await?;
+                if !vectors.is_empty() {
+                    tracing::info!(
+                        "Loading {} vector(s) from SQLite for collection {}",
+                        vectors.len(),
+                        collection.collection_id
+                    );
+
+                    // FIX BUG #12: Validate dimension for legacy SQLite vectors too
+                    let expected_dim = collection.dimension as usize;
+                    let mut skipped_count = 0;
+
+                    for doc in vectors {
+                        // Validate dimension
+                        if doc.vector.len() != expected_dim {
+                            tracing::error!(
+                                "Skipping corrupted vector {} from SQLite: expected dimension {}, got {}",
+                                doc.doc_id,
+                                expected_dim,
+                                doc.vector.len()
+                            );
+                            skipped_count += 1;
+                            continue;
+                        }
+
+                        // Insert validated vectors into the index AND StorageBackend
+                        index.insert(doc.clone()).await?;
+                        storage_backend.insert(doc).await?;
+                    }
+
+                    if skipped_count > 0 {
+                        tracing::warn!(
+                            "Skipped {} corrupted vector(s) from SQLite for collection {}",
+                            skipped_count,
+                            collection.collection_id
+                        );
+                    }
+                }
+            }
+        }
+
+        // Store in collections cache (BUG FIX #7: Required for insert() validation)
+        {
+            let mut collections = self.collections.write().await;
+            collections.insert(collection.collection_id, collection.clone());
+        }
+
+        // Store in indexes map
+        let mut indexes = self.indexes.write().await;
+        indexes.insert(collection.collection_id, index);
+
+        // Store in storage_backends map
+        {
+            let mut backends = self.storage_backends.write().await;
+            backends.insert(collection.collection_id, storage_backend);
+        }
+
+        if let Some(sparse_index) = sparse_index {
+            self.sparse_indexes
+                .write()
+                .await
+                .insert(collection.collection_id, sparse_index);
+        }
+        if let Some(named) = named_vectors {
+            self.named_vectors
+                .write()
+                .await
+                .insert(collection.collection_id, named);
+        }
+        if let Some(analysis) = text_analysis {
+            self.text_analysis
+                .write()
+                .await
+                .insert(collection.collection_id, analysis);
+        }
+
+        Ok(())
+    }
+
+    /// Unload collection from memory (called on deletion).
+    pub async fn unload_collection(&self, collection_id: CollectionId) -> CoreResult<()> {
+        // Remove from collections cache (BUG FIX #7: Keep cache consistent)
+        {
+            let mut collections = self.collections.write().await;
+            collections.remove(&collection_id);
+        }
+
+        // Remove from indexes
+        let mut indexes = self.indexes.write().await;
+        indexes.remove(&collection_id);
+
+        // Remove from storage backends
+        {
+            let mut backends = self.storage_backends.write().await;
+            backends.remove(&collection_id);
+        }
+
+        Ok(())
+    }
+
+    /// Get collection count (number of documents).
+    pub async fn get_count(&self, collection_id: CollectionId) -> CoreResult<usize> {
+        let indexes = self.indexes.read().await;
+        let index = indexes
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        index.count().await
+    }
+
+    // ========================================================================
+    // Admin Operations (Phase 7 Week 4)
+    // ========================================================================
+
+    /// Get storage metrics for a specific collection or overall
+    pub async fn get_storage_metrics(&self) -> CoreResult<StorageMetrics> {
+        // Aggregate metrics across all storage backends
+        let backends = self.storage_backends.read().await;
+
+        if backends.is_empty() {
+            // Return default metrics if no storage backends
+            return Ok(StorageMetrics {
+                inserts: 0,
+                queries: 0,
+                deletes: 0,
+                s3_uploads: 0,
+                s3_downloads: 0,
+                cache_hits: 0,
+                cache_misses: 0,
+                wal_size_bytes: 0,
+                last_snapshot_at: None,
+                compactions: 0,
+                s3_retries: 0,
+                s3_permanent_failures: 0,
+                dlq_size: 0,
+                circuit_breaker_state: 0, // Closed = 0
+                circuit_breaker_error_rate: 0.0,
+            });
+        }
+
+        // Aggregate from all backends
+        let mut total_metrics = StorageMetrics {
+            inserts: 0,
+            queries: 0,
+            deletes: 0,
+            s3_uploads: 0,
+            s3_downloads: 0,
+            cache_hits: 0,
+            cache_misses: 0,
+            wal_size_bytes: 0,
+            last_snapshot_at: None,
+            compactions: 0,
+            s3_retries: 0,
+            s3_permanent_failures: 0,
+            dlq_size: 0,
+            circuit_breaker_state: 0, // Closed = 0
+            circuit_breaker_error_rate: 0.0,
+        };
+
+        for backend in backends.values() {
+            let metrics = backend.metrics();
+
+            // FIX BUG #11: Use saturating_add to prevent integer overflow
+            total_metrics.inserts = total_metrics.inserts.saturating_add(metrics.inserts);
+            total_metrics.queries = total_metrics.queries.saturating_add(metrics.queries);
+            total_metrics.deletes = total_metrics.deletes.saturating_add(metrics.deletes);
+            total_metrics.s3_uploads = total_metrics.s3_uploads.saturating_add(metrics.s3_uploads);
+            total_metrics.s3_downloads = total_metrics
+                .s3_downloads
+                .saturating_add(metrics.s3_downloads);
+            total_metrics.cache_hits = total_metrics.cache_hits.saturating_add(metrics.cache_hits);
+            total_metrics.cache_misses = total_metrics
+                .cache_misses
+                .saturating_add(metrics.cache_misses);
+            total_metrics.wal_size_bytes = total_metrics
+                .wal_size_bytes
+                .saturating_add(metrics.wal_size_bytes);
+            total_metrics.compactions = total_metrics
+                .compactions
+                .saturating_add(metrics.compactions);
+            total_metrics.s3_retries = total_metrics.s3_retries.saturating_add(metrics.s3_retries);
+            total_metrics.s3_permanent_failures = total_metrics
+                .s3_permanent_failures
+                .saturating_add(metrics.s3_permanent_failures);
+            total_metrics.dlq_size = total_metrics.dlq_size.saturating_add(metrics.dlq_size);
+
+            // Use max error rate
+            total_metrics.circuit_breaker_error_rate = total_metrics
+                .circuit_breaker_error_rate
+                .max(metrics.circuit_breaker_error_rate);
+
+            // Use most recent snapshot time
+            if let Some(snapshot_at) = metrics.last_snapshot_at {
+                total_metrics.last_snapshot_at = Some(
+                    total_metrics
+                        .last_snapshot_at
+                        .map(|existing| existing.max(snapshot_at))
+                        .unwrap_or(snapshot_at),
+                );
+            }
+
+            // Use worst circuit breaker state (0=Closed, 1=HalfOpen, 2=Open)
+            total_metrics.circuit_breaker_state = total_metrics
+                .circuit_breaker_state
+                .max(metrics.circuit_breaker_state);
+        }
+
+        Ok(total_metrics)
+    }
+
+    /// Get cache statistics
+    pub async fn get_cache_stats(&self) -> CoreResult<CacheStats> {
+        // Aggregate cache stats from all storage backends
+        let backends = self.storage_backends.read().await;
+
+        if backends.is_empty() {
+            return Ok(CacheStats {
+                size: 0,
+                capacity: 0,
+                hit_rate: 0.0,
+                hits: 0,
+                misses: 0,
+            });
+        }
+
+        let mut total_stats = CacheStats {
+            size: 0,
+            capacity: 0,
+            hit_rate: 0.0,
+            hits: 0,
+            misses: 0,
+        };
+
+        for backend in backends.values() {
+            if let Some(stats) = backend.get_cache_stats() {
+                total_stats.size += stats.size;
+                total_stats.capacity += stats.capacity;
+                total_stats.hits += stats.hits;
+                total_stats.misses += stats.misses;
+            }
+        }
+
+        // Recalculate hit rate
+        let total_requests = total_stats.hits + total_stats.misses;
+        total_stats.hit_rate = if total_requests > 0 {
+            total_stats.hits as f64 / total_requests as f64
+        } else {
+            0.0
+        };
+
+        Ok(total_stats)
+    }
+
+    /// Get server uptime in seconds
+    pub fn uptime_seconds(&self) -> u64 {
+        self.start_time.elapsed().as_secs()
+    }
+
+    /// Get service-level metrics
+    ///
+    /// Returns aggregated metrics across all collections including:
+    /// - Total collections count
+    /// - Total vectors across all collections
+    /// - Server uptime
+    ///
+    /// Returns `None` if the service was created without persistence (testing mode).
+    pub fn metrics(&self) -> Option<ServiceMetrics> {
+        if self.repository.is_none() {
+            return None;
+        }
+
+        Some(ServiceMetrics {
+            total_collections: 0, // Will be populated by async call
+            total_vectors: 0,
+            total_searches: 0,
+            total_inserts: 0,
+            uptime_seconds: self.uptime_seconds(),
+        })
+    }
+
+    /// Retry all DLQ entries for a collection
+    ///
+    /// Note: Currently clears the DLQ. In the future, this could retry each entry.
+    pub async fn retry_dlq_entries(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<DLQRetryResult> {
+        let backends = self.storage_backends.read().await;
+        let backend = backends
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        // Get DLQ entries before clearing
+        let entries = backend.get_dead_letter_queue();
+        let total = entries.len();
+
+        // Clear DLQ (in the future, could retry each entry)
+        backend.clear_dead_letter_queue();
+
+        Ok(DLQRetryResult {
+            total,
+            succeeded: 0,  // Not actually retrying yet
+            failed: total, // All remain in "manual review needed" state
+        })
+    }
+
+    /// Reset circuit breaker (emergency recovery)
+    pub async fn reset_circuit_breaker(&self) -> CoreResult<CircuitBreakerState> {
+        // Reset circuit breaker for all storage backends
+        let backends = self.storage_backends.read().await;
+
+        // Get previous state from metrics before resetting
+        let mut previous_state_u8 = 0u8; // Closed
+
+        for backend in backends.values() {
+            let metrics_before = backend.metrics();
+            previous_state_u8 = previous_state_u8.max(metrics_before.circuit_breaker_state);
+
+            // Reset (not async)
+            backend.reset_circuit_breaker();
+        }
+
+        // Convert u8 back to CircuitBreakerState
+        let previous_state = match previous_state_u8 {
+            0 => CircuitBreakerState::Closed,
+            1 => CircuitBreakerState::HalfOpen,
+            _ => CircuitBreakerState::Open,
+        };
+
+        Ok(previous_state)
+    }
+
+    // ========================================================================
+    // Lifecycle Management (Production Critical)
+    // ========================================================================
+
+    /// Gracefully shutdown the collection service.
+    ///
+    /// This method ensures:
+    /// 1. All storage backends are shutdown cleanly (WAL flush, task abort)
+    /// 2. Background tasks are stopped
+    /// 3. Resources are released properly
+    ///
+    /// CRITICAL: Call this during server shutdown (SIGTERM handler) to prevent:
+    /// - Data loss (unflushed WAL buffers)
+    /// - Resource leaks (background tasks, file descriptors)
+    /// - Corruption (incomplete writes)
+    ///
+    /// # Example
+    /// ```rust
+    /// // In server shutdown handler
+    /// collection_service.shutdown().await?;
+    /// ```
+    ///
+    /// # Timeout
+    /// This method has an internal timeout of 30 seconds. If shutdown takes longer,
+    /// it will log warnings but continue to ensure the server can stop.
+    pub async fn shutdown(&self) -> CoreResult<()> {
+        tracing::info!("CollectionService shutdown initiated...");
+
+        let shutdown_start = std::time::Instant::now();
+
+        // Step 1: Shutdown all storage backends
+        // This is CRITICAL - ensures WAL flush and task cleanup
+        {
+            let backends = self.storage_backends.read().await;
+            let backend_count = backends.len();
+
+            tracing::info!("Shutting down {} storage backend(s)...", backend_count);
+
+            let mut successful_shutdowns = 0;
+            let mut failed_shutdowns = 0;
+
+            for (collection_id, backend) in backends.iter() {
+                tracing::debug!("Shutting down backend for collection {}", collection_id);
+
+                match backend.shutdown().await {
+                    Ok(()) => {
+                        successful_shutdowns += 1;
+                        tracing::debug!(
+                            "Backend shutdown successful for collection {}",
+                            collection_id
+                        );
+                    }
+                    Err(e) => {
+                        failed_shutdowns += 1;
+                        tracing::warn!(
+                            "Failed to shutdown backend for collection {}: {}",
+                            collection_id,
+                            e
+                        );
+                        // Continue shutting down other backends even if one fails
+                    }
+                }
+            }
+
+            tracing::info!(
+                "Storage backend shutdown complete: {}/{} successful",
+                successful_shutdowns,
+                backend_count
+            );
+
+            if failed_shutdowns > 0 {
+                tracing::warn!("{} backend(s) failed to shutdown cleanly", failed_shutdowns);
+            }
+        }
+
+        // Step 2: Note on in-memory indexes
+        // VectorIndex implementations (BruteForceIndex, InstantDistanceIndex) are
+        // Drop-based and don't require explicit shutdown. They will clean up when
+        // the Arc refcount reaches 0.
+
+        // Step 3: Note on repository
+        // SQLite connections are managed by sqlx pool and will close automatically.
+        // No explicit shutdown needed.
+
+        let shutdown_duration = shutdown_start.elapsed();
+        tracing::info!(
+            "CollectionService shutdown complete in {:.2}s",
+            shutdown_duration.as_secs_f64()
+        );
+
+        // Warn if shutdown took > 10 seconds (may indicate hanging tasks)
+        if shutdown_duration.as_secs() > 10 {
+            tracing::warn!(
+                "Shutdown took {:.1}s (>10s threshold). Check for slow/hanging tasks.",
+                shutdown_duration.as_secs_f64()
+            );
+        }
+
+        Ok(())
+    }
+
+    /// Check if the service is ready to serve requests.
+    ///
+    /// Returns `true` if:
+    /// - All required dependencies are initialized
+    /// - At least one collection is loaded (if applicable)
+    /// - Storage backends are healthy
+    ///
+    /// This is used for Kubernetes readiness probes.
+    pub async fn is_ready(&self) -> bool {
+        // Check 1: At least have the basic structures initialized
+        if self.collections.read().await.is_empty() && self.repository.is_some() {
+            // If we have a repository but no collections, we haven't loaded yet
+            return false;
+        }
+
+        // Check 2: Verify storage backends are healthy (not in permanent failure state)
+        let backends = self.storage_backends.read().await;
+        for backend in backends.values() {
+            // Check if circuit breaker is permanently open (indicates S3 down)
+            if let Some(state) = backend.circuit_breaker_state() {
+                if matches!(state, akidb_storage::CircuitBreakerState::Open) {
+                    tracing::warn!("Storage backend circuit breaker is OPEN (not ready)");
+                    return false;
+                }
+            }
+        }
+
+        // All checks passed
+        true
+    }
+
+    /// Get health status of the service.
+    ///
+    /// Always returns `true` unless the service is critically broken.
+    /// This is used for Kubernetes liveness probes.
+    ///
+    /// Difference from `is_ready()`:
+    /// - `is_healthy()` checks if the service is alive (liveness)
+    /// - `is_ready()` checks if the service can handle traffic (readiness)
+    pub fn is_healthy(&self) -> bool {
+        // For liveness check, we just verify the service struct is valid
+        // If we can call this method, the service is alive
+        true
+    }
+}
+
+/// A collection setting stored as JSON in the collection's descriptor.
+fn encode_setting<T: serde::Serialize>(name: &str, setting: &T) -> CoreResult<JsonValue> {
+    serde_json::to_value(setting)
+        .map_err(|e| CoreError::internal(format!("Failed to encode {}: {}", name, e)))
+}
+
+/// A setting read back from a collection's descriptor (see `encode_setting`).
+fn decode_setting<T: serde::de::DeserializeOwned>(
+    name: &str,
+    setting: &JsonValue,
+) -> CoreResult<T> {
+    serde_json::from_value(setting.clone())
+        .map_err(|e| CoreError::internal(format!("Invalid stored {}: {}", name, e)))
+}
+
+/// Validate a collection name (used as a file system path component).
+fn validate_collection_name(name: &str) -> CoreResult<()> {
+    // FIX BUG #14: Validate collection name (prevent path traversal, DoS, file system attacks)
+    const MAX_COLLECTION_NAME_LEN: usize = 255; // File system path component limit
+
+    if name.is_empty() {
+        return Err(CoreError::ValidationError(
+            "collection name cannot be empty".to_string(),
+        ));
+    }
+
+    if name.len() > MAX_COLLECTION_NAME_LEN {
+        return Err(CoreError::ValidationError(format!(
+            "collection name must be <= {} characters (got {})",
+            MAX_COLLECTION_NAME_LEN,
+            name.len()
+        )));
+    }
+
+    // Check for path traversal attacks
+    if name.contains("..") || name.contains('/') || name.contains('\\') || name.contains('\0') {
+        return Err(CoreError::ValidationError(
+            "collection name contains invalid path characters (.. / \\ \\0)".to_string(),
+        ));
+    }
+
+    // Check for Windows invalid characters (cross-platform compatibility)
+    const WINDOWS_INVALID_CHARS: &[char] = &['<', '>', ':', '"', '|', '?', '*'];
+    if name.chars().any(|c| WINDOWS_INVALID_CHARS.contains(&c)) {
+        return Err(CoreError::ValidationError(
+            "collection name contains invalid characters (< > : \" | ? *)".to_string(),
+        ));
+    }
+
+    // Check for control characters (0x00-0x1F, 0x7F-0x9F)
+    if name.chars().any(|c| c.is_control()) {
+        return Err(CoreError::ValidationError(
+            "collection name contains control characters".to_string(),
+        ));
+    }
+
+    Ok(())
+}
+
+impl Default for CollectionService {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::{
+        hash_api_key, ApiKeyDescriptor, ApiKeyId, ApiKeyRepository, CollectionDefaults,
+        CollectionDescriptor, CollectionLimits, CollectionPolicy, CreateTenantRequest, DatabaseId,
+        DistanceMetric, DocumentId, IndexType, JobId, RotateApiKeyRequest, TenantCatalog,
+        TenantDescriptor, TenantId, TenantQuota, TenantStatus, MAX_KEY_ROTATION_GRACE_SECS,
+    };
+    use akidb_storage::object_store::ObjectStore;
+    use akidb_storage::TieringPolicy;
+    use async_trait::async_trait;
+    use chrono::Utc;
+    use std::collections::BTreeMap;
+
+    use crate::adaptive::{AdaptiveSearchConfig, DEFAULT_EF_SEARCH};
+    use crate::allowlist::{AllowlistScope, AllowlistSpec};
+    use crate::analysis::AnalyzerSettings;
+    use crate::anomaly::{AnomalyConfig, AnomalyKind};
+    use crate::backfill::{BackfillPatch, BackfillStatus};
+    use crate::batch::{MAX_BATCH_SIZE, MAX_DELETE_BATCH_SIZE};
+    use crate::capacity::{estimate_memory_bytes, ForecastLimits};
+    use crate::cloning::CloneOptions;
+    use crate::coalesce::CoalescingConfig;
+    use crate::compliance::{ComplianceAction, ComplianceRequest, ComplianceStatus, ExportRecord};
+    use crate::composition::QueryTerm;
+    use crate::drift::DriftConfig;
+    use crate::events::{EventComponent, EventLevel};
+    use crate::field_index::FieldIndexType;
+    use crate::hybrid::{FusionStrategy, HybridQuery};
+    use crate::impersonation::ImpersonationRequest;
+    use crate::legal_hold::LegalHoldSpec;
+    use crate::named_vectors::NamedVectorConfig;
+    use crate::ordering::{SortDirection, SortField};
+    use crate::progress::IMPORT_PROGRESS_INTERVAL;
+    use crate::provisioning::{
+        DeleteTenantOptions, TenantCreateStatus, TenantDeleteMode, TenantFilter,
+    };
+    use crate::reindex::{ReindexPlan, ReindexProgress};
+    use crate::replication::{
+        ReplicationConfig, ReplicationHealth, ReplicationProtocol, ReplicationTransport,
+    };
+    use crate::schedule::{JobRunStatus, ScheduledAction, ScheduledJobSpec};
+    use crate::snapshot::snapshot_path;
+    use crate::sparse::SparseVector;
+    use crate::standing::StandingQuerySpec;
+    use crate::transforms::TransformSpec;
+    use crate::upload::UploadStatus;
+    use crate::usage::{UsageGranularity, UsageMonth};
+    use crate::vector_codec::VectorCodec;
+    use crate::whoami::{Principal, SCOPE_WRITE};
+
+    fn create_test_collection() -> CollectionDescriptor {
+        CollectionDescriptor {
+            collection_id: CollectionId::new(),
+            database_id: DatabaseId::new(),
+            name: "test-collection".to_string(),
+            dimension: 128,
+            metric: DistanceMetric::Cosine,
+            embedding_model: "test-model".to_string(),
+            index_type: IndexType::Auto,
+            hnsw_m: 32,
+            hnsw_ef_construction: 200,
+            max_doc_count: 50_000_000,
+            description: None,
+            metadata: None,
+            read_only: false,
+            sparse_index: None,
+            named_vectors: None,
+            search_defaults: None,
+            text_analysis: None,
+            created_at: Utc::now(),
+            updated_at: Utc::now(),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_load_and_insert() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        // Load collection
+        service.load_collection(&collection).await.unwrap();
+
+        // Insert vector
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128]);
+        let doc_id = service.insert(collection.collection_id, doc).await.unwrap();
+
+        // Get vector
+        let retrieved = service.get(collection.collection_id, doc_id).await.unwrap();
+        assert!(retrieved.is_some());
+    }
+
+    #[tokio::test]
+    async fn test_query() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        service.load_collection(&collection).await.unwrap();
+
+        // Insert some vectors (non-zero for Cosine similarity)
+        for i in 0..10 {
+            let vector = vec![0.1 * (i + 1) as f32; 128]; // Start from 0.1, not 0.0
+            let doc = VectorDocument::new(DocumentId::new(), vector);
+            service.insert(collection.collection_id, doc).await.unwrap();
+        }
+
+        // Query
+        let query = vec![0.5; 128];
+        let results = service
+            .query(collection.collection_id, query, 5)
+            .await
+            .unwrap();
+
+        assert_eq!(results.len(), 5);
+    }
+
+    #[tokio::test]
+    async fn test_query_post_processing() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        service.load_collection(&collection).await.unwrap();
+
+        for i in 0..10 {
+            let mut vector = vec![0.1; 128];
+            vector[0] = i as f32;
+            let doc = VectorDocument::new(DocumentId::new(), vector);
+            service.insert(collection.collection_id, doc).await.unwrap();
+        }
+
+        let mut query = vec![0.1; 128];
+        query[0] = 9.0;
+
+        // Per-call pipeline: normalized scores, best match is 1.0
+        let pipeline = PostProcessingPipeline::new()
+            .with_normalization()
+            .with_threshold(0.5);
+        let results = service
+            .query_with_pipeline(collection.collection_id, query.clone(), 10, &pipeline)
+            .await
+            .unwrap();
+        assert!(!results.is_empty() && results.len() < 10);
+        assert!((results[0].score - 1.0).abs() < 1e-6);
+
+        // Default pipeline applies to plain query()
+        service
+            .set_post_processing(Some(PostProcessingPipeline::new().with_enricher(|r| {
+                r.metadata = Some(serde_json::json!({ "enriched": true }));
+            })))
+            .await;
+        let results = service
+            .query(collection.collection_id, query, 3)
+            .await
+            .unwrap();
+        assert!(results
+            .iter()
+            .all(|r| r.metadata.as_ref().unwrap()["enriched"] == true));
+    }
+
+    #[tokio::test]
+    async fn test_search_parents() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        service.load_collection(&collection).await.unwrap();
+
+        // Two parent documents, each with two chunks
+        let mut parent_ids = Vec::new();
+        for p in 0..2 {
+            let parent = VectorDocument::new(DocumentId::new(), vec![-1.0; 128])
+                .with_metadata(serde_json::json!({ "title": format!("doc-{}", p) }));
+            parent_ids.push(parent.doc_id);
+            service
+                .insert(collection.collection_id, parent)
+                .await
+                .unwrap();
+        }
+        for (p, parent_id) in parent_ids.iter().enumerate() {
+            for c in 0..2 {
+                let mut vector = vec![0.1; 128];
+                vector[p] = 1.0 + c as f32;
+                let chunk = VectorDocument::new(DocumentId::new(), vector)
+                    .with_metadata(serde_json::json!({ "parent_id": parent_id.to_string() }));
+                service
+                    .insert(collection.collection_id, chunk)
+                    .await
+                    .unwrap();
+            }
+        }
+
+        let mut query = vec![0.1; 128];
+        query[0] = 2.0;
+        let options = ParentSearchOptions::new(2).with_chunk_top_k(6);
+        let parents = service
+            .search_parents(collection.collection_id, query, &options, None)
+            .await
+            .unwrap();
+
+        assert_eq!(parents.len(), 2);
+        assert_eq!(parents[0].parent_id, parent_ids[0].to_string());
+        assert_eq!(parents[0].chunks.len(), 2);
+        assert_eq!(parents[0].metadata.as_ref().unwrap()["title"], "doc-0");
+    }
+
+    #[tokio::test]
+    async fn test_idf_stats() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        service.load_collection(&collection).await.unwrap();
+
+        for text in ["red apple", "green apple", "blue sky"] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128])
+                .with_metadata(serde_json::json!({ "text": text }));
+            service.insert(collection.collection_id, doc).await.unwrap();
+        }
+        // Document without text is skipped
+        service
+            .insert(
+                collection.collection_id,
+                VectorDocument::new(DocumentId::new(), vec![0.2; 128]),
+            )
+            .await
+            .unwrap();
+
+        let stats = service
+            .idf_stats(collection.collection_id, "text")
+            .await
+            .unwrap();
+        assert_eq!(stats.doc_count, 3);
+        assert_eq!(stats.doc_freq["apple"], 2);
+        assert!(stats.idf("sky") > stats.idf("apple"));
+    }
+
+    #[tokio::test]
+    async fn test_search_defaults() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        service.load_collection(&collection).await.unwrap();
+
+        for status in ["draft", "published", "published"] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128])
+                .with_metadata(serde_json::json!({ "status": status }));
+            service.insert(collection_id, doc).await.unwrap();
+        }
+        let defaults: SearchDefaults = serde_json::from_value(serde_json::json!({
+            "top_k": 5,
+            "ef_search": 64,
+            "filter": {"status": "published"},
+        }))
+        .unwrap();
+        service
+            .set_search_defaults(collection_id, defaults.clone())
+            .await
+            .unwrap();
+
+        let results = service
+            .query(collection_id, vec![0.1; 128], 5)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 2);
+        assert!(results
+            .iter()
+            .all(|r| r.metadata.as_ref().unwrap()["status"] == "published"));
+
+        // The defaults are stored with the collection and survive a restart
+        let descriptor = service.get_collection(collection_id).await.unwrap();
+        let restarted = CollectionService::new();
+        restarted.load_collection(&descriptor).await.unwrap();
+        assert_eq!(
+            restarted.search_defaults(collection_id).await.unwrap(),
+            defaults
+        );
+
+        service
+            .set_search_defaults(collection_id, SearchDefaults::default())
+            .await
+            .unwrap();
+        let results = service
+            .query(collection_id, vec![0.1; 128], 5)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 3);
+        assert!(service
+            .search_defaults(collection_id)
+            .await
+            .unwrap()
+            .is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_hybrid_search() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        service.load_collection(&collection).await.unwrap();
+
+        let mut ids = Vec::new();
+        for (axis, text) in ["red apple", "green apple", "blue sky"].iter().enumerate() {
+            let mut vector = vec![0.0; 128];
+            vector[axis] = 1.0;
+            let doc = VectorDocument::new(DocumentId::new(), vector)
+                .with_metadata(serde_json::json!({ "text": text }));
+            ids.push(service.insert(collection.collection_id, doc).await.unwrap());
+        }
+        let mut query = vec![0.0; 128];
+        query[0] = 1.0;
+
+        // "sky" only matches the last document, which RRF lifts to the top
+        let results = service
+            .hybrid_search(
+                collection.collection_id,
+                query.clone(),
+                &HybridQuery::text("sky"),
+                2,
+            )
+            .await
+            .unwrap();
+        let found: Vec<_> = results.iter().map(|r| r.doc_id).collect();
+        assert_eq!(found, vec![ids[2], ids[0]]);
+        assert!(results[0].metadata.is_some());
+
+        let dense_only =
+            HybridQuery::text("sky").with_fusion(FusionStrategy::Weighted { dense_weight: 1.0 });
+        let results = service
+            .hybrid_search(collection.collection_id, query, &dense_only, 1)
+            .await
+            .unwrap();
+        assert_eq!(results[0].doc_id, ids[0]);
+    }
+
+    #[tokio::test]
+    async fn test_sparse_search() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        service.load_collection(&collection).await.unwrap();
+
+        let mut ids = Vec::new();
+        for weight in [1.0, 2.0] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 128]);
+            let doc_id = service.insert(collection_id, doc).await.unwrap();
+            let vector = SparseVector::from_pairs([(3, weight)]);
+            // No sparse index yet
+            assert!(service
+                .set_sparse_vector(collection_id, doc_id, vector)
+                .await
+                .is_err());
+            ids.push(doc_id);
+        }
+
+        let config = SparseIndexConfig {
+            dimension: Some(1_000),
+            ..Default::default()
+        };
+        service
+            .set_sparse_index(collection_id, config)
+            .await
+            .unwrap();
+        for (doc_id, weight) in ids.iter().zip([1.0, 2.0]) {
+            let vector = SparseVector::from_pairs([(3, weight), (500, 1.0)]);
+            service
+                .set_sparse_vector(collection_id, *doc_id, vector)
+                .await
+                .unwrap();
+        }
+        let out_of_range = SparseVector::from_pairs([(1_000, 1.0)]);
+        assert!(service
+            .check_sparse_vector(collection_id, &out_of_range)
+            .await
+            .is_err());
+
+        let query = SparseVector::from_pairs([(3, 1.0)]);
+        let results = service
+            .sparse_search(collection_id, &query, 10)
+            .await
+            .unwrap();
+        let found: Vec<_> = results.iter().map(|r| r.doc_id).collect();
+        assert_eq!(found, vec![ids[1], ids[0]]);
+        assert_eq!(results[0].score, 2.0);
+
+        // Metadata updates keep the sparse vector; deletes drop it
+        let patch = serde_json::json!({"tag": "x"});
+        service
+            .update_metadata(collection_id, ids[1], patch.as_object().unwrap())
+            .await
+            .unwrap();
+        assert!(service.sparse_vector(collection_id, ids[1]).await.is_some());
+        service.delete(collection_id, ids[1]).await.unwrap();
+        let results = service
+            .sparse_search(collection_id, &query, 10)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].doc_id, ids[0]);
+    }
+
+    #[tokio::test]
+    async fn test_sparse_vectors_survive_reload() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        service.load_collection(&collection).await.unwrap();
+        let config = SparseIndexConfig {
+            dimension: Some(1_000),
+            ..Default::default()
+        };
+        service
+            .set_sparse_index(collection_id, config.clone())
+            .await
+            .unwrap();
+
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 128]);
+        let doc_id = service.insert(collection_id, doc).await.unwrap();
+        let vector = SparseVector::from_pairs([(3, 1.0), (500, 2.0)]);
+        service
+            .set_sparse_vector(collection_id, doc_id, vector.clone())
+            .await
+            .unwrap();
+
+        // A restarted service rebuilds the index from the stored documents
+        let descriptor = service.get_collection(collection_id).await.unwrap();
+        let restarted = CollectionService::new();
+        restarted.load_collection(&descriptor).await.unwrap();
+        assert_eq!(
+            restarted.sparse_index_config(collection_id).await.unwrap(),
+            Some(config)
+        );
+        assert_eq!(
+            restarted.sparse_vector(collection_id, doc_id).await,
+            Some(vector)
+        );
+        let query = SparseVector::from_pairs([(500, 1.0)]);
+        let results = restarted
+            .sparse_search(collection_id, &query, 10)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].doc_id, doc_id);
+
+        // Dropping the index removes the vectors from the documents too
+        restarted.drop_sparse_index(collection_id).await.unwrap();
+        let descriptor = restarted.get_collection(collection_id).await.unwrap();
+        assert!(descriptor.sparse_index.is_none());
+        let restarted = CollectionService::new();
+        restarted.load_collection(&descriptor).await.unwrap();
+        restarted
+            .set_sparse_index(collection_id, SparseIndexConfig::default())
+            .await
+            .unwrap();
+        assert!(restarted
+            .sparse_vector(collection_id, doc_id)
+            .await
+            .is_none());
+    }
+
+    #[tokio::test]
+    async fn test_named_vector_search() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        service.load_collection(&collection).await.unwrap();
+
+        let configs = BTreeMap::from([(
+            "title".to_string(),
+            NamedVectorConfig::new(16, DistanceMetric::Dot),
+        )]);
+        service
+            .set_named_vector_configs(collection_id, configs)
+            .await
+            .unwrap();
+
+        let mut ids = Vec::new();
+        for weight in [1.0, 2.0] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 128]);
+            let doc_id = service.insert(collection_id, doc).await.unwrap();
+            let vectors = HashMap::from([("title".to_string(), vec![weight; 16])]);
+            service
+                .set_named_vectors(collection_id, doc_id, vectors)
+                .await
+                .unwrap();
+            ids.push(doc_id);
+        }
+        let wrong_dimension = HashMap::from([("title".to_string(), vec![1.0; 128])]);
+        assert!(service
+            .check_named_vectors(collection_id, &wrong_dimension)
+            .await
+            .is_err());
+
+        let results = service
+            .named_vector_search(collection_id, "title", &[1.0; 16], 10, None)
+            .await
+            .unwrap();
+        let found: Vec<_> = results.iter().map(|r| r.doc_id).collect();
+        assert_eq!(found, vec![ids[1], ids[0]]);
+        assert!(service
+            .named_vector_search(collection_id, "body", &[1.0; 16], 10, None)
+            .await
+            .is_err());
+
+        // Metadata updates keep named vectors; deletes drop them
+        let patch = serde_json::json!({"tag": "x"});
+        service
+            .update_metadata(collection_id, ids[1], patch.as_object().unwrap())
+            .await
+            .unwrap();
+        assert_eq!(service.named_vectors(collection_id, ids[1]).await.len(), 1);
+        service.delete(collection_id, ids[1]).await.unwrap();
+        let results = service
+            .named_vector_search(collection_id, "title", &[1.0; 16], 10, None)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_named_vectors_survive_reload() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        service.load_collection(&collection).await.unwrap();
+        let configs = BTreeMap::from([
+            (
+                "title".to_string(),
+                NamedVectorConfig::new(16, DistanceMetric::Dot),
+            ),
+            (
+                "body".to_string(),
+                NamedVectorConfig::new(32, DistanceMetric::Cosine),
+            ),
+        ]);
+        service
+            .set_named_vector_configs(collection_id, configs.clone())
+            .await
+            .unwrap();
+
+        // Named vectors are written with the document
+        let vectors = HashMap::from([
+            ("title".to_string(), vec![1.0; 16]),
+            ("body".to_string(), vec![0.5; 32]),
+        ]);
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 128])
+            .with_named_vectors(vectors.clone());
+        let doc_id = service.insert(collection_id, doc).await.unwrap();
+        let wrong = VectorDocument::new(DocumentId::new(), vec![1.0; 128])
+            .with_named_vectors(HashMap::from([("title".to_string(), vec![1.0; 8])]));
+        assert!(matches!(
+            service.insert(collection_id, wrong).await,
+            Err(CoreError::ValidationError(_))
+        ));
+
+        let descriptor = service.get_collection(collection_id).await.unwrap();
+        let restarted = CollectionService::new();
+        restarted.load_collection(&descriptor).await.unwrap();
+        assert_eq!(
+            restarted.named_vector_configs(collection_id).await.unwrap(),
+            configs
+        );
+        assert_eq!(
+            restarted.named_vectors(collection_id, doc_id).await,
+            vectors
+        );
+        let results = restarted
+            .named_vector_search(collection_id, "title", &[1.0; 16], 10, None)
+            .await
+            .unwrap();
+        assert_eq!(results[0].doc_id, doc_id);
+
+        // Dropping a space removes its vectors from the documents too
+        let title_only = BTreeMap::from([(
+            "title".to_string(),
+            NamedVectorConfig::new(16, DistanceMetric::Dot),
+        )]);
+        restarted
+            .set_named_vector_configs(collection_id, title_only)
+            .await
+            .unwrap();
+        let descriptor = restarted.get_collection(collection_id).await.unwrap();
+        let restarted = CollectionService::new();
+        restarted.load_collection(&descriptor).await.unwrap();
+        restarted
+            .set_named_vector_configs(collection_id, configs)
+            .await
+            .unwrap();
+        let stored = restarted.named_vectors(collection_id, doc_id).await;
+        assert_eq!(stored.keys().collect::<Vec<_>>(), vec!["title"]);
+    }
+
+    struct LocalTransport(Arc<CollectionService>);
+
+    #[async_trait]
+    impl ReplicationTransport for LocalTransport {
+        async fn ship(
+            &self,
+            _endpoint: &str,
+            collection_id: CollectionId,
+            ops: &[ReplicationOp],
+        ) -> CoreResult<()> {
+            self.0
+                .apply_replicated(collection_id, ops.to_vec())
+                .await
+                .map(|_| ())
+        }
+    }
+
+    #[tokio::test]
+    async fn test_replication_to_another_region() {
+        let source = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        source.load_collection(&collection).await.unwrap();
+        let target = Arc::new(CollectionService::new());
+        let replica = create_test_collection();
+        target.load_collection(&replica).await.unwrap();
+
+        let existing = VectorDocument::new(DocumentId::new(), vec![1.0; 128]);
+        let existing_id = source.insert(collection_id, existing).await.unwrap();
+        source.set_replication_transport(
+            ReplicationProtocol::Http,
+            Arc::new(LocalTransport(Arc::clone(&target))),
+        );
+        let config = ReplicationConfig {
+            target_region: "eu-west-1".to_string(),
+            target_endpoint: "http://akidb.eu-west-1.internal:8080".to_string(),
+            protocol: ReplicationProtocol::Http,
+            target_collection_id: Some(replica.collection_id),
+            lag_slo_secs: 60,
+            paused: false,
+        };
+        source
+            .configure_replication(collection_id, config)
+            .await
+            .unwrap();
+
+        // The full copy carries documents stored before replication started
+        assert_eq!(source.replicate_pending().await, 1);
+        assert!(target
+            .get(replica.collection_id, existing_id)
+            .await
+            .unwrap()
+            .is_some());
+
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 128]);
+        let doc_id = source.insert(collection_id, doc).await.unwrap();
+        source.delete(collection_id, existing_id).await.unwrap();
+        let status = source.replication_status(collection_id).await.unwrap();
+        assert_eq!(status.pending, 2);
+        assert_eq!(source.replicate_pending().await, 2);
+        assert!(target
+            .get(replica.collection_id, doc_id)
+            .await
+            .unwrap()
+            .is_some());
+        assert!(target
+            .get(replica.collection_id, existing_id)
+            .await
+            .unwrap()
+            .is_none());
+
+        let status = source.replication_status(collection_id).await.unwrap();
+        assert_eq!(status.health, ReplicationHealth::Healthy);
+        assert_eq!((status.pending, status.shipped), (0, 3));
+    }
+
+    #[tokio::test]
+    async fn test_background_failures_published() {
+        let source = CollectionService::new();
+        let collection = create_test_collection();
+        let collection_id = collection.collection_id;
+        source.load_collection(&collection).await.unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 128]);
+        source.insert(collection_id, doc).await.unwrap();
+        let mut events = source.subscribe_events();
+
+        let failing = crate::chaos::ChaosTransport::new(
+            Arc::new(LocalTransport(Arc::new(CollectionService::new()))),
+            crate::chaos::FaultProfile {
+                error_rate: 1.0,
+                ..Default::default()
+            },
+        )
+        .unwrap();
+        source.set_replication_transport(ReplicationProtocol::Http, Arc::new(failing));
+        let config = ReplicationConfig {
+            target_region: "eu-west-1".to_string(),
+            target_endpoint: "http://akidb.eu-west-1.internal:8080".to_string(),
+            protocol: ReplicationProtocol::Http,
+            target_collection_id: None,
+            lag_slo_secs: 60,
+            paused: false,
+        };
+        source
+            .configure_replication(collection_id, config)
+            .await
+            .unwrap();
+        assert_eq!(source.replicate_pending().await, 0);
+
+        let event = events.try_recv().unwrap();
+        assert_eq!(event.level, EventLevel::Warning);
+        assert_eq!(event.component, EventComponent::Replication);
+        assert_eq!(event.collection_id, Some(collection_id));
+        assert!(event.message.contains("akidb.eu-west-1.internal"));
+
+        assert_eq!(source.list_events(None, EventLevel::Warning).len(), 1);
+        assert!(source
+            .list_events(Some(event.id), EventLevel::Info)
+            .is_empty());
+        assert!(source.list_events(None, EventLevel::Error).is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_text_analysis_settings() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        service.load_collection(&collection).await.unwrap();
+
+        service
+            .set_stopwords(collection.collection_id, vec!["the".to_string()])
+            .await
+            .unwrap();
+        service
+            .set_synonyms(
+                collection.collection_id,
+                vec![vec!["car".to_string(), "auto".to_string()]],
+            )
+            .await
+            .unwrap();
+
+        let tokens = service
+            .analyze(collection.collection_id, None, "The Auto", None)
+            .await
+            .unwrap();
+        assert_eq!(tokens.len(), 1);
+        assert_eq!(tokens[0].token, "car");
+        assert_eq!(tokens[0].original, "Auto");
+
+        // Per-field language settings add stemming
+        service
+            .set_field_analyzer(
+                collection.collection_id,
+                "title",
+                Some(AnalyzerSettings {
+                    language: crate::analysis::Language::English,
+                    ..Default::default()
+                }),
+            )
+            .await
+            .unwrap();
+        let tokens = service
+            .analyze(collection.collection_id, Some("title"), "The Autos", None)
+            .await
+            .unwrap();
+        assert_eq!(tokens[0].token, "car");
+
+        // Stopwords and synonyms survive later updates
+        let analysis = service
+            .text_analysis(collection.collection_id)
+            .await
+            .unwrap();
+        assert!(analysis.analyzer.stopwords.contains("the"));
+        assert_eq!(analysis.analyzer.synonyms.len(), 1);
+        assert!(analysis.fields.contains_key("title"));
+
+        // ...and a restart
+        let descriptor = service
+            .get_collection(collection.collection_id)
+            .await
+            .unwrap();
+        let restarted = CollectionService::new();
+        restarted.load_collection(&descriptor).await.unwrap();
+        assert_eq!(
+            restarted
+                .text_analysis(collection.collection_id)
+                .await
+                .unwrap(),
+            analysis
+        );
+
+        assert!(service
+            .analyze(CollectionId::new(), None, "text", None)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_aliases() {
+        let service = CollectionService::new();
+        let blue = service
+            .create_collection("docs_v1".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let green = service
+            .create_collection("docs_v2".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+
+        assert_eq!(service.set_alias("docs", blue).await.unwrap(), None);
+        assert_eq!(service.set_alias("docs", green).await.unwrap(), Some(blue));
+        assert_eq!(service.resolve_alias("docs").await.unwrap(), green);
+        assert_eq!(
+            service.list_aliases().await.into_iter().collect::<Vec<_>>(),
+            vec![("docs".to_string(), green)]
+        );
+
+        for invalid in ["", "a/b", "..", "docs v2", &blue.to_string()] {
+            assert!(
+                service.set_alias(invalid, green).await.is_err(),
+                "{}",
+                invalid
+            );
+        }
+        assert_eq!(service.delete_alias("docs").await.unwrap(), green);
+        assert!(service.resolve_alias("docs").await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_collection_handle() {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let docs = service.collection(collection_id).with_top_k(2);
+        let vector = |x: f32, y: f32, z: f32| {
+            let mut vector = vec![0.0; 16];
+            vector[..3].copy_from_slice(&[x, y, z]);
+            vector
+        };
+
+        for i in 0..5 {
+            let doc = VectorDocument::new(DocumentId::new(), vector(1.0, i as f32, 0.0));
+            docs.insert(doc).await.unwrap();
+        }
+        let doc_id = docs
+            .insert(VectorDocument::new(
+                DocumentId::new(),
+                vector(0.0, 0.0, 1.0),
+            ))
+            .await
+            .unwrap();
+        assert!(docs.get(doc_id).await.unwrap().is_some());
+
+        assert_eq!(docs.search(vector(1.0, 0.0, 0.0)).await.unwrap().len(), 2);
+        let results = docs
+            .search_top_k(vector(1.0, 0.0, 0.0), Some(4))
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 4);
+
+        // A score threshold drops the orthogonal document
+        let strict = docs.clone().with_top_k(10).with_min_score(0.1);
+        let results = strict.search(vector(1.0, 0.0, 0.0)).await.unwrap();
+        assert_eq!(results.len(), 5);
+        assert!(results.iter().all(|r| r.doc_id != doc_id));
+
+        docs.delete(doc_id).await.unwrap();
+        assert!(docs.get(doc_id).await.unwrap().is_none());
+
+        // By name and by alias
+        let named = service.collection_named("docs").await.unwrap();
+        assert_eq!(named.collection_id(), collection_id);
+        service.set_alias("latest", collection_id).await.unwrap();
+        let aliased = service.collection_named("latest").await.unwrap();
+        assert_eq!(aliased.collection_id(), collection_id);
+        assert!(service.collection_named("missing").await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_collection_handle_scope() {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let docs = service.collection(collection_id);
+        for i in 0..3 {
+            let mut vector = vec![0.0; 16];
+            vector[..2].copy_from_slice(&[1.0, i as f32]);
+            docs.insert(VectorDocument::new(DocumentId::new(), vector))
+                .await
+                .unwrap();
+        }
+
+        assert!(CollectionHandle::current().is_none());
+        let bound = docs
+            .with_top_k(1)
+            .scope(async {
+                let current = CollectionHandle::current().unwrap();
+                assert_eq!(current.collection_id(), collection_id);
+                current.search(vec![1.0; 16]).await.unwrap().len()
+            })
+            .await;
+        assert_eq!(bound, 1);
+
+        // Not visible outside the scope, nor from tasks it spawns
+        assert!(CollectionHandle::current().is_none());
+        let spawned = service
+            .collection(collection_id)
+            .scope(async { tokio::spawn(async { CollectionHandle::current().is_none() }).await })
+            .await
+            .unwrap();
+        assert!(spawned);
+    }
+
+    #[tokio::test]
+    async fn test_change_stream() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+        service.load_collection(&collection).await.unwrap();
+        let collection_id = collection.collection_id;
+
+        let mut changes = service
+            .subscribe_changes(collection_id, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128]);
+        let doc_id = service.insert(collection_id, doc.clone()).await.unwrap();
+        service
+            .upsert(
+                collection_id,
+                doc.with_metadata(serde_json::json!({"v": 2})),
+            )
+            .await
+            .unwrap();
+        service.delete(collection_id, doc_id).await.unwrap();
+
+        let mut events = Vec::new();
+        while let Ok(event) = changes.updates.try_recv() {
+            events.push(event);
+        }
+        let ops: Vec<&str> = events
+            .iter()
+            .map(|event| match &event.op {
+                ReplicationOp::Upsert { .. } => "upsert",
+                ReplicationOp::Delete { .. } => "delete",
+            })
+            .collect();
+        // The replacement is a single upsert
+        assert_eq!(ops, ["upsert", "upsert", "delete"]);
+        match &events[1].op {
+            ReplicationOp::Upsert { document } => {
+                assert_eq!(document.metadata, Some(serde_json::json!({"v": 2})))
+            }
+            op => panic!("unexpected {:?}", op),
+        }
+
+        // Resume after the first event
+        let resumed = service
+            .subscribe_changes(collection_id, Some(&events[0].cursor))
+            .await
+            .unwrap();
+        assert_eq!(resumed.backlog.len(), 2);
+
+        service.delete_collection(collection_id).await.unwrap();
+        assert!(changes.updates.try_recv().is_err());
+        assert!(service
+            .subscribe_changes(collection_id, None)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_reindex_swaps_alias() {
+        let service = CollectionService::new();
+        let source = service
+            .create_collection("reindex-src".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        service.set_alias("products", source).await.unwrap();
+
+        for i in 0..5 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![i as f32 + 1.0; 16])
+                .with_metadata(serde_json::json!({ "keep": i % 2 == 0 }));
+            service.insert(source, doc).await.unwrap();
+        }
+
+        let progress = Arc::new(std::sync::Mutex::new(Vec::new()));
+        let progress_log = Arc::clone(&progress);
+        let plan = ReindexPlan::new("products", source, "reindex-dst")
+            .with_metric(DistanceMetric::L2)
+            .with_transform(|doc| {
+                let keep = doc.metadata.as_ref().map_or(false, |m| m["keep"] == true);
+                Ok(keep.then_some(doc))
+            })
+            .on_progress(move |p| progress_log.lock().unwrap().push(p));
+
+        let report = service.reindex(plan).await.unwrap();
+        assert_eq!((report.copied, report.skipped), (3, 2));
+        let alias_target = service.resolve_alias("products").await.unwrap();
+        assert_eq!(alias_target, report.target);
+        assert_eq!(service.get_count(report.target).await.unwrap(), 3);
+        assert_eq!(
+            service.get_collection(report.target).await.unwrap().metric,
+            DistanceMetric::L2
+        );
+        // Source is kept for rollback unless requested otherwise
+        assert_eq!(service.get_count(source).await.unwrap(), 5);
+
+        let last = *progress.lock().unwrap().last().unwrap();
+        assert_eq!(
+            last,
+            ReindexProgress {
+                copied: 3,
+                skipped: 2,
+                total: 5
+            }
+        );
+    }
+
+    #[tokio::test]
+    async fn test_reindex_with_declarative_transforms() {
+        let service = CollectionService::new();
+        let source = service
+            .create_collection("mrl-src".to_string(), 32, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 32])
+            .with_metadata(serde_json::json!({ "title": "a", "legacy": 1 }));
+        let doc_id = doc.doc_id;
+        service.insert(source, doc).await.unwrap();
+
+        let spec: TransformSpec = serde_json::from_value(serde_json::json!([
+            { "op": "rename_field", "from": "title", "to": "name" },
+            { "op": "drop_field", "field": "legacy" },
+            { "op": "truncate_dimension", "dimension": 16, "normalize": true }
+        ]))
+        .unwrap();
+        let plan = ReindexPlan::new("mrl", source, "mrl-dst").with_transforms(spec);
+        let report = service.reindex(plan).await.unwrap();
+
+        let target = service.get_collection(report.target).await.unwrap();
+        assert_eq!(target.dimension, 16);
+        let copied = service.get(report.target, doc_id).await.unwrap().unwrap();
+        assert_eq!(copied.vector.len(), 16);
+        assert_eq!(copied.metadata, Some(serde_json::json!({ "name": "a" })));
+    }
+
+    #[tokio::test]
+    async fn test_reindex_failure_keeps_alias() {
+        let service = CollectionService::new();
+        let source = service
+            .create_collection("fail-src".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        service.set_alias("live", source).await.unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(source, doc).await.unwrap();
+
+        // Transform produces vectors of the wrong dimension
+        let plan = ReindexPlan::new("live", source, "fail-dst")
+            .with_dimension(32)
+            .with_transform(|doc| Ok(Some(doc)));
+        assert!(service.reindex(plan).await.is_err());
+
+        assert_eq!(service.resolve_alias("live").await.unwrap(), source);
+        assert_eq!(service.list_collections().await.unwrap().len(), 1);
+
+        // Alias pointing elsewhere is rejected up front
+        let other = service
+            .create_collection("other".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let plan = ReindexPlan::new("live", other, "other-dst");
+        assert!(service.reindex(plan).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_backfill_job_resumes_unpatched_records() {
+        let service = CollectionService::new();
+        let source = service
+            .create_collection("bf-src".to_string(), 32, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let target = service
+            .create_collection("bf-dst".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        for i in 0..5 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![i as f32 + 1.0; 32])
+                .with_metadata(serde_json::json!({ "text": format!("doc {}", i) }));
+            service.insert(source, doc).await.unwrap();
+        }
+
+        let patch = |record: &crate::backfill::BackfillRecord| BackfillPatch {
+            doc_id: record.doc_id,
+            vector: vec![0.5; 16],
+        };
+        let job = service.create_backfill_job(source, target).await.unwrap();
+
+        let first = service.backfill_next_batch(job.id, 2).await.unwrap();
+        assert_eq!(first.records.len(), 2);
+        assert!(!first.exhausted);
+        let progress = service
+            .backfill_patch(job.id, first.records.iter().map(patch).collect())
+            .await
+            .unwrap();
+        assert_eq!((progress.completed, progress.remaining), (2, 3));
+
+        // A worker takes the rest but only patches two before crashing
+        let second = service.backfill_next_batch(job.id, 10).await.unwrap();
+        assert_eq!(second.records.len(), 3);
+        assert!(second.exhausted);
+        let first_ids: HashSet<DocumentId> = first.records.iter().map(|r| r.doc_id).collect();
+        let overlap = second.records.iter().any(|r| first_ids.contains(&r.doc_id));
+        assert!(!overlap);
+        service
+            .backfill_patch(job.id, second.records[..2].iter().map(patch).collect())
+            .await
+            .unwrap();
+        assert!(service
+            .backfill_next_batch(job.id, 10)
+            .await
+            .unwrap()
+            .records
+            .is_empty());
+
+        // Resume streams only the record still missing a vector
+        service.resume_backfill(job.id).await.unwrap();
+        let retry = service.backfill_next_batch(job.id, 10).await.unwrap();
+        assert_eq!(retry.records.len(), 1);
+        assert_eq!(retry.records[0].doc_id, second.records[2].doc_id);
+        let progress = service
+            .backfill_patch(job.id, retry.records.iter().map(patch).collect())
+            .await
+            .unwrap();
+        assert_eq!(progress.status, BackfillStatus::Completed);
+        assert_eq!((progress.completed, progress.remaining), (5, 0));
+
+        // Patched documents carry the source metadata
+        let doc_id = retry.records[0].doc_id;
+        let patched = service.get(target, doc_id).await.unwrap().unwrap();
+        assert_eq!(patched.vector, vec![0.5; 16]);
+        assert_eq!(patched.metadata, retry.records[0].metadata);
+    }
+
+    #[tokio::test]
+    async fn test_drift_report_tracks_queries() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("drift".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        for _ in 0..4 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![0.25; 16]);
+            service.insert(collection_id, doc).await.unwrap();
+        }
+
+        assert!(service.drift_report(collection_id).await.is_err());
+        let config = DriftConfig {
+            min_samples: 2,
+            ..Default::default()
+        };
+        let baseline = service
+            .enable_drift_monitoring(collection_id, config)
+            .await
+            .unwrap();
+        assert_eq!(baseline.count, 4);
+
+        let mut mismatched = vec![0.0; 16];
+        mismatched[0] = 3.0;
+        for _ in 0..3 {
+            service
+                .query(collection_id, mismatched.clone(), 1)
+                .await
+                .unwrap();
+        }
+
+        let report = service.drift_report(collection_id).await.unwrap();
+        assert_eq!(report.queries.count, 3);
+        assert!(!report.stored_drift.unwrap().drifted);
+        assert!(report.query_drift.unwrap().drifted);
+        assert!(report.drifted);
+    }
+
+    #[tokio::test]
+    async fn test_growth_forecast() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("growth".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+
+        assert_eq!(service.record_growth_samples().await.unwrap(), 1);
+        tokio::time::sleep(std::time::Duration::from_millis(5)).await;
+        for _ in 0..3 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+            service.insert(collection_id, doc).await.unwrap();
+        }
+        service.record_growth_samples().await.unwrap();
+
+        let history = service.growth_history(collection_id).await.unwrap();
+        assert_eq!(history.len(), 2);
+        assert_eq!(history[1].vector_count, 3);
+        assert_eq!(history[1].estimated_bytes, estimate_memory_bytes(3, 16));
+
+        let limits = ForecastLimits {
+            max_vectors: Some(2),
+            memory_bytes: None,
+        };
+        let forecast = service
+            .forecast_growth(collection_id, 7.0, limits)
+            .await
+            .unwrap();
+        assert_eq!(forecast.trend.vector_count, 3);
+        assert!(forecast.trend.vectors_per_day > 0.0);
+        assert_eq!(forecast.days_until_vector_limit, Some(0.0));
+
+        let node = service.forecast_capacity(7.0, limits).await.unwrap();
+        assert_eq!(node.trend.vector_count, 3);
+        assert!(service.forecast_capacity(0.0, limits).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_cost_estimates() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("cost".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        for _ in 0..2 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+            service.insert(collection_id, doc).await.unwrap();
+        }
+
+        let search = service
+            .estimate_search_cost(collection_id, 5, 10)
+            .await
+            .unwrap();
+        assert_eq!(search.vectors_scanned, 2);
+        assert_eq!(search.read_units, 10);
+        assert!(service
+            .estimate_search_cost(collection_id, 0, 1)
+            .await
+            .is_err());
+
+        let import = service
+            .estimate_import_cost(collection_id, 100, 0)
+            .await
+            .unwrap();
+        assert_eq!(import.bytes, estimate_memory_bytes(100, 16));
+    }
+
+    #[tokio::test]
+    async fn test_collection_policy_limits() {
+        let service = CollectionService::new();
+        let policy = CollectionPolicy {
+            defaults: CollectionDefaults {
+                metric: Some(DistanceMetric::L2),
+                top_k: Some(5),
+            },
+            limits: CollectionLimits {
+                max_dimension: Some(64),
+                max_top_k: Some(20),
+                max_filter_clauses: None,
+            },
+        };
+        service.set_collection_policy(policy).await.unwrap();
+
+        let result = service
+            .create_collection("wide".to_string(), 128, DistanceMetric::Cosine, None)
+            .await;
+        assert!(matches!(result, Err(CoreError::QuotaExceeded { .. })));
+
+        let metric = service.resolve_metric(None).await.unwrap();
+        assert_eq!(metric, DistanceMetric::L2);
+        let collection_id = service
+            .create_collection("narrow".to_string(), 32, metric, None)
+            .await
+            .unwrap();
+
+        assert_eq!(service.resolve_top_k(None).await.unwrap(), 5);
+        let result = service.query(collection_id, vec![1.0; 32], 50).await;
+        assert!(matches!(result, Err(CoreError::QuotaExceeded { .. })));
+        let result = service.query(collection_id, vec![1.0; 32], 20).await;
+        assert!(result.is_ok());
+
+        // Defaults must respect the limits
+        let invalid = CollectionPolicy {
+            defaults: CollectionDefaults {
+                metric: None,
+                top_k: Some(50),
+            },
+            limits: CollectionLimits {
+                max_top_k: Some(20),
+                ..Default::default()
+            },
+        };
+        assert!(service.set_collection_policy(invalid).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_resumable_upload() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("uploads".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let upload = service.create_upload(collection_id, None).await.unwrap();
+
+        let record = |i: usize| {
+            format!(
+                "{{\"external_id\": \"doc-{}\", \"vector\": {:?}}}\n",
+                i,
+                vec![1.0f32; 16]
+            )
+        };
+        let file = format!("{}\n{}not json\n{}", record(1), record(2), record(3));
+        // Split mid-record and send the parts out of order
+        let (first, second) = file.as_bytes().split_at(record(1).len() + 10);
+        service
+            .upload_part(upload.id, 2, second.to_vec())
+            .await
+            .unwrap();
+        let err = service.complete_upload(upload.id).await.unwrap_err();
+        assert!(matches!(err, CoreError::InvalidState { .. }));
+        service
+            .upload_part(upload.id, 1, first.to_vec())
+            .await
+            .unwrap();
+
+        let report = service.complete_upload(upload.id).await.unwrap();
+        assert_eq!(report.records, 4);
+        assert_eq!(report.inserted, 3);
+        assert_eq!(report.failed, 1);
+        assert_eq!(report.errors[0].line, 4);
+        assert_eq!(service.get_count(collection_id).await.unwrap(), 3);
+
+        let upload = service.get_upload(upload.id).await.unwrap();
+        assert_eq!(upload.status, UploadStatus::Completed);
+        assert!(service
+            .upload_part(upload.id, 3, vec![b'\n'])
+            .await
+            .is_err());
+        assert!(!upload.dir().exists());
+    }
+
+    #[tokio::test]
+    async fn test_import_progress_events() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("progress".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let upload = service.create_upload(collection_id, None).await.unwrap();
+        let record = format!("{{\"vector\": {:?}}}\n", vec![1.0f32; 16]);
+        let file = record.repeat(IMPORT_PROGRESS_INTERVAL as usize + 1);
+        service
+            .upload_part(upload.id, 1, file.into_bytes())
+            .await
+            .unwrap();
+
+        let mut events = service.subscribe_import_progress(upload.id).await.unwrap();
+        service.complete_upload(upload.id).await.unwrap();
+
+        let statuses: Vec<(UploadStatus, u64)> = std::iter::from_fn(|| events.try_recv().ok())
+            .map(|e| (e.status, e.inserted))
+            .collect();
+        assert_eq!(
+            statuses,
+            vec![
+                (UploadStatus::Importing, 0),
+                (UploadStatus::Importing, IMPORT_PROGRESS_INTERVAL),
+                (UploadStatus::Completed, IMPORT_PROGRESS_INTERVAL + 1),
+            ]
+        );
+        // The stream ends with the import
+        assert!(matches!(
+            events.try_recv(),
+            Err(tokio::sync::broadcast::error::TryRecvError::Closed)
+        ));
+
+        // Subscribing to a finished upload yields a closed stream
+        let mut events = service.subscribe_import_progress(upload.id).await.unwrap();
+        assert!(matches!(
+            events.try_recv(),
+            Err(tokio::sync::broadcast::error::TryRecvError::Closed)
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_signed_upload_url() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("signed".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let err = service
+            .create_upload_url(collection_id, None, None)
+            .await
+            .unwrap_err();
+        assert!(matches!(err, CoreError::InvalidState { .. }));
+
+        let store = Arc::new(akidb_storage::MockS3ObjectStore::new());
+        service.set_import_store(Some(store.clone())).await;
+        let signed = service
+            .create_upload_url(collection_id, None, None)
+            .await
+            .unwrap();
+        assert!(signed.url.contains("method=PUT"));
+        let upload_id = signed.upload.id;
+        let key = signed.upload.object_key.unwrap();
+
+        // Nothing has been PUT to the URL yet
+        let err = service.complete_upload(upload_id).await.unwrap_err();
+        assert!(matches!(err, CoreError::InvalidState { .. }));
+        assert!(service
+            .upload_part(upload_id, 1, b"{}".to_vec())
+            .await
+            .is_err());
+
+        let record = format!("{{\"vector\": {:?}}}", vec![1.0f32; 16]);
+        store
+            .put(&key, format!("{}\n{}", record, record).into())
+            .await
+            .unwrap();
+        let report = service.complete_upload(upload_id).await.unwrap();
+        assert_eq!(report.inserted, 2);
+        assert_eq!(service.get_count(collection_id).await.unwrap(), 2);
+        assert!(!store.contains_key(&key));
+    }
+
+    #[tokio::test]
+    async fn test_standing_query_notifications() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("alerts".to_string(), 16, DistanceMetric::L2, None)
+            .await
+            .unwrap();
+        let mut far = vec![0.0; 16];
+        far[0] = 10.0;
+        service
+            .insert(collection_id, VectorDocument::new(DocumentId::new(), far))
+            .await
+            .unwrap();
+
+        let spec = StandingQuerySpec {
+            vector: vec![1.0; 16],
+            top_k: 1,
+            filter: None,
+        };
+        let info = service
+            .create_standing_query(collection_id, spec)
+            .await
+            .unwrap();
+        let mut rx = service.subscribe_standing_query(info.id).await.unwrap();
+
+        let close = VectorDocument::new(DocumentId::new(), vec![1.1; 16]);
+        let close_id = service.insert(collection_id, close).await.unwrap();
+        let matched = rx.try_recv().unwrap();
+        assert_eq!(matched.doc_id, close_id);
+        assert_eq!(matched.rank, 1);
+
+        // Not closer than the current top-1
+        let other = VectorDocument::new(DocumentId::new(), vec![2.0; 16]);
+        service.insert(collection_id, other).await.unwrap();
+        assert!(rx.try_recv().is_err());
+
+        service.delete_collection(collection_id).await.unwrap();
+        assert!(service.list_standing_queries(None).await.is_empty());
+        assert!(matches!(
+            rx.try_recv(),
+            Err(tokio::sync::broadcast::error::TryRecvError::Closed)
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_scheduled_delete_expired() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("sessions".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let now = Utc::now();
+        for expires_at in [
+            now - chrono::Duration::hours(1),
+            now + chrono::Duration::hours(1),
+        ] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                .with_metadata(serde_json::json!({ "expires_at": expires_at.to_rfc3339() }));
+            service.insert(collection_id, doc).await.unwrap();
+        }
+        let unlabelled = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(collection_id, unlabelled).await.unwrap();
+
+        let job = service
+            .create_scheduled_job(ScheduledJobSpec {
+                name: "expire sessions".to_string(),
+                schedule: "0 3 * * *".to_string(),
+                action: ScheduledAction::DeleteExpired {
+                    collection_id,
+                    field: "expires_at".to_string(),
+                },
+                enabled: true,
+            })
+            .await
+            .unwrap();
+        let next_run_at = job.next_run_at.unwrap();
+        assert_eq!(
+            service
+                .run_due_jobs(next_run_at - chrono::Duration::minutes(1))
+                .await,
+            0
+        );
+
+        assert_eq!(service.run_due_jobs(next_run_at).await, 1);
+        assert_eq!(service.get_count(collection_id).await.unwrap(), 2);
+        let job = service.get_scheduled_job(job.id).await.unwrap();
+        let run = job.last_run().unwrap();
+        assert_eq!(
+            (run.status, run.affected, run.manual),
+            (JobRunStatus::Succeeded, 1, false)
+        );
+        assert_eq!(
+            job.next_run_at,
+            Some(next_run_at + chrono::Duration::days(1))
+        );
+        // Already advanced past this run
+        assert_eq!(service.run_due_jobs(next_run_at).await, 0);
+
+        let compact = service
+            .create_scheduled_job(ScheduledJobSpec {
+                name: "compact".to_string(),
+                schedule: "@weekly".to_string(),
+                action: ScheduledAction::Compact { collection_id },
+                enabled: false,
+            })
+            .await
+            .unwrap();
+        assert!(compact.next_run_at.is_none());
+        let run = service.run_scheduled_job(compact.id).await.unwrap();
+        assert_eq!(run.status, JobRunStatus::Succeeded);
+        assert!(run.manual);
+        assert_eq!(
+            service
+                .get_scheduled_job(compact.id)
+                .await
+                .unwrap()
+                .history
+                .len(),
+            1
+        );
+
+        service.delete_collection(collection_id).await.unwrap();
+        assert!(service.list_scheduled_jobs().await.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_compliance_export_and_purge() {
+        let service = Arc::new(CollectionService::new());
+        let mut collections = Vec::new();
+        for name in ["profiles", "messages"] {
+            let collection_id = service
+                .create_collection(name.to_string(), 16, DistanceMetric::Cosine, None)
+                .await
+                .unwrap();
+            for user in ["alice", "bob"] {
+                let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                    .with_metadata(serde_json::json!({ "user_id": user }));
+                service.insert(collection_id, doc).await.unwrap();
+            }
+            collections.push(collection_id);
+        }
+        service
+            .set_compliance_signing_key(b"audit-key".to_vec())
+            .await
+            .unwrap();
+
+        let wait = |job_id: JobId| {
+            let service = Arc::clone(&service);
+            async move {
+                loop {
+                    let job = service.get_compliance_job(job_id).await.unwrap();
+                    if job.status != ComplianceStatus::Running {
+                        return job;
+                    }
+                    tokio::time::sleep(std::time::Duration::from_millis(5)).await;
+                }
+            }
+        };
+        let subject = Some(crate::compliance::SubjectFilter {
+            field: "user_id".to_string(),
+            value: serde_json::json!("alice"),
+        });
+
+        let export = service
+            .start_compliance_job(ComplianceRequest {
+                action: ComplianceAction::Export,
+                subject: subject.clone(),
+                vector_codec: VectorCodec::F16,
+            })
+            .await
+            .unwrap();
+        let export = wait(export.id).await;
+        assert_eq!(export.status, ComplianceStatus::Completed);
+        let report = export.report.unwrap();
+        assert_eq!(report.total_documents, 2);
+        assert_eq!(report.collections.len(), 2);
+        assert!(service.verify_compliance_report(&report).await);
+        let path = service.compliance_export_file(export.id).await.unwrap();
+        let contents = std::fs::read_to_string(&path).unwrap();
+        assert_eq!(contents.lines().count(), 2);
+        assert!(contents.lines().all(|line| line.contains("alice")));
+        let record: ExportRecord = serde_json::from_str(contents.lines().next().unwrap()).unwrap();
+        assert_eq!(record.vector.decode().unwrap().len(), 16);
+
+        // Restoring checks the file against the export manifest
+        let manifest = report.export_manifest.clone().unwrap();
+        assert_eq!(manifest.records, 2);
+        assert_eq!(report.export_sha256.as_ref(), Some(&manifest.sha256));
+        let restored = service
+            .create_collection("restored".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let upload = service
+            .create_upload(restored, Some(manifest))
+            .await
+            .unwrap();
+        let first_line = contents.lines().next().unwrap().len() + 1;
+        service
+            .upload_part(upload.id, 1, contents.as_bytes()[..first_line].to_vec())
+            .await
+            .unwrap();
+        let err = service.complete_upload(upload.id).await.unwrap_err();
+        assert!(matches!(err, CoreError::ValidationError(_)));
+        assert_eq!(service.get_count(restored).await.unwrap(), 0);
+        service
+            .upload_part(upload.id, 1, contents.clone().into_bytes())
+            .await
+            .unwrap();
+        let restore = service.complete_upload(upload.id).await.unwrap();
+        assert_eq!(restore.inserted, 2);
+        service.delete_collection(restored).await.unwrap();
+        std::fs::remove_file(path).unwrap();
+
+        let purge = service
+            .start_compliance_job(ComplianceRequest {
+                action: ComplianceAction::Purge,
+                subject,
+                vector_codec: VectorCodec::default(),
+            })
+            .await
+            .unwrap();
+        let mut report = wait(purge.id).await.report.unwrap();
+        assert_eq!(report.total_documents, 2);
+        for collection_id in collections {
+            assert_eq!(service.get_count(collection_id).await.unwrap(), 1);
+        }
+        assert!(service.compliance_export_file(purge.id).await.is_err());
+
+        // Tampered reports fail verification
+        report.total_documents = 0;
+        assert!(!service.verify_compliance_report(&report).await);
+    }
+
+    #[tokio::test]
+    async fn test_legal_hold_blocks_deletes() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("mail".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut ids = Vec::new();
+        for owner in ["alice", "bob"] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                .with_metadata(serde_json::json!({ "owner": owner }));
+            ids.push(service.insert(collection_id, doc).await.unwrap());
+        }
+
+        let hold = service
+            .place_legal_hold(
+                collection_id,
+                LegalHoldSpec {
+                    reason: "case 42".to_string(),
+                    filter: serde_json::json!({ "owner": "alice" }).as_object().cloned(),
+                },
+            )
+            .await
+            .unwrap();
+        assert!(matches!(
+            service.delete(collection_id, ids[0]).await,
+            Err(CoreError::UnderLegalHold { hold_id, .. }) if hold_id == hold.id
+        ));
+        assert!(matches!(
+            service.delete_collection(collection_id).await,
+            Err(CoreError::UnderLegalHold {
+                entity: "Collection",
+                ..
+            })
+        ));
+        service.delete(collection_id, ids[1]).await.unwrap();
+
+        service.release_legal_hold(hold.id).await.unwrap();
+        let holds = service.list_legal_holds(Some(collection_id)).await;
+        assert!(holds.is_empty());
+        service.delete(collection_id, ids[0]).await.unwrap();
+        service.delete_collection(collection_id).await.unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_mass_scan_flagged() {
+        let service = CollectionService::new();
+        service
+            .set_anomaly_config(AnomalyConfig {
+                window_secs: 60,
+                scan_fraction: 0.5,
+                min_documents: 5,
+            })
+            .unwrap();
+        let collection_id = service
+            .create_collection("records".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut ids = Vec::new();
+        for i in 0..10 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![i as f32 + 1.0; 16]);
+            ids.push(service.insert(collection_id, doc).await.unwrap());
+        }
+        let mut anomalies = service.subscribe_anomalies();
+
+        for doc_id in &ids[..4] {
+            service.get(collection_id, *doc_id).await.unwrap();
+        }
+        assert!(service.list_anomalies(None).is_empty());
+        service.get(collection_id, ids[4]).await.unwrap();
+
+        let event = anomalies.try_recv().unwrap();
+        assert_eq!(event.kind, AnomalyKind::MassScan);
+        assert_eq!(event.collection_id, Some(collection_id));
+        assert_eq!(event.documents_read, Some(5));
+        assert_eq!(service.list_anomalies(None).len(), 1);
+        assert!(service.list_anomalies(Some(event.id)).is_empty());
+        let recent = service.recent_anomalies(std::time::Duration::from_secs(3600));
+        assert_eq!(recent.len(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_batched_and_snapshot_reads_flagged() {
+        let service = CollectionService::new();
+        service
+            .set_anomaly_config(AnomalyConfig {
+                window_secs: 60,
+                scan_fraction: 0.5,
+                min_documents: 5,
+            })
+            .unwrap();
+        let mut collections = Vec::new();
+        for name in ["batched", "snapshotted"] {
+            let collection_id = service
+                .create_collection(name.to_string(), 16, DistanceMetric::Cosine, None)
+                .await
+                .unwrap();
+            let mut ids = Vec::new();
+            for i in 0..8 {
+                let doc = VectorDocument::new(DocumentId::new(), vec![i as f32 + 1.0; 16]);
+                ids.push(service.insert(collection_id, doc).await.unwrap());
+            }
+            collections.push((collection_id, ids));
+        }
+
+        // One batched Get of most of a collection
+        let (batched, ids) = &collections[0];
+        service.get_many(*batched, &ids[..6]).await.unwrap();
+        let flagged = service.list_anomalies(None);
+        assert_eq!(flagged.len(), 1);
+        assert_eq!(flagged[0].collection_id, Some(*batched));
+        assert_eq!(flagged[0].documents_read, Some(6));
+
+        // Downloading a snapshot reads every document in it
+        let (snapshotted, _) = &collections[1];
+        let snapshot = service.create_snapshot(*snapshotted).await.unwrap();
+        service.snapshot_file(snapshot.snapshot_id).await.unwrap();
+        let flagged = service.list_anomalies(Some(flagged[0].id));
+        assert_eq!(flagged.len(), 1);
+        assert_eq!(flagged[0].collection_id, Some(*snapshotted));
+        assert_eq!(flagged[0].documents_read, Some(8));
+        service.delete_snapshot(snapshot.snapshot_id).await.unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_ip_allowlists() {
+        let service = CollectionService::new();
+        let office: std::net::IpAddr = "10.1.2.3".parse().unwrap();
+        let home: std::net::IpAddr = "203.0.113.9".parse().unwrap();
+        assert!(service.evaluate_source_ip(home, None).await.allowed);
+
+        let spec = |networks: &[&str]| AllowlistSpec {
+            networks: networks.iter().map(|n| n.parse().unwrap()).collect(),
+            description: None,
+        };
+        service
+            .set_ip_allowlist(AllowlistScope::Tenant, spec(&["10.0.0.0/8"]))
+            .await
+            .unwrap();
+        let key_id = ApiKeyId::new();
+        let key_scope = AllowlistScope::ApiKey { key_id };
+        service
+            .set_ip_allowlist(key_scope, spec(&["10.9.0.0/16"]))
+            .await
+            .unwrap();
+
+        assert!(service.evaluate_source_ip(office, None).await.allowed);
+        assert!(!service.evaluate_source_ip(home, None).await.allowed);
+        let evaluation = service.evaluate_source_ip(office, Some(key_id)).await;
+        assert!(!evaluation.allowed);
+        assert!(evaluation.tenant.unwrap().allowed);
+
+        let allowlists = service.list_ip_allowlists().await;
+        assert_eq!(allowlists[0].scope, AllowlistScope::Tenant);
+        service.delete_ip_allowlist(key_scope).await.unwrap();
+        let evaluation = service.evaluate_source_ip(office, Some(key_id)).await;
+        assert!(evaluation.allowed);
+        assert!(service.get_ip_allowlist(key_scope).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_find_api_key() {
+        let service = CollectionService::new();
+        assert_eq!(service.find_api_key("ak_secret").await.unwrap(), None);
+
+        let repository = Arc::new(MemoryApiKeyRepository::default());
+        service
+            .set_api_key_repository(Some(repository.clone()))
+            .await;
+        let key = ApiKeyDescriptor::new(TenantId::new(), "ci".to_string(), vec![], None, None);
+        repository
+            .create(&key, &hash_api_key("ak_secret"))
+            .await
+            .unwrap();
+        assert_eq!(
+            service.find_api_key("ak_secret").await.unwrap(),
+            Some(key.key_id)
+        );
+        assert_eq!(service.find_api_key("ak_other").await.unwrap(), None);
+    }
+
+    #[tokio::test]
+    async fn test_insert_batch_reports_duplicates() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("batch".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let existing = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        let existing_id = service.insert(collection_id, existing).await.unwrap();
+
+        let repeated_id = DocumentId::new();
+        let docs = vec![
+            VectorDocument::new(repeated_id, vec![1.0; 16]),
+            VectorDocument::new(existing_id, vec![0.5; 16]),
+            VectorDocument::new(repeated_id, vec![0.5; 16]),
+            VectorDocument::new(DocumentId::new(), vec![0.0; 16]),
+        ];
+        let report = service.insert_batch(collection_id, docs).await.unwrap();
+        assert_eq!((report.inserted, report.skipped, report.failed), (1, 2, 1));
+        assert_eq!(report.ids[..3], [repeated_id, existing_id, repeated_id]);
+        assert_eq!(report.errors[0].index, 3);
+        assert_eq!(
+            report.duplicates,
+            vec![
+                DuplicateId {
+                    doc_id: repeated_id,
+                    positions: vec![0, 2],
+                    action: DuplicateAction::SkippedRepeats,
+                },
+                DuplicateId {
+                    doc_id: existing_id,
+                    positions: vec![1],
+                    action: DuplicateAction::KeptExisting,
+                },
+            ]
+        );
+        let stored = service.get(collection_id, repeated_id).await.unwrap();
+        assert_eq!(stored.unwrap().vector, vec![1.0; 16]);
+    }
+
+    #[tokio::test]
+    async fn test_delete_by_ids_and_filter() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("prune".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut ids = Vec::new();
+        for (source, owner) in [
+            ("a.md", "alice"),
+            ("a.md", "bob"),
+            ("b.md", "bob"),
+            ("c.md", "bob"),
+        ] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                .with_metadata(serde_json::json!({ "source": source, "owner": owner }));
+            ids.push(service.insert(collection_id, doc).await.unwrap());
+        }
+        service
+            .place_legal_hold(
+                collection_id,
+                LegalHoldSpec {
+                    reason: "case 42".to_string(),
+                    filter: serde_json::json!({ "owner": "alice" }).as_object().cloned(),
+                },
+            )
+            .await
+            .unwrap();
+
+        let filter: MetadataFilter =
+            serde_json::from_value(serde_json::json!({ "source": "a.md" })).unwrap();
+        let report = service
+            .delete_by_filter(collection_id, &filter)
+            .await
+            .unwrap();
+        assert_eq!((report.deleted, report.held.clone()), (1, vec![ids[0]]));
+
+        let unknown = DocumentId::new();
+        let report = service
+            .delete_many(collection_id, &[ids[2], unknown, ids[2]])
+            .await
+            .unwrap();
+        assert_eq!((report.deleted, report.missing), (1, vec![unknown]));
+        assert!(report.failed.is_empty());
+        assert_eq!(service.get_count(collection_id).await.unwrap(), 2);
+
+        // Batch deletes may exceed the insert batch size
+        let unknown: Vec<_> = (0..MAX_BATCH_SIZE + 1).map(|_| DocumentId::new()).collect();
+        let report = service.delete_many(collection_id, &unknown).await.unwrap();
+        assert_eq!(report.missing.len(), MAX_BATCH_SIZE + 1);
+        let oversized = vec![unknown[0]; MAX_DELETE_BATCH_SIZE + 1];
+        assert!(service
+            .delete_many(collection_id, &oversized)
+            .await
+            .is_err());
+        assert!(service
+            .delete_by_filter(collection_id, &MetadataFilter::default())
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_scroll_visits_every_document_once() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("scroll".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut ids = HashSet::new();
+        for i in 0..5 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                .with_metadata(serde_json::json!({ "even": i % 2 == 0 }));
+            ids.insert(service.insert(collection_id, doc).await.unwrap());
+        }
+
+        let mut seen = HashSet::new();
+        let mut cursor = None;
+        loop {
+            let page = service
+                .scroll_documents(
+                    collection_id,
+                    ListOrder::default(),
+                    None,
+                    None,
+                    cursor.as_deref(),
+                    2,
+                )
+                .await
+                .unwrap();
+            for doc in page.items {
+                assert!(seen.insert(doc.doc_id));
+            }
+            // Writes between pages do not cause duplicates or gaps
+            if seen.len() == 2 {
+                let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+                ids.insert(service.insert(collection_id, doc).await.unwrap());
+            }
+            cursor = page.next_cursor;
+            if cursor.is_none() {
+                break;
+            }
+        }
+        assert_eq!(seen, ids);
+
+        let filter: MetadataFilter =
+            serde_json::from_value(serde_json::json!({ "even": true })).unwrap();
+        let page = service
+            .scroll_documents(
+                collection_id,
+                ListOrder::default(),
+                Some(&filter),
+                None,
+                None,
+                10,
+            )
+            .await
+            .unwrap();
+        assert_eq!(page.items.len(), 3);
+
+        // Partitions split the walk without overlap
+        let mut partitioned = HashSet::new();
+        for index in 0..3 {
+            let page = service
+                .scroll_documents(
+                    collection_id,
+                    ListOrder::default(),
+                    None,
+                    Some(Partition { index, count: 3 }),
+                    None,
+                    100,
+                )
+                .await
+                .unwrap();
+            for doc in page.items {
+                assert!(partitioned.insert(doc.doc_id));
+            }
+        }
+        assert_eq!(partitioned, ids);
+    }
+
+    #[tokio::test]
+    async fn test_update_collection_settings() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(collection_id, doc).await.unwrap();
+
+        let update = CollectionUpdate {
+            description: Some("Product manuals".to_string()),
+            metadata: Some(serde_json::json!({ "team": "support" })),
+            hnsw_m: Some(16),
+            search_defaults: Some(SearchDefaults {
+                top_k: Some(5),
+                ..Default::default()
+            }),
+            ..Default::default()
+        };
+        let updated = service
+            .update_collection(collection_id, update)
+            .await
+            .unwrap();
+        assert_eq!(updated.description.as_deref(), Some("Product manuals"));
+        assert_eq!(updated.hnsw_m, 16);
+        assert_eq!(updated.hnsw_ef_construction, 200);
+        let stored = service.get_collection(collection_id).await.unwrap();
+        assert_eq!(
+            stored.metadata,
+            Some(serde_json::json!({ "team": "support" }))
+        );
+        let defaults = service.search_defaults(collection_id).await.unwrap();
+        assert_eq!(defaults.top_k, Some(5));
+        assert_eq!(service.get_count(collection_id).await.unwrap(), 1);
+
+        // Empty values clear; invalid updates change nothing
+        let clear = CollectionUpdate {
+            description: Some(String::new()),
+            metadata: Some(serde_json::json!({})),
+            ..Default::default()
+        };
+        let cleared = service
+            .update_collection(collection_id, clear)
+            .await
+            .unwrap();
+        assert!(cleared.description.is_none() && cleared.metadata.is_none());
+        let too_small = CollectionUpdate {
+            max_doc_count: Some(0),
+            hnsw_m: Some(8),
+            ..Default::default()
+        };
+        assert!(service
+            .update_collection(collection_id, too_small)
+            .await
+            .is_err());
+        let at_count = CollectionUpdate {
+            max_doc_count: Some(1),
+            ..Default::default()
+        };
+        assert!(service
+            .update_collection(collection_id, at_count)
+            .await
+            .is_ok());
+        assert_eq!(
+            service.get_collection(collection_id).await.unwrap().hnsw_m,
+            16
+        );
+    }
+
+    #[tokio::test]
+    async fn test_prime_replays_query_patterns() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(collection_id, doc).await.unwrap();
+        for _ in 0..2 {
+            service
+                .query(collection_id, vec![1.0; 16], 5)
+                .await
+                .unwrap();
+        }
+        service
+            .query(collection_id, vec![0.5; 16], 5)
+            .await
+            .unwrap();
+
+        let patterns = service.query_patterns(collection_id, 10).await.unwrap();
+        assert_eq!(patterns.len(), 2);
+        assert_eq!(patterns[0].query_vector, vec![1.0; 16]);
+        assert_eq!(patterns[0].count, 2);
+
+        let report = service.prime(collection_id, None, 1).await.unwrap();
+        assert_eq!((report.queries, report.failed), (1, 0));
+        // Replays are not logged
+        let after = service.query_patterns(collection_id, 10).await.unwrap();
+        assert_eq!(after[0].count, 2);
+
+        // Saved patterns, e.g. from before a restart; bad ones are skipped
+        let mut saved = patterns.clone();
+        saved[1].query_vector = vec![1.0; 8];
+        let report = service.prime(collection_id, Some(saved), 10).await.unwrap();
+        assert_eq!((report.queries, report.failed), (2, 1));
+        assert!(service.query_patterns(collection_id, 0).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_create_collection_with_index_options() {
+        let service = CollectionService::new();
+        let flat = IndexOptions {
+            index_type: IndexType::Flat,
+            ..Default::default()
+        };
+        let flat_id = service
+            .create_collection_with_index(
+                "flat".to_string(),
+                16,
+                DistanceMetric::Cosine,
+                None,
+                flat,
+            )
+            .await
+            .unwrap();
+        let descriptor = service.get_collection(flat_id).await.unwrap();
+        assert_eq!(descriptor.index_type, IndexType::Flat);
+        assert_eq!(
+            IndexKind::for_collection(&descriptor),
+            IndexKind::BruteForce
+        );
+
+        let hnsw = IndexOptions {
+            index_type: IndexType::Hnsw,
+            hnsw_m: Some(16),
+            hnsw_ef_construction: Some(100),
+            ef_search: Some(64),
+        };
+        let hnsw_id = service
+            .create_collection_with_index(
+                "hnsw".to_string(),
+                16,
+                DistanceMetric::Cosine,
+                None,
+                hnsw,
+            )
+            .await
+            .unwrap();
+        let descriptor = service.get_collection(hnsw_id).await.unwrap();
+        assert_eq!(descriptor.index_type, IndexType::Hnsw);
+        assert_eq!(
+            (descriptor.hnsw_m, descriptor.hnsw_ef_construction),
+            (16, 100)
+        );
+        let defaults = service.search_defaults(hnsw_id).await.unwrap();
+        assert_eq!(defaults.ef_search, Some(64));
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16]);
+        service.insert(hnsw_id, doc).await.unwrap();
+        assert_eq!(
+            service
+                .query(hnsw_id, vec![0.5; 16], 1)
+                .await
+                .unwrap()
+                .len(),
+            1
+        );
+
+        // HNSW parameters on a flat index are refused
+        let invalid = IndexOptions {
+            index_type: IndexType::Flat,
+            ef_search: Some(64),
+            ..Default::default()
+        };
+        assert!(service
+            .create_collection_with_index(
+                "bad".to_string(),
+                16,
+                DistanceMetric::Cosine,
+                None,
+                invalid
+            )
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_get_coalescing() {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut doc_ids = Vec::new();
+        for i in 0..8 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![i as f32 + 1.0; 16]);
+            doc_ids.push(doc.doc_id);
+            service.insert(collection_id, doc).await.unwrap();
+        }
+        // Unknown ID
+        doc_ids.push(DocumentId::new());
+
+        service
+            .set_get_coalescing(CoalescingConfig {
+                enabled: true,
+                window_ms: 20,
+                ..Default::default()
+            })
+            .unwrap();
+        let mut gets = tokio::task::JoinSet::new();
+        for doc_id in doc_ids.clone() {
+            let service = Arc::clone(&service);
+            gets.spawn(async move {
+                let doc = service.get(collection_id, doc_id).await.unwrap();
+                (doc_id, doc)
+            });
+        }
+        let mut found = 0;
+        while let Some(result) = gets.join_next().await {
+            let (doc_id, doc) = result.unwrap();
+            if let Some(doc) = doc {
+                assert_eq!(doc.doc_id, doc_id);
+                found += 1;
+            }
+        }
+        assert_eq!(found, 8);
+        let stats = service.get_coalescing_stats();
+        assert_eq!(stats.requests, 9);
+        assert!(stats.batches < 9);
+
+        // Errors reach every Get of a batch
+        assert!(service
+            .get(CollectionId::new(), DocumentId::new())
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_read_only_collection() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16]);
+        service.insert(collection_id, doc.clone()).await.unwrap();
+
+        service.set_read_only(collection_id, true).await.unwrap();
+        assert!(service.is_read_only(collection_id).await);
+        // Kept in the descriptor, which the repository persists
+        let descriptor = service.get_collection(collection_id).await.unwrap();
+        assert!(descriptor.read_only);
+        let other = VectorDocument::new(DocumentId::new(), vec![0.25; 16]);
+        assert!(matches!(
+            service.insert(collection_id, other.clone()).await,
+            Err(CoreError::ReadOnly { .. })
+        ));
+        assert!(matches!(
+            service.delete(collection_id, doc.doc_id).await,
+            Err(CoreError::ReadOnly { .. })
+        ));
+        assert!(matches!(
+            service
+                .upsert_batch(collection_id, vec![other.clone()])
+                .await,
+            Err(CoreError::ReadOnly { .. })
+        ));
+        assert!(matches!(
+            service.delete_collection(collection_id).await,
+            Err(CoreError::ReadOnly { .. })
+        ));
+        // Reads still work
+        assert!(service
+            .get(collection_id, doc.doc_id)
+            .await
+            .unwrap()
+            .is_some());
+        assert_eq!(
+            service
+                .query(collection_id, vec![0.5; 16], 1)
+                .await
+                .unwrap()
+                .len(),
+            1
+        );
+
+        service.set_read_only(collection_id, false).await.unwrap();
+        service.insert(collection_id, other).await.unwrap();
+        assert!(service
+            .set_read_only(CollectionId::new(), true)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_field_index_filters() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let insert = |i: usize| {
+            let mut vector = vec![1.0; 16];
+            vector[i % 16] += i as f32;
+            VectorDocument::new(DocumentId::new(), vector)
+                .with_metadata(serde_json::json!({ "user_id": format!("u{}", i % 10) }))
+        };
+        for i in 0..20 {
+            service.insert(collection_id, insert(i)).await.unwrap();
+        }
+        let info = service
+            .create_field_index(collection_id, "user_id", FieldIndexType::Keyword)
+            .await
+            .unwrap();
+        assert_eq!((info.values, info.documents), (10, 20));
+        // Documents written afterwards are indexed too
+        let late = insert(27);
+        service.insert(collection_id, late.clone()).await.unwrap();
+
+        let filter = MetadataFilter::eq("user_id", "u7");
+        let found = service
+            .find_documents(collection_id, &filter, None, 10)
+            .await
+            .unwrap();
+        assert_eq!(found.len(), 3);
+        let count = service
+            .count_documents(collection_id, Some(&filter), true)
+            .await
+            .unwrap();
+        assert_eq!(count.count, 3);
+
+        // Searches with an indexed default filter score the matches exactly
+        let defaults = SearchDefaults {
+            filter: Some(filter.clone()),
+            ..Default::default()
+        };
+        service
+            .set_search_defaults(collection_id, defaults)
+            .await
+            .unwrap();
+        let results = service
+            .query(collection_id, late.vector.clone(), 10)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 3);
+        assert_eq!(results[0].doc_id, late.doc_id);
+
+        let report = service
+            .delete_by_filter(collection_id, &filter)
+            .await
+            .unwrap();
+        assert_eq!(report.deleted, 3);
+        let indexes = service.list_field_indexes(collection_id).await.unwrap();
+        assert_eq!((indexes[0].values, indexes[0].documents), (9, 18));
+
+        service
+            .delete_field_index(collection_id, "user_id")
+            .await
+            .unwrap();
+        assert!(service
+            .delete_field_index(collection_id, "user_id")
+            .await
+            .is_err());
+        assert!(service
+            .list_field_indexes(collection_id)
+            .await
+            .unwrap()
+            .is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_whoami() {
+        let service = CollectionService::new();
+        let tenant_id = TenantId::new();
+        service.set_tenant_id(tenant_id).await;
+        let frozen = service
+            .create_collection("frozen".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let held = service
+            .create_collection("held".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        service.set_read_only(frozen, true).await.unwrap();
+        service
+            .place_legal_hold(
+                held,
+                LegalHoldSpec {
+                    reason: "case 12".to_string(),
+                    filter: None,
+                },
+            )
+            .await
+            .unwrap();
+
+        let identity = service.whoami(None).await.unwrap();
+        assert_eq!(identity.principal, Principal::Anonymous);
+        assert_eq!(identity.tenant_id, Some(tenant_id));
+        assert!(identity.has_scope(SCOPE_WRITE));
+        let permissions: Vec<(bool, bool, bool)> = identity
+            .collections
+            .iter()
+            .map(|c| (c.read, c.write, c.delete))
+            .collect();
+        assert_eq!(permissions, vec![(true, false, false), (true, true, false)]);
+
+        // Impersonated requests only read
+        let request = ImpersonationRequest {
+            tenant_id: tenant_id.to_string(),
+            actor: Some("support@example.com".to_string()),
+            reason: None,
+            method: "GET".to_string(),
+            path: "/api/v1/whoami".to_string(),
+            source_ip: None,
+            read_only: true,
+        };
+        let identity = service.whoami(Some(&request)).await.unwrap();
+        assert!(!identity.has_scope(SCOPE_WRITE));
+        assert!(identity.collections.iter().all(|c| c.read && !c.write));
+    }
+
+    #[derive(Default)]
+    struct MemoryTenantCatalog(std::sync::Mutex<Vec<TenantDescriptor>>);
+
+    #[async_trait]
+    impl TenantCatalog for MemoryTenantCatalog {
+        async fn list(&self) -> CoreResult<Vec<TenantDescriptor>> {
+            Ok(self.0.lock().unwrap().clone())
+        }
+
+        async fn get(&self, tenant_id: TenantId) -> CoreResult<Option<TenantDescriptor>> {
+            let tenants = self.0.lock().unwrap();
+            Ok(tenants.iter().find(|t| t.tenant_id == tenant_id).cloned())
+        }
+
+        async fn create(&self, tenant: &TenantDescriptor) -> CoreResult<()> {
+            if tenant.name == "unwritable" {
+                return Err(CoreError::internal("disk full"));
+            }
+            self.0.lock().unwrap().push(tenant.clone());
+            Ok(())
+        }
+
+        async fn update(&self, tenant: &TenantDescriptor) -> CoreResult<()> {
+            let mut tenants = self.0.lock().unwrap();
+            match tenants.iter_mut().find(|t| t.tenant_id == tenant.tenant_id) {
+                Some(existing) => {
+                    *existing = tenant.clone();
+                    Ok(())
+                }
+                None => Err(CoreError::not_found("tenant", tenant.tenant_id.to_string())),
+            }
+        }
+
+        async fn delete(&self, tenant_id: TenantId) -> CoreResult<()> {
+            self.0.lock().unwrap().retain(|t| t.tenant_id != tenant_id);
+            Ok(())
+        }
+    }
+
+    #[tokio::test]
+    async fn test_batch_create_tenants() {
+        let service = CollectionService::new();
+        let request = |name: &str, slug: &str| CreateTenantRequest {
+            name: name.to_string(),
+            slug: slug.to_string(),
+            quotas: TenantQuota::default(),
+            metadata: None,
+        };
+        assert!(service
+            .batch_create_tenants(vec![request("Acme", "acme")])
+            .await
+            .is_err());
+        let catalog = Arc::new(MemoryTenantCatalog::default());
+        service.set_tenant_catalog(Some(catalog.clone())).await;
+
+        let mut requests: Vec<CreateTenantRequest> = (0..40)
+            .map(|i| request(&format!("Tenant {}", i), &format!("tenant-{}", i)))
+            .collect();
+        requests.push(request("Bad", "Not A Slug"));
+        requests.push(request("unwritable", "unwritable"));
+        let report = service
+            .batch_create_tenants(requests.clone())
+            .await
+            .unwrap();
+        assert_eq!((report.created, report.existing, report.failed), (40, 0, 2));
+        assert!(report
+            .results
+            .iter()
+            .enumerate()
+            .all(|(i, result)| result.index == i));
+        assert_eq!(report.results[40].status, TenantCreateStatus::Failed);
+
+        // Sending the batch again creates nothing twice
+        requests.truncate(40);
+        requests.push(request("Renamed", "tenant-0"));
+        requests.push(request("Late", "late"));
+        requests.push(request("Late again", "late"));
+        let retry = service.batch_create_tenants(requests).await.unwrap();
+        assert_eq!((retry.created, retry.existing, retry.failed), (1, 40, 2));
+        assert_eq!(retry.results[0].tenant_id, report.results[0].tenant_id);
+        assert_eq!(catalog.list().await.unwrap().len(), 41);
+    }
+
+    #[tokio::test]
+    async fn test_list_tenants() {
+        let service = CollectionService::new();
+        let catalog = Arc::new(MemoryTenantCatalog::default());
+        service.set_tenant_catalog(Some(catalog.clone())).await;
+        let start = Utc::now() - chrono::Duration::hours(1);
+        for (i, tier) in ["free", "enterprise", "free", "enterprise", "enterprise"]
+            .iter()
+            .enumerate()
+        {
+            let mut tenant = TenantDescriptor::new(format!("Tenant {}", 4 - i), format!("t{}", i));
+            tenant.created_at = start + chrono::Duration::minutes(i as i64);
+            tenant.metadata = serde_json::json!({ "tier": tier, "seats": i * 10 });
+            if i == 3 {
+                tenant.transition_to(TenantStatus::Suspended);
+            }
+            catalog.create(&tenant).await.unwrap();
+        }
+
+        let mut filter = TenantFilter::default();
+        filter
+            .metadata
+            .insert("tier".to_string(), serde_json::json!("enterprise"));
+        let by_name = ListOrder {
+            sort: SortField::Name,
+            direction: SortDirection::Asc,
+        };
+        let first = service
+            .list_tenants(&filter, by_name, None, 2)
+            .await
+            .unwrap();
+        let slugs: Vec<_> = first.items.iter().map(|t| t.slug.as_str()).collect();
+        assert_eq!(slugs, ["t4", "t3"]);
+        let rest = service
+            .list_tenants(&filter, by_name, first.next_cursor.as_deref(), 2)
+            .await
+            .unwrap();
+        assert_eq!(rest.items.len(), 1);
+        assert!(rest.next_cursor.is_none());
+
+        filter.status = Some(TenantStatus::Active);
+        filter.created_after = Some(start + chrono::Duration::minutes(2));
+        let page = service
+            .list_tenants(&filter, ListOrder::default(), None, 10)
+            .await
+            .unwrap();
+        assert_eq!(page.items.len(), 0);
+        filter.status = Some(TenantStatus::Provisioning);
+        let page = service
+            .list_tenants(&filter, ListOrder::default(), None, 10)
+            .await
+            .unwrap();
+        assert_eq!(page.items[0].slug, "t4");
+    }
+
+    #[tokio::test]
+    async fn test_suspend_tenant() {
+        let service = CollectionService::new();
+        let catalog = Arc::new(MemoryTenantCatalog::default());
+        service.set_tenant_catalog(Some(catalog.clone())).await;
+        let mut tenant = TenantDescriptor::new("Acme", "acme");
+        tenant.transition_to(TenantStatus::Active);
+        catalog.create(&tenant).await.unwrap();
+        let tenant_id = tenant.tenant_id;
+        service.set_tenant_id(tenant_id).await;
+
+        let suspended = service.suspend_tenant(tenant_id).await.unwrap();
+        assert_eq!(suspended.status, TenantStatus::Suspended);
+        assert!(service.is_tenant_suspended().await);
+        assert!(service.suspend_tenant(tenant_id).await.is_err());
+
+        let resumed = service.resume_tenant(tenant_id).await.unwrap();
+        assert_eq!(resumed.status, TenantStatus::Active);
+        assert!(!service.is_tenant_suspended().await);
+        assert!(service.resume_tenant(tenant_id).await.is_err());
+
+        // Suspended elsewhere: picked up when reloaded
+        let mut tenant = catalog.get(tenant_id).await.unwrap().unwrap();
+        tenant.suspend().unwrap();
+        catalog.update(&tenant).await.unwrap();
+        assert!(!service.is_tenant_suspended().await);
+        assert!(service.load_tenant_suspension().await.unwrap());
+        assert!(service.is_tenant_suspended().await);
+
+        assert!(service.suspend_tenant(TenantId::new()).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_soft_delete_tenant() {
+        let service = CollectionService::new();
+        let catalog = Arc::new(MemoryTenantCatalog::default());
+        service.set_tenant_catalog(Some(catalog.clone())).await;
+        let request = CreateTenantRequest {
+            name: "Acme".to_string(),
+            slug: "acme".to_string(),
+            quotas: TenantQuota::default(),
+            metadata: Some(serde_json::json!({ "tier": "enterprise" })),
+        };
+        let report = service
+            .batch_create_tenants(vec![request.clone()])
+            .await
+            .unwrap();
+        let tenant_id = report.results[0].tenant_id.unwrap();
+
+        let deletion = service
+            .delete_tenant(tenant_id, &DeleteTenantOptions::default())
+            .await
+            .unwrap()
+            .unwrap();
+        assert_eq!(
+            deletion.purge_after - deletion.deleted_at,
+            chrono::Duration::days(30)
+        );
+        let tenant = catalog.get(tenant_id).await.unwrap().unwrap();
+        assert_eq!(tenant.status, TenantStatus::Decommissioned);
+        assert_eq!(tenant.updated_at, deletion.deleted_at);
+        assert!(service
+            .delete_tenant(tenant_id, &DeleteTenantOptions::default())
+            .await
+            .is_err());
+        // The slug stays taken while the tenant can be restored
+        let retry = service.batch_create_tenants(vec![request]).await.unwrap();
+        assert_eq!(retry.failed, 1);
+
+        let restored = service.undelete_tenant(tenant_id).await.unwrap();
+        assert_eq!(restored.status, TenantStatus::Active);
+        assert_eq!(
+            restored.metadata,
+            serde_json::json!({ "tier": "enterprise" })
+        );
+        assert!(service.undelete_tenant(tenant_id).await.is_err());
+
+        // Purged once the retention has passed
+        let mut tenant = catalog.get(tenant_id).await.unwrap().unwrap();
+        tenant.soft_delete(chrono::Duration::zero()).unwrap();
+        catalog.update(&tenant).await.unwrap();
+        assert_eq!(service.purge_deleted_tenants().await.unwrap(), 1);
+        assert!(catalog.get(tenant_id).await.unwrap().is_none());
+
+        let invalid = DeleteTenantOptions {
+            mode: TenantDeleteMode::Hard,
+            retention_days: Some(7),
+        };
+        assert!(matches!(
+            service.delete_tenant(tenant_id, &invalid).await,
+            Err(CoreError::ValidationError(_))
+        ));
+    }
+
+    #[derive(Default)]
+    struct MemoryApiKeyRepository(std::sync::Mutex<Vec<(ApiKeyDescriptor, String)>>);
+
+    #[async_trait]
+    impl ApiKeyRepository for MemoryApiKeyRepository {
+        async fn create(&self, api_key: &ApiKeyDescriptor, key_hash: &str) -> CoreResult<()> {
+            let mut keys = self.0.lock().unwrap();
+            keys.push((api_key.clone(), key_hash.to_string()));
+            Ok(())
+        }
+
+        async fn get(&self, key_id: ApiKeyId) -> CoreResult<Option<ApiKeyDescriptor>> {
+            let keys = self.0.lock().unwrap();
+            Ok(keys
+                .iter()
+                .find(|(key, _)| key.key_id == key_id)
+                .map(|(key, _)| key.clone()))
+        }
+
+        async fn get_by_hash(&self, key_hash: &str) -> CoreResult<Option<ApiKeyDescriptor>> {
+            let keys = self.0.lock().unwrap();
+            Ok(keys
+                .iter()
+                .find(|(_, hash)| hash == key_hash)
+                .map(|(key, _)| key.clone()))
+        }
+
+        async fn list_by_tenant(&self, tenant_id: TenantId) -> CoreResult<Vec<ApiKeyDescriptor>> {
+            let keys = self.0.lock().unwrap();
+            Ok(keys
+                .iter()
+                .filter(|(key, _)| key.tenant_id == tenant_id)
+                .map(|(key, _)| key.clone())
+                .collect())
+        }
+
+        async fn delete(&self, key_id: ApiKeyId) -> CoreResult<()> {
+            self.0
+                .lock()
+                .unwrap()
+                .retain(|(key, _)| key.key_id != key_id);
+            Ok(())
+        }
+
+        async fn set_expires_at(
+            &self,
+            key_id: ApiKeyId,
+            expires_at: Option<chrono::DateTime<Utc>>,
+        ) -> CoreResult<()> {
+            let mut keys = self.0.lock().unwrap();
+            for (key, _) in keys.iter_mut().filter(|(key, _)| key.key_id == key_id) {
+                key.expires_at = expires_at;
+            }
+            Ok(())
+        }
+
+        async fn update_last_used(&self, _key_id: ApiKeyId) -> CoreResult<()> {
+            Ok(())
+        }
+    }
+
+    #[tokio::test]
+    async fn test_rotate_api_key() {
+        let service = CollectionService::new();
+        let repository = Arc::new(MemoryApiKeyRepository::default());
+        service
+            .set_api_key_repository(Some(repository.clone()))
+            .await;
+        let tenant_id = TenantId::new();
+        let key = ApiKeyDescriptor::new(
+            tenant_id,
+            "ci".to_string(),
+            vec!["collection::read".to_string()],
+            None,
+            None,
+        );
+        repository.create(&key, "old-hash").await.unwrap();
+
+        let grace = RotateApiKeyRequest {
+            grace_period_secs: 3_600,
+        };
+        assert!(matches!(
+            service
+                .rotate_api_key(TenantId::new(), key.key_id, &grace)
+                .await,
+            Err(CoreError::NotFound { .. })
+        ));
+        let too_long = RotateApiKeyRequest {
+            grace_period_secs: MAX_KEY_ROTATION_GRACE_SECS + 1,
+        };
+        assert!(service
+            .rotate_api_key(tenant_id, key.key_id, &too_long)
+            .await
+            .is_err());
+
+        let rotated = service
+            .rotate_api_key(tenant_id, key.key_id, &grace)
+            .await
+            .unwrap();
+        assert_eq!(rotated.previous_key_id, key.key_id);
+        assert_eq!(rotated.descriptor.name, "ci");
+        let new_key = repository
+            .get_by_hash(&hash_api_key(&rotated.api_key))
+            .await
+            .unwrap()
+            .unwrap();
+        assert_eq!(new_key.key_id, rotated.descriptor.key_id);
+        // The old key works until the grace period ends
+        let old_key = repository.get(key.key_id).await.unwrap().unwrap();
+        assert!(!old_key.is_expired());
+        assert_eq!(old_key.expires_at, rotated.previous_expires_at);
+
+        // Without a grace period the old key is revoked at once
+        let again = service
+            .rotate_api_key(tenant_id, new_key.key_id, &RotateApiKeyRequest::default())
+            .await
+            .unwrap();
+        assert!(again.previous_expires_at.is_none());
+        assert!(repository.get(new_key.key_id).await.unwrap().is_none());
+        assert_eq!(repository.list_by_tenant(tenant_id).await.unwrap().len(), 2);
+    }
+
+    #[tokio::test]
+    async fn test_clone_collection() {
+        let service = CollectionService::new();
+        let source = service
+            .create_collection_with_index(
+                "products".to_string(),
+                16,
+                DistanceMetric::Cosine,
+                None,
+                IndexOptions {
+                    index_type: IndexType::Hnsw,
+                    hnsw_m: Some(24),
+                    ..Default::default()
+                },
+            )
+            .await
+            .unwrap();
+        let mut ids = Vec::new();
+        for i in 0..10 {
+            let mut vector = vec![1.0; 16];
+            vector[i] = 2.0;
+            let doc = VectorDocument::new(DocumentId::new(), vector)
+                .with_metadata(serde_json::json!({ "lang": if i < 4 { "en" } else { "de" } }));
+            ids.push(doc.doc_id);
+            service.insert(source, doc).await.unwrap();
+        }
+        service
+            .create_field_index(source, "lang", FieldIndexType::Keyword)
+            .await
+            .unwrap();
+
+        let report = service
+            .clone_collection(
+                source,
+                "products-staging".to_string(),
+                CloneOptions {
+                    filter: Some(MetadataFilter::eq("lang", "en")),
+                    ..Default::default()
+                },
+            )
+            .await
+            .unwrap();
+        assert_eq!(report.documents, 4);
+        let copy = service.get_collection(report.collection_id).await.unwrap();
+        assert_eq!((copy.index_type, copy.hnsw_m), (IndexType::Hnsw, 24));
+        assert!(service
+            .get(report.collection_id, ids[0])
+            .await
+            .unwrap()
+            .is_some());
+        assert!(service
+            .get(report.collection_id, ids[9])
+            .await
+            .unwrap()
+            .is_none());
+        let indexes = service
+            .list_field_indexes(report.collection_id)
+            .await
+            .unwrap();
+        assert_eq!(
+            (indexes[0].field.as_str(), indexes[0].documents),
+            ("lang", 4)
+        );
+
+        // Schema only
+        let empty = service
+            .clone_collection(
+                source,
+                "products-empty".to_string(),
+                CloneOptions {
+                    schema_only: true,
+                    ..Default::default()
+                },
+            )
+            .await
+            .unwrap();
+        assert_eq!(service.get_count(empty.collection_id).await.unwrap(), 0);
+        assert!(matches!(
+            service
+                .clone_collection(
+                    CollectionId::new(),
+                    "missing".to_string(),
+                    CloneOptions::default()
+                )
+                .await,
+            Err(CoreError::NotFound { .. })
+        ));
+        assert_eq!(service.get_count(source).await.unwrap(), 10);
+    }
+
+    #[tokio::test]
+    async fn test_snapshot_and_restore() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16])
+            .with_metadata(serde_json::json!({ "lang": "en" }));
+        service.insert(collection_id, doc.clone()).await.unwrap();
+        let update = CollectionUpdate {
+            description: Some("Manuals".to_string()),
+            ..Default::default()
+        };
+        service
+            .update_collection(collection_id, update)
+            .await
+            .unwrap();
+
+        let snapshot = service.create_snapshot(collection_id).await.unwrap();
+        assert_eq!(snapshot.manifest.records, 1);
+        assert_eq!(service.list_snapshots(Some(collection_id)).await.len(), 1);
+        assert!(service
+            .list_snapshots(Some(CollectionId::new()))
+            .await
+            .is_empty());
+
+        // Later writes are not in the snapshot
+        let later = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(collection_id, later).await.unwrap();
+
+        let report = service
+            .restore_snapshot(snapshot.snapshot_id, Some("docs-restored".to_string()))
+            .await
+            .unwrap();
+        assert_eq!(report.documents, 1);
+        let restored = service.get_collection(report.collection_id).await.unwrap();
+        assert_eq!(restored.description.as_deref(), Some("Manuals"));
+        let docs = service.list_documents(report.collection_id).await.unwrap();
+        assert_eq!(docs.len(), 1);
+        assert_eq!(docs[0].doc_id, doc.doc_id);
+        assert_eq!(docs[0].vector, doc.vector);
+        assert_eq!(docs[0].metadata, doc.metadata);
+
+        // An invalid name is refused; a corrupted file is not restored
+        assert!(service
+            .restore_snapshot(snapshot.snapshot_id, Some("../docs".to_string()))
+            .await
+            .is_err());
+        std::fs::write(snapshot_path(snapshot.snapshot_id), b"{}\n").unwrap();
+        let before = service.list_collections().await.unwrap().len();
+        assert!(service
+            .restore_snapshot(snapshot.snapshot_id, None)
+            .await
+            .is_err());
+        assert_eq!(service.list_collections().await.unwrap().len(), before);
+
+        // Snapshots are listed again after a restart
+        let restarted = CollectionService::new();
+        restarted.load_snapshots().await.unwrap();
+        let reloaded = restarted.get_snapshot(snapshot.snapshot_id).await.unwrap();
+        assert_eq!(reloaded.manifest.records, 1);
+        assert_eq!(reloaded.collection.description.as_deref(), Some("Manuals"));
+
+        service.delete_snapshot(snapshot.snapshot_id).await.unwrap();
+        assert!(service.get_snapshot(snapshot.snapshot_id).await.is_err());
+        let restarted = CollectionService::new();
+        restarted.load_snapshots().await.unwrap();
+        assert!(restarted.get_snapshot(snapshot.snapshot_id).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_adaptive_search_raises_ef_with_headroom() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("docs".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(collection_id, doc).await.unwrap();
+
+        let config = AdaptiveSearchConfig {
+            latency_slo_ms: 60_000.0,
+            min_ef_search: 16,
+            max_ef_search: 512,
+            window: 20,
+        };
+        let status = service
+            .enable_adaptive_search(collection_id, config)
+            .await
+            .unwrap();
+        assert_eq!(status.ef_search, DEFAULT_EF_SEARCH);
+        for _ in 0..20 {
+            service
+                .query(collection_id, vec![1.0; 16], 1)
+                .await
+                .unwrap();
+        }
+        let status = service.adaptive_search_status(collection_id).await.unwrap();
+        assert_eq!(status.ef_search, 160);
+        assert_eq!(status.adjustments, 1);
+
+        service
+            .disable_adaptive_search(collection_id)
+            .await
+            .unwrap();
+        assert!(service.adaptive_search_status(collection_id).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_find_documents_by_metadata() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("chunks".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        for (parent, chunk) in [("doc_42", 2), ("doc_7", 0), ("doc_42", 0), ("doc_42", 1)] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                .with_metadata(serde_json::json!({ "parent": parent, "chunk_index": chunk }));
+            service.insert(collection_id, doc).await.unwrap();
+        }
+
+        let filter: MetadataFilter =
+            serde_json::from_value(serde_json::json!({ "parent": "doc_42" })).unwrap();
+        let sort = MetadataSort {
+            field: "chunk_index".to_string(),
+            direction: SortDirection::Asc,
+        };
+        let docs = service
+            .find_documents(collection_id, &filter, Some(&sort), 10)
+            .await
+            .unwrap();
+        let chunks: Vec<_> = docs
+            .iter()
+            .map(|d| {
+                d.metadata.as_ref().unwrap()["chunk_index"]
+                    .as_u64()
+                    .unwrap()
+            })
+            .collect();
+        assert_eq!(chunks, vec![0, 1, 2]);
+
+        let docs = service
+            .find_documents(collection_id, &filter, Some(&sort), 1)
+            .await
+            .unwrap();
+        assert_eq!(docs.len(), 1);
+
+        // The tenant's filter clause limit applies
+        let mut policy = service.collection_policy().await;
+        policy.limits.max_filter_clauses = Some(1);
+        service.set_collection_policy(policy).await.unwrap();
+        let filter: MetadataFilter =
+            serde_json::from_value(serde_json::json!({ "parent": "doc_42", "chunk_index": 0 }))
+                .unwrap();
+        assert!(matches!(
+            service
+                .find_documents(collection_id, &filter, None, 10)
+                .await,
+            Err(CoreError::QuotaExceeded { .. })
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_batch_search_keeps_query_order() {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("batch".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut ids = Vec::new();
+        for axis in 0..4 {
+            let mut vector = vec![0.0; 16];
+            vector[axis] = 1.0;
+            let doc = VectorDocument::new(DocumentId::new(), vector);
+            ids.push(service.insert(collection_id, doc).await.unwrap());
+        }
+
+        let mut queries: Vec<_> = (0..4)
+            .rev()
+            .map(|axis| {
+                let mut vector = vec![0.0; 16];
+                vector[axis] = 1.0;
+                BatchQuery { vector, top_k: 1 }
+            })
+            .collect();
+        queries.insert(
+            1,
+            BatchQuery {
+                vector: vec![1.0; 15],
+                top_k: 1,
+            },
+        );
+        let options = BatchSearchOptions { concurrency: 2 };
+        let results = service
+            .batch_search(collection_id, queries, &options)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 5);
+        assert!(results[1].is_err());
+        let top: Vec<_> = results
+            .iter()
+            .filter_map(|r| r.as_ref().ok())
+            .map(|r| r[0].doc_id)
+            .collect();
+        assert_eq!(top, vec![ids[3], ids[2], ids[1], ids[0]]);
+
+        let serial = BatchSearchOptions { concurrency: 0 };
+        let query = BatchQuery {
+            vector: vec![1.0; 16],
+            top_k: 1,
+        };
+        assert!(service
+            .batch_search(collection_id, vec![query], &serial)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_count_documents_with_filter() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("counted".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        for year in [2018, 2020, 2021, 2024] {
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+                .with_metadata(serde_json::json!({ "year": year }));
+            service.insert(collection_id, doc).await.unwrap();
+        }
+
+        let all = service
+            .count_documents(collection_id, None, false)
+            .await
+            .unwrap();
+        assert_eq!((all.count, all.exact), (4, true));
+        let recent = MetadataFilter::gte("year", 2020);
+        let count = service
+            .count_documents(collection_id, Some(&recent), false)
+            .await
+            .unwrap();
+        assert_eq!((count.count, count.exact), (3, true));
+        assert!(service
+            .count_documents(collection_id, Some(&MetadataFilter::default()), true)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_update_metadata_keeps_vector() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("patches".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16])
+            .with_metadata(serde_json::json!({ "title": "Draft", "tags": ["a"] }));
+        let doc_id = service.insert(collection_id, doc).await.unwrap();
+        let mut changes = service
+            .subscribe_changes(collection_id, None)
+            .await
+            .unwrap();
+
+        let patch = serde_json::json!({ "title": "Final", "tags": null });
+        let updated = service
+            .update_metadata(collection_id, doc_id, patch.as_object().unwrap())
+            .await
+            .unwrap();
+        assert_eq!(
+            updated.metadata,
+            Some(serde_json::json!({ "title": "Final" }))
+        );
+        // Published as one upsert
+        let event = changes.updates.try_recv().unwrap();
+        assert!(matches!(event.op, ReplicationOp::Upsert { .. }));
+        assert!(changes.updates.try_recv().is_err());
+        let stored = service.get(collection_id, doc_id).await.unwrap().unwrap();
+        assert_eq!(stored.vector, vec![0.5; 16]);
+        assert_eq!(stored.metadata, updated.metadata);
+
+        let patches = vec![
+            MetadataPatch {
+                doc_id,
+                patch: patch.as_object().unwrap().clone(),
+            },
+            MetadataPatch {
+                doc_id: DocumentId::new(),
+                patch: patch.as_object().unwrap().clone(),
+            },
+        ];
+        let report = service
+            .update_metadata_batch(collection_id, patches)
+            .await
+            .unwrap();
+        assert_eq!((report.updated, report.failed), (1, 1));
+        assert_eq!(report.errors[0].index, 1);
+    }
+
+    #[tokio::test]
+    async fn test_upsert_counts_created_and_updated() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("upserts".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc_id = DocumentId::new();
+        let doc = VectorDocument::new(doc_id, vec![1.0; 16]);
+        assert!(service.upsert(collection_id, doc).await.unwrap());
+
+        let docs = vec![
+            VectorDocument::new(doc_id, vec![0.5; 16]),
+            VectorDocument::new(DocumentId::new(), vec![1.0; 16]),
+            VectorDocument::new(doc_id, vec![0.25; 16]),
+        ];
+        let report = service.upsert_batch(collection_id, docs).await.unwrap();
+        assert_eq!((report.inserted, report.updated, report.failed), (1, 2, 0));
+        assert_eq!(report.duplicates[0].action, DuplicateAction::LastWriteWins);
+        let stored = service.get(collection_id, doc_id).await.unwrap().unwrap();
+        assert_eq!(stored.vector, vec![0.25; 16]);
+
+        // A failed replacement keeps the stored document
+        let zero = VectorDocument::new(doc_id, vec![0.0; 16]);
+        assert!(service.upsert(collection_id, zero).await.is_err());
+        let stored = service.get(collection_id, doc_id).await.unwrap().unwrap();
+        assert_eq!(stored.vector, vec![0.25; 16]);
+
+        // Concurrent upserts of a new document: one creates it, the others
+        // replace it
+        let service = Arc::new(service);
+        let new_id = DocumentId::new();
+        let upserts: Vec<_> = (1..=8)
+            .map(|i| {
+                let service = Arc::clone(&service);
+                let doc = VectorDocument::new(new_id, vec![i as f32; 16]);
+                tokio::spawn(async move { service.upsert(collection_id, doc).await })
+            })
+            .collect();
+        let mut created = 0;
+        for upsert in upserts {
+            if upsert.await.unwrap().unwrap() {
+                created += 1;
+            }
+        }
+        assert_eq!(created, 1);
+    }
+
+    #[tokio::test]
+    async fn test_upsert_by_content_id() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("content-ids".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let spec = ContentIdSpec {
+            fields: vec!["text".to_string(), "source".to_string()],
+        };
+        let doc = |text: &str, value: f32| {
+            VectorDocument::new(DocumentId::new(), vec![value; 16])
+                .with_metadata(serde_json::json!({"text": text, "source": "a.md"}))
+        };
+
+        let report = service
+            .upsert_batch_by_content(collection_id, vec![doc("one", 1.0), doc("two", 1.0)], &spec)
+            .await
+            .unwrap();
+        assert_eq!((report.inserted, report.updated), (2, 0));
+
+        // Re-ingesting the same content replaces it
+        let report = service
+            .upsert_batch_by_content(collection_id, vec![doc("one", 0.5)], &spec)
+            .await
+            .unwrap();
+        assert_eq!((report.inserted, report.updated), (0, 1));
+        let stored = service
+            .get(collection_id, report.ids[0])
+            .await
+            .unwrap()
+            .unwrap();
+        assert_eq!(stored.vector, vec![0.5; 16]);
+
+        // An ID taken by different content is a collision, not an update
+        let mut other = doc("three", 1.0);
+        other.doc_id = report.ids[0];
+        service.delete(collection_id, report.ids[0]).await.unwrap();
+        service.insert(collection_id, other).await.unwrap();
+        let report = service
+            .upsert_batch_by_content(collection_id, vec![doc("one", 1.0)], &spec)
+            .await
+            .unwrap();
+        assert_eq!(
+            (report.updated, report.failed, report.collisions),
+            (0, 1, 1)
+        );
+
+        let missing = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        assert!(service
+            .upsert_batch_by_content(collection_id, vec![missing], &spec)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_score_in_metrics() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("items".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![2.0; 16]);
+        service.insert(collection_id, doc).await.unwrap();
+
+        let query = vec![1.0; 16];
+        let results = service
+            .query(collection_id, query.clone(), 1)
+            .await
+            .unwrap();
+        let metrics = [DistanceMetric::Dot, DistanceMetric::L2];
+        let scores = service
+            .score_in_metrics(collection_id, &query, &results, &metrics)
+            .await
+            .unwrap();
+        assert_eq!(scores, vec![Some(vec![32.0, 4.0])]);
+    }
+
+    #[tokio::test]
+    async fn test_attach_vectors() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("items".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![2.0; 16]);
+        let doc_id = service.insert(collection_id, doc).await.unwrap();
+
+        let mut results = service
+            .query(collection_id, vec![1.0; 16], 1)
+            .await
+            .unwrap();
+        assert!(results[0].vector.is_none());
+        service
+            .attach_vectors(collection_id, &mut results)
+            .await
+            .unwrap();
+        assert_eq!(results[0].vector, Some(vec![2.0; 16]));
+
+        // Documents deleted since the search get no vector
+        service.delete(collection_id, doc_id).await.unwrap();
+        service
+            .attach_vectors(collection_id, &mut results)
+            .await
+            .unwrap();
+        assert!(results[0].vector.is_none());
+    }
+
+    #[tokio::test]
+    async fn test_usage_report() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("items".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16])
+            .with_metadata(serde_json::json!({"k": 1}));
+        service.insert(collection_id, doc).await.unwrap();
+        service
+            .query(collection_id, vec![1.0; 16], 1)
+            .await
+            .unwrap();
+        service.record_growth_samples().await.unwrap();
+
+        let report = service.usage_report(UsageMonth::current()).await.unwrap();
+        assert_eq!(report.rows.len(), 1);
+        let row = &report.rows[0];
+        assert_eq!(row.collection_name, "items");
+        assert_eq!(row.usage.queries, 1);
+        assert_eq!(row.usage.vectors_ingested, 1);
+        // 16 f32 components plus {"k":1}
+        assert_eq!(row.usage.bytes_ingested, 64 + 7);
+        assert_eq!(row.usage.peak_storage_bytes, estimate_memory_bytes(1, 16));
+    }
+
+    #[tokio::test]
+    async fn test_usage_history() {
+        let service = CollectionService::new();
+        let tenant_id = TenantId::new();
+        service.set_tenant_id(tenant_id).await;
+        let collection_id = service
+            .create_collection("items".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        service.insert(collection_id, doc).await.unwrap();
+        service
+            .query(collection_id, vec![1.0; 16], 1)
+            .await
+            .unwrap();
+        service.record_growth_samples().await.unwrap();
+
+        let to = Utc::now() + chrono::Duration::seconds(1);
+        let from = to - chrono::Duration::days(1);
+        let history = service
+            .usage_history(tenant_id, from, to, UsageGranularity::Day)
+            .await
+            .unwrap();
+        let points: Vec<_> = history.points.iter().filter(|p| p.queries > 0).collect();
+        assert_eq!(points.len(), 1);
+        assert_eq!(points[0].vectors_ingested, 1);
+        assert_eq!(points[0].vector_count, Some(1));
+        assert_eq!(points[0].storage_bytes, Some(estimate_memory_bytes(1, 16)));
+
+        let err = service
+            .usage_history(TenantId::new(), from, to, UsageGranularity::Day)
+            .await
+            .unwrap_err();
+        assert!(matches!(err, CoreError::NotFound { .. }));
+    }
+
+    #[tokio::test]
+    async fn test_composed_query() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("items".to_string(), 16, DistanceMetric::L2, None)
+            .await
+            .unwrap();
+        let mut ids = Vec::new();
+        for i in 0..3 {
+            let mut vector = vec![0.0; 16];
+            vector[i] = 1.0;
+            let doc = VectorDocument::new(DocumentId::new(), vector);
+            ids.push(service.insert(collection_id, doc).await.unwrap());
+        }
+
+        // doc0 - doc1 + doc2 is closest to doc0 and doc2
+        let composition = QueryComposition {
+            terms: vec![
+                QueryTerm {
+                    doc_id: ids[0],
+                    weight: 1.0,
+                },
+                QueryTerm {
+                    doc_id: ids[1],
+                    weight: -1.0,
+                },
+            ],
+            literal: Some({
+                let mut literal = vec![0.0; 16];
+                literal[2] = 1.0;
+                literal
+            }),
+            normalize: false,
+        };
+        let query = service
+            .compose_query_vector(collection_id, &composition)
+            .await
+            .unwrap();
+        assert_eq!(&query[..3], &[1.0, -1.0, 1.0]);
+        let results = service.query(collection_id, query, 3).await.unwrap();
+        assert_eq!(results[2].doc_id, ids[1]);
+
+        let missing = QueryComposition {
+            terms: vec![QueryTerm {
+                doc_id: DocumentId::new(),
+                weight: 1.0,
+            }],
+            ..Default::default()
+        };
+        assert!(matches!(
+            service.compose_query_vector(collection_id, &missing).await,
+            Err(CoreError::NotFound { .. })
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_range_search() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("points".to_string(), 16, DistanceMetric::L2, None)
+            .await
+            .unwrap();
+        let mut vectors = Vec::new();
+        for i in 0..200 {
+            let mut vector = vec![0.0; 16];
+            vector[0] = i as f32;
+            vectors.push(vector.clone());
+            let doc = VectorDocument::new(DocumentId::new(), vector);
+            service.insert(collection_id, doc).await.unwrap();
+        }
+        let query = vec![0.0; 16];
+        let radius = DistanceMetric::L2.compute(&query, &vectors[100]);
+
+        // More matches than the first round fetches
+        let range = RangeQuery::max_distance(radius);
+        let found = service
+            .range_search(collection_id, query.clone(), &range)
+            .await
+            .unwrap();
+        assert_eq!(found.results.len(), 101);
+        assert!(!found.truncated);
+        assert!(found.results.iter().all(|r| r.score <= radius));
+
+        let limited = range.clone().with_limit(50);
+        let found = service
+            .range_search(collection_id, query.clone(), &limited)
+            .await
+            .unwrap();
+        assert_eq!(found.results.len(), 50);
+        assert!(found.truncated);
+
+        // The whole collection lies within a large radius
+        let everything = RangeQuery::max_distance(f32::MAX);
+        let found = service
+            .range_search(collection_id, query.clone(), &everything)
+            .await
+            .unwrap();
+        assert_eq!(found.results.len(), 200);
+        assert!(!found.truncated);
+
+        assert!(service
+            .range_search(collection_id, query, &RangeQuery::min_score(0.5))
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_recommend() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("items".to_string(), 16, DistanceMetric::L2, None)
+            .await
+            .unwrap();
+        let docs = [
+            (vec![(0, 1.0)], "en"),
+            (vec![(1, 1.0)], "en"),
+            (vec![(0, 0.9), (2, 0.1)], "en"),
+            (vec![(0, 0.9), (3, 0.1)], "de"),
+            (vec![(0, 0.5), (1, 0.5)], "en"),
+        ];
+        let mut ids = Vec::new();
+        for (weights, lang) in docs {
+            let mut vector = vec![0.0; 16];
+            for (i, weight) in weights {
+                vector[i] = weight;
+            }
+            let doc = VectorDocument::new(DocumentId::new(), vector)
+                .with_metadata(serde_json::json!({ "lang": lang }));
+            ids.push(service.insert(collection_id, doc).await.unwrap());
+        }
+        let [positive, negative, near_en, near_de, mixed] = ids[..] else {
+            unreachable!()
+        };
+
+        // Examples are never recommended; the negative pushes `mixed` last
+        let request = RecommendRequest {
+            positive_ids: vec![positive],
+            negative_ids: vec![negative],
+            top_k: 10,
+            filter: None,
+        };
+        let results = service.recommend(collection_id, &request).await.unwrap();
+        let ids: Vec<_> = results.iter().map(|r| r.doc_id).collect();
+        assert_eq!(ids.len(), 3);
+        assert_eq!(ids[2], mixed);
+
+        let filtered = RecommendRequest {
+            top_k: 2,
+            filter: Some(MetadataFilter::eq("lang", "en")),
+            ..request.clone()
+        };
+        let results = service.recommend(collection_id, &filtered).await.unwrap();
+        let ids: Vec<_> = results.iter().map(|r| r.doc_id).collect();
+        assert_eq!(ids, vec![near_en, mixed]);
+        assert!(!ids.contains(&near_de));
+
+        let missing = RecommendRequest {
+            positive_ids: vec![DocumentId::new()],
+            ..request
+        };
+        assert!(matches!(
+            service.recommend(collection_id, &missing).await,
+            Err(CoreError::NotFound { .. })
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_rename_collection() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("drafts".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        service
+            .create_collection("archive".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]);
+        let doc_id = service.insert(collection_id, doc).await.unwrap();
+
+        assert!(matches!(
+            service
+                .rename_collection(collection_id, "archive".to_string())
+                .await,
+            Err(CoreError::AlreadyExists { .. })
+        ));
+        assert!(service
+            .rename_collection(collection_id, "../etc".to_string())
+            .await
+            .is_err());
+
+        let renamed = service
+            .rename_collection(collection_id, "published".to_string())
+            .await
+            .unwrap();
+        assert_eq!(renamed.name, "published");
+        let collection = service.get_collection(collection_id).await.unwrap();
+        assert_eq!(collection.name, "published");
+        assert!(service.get(collection_id, doc_id).await.unwrap().is_some());
+    }
+
+    #[tokio::test]
+    async fn test_transaction_commit_is_atomic() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("ledger".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let old_id = service
+            .insert(
+                collection_id,
+                VectorDocument::new(DocumentId::new(), vec![1.0; 16]),
+            )
+            .await
+            .unwrap();
+
+        // Replace a document: nothing is visible before commit
+        let tx = service.begin_transaction(None).await.unwrap();
+        let new_doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16]);
+        let new_id = new_doc.doc_id;
+        service
+            .transaction_insert(tx.id, collection_id, new_doc)
+            .await
+            .unwrap();
+        let info = service
+            .transaction_delete(tx.id, collection_id, old_id)
+            .await
+            .unwrap();
+        assert_eq!((info.inserts, info.deletes), (1, 1));
+        assert!(service.get(collection_id, new_id).await.unwrap().is_none());
+        assert!(service.get(collection_id, old_id).await.unwrap().is_some());
+
+        let report = service.commit_transaction(tx.id).await.unwrap();
+        assert_eq!((report.inserted, report.deleted), (1, 1));
+        assert!(service.get(collection_id, new_id).await.unwrap().is_some());
+        assert!(service.get(collection_id, old_id).await.unwrap().is_none());
+        assert!(service.get_transaction(tx.id).await.is_err());
+
+        // Rolled back writes are discarded
+        let tx = service.begin_transaction(None).await.unwrap();
+        service
+            .transaction_delete(tx.id, collection_id, new_id)
+            .await
+            .unwrap();
+        service.rollback_transaction(tx.id).await.unwrap();
+        assert!(service.commit_transaction(tx.id).await.is_err());
+        assert!(service.get(collection_id, new_id).await.unwrap().is_some());
+    }
+
+    #[tokio::test]
+    async fn test_failed_commit_changes_nothing() {
+        let service = CollectionService::new();
+        let collection_id = service
+            .create_collection("ledger".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let kept_id = service
+            .insert(
+                collection_id,
+                VectorDocument::new(DocumentId::new(), vec![1.0; 16]),
+            )
+            .await
+            .unwrap();
+
+        let tx = service.begin_transaction(None).await.unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16]);
+        let doc_id = doc.doc_id;
+        service
+            .transaction_insert(tx.id, collection_id, doc)
+            .await
+            .unwrap();
+        service
+            .transaction_delete(tx.id, collection_id, kept_id)
+            .await
+            .unwrap();
+        service
+            .transaction_delete(tx.id, collection_id, DocumentId::new())
+            .await
+            .unwrap();
+
+        let mut changes = service
+            .subscribe_changes(collection_id, None)
+            .await
+            .unwrap();
+        let err = service.commit_transaction(tx.id).await.err().unwrap();
+        assert!(matches!(err, CoreError::NotFound { .. }));
+        assert!(service.get(collection_id, doc_id).await.unwrap().is_none());
+        assert!(service.get(collection_id, kept_id).await.unwrap().is_some());
+        // Nothing was written, so nothing was published
+        assert!(changes.updates.try_recv().is_err());
+
+        // Inserting an ID stored or inserted earlier fails the checks too
+        let tx = service.begin_transaction(None).await.unwrap();
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16]);
+        for doc in [doc.clone(), doc] {
+            service
+                .transaction_insert(tx.id, collection_id, doc)
+                .await
+                .unwrap();
+        }
+        let err = service.commit_transaction(tx.id).await.err().unwrap();
+        assert!(matches!(err, CoreError::AlreadyExists { .. }));
+
+        // Invalid vectors are rejected when buffered
+        let tx = service.begin_transaction(None).await.unwrap();
+        let zero = VectorDocument::new(DocumentId::new(), vec![0.0; 16]);
+        assert!(service
+            .transaction_insert(tx.id, collection_id, zero)
+            .await
+            .is_err());
+    }
+
+    #[tokio::test]
+    async fn test_delete() {
+        let service = CollectionService::new();
+        let collection = create_test_collection();
+
+        service.load_collection(&collection).await.unwrap();
+
+        // Insert vector
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128]);
+        let doc_id = doc.doc_id;
+        service.insert(collection.collection_id, doc).await.unwrap();
+
+        // Delete vector
+        service
+            .delete(collection.collection_id, doc_id)
+            .await
+            .unwrap();
+
+        // Verify deleted
+        let retrieved = service.get(collection.collection_id, doc_id).await.unwrap();
+        assert!(retrieved.is_none());
+    }
+
+    #[tokio::test]
+    async fn test_collection_service_with_storage_config() {
+        use akidb_storage::{StorageConfig, TieringPolicy};
+
+        // Create custom storage config
+        let storage_config = StorageConfig::memory("./test-storage.wal");
+        assert_eq!(storage_config.tiering_policy, TieringPolicy::Memory);
+
+        // Create service with custom storage config
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        // Verify service was created successfully
+        assert!(service.repository.is_some());
+        assert!(service.vector_persistence.is_some());
+
+        // Verify storage config was set correctly
+        assert_eq!(service.storage_config.tiering_policy, TieringPolicy::Memory);
+        assert_eq!(
+            service.storage_config.wal_path.to_str().unwrap(),
+            "./test-storage.wal"
+        );
+    }
+
+    #[tokio::test]
+    async fn test_storage_backends_initialized_empty() {
+        let service = CollectionService::new();
+
+        // Verify storage_backends starts empty
+        let backends = service.storage_backends.read().await;
+        assert_eq!(backends.len(), 0);
+
+        // Verify default storage config is set
+        assert_eq!(service.storage_config.tiering_policy, TieringPolicy::Memory);
+    }
+
+    #[tokio::test]
+    async fn test_create_collection_creates_storage_backend() {
+        use tempfile::TempDir;
+
+        // Create temp directory for WAL/snapshots
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        // Set default database ID
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Verify storage backend was created
+        let backends = service.storage_backends.read().await;
+        assert_eq!(backends.len(), 1);
+        assert!(backends.contains_key(&collection_id));
+    }
+
+    #[tokio::test]
+    async fn test_storage_backend_has_correct_wal_path() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let base_wal_path = temp_dir.path().join("akidb.wal");
+        let storage_config = StorageConfig::memory(&base_wal_path);
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Verify per-collection WAL directory was created
+        let expected_wal_dir = temp_dir
+            .path()
+            .join("collections")
+            .join(collection_id.to_string())
+            .join("wal");
+
+        assert!(
+            expected_wal_dir.parent().unwrap().exists(),
+            "Per-collection directory should be created"
+        );
+    }
+
+    #[tokio::test]
+    async fn test_transaction_commit_reaches_storage() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+        service.set_default_database_id(DatabaseId::new()).await;
+        let collection_id = service
+            .create_collection("ledger".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let old_id = service
+            .insert(
+                collection_id,
+                VectorDocument::new(DocumentId::new(), vec![1.0; 16]),
+            )
+            .await
+            .unwrap();
+
+        let tx = service.begin_transaction(None).await.unwrap();
+        let new_doc = VectorDocument::new(DocumentId::new(), vec![0.5; 16]);
+        let new_id = new_doc.doc_id;
+        service
+            .transaction_insert(tx.id, collection_id, new_doc)
+            .await
+            .unwrap();
+        service
+            .transaction_delete(tx.id, collection_id, old_id)
+            .await
+            .unwrap();
+        service.commit_transaction(tx.id).await.unwrap();
+
+        let backends = service.storage_backends.read().await;
+        let backend = &backends[&collection_id];
+        assert!(backend.get(&new_id).await.unwrap().is_some());
+        assert!(backend.get(&old_id).await.unwrap().is_none());
+        assert!(service.get(collection_id, new_id).await.unwrap().is_some());
+        assert!(service.get(collection_id, old_id).await.unwrap().is_none());
+    }
+
+    #[tokio::test]
+    async fn test_multiple_collections_separate_storage() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create multiple collections
+        let collection_id_1 = service
+            .create_collection(
+                "collection-1".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("model-1".to_string()),
+            )
+            .await
+            .unwrap();
+
+        let collection_id_2 = service
+            .create_collection(
+                "collection-2".to_string(),
+                256,
+                DistanceMetric::L2,
+                Some("model-2".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Verify both have separate storage backends
+        let backends = service.storage_backends.read().await;
+        assert_eq!(backends.len(), 2);
+        assert!(backends.contains_key(&collection_id_1));
+        assert!(backends.contains_key(&collection_id_2));
+
+        // Verify separate directories
+        let dir_1 = temp_dir
+            .path()
+            .join("collections")
+            .join(collection_id_1.to_string());
+        let dir_2 = temp_dir
+            .path()
+            .join("collections")
+            .join(collection_id_2.to_string());
+
+        assert!(dir_1.exists(), "Collection 1 directory should exist");
+        assert!(dir_2.exists(), "Collection 2 directory should exist");
+    }
+
+    #[tokio::test]
+    async fn test_delete_collection_removes_storage_backend() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Verify storage backend exists
+        {
+            let backends = service.storage_backends.read().await;
+            assert_eq!(backends.len(), 1);
+        }
+
+        // Delete collection
+        service.delete_collection(collection_id).await.unwrap();
+
+        // Verify storage backend was removed
+        let backends = service.storage_backends.read().await;
+        assert_eq!(backends.len(), 0);
+        assert!(!backends.contains_key(&collection_id));
+    }
+
+    #[tokio::test]
+    async fn test_insert_persists_to_storage_backend() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Insert vector
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128]);
+        let doc_id = doc.doc_id;
+        service.insert(collection_id, doc).await.unwrap();
+
+        // Verify vector persisted to StorageBackend (not just in-memory index)
+        let backends = service.storage_backends.read().await;
+        let backend = backends.get(&collection_id).unwrap();
+
+        let retrieved = backend.get(&doc_id).await.unwrap();
+        assert!(
+            retrieved.is_some(),
+            "Vector should be persisted to StorageBackend"
+        );
+        assert_eq!(retrieved.unwrap().vector, vec![0.1; 128]);
+    }
+
+    #[tokio::test]
+    async fn test_delete_persists_to_storage_backend() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Insert vector
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128]);
+        let doc_id = doc.doc_id;
+        service.insert(collection_id, doc).await.unwrap();
+
+        // Verify vector exists in StorageBackend
+        {
+            let backends = service.storage_backends.read().await;
+            let backend = backends.get(&collection_id).unwrap();
+            assert!(backend.get(&doc_id).await.unwrap().is_some());
+        }
+
+        // Delete vector
+        service.delete(collection_id, doc_id).await.unwrap();
+
+        // Verify vector deleted from StorageBackend
+        let backends = service.storage_backends.read().await;
+        let backend = backends.get(&collection_id).unwrap();
+        assert!(
+            backend.get(&doc_id).await.unwrap().is_none(),
+            "Vector should be deleted from StorageBackend"
+        );
+    }
+
+    #[tokio::test]
+    async fn test_insert_updates_both_storage_and_index() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"));
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Insert vector
+        let doc = VectorDocument::new(DocumentId::new(), vec![0.1; 128]);
+        let doc_id = doc.doc_id;
+        service.insert(collection_id, doc).await.unwrap();
+
+        // Verify vector in StorageBackend
+        {
+            let backends = service.storage_backends.read().await;
+            let backend = backends.get(&collection_id).unwrap();
+            assert!(backend.get(&doc_id).await.unwrap().is_some());
+        }
+
+        // Verify vector in VectorIndex (for search)
+        let retrieved_from_index = service.get(collection_id, doc_id).await.unwrap();
+        assert!(
+            retrieved_from_index.is_some(),
+            "Vector should be in VectorIndex"
+        );
+
+        // Verify search works (requires VectorIndex)
+        let results = service
+            .query(collection_id, vec![0.1; 128], 1)
+            .await
+            .unwrap();
+        assert_eq!(results.len(), 1);
+        assert_eq!(results[0].doc_id, doc_id);
+    }
+
+    #[tokio::test]
+    #[ignore = "Memory policy doesn't support S3 compaction - compaction only works with MemoryS3 or S3Only policies"]
+    async fn test_auto_compaction_triggered() {
+        use tempfile::TempDir;
+
+        let temp_dir = TempDir::new().unwrap();
+        let storage_config = StorageConfig::memory(temp_dir.path().join("akidb.wal"))
+            .with_compaction_thresholds(1_000_000, 10); // Trigger at 10 inserts
+
+        let service = CollectionService::with_storage(
+            Arc::new(MockCollectionRepository {}),
+            Arc::new(akidb_metadata::VectorPersistence::new(
+                create_test_db().await,
+            )),
+            storage_config,
+        );
+
+        service.set_default_database_id(DatabaseId::new()).await;
+
+        // Create collection
+        let collection_id = service
+            .create_collection(
+                "test-collection".to_string(),
+                128,
+                DistanceMetric::Cosine,
+                Some("test-model".to_string()),
+            )
+            .await
+            .unwrap();
+
+        // Insert 15 vectors (exceeds compaction threshold of 10)
+        // Use non-zero vectors to avoid Cosine similarity undefined behavior
+        for i in 0..15 {
+            let doc = VectorDocument::new(DocumentId::new(), vec![0.1 * (i + 1) as f32; 128]);
+            service.insert(collection_id, doc).await.unwrap();
+        }
+
+        // Wait for background compaction worker to run
+        // Background compaction checks every 1 second, so wait 2 seconds to be safe
+        tokio::time::sleep(tokio::time::Duration::from_secs(2)).await;
+
+        // Verify auto-compaction was triggered
+        let backends = service.storage_backends.read().await;
+        let backend = backends.get(&collection_id).unwrap();
+        let metrics = backend.metrics();
+
+        assert!(
+            metrics.compactions >= 1,
+            "Auto-compaction should have been triggered (compactions: {})",
+            metrics.compactions
+        );
+        assert!(
+            metrics.last_snapshot_at.is_some(),
+            "Snapshot should have been created"
+        );
+    }
+
+    // Mock repository for testing
+    struct MockCollectionRepository {}
+
+    #[async_trait]
+    impl CollectionRepository for MockCollectionRepository {
+        async fn create(&self, _collection: &CollectionDescriptor) -> CoreResult<()> {
+            Ok(())
+        }
+
+        async fn get(
+            &self,
+            _collection_id: CollectionId,
+        ) -> CoreResult<Option<CollectionDescriptor>> {
+            Ok(None)
+        }
+
+        async fn list_by_database(
+            &self,
+            _database_id: DatabaseId,
+        ) -> CoreResult<Vec<CollectionDescriptor>> {
+            Ok(vec![])
+        }
+
+        async fn list_all(&self) -> CoreResult<Vec<CollectionDescriptor>> {
+            Ok(vec![])
+        }
+
+        async fn update(&self, _collection: &CollectionDescriptor) -> CoreResult<()> {
+            Ok(())
+        }
+
+        async fn delete(&self, _collection_id: CollectionId) -> CoreResult<()> {
+            Ok(())
+        }
+    }
+
+    // Helper to create test database with migrations
+    async fn create_test_db() -> sqlx::Pool<sqlx::Sqlite> {
+        let db_url = "sqlite::memory:";
+        let pool = sqlx::SqlitePool::connect(db_url).await.unwrap();
+
+        // Run migrations to create vector_documents table
+        sqlx::migrate!("../akidb-metadata/migrations")
+            .run(&pool)
+            .await
+            .unwrap();
+
+        pool
+    }
+}
@@ -0,0 +1,296 @@
+//! Per-collection monitoring: query/stored vector drift, latency-driven
+//! `ef_search` tuning, and size samples for capacity forecasts.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::Utc;
+use std::collections::{HashMap, VecDeque};
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::adaptive::{
+    AdaptiveSearch, AdaptiveSearchConfig, AdaptiveSearchStatus, DEFAULT_EF_SEARCH,
+};
+use crate::capacity::{
+    estimate_memory_bytes, ForecastLimits, GrowthForecast, GrowthSample, GrowthTrend,
+    GROWTH_HISTORY_LIMIT,
+};
+use crate::drift::{DriftConfig, DriftMonitor, DriftReport, VectorStats};
+use crate::events::{EventComponent, EventLevel};
+
+/// Per-collection monitors; collections without one are not sampled.
+#[derive(Default)]
+pub(super) struct Monitors {
+    // Query/stored vector drift monitors
+    drift: RwLock<HashMap<CollectionId, Arc<DriftMonitor>>>,
+
+    // Latency-driven ef_search controllers (collections without one use their defaults)
+    adaptive_search: RwLock<HashMap<CollectionId, Arc<AdaptiveSearch>>>,
+
+    // Periodic size samples for capacity forecasting (oldest first)
+    growth_history: RwLock<HashMap<CollectionId, VecDeque<GrowthSample>>>,
+}
+
+impl Monitors {
+    pub(super) async fn drift_monitor(
+        &self,
+        collection_id: CollectionId,
+    ) -> Option<Arc<DriftMonitor>> {
+        self.drift.read().await.get(&collection_id).cloned()
+    }
+
+    pub(super) async fn adaptive_search(
+        &self,
+        collection_id: CollectionId,
+    ) -> Option<Arc<AdaptiveSearch>> {
+        self.adaptive_search
+            .read()
+            .await
+            .get(&collection_id)
+            .cloned()
+    }
+
+    /// Drop the monitors of a deleted collection.
+    pub(super) async fn forget(&self, collection_id: CollectionId) {
+        self.drift.write().await.remove(&collection_id);
+        self.adaptive_search.write().await.remove(&collection_id);
+        self.growth_history.write().await.remove(&collection_id);
+    }
+}
+
+impl CollectionService {
+    /// Start sampling query vectors for drift detection.
+    ///
+    /// The current stored vectors become the baseline for stored-vector drift.
+    /// Replaces an existing monitor (and its query window).
+    pub async fn enable_drift_monitoring(
+        &self,
+        collection_id: CollectionId,
+        config: DriftConfig,
+    ) -> CoreResult<VectorStats> {
+        if config.window == 0 {
+            return Err(CoreError::ValidationError(
+                "drift window must be greater than 0".to_string(),
+            ));
+        }
+        let baseline = self.stored_vector_stats(collection_id).await?;
+        self.monitors.drift.write().await.insert(
+            collection_id,
+            Arc::new(DriftMonitor::new(config, baseline.clone())),
+        );
+        Ok(baseline)
+    }
+
+    /// Stop drift monitoring for a collection.
+    pub async fn disable_drift_monitoring(&self, collection_id: CollectionId) -> CoreResult<()> {
+        self.monitors
+            .drift
+            .write()
+            .await
+            .remove(&collection_id)
+            .map(|_| ())
+            .ok_or_else(|| CoreError::not_found("DriftMonitor", collection_id.to_string()))
+    }
+
+    /// Compare recent query vectors and current stored vectors against each other
+    /// and the baseline.
+    pub async fn drift_report(&self, collection_id: CollectionId) -> CoreResult<DriftReport> {
+        let monitor = self
+            .monitors
+            .drift
+            .read()
+            .await
+            .get(&collection_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("DriftMonitor", collection_id.to_string()))?;
+        let stored = self.stored_vector_stats(collection_id).await?;
+        let report = monitor.report(stored);
+
+        if report.drifted {
+            tracing::warn!(
+                "Vector drift detected in collection {} (query: {:?}, stored: {:?})",
+                collection_id,
+                report.query_drift,
+                report.stored_drift
+            );
+        }
+        Ok(report)
+    }
+
+    async fn stored_vector_stats(&self, collection_id: CollectionId) -> CoreResult<VectorStats> {
+        let docs = self.list_documents(collection_id).await?;
+        Ok(VectorStats::from_vectors(
+            docs.iter().map(|doc| doc.vector.as_slice()),
+        ))
+    }
+
+    /// Let the collection's `ef_search` follow its search latency.
+    ///
+    /// Starts from the `ef_search` search default (or the HNSW default) and
+    /// replaces an existing controller.
+    pub async fn enable_adaptive_search(
+        &self,
+        collection_id: CollectionId,
+        config: AdaptiveSearchConfig,
+    ) -> CoreResult<AdaptiveSearchStatus> {
+        config.validate()?;
+        let defaults = self.search_defaults(collection_id).await?;
+        let adaptive = Arc::new(AdaptiveSearch::new(
+            config,
+            defaults.ef_search.unwrap_or(DEFAULT_EF_SEARCH),
+        ));
+        let status = adaptive.status();
+        self.monitors
+            .adaptive_search
+            .write()
+            .await
+            .insert(collection_id, adaptive);
+        Ok(status)
+    }
+
+    /// Go back to the collection's `ef_search` search default.
+    pub async fn disable_adaptive_search(&self, collection_id: CollectionId) -> CoreResult<()> {
+        self.monitors
+            .adaptive_search
+            .write()
+            .await
+            .remove(&collection_id)
+            .map(|_| ())
+            .ok_or_else(|| CoreError::not_found("AdaptiveSearch", collection_id.to_string()))
+    }
+
+    /// Current `ef_search` and recent latency of an adaptive collection.
+    pub async fn adaptive_search_status(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<AdaptiveSearchStatus> {
+        self.monitors
+            .adaptive_search
+            .read()
+            .await
+            .get(&collection_id)
+            .map(|adaptive| adaptive.status())
+            .ok_or_else(|| CoreError::not_found("AdaptiveSearch", collection_id.to_string()))
+    }
+
+    /// Record a size sample for every loaded collection.
+    ///
+    /// Called periodically (see `GROWTH_SAMPLE_INTERVAL`); returns the number of
+    /// collections sampled.
+    pub async fn record_growth_samples(&self) -> CoreResult<usize> {
+        let collections = self.list_collections().await?;
+        let timestamp = Utc::now();
+        let mut sampled = 0;
+        let (mut tenant_vectors, mut tenant_bytes) = (0, 0);
+
+        for collection in collections {
+            // Collections without a loaded index have no live count
+            let Ok(count) = self.get_count(collection.collection_id).await else {
+                continue;
+            };
+            let sample = GrowthSample {
+                timestamp,
+                vector_count: count as u64,
+                estimated_bytes: estimate_memory_bytes(count as u64, collection.dimension),
+            };
+
+            let mut history = self.monitors.growth_history.write().await;
+            let samples = history.entry(collection.collection_id).or_default();
+            if samples.len() >= GROWTH_HISTORY_LIMIT {
+                samples.pop_front();
+            }
+            samples.push_back(sample);
+            self.usage
+                .record_storage(collection.collection_id, sample.estimated_bytes, timestamp);
+            tenant_vectors += sample.vector_count;
+            tenant_bytes += sample.estimated_bytes;
+            sampled += 1;
+        }
+        self.usage
+            .record_tenant_storage(tenant_vectors, tenant_bytes, timestamp);
+
+        Ok(sampled)
+    }
+
+    /// Spawn a background task calling `record_growth_samples` every `interval`.
+    pub fn spawn_growth_sampler(
+        self: &Arc<Self>,
+        interval: std::time::Duration,
+    ) -> tokio::task::JoinHandle<()> {
+        let service = Arc::clone(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            loop {
+                ticker.tick().await;
+                if let Err(e) = service.record_growth_samples().await {
+                    service.events.publish(
+                        EventLevel::Warning,
+                        EventComponent::GrowthSampler,
+                        None,
+                        format!("Failed to record growth samples: {}", e),
+                    );
+                }
+            }
+        })
+    }
+
+    /// Size samples recorded for a collection (oldest first).
+    pub async fn growth_history(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<Vec<GrowthSample>> {
+        self.get_collection(collection_id).await?;
+        let history = self.monitors.growth_history.read().await;
+        Ok(history
+            .get(&collection_id)
+            .map(|samples| samples.iter().copied().collect())
+            .unwrap_or_default())
+    }
+
+    /// Forecast a collection's growth `horizon_days` ahead.
+    pub async fn forecast_growth(
+        &self,
+        collection_id: CollectionId,
+        horizon_days: f64,
+        limits: ForecastLimits,
+    ) -> CoreResult<GrowthForecast> {
+        validate_horizon(horizon_days)?;
+        let trend = self.growth_trend(collection_id).await?;
+        Ok(trend.forecast(horizon_days, limits))
+    }
+
+    /// Forecast the combined growth of all collections on this node.
+    pub async fn forecast_capacity(
+        &self,
+        horizon_days: f64,
+        limits: ForecastLimits,
+    ) -> CoreResult<GrowthForecast> {
+        validate_horizon(horizon_days)?;
+        let mut trends = Vec::new();
+        for collection in self.list_collections().await? {
+            trends.push(self.growth_trend(collection.collection_id).await?);
+        }
+        Ok(GrowthTrend::combine(trends).forecast(horizon_days, limits))
+    }
+
+    async fn growth_trend(&self, collection_id: CollectionId) -> CoreResult<GrowthTrend> {
+        let collection = self.get_collection(collection_id).await?;
+        let count = self.get_count(collection_id).await.unwrap_or(0) as u64;
+        let samples = self.growth_history(collection_id).await?;
+        Ok(GrowthTrend::fit(
+            &samples,
+            count,
+            estimate_memory_bytes(count, collection.dimension),
+        ))
+    }
+}
+
+fn validate_horizon(horizon_days: f64) -> CoreResult<()> {
+    if !horizon_days.is_finite() || horizon_days <= 0.0 {
+        return Err(CoreError::ValidationError(format!(
+            "horizon_days must be a positive number (got {})",
+            horizon_days
+        )));
+    }
+    Ok(())
+}
@@ -0,0 +1,212 @@
+//! Named vectors: additional vector spaces of a collection's documents (e.g.
+//! title and body embeddings), each with its own index.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, SearchResult};
+use chrono::Utc;
+use std::collections::{BTreeMap, HashMap, HashSet};
+
+use super::{encode_setting, CollectionService};
+use crate::named_vectors::{validate_named_vectors, NamedVectorConfig, NamedVectors};
+use crate::post_processing::PostProcessingPipeline;
+use crate::search_defaults::FILTER_OVERFETCH;
+
+impl CollectionService {
+    /// Named vector spaces of a collection (empty if it declares none).
+    pub async fn named_vector_configs(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<BTreeMap<String, NamedVectorConfig>> {
+        self.get_collection(collection_id).await?;
+        let named_vectors = self.named_vectors.read().await;
+        Ok(named_vectors
+            .get(&collection_id)
+            .map(NamedVectors::configs)
+            .unwrap_or_default())
+    }
+
+    /// Declare a collection's named vector spaces, replacing the previous
+    /// ones. Spaces left out are dropped with their stored vectors (their
+    /// documents are rewritten without them); a space holding vectors keeps
+    /// its dimension and metric. The spaces are stored with the collection.
+    pub async fn set_named_vector_configs(
+        &self,
+        collection_id: CollectionId,
+        configs: BTreeMap<String, NamedVectorConfig>,
+    ) -> CoreResult<BTreeMap<String, NamedVectorConfig>> {
+        self.get_collection(collection_id).await?;
+        let dropped = match self.named_vectors.read().await.get(&collection_id) {
+            Some(named) => {
+                named.check_configs(&configs)?;
+                named.doc_ids_outside(&configs)
+            }
+            None => {
+                validate_named_vectors(&configs)?;
+                HashSet::new()
+            }
+        };
+        if !dropped.is_empty() {
+            self.check_writable(collection_id).await?;
+        }
+        for doc_id in dropped {
+            let _lock = self.write_locks.lock(collection_id, doc_id).await;
+            let Some(previous) = self.read_document(collection_id, doc_id).await? else {
+                continue;
+            };
+            let mut doc = previous.clone();
+            if let Some(vectors) = &mut doc.named_vectors {
+                vectors.retain(|name, _| configs.contains_key(name));
+            }
+            self.write_document(collection_id, doc, Some(previous))
+                .await?;
+        }
+
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        let mut named_vectors = self.named_vectors.write().await;
+        if let Some(named) = named_vectors.get(&collection_id) {
+            named.check_configs(&configs)?;
+        }
+        let mut updated = current.clone();
+        updated.named_vectors = if configs.is_empty() {
+            None
+        } else {
+            Some(encode_setting("named vectors", &configs)?)
+        };
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated);
+        if configs.is_empty() {
+            named_vectors.remove(&collection_id);
+        } else {
+            match named_vectors.get_mut(&collection_id) {
+                Some(named) => named.set_configs(configs.clone())?,
+                None => {
+                    named_vectors.insert(collection_id, NamedVectors::new(configs.clone())?);
+                }
+            }
+        }
+        Ok(configs)
+    }
+
+    /// Check named vectors against the collection's spaces, e.g. before
+    /// writing their document.
+    pub async fn check_named_vectors(
+        &self,
+        collection_id: CollectionId,
+        vectors: &HashMap<String, Vec<f32>>,
+    ) -> CoreResult<()> {
+        self.get_collection(collection_id).await?;
+        let named_vectors = self.named_vectors.read().await;
+        match named_vectors.get(&collection_id) {
+            Some(named) => named.check(vectors),
+            None if vectors.is_empty() => Ok(()),
+            None => Err(CoreError::ValidationError(format!(
+                "collection {} has no named vectors",
+                collection_id
+            ))),
+        }
+    }
+
+    /// Store a document's named vectors, replacing all previous ones (an
+    /// empty map removes them). The document is rewritten with them, and
+    /// they stay with it until it is replaced or deleted.
+    pub async fn set_named_vectors(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+        vectors: HashMap<String, Vec<f32>>,
+    ) -> CoreResult<()> {
+        self.check_named_vectors(collection_id, &vectors).await?;
+        self.check_writable(collection_id).await?;
+        let _lock = self.write_locks.lock(collection_id, doc_id).await;
+        let previous = self
+            .read_document(collection_id, doc_id)
+            .await?
+            .ok_or_else(|| CoreError::not_found("Document", doc_id.to_string()))?;
+
+        let mut doc = previous.clone();
+        doc.named_vectors = Some(vectors);
+        self.write_document(collection_id, doc, Some(previous))
+            .await?;
+        Ok(())
+    }
+
+    /// A document's named vectors (empty if it has none).
+    pub async fn named_vectors(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> HashMap<String, Vec<f32>> {
+        let named_vectors = self.named_vectors.read().await;
+        named_vectors
+            .get(&collection_id)
+            .map(|named| named.get(doc_id))
+            .unwrap_or_default()
+    }
+
+    /// Search the named vector space `name`: the `top_k` documents nearest to
+    /// `query_vector` by the space's metric, post-processed by `pipeline`.
+    ///
+    /// The collection's default filter applies as for primary vector searches.
+    pub async fn named_vector_search(
+        &self,
+        collection_id: CollectionId,
+        name: &str,
+        query_vector: &[f32],
+        top_k: usize,
+        pipeline: Option<&PostProcessingPipeline>,
+    ) -> CoreResult<Vec<SearchResult>> {
+        if top_k == 0 {
+            return Err(CoreError::ValidationError(
+                "top_k must be greater than 0".to_string(),
+            ));
+        }
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_top_k(top_k)?;
+
+        let defaults = self.search_defaults(collection_id).await?;
+        let fetch_k = match &defaults.filter {
+            Some(_) => top_k * FILTER_OVERFETCH,
+            None => top_k,
+        };
+        let (ranked, metric) = {
+            let named_vectors = self.named_vectors.read().await;
+            let not_found = || CoreError::not_found("Named vector", name.to_string());
+            let named = named_vectors.get(&collection_id).ok_or_else(not_found)?;
+            let metric = named.config(name).ok_or_else(not_found)?.metric;
+            (named.search(name, query_vector, fetch_k)?, metric)
+        };
+
+        let doc_ids: Vec<DocumentId> = ranked.iter().map(|(doc_id, _)| *doc_id).collect();
+        let docs = self.get_many(collection_id, &doc_ids).await?;
+        let mut results: Vec<SearchResult> = ranked
+            .into_iter()
+            .zip(docs)
+            .filter_map(|((doc_id, score), doc)| {
+                let doc = doc?;
+                Some(SearchResult {
+                    doc_id,
+                    external_id: doc.external_id,
+                    score,
+                    metadata: doc.metadata,
+                    vector: None,
+                })
+            })
+            .collect();
+        if let Some(filter) = &defaults.filter {
+            results.retain(|r| filter.matches(r.metadata.as_ref()));
+        }
+        results.truncate(top_k);
+        Ok(match pipeline {
+            Some(pipeline) => pipeline.apply(results, metric),
+            None => results,
+        })
+    }
+}
@@ -0,0 +1,197 @@
+//! Operator controls and feeds: read-only mode, Get coalescing, access
+//! anomalies, service events, admin impersonation and caller identity.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, TenantId};
+use chrono::Utc;
+use std::time::Instant;
+
+use super::CollectionService;
+use crate::anomaly::{AnomalyConfig, AnomalyEvent};
+use crate::coalesce::{CoalescingConfig, CoalescingStats};
+use crate::events::{EventLevel, ServiceEvent};
+use crate::impersonation::{Impersonation, ImpersonationRecord, ImpersonationRequest};
+use crate::whoami::{scopes, CollectionPermissions, Identity, Principal, SCOPE_WRITE};
+
+impl CollectionService {
+    /// Make a collection read-only (or writable again). Writes to a
+    /// read-only collection, including deleting it, fail with `ReadOnly`;
+    /// searches and reads are unaffected.
+    ///
+    /// The mode is stored in the collection's descriptor, persisted before
+    /// the cached copy is swapped.
+    pub async fn set_read_only(
+        &self,
+        collection_id: CollectionId,
+        read_only: bool,
+    ) -> CoreResult<()> {
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        if current.read_only == read_only {
+            return Ok(());
+        }
+        let mut updated = current.clone();
+        updated.read_only = read_only;
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated);
+        tracing::info!(
+            "Collection {} is now {}",
+            collection_id,
+            if read_only { "read-only" } else { "writable" }
+        );
+        Ok(())
+    }
+
+    pub async fn is_read_only(&self, collection_id: CollectionId) -> bool {
+        self.collections
+            .read()
+            .await
+            .get(&collection_id)
+            .map_or(false, |c| c.read_only)
+    }
+
+    /// Fail with `ReadOnly` if the collection is read-only.
+    pub(super) async fn check_writable(&self, collection_id: CollectionId) -> CoreResult<()> {
+        if self.is_read_only(collection_id).await {
+            return Err(CoreError::read_only(
+                "Collection",
+                collection_id.to_string(),
+            ));
+        }
+        Ok(())
+    }
+
+    /// Enable (or disable) coalescing of concurrent Gets of a collection into
+    /// batched Gets.
+    pub fn set_get_coalescing(&self, config: CoalescingConfig) -> CoreResult<()> {
+        self.get_coalescer.set_config(config)
+    }
+
+    pub fn get_coalescing(&self) -> CoalescingConfig {
+        self.get_coalescer.config()
+    }
+
+    /// Gets answered by batches, and batches run, since startup.
+    pub fn get_coalescing_stats(&self) -> CoalescingStats {
+        self.get_coalescer.stats()
+    }
+
+    /// Set the thresholds for flagging mass scans.
+    pub fn set_anomaly_config(&self, config: AnomalyConfig) -> CoreResult<()> {
+        self.access_monitor.set_config(config)
+    }
+
+    /// Flagged access anomalies newer than event `after` (all kept events
+    /// when None), oldest first.
+    pub fn list_anomalies(&self, after: Option<u64>) -> Vec<AnomalyEvent> {
+        self.access_monitor.events(after)
+    }
+
+    /// Access anomalies flagged within the last `within`.
+    pub fn recent_anomalies(&self, within: std::time::Duration) -> Vec<AnomalyEvent> {
+        let mut events = self.access_monitor.events(None);
+        // Out of range durations cover every kept event
+        if let Ok(within) = chrono::Duration::from_std(within) {
+            let since = Utc::now() - within;
+            events.retain(|event| event.detected_at >= since);
+        }
+        events
+    }
+
+    /// Subscribe to access anomalies as they are flagged.
+    pub fn subscribe_anomalies(&self) -> tokio::sync::broadcast::Receiver<AnomalyEvent> {
+        self.access_monitor.subscribe()
+    }
+
+    pub(super) fn record_reads(
+        &self,
+        collection_id: CollectionId,
+        documents: usize,
+        collection_size: usize,
+    ) {
+        self.access_monitor.record_reads(
+            collection_id,
+            documents as u64,
+            collection_size as u64,
+            Instant::now(),
+        );
+    }
+
+    /// Events of background components newer than event `after` (all kept
+    /// events when None) and at least `min_level`, oldest first.
+    pub fn list_events(&self, after: Option<u64>, min_level: EventLevel) -> Vec<ServiceEvent> {
+        self.events.events(after, min_level)
+    }
+
+    /// Subscribe to events of background components as they are published.
+    pub fn subscribe_events(&self) -> tokio::sync::broadcast::Receiver<ServiceEvent> {
+        self.events.subscribe()
+    }
+
+    /// Enable admin impersonation of `tenant_id`, the tenant this server
+    /// serves, with `admin_key` (disabled when None).
+    pub fn configure_impersonation(
+        &self,
+        tenant_id: TenantId,
+        admin_key: Option<&str>,
+    ) -> CoreResult<()> {
+        self.impersonation.configure(tenant_id, admin_key)
+    }
+
+    /// Check an impersonated request against the admin key and audit it.
+    pub fn authorize_impersonation(
+        &self,
+        admin_key: Option<&str>,
+        request: ImpersonationRequest,
+    ) -> CoreResult<ImpersonationRecord> {
+        self.impersonation.authorize(admin_key, request)
+    }
+
+    /// Impersonation audit records newer than `after` (all kept records when
+    /// None), oldest first.
+    pub fn list_impersonations(&self, after: Option<u64>) -> Vec<ImpersonationRecord> {
+        self.impersonation.records(after)
+    }
+
+    /// Describe the caller of a request: the admin acting as the tenant for
+    /// a granted impersonated request, otherwise the tenant itself.
+    pub async fn whoami(
+        &self,
+        impersonation: Option<&ImpersonationRequest>,
+    ) -> CoreResult<Identity> {
+        let principal = match impersonation {
+            Some(request) => Principal::Impersonation {
+                actor: request.actor.clone(),
+            },
+            None => Principal::Anonymous,
+        };
+        let scopes = scopes(&principal);
+        let writer = scopes.iter().any(|scope| scope == SCOPE_WRITE);
+
+        let mut collections = Vec::new();
+        for collection in self.list_collections().await? {
+            let collection_id = collection.collection_id;
+            let writable = writer && !self.is_read_only(collection_id).await;
+            let held = !self.legal_holds_on(collection_id).await.is_empty();
+            collections.push(CollectionPermissions {
+                collection_id,
+                name: collection.name,
+                read: true,
+                write: writable,
+                delete: writable && !held,
+            });
+        }
+
+        Ok(Identity {
+            principal,
+            tenant_id: self.tenant.id().await,
+            scopes,
+            collections,
+            limits: self.collection_policy().await.limits,
+        })
+    }
+}
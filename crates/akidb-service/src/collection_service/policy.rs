@@ -0,0 +1,87 @@
+//! The tenant's collection policy: defaults filled into new collections and
+//! searches, and hard limits checked against every request.
+
+use akidb_core::{CollectionPolicy, CoreError, CoreResult, DistanceMetric};
+use std::sync::Arc;
+use tokio::sync::{RwLock, RwLockReadGuard};
+
+use super::CollectionService;
+
+/// The collection policy in force, and where it is stored.
+#[derive(Default)]
+pub(super) struct PolicyState {
+    current: RwLock<CollectionPolicy>,
+
+    // Kept in memory only when None
+    repository: RwLock<Option<Arc<akidb_metadata::CollectionPolicyRepository>>>,
+}
+
+impl PolicyState {
+    /// The policy in force, for checking a request against its limits.
+    pub(super) async fn read(&self) -> RwLockReadGuard<'_, CollectionPolicy> {
+        self.current.read().await
+    }
+}
+
+impl CollectionService {
+    /// Get the tenant's collection defaults and limits.
+    pub async fn collection_policy(&self) -> CollectionPolicy {
+        self.collection_policy.current.read().await.clone()
+    }
+
+    /// Replace the tenant's collection defaults and limits.
+    ///
+    /// Limits apply to new collections and queries; existing collections are kept.
+    pub async fn set_collection_policy(&self, policy: CollectionPolicy) -> CoreResult<()> {
+        policy.validate()?;
+        let mut current = self.collection_policy.current.write().await;
+        if let Some(repository) = self.collection_policy.repository.read().await.clone() {
+            repository.set(&policy).await?;
+        }
+        *current = policy;
+        Ok(())
+    }
+
+    /// Set the repository the collection policy is stored in.
+    ///
+    /// Pass `None` to keep the policy in memory only.
+    pub async fn set_collection_policy_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::CollectionPolicyRepository>>,
+    ) {
+        *self.collection_policy.repository.write().await = repository;
+    }
+
+    /// Load the collection policy stored in the repository (called on
+    /// startup). Returns whether a stored policy was found.
+    pub async fn load_collection_policy(&self) -> CoreResult<bool> {
+        let Some(repository) = self.collection_policy.repository.read().await.clone() else {
+            return Ok(false);
+        };
+        match repository.get().await? {
+            Some(policy) => {
+                *self.collection_policy.current.write().await = policy;
+                Ok(true)
+            }
+            None => Ok(false),
+        }
+    }
+
+    /// Resolve a requested distance metric, falling back to the tenant default.
+    pub async fn resolve_metric(
+        &self,
+        metric: Option<DistanceMetric>,
+    ) -> CoreResult<DistanceMetric> {
+        metric
+            .or(self.collection_policy.current.read().await.defaults.metric)
+            .ok_or_else(|| CoreError::ValidationError("metric is required".to_string()))
+    }
+
+    /// Resolve a requested `top_k`, falling back to the tenant default.
+    pub async fn resolve_top_k(&self, top_k: Option<usize>) -> CoreResult<usize> {
+        let default = self.collection_policy.current.read().await.defaults.top_k;
+        top_k
+            .or(default.map(|k| k as usize))
+            .ok_or_else(|| CoreError::ValidationError("top_k is required".to_string()))
+    }
+}
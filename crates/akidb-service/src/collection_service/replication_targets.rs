@@ -0,0 +1,247 @@
+//! Cross-region replication: targets receiving a collection's writes, shipped
+//! in batches by a background task.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::events::{EventComponent, EventLevel};
+use crate::replication::{
+    as_replicated_writes, Replication, ReplicationConfig, ReplicationOp, ReplicationProtocol,
+    ReplicationStatus, ReplicationTransport, REPLICATION_BATCH_SIZE,
+};
+
+/// Replication targets with their pending writes, and where the targets are
+/// stored.
+#[derive(Default)]
+pub(super) struct ReplicationState {
+    targets: Replication,
+
+    // Kept in memory only when None
+    repository: RwLock<Option<Arc<akidb_metadata::ReplicationTargetRepository>>>,
+}
+
+impl ReplicationState {
+    /// Whether the collection has targets, so its writes must be kept.
+    pub(super) fn is_replicated(&self, collection_id: CollectionId) -> bool {
+        self.targets.is_replicated(collection_id)
+    }
+
+    /// Queue a write for the collection's targets (dropped if it has none).
+    pub(super) fn record(&self, collection_id: CollectionId, op: ReplicationOp) {
+        self.targets.record(collection_id, op);
+    }
+
+    /// Stop replicating a deleted collection.
+    pub(super) fn forget(&self, collection_id: CollectionId) {
+        self.targets.remove(collection_id);
+    }
+}
+
+impl CollectionService {
+    /// Set how writes reach other regions' clusters over `protocol`
+    /// (nothing is shipped to targets using a protocol until set).
+    pub fn set_replication_transport(
+        &self,
+        protocol: ReplicationProtocol,
+        transport: Arc<dyn ReplicationTransport>,
+    ) {
+        self.replication.targets.set_transport(protocol, transport);
+    }
+
+    /// Set the repository replication targets are stored in.
+    ///
+    /// Pass `None` to keep targets in memory only.
+    pub async fn set_replication_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::ReplicationTargetRepository>>,
+    ) {
+        *self.replication.repository.write().await = repository;
+    }
+
+    /// Resume replicating the collections whose targets are stored in the
+    /// repository (called on startup, after the collections are loaded).
+    /// Writes not shipped before the restart were not stored, so each
+    /// target starts with a full copy of its collection. Returns the number
+    /// of targets loaded.
+    pub async fn load_replication_targets(&self) -> CoreResult<usize> {
+        let Some(repository) = self.replication.repository.read().await.clone() else {
+            return Ok(0);
+        };
+        let stored = repository.list_all().await?;
+        let count = stored.len();
+        for (collection_id, config) in stored {
+            let config: ReplicationConfig = serde_json::from_value(config)?;
+            self.replication.targets.configure(collection_id, config)?;
+        }
+        Ok(count)
+    }
+
+    /// Replicate a collection to another region, or change its target. A
+    /// new target first receives a full copy of the collection.
+    pub async fn configure_replication(
+        &self,
+        collection_id: CollectionId,
+        config: ReplicationConfig,
+    ) -> CoreResult<ReplicationStatus> {
+        self.get_collection(collection_id).await?;
+        config.validate()?;
+        if let Some(repository) = self.replication.repository.read().await.clone() {
+            repository
+                .save(collection_id, &serde_json::to_value(&config)?)
+                .await?;
+        }
+        self.replication.targets.configure(collection_id, config)?;
+        self.replication_status(collection_id).await
+    }
+
+    /// Replication status of a collection.
+    pub async fn replication_status(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<ReplicationStatus> {
+        self.get_collection(collection_id).await?;
+        self.replication
+            .targets
+            .status(collection_id)
+            .ok_or_else(|| CoreError::not_found("Replication target", collection_id.to_string()))
+    }
+
+    /// Replication status of every replicated collection.
+    pub fn list_replication_statuses(&self) -> Vec<ReplicationStatus> {
+        self.replication.targets.statuses()
+    }
+
+    /// Stop replicating a collection; writes not yet shipped are dropped.
+    pub async fn remove_replication(&self, collection_id: CollectionId) -> CoreResult<()> {
+        self.get_collection(collection_id).await?;
+        if !self.replication.targets.is_replicated(collection_id) {
+            return Err(CoreError::not_found(
+                "Replication target",
+                collection_id.to_string(),
+            ));
+        }
+        if let Some(repository) = self.replication.repository.read().await.clone() {
+            repository.delete(collection_id).await?;
+        }
+        self.replication.targets.remove(collection_id);
+        Ok(())
+    }
+
+    /// Apply writes replicated from another region, in order; returns the
+    /// writes applied.
+    ///
+    /// Deletes of missing or held documents are skipped. The writes are not
+    /// queued for this collection's own target.
+    pub async fn apply_replicated(
+        &self,
+        collection_id: CollectionId,
+        ops: Vec<ReplicationOp>,
+    ) -> CoreResult<usize> {
+        self.get_collection(collection_id).await?;
+        as_replicated_writes(async {
+            let mut applied = 0;
+            for op in ops {
+                match op {
+                    ReplicationOp::Upsert { document } => {
+                        self.upsert(collection_id, document).await?;
+                    }
+                    ReplicationOp::Delete { doc_id } => {
+                        match self.delete(collection_id, doc_id).await {
+                            Ok(()) => {}
+                            Err(CoreError::NotFound { .. }) => continue,
+                            Err(e @ CoreError::UnderLegalHold { .. }) => {
+                                tracing::warn!("Skipping replicated delete of {}: {}", doc_id, e);
+                                continue;
+                            }
+                            Err(e) => return Err(e),
+                        }
+                    }
+                }
+                applied += 1;
+            }
+            Ok(applied)
+        })
+        .await
+    }
+
+    /// Ship the pending writes of every replicated collection; returns the
+    /// writes (or, for full copies, documents) shipped.
+    pub async fn replicate_pending(&self) -> usize {
+        let mut shipped = 0;
+        for collection_id in self.replication.targets.collections() {
+            // Targets wait until their protocol's transport is set
+            let Some(transport) = self.replication.targets.target_transport(collection_id) else {
+                continue;
+            };
+            while let Some(batch) = self.replication.targets.next_batch(collection_id) {
+                let result = if batch.full_sync {
+                    self.ship_full_copy(collection_id, &batch.endpoint, batch.target, &*transport)
+                        .await
+                } else {
+                    transport
+                        .ship(&batch.endpoint, batch.target, &batch.ops)
+                        .await
+                        .map(|_| batch.ops.len())
+                };
+                self.replication
+                    .targets
+                    .finish_batch(collection_id, &batch, &result);
+                // A partial batch means the queue is drained
+                let drained = !batch.full_sync && batch.ops.len() < REPLICATION_BATCH_SIZE;
+                match result {
+                    Ok(count) => shipped += count,
+                    Err(e) => {
+                        self.events.publish(
+                            EventLevel::Warning,
+                            EventComponent::Replication,
+                            Some(collection_id),
+                            format!("Shipping writes to {} failed: {}", batch.endpoint, e),
+                        );
+                        break;
+                    }
+                }
+                if drained {
+                    break;
+                }
+            }
+        }
+        shipped
+    }
+
+    async fn ship_full_copy(
+        &self,
+        collection_id: CollectionId,
+        endpoint: &str,
+        target: CollectionId,
+        transport: &dyn ReplicationTransport,
+    ) -> CoreResult<usize> {
+        let docs = self.list_documents(collection_id).await?;
+        for chunk in docs.chunks(REPLICATION_BATCH_SIZE) {
+            let ops: Vec<ReplicationOp> = chunk
+                .iter()
+                .map(|document| ReplicationOp::Upsert {
+                    document: document.clone(),
+                })
+                .collect();
+            transport.ship(endpoint, target, &ops).await?;
+        }
+        Ok(docs.len())
+    }
+
+    /// Ship pending replication writes every `interval` in the background.
+    pub fn spawn_replicator(
+        self: &Arc<Self>,
+        interval: std::time::Duration,
+    ) -> tokio::task::JoinHandle<()> {
+        let service = Arc::clone(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            loop {
+                ticker.tick().await;
+                service.replicate_pending().await;
+            }
+        })
+    }
+}
@@ -0,0 +1,299 @@
+//! Cron-scheduled housekeeping jobs: TTL expiry, compaction and snapshots of
+//! a collection, run by a background ticker.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, JobId};
+use chrono::{DateTime, Utc};
+use std::collections::HashMap;
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::events::{EventComponent, EventLevel};
+use crate::legal_hold::held_by;
+use crate::schedule::{
+    is_expired, JobRun, JobRunStatus, ScheduledAction, ScheduledJob, ScheduledJobSpec,
+};
+
+/// Scheduled jobs, and where they are stored.
+#[derive(Default)]
+pub(super) struct Scheduler {
+    jobs: RwLock<HashMap<JobId, ScheduledJob>>,
+
+    // Kept in memory only when None
+    repository: RwLock<Option<Arc<akidb_metadata::ScheduledJobRepository>>>,
+}
+
+impl Scheduler {
+    /// Drop the jobs of a deleted collection.
+    pub(super) async fn forget(&self, collection_id: CollectionId) {
+        self.jobs
+            .write()
+            .await
+            .retain(|_, job| job.spec.action.collection_id() != collection_id);
+    }
+}
+
+impl CollectionService {
+    /// Create a job running a housekeeping action on a cron schedule.
+    pub async fn create_scheduled_job(&self, spec: ScheduledJobSpec) -> CoreResult<ScheduledJob> {
+        self.get_collection(spec.action.collection_id()).await?;
+        let job = ScheduledJob::new(spec)?;
+        self.save_scheduled_job(&job).await?;
+        self.scheduler
+            .jobs
+            .write()
+            .await
+            .insert(job.id, job.clone());
+
+        tracing::info!(
+            "Created scheduled job {} '{}' ({})",
+            job.id,
+            job.spec.name,
+            job.spec.schedule
+        );
+        Ok(job)
+    }
+
+    /// List scheduled jobs, oldest first.
+    pub async fn list_scheduled_jobs(&self) -> Vec<ScheduledJob> {
+        let jobs = self.scheduler.jobs.read().await;
+        let mut jobs: Vec<ScheduledJob> = jobs.values().cloned().collect();
+        jobs.sort_by_key(|job| job.created_at);
+        jobs
+    }
+
+    /// Get a scheduled job, including its recent runs.
+    pub async fn get_scheduled_job(&self, job_id: JobId) -> CoreResult<ScheduledJob> {
+        self.scheduler
+            .jobs
+            .read()
+            .await
+            .get(&job_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("ScheduledJob", job_id.to_string()))
+    }
+
+    /// Replace a scheduled job's definition, keeping its history.
+    pub async fn update_scheduled_job(
+        &self,
+        job_id: JobId,
+        spec: ScheduledJobSpec,
+    ) -> CoreResult<ScheduledJob> {
+        self.get_collection(spec.action.collection_id()).await?;
+        let mut jobs = self.scheduler.jobs.write().await;
+        let job = jobs
+            .get_mut(&job_id)
+            .ok_or_else(|| CoreError::not_found("ScheduledJob", job_id.to_string()))?;
+        let mut updated = job.clone();
+        updated.update(spec, Utc::now())?;
+        self.save_scheduled_job(&updated).await?;
+        *job = updated.clone();
+        Ok(updated)
+    }
+
+    /// Delete a scheduled job. A run in progress finishes.
+    pub async fn delete_scheduled_job(&self, job_id: JobId) -> CoreResult<()> {
+        let mut jobs = self.scheduler.jobs.write().await;
+        if !jobs.contains_key(&job_id) {
+            return Err(CoreError::not_found("ScheduledJob", job_id.to_string()));
+        }
+        if let Some(repository) = self.scheduler.repository.read().await.clone() {
+            repository.delete(job_id).await?;
+        }
+        jobs.remove(&job_id);
+        Ok(())
+    }
+
+    /// Set the repository scheduled jobs are stored in.
+    ///
+    /// Pass `None` to keep jobs in memory only.
+    pub async fn set_scheduled_job_repository(
+        &self,
+        repository: Option<Arc<akidb_metadata::ScheduledJobRepository>>,
+    ) {
+        *self.scheduler.repository.write().await = repository;
+    }
+
+    /// Load the scheduled jobs stored in the repository (called on startup,
+    /// after the collections are loaded). Jobs due while the service was
+    /// down run once on the next scheduler tick. Returns the number of jobs
+    /// loaded.
+    pub async fn load_scheduled_jobs(&self) -> CoreResult<usize> {
+        let Some(repository) = self.scheduler.repository.read().await.clone() else {
+            return Ok(0);
+        };
+        let stored = repository.list_all().await?;
+        let count = stored.len();
+        let mut jobs = self.scheduler.jobs.write().await;
+        for job in stored {
+            let job: ScheduledJob = serde_json::from_value(job)?;
+            jobs.insert(job.id, job);
+        }
+        Ok(count)
+    }
+
+    /// Store a job's current state in the repository, if any.
+    async fn save_scheduled_job(&self, job: &ScheduledJob) -> CoreResult<()> {
+        if let Some(repository) = self.scheduler.repository.read().await.clone() {
+            repository
+                .save(
+                    job.id,
+                    job.spec.action.collection_id(),
+                    &serde_json::to_value(job)?,
+                )
+                .await?;
+        }
+        Ok(())
+    }
+
+    /// Store a job changed by the scheduler. Failures are reported as events:
+    /// the job keeps running from memory and is stored again on its next
+    /// change.
+    async fn store_scheduled_job(&self, job: &ScheduledJob) {
+        if let Err(e) = self.save_scheduled_job(job).await {
+            self.events.publish(
+                EventLevel::Warning,
+                EventComponent::Scheduler,
+                Some(job.spec.action.collection_id()),
+                format!("Failed to store scheduled job {}: {}", job.id, e),
+            );
+        }
+    }
+
+    /// Run a scheduled job now, regardless of its schedule.
+    pub async fn run_scheduled_job(&self, job_id: JobId) -> CoreResult<JobRun> {
+        let action = self.get_scheduled_job(job_id).await?.spec.action;
+        Ok(self.execute_scheduled_job(job_id, action, true).await)
+    }
+
+    /// Run every enabled job whose next run is due at `now`; returns the
+    /// number of jobs run.
+    pub async fn run_due_jobs(&self, now: DateTime<Utc>) -> usize {
+        // Advance the schedule before running so a slow run is not started twice
+        let due: Vec<ScheduledJob> = {
+            let mut jobs = self.scheduler.jobs.write().await;
+            jobs.values_mut()
+                .filter(|job| job.is_due(now))
+                .map(|job| {
+                    job.advance(now);
+                    job.clone()
+                })
+                .collect()
+        };
+
+        for job in &due {
+            self.store_scheduled_job(job).await;
+            self.execute_scheduled_job(job.id, job.spec.action.clone(), false)
+                .await;
+        }
+        due.len()
+    }
+
+    /// Spawn a background task calling `run_due_jobs` every `interval`.
+    pub fn spawn_job_scheduler(
+        self: &Arc<Self>,
+        interval: std::time::Duration,
+    ) -> tokio::task::JoinHandle<()> {
+        let service = Arc::clone(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            loop {
+                ticker.tick().await;
+                service.run_due_jobs(Utc::now()).await;
+            }
+        })
+    }
+
+    /// Run an action and record the outcome in the job's history.
+    async fn execute_scheduled_job(
+        &self,
+        job_id: JobId,
+        action: ScheduledAction,
+        manual: bool,
+    ) -> JobRun {
+        let started_at = Utc::now();
+        let result = match &action {
+            ScheduledAction::DeleteExpired {
+                collection_id,
+                field,
+            } => self.delete_expired(*collection_id, field).await,
+            ScheduledAction::Compact { collection_id } => {
+                self.compact_collection(*collection_id).await.map(|_| 0)
+            }
+        };
+
+        let run = JobRun {
+            started_at,
+            finished_at: Utc::now(),
+            status: if result.is_ok() {
+                JobRunStatus::Succeeded
+            } else {
+                JobRunStatus::Failed
+            },
+            affected: *result.as_ref().unwrap_or(&0),
+            error: result.err().map(|e| e.to_string()),
+            manual,
+        };
+        match &run.error {
+            Some(e) => {
+                self.events.publish(
+                    EventLevel::Warning,
+                    EventComponent::Scheduler,
+                    Some(action.collection_id()),
+                    format!("Scheduled job {} failed: {}", job_id, e),
+                );
+            }
+            None => tracing::info!("Scheduled job {} affected {}", job_id, run.affected),
+        }
+        let recorded = self
+            .scheduler
+            .jobs
+            .write()
+            .await
+            .get_mut(&job_id)
+            .map(|job| {
+                job.record(run.clone());
+                job.clone()
+            });
+        if let Some(job) = recorded {
+            self.store_scheduled_job(&job).await;
+        }
+        run
+    }
+
+    /// Delete documents whose metadata `field` holds a past time.
+    async fn delete_expired(&self, collection_id: CollectionId, field: &str) -> CoreResult<u64> {
+        let now = Utc::now();
+        let holds = self.legal_holds_on(collection_id).await;
+        let expired: Vec<DocumentId> = self
+            .list_documents(collection_id)
+            .await?
+            .into_iter()
+            .filter(|doc| is_expired(doc.metadata.as_ref(), field, now))
+            .filter(|doc| held_by(&holds, doc.metadata.as_ref()).is_none())
+            .map(|doc| doc.doc_id)
+            .collect();
+
+        for doc_id in &expired {
+            self.delete(collection_id, *doc_id).await?;
+        }
+        Ok(expired.len() as u64)
+    }
+
+    /// Snapshot a collection's storage and truncate its write-ahead log.
+    async fn compact_collection(&self, collection_id: CollectionId) -> CoreResult<()> {
+        let backend = self
+            .storage_backends
+            .read()
+            .await
+            .get(&collection_id)
+            .cloned()
+            .ok_or_else(|| {
+                CoreError::invalid_state(format!(
+                    "collection {} has no persistent storage to compact",
+                    collection_id
+                ))
+            })?;
+        backend.compact().await
+    }
+}
@@ -0,0 +1,276 @@
+//! Collection snapshots: point-in-time copies written to files, listed again
+//! on startup and restorable into a new collection.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, SnapshotId};
+use chrono::Utc;
+
+use super::CollectionService;
+use crate::collection_update::CollectionUpdate;
+use crate::compliance::ExportRecord;
+use crate::index_options::IndexOptions;
+use crate::manifest::{ManifestBuilder, EXPORT_PART_BYTES};
+use crate::snapshot::{
+    snapshot_dir, snapshot_info_path, snapshot_path, CollectionSnapshot, RestoreReport,
+};
+use crate::upload::{ImportRecord, LineSplitter};
+use crate::vector_codec::VectorCodec;
+
+impl CollectionService {
+    /// Snapshot a collection: its settings, search defaults and documents.
+    ///
+    /// Writes are not blocked; the documents are those visible when the
+    /// snapshot starts reading them.
+    pub async fn create_snapshot(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<CollectionSnapshot> {
+        use tokio::io::AsyncWriteExt;
+
+        let collection = self.get_collection(collection_id).await?;
+        let search_defaults = self.search_defaults(collection_id).await?;
+        let documents = self.list_documents(collection_id).await?;
+
+        let snapshot_id = SnapshotId::new();
+        let path = snapshot_path(snapshot_id);
+        let io_err = |e: std::io::Error| CoreError::internal(format!("Snapshot failed: {}", e));
+        if let Some(dir) = path.parent() {
+            tokio::fs::create_dir_all(dir).await.map_err(io_err)?;
+        }
+        let file = tokio::fs::File::create(&path).await.map_err(io_err)?;
+        let mut writer = tokio::io::BufWriter::new(file);
+        let mut manifest = ManifestBuilder::new(EXPORT_PART_BYTES);
+        for doc in documents {
+            let record = ExportRecord::new(collection_id, &collection.name, doc, VectorCodec::F32);
+            let mut line = serde_json::to_vec(&record)
+                .map_err(|e| CoreError::internal(format!("Failed to serialize record: {}", e)))?;
+            line.push(b'\n');
+            manifest.push(&line);
+            writer.write_all(&line).await.map_err(io_err)?;
+        }
+        writer.flush().await.map_err(io_err)?;
+
+        let snapshot = CollectionSnapshot {
+            snapshot_id,
+            collection,
+            search_defaults,
+            manifest: manifest.finish(),
+            created_at: Utc::now(),
+        };
+        tracing::info!(
+            "Created snapshot {} of collection {} ({} documents)",
+            snapshot_id,
+            collection_id,
+            snapshot.manifest.records
+        );
+        let info = serde_json::to_vec(&snapshot)
+            .map_err(|e| CoreError::internal(format!("Failed to serialize snapshot: {}", e)))?;
+        tokio::fs::write(snapshot_info_path(snapshot_id), info)
+            .await
+            .map_err(io_err)?;
+        self.snapshots
+            .write()
+            .await
+            .insert(snapshot_id, snapshot.clone());
+        Ok(snapshot)
+    }
+
+    /// List the snapshots found in the snapshot directory (called on
+    /// startup). Snapshots whose files are missing or unreadable are
+    /// skipped. Returns the number of snapshots loaded.
+    pub async fn load_snapshots(&self) -> CoreResult<usize> {
+        let io_err =
+            |e: std::io::Error| CoreError::internal(format!("Failed to list snapshots: {}", e));
+        let mut entries = match tokio::fs::read_dir(snapshot_dir()).await {
+            Ok(entries) => entries,
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => return Ok(0),
+            Err(e) => return Err(io_err(e)),
+        };
+        let mut loaded = Vec::new();
+        while let Some(entry) = entries.next_entry().await.map_err(io_err)? {
+            let path = entry.path();
+            if path.extension().and_then(|ext| ext.to_str()) != Some("json") {
+                continue;
+            }
+            let info = match tokio::fs::read(&path).await {
+                Ok(info) => info,
+                Err(e) => {
+                    tracing::warn!("Skipping snapshot {}: {}", path.display(), e);
+                    continue;
+                }
+            };
+            let snapshot: CollectionSnapshot = match serde_json::from_slice(&info) {
+                Ok(snapshot) => snapshot,
+                Err(e) => {
+                    tracing::warn!("Skipping snapshot {}: {}", path.display(), e);
+                    continue;
+                }
+            };
+            if !tokio::fs::try_exists(snapshot_path(snapshot.snapshot_id))
+                .await
+                .unwrap_or(false)
+            {
+                tracing::warn!(
+                    "Skipping snapshot {}: its file is missing",
+                    snapshot.snapshot_id
+                );
+                continue;
+            }
+            loaded.push(snapshot);
+        }
+        let count = loaded.len();
+        let mut snapshots = self.snapshots.write().await;
+        for snapshot in loaded {
+            snapshots.insert(snapshot.snapshot_id, snapshot);
+        }
+        Ok(count)
+    }
+
+    /// Snapshots, oldest first; only those of `collection_id` if given.
+    ///
+    /// Snapshots of deleted collections are kept and listed.
+    pub async fn list_snapshots(
+        &self,
+        collection_id: Option<CollectionId>,
+    ) -> Vec<CollectionSnapshot> {
+        let mut snapshots: Vec<CollectionSnapshot> = self
+            .snapshots
+            .read()
+            .await
+            .values()
+            .filter(|s| collection_id.map_or(true, |id| s.collection_id() == id))
+            .cloned()
+            .collect();
+        snapshots.sort_by_key(|s| s.created_at);
+        snapshots
+    }
+
+    pub async fn get_snapshot(&self, snapshot_id: SnapshotId) -> CoreResult<CollectionSnapshot> {
+        self.snapshots
+            .read()
+            .await
+            .get(&snapshot_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("Snapshot", snapshot_id.to_string()))
+    }
+
+    /// Path of a snapshot's NDJSON file, for downloads.
+    ///
+    /// A download counts as reading every document in the snapshot.
+    pub async fn snapshot_file(&self, snapshot_id: SnapshotId) -> CoreResult<std::path::PathBuf> {
+        let snapshot = self.get_snapshot(snapshot_id).await?;
+        let collection_id = snapshot.collection_id();
+        let records = snapshot.manifest.records as usize;
+        // The collection may have been deleted since
+        let size = self.get_count(collection_id).await.unwrap_or(records);
+        self.record_reads(collection_id, records, size);
+        Ok(snapshot_path(snapshot_id))
+    }
+
+    /// Delete a snapshot and its file.
+    pub async fn delete_snapshot(&self, snapshot_id: SnapshotId) -> CoreResult<()> {
+        self.snapshots
+            .write()
+            .await
+            .remove(&snapshot_id)
+            .ok_or_else(|| CoreError::not_found("Snapshot", snapshot_id.to_string()))?;
+        for path in [snapshot_info_path(snapshot_id), snapshot_path(snapshot_id)] {
+            if let Err(e) = tokio::fs::remove_file(path).await {
+                tracing::warn!("Failed to remove snapshot file {}: {}", snapshot_id, e);
+            }
+        }
+        Ok(())
+    }
+
+    /// Restore a snapshot into a new collection named `name` (default: the
+    /// snapshot's collection name and time).
+    ///
+    /// The snapshot file is checked against its manifest first. If a document
+    /// cannot be restored, the new collection is deleted again.
+    pub async fn restore_snapshot(
+        &self,
+        snapshot_id: SnapshotId,
+        name: Option<String>,
+    ) -> CoreResult<RestoreReport> {
+        let snapshot = self.get_snapshot(snapshot_id).await?;
+        let data = tokio::fs::read(snapshot_path(snapshot_id))
+            .await
+            .map_err(|e| CoreError::internal(format!("Failed to read snapshot: {}", e)))?;
+        snapshot.manifest.verify_file(&data)?;
+
+        let source = &snapshot.collection;
+        let name = name.unwrap_or_else(|| snapshot.default_restore_name());
+        let collection_id = self
+            .create_collection_with_index(
+                name.clone(),
+                source.dimension,
+                source.metric,
+                Some(source.embedding_model.clone()),
+                IndexOptions {
+                    index_type: source.index_type,
+                    ..Default::default()
+                },
+            )
+            .await?;
+        match self.restore_into(collection_id, &snapshot, &data).await {
+            Ok(documents) => {
+                tracing::info!(
+                    "Restored snapshot {} into collection {} ({} documents)",
+                    snapshot_id,
+                    collection_id,
+                    documents
+                );
+                Ok(RestoreReport {
+                    snapshot_id,
+                    collection_id,
+                    name,
+                    documents,
+                })
+            }
+            Err(e) => {
+                if let Err(cleanup) = self.delete_collection(collection_id).await {
+                    tracing::error!(
+                        "Failed to delete collection {} after a failed restore: {}",
+                        collection_id,
+                        cleanup
+                    );
+                }
+                Err(e)
+            }
+        }
+    }
+
+    async fn restore_into(
+        &self,
+        collection_id: CollectionId,
+        snapshot: &CollectionSnapshot,
+        data: &[u8],
+    ) -> CoreResult<u64> {
+        let source = &snapshot.collection;
+        let settings = CollectionUpdate {
+            description: source.description.clone(),
+            metadata: source.metadata.clone(),
+            hnsw_m: Some(source.hnsw_m),
+            hnsw_ef_construction: Some(source.hnsw_ef_construction),
+            max_doc_count: Some(source.max_doc_count),
+            search_defaults: (!snapshot.search_defaults.is_empty())
+                .then(|| snapshot.search_defaults.clone()),
+        };
+        self.update_collection(collection_id, settings).await?;
+
+        let mut documents = 0;
+        let mut lines = LineSplitter::default();
+        let mut records = lines.push(data);
+        records.extend(lines.finish());
+        for (number, line) in records {
+            if line.iter().all(u8::is_ascii_whitespace) {
+                continue;
+            }
+            let doc = serde_json::from_slice::<ImportRecord>(&line)
+                .map_err(|e| CoreError::internal(format!("snapshot line {}: {}", number, e)))?
+                .into_document()?;
+            self.insert(collection_id, doc).await?;
+            documents += 1;
+        }
+        Ok(documents)
+    }
+}
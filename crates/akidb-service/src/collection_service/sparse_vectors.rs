@@ -0,0 +1,322 @@
+//! Sparse vectors: per-collection sparse (BM25 or client-encoded) indexes,
+//! searched on their own or fused with dense results.
+
+use akidb_core::{
+    CollectionId, CoreError, CoreResult, DistanceMetric, DocumentId, SearchResult, VectorDocument,
+};
+use chrono::Utc;
+
+use super::{encode_setting, CollectionService};
+use crate::hybrid::HybridQuery;
+use crate::search_defaults::FILTER_OVERFETCH;
+use crate::sparse::{Bm25Encoder, IdfStats, SparseEncoder, SparseVector};
+use crate::sparse_index::{SparseIndex, SparseIndexConfig};
+
+impl CollectionService {
+    /// Compute BM25 document-frequency stats over a metadata text field.
+    ///
+    /// Documents without a string value in `field` are skipped. Clients use the
+    /// stats to build sparse query vectors locally (see `Bm25Encoder`).
+    pub async fn idf_stats(
+        &self,
+        collection_id: CollectionId,
+        field: &str,
+    ) -> CoreResult<IdfStats> {
+        let analyzer = self.text_analysis(collection_id).await?.analyzer_for(field);
+        let docs = self.list_documents(collection_id).await?;
+        let texts = docs.iter().filter_map(|doc| {
+            doc.metadata
+                .as_ref()
+                .and_then(|m| m.get(field))
+                .and_then(|v| v.as_str())
+        });
+
+        Ok(IdfStats::from_texts(field, analyzer, texts))
+    }
+
+    /// Hybrid search: fuse the dense k-NN results for `query_vector` with
+    /// BM25 keyword results over `hybrid.text_field`.
+    ///
+    /// The keyword side scores every document with text in the field, so it
+    /// costs a collection scan. A `sparse_vector` query against a collection
+    /// with a sparse index searches the stored sparse vectors instead. Fused
+    /// scores are higher-is-better for every metric.
+    pub async fn hybrid_search(
+        &self,
+        collection_id: CollectionId,
+        query_vector: Vec<f32>,
+        hybrid: &HybridQuery,
+        top_k: usize,
+    ) -> CoreResult<Vec<SearchResult>> {
+        hybrid.validate()?;
+        let candidates = hybrid.candidates(top_k);
+        let metric = self.get_collection(collection_id).await?.metric;
+
+        let mut dense = self
+            .search_index(collection_id, query_vector, candidates)
+            .await?;
+        if metric == DistanceMetric::L2 {
+            // Fusion expects higher-is-better scores
+            dense.iter_mut().for_each(|r| r.score = -r.score);
+        }
+
+        let indexed = self
+            .sparse_indexes
+            .read()
+            .await
+            .contains_key(&collection_id);
+        if let Some(query) = hybrid.sparse_vector.as_ref().filter(|_| indexed) {
+            let sparse = self.sparse_search(collection_id, query, candidates).await?;
+            return Ok(hybrid.fusion.fuse(dense, sparse, top_k));
+        }
+
+        let field = hybrid.text_field.as_str();
+        let analyzer = self.text_analysis(collection_id).await?.analyzer_for(field);
+        let docs = self.list_documents(collection_id).await?;
+        let text = |doc: &VectorDocument| {
+            doc.metadata
+                .as_ref()
+                .and_then(|m| m.get(field))
+                .and_then(|v| v.as_str())
+                .map(str::to_string)
+        };
+        let texts: Vec<_> = docs.iter().map(text).collect();
+        let encoder = Bm25Encoder::new(IdfStats::from_texts(
+            field,
+            analyzer,
+            texts.iter().flatten().map(String::as_str),
+        ));
+        let query = match &hybrid.text {
+            Some(text) => encoder.encode_query(text),
+            None => hybrid.sparse_vector.clone().unwrap_or_default(),
+        };
+
+        let mut sparse: Vec<SearchResult> = docs
+            .into_iter()
+            .zip(texts)
+            .filter_map(|(doc, text)| {
+                let score = query.dot(&encoder.encode_document(&text?));
+                (score > 0.0).then(|| SearchResult {
+                    doc_id: doc.doc_id,
+                    external_id: doc.external_id,
+                    score,
+                    metadata: doc.metadata,
+                    vector: None,
+                })
+            })
+            .collect();
+        sparse.sort_by(|a, b| b.score.total_cmp(&a.score));
+        sparse.truncate(candidates);
+
+        Ok(hybrid.fusion.fuse(dense, sparse, top_k))
+    }
+
+    /// Sparse index settings of a collection (none if it has no sparse index).
+    pub async fn sparse_index_config(
+        &self,
+        collection_id: CollectionId,
+    ) -> CoreResult<Option<SparseIndexConfig>> {
+        self.get_collection(collection_id).await?;
+        let sparse_indexes = self.sparse_indexes.read().await;
+        Ok(sparse_indexes
+            .get(&collection_id)
+            .map(|index| index.config().clone()))
+    }
+
+    /// Create a collection's sparse index, or change its settings (stored
+    /// vectors must satisfy the new ones). The settings are stored with the
+    /// collection.
+    pub async fn set_sparse_index(
+        &self,
+        collection_id: CollectionId,
+        config: SparseIndexConfig,
+    ) -> CoreResult<SparseIndexConfig> {
+        config.validate()?;
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        let mut sparse_indexes = self.sparse_indexes.write().await;
+        if let Some(index) = sparse_indexes.get(&collection_id) {
+            index.check_config(&config)?;
+        }
+        let mut updated = current.clone();
+        updated.sparse_index = Some(encode_setting("sparse index", &config)?);
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated);
+        match sparse_indexes.get_mut(&collection_id) {
+            Some(index) => index.set_config(config.clone())?,
+            None => {
+                sparse_indexes.insert(collection_id, SparseIndex::new(config.clone()));
+            }
+        }
+        Ok(config)
+    }
+
+    /// Drop a collection's sparse index with every stored sparse vector.
+    ///
+    /// Each document with a sparse vector is rewritten without it, so it
+    /// fails (leaving the index in place) if one of them is under legal hold.
+    pub async fn drop_sparse_index(&self, collection_id: CollectionId) -> CoreResult<()> {
+        self.get_collection(collection_id).await?;
+        let doc_ids: Vec<DocumentId> = self
+            .sparse_indexes
+            .read()
+            .await
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Sparse index", collection_id.to_string()))?
+            .doc_ids()
+            .collect();
+        if !doc_ids.is_empty() {
+            self.check_writable(collection_id).await?;
+        }
+        for doc_id in doc_ids {
+            let _lock = self.write_locks.lock(collection_id, doc_id).await;
+            let Some(previous) = self.read_document(collection_id, doc_id).await? else {
+                continue;
+            };
+            if previous.sparse_vector.is_none() {
+                continue;
+            }
+            let mut doc = previous.clone();
+            doc.sparse_vector = None;
+            self.write_document(collection_id, doc, Some(previous))
+                .await?;
+        }
+
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+        let mut updated = current.clone();
+        updated.sparse_index = None;
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated);
+        self.sparse_indexes.write().await.remove(&collection_id);
+        Ok(())
+    }
+
+    /// Check a sparse vector against the collection's sparse index settings,
+    /// e.g. before writing its document.
+    pub async fn check_sparse_vector(
+        &self,
+        collection_id: CollectionId,
+        vector: &SparseVector,
+    ) -> CoreResult<()> {
+        self.get_collection(collection_id).await?;
+        let sparse_indexes = self.sparse_indexes.read().await;
+        let index = sparse_indexes.get(&collection_id).ok_or_else(|| {
+            CoreError::ValidationError(format!("collection {} has no sparse index", collection_id))
+        })?;
+        index.config().check(vector)
+    }
+
+    /// Store a document's sparse vector, replacing any previous one (an empty
+    /// vector removes it). The document is rewritten with the vector, which
+    /// stays with it until it is replaced or deleted.
+    pub async fn set_sparse_vector(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+        vector: SparseVector,
+    ) -> CoreResult<()> {
+        self.check_writable(collection_id).await?;
+        let _lock = self.write_locks.lock(collection_id, doc_id).await;
+        let previous = self
+            .read_document(collection_id, doc_id)
+            .await?
+            .ok_or_else(|| CoreError::not_found("Document", doc_id.to_string()))?;
+        if !self
+            .sparse_indexes
+            .read()
+            .await
+            .contains_key(&collection_id)
+        {
+            return Err(CoreError::not_found(
+                "Sparse index",
+                collection_id.to_string(),
+            ));
+        }
+
+        let mut doc = previous.clone();
+        doc.sparse_vector = Some(vector);
+        self.write_document(collection_id, doc, Some(previous))
+            .await?;
+        Ok(())
+    }
+
+    /// A document's stored sparse vector.
+    pub async fn sparse_vector(
+        &self,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> Option<SparseVector> {
+        let sparse_indexes = self.sparse_indexes.read().await;
+        sparse_indexes.get(&collection_id)?.get(doc_id).cloned()
+    }
+
+    /// Sparse search: the `top_k` documents whose stored sparse vectors have
+    /// the highest dot product with `query`.
+    ///
+    /// The collection's default filter applies as for dense searches.
+    pub async fn sparse_search(
+        &self,
+        collection_id: CollectionId,
+        query: &SparseVector,
+        top_k: usize,
+    ) -> CoreResult<Vec<SearchResult>> {
+        query.validate()?;
+        if top_k == 0 {
+            return Err(CoreError::ValidationError(
+                "top_k must be greater than 0".to_string(),
+            ));
+        }
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_top_k(top_k)?;
+
+        let defaults = self.search_defaults(collection_id).await?;
+        let fetch_k = match &defaults.filter {
+            Some(_) => top_k * FILTER_OVERFETCH,
+            None => top_k,
+        };
+        let ranked = {
+            let sparse_indexes = self.sparse_indexes.read().await;
+            let index = sparse_indexes
+                .get(&collection_id)
+                .ok_or_else(|| CoreError::not_found("Sparse index", collection_id.to_string()))?;
+            index.search(query, fetch_k)
+        };
+
+        let doc_ids: Vec<DocumentId> = ranked.iter().map(|(doc_id, _)| *doc_id).collect();
+        let docs = self.get_many(collection_id, &doc_ids).await?;
+        let mut results: Vec<SearchResult> = ranked
+            .into_iter()
+            .zip(docs)
+            .filter_map(|((doc_id, score), doc)| {
+                let doc = doc?;
+                Some(SearchResult {
+                    doc_id,
+                    external_id: doc.external_id,
+                    score,
+                    metadata: doc.metadata,
+                    vector: None,
+                })
+            })
+            .collect();
+        if let Some(filter) = &defaults.filter {
+            results.retain(|r| filter.matches(r.metadata.as_ref()));
+        }
+        results.truncate(top_k);
+        Ok(results)
+    }
+}
@@ -0,0 +1,119 @@
+//! Subscriptions to a collection's writes: change streams, and standing
+//! queries notified of inserts matching them.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, SubscriptionId};
+use std::sync::Arc;
+
+use super::CollectionService;
+use crate::changes::ChangeSubscription;
+use crate::standing::{StandingQuery, StandingQueryInfo, StandingQueryMatch, StandingQuerySpec};
+
+impl CollectionService {
+    /// Follow the writes to a collection: those logged after the event with
+    /// cursor `since`, then each new one (see `changes`).
+    ///
+    /// The stream ends when the collection is deleted.
+    pub async fn subscribe_changes(
+        &self,
+        collection_id: CollectionId,
+        since: Option<&str>,
+    ) -> CoreResult<ChangeSubscription> {
+        self.get_collection(collection_id).await?;
+        self.changes.subscribe(collection_id, since)
+    }
+
+    /// Register a standing query: subscribers are notified whenever a newly
+    /// inserted document would rank in its top-K.
+    pub async fn create_standing_query(
+        &self,
+        collection_id: CollectionId,
+        spec: StandingQuerySpec,
+    ) -> CoreResult<StandingQueryInfo> {
+        let collection = self.get_collection(collection_id).await?;
+        if spec.vector.len() != collection.dimension as usize {
+            return Err(CoreError::ValidationError(format!(
+                "Vector dimension mismatch: expected {}, got {}",
+                collection.dimension,
+                spec.vector.len()
+            )));
+        }
+        self.collection_policy
+            .read()
+            .await
+            .limits
+            .check_top_k(spec.top_k)?;
+
+        let documents = self.list_documents(collection_id).await?;
+        let query = StandingQuery::new(collection_id, spec, collection.metric, &documents)?;
+        let info = query.info();
+        self.standing_queries
+            .write()
+            .await
+            .insert(query.id(), Arc::new(query));
+        Ok(info)
+    }
+
+    /// List standing queries, optionally for one collection.
+    pub async fn list_standing_queries(
+        &self,
+        collection_id: Option<CollectionId>,
+    ) -> Vec<StandingQueryInfo> {
+        let queries = self.standing_queries.read().await;
+        let mut infos: Vec<StandingQueryInfo> = queries
+            .values()
+            .filter(|q| collection_id.map_or(true, |cid| q.collection_id() == cid))
+            .map(|q| q.info())
+            .collect();
+        infos.sort_by_key(|info| info.created_at);
+        infos
+    }
+
+    /// Get a standing query.
+    pub async fn get_standing_query(&self, id: SubscriptionId) -> CoreResult<StandingQueryInfo> {
+        self.standing_queries
+            .read()
+            .await
+            .get(&id)
+            .map(|q| q.info())
+            .ok_or_else(|| CoreError::not_found("Subscription", id.to_string()))
+    }
+
+    /// Delete a standing query; open subscriptions to it are closed.
+    pub async fn delete_standing_query(&self, id: SubscriptionId) -> CoreResult<()> {
+        self.standing_queries
+            .write()
+            .await
+            .remove(&id)
+            .map(|_| ())
+            .ok_or_else(|| CoreError::not_found("Subscription", id.to_string()))
+    }
+
+    /// Receive matches of a standing query from now on.
+    ///
+    /// The stream ends when the query (or its collection) is deleted.
+    pub async fn subscribe_standing_query(
+        &self,
+        id: SubscriptionId,
+    ) -> CoreResult<tokio::sync::broadcast::Receiver<StandingQueryMatch>> {
+        self.standing_queries
+            .read()
+            .await
+            .get(&id)
+            .map(|q| q.subscribe())
+            .ok_or_else(|| CoreError::not_found("Subscription", id.to_string()))
+    }
+
+    /// Standing queries registered on a collection.
+    pub(super) async fn standing_queries_for(
+        &self,
+        collection_id: CollectionId,
+    ) -> Vec<Arc<StandingQuery>> {
+        self.standing_queries
+            .read()
+            .await
+            .values()
+            .filter(|q| q.collection_id() == collection_id)
+            .cloned()
+            .collect()
+    }
+}
@@ -0,0 +1,449 @@
+//! Tenant provisioning and lifecycle (batch creation, soft deletion with a
+//! retention period, suspension) and API key rotation.
+
+use akidb_core::{
+    generate_api_key, hash_api_key, ApiKeyId, ApiKeyRepository, CoreError, CoreResult,
+    CreateTenantRequest, RotateApiKeyRequest, RotateApiKeyResponse, TenantCatalog, TenantDeletion,
+    TenantDescriptor, TenantId, TenantStatus, MAX_KEY_ROTATION_GRACE_SECS,
+};
+use chrono::Utc;
+use std::collections::{HashMap, HashSet};
+use std::sync::Arc;
+use tokio::sync::RwLock;
+use tokio::task::{JoinError, JoinSet};
+
+use super::CollectionService;
+use crate::events::{EventComponent, EventLevel};
+use crate::ordering::{ListOrder, Page};
+use crate::provisioning::{
+    check_tenant_batch_size, BatchCreateTenantsReport, DeleteTenantOptions, TenantCreateResult,
+    TenantCreateStatus, TenantDeleteMode, TenantFilter, TENANT_BATCH_CONCURRENCY,
+};
+use crate::schedule::is_expired;
+
+/// The tenant this node serves, and the stores tenants and their API keys
+/// are provisioned in.
+#[derive(Default)]
+pub(super) struct TenantState {
+    // Tenant served by this node, named in usage reports
+    id: RwLock<Option<TenantId>>,
+
+    // Whether the served tenant is suspended (its requests are rejected)
+    suspended: RwLock<bool>,
+
+    // Catalog tenants are provisioned in (tenant provisioning disabled when None)
+    catalog: RwLock<Option<Arc<dyn TenantCatalog>>>,
+
+    // Tenants' API keys (key rotation disabled when None)
+    api_keys: RwLock<Option<Arc<dyn ApiKeyRepository>>>,
+}
+
+impl TenantState {
+    /// The tenant this node serves, if set.
+    pub(super) async fn id(&self) -> Option<TenantId> {
+        *self.id.read().await
+    }
+}
+
+impl CollectionService {
+    /// Set the catalog tenants are provisioned in.
+    ///
+    /// Pass `None` to disable tenant provisioning.
+    pub async fn set_tenant_catalog(&self, catalog: Option<Arc<dyn TenantCatalog>>) {
+        *self.tenant.catalog.write().await = catalog;
+    }
+
+    async fn tenant_catalog(&self) -> CoreResult<Arc<dyn TenantCatalog>> {
+        self.tenant
+            .catalog
+            .read()
+            .await
+            .clone()
+            .ok_or_else(|| CoreError::invalid_state("tenant provisioning is not configured"))
+    }
+
+    /// Create tenants, up to `TENANT_BATCH_CONCURRENCY` at a time.
+    ///
+    /// Each tenant is created on its own and the report lists every item's
+    /// outcome in request order. A tenant that already exists with the
+    /// requested name and quotas is reported as existing, so a batch can be
+    /// sent again after a partial failure.
+    pub async fn batch_create_tenants(
+        &self,
+        requests: Vec<CreateTenantRequest>,
+    ) -> CoreResult<BatchCreateTenantsReport> {
+        check_tenant_batch_size(requests.len())?;
+        let catalog = self.tenant_catalog().await?;
+        let existing: HashMap<String, TenantDescriptor> = catalog
+            .list()
+            .await?
+            .into_iter()
+            .map(|tenant| (tenant.slug.clone(), tenant))
+            .collect();
+
+        let mut results = Vec::with_capacity(requests.len());
+        let mut slugs = HashSet::new();
+        let mut tasks = JoinSet::new();
+        let joined = |joined: Result<TenantCreateResult, JoinError>| {
+            joined.map_err(|e| CoreError::internal(format!("tenant creation task failed: {}", e)))
+        };
+        for (index, request) in requests.into_iter().enumerate() {
+            let slug = request.slug.clone();
+            if let Err(e) = request.validate() {
+                results.push(TenantCreateResult::failed(index, slug, &e));
+                continue;
+            }
+            if !slugs.insert(slug.clone()) {
+                let e = CoreError::ValidationError(format!(
+                    "tenant slug `{}` appears more than once in the batch",
+                    slug
+                ));
+                results.push(TenantCreateResult::failed(index, slug, &e));
+                continue;
+            }
+            if let Some(tenant) = existing.get(&slug) {
+                results.push(if request.matches(tenant) {
+                    TenantCreateResult::succeeded(
+                        index,
+                        slug,
+                        TenantCreateStatus::Existing,
+                        tenant.tenant_id,
+                    )
+                } else {
+                    let e = CoreError::already_exists("Tenant", slug.clone());
+                    TenantCreateResult::failed(index, slug, &e)
+                });
+                continue;
+            }
+
+            if tasks.len() >= TENANT_BATCH_CONCURRENCY {
+                if let Some(result) = tasks.join_next().await {
+                    results.push(joined(result)?);
+                }
+            }
+            let catalog = Arc::clone(&catalog);
+            tasks.spawn(async move {
+                let tenant = request.into_descriptor();
+                match catalog.create(&tenant).await {
+                    Ok(()) => TenantCreateResult::succeeded(
+                        index,
+                        slug,
+                        TenantCreateStatus::Created,
+                        tenant.tenant_id,
+                    ),
+                    Err(e) => TenantCreateResult::failed(index, slug, &e),
+                }
+            });
+        }
+        while let Some(result) = tasks.join_next().await {
+            results.push(joined(result)?);
+        }
+
+        let report = BatchCreateTenantsReport::from_results(results);
+        tracing::info!(
+            "Provisioned tenants: {} created, {} existing, {} failed",
+            report.created,
+            report.existing,
+            report.failed
+        );
+        Ok(report)
+    }
+
+    /// One page of the tenants matching `filter`, in `order` (see
+    /// `ListOrder`).
+    pub async fn list_tenants(
+        &self,
+        filter: &TenantFilter,
+        order: ListOrder,
+        cursor: Option<&str>,
+        limit: usize,
+    ) -> CoreResult<Page<TenantDescriptor>> {
+        let tenants = self
+            .tenant_catalog()
+            .await?
+            .list()
+            .await?
+            .into_iter()
+            .filter(|tenant| filter.matches(tenant))
+            .collect();
+        order.paginate(tenants, cursor, limit)
+    }
+
+    /// Delete a tenant (not the one this server serves).
+    ///
+    /// A soft delete (the default) decommissions the tenant and keeps it for
+    /// the retention period, during which `undelete_tenant` restores it; it
+    /// returns when the tenant will be purged. A hard delete removes it at
+    /// once, soft-deleted or not.
+    pub async fn delete_tenant(
+        &self,
+        tenant_id: TenantId,
+        options: &DeleteTenantOptions,
+    ) -> CoreResult<Option<TenantDeletion>> {
+        options.validate()?;
+        if self.tenant.id().await == Some(tenant_id) {
+            return Err(CoreError::invalid_state(
+                "cannot delete the tenant this server serves",
+            ));
+        }
+        let catalog = self.tenant_catalog().await?;
+        let mut tenant = catalog
+            .get(tenant_id)
+            .await?
+            .ok_or_else(|| CoreError::not_found("Tenant", tenant_id.to_string()))?;
+
+        match options.mode {
+            TenantDeleteMode::Hard => {
+                catalog.delete(tenant_id).await?;
+                tracing::info!("Deleted tenant {} ({})", tenant.slug, tenant_id);
+                Ok(None)
+            }
+            TenantDeleteMode::Soft => {
+                let deletion = tenant.soft_delete(options.retention())?;
+                catalog.update(&tenant).await?;
+                tracing::info!(
+                    "Soft-deleted tenant {} ({}), purged after {}",
+                    tenant.slug,
+                    tenant_id,
+                    deletion.purge_after
+                );
+                Ok(Some(deletion))
+            }
+        }
+    }
+
+    /// Restore a soft-deleted tenant before it is purged; it becomes active.
+    pub async fn undelete_tenant(&self, tenant_id: TenantId) -> CoreResult<TenantDescriptor> {
+        let catalog = self.tenant_catalog().await?;
+        let mut tenant = catalog
+            .get(tenant_id)
+            .await?
+            .ok_or_else(|| CoreError::not_found("Tenant", tenant_id.to_string()))?;
+        tenant.undelete()?;
+        catalog.update(&tenant).await?;
+        tracing::info!("Restored tenant {} ({})", tenant.slug, tenant_id);
+        Ok(tenant)
+    }
+
+    /// Suspend a tenant, e.g. a delinquent account: its data is kept, but
+    /// requests to a node serving it are rejected until it is resumed.
+    pub async fn suspend_tenant(&self, tenant_id: TenantId) -> CoreResult<TenantDescriptor> {
+        self.set_tenant_status(tenant_id, TenantDescriptor::suspend)
+            .await
+    }
+
+    /// Reactivate a suspended tenant.
+    pub async fn resume_tenant(&self, tenant_id: TenantId) -> CoreResult<TenantDescriptor> {
+        self.set_tenant_status(tenant_id, TenantDescriptor::resume)
+            .await
+    }
+
+    async fn set_tenant_status(
+        &self,
+        tenant_id: TenantId,
+        transition: fn(&mut TenantDescriptor) -> CoreResult<()>,
+    ) -> CoreResult<TenantDescriptor> {
+        let catalog = self.tenant_catalog().await?;
+        let mut tenant = catalog
+            .get(tenant_id)
+            .await?
+            .ok_or_else(|| CoreError::not_found("Tenant", tenant_id.to_string()))?;
+        transition(&mut tenant)?;
+        catalog.update(&tenant).await?;
+        if self.tenant.id().await == Some(tenant_id) {
+            *self.tenant.suspended.write().await = tenant.status == TenantStatus::Suspended;
+        }
+        tracing::info!(
+            "Tenant {} ({}) is now {}",
+            tenant.slug,
+            tenant_id,
+            tenant.status.as_str()
+        );
+        Ok(tenant)
+    }
+
+    /// Whether the tenant this node serves is suspended.
+    pub async fn is_tenant_suspended(&self) -> bool {
+        *self.tenant.suspended.read().await
+    }
+
+    /// Read whether the served tenant is suspended from the tenant catalog,
+    /// e.g. at startup or after another node suspended it.
+    pub async fn load_tenant_suspension(&self) -> CoreResult<bool> {
+        let Some(tenant_id) = self.tenant.id().await else {
+            return Ok(false);
+        };
+        let suspended = self
+            .tenant_catalog()
+            .await?
+            .get(tenant_id)
+            .await?
+            .map_or(false, |tenant| tenant.status == TenantStatus::Suspended);
+        *self.tenant.suspended.write().await = suspended;
+        Ok(suspended)
+    }
+
+    /// Permanently delete soft-deleted tenants past their retention; returns
+    /// how many were purged.
+    pub async fn purge_deleted_tenants(&self) -> CoreResult<usize> {
+        let Some(catalog) = self.tenant.catalog.read().await.clone() else {
+            return Ok(0);
+        };
+        let now = Utc::now();
+        let mut purged = 0;
+        for tenant in catalog.list().await? {
+            if let Some(deletion) = tenant.deletion() {
+                if deletion.purge_after <= now {
+                    catalog.delete(tenant.tenant_id).await?;
+                    tracing::info!("Purged tenant {} ({})", tenant.slug, tenant.tenant_id);
+                    purged += 1;
+                }
+            }
+        }
+        Ok(purged)
+    }
+
+    /// Spawn a background task calling `purge_deleted_tenants` every
+    /// `interval`.
+    pub fn spawn_tenant_purger(
+        self: &Arc<Self>,
+        interval: std::time::Duration,
+    ) -> tokio::task::JoinHandle<()> {
+        let service = Arc::clone(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            loop {
+                ticker.tick().await;
+                if let Err(e) = service.purge_deleted_tenants().await {
+                    service.events.publish(
+                        EventLevel::Warning,
+                        EventComponent::TenantPurger,
+                        None,
+                        format!("Failed to purge deleted tenants: {}", e),
+                    );
+                }
+            }
+        })
+    }
+
+    /// Spawn a background task calling `load_tenant_suspension` every
+    /// `interval`, so suspending the tenant through another node takes effect
+    /// here too.
+    pub fn spawn_suspension_refresher(
+        self: &Arc<Self>,
+        interval: std::time::Duration,
+    ) -> tokio::task::JoinHandle<()> {
+        let service = Arc::clone(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            loop {
+                ticker.tick().await;
+                if let Err(e) = service.load_tenant_suspension().await {
+                    service.events.publish(
+                        EventLevel::Warning,
+                        EventComponent::SuspensionRefresher,
+                        None,
+                        format!("Failed to refresh tenant suspension: {}", e),
+                    );
+                }
+            }
+        })
+    }
+
+    /// Set the repository tenants' API keys are stored in.
+    ///
+    /// Pass `None` to disable key rotation.
+    pub async fn set_api_key_repository(&self, repository: Option<Arc<dyn ApiKeyRepository>>) {
+        *self.tenant.api_keys.write().await = repository;
+    }
+
+    async fn api_key_repository(&self) -> CoreResult<Arc<dyn ApiKeyRepository>> {
+        self.tenant
+            .api_keys
+            .read()
+            .await
+            .clone()
+            .ok_or_else(|| CoreError::invalid_state("API key management is not configured"))
+    }
+
+    /// Replace a tenant's API key with a new one with the same name,
+    /// permissions and lifetime.
+    ///
+    /// The old key stays valid for the request's grace period so clients can
+    /// switch over, or is revoked at once without one. The new key is stored
+    /// before the old one is retired, so a failure never leaves the tenant
+    /// without a working key.
+    pub async fn rotate_api_key(
+        &self,
+        tenant_id: TenantId,
+        key_id: ApiKeyId,
+        request: &RotateApiKeyRequest,
+    ) -> CoreResult<RotateApiKeyResponse> {
+        if request.grace_period_secs > MAX_KEY_ROTATION_GRACE_SECS {
+            return Err(CoreError::ValidationError(format!(
+                "grace_period_secs must be at most {} (got {})",
+                MAX_KEY_ROTATION_GRACE_SECS, request.grace_period_secs
+            )));
+        }
+        let repository = self.api_key_repository().await?;
+        let previous = repository
+            .get(key_id)
+            .await?
+            .filter(|key| key.tenant_id == tenant_id)
+            .ok_or_else(|| CoreError::not_found("API key", key_id.to_string()))?;
+        if previous.is_expired() {
+            return Err(CoreError::invalid_state(format!(
+                "API key {} has expired",
+                key_id
+            )));
+        }
+
+        let descriptor = previous.rotated();
+        let api_key = generate_api_key();
+        repository
+            .create(&descriptor, &hash_api_key(&api_key))
+            .await?;
+        let previous_expires_at = if request.grace_period_secs == 0 {
+            repository.delete(key_id).await?;
+            None
+        } else {
+            let grace_end =
+                Utc::now() + chrono::Duration::seconds(request.grace_period_secs as i64);
+            let expires_at = previous
+                .expires_at
+                .map_or(grace_end, |expires_at| expires_at.min(grace_end));
+            repository.set_expires_at(key_id, Some(expires_at)).await?;
+            Some(expires_at)
+        };
+        tracing::info!(
+            "Rotated API key {} of tenant {} (replaced by {})",
+            key_id,
+            tenant_id,
+            descriptor.key_id
+        );
+
+        Ok(RotateApiKeyResponse {
+            descriptor,
+            api_key,
+            previous_key_id: key_id,
+            previous_expires_at,
+        })
+    }
+
+    /// Set the tenant this node serves (single-tenant mode), named in usage
+    /// reports.
+    pub async fn set_tenant_id(&self, tenant_id: TenantId) {
+        *self.tenant.id.write().await = Some(tenant_id);
+    }
+
+    /// ID of the stored API key `api_key` (the plaintext key, as sent in the
+    /// `X-API-Key` header). None for unknown keys, and when no API key
+    /// repository is set.
+    pub async fn find_api_key(&self, api_key: &str) -> CoreResult<Option<ApiKeyId>> {
+        let Some(repository) = self.tenant.api_keys.read().await.clone() else {
+            return Ok(None);
+        };
+        let key = repository.get_by_hash(&hash_api_key(api_key)).await?;
+        Ok(key.map(|key| key.key_id))
+    }
+}
@@ -0,0 +1,139 @@
+//! Text analysis settings of a collection: stopwords, synonyms and
+//! per-field analyzers used to tokenize text for sparse encoding.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::Utc;
+
+use super::{encode_setting, CollectionService};
+use crate::analysis::{AnalyzedToken, AnalyzerSettings, TextAnalysis};
+
+impl CollectionService {
+    /// Get the text analysis configuration for a collection (defaults if not configured).
+    pub async fn text_analysis(&self, collection_id: CollectionId) -> CoreResult<TextAnalysis> {
+        self.get_collection(collection_id).await?;
+        let text_analysis = self.text_analysis.read().await;
+        Ok(text_analysis
+            .get(&collection_id)
+            .cloned()
+            .unwrap_or_default())
+    }
+
+    /// Replace the stopword list applied at text analysis time.
+    pub async fn set_stopwords(
+        &self,
+        collection_id: CollectionId,
+        stopwords: Vec<String>,
+    ) -> CoreResult<TextAnalysis> {
+        self.update_text_analysis(collection_id, |analysis| {
+            analysis.analyzer = std::mem::take(&mut analysis.analyzer).with_stopwords(stopwords);
+            Ok(())
+        })
+        .await
+    }
+
+    /// Replace the synonym sets applied at text analysis time.
+    pub async fn set_synonyms(
+        &self,
+        collection_id: CollectionId,
+        synonyms: Vec<Vec<String>>,
+    ) -> CoreResult<TextAnalysis> {
+        self.update_text_analysis(collection_id, |analysis| {
+            analysis.analyzer = analysis.analyzer.clone().with_synonyms(synonyms)?;
+            Ok(())
+        })
+        .await
+    }
+
+    /// Set (or with `None`, clear) the analyzer settings for a text field.
+    pub async fn set_field_analyzer(
+        &self,
+        collection_id: CollectionId,
+        field: &str,
+        settings: Option<AnalyzerSettings>,
+    ) -> CoreResult<TextAnalysis> {
+        if let Some(settings) = &settings {
+            settings.validate()?;
+        }
+        self.update_text_analysis(collection_id, |analysis| {
+            match settings {
+                Some(settings) => analysis.fields.insert(field.to_string(), settings),
+                None => analysis.fields.remove(field),
+            };
+            Ok(())
+        })
+        .await
+    }
+
+    /// Show how text is analyzed (debug aid).
+    ///
+    /// Uses the analyzer for `field` (collection defaults if `None`), with
+    /// `overrides` replacing its settings for this call only.
+    pub async fn analyze(
+        &self,
+        collection_id: CollectionId,
+        field: Option<&str>,
+        text: &str,
+        overrides: Option<AnalyzerSettings>,
+    ) -> CoreResult<Vec<AnalyzedToken>> {
+        let analysis = self.text_analysis(collection_id).await?;
+        let mut analyzer = match field {
+            Some(field) => analysis.analyzer_for(field),
+            None => analysis.analyzer,
+        };
+        if let Some(settings) = overrides {
+            settings.validate()?;
+            analyzer = analyzer.with_settings(settings);
+        }
+        Ok(analyzer.analyze(text))
+    }
+
+    /// Apply `update` to a collection's text analysis configuration atomically.
+    /// The configuration is stored with the collection.
+    async fn update_text_analysis<F>(
+        &self,
+        collection_id: CollectionId,
+        update: F,
+    ) -> CoreResult<TextAnalysis>
+    where
+        F: FnOnce(&mut TextAnalysis) -> CoreResult<()>,
+    {
+        let mut collections = self.collections.write().await;
+        let current = collections
+            .get(&collection_id)
+            .ok_or_else(|| CoreError::not_found("Collection", collection_id.to_string()))?;
+
+        let mut text_analysis = self.text_analysis.write().await;
+        let mut analysis = text_analysis
+            .get(&collection_id)
+            .cloned()
+            .unwrap_or_default();
+        update(&mut analysis)?;
+
+        let mut updated = current.clone();
+        updated.text_analysis = Some(encode_setting("text analysis", &analysis)?);
+        updated.updated_at = Utc::now();
+        if let Some(repo) = &self.repository {
+            repo.update(&updated).await?;
+        }
+        collections.insert(collection_id, updated);
+        text_analysis.insert(collection_id, analysis.clone());
+        Ok(analysis)
+    }
+
+    /// Give `target` the text analysis configuration of `source` (if it has one).
+    pub(super) async fn copy_text_analysis(
+        &self,
+        source: CollectionId,
+        target: CollectionId,
+    ) -> CoreResult<()> {
+        let analysis = self.text_analysis.read().await.get(&source).cloned();
+        if let Some(analysis) = analysis {
+            self.update_text_analysis(target, |current| {
+                *current = analysis;
+                Ok(())
+            })
+            .await?;
+        }
+        Ok(())
+    }
+}
@@ -0,0 +1,303 @@
+//! Write transactions: buffered inserts and deletes across collections,
+//! committed together or not at all.
+
+use akidb_core::{
+    CollectionId, CoreError, CoreResult, DistanceMetric, DocumentId, TransactionId, VectorDocument,
+};
+use akidb_storage::BatchWrite;
+use chrono::Utc;
+use std::collections::{BTreeMap, HashMap};
+
+use super::CollectionService;
+use crate::schedule::is_expired;
+use crate::transaction::{
+    CommitReport, Transaction, TransactionInfo, TransactionOp, MAX_OPEN_TRANSACTIONS,
+};
+
+impl CollectionService {
+    /// Begin a write transaction.
+    ///
+    /// Writes buffered with `transaction_insert` and `transaction_delete` are
+    /// invisible to readers until `commit_transaction`, which applies them all
+    /// at once. The transaction is discarded if not committed within `timeout`
+    /// (default `DEFAULT_TRANSACTION_TIMEOUT`).
+    pub async fn begin_transaction(
+        &self,
+        timeout: Option<std::time::Duration>,
+    ) -> CoreResult<TransactionInfo> {
+        let transaction = Transaction::new(timeout)?;
+        let mut transactions = self.transactions.write().await;
+        let now = Utc::now();
+        transactions.retain(|_, tx| !tx.is_expired(now));
+        if transactions.len() >= MAX_OPEN_TRANSACTIONS {
+            return Err(CoreError::invalid_state(format!(
+                "too many open transactions (max {})",
+                MAX_OPEN_TRANSACTIONS
+            )));
+        }
+        let info = transaction.info();
+        transactions.insert(transaction.id, transaction);
+        tracing::debug!("Began transaction {}", info.id);
+        Ok(info)
+    }
+
+    /// Get an open transaction.
+    pub async fn get_transaction(
+        &self,
+        transaction_id: TransactionId,
+    ) -> CoreResult<TransactionInfo> {
+        self.with_transaction(transaction_id, |_| Ok(())).await
+    }
+
+    /// Buffer an insert. The collection and vector are checked now; other
+    /// failures surface at commit.
+    pub async fn transaction_insert(
+        &self,
+        transaction_id: TransactionId,
+        collection_id: CollectionId,
+        mut doc: VectorDocument,
+    ) -> CoreResult<TransactionInfo> {
+        let collection = self.get_collection(collection_id).await?;
+        let expected_dim = collection.dimension as usize;
+        if doc.vector.len() != expected_dim {
+            return Err(CoreError::ValidationError(format!(
+                "Vector dimension mismatch: expected {}, got {}",
+                expected_dim,
+                doc.vector.len()
+            )));
+        }
+        if let Some(i) = doc.vector.iter().position(|v| !v.is_finite()) {
+            return Err(CoreError::ValidationError(format!(
+                "Vector component {} is {}; only finite numbers are allowed",
+                i, doc.vector[i]
+            )));
+        }
+        if collection.metric == DistanceMetric::Cosine && doc.vector.iter().all(|v| *v == 0.0) {
+            return Err(CoreError::ValidationError(
+                "Cannot insert a zero vector into a Cosine collection".to_string(),
+            ));
+        }
+        // Logged as buffered at commit, so drop empty extra vectors now
+        doc.sparse_vector = doc.sparse_vector.filter(|vector| !vector.is_empty());
+        doc.named_vectors = doc.named_vectors.filter(|vectors| !vectors.is_empty());
+        self.with_transaction(transaction_id, |tx| {
+            tx.push(TransactionOp::Insert {
+                collection_id,
+                document: doc,
+            })
+        })
+        .await
+    }
+
+    /// Buffer a delete.
+    pub async fn transaction_delete(
+        &self,
+        transaction_id: TransactionId,
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    ) -> CoreResult<TransactionInfo> {
+        self.get_collection(collection_id).await?;
+        self.with_transaction(transaction_id, |tx| {
+            tx.push(TransactionOp::Delete {
+                collection_id,
+                doc_id,
+            })
+        })
+        .await
+    }
+
+    /// Apply a transaction's writes in order. Readers see none of them
+    /// until all are applied.
+    ///
+    /// Every write is checked before any is applied: the collections must be
+    /// writable, inserted IDs free, and deleted documents stored and not
+    /// under legal hold. A transaction failing the checks changes nothing.
+    /// Each collection's writes are then logged as one WAL batch, which
+    /// recovery replays whole or not at all, and applied under the
+    /// documents' write locks. A transaction spanning collections logs one
+    /// batch per collection, so a crash between them keeps the first ones.
+    /// Either way the transaction is closed.
+    pub async fn commit_transaction(
+        &self,
+        transaction_id: TransactionId,
+    ) -> CoreResult<CommitReport> {
+        let transaction = self
+            .transactions
+            .write()
+            .await
+            .remove(&transaction_id)
+            .ok_or_else(|| CoreError::not_found("Transaction", transaction_id.to_string()))?;
+        if transaction.is_expired(Utc::now()) {
+            return Err(transaction_expired(transaction_id));
+        }
+
+        let _locks = self
+            .write_locks
+            .lock_all(
+                transaction
+                    .ops
+                    .iter()
+                    .map(|op| (op.collection_id(), op.doc_id())),
+            )
+            .await;
+        self.check_transaction(&transaction).await?;
+        self.log_transaction(&transaction).await?;
+
+        // Readers wait only while the logged writes reach the indexes
+        let _gate = self.commit_gate.write().await;
+        for (applied, op) in transaction.ops.iter().enumerate() {
+            let result = match op {
+                TransactionOp::Insert {
+                    collection_id,
+                    document,
+                } => self
+                    .apply_write(*collection_id, document.clone(), None, true)
+                    .await
+                    .map(|_| ()),
+                TransactionOp::Delete {
+                    collection_id,
+                    doc_id,
+                } => self.apply_delete(*collection_id, *doc_id, true).await,
+            };
+            if let Err(e) = result {
+                // Logged, so recovery still applies the rest
+                tracing::error!(
+                    "Transaction {} logged but failed to apply after {} of {} writes: {}",
+                    transaction_id,
+                    applied,
+                    transaction.ops.len(),
+                    e
+                );
+                return Err(CoreError::internal(format!(
+                    "Transaction {} logged but failed to apply after {} of {} writes: {}",
+                    transaction_id,
+                    applied,
+                    transaction.ops.len(),
+                    e
+                )));
+            }
+        }
+
+        let info = transaction.info();
+        tracing::info!(
+            "Committed transaction {} ({} inserts, {} deletes)",
+            transaction_id,
+            info.inserts,
+            info.deletes
+        );
+        Ok(CommitReport {
+            id: transaction_id,
+            inserted: info.inserts,
+            deleted: info.deletes,
+            committed_at: Utc::now(),
+        })
+    }
+
+    /// Store a checked transaction's writes, one WAL batch per collection.
+    /// Collections without a storage backend fall back to legacy
+    /// persistence, written one document at a time.
+    async fn log_transaction(&self, transaction: &Transaction) -> CoreResult<()> {
+        let mut batches: BTreeMap<CollectionId, Vec<BatchWrite>> = BTreeMap::new();
+        for op in &transaction.ops {
+            let write = match op {
+                TransactionOp::Insert { document, .. } => BatchWrite::Upsert(document.clone()),
+                TransactionOp::Delete { doc_id, .. } => BatchWrite::Delete(*doc_id),
+            };
+            batches.entry(op.collection_id()).or_default().push(write);
+        }
+
+        let backends = self.storage_backends.read().await;
+        for (collection_id, writes) in batches {
+            if let Some(storage_backend) = backends.get(&collection_id) {
+                storage_backend.write_batch(writes).await?;
+                continue;
+            }
+            let Some(persistence) = &self.vector_persistence else {
+                continue;
+            };
+            for write in writes {
+                match write {
+                    BatchWrite::Upsert(doc) => persistence.save_vector(collection_id, &doc).await?,
+                    BatchWrite::Delete(doc_id) => {
+                        persistence.delete_vector(collection_id, doc_id).await?
+                    }
+                }
+            }
+        }
+        Ok(())
+    }
+
+    /// Check that a transaction's writes can all be applied, in order. The
+    /// caller holds the documents' write locks.
+    async fn check_transaction(&self, transaction: &Transaction) -> CoreResult<()> {
+        // Whether each document is stored once the writes checked so far apply
+        let mut stored: HashMap<(CollectionId, DocumentId), bool> = HashMap::new();
+        for op in &transaction.ops {
+            let (collection_id, doc_id) = (op.collection_id(), op.doc_id());
+            self.check_writable(collection_id).await?;
+            let exists = match stored.get(&(collection_id, doc_id)) {
+                Some(exists) => *exists,
+                None => self.read_document(collection_id, doc_id).await?.is_some(),
+            };
+            match op {
+                TransactionOp::Insert { .. } if exists => {
+                    return Err(CoreError::already_exists("Document", doc_id.to_string()));
+                }
+                TransactionOp::Insert { document, .. } => {
+                    if let Some(vector) = &document.sparse_vector {
+                        self.check_sparse_vector(collection_id, vector).await?;
+                    }
+                    if let Some(vectors) = &document.named_vectors {
+                        self.check_named_vectors(collection_id, vectors).await?;
+                    }
+                }
+                TransactionOp::Delete { .. } if !exists => {
+                    return Err(CoreError::not_found("Document", doc_id.to_string()));
+                }
+                TransactionOp::Delete { .. } => {
+                    self.check_legal_holds(collection_id, doc_id).await?;
+                }
+            }
+            stored.insert(
+                (collection_id, doc_id),
+                matches!(op, TransactionOp::Insert { .. }),
+            );
+        }
+        Ok(())
+    }
+
+    /// Discard a transaction's buffered writes.
+    pub async fn rollback_transaction(&self, transaction_id: TransactionId) -> CoreResult<()> {
+        self.transactions
+            .write()
+            .await
+            .remove(&transaction_id)
+            .ok_or_else(|| CoreError::not_found("Transaction", transaction_id.to_string()))?;
+        tracing::debug!("Rolled back transaction {}", transaction_id);
+        Ok(())
+    }
+
+    async fn with_transaction(
+        &self,
+        transaction_id: TransactionId,
+        update: impl FnOnce(&mut Transaction) -> CoreResult<()>,
+    ) -> CoreResult<TransactionInfo> {
+        let mut transactions = self.transactions.write().await;
+        let transaction = transactions
+            .get_mut(&transaction_id)
+            .ok_or_else(|| CoreError::not_found("Transaction", transaction_id.to_string()))?;
+        if transaction.is_expired(Utc::now()) {
+            transactions.remove(&transaction_id);
+            return Err(transaction_expired(transaction_id));
+        }
+        update(transaction)?;
+        Ok(transaction.info())
+    }
+}
+
+fn transaction_expired(transaction_id: TransactionId) -> CoreError {
+    CoreError::invalid_state(format!(
+        "transaction {} expired before commit; its writes were discarded",
+        transaction_id
+    ))
+}
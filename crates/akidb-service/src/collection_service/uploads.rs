@@ -0,0 +1,468 @@
+//! Resumable imports: uploads sent in parts (or straight to the import bucket
+//! through signed URLs), then imported into a collection.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, UploadId};
+use akidb_storage::object_store::{ObjectStore, MAX_PRESIGN_EXPIRY};
+use chrono::Utc;
+use std::collections::HashMap;
+use std::sync::Arc;
+use tokio::sync::RwLock;
+
+use super::CollectionService;
+use crate::manifest::ExportManifest;
+use crate::progress::{ImportProgress, ProgressChannels, IMPORT_PROGRESS_INTERVAL};
+use crate::upload::{
+    upload_dir, upload_object_key, validate_part, ImportRecord, ImportReport, LineSplitter,
+    SignedUploadUrl, Upload, UploadPart, UploadStatus, DEFAULT_UPLOAD_URL_EXPIRY,
+};
+
+/// Open and finished uploads, the bucket of direct-to-storage imports, and
+/// the progress streams of their watchers.
+#[derive(Default)]
+pub(super) struct Uploads {
+    uploads: RwLock<HashMap<UploadId, Upload>>,
+
+    // Bucket for direct-to-storage imports (signed upload URLs disabled when None)
+    store: RwLock<Option<Arc<dyn ObjectStore>>>,
+
+    // Progress events for dashboards watching imports
+    events: ProgressChannels<UploadId, ImportProgress>,
+}
+
+impl CollectionService {
+    /// Start a resumable upload of an NDJSON import file into a collection.
+    ///
+    /// With the `manifest` of an export file, the upload is only imported if
+    /// its parts are exactly the parts the manifest lists.
+    pub async fn create_upload(
+        &self,
+        collection_id: CollectionId,
+        manifest: Option<ExportManifest>,
+    ) -> CoreResult<Upload> {
+        if let Some(manifest) = &manifest {
+            manifest.validate()?;
+        }
+        self.get_collection(collection_id).await?;
+        let mut upload = Upload::new(collection_id);
+        upload.manifest = manifest;
+        self.uploads
+            .uploads
+            .write()
+            .await
+            .insert(upload.id, upload.clone());
+        Ok(upload)
+    }
+
+    /// List uploads, optionally for one collection.
+    pub async fn list_uploads(&self, collection_id: Option<CollectionId>) -> Vec<Upload> {
+        let uploads = self.uploads.uploads.read().await;
+        let mut uploads: Vec<Upload> = uploads
+            .values()
+            .filter(|u| collection_id.map_or(true, |cid| u.collection_id == cid))
+            .cloned()
+            .collect();
+        uploads.sort_by_key(|u| u.created_at);
+        uploads
+    }
+
+    /// Get an upload, including the parts received so far.
+    pub async fn get_upload(&self, upload_id: UploadId) -> CoreResult<Upload> {
+        self.uploads
+            .uploads
+            .read()
+            .await
+            .get(&upload_id)
+            .cloned()
+            .ok_or_else(|| CoreError::not_found("Upload", upload_id.to_string()))
+    }
+
+    /// Set the object store clients upload import files to with signed URLs.
+    ///
+    /// Pass `None` to disable signed upload URLs.
+    pub async fn set_import_store(&self, store: Option<Arc<dyn ObjectStore>>) {
+        *self.uploads.store.write().await = store;
+    }
+
+    /// Start an upload whose file the client PUTs directly to object storage.
+    ///
+    /// Returns a presigned URL valid for `expires_in` (default one hour). Once
+    /// the file is uploaded, `complete_upload` imports it, after checking it
+    /// against `manifest` if given.
+    pub async fn create_upload_url(
+        &self,
+        collection_id: CollectionId,
+        expires_in: Option<std::time::Duration>,
+        manifest: Option<ExportManifest>,
+    ) -> CoreResult<SignedUploadUrl> {
+        if let Some(manifest) = &manifest {
+            manifest.validate()?;
+        }
+        let expires_in = expires_in.unwrap_or(DEFAULT_UPLOAD_URL_EXPIRY);
+        if expires_in.as_secs() == 0 || expires_in > MAX_PRESIGN_EXPIRY {
+            return Err(CoreError::ValidationError(format!(
+                "expiry must be between 1 and {} seconds",
+                MAX_PRESIGN_EXPIRY.as_secs()
+            )));
+        }
+        let store = self.import_store().await?;
+        self.get_collection(collection_id).await?;
+
+        let mut upload = Upload::new(collection_id);
+        let key = upload_object_key(collection_id, upload.id);
+        let url = store.presign_put(&key, expires_in).await?;
+        upload.object_key = Some(key);
+        upload.manifest = manifest;
+        let expires_at = upload.created_at + chrono::Duration::seconds(expires_in.as_secs() as i64);
+
+        self.uploads
+            .uploads
+            .write()
+            .await
+            .insert(upload.id, upload.clone());
+        Ok(SignedUploadUrl {
+            upload,
+            url,
+            expires_at,
+        })
+    }
+
+    async fn import_store(&self) -> CoreResult<Arc<dyn ObjectStore>> {
+        self.uploads
+            .store
+            .read()
+            .await
+            .clone()
+            .ok_or_else(|| CoreError::invalid_state("signed upload URLs are not configured"))
+    }
+
+    /// Store one part of an upload.
+    ///
+    /// Parts may arrive in any order; re-sending a part replaces it.
+    pub async fn upload_part(
+        &self,
+        upload_id: UploadId,
+        part_number: u32,
+        data: Vec<u8>,
+    ) -> CoreResult<UploadPart> {
+        validate_part(part_number, data.len())?;
+        let path = {
+            let uploads = self.uploads.uploads.read().await;
+            let upload = uploads
+                .get(&upload_id)
+                .ok_or_else(|| CoreError::not_found("Upload", upload_id.to_string()))?;
+            Self::require_open(upload)?;
+            if upload.object_key.is_some() {
+                return Err(CoreError::invalid_state(
+                    "upload uses a signed URL; PUT the file to the URL instead",
+                ));
+            }
+            upload.part_path(part_number)
+        };
+
+        // Write outside the lock; the rename makes a re-sent part replace the
+        // old one atomically.
+        let io_err =
+            |e: std::io::Error| CoreError::internal(format!("Failed to spool part: {}", e));
+        tokio::fs::create_dir_all(upload_dir(upload_id))
+            .await
+            .map_err(io_err)?;
+        let tmp = path.with_extension("tmp");
+        tokio::fs::write(&tmp, &data).await.map_err(io_err)?;
+        tokio::fs::rename(&tmp, &path).await.map_err(io_err)?;
+
+        let mut uploads = self.uploads.uploads.write().await;
+        let upload = uploads
+            .get_mut(&upload_id)
+            .ok_or_else(|| CoreError::not_found("Upload", upload_id.to_string()))?;
+        Self::require_open(upload)?;
+        let part = UploadPart {
+            part_number,
+            size_bytes: data.len() as u64,
+            uploaded_at: Utc::now(),
+        };
+        upload.parts.insert(part_number, part.clone());
+        upload.updated_at = part.uploaded_at;
+        Ok(part)
+    }
+
+    /// Import all parts of an upload, in part order (or the object uploaded
+    /// with a signed URL).
+    ///
+    /// Records that fail to parse or insert are counted in the report and do
+    /// not stop the import. If reading the data fails, or it does not match
+    /// the upload's export manifest, nothing is imported and the upload is
+    /// left open so completion can be retried (e.g. after re-sending a part).
+    pub async fn complete_upload(&self, upload_id: UploadId) -> CoreResult<ImportReport> {
+        let (collection_id, paths, object_key, manifest) = {
+            let mut uploads = self.uploads.uploads.write().await;
+            let upload = uploads
+                .get_mut(&upload_id)
+                .ok_or_else(|| CoreError::not_found("Upload", upload_id.to_string()))?;
+            Self::require_open(upload)?;
+            if upload.parts.is_empty() && upload.object_key.is_none() {
+                return Err(CoreError::invalid_state("upload has no parts"));
+            }
+            let missing = upload.missing_parts();
+            if !missing.is_empty() {
+                return Err(CoreError::invalid_state(format!(
+                    "upload is missing parts {:?}",
+                    missing
+                )));
+            }
+            if let Some(manifest) = &upload.manifest {
+                if upload.object_key.is_none() && upload.parts.len() != manifest.parts.len() {
+                    return Err(CoreError::invalid_state(format!(
+                        "upload has {} of the {} parts in its export manifest",
+                        upload.parts.len(),
+                        manifest.parts.len()
+                    )));
+                }
+            }
+            upload.status = UploadStatus::Importing;
+            upload.updated_at = Utc::now();
+            let paths: Vec<_> = upload
+                .parts
+                .keys()
+                .map(|&n| (n, upload.part_path(n)))
+                .collect();
+            (
+                upload.collection_id,
+                paths,
+                upload.object_key.clone(),
+                upload.manifest.clone(),
+            )
+        };
+        self.publish_import_progress(upload_id, UploadStatus::Importing, None);
+
+        let manifest = manifest.as_ref();
+        let result = match &object_key {
+            Some(key) => {
+                self.import_object(upload_id, collection_id, key, manifest)
+                    .await
+            }
+            None => {
+                self.import_parts(upload_id, collection_id, &paths, manifest)
+                    .await
+            }
+        };
+
+        let mut uploads = self.uploads.uploads.write().await;
+        let Some(upload) = uploads.get_mut(&upload_id) else {
+            // Aborted (or collection deleted) while importing
+            return result;
+        };
+        upload.updated_at = Utc::now();
+        match &result {
+            Ok(report) => {
+                upload.status = UploadStatus::Completed;
+                upload.report = Some(report.clone());
+                let upload = upload.clone();
+                drop(uploads);
+                self.publish_import_progress(upload_id, UploadStatus::Completed, Some(report));
+                self.discard_upload_data(&upload).await;
+            }
+            Err(_) => {
+                upload.status = UploadStatus::Open;
+                self.publish_import_progress(upload_id, UploadStatus::Open, None);
+            }
+        }
+        result
+    }
+
+    /// Abort an upload and discard its parts (or uploaded object).
+    pub async fn abort_upload(&self, upload_id: UploadId) -> CoreResult<()> {
+        let upload = {
+            let mut uploads = self.uploads.uploads.write().await;
+            let upload = uploads
+                .get_mut(&upload_id)
+                .ok_or_else(|| CoreError::not_found("Upload", upload_id.to_string()))?;
+            if matches!(
+                upload.status,
+                UploadStatus::Importing | UploadStatus::Completed
+            ) {
+                return Err(CoreError::invalid_state(format!(
+                    "cannot abort upload in state {:?}",
+                    upload.status
+                )));
+            }
+            upload.status = UploadStatus::Aborted;
+            upload.updated_at = Utc::now();
+            upload.clone()
+        };
+        self.publish_import_progress(upload_id, UploadStatus::Aborted, None);
+        self.discard_upload_data(&upload).await;
+        Ok(())
+    }
+
+    /// Receive an upload's import progress from now on: when the import
+    /// starts, every `IMPORT_PROGRESS_INTERVAL` records, and when it ends.
+    ///
+    /// The stream ends once the upload is completed or aborted; for such an
+    /// upload it is already closed.
+    pub async fn subscribe_import_progress(
+        &self,
+        upload_id: UploadId,
+    ) -> CoreResult<tokio::sync::broadcast::Receiver<ImportProgress>> {
+        let receiver = self.uploads.events.subscribe(upload_id);
+        // Checked after subscribing so a completion in between is not missed
+        let status = match self.get_upload(upload_id).await {
+            Ok(upload) => upload.status,
+            Err(e) => {
+                self.uploads.events.close(&upload_id);
+                return Err(e);
+            }
+        };
+        if matches!(status, UploadStatus::Completed | UploadStatus::Aborted) {
+            self.uploads.events.close(&upload_id);
+        }
+        Ok(receiver)
+    }
+
+    fn publish_import_progress(
+        &self,
+        upload_id: UploadId,
+        status: UploadStatus,
+        report: Option<&ImportReport>,
+    ) {
+        self.uploads
+            .events
+            .publish(&upload_id, ImportProgress::new(upload_id, status, report));
+        if matches!(status, UploadStatus::Completed | UploadStatus::Aborted) {
+            self.uploads.events.close(&upload_id);
+        }
+    }
+
+    /// Best-effort removal of an upload's spooled parts or uploaded object.
+    async fn discard_upload_data(&self, upload: &Upload) {
+        let _ = tokio::fs::remove_dir_all(upload.dir()).await;
+        if let Some(key) = &upload.object_key {
+            if let Ok(store) = self.import_store().await {
+                if let Err(e) = store.delete(key).await {
+                    tracing::warn!("Failed to delete import object {}: {}", key, e);
+                }
+            }
+        }
+    }
+
+    fn require_open(upload: &Upload) -> CoreResult<()> {
+        if upload.status != UploadStatus::Open {
+            return Err(CoreError::invalid_state(format!(
+                "upload {} is {:?}",
+                upload.id, upload.status
+            )));
+        }
+        Ok(())
+    }
+
+    /// Stream NDJSON records from spooled parts into a collection.
+    ///
+    /// With a manifest, every part is checked before the first record is
+    /// imported.
+    async fn import_parts(
+        &self,
+        upload_id: UploadId,
+        collection_id: CollectionId,
+        paths: &[(u32, std::path::PathBuf)],
+        manifest: Option<&ExportManifest>,
+    ) -> CoreResult<ImportReport> {
+        let read_part = |path| async move {
+            tokio::fs::read(path)
+                .await
+                .map_err(|e| CoreError::internal(format!("Failed to read part: {}", e)))
+        };
+        if let Some(manifest) = manifest {
+            for (part_number, path) in paths {
+                manifest.verify_part(*part_number, &read_part(path).await?)?;
+            }
+        }
+
+        let mut report = ImportReport::default();
+        let mut lines = LineSplitter::default();
+        for (_, path) in paths {
+            let data = read_part(path).await?;
+            for (number, line) in lines.push(&data) {
+                self.import_line(upload_id, collection_id, &line, number, &mut report)
+                    .await;
+            }
+        }
+        if let Some((number, line)) = lines.finish() {
+            self.import_line(upload_id, collection_id, &line, number, &mut report)
+                .await;
+        }
+        Ok(report)
+    }
+
+    /// Import NDJSON records from an object uploaded with a signed URL.
+    async fn import_object(
+        &self,
+        upload_id: UploadId,
+        collection_id: CollectionId,
+        key: &str,
+        manifest: Option<&ExportManifest>,
+    ) -> CoreResult<ImportReport> {
+        let store = self.import_store().await?;
+        let data = store.get(key).await.map_err(|e| match e {
+            CoreError::NotFound { .. } => {
+                CoreError::invalid_state("the file has not been uploaded to the signed URL yet")
+            }
+            e => e,
+        })?;
+        if let Some(manifest) = manifest {
+            manifest.verify_file(&data)?;
+        }
+
+        let mut report = ImportReport::default();
+        let mut lines = LineSplitter::default();
+        let mut records = lines.push(&data);
+        records.extend(lines.finish());
+        for (number, line) in records {
+            self.import_line(upload_id, collection_id, &line, number, &mut report)
+                .await;
+        }
+        Ok(report)
+    }
+
+    async fn import_line(
+        &self,
+        upload_id: UploadId,
+        collection_id: CollectionId,
+        line: &[u8],
+        line_number: u64,
+        report: &mut ImportReport,
+    ) {
+        if line.iter().all(u8::is_ascii_whitespace) {
+            return;
+        }
+        report.records += 1;
+        match serde_json::from_slice::<ImportRecord>(line) {
+            Ok(record) => match record.into_document() {
+                Ok(doc) => match self.insert(collection_id, doc).await {
+                    Ok(_) => report.inserted += 1,
+                    Err(e) => report.record_error(line_number, e.to_string()),
+                },
+                Err(e) => report.record_error(line_number, e.to_string()),
+            },
+            Err(e) => report.record_error(line_number, e.to_string()),
+        }
+        if report.records % IMPORT_PROGRESS_INTERVAL == 0 {
+            self.publish_import_progress(upload_id, UploadStatus::Importing, Some(report));
+        }
+    }
+
+    /// Drop the uploads into a deleted collection, with their spooled data.
+    pub(super) async fn forget_uploads(&self, collection_id: CollectionId) {
+        let dropped_uploads: Vec<Upload> = {
+            let mut uploads = self.uploads.uploads.write().await;
+            let ids: Vec<UploadId> = uploads
+                .values()
+                .filter(|u| u.collection_id == collection_id)
+                .map(|u| u.id)
+                .collect();
+            ids.iter().filter_map(|id| uploads.remove(id)).collect()
+        };
+        for upload in dropped_uploads {
+            self.uploads.events.close(&upload.id);
+            self.discard_upload_data(&upload).await;
+        }
+    }
+}
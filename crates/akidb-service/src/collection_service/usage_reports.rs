@@ -0,0 +1,103 @@
+//! Billing views: monthly usage reports and history of the served tenant,
+//! and cost estimates of searches and imports.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, TenantId};
+use chrono::{DateTime, Utc};
+use std::collections::HashMap;
+
+use super::CollectionService;
+use crate::cost::{estimate_import, estimate_search, ImportCostEstimate, SearchCostEstimate};
+use crate::usage::{UsageGranularity, UsageHistory, UsageMonth, UsageReport, UsageReportRow};
+
+impl CollectionService {
+    /// Usage of every collection metered in `month`, by collection name.
+    ///
+    /// Collections deleted since still appear (with an empty name), so a
+    /// month's report covers everything billed in it.
+    pub async fn usage_report(&self, month: UsageMonth) -> CoreResult<UsageReport> {
+        let names: HashMap<CollectionId, String> = self
+            .list_collections()
+            .await?
+            .into_iter()
+            .map(|collection| (collection.collection_id, collection.name))
+            .collect();
+        let mut rows: Vec<UsageReportRow> = self
+            .usage
+            .usage(month)
+            .into_iter()
+            .map(|(collection_id, usage)| UsageReportRow {
+                collection_id,
+                collection_name: names.get(&collection_id).cloned().unwrap_or_default(),
+                usage,
+            })
+            .collect();
+        rows.sort_by(|a, b| {
+            a.collection_name
+                .cmp(&b.collection_name)
+                .then_with(|| a.collection_id.as_uuid().cmp(&b.collection_id.as_uuid()))
+        });
+        Ok(UsageReport {
+            month,
+            tenant_id: self.tenant.id().await,
+            rows,
+        })
+    }
+
+    /// A tenant's searches, ingestion and storage over time.
+    ///
+    /// Usage is metered by the node serving the tenant; other tenants are
+    /// not found.
+    pub async fn usage_history(
+        &self,
+        tenant_id: TenantId,
+        from: DateTime<Utc>,
+        to: DateTime<Utc>,
+        granularity: UsageGranularity,
+    ) -> CoreResult<UsageHistory> {
+        if self.tenant.id().await != Some(tenant_id) {
+            return Err(CoreError::not_found("Tenant", tenant_id.to_string()));
+        }
+        Ok(UsageHistory {
+            tenant_id,
+            granularity,
+            from,
+            to,
+            points: self.usage.history(from, to, granularity)?,
+        })
+    }
+
+    /// Estimate the cost of running `queries` searches returning `top_k` results.
+    pub async fn estimate_search_cost(
+        &self,
+        collection_id: CollectionId,
+        top_k: usize,
+        queries: u64,
+    ) -> CoreResult<SearchCostEstimate> {
+        if top_k == 0 || queries == 0 {
+            return Err(CoreError::ValidationError(
+                "top_k and queries must be greater than 0".to_string(),
+            ));
+        }
+        let collection = self.get_collection(collection_id).await?;
+        let count = self.get_count(collection_id).await? as u64;
+        Ok(estimate_search(&collection, count, top_k as u64, queries))
+    }
+
+    /// Estimate the cost of importing `vectors` vectors with about
+    /// `avg_metadata_bytes` of metadata each.
+    pub async fn estimate_import_cost(
+        &self,
+        collection_id: CollectionId,
+        vectors: u64,
+        avg_metadata_bytes: u64,
+    ) -> CoreResult<ImportCostEstimate> {
+        let collection = self.get_collection(collection_id).await?;
+        let count = self.get_count(collection_id).await? as u64;
+        Ok(estimate_import(
+            &collection,
+            count,
+            vectors,
+            avg_metadata_bytes,
+        ))
+    }
+}
@@ -0,0 +1,120 @@
+//! In-place updates of a collection's mutable settings.
+//!
+//! Dimension and metric are fixed once vectors are stored, but the rest of a
+//! collection's configuration can change without deleting, recreating and
+//! re-ingesting it: description, metadata, HNSW parameters, document limit
+//! and default search parameters. See `CollectionService::update_collection`.
+
+use akidb_core::{CollectionDescriptor, CoreError, CoreResult};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+
+use crate::search_defaults::SearchDefaults;
+
+/// Maximum length of a collection description.
+pub const MAX_DESCRIPTION_LEN: usize = 4_096;
+
+/// Maximum size of a collection's metadata (serialized JSON).
+pub const MAX_COLLECTION_METADATA_BYTES: usize = 64 * 1024;
+
+/// Settings to change; absent fields are left as they are.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct CollectionUpdate {
+    /// New description; an empty string clears it.
+    #[serde(default)]
+    pub description: Option<String>,
+
+    /// New metadata (a JSON object) replacing the current one; `{}` clears
+    /// it.
+    #[serde(default)]
+    pub metadata: Option<JsonValue>,
+
+    /// HNSW graph degree. The existing index is not rebuilt.
+    #[serde(default)]
+    pub hnsw_m: Option<u32>,
+
+    /// HNSW construction EF. The existing index is not rebuilt.
+    #[serde(default)]
+    pub hnsw_ef_construction: Option<u32>,
+
+    /// Maximum document count; may not be below the current count.
+    #[serde(default)]
+    pub max_doc_count: Option<u64>,
+
+    /// Default search parameters, replacing the current ones.
+    #[serde(default)]
+    pub search_defaults: Option<SearchDefaults>,
+}
+
+impl CollectionUpdate {
+    /// Returns true if the update changes nothing.
+    pub fn is_empty(&self) -> bool {
+        *self == Self::default()
+    }
+
+    pub fn validate(&self) -> CoreResult<()> {
+        let invalid = |message: String| Err(CoreError::ValidationError(message));
+        if let Some(description) = &self.description {
+            if description.len() > MAX_DESCRIPTION_LEN {
+                return invalid(format!(
+                    "description must be <= {} bytes (got {})",
+                    MAX_DESCRIPTION_LEN,
+                    description.len()
+                ));
+            }
+        }
+        if let Some(metadata) = &self.metadata {
+            if !metadata.is_object() {
+                return invalid("collection metadata must be a JSON object".to_string());
+            }
+            let size = metadata.to_string().len();
+            if size > MAX_COLLECTION_METADATA_BYTES {
+                return invalid(format!(
+                    "collection metadata must be <= {} bytes (got {})",
+                    MAX_COLLECTION_METADATA_BYTES, size
+                ));
+            }
+        }
+        // Same bounds as the server's HNSW configuration
+        if let Some(m) = self.hnsw_m {
+            if !(2..=100).contains(&m) {
+                return invalid(format!("hnsw_m must be between 2 and 100 (got {})", m));
+            }
+        }
+        if let Some(ef) = self.hnsw_ef_construction {
+            if !(10..=1000).contains(&ef) {
+                return invalid(format!(
+                    "hnsw_ef_construction must be between 10 and 1000 (got {})",
+                    ef
+                ));
+            }
+        }
+        if self.max_doc_count == Some(0) {
+            return invalid("max_doc_count must be at least 1".to_string());
+        }
+        if let Some(defaults) = &self.search_defaults {
+            defaults.validate()?;
+        }
+        Ok(())
+    }
+
+    /// Applies the descriptor settings (all but the search defaults).
+    pub fn apply(&self, collection: &mut CollectionDescriptor) {
+        if let Some(description) = &self.description {
+            collection.description = (!description.is_empty()).then(|| description.clone());
+        }
+        if let Some(metadata) = &self.metadata {
+            let empty = metadata.as_object().map_or(true, |m| m.is_empty());
+            collection.metadata = (!empty).then(|| metadata.clone());
+        }
+        if let Some(m) = self.hnsw_m {
+            collection.hnsw_m = m;
+        }
+        if let Some(ef) = self.hnsw_ef_construction {
+            collection.hnsw_ef_construction = ef;
+        }
+        if let Some(max_doc_count) = self.max_doc_count {
+            collection.max_doc_count = max_doc_count;
+        }
+    }
+}
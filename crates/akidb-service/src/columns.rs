@@ -0,0 +1,167 @@
+//! Column-oriented search results.
+//!
+//! Analytics jobs load matches into dataframes and Arrow tables, which
+//! decode parallel arrays far faster than an array of objects. With the
+//! `columns` layout a search returns one array per attribute (IDs, scores,
+//! each selected metadata field and optionally vectors), all in match order
+//! and of the same length. A match without a selected field holds `null` in
+//! that column. Results with vectors convert back into documents, e.g. to
+//! copy matches into another collection (see also `VectorMatrix`).
+
+use akidb_core::{CoreError, CoreResult, DocumentId, SearchResult, VectorDocument};
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value as JsonValue};
+use std::collections::BTreeMap;
+
+/// Maximum metadata fields selected as columns.
+pub const MAX_COLUMN_FIELDS: usize = 64;
+
+/// How search matches are laid out in a response.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ResultLayout {
+    /// One object per match.
+    #[default]
+    Rows,
+    /// Parallel arrays (see `ResultColumns`).
+    Columns,
+}
+
+/// Matches as parallel arrays, best match first.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct ResultColumns {
+    pub doc_ids: Vec<DocumentId>,
+    pub external_ids: Vec<Option<String>>,
+    pub scores: Vec<f32>,
+
+    /// Selected metadata fields, keyed by field name.
+    pub metadata: BTreeMap<String, Vec<JsonValue>>,
+
+    /// Stored vectors (when requested).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub vectors: Option<Vec<Vec<f32>>>,
+}
+
+impl ResultColumns {
+    /// Lay `results` out as columns, with a column per top-level metadata
+    /// field in `fields` and, with `include_vectors`, the stored vectors.
+    pub fn from_results(
+        results: Vec<SearchResult>,
+        fields: &[String],
+        include_vectors: bool,
+    ) -> Self {
+        let mut columns = Self {
+            doc_ids: Vec::with_capacity(results.len()),
+            external_ids: Vec::with_capacity(results.len()),
+            scores: Vec::with_capacity(results.len()),
+            metadata: fields
+                .iter()
+                .map(|field| (field.clone(), Vec::with_capacity(results.len())))
+                .collect(),
+            vectors: include_vectors.then(|| Vec::with_capacity(results.len())),
+        };
+        for result in results {
+            for (field, values) in columns.metadata.iter_mut() {
+                let value = result.metadata.as_ref().and_then(|m| m.get(field));
+                values.push(value.cloned().unwrap_or(JsonValue::Null));
+            }
+            if let Some(vectors) = columns.vectors.as_mut() {
+                vectors.push(result.vector.unwrap_or_default());
+            }
+            columns.doc_ids.push(result.doc_id);
+            columns.external_ids.push(result.external_id);
+            columns.scores.push(result.score);
+        }
+        columns
+    }
+
+    /// Documents for inserting the matches, keeping their IDs, with the
+    /// selected metadata fields (`null` values left out) as metadata.
+    pub fn into_documents(self) -> CoreResult<Vec<VectorDocument>> {
+        let vectors = self.vectors.ok_or_else(|| {
+            CoreError::ValidationError("results do not include vectors".to_string())
+        })?;
+        let mut metadata: Vec<_> = self
+            .metadata
+            .into_iter()
+            .map(|(field, values)| (field, values.into_iter()))
+            .collect();
+        let docs = self
+            .doc_ids
+            .into_iter()
+            .zip(self.external_ids)
+            .zip(vectors)
+            .map(|((doc_id, external_id), vector)| {
+                let fields: Map<String, JsonValue> = metadata
+                    .iter_mut()
+                    .filter_map(|(field, values)| {
+                        values
+                            .next()
+                            .filter(|value| !value.is_null())
+                            .map(|value| (field.clone(), value))
+                    })
+                    .collect();
+                let mut doc = VectorDocument::new(doc_id, vector);
+                doc.external_id = external_id;
+                if !fields.is_empty() {
+                    doc.metadata = Some(JsonValue::Object(fields));
+                }
+                doc
+            })
+            .collect();
+        Ok(docs)
+    }
+}
+
+/// Check the metadata fields selected as columns.
+pub fn validate_column_fields(fields: &[String]) -> CoreResult<()> {
+    if fields.len() > MAX_COLUMN_FIELDS {
+        return Err(CoreError::ValidationError(format!(
+            "at most {} fields can be selected (got {})",
+            MAX_COLUMN_FIELDS,
+            fields.len()
+        )));
+    }
+    if fields.iter().any(|field| field.is_empty()) {
+        return Err(CoreError::ValidationError(
+            "field names must not be empty".to_string(),
+        ));
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_result_columns() {
+        let mut first = SearchResult::new(DocumentId::new(), 0.9);
+        first.external_id = Some("a".to_string());
+        first.metadata = Some(json!({"lang": "en", "year": 2021}));
+        first.vector = Some(vec![1.0, 0.0]);
+        let second = SearchResult::new(DocumentId::new(), 0.5);
+        let ids = [first.doc_id, second.doc_id];
+
+        let fields = ["lang".to_string(), "year".to_string()];
+        let columns = ResultColumns::from_results(vec![first, second], &fields, true);
+        assert_eq!(columns.doc_ids, ids);
+        assert_eq!(columns.external_ids, [Some("a".to_string()), None]);
+        assert_eq!(columns.scores, [0.9, 0.5]);
+        assert_eq!(columns.metadata["lang"], [json!("en"), JsonValue::Null]);
+        assert_eq!(columns.metadata["year"], [json!(2021), JsonValue::Null]);
+        assert_eq!(columns.vectors, Some(vec![vec![1.0, 0.0], vec![]]));
+
+        let docs = columns.into_documents().unwrap();
+        assert_eq!(docs[0].doc_id, ids[0]);
+        assert_eq!(docs[0].external_id.as_deref(), Some("a"));
+        assert_eq!(docs[0].metadata, Some(json!({"lang": "en", "year": 2021})));
+        assert_eq!(docs[1].metadata, None);
+
+        let empty = ResultColumns::from_results(Vec::new(), &[], false);
+        assert!(empty.clone().into_documents().is_err());
+        assert!(empty.doc_ids.is_empty() && empty.vectors.is_none());
+        assert!(validate_column_fields(&[String::new()]).is_err());
+    }
+}
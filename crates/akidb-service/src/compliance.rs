@@ -0,0 +1,311 @@
+//! Compliance jobs: data subject exports and purges (GDPR access and erasure).
+//!
+//! An export job writes every document of the tenant (optionally only those
+//! of one data subject) across all collections to an NDJSON file; a purge job
+//! deletes every document of a data subject. Subjects are identified by a
+//! metadata field, e.g. `{"field": "user_id", "value": "u-123"}`.
+//!
+//! Each finished job produces a report listing what was exported or deleted
+//! per collection, signed with HMAC-SHA256 so auditors can check it has not
+//! been altered. An export's report also carries the file's manifest (see
+//! `ExportManifest`), which imports use to refuse incomplete copies. See
+//! `CollectionService::start_compliance_job`.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, JobId, VectorDocument};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+use sha2::{Digest, Sha256};
+use std::path::PathBuf;
+
+use crate::manifest::ExportManifest;
+use crate::vector_codec::{EncodedVector, VectorCodec};
+
+/// Documents whose metadata `field` equals `value` belong to the subject.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SubjectFilter {
+    pub field: String,
+    pub value: JsonValue,
+}
+
+impl SubjectFilter {
+    /// Returns true if the document belongs to the subject.
+    pub fn matches(&self, doc: &VectorDocument) -> bool {
+        doc.metadata
+            .as_ref()
+            .and_then(|m| m.get(&self.field))
+            .map_or(false, |value| *value == self.value)
+    }
+}
+
+/// What a compliance job does.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ComplianceAction {
+    /// Export matching documents to a downloadable NDJSON file.
+    Export,
+
+    /// Delete matching documents.
+    Purge,
+}
+
+/// Compliance job request.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ComplianceRequest {
+    pub action: ComplianceAction,
+
+    /// Data subject to export or purge. Exports without a subject include all
+    /// documents; purges require one.
+    #[serde(default)]
+    pub subject: Option<SubjectFilter>,
+
+    /// Encoding of exported vectors.
+    #[serde(default)]
+    pub vector_codec: VectorCodec,
+}
+
+impl ComplianceRequest {
+    pub fn validate(&self) -> CoreResult<()> {
+        match &self.subject {
+            None if self.action == ComplianceAction::Purge => Err(CoreError::ValidationError(
+                "purge requires a subject; delete collections to remove all data".to_string(),
+            )),
+            Some(subject) if subject.field.trim().is_empty() => Err(CoreError::ValidationError(
+                "subject field must not be empty".to_string(),
+            )),
+            _ => Ok(()),
+        }
+    }
+}
+
+/// Lifecycle state of a compliance job.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ComplianceStatus {
+    Running,
+    Completed,
+    Failed,
+}
+
+/// A compliance job.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ComplianceJob {
+    pub id: JobId,
+    pub request: ComplianceRequest,
+    pub status: ComplianceStatus,
+
+    /// Set once the job completes.
+    pub report: Option<ComplianceReport>,
+
+    pub error: Option<String>,
+    pub created_at: DateTime<Utc>,
+    pub updated_at: DateTime<Utc>,
+}
+
+impl ComplianceJob {
+    pub fn new(request: ComplianceRequest) -> Self {
+        let now = Utc::now();
+        Self {
+            id: JobId::new(),
+            request,
+            status: ComplianceStatus::Running,
+            report: None,
+            error: None,
+            created_at: now,
+            updated_at: now,
+        }
+    }
+}
+
+/// Documents exported or deleted from one collection.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CollectionTally {
+    pub collection_id: CollectionId,
+    pub name: String,
+    pub documents: u64,
+}
+
+/// Signed record of a completed compliance job.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ComplianceReport {
+    pub job_id: JobId,
+    pub action: ComplianceAction,
+    pub subject: Option<SubjectFilter>,
+
+    /// Collections with at least one matching document.
+    pub collections: Vec<CollectionTally>,
+
+    pub total_documents: u64,
+
+    /// Matching documents a purge kept because they are under legal hold.
+    #[serde(default)]
+    pub held_documents: u64,
+
+    /// SHA-256 (hex) of the export file (exports only).
+    pub export_sha256: Option<String>,
+
+    /// Parts and checksums of the export file, for verified imports
+    /// (exports only).
+    #[serde(default)]
+    pub export_manifest: Option<ExportManifest>,
+
+    pub started_at: DateTime<Utc>,
+    pub completed_at: DateTime<Utc>,
+
+    /// HMAC-SHA256 (hex) of this report serialized as JSON with an empty
+    /// `signature`.
+    pub signature: String,
+}
+
+impl ComplianceReport {
+    /// Sets `signature` for `key`.
+    pub fn sign(&mut self, key: &[u8]) {
+        self.signature = hex::encode(hmac_sha256(key, &self.signing_payload()));
+    }
+
+    /// Returns true if `signature` is valid for `key`.
+    pub fn verify(&self, key: &[u8]) -> bool {
+        let expected = hex::encode(hmac_sha256(key, &self.signing_payload()));
+        // Constant-time comparison
+        expected.len() == self.signature.len()
+            && expected
+                .bytes()
+                .zip(self.signature.bytes())
+                .fold(0u8, |diff, (a, b)| diff | (a ^ b))
+                == 0
+    }
+
+    fn signing_payload(&self) -> Vec<u8> {
+        let unsigned = Self {
+            signature: String::new(),
+            ..self.clone()
+        };
+        serde_json::to_vec(&unsigned).unwrap_or_default()
+    }
+}
+
+/// One line of an export file.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ExportRecord {
+    pub collection_id: CollectionId,
+    pub collection: String,
+    pub id: DocumentId,
+    pub external_id: Option<String>,
+    pub vector: EncodedVector,
+    pub metadata: Option<JsonValue>,
+}
+
+impl ExportRecord {
+    pub fn new(
+        collection_id: CollectionId,
+        collection: &str,
+        doc: VectorDocument,
+        codec: VectorCodec,
+    ) -> Self {
+        Self {
+            collection_id,
+            collection: collection.to_string(),
+            id: doc.doc_id,
+            external_id: doc.external_id,
+            vector: codec.encode(&doc.vector),
+            metadata: doc.metadata,
+        }
+    }
+}
+
+/// Generates a random report signing key (used when none is configured).
+pub fn random_signing_key() -> Vec<u8> {
+    rand::random::<[u8; 32]>().to_vec()
+}
+
+/// File holding an export job's NDJSON output.
+pub fn export_path(job_id: JobId) -> PathBuf {
+    std::env::temp_dir()
+        .join("akidb-exports")
+        .join(format!("{}.ndjson", job_id))
+}
+
+/// HMAC-SHA256 (RFC 2104).
+pub(crate) fn hmac_sha256(key: &[u8], message: &[u8]) -> [u8; 32] {
+    const BLOCK_SIZE: usize = 64;
+
+    let mut block = [0u8; BLOCK_SIZE];
+    if key.len() > BLOCK_SIZE {
+        block[..32].copy_from_slice(&Sha256::digest(key));
+    } else {
+        block[..key.len()].copy_from_slice(key);
+    }
+
+    let mut inner = Sha256::new();
+    inner.update(block.map(|b| b ^ 0x36));
+    inner.update(message);
+    let mut outer = Sha256::new();
+    outer.update(block.map(|b| b ^ 0x5c));
+    outer.update(inner.finalize());
+    outer.finalize().into()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_hmac_sha256_rfc4231() {
+        assert_eq!(
+            hex::encode(hmac_sha256(b"Jefe", b"what do ya want for nothing?")),
+            "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+        );
+        // Keys longer than the block size are hashed first
+        assert_eq!(
+            hex::encode(hmac_sha256(
+                &[0xaa; 131],
+                b"Test Using Larger Than Block-Size Key - Hash Key First"
+            )),
+            "60e431591ee0b67f0d8a26aacbf5b77f8e0bc6213728c5140546040f0ee37f54"
+        );
+    }
+
+    #[test]
+    fn test_report_signature() {
+        let now = Utc::now();
+        let mut report = ComplianceReport {
+            job_id: JobId::new(),
+            action: ComplianceAction::Purge,
+            subject: Some(SubjectFilter {
+                field: "user_id".to_string(),
+                value: json!("u-1"),
+            }),
+            collections: vec![],
+            total_documents: 3,
+            held_documents: 0,
+            export_sha256: None,
+            export_manifest: None,
+            started_at: now,
+            completed_at: now,
+            signature: String::new(),
+        };
+        report.sign(b"secret");
+        assert!(report.verify(b"secret"));
+        assert!(!report.verify(b"other"));
+
+        report.total_documents = 2;
+        assert!(!report.verify(b"secret"));
+    }
+
+    #[test]
+    fn test_purge_requires_subject() {
+        let request = ComplianceRequest {
+            action: ComplianceAction::Purge,
+            subject: None,
+            vector_codec: VectorCodec::default(),
+        };
+        assert!(request.validate().is_err());
+        let request = ComplianceRequest {
+            action: ComplianceAction::Export,
+            subject: None,
+            vector_codec: VectorCodec::default(),
+        };
+        assert!(request.validate().is_ok());
+    }
+}
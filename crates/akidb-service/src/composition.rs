@@ -0,0 +1,149 @@
+//! Query vector composition.
+//!
+//! Builds a query vector server-side as a weighted sum of stored documents'
+//! vectors plus an optional literal vector, e.g.
+//! `0.7 * doc_a - 0.3 * doc_b + literal`. Analogy-style and profile-adjusted
+//! searches then need no round trip to fetch embeddings.
+//! See `CollectionService::compose_query_vector`.
+
+use akidb_core::{CoreError, CoreResult, DocumentId};
+use serde::{Deserialize, Serialize};
+
+/// Maximum stored documents referenced by one composition.
+pub const MAX_COMPOSITION_TERMS: usize = 64;
+
+/// A weighted stored document.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct QueryTerm {
+    pub doc_id: DocumentId,
+
+    /// Weight of the document's vector (negative to subtract it).
+    pub weight: f32,
+}
+
+/// Recipe for a query vector.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct QueryComposition {
+    /// Weighted documents of the searched collection.
+    #[serde(default)]
+    pub terms: Vec<QueryTerm>,
+
+    /// Vector added as is.
+    #[serde(default)]
+    pub literal: Option<Vec<f32>>,
+
+    /// Scale the result to unit length.
+    #[serde(default)]
+    pub normalize: bool,
+}
+
+impl QueryComposition {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.terms.is_empty() && self.literal.is_none() {
+            return Err(CoreError::ValidationError(
+                "query composition needs at least one term or a literal vector".to_string(),
+            ));
+        }
+        if self.terms.len() > MAX_COMPOSITION_TERMS {
+            return Err(CoreError::ValidationError(format!(
+                "query composition must have at most {} terms (got {})",
+                MAX_COMPOSITION_TERMS,
+                self.terms.len()
+            )));
+        }
+        if let Some(term) = self.terms.iter().find(|t| !t.weight.is_finite()) {
+            return Err(CoreError::ValidationError(format!(
+                "weight of document {} must be a finite number",
+                term.doc_id
+            )));
+        }
+        Ok(())
+    }
+
+    /// Combine the terms' vectors (in `terms` order) and the literal.
+    pub fn compose(&self, vectors: &[Vec<f32>], dimension: usize) -> CoreResult<Vec<f32>> {
+        self.validate()?;
+        let mut query = vec![0.0f32; dimension];
+        for (term, vector) in self.terms.iter().zip(vectors) {
+            add_scaled(&mut query, vector, term.weight, "document vector")?;
+        }
+        if let Some(literal) = &self.literal {
+            add_scaled(&mut query, literal, 1.0, "literal vector")?;
+        }
+
+        let norm = query.iter().map(|x| x * x).sum::<f32>().sqrt();
+        if norm == 0.0 || !norm.is_finite() {
+            return Err(CoreError::ValidationError(
+                "composed query vector is zero or not finite".to_string(),
+            ));
+        }
+        if self.normalize {
+            query.iter_mut().for_each(|x| *x /= norm);
+        }
+        Ok(query)
+    }
+}
+
+fn add_scaled(query: &mut [f32], vector: &[f32], weight: f32, what: &str) -> CoreResult<()> {
+    if vector.len() != query.len() {
+        return Err(CoreError::ValidationError(format!(
+            "{} dimension mismatch: expected {}, got {}",
+            what,
+            query.len(),
+            vector.len()
+        )));
+    }
+    for (acc, x) in query.iter_mut().zip(vector) {
+        *acc += weight * x;
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_compose_weighted_sum() {
+        let composition = QueryComposition {
+            terms: vec![
+                QueryTerm {
+                    doc_id: DocumentId::new(),
+                    weight: 0.5,
+                },
+                QueryTerm {
+                    doc_id: DocumentId::new(),
+                    weight: -1.0,
+                },
+            ],
+            literal: Some(vec![1.0, 1.0]),
+            normalize: false,
+        };
+        let vectors = vec![vec![2.0, 4.0], vec![1.0, 0.0]];
+        assert_eq!(composition.compose(&vectors, 2).unwrap(), vec![1.0, 3.0]);
+
+        let normalized = QueryComposition {
+            normalize: true,
+            ..composition.clone()
+        };
+        let query = normalized.compose(&vectors, 2).unwrap();
+        assert!((query.iter().map(|x| x * x).sum::<f32>() - 1.0).abs() < 1e-6);
+
+        assert!(composition.compose(&vectors, 3).is_err());
+    }
+
+    #[test]
+    fn test_zero_result_rejected() {
+        let doc_id = DocumentId::new();
+        let composition = QueryComposition {
+            terms: vec![QueryTerm {
+                doc_id,
+                weight: 1.0,
+            }],
+            literal: Some(vec![-1.0, -2.0]),
+            normalize: false,
+        };
+        assert!(composition.compose(&[vec![1.0, 2.0]], 2).is_err());
+        assert!(QueryComposition::default().validate().is_err());
+    }
+}
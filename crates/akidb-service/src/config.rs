@@ -5,6 +5,10 @@
 //! 2. TOML configuration file
 //! 3. Default values (lowest priority)
 
+use crate::anomaly::AnomalyConfig;
+use crate::coalesce::CoalescingConfig;
+use crate::impersonation::MIN_ADMIN_KEY_LEN;
+use crate::replication::ReplicationClientConfig;
 use serde::{Deserialize, Serialize};
 use std::path::PathBuf;
 
@@ -35,6 +39,30 @@ pub struct Config {
     /// Logging configuration
     #[serde(default)]
     pub logging: LoggingConfig,
+
+    /// Direct-to-storage imports (signed upload URLs)
+    #[serde(default)]
+    pub imports: ImportsConfig,
+
+    /// Data subject export/purge jobs
+    #[serde(default)]
+    pub compliance: ComplianceConfig,
+
+    /// Access anomaly (mass scan) detection thresholds
+    #[serde(default)]
+    pub anomaly: AnomalyConfig,
+
+    /// Coalescing of concurrent Get-by-ID requests
+    #[serde(default)]
+    pub coalescing: CoalescingConfig,
+
+    /// Admin access (impersonation)
+    #[serde(default)]
+    pub admin: AdminConfig,
+
+    /// HTTP client of cross-region replication
+    #[serde(default)]
+    pub replication_client: ReplicationClientConfig,
 }
 
 /// Server configuration (host, port, protocol)
@@ -55,6 +83,11 @@ pub struct ServerConfig {
     /// Request timeout in seconds (default: 30)
     #[serde(default = "default_timeout")]
     pub timeout_seconds: u64,
+
+    /// Accept gzip/zstd request bodies and compress responses for clients
+    /// sending `Accept-Encoding` (default: true)
+    #[serde(default = "default_true")]
+    pub compression: bool,
 }
 
 /// Database configuration
@@ -134,6 +167,56 @@ pub struct LoggingConfig {
     pub format: String,
 }
 
+/// Direct-to-storage import configuration
+///
+/// Clients upload import files straight to this bucket with signed URLs, so
+/// large payloads never pass through the API servers. Signed upload URLs are
+/// disabled when no bucket is set.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ImportsConfig {
+    /// Bucket receiving import files (default: none)
+    #[serde(default)]
+    pub s3_bucket: Option<String>,
+
+    /// Bucket region (default: "us-east-1")
+    #[serde(default = "default_s3_region")]
+    pub s3_region: String,
+
+    /// Custom endpoint for S3-compatible stores such as MinIO
+    #[serde(default)]
+    pub s3_endpoint: Option<String>,
+
+    /// Access key for a custom endpoint
+    #[serde(default)]
+    pub s3_access_key: Option<String>,
+
+    /// Secret key for a custom endpoint
+    #[serde(default)]
+    pub s3_secret_key: Option<String>,
+
+    /// Key prefix for import files within the bucket
+    #[serde(default)]
+    pub s3_prefix: Option<String>,
+}
+
+/// Compliance job configuration
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct ComplianceConfig {
+    /// Key signing compliance reports (HMAC-SHA256). When unset, a random key
+    /// is generated at startup and reports cannot be verified after a restart.
+    #[serde(default)]
+    pub signing_key: Option<String>,
+}
+
+/// Admin access configuration
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct AdminConfig {
+    /// Key letting support tooling act as the tenant (read-only, audited).
+    /// Impersonation is disabled when unset.
+    #[serde(default)]
+    pub impersonation_key: Option<String>,
+}
+
 // Default value functions
 fn default_host() -> String {
     "0.0.0.0".to_string()
@@ -187,6 +270,10 @@ fn default_log_format() -> String {
     "pretty".to_string()
 }
 
+fn default_s3_region() -> String {
+    "us-east-1".to_string()
+}
+
 fn default_embedding_provider() -> String {
     "mlx".to_string()
 }
@@ -204,6 +291,12 @@ impl Default for Config {
             features: FeaturesConfig::default(),
             hnsw: HnswConfig::default(),
             logging: LoggingConfig::default(),
+            imports: ImportsConfig::default(),
+            compliance: ComplianceConfig::default(),
+            anomaly: AnomalyConfig::default(),
+            coalescing: CoalescingConfig::default(),
+            admin: AdminConfig::default(),
+            replication_client: ReplicationClientConfig::default(),
         }
     }
 }
@@ -215,6 +308,7 @@ impl Default for ServerConfig {
             rest_port: default_rest_port(),
             grpc_port: default_grpc_port(),
             timeout_seconds: default_timeout(),
+            compression: true,
         }
     }
 }
@@ -268,6 +362,19 @@ impl Default for LoggingConfig {
     }
 }
 
+impl Default for ImportsConfig {
+    fn default() -> Self {
+        Self {
+            s3_bucket: None,
+            s3_region: default_s3_region(),
+            s3_endpoint: None,
+            s3_access_key: None,
+            s3_secret_key: None,
+            s3_prefix: None,
+        }
+    }
+}
+
 impl Config {
     /// Load configuration from a TOML file.
     ///
@@ -327,6 +434,12 @@ impl Config {
             }
         }
 
+        if let Ok(enabled) = std::env::var("AKIDB_COMPRESSION") {
+            if let Ok(enabled) = enabled.parse() {
+                self.server.compression = enabled;
+            }
+        }
+
         if let Ok(path) = std::env::var("AKIDB_DB_PATH") {
             self.database.path = path;
         }
@@ -362,6 +475,34 @@ impl Config {
         if let Ok(python_path) = std::env::var("AKIDB_EMBEDDING_PYTHON_PATH") {
             self.embedding.python_path = Some(python_path);
         }
+
+        if let Ok(bucket) = std::env::var("AKIDB_IMPORTS_S3_BUCKET") {
+            self.imports.s3_bucket = Some(bucket);
+        }
+
+        if let Ok(region) = std::env::var("AKIDB_IMPORTS_S3_REGION") {
+            self.imports.s3_region = region;
+        }
+
+        if let Ok(endpoint) = std::env::var("AKIDB_IMPORTS_S3_ENDPOINT") {
+            self.imports.s3_endpoint = Some(endpoint);
+        }
+
+        if let Ok(access_key) = std::env::var("AKIDB_IMPORTS_S3_ACCESS_KEY") {
+            self.imports.s3_access_key = Some(access_key);
+        }
+
+        if let Ok(secret_key) = std::env::var("AKIDB_IMPORTS_S3_SECRET_KEY") {
+            self.imports.s3_secret_key = Some(secret_key);
+        }
+
+        if let Ok(signing_key) = std::env::var("AKIDB_COMPLIANCE_SIGNING_KEY") {
+            self.compliance.signing_key = Some(signing_key);
+        }
+
+        if let Ok(key) = std::env::var("AKIDB_ADMIN_IMPERSONATION_KEY") {
+            self.admin.impersonation_key = Some(key);
+        }
     }
 
     /// Validate the configuration.
@@ -483,6 +624,25 @@ impl Config {
             )));
         }
 
+        self.anomaly
+            .validate()
+            .map_err(|e| ConfigError::ValidationError(e.to_string()))?;
+        self.coalescing
+            .validate()
+            .map_err(|e| ConfigError::ValidationError(e.to_string()))?;
+        self.replication_client
+            .validate()
+            .map_err(|e| ConfigError::ValidationError(e.to_string()))?;
+
+        if let Some(key) = &self.admin.impersonation_key {
+            if key.len() < MIN_ADMIN_KEY_LEN {
+                return Err(ConfigError::ValidationError(format!(
+                    "admin.impersonation_key must be at least {} bytes",
+                    MIN_ADMIN_KEY_LEN
+                )));
+            }
+        }
+
         Ok(())
     }
 }
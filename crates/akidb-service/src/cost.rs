@@ -0,0 +1,190 @@
+//! Cost estimation for searches and imports.
+//!
+//! Estimates are expressed in abstract units so chargeback systems can budget
+//! workloads before running them:
+//! - compute units: one unit per million vector-component operations
+//!   (distance computations during search, graph construction during import);
+//! - read units: one unit per 4 KiB of stored data returned;
+//! - write units: one unit per 1 KiB written, rounded up per vector.
+//!
+//! Search estimates model the index the collection actually uses: brute force
+//! scans every vector, HNSW visits roughly `ef * m * log2(n)` candidates.
+
+use akidb_core::{CollectionDescriptor, IndexType};
+use serde::{Deserialize, Serialize};
+
+use crate::capacity::PER_VECTOR_OVERHEAD_BYTES;
+
+/// Collections with an automatic index type and a `max_doc_count` up to this
+/// use a brute-force index.
+pub const BRUTE_FORCE_MAX_DOCS: u64 = 10_000;
+
+/// HNSW `ef_search` used by collection indexes.
+pub const HNSW_EF_SEARCH: u64 = 128;
+
+/// Vector-component operations per compute unit.
+pub const OPS_PER_COMPUTE_UNIT: f64 = 1_000_000.0;
+
+/// Bytes per read unit.
+pub const READ_UNIT_BYTES: u64 = 4096;
+
+/// Bytes per write unit.
+pub const WRITE_UNIT_BYTES: u64 = 1024;
+
+/// Index used by a collection, as far as cost is concerned.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum IndexKind {
+    BruteForce,
+    Hnsw,
+}
+
+impl IndexKind {
+    /// The index kind chosen for a collection when it is loaded.
+    pub fn for_collection(collection: &CollectionDescriptor) -> Self {
+        match collection.index_type {
+            IndexType::Flat => Self::BruteForce,
+            IndexType::Hnsw => Self::Hnsw,
+            IndexType::Auto if collection.max_doc_count <= BRUTE_FORCE_MAX_DOCS => {
+                Self::BruteForce
+            }
+            IndexType::Auto => Self::Hnsw,
+        }
+    }
+}
+
+/// Expected cost of running `queries` searches.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SearchCostEstimate {
+    pub index: IndexKind,
+    pub queries: u64,
+
+    /// Vectors compared per query.
+    pub vectors_scanned: u64,
+
+    pub compute_units: f64,
+    pub read_units: u64,
+}
+
+/// Expected cost of importing vectors.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ImportCostEstimate {
+    pub index: IndexKind,
+    pub vectors: u64,
+
+    /// Bytes written (vectors, metadata and per-vector overhead).
+    pub bytes: u64,
+
+    pub compute_units: f64,
+    pub write_units: u64,
+}
+
+/// Estimates search cost against a collection holding `count` vectors.
+pub fn estimate_search(
+    collection: &CollectionDescriptor,
+    count: u64,
+    top_k: u64,
+    queries: u64,
+) -> SearchCostEstimate {
+    let index = IndexKind::for_collection(collection);
+    let dimension = u64::from(collection.dimension);
+    let vectors_scanned = match index {
+        IndexKind::BruteForce => count,
+        IndexKind::Hnsw => {
+            let ef = HNSW_EF_SEARCH.max(top_k);
+            let visited = ef as f64 * f64::from(collection.hnsw_m) * log2(count);
+            (visited.ceil() as u64).min(count)
+        }
+    };
+
+    let result_bytes = top_k.min(count) * (dimension * 4 + PER_VECTOR_OVERHEAD_BYTES);
+    SearchCostEstimate {
+        index,
+        queries,
+        vectors_scanned,
+        compute_units: (vectors_scanned * dimension * queries) as f64 / OPS_PER_COMPUTE_UNIT,
+        read_units: result_bytes.div_ceil(READ_UNIT_BYTES).max(1) * queries,
+    }
+}
+
+/// Estimates the cost of importing `vectors` vectors with about
+/// `avg_metadata_bytes` of metadata each into a collection holding `count`.
+pub fn estimate_import(
+    collection: &CollectionDescriptor,
+    count: u64,
+    vectors: u64,
+    avg_metadata_bytes: u64,
+) -> ImportCostEstimate {
+    let index = IndexKind::for_collection(collection);
+    let dimension = u64::from(collection.dimension);
+    let per_vector_bytes = dimension * 4 + PER_VECTOR_OVERHEAD_BYTES + avg_metadata_bytes;
+
+    let ops = match index {
+        // Append only
+        IndexKind::BruteForce => (vectors * dimension) as f64,
+        // Each insert searches the graph with ef_construction candidates
+        IndexKind::Hnsw => {
+            let final_count = count + vectors;
+            vectors as f64
+                * f64::from(collection.hnsw_ef_construction)
+                * log2(final_count)
+                * dimension as f64
+        }
+    };
+
+    ImportCostEstimate {
+        index,
+        vectors,
+        bytes: vectors * per_vector_bytes,
+        compute_units: ops / OPS_PER_COMPUTE_UNIT,
+        write_units: vectors * per_vector_bytes.div_ceil(WRITE_UNIT_BYTES),
+    }
+}
+
+fn log2(n: u64) -> f64 {
+    (n.max(2) as f64).log2()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DatabaseId;
+
+    fn collection(max_doc_count: u64) -> CollectionDescriptor {
+        let mut collection = CollectionDescriptor::new(DatabaseId::new(), "cost", 128, "model");
+        collection.max_doc_count = max_doc_count;
+        collection
+    }
+
+    #[test]
+    fn test_search_cost_by_index() {
+        let brute = estimate_search(&collection(5000), 5000, 10, 1);
+        assert_eq!(brute.index, IndexKind::BruteForce);
+        assert_eq!(brute.vectors_scanned, 5000);
+        assert!((brute.compute_units - 0.64).abs() < 1e-9);
+        assert_eq!(brute.read_units, 2);
+
+        let hnsw = estimate_search(&collection(1_000_000), 1_000_000, 10, 100);
+        assert_eq!(hnsw.index, IndexKind::Hnsw);
+        assert!(hnsw.vectors_scanned < 1_000_000);
+        assert_eq!(hnsw.read_units, 200);
+
+        // An explicit index type overrides the choice by document limit
+        let mut flat = collection(1_000_000);
+        flat.index_type = IndexType::Flat;
+        assert_eq!(IndexKind::for_collection(&flat), IndexKind::BruteForce);
+        let mut small_hnsw = collection(5000);
+        small_hnsw.index_type = IndexType::Hnsw;
+        assert_eq!(IndexKind::for_collection(&small_hnsw), IndexKind::Hnsw);
+    }
+
+    #[test]
+    fn test_import_cost() {
+        let estimate = estimate_import(&collection(5000), 0, 1000, 384);
+        assert_eq!(estimate.bytes, 1000 * 1024);
+        assert_eq!(estimate.write_units, 1000);
+
+        let hnsw = estimate_import(&collection(1_000_000), 0, 1000, 384);
+        assert!(hnsw.compute_units > estimate.compute_units);
+    }
+}
@@ -0,0 +1,309 @@
+//! Vector drift monitoring.
+//!
+//! Tracks the distribution of recent query vectors and of stored vectors
+//! (centroid and norm histogram) and flags drift when they diverge. A query
+//! centroid that moves away from the stored centroid, or norms that no longer
+//! match, usually means queries and documents are embedded with different
+//! models. See `CollectionService::enable_drift_monitoring`.
+
+use serde::{Deserialize, Serialize};
+use std::collections::VecDeque;
+use std::sync::Mutex;
+
+/// Width of a norm histogram bucket.
+pub const NORM_BUCKET_WIDTH: f32 = 0.1;
+
+/// Number of norm histogram buckets; the last one holds all larger norms.
+pub const NORM_BUCKETS: usize = 21;
+
+/// Distribution summary of a set of vectors.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct VectorStats {
+    pub count: usize,
+
+    /// Mean vector.
+    pub centroid: Vec<f32>,
+
+    /// Mean L2 norm.
+    pub mean_norm: f32,
+
+    /// Vector counts per norm bucket ([0, 0.1), [0.1, 0.2), ..., [2.0, inf)).
+    pub norm_histogram: Vec<u64>,
+}
+
+impl VectorStats {
+    /// Summarizes a set of vectors (all of the same dimension).
+    pub fn from_vectors<'a>(vectors: impl IntoIterator<Item = &'a [f32]>) -> Self {
+        let mut count = 0;
+        let mut sum: Vec<f64> = Vec::new();
+        let mut norm_sum = 0.0f64;
+        let mut norm_histogram = vec![0u64; NORM_BUCKETS];
+
+        for vector in vectors {
+            if sum.is_empty() {
+                sum = vec![0.0; vector.len()];
+            }
+            for (acc, x) in sum.iter_mut().zip(vector) {
+                *acc += f64::from(*x);
+            }
+            let norm = vector.iter().map(|x| x * x).sum::<f32>().sqrt();
+            norm_sum += f64::from(norm);
+            let bucket = ((norm / NORM_BUCKET_WIDTH) as usize).min(NORM_BUCKETS - 1);
+            norm_histogram[bucket] += 1;
+            count += 1;
+        }
+
+        if count == 0 {
+            return Self {
+                norm_histogram,
+                ..Default::default()
+            };
+        }
+        let n = count as f64;
+        Self {
+            count,
+            centroid: sum.into_iter().map(|s| (s / n) as f32).collect(),
+            mean_norm: (norm_sum / n) as f32,
+            norm_histogram,
+        }
+    }
+
+    /// Fraction of vectors in each norm bucket.
+    fn norm_distribution(&self) -> Vec<f32> {
+        let total = self.count.max(1) as f32;
+        self.norm_histogram
+            .iter()
+            .map(|&c| c as f32 / total)
+            .collect()
+    }
+}
+
+/// Drift thresholds and sampling settings.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(default)]
+pub struct DriftConfig {
+    /// Number of recent query vectors kept for comparison.
+    pub window: usize,
+
+    /// Minimum vectors on each side before drift is evaluated.
+    pub min_samples: usize,
+
+    /// Flag drift when the cosine distance between centroids exceeds this.
+    pub centroid_threshold: f32,
+
+    /// Flag drift when mean norms differ by more than this fraction.
+    pub norm_threshold: f32,
+
+    /// Flag drift when the norm histograms' total variation distance exceeds this.
+    pub histogram_threshold: f32,
+}
+
+impl Default for DriftConfig {
+    fn default() -> Self {
+        Self {
+            window: 1000,
+            min_samples: 50,
+            centroid_threshold: 0.3,
+            norm_threshold: 0.2,
+            histogram_threshold: 0.5,
+        }
+    }
+}
+
+/// Divergence between two vector distributions.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct DriftMetrics {
+    /// Cosine distance between the centroids (0 = same direction, 2 = opposite).
+    pub centroid_shift: f32,
+
+    /// Relative difference of mean norms.
+    pub norm_shift: f32,
+
+    /// Total variation distance between norm histograms (0-1).
+    pub histogram_distance: f32,
+
+    /// True when any metric exceeds its threshold.
+    pub drifted: bool,
+}
+
+impl DriftMetrics {
+    /// Compares `current` against `baseline`.
+    ///
+    /// Returns None if either side has fewer than `min_samples` vectors or the
+    /// dimensions differ.
+    pub fn compare(
+        baseline: &VectorStats,
+        current: &VectorStats,
+        config: &DriftConfig,
+    ) -> Option<Self> {
+        if baseline.count < config.min_samples.max(1)
+            || current.count < config.min_samples.max(1)
+            || baseline.centroid.len() != current.centroid.len()
+        {
+            return None;
+        }
+
+        let centroid_shift = cosine_distance(&baseline.centroid, &current.centroid);
+        let norm_shift = if baseline.mean_norm > 0.0 {
+            (current.mean_norm - baseline.mean_norm).abs() / baseline.mean_norm
+        } else {
+            current.mean_norm
+        };
+        let histogram_distance = baseline
+            .norm_distribution()
+            .iter()
+            .zip(current.norm_distribution())
+            .map(|(a, b)| (a - b).abs())
+            .sum::<f32>()
+            / 2.0;
+
+        Some(Self {
+            centroid_shift,
+            norm_shift,
+            histogram_distance,
+            drifted: centroid_shift > config.centroid_threshold
+                || norm_shift > config.norm_threshold
+                || histogram_distance > config.histogram_threshold,
+        })
+    }
+}
+
+fn cosine_distance(a: &[f32], b: &[f32]) -> f32 {
+    let dot: f32 = a.iter().zip(b).map(|(x, y)| x * y).sum();
+    let norm_a = a.iter().map(|x| x * x).sum::<f32>().sqrt();
+    let norm_b = b.iter().map(|x| x * x).sum::<f32>().sqrt();
+    if norm_a == 0.0 || norm_b == 0.0 {
+        return 0.0;
+    }
+    1.0 - dot / (norm_a * norm_b)
+}
+
+/// Drift report for a collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct DriftReport {
+    pub config: DriftConfig,
+
+    /// Recent query vectors.
+    pub queries: VectorStats,
+
+    /// Stored vectors now.
+    pub stored: VectorStats,
+
+    /// Stored vectors when monitoring was enabled.
+    pub baseline: VectorStats,
+
+    /// Recent queries vs stored vectors (None until enough samples).
+    pub query_drift: Option<DriftMetrics>,
+
+    /// Stored vectors now vs the baseline (None until enough samples).
+    pub stored_drift: Option<DriftMetrics>,
+
+    /// True when either comparison flags drift.
+    pub drifted: bool,
+}
+
+/// Sliding window of recent query vectors for one collection.
+#[derive(Debug)]
+pub struct DriftMonitor {
+    config: DriftConfig,
+    baseline: VectorStats,
+    recent: Mutex<VecDeque<Vec<f32>>>,
+}
+
+impl DriftMonitor {
+    /// Creates a monitor comparing against `baseline` (the stored vectors' stats).
+    pub fn new(config: DriftConfig, baseline: VectorStats) -> Self {
+        Self {
+            recent: Mutex::new(VecDeque::with_capacity(config.window)),
+            config,
+            baseline,
+        }
+    }
+
+    pub fn config(&self) -> &DriftConfig {
+        &self.config
+    }
+
+    /// Records a query vector, evicting the oldest beyond the window.
+    pub fn record(&self, vector: &[f32]) {
+        let mut recent = self.recent.lock().unwrap();
+        if recent.len() >= self.config.window.max(1) {
+            recent.pop_front();
+        }
+        recent.push_back(vector.to_vec());
+    }
+
+    /// Stats of the recorded query window.
+    pub fn query_stats(&self) -> VectorStats {
+        let recent = self.recent.lock().unwrap();
+        VectorStats::from_vectors(recent.iter().map(Vec::as_slice))
+    }
+
+    /// Builds a report against the current stored-vector stats.
+    pub fn report(&self, stored: VectorStats) -> DriftReport {
+        let queries = self.query_stats();
+        let query_drift = DriftMetrics::compare(&stored, &queries, &self.config);
+        let stored_drift = DriftMetrics::compare(&self.baseline, &stored, &self.config);
+        let drifted = query_drift.as_ref().map_or(false, |m| m.drifted)
+            || stored_drift.as_ref().map_or(false, |m| m.drifted);
+
+        DriftReport {
+            config: self.config.clone(),
+            queries,
+            stored,
+            baseline: self.baseline.clone(),
+            query_drift,
+            stored_drift,
+            drifted,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn config() -> DriftConfig {
+        DriftConfig {
+            window: 4,
+            min_samples: 2,
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_vector_stats() {
+        let vectors = [vec![1.0, 0.0], vec![0.0, 1.0], vec![3.0, 4.0]];
+        let stats = VectorStats::from_vectors(vectors.iter().map(Vec::as_slice));
+        assert_eq!(stats.count, 3);
+        assert_eq!(stats.centroid, vec![4.0 / 3.0, 5.0 / 3.0]);
+        assert!((stats.mean_norm - 7.0 / 3.0).abs() < 1e-6);
+        assert_eq!(stats.norm_histogram[10], 2);
+        assert_eq!(stats.norm_histogram[NORM_BUCKETS - 1], 1);
+    }
+
+    #[test]
+    fn test_drift_detection() {
+        let stored = [vec![1.0, 0.1], vec![1.0, -0.1], vec![0.9, 0.0]];
+        let stored = VectorStats::from_vectors(stored.iter().map(Vec::as_slice));
+        let monitor = DriftMonitor::new(config(), stored.clone());
+
+        monitor.record(&[1.0, 0.0]);
+        assert!(monitor.report(stored.clone()).query_drift.is_none());
+        monitor.record(&[0.95, 0.05]);
+        let report = monitor.report(stored.clone());
+        assert!(!report.drifted);
+
+        // Queries from a different model point elsewhere with other norms
+        for _ in 0..4 {
+            monitor.record(&[0.0, 5.0]);
+        }
+        let report = monitor.report(stored.clone());
+        assert_eq!(report.queries.count, 4);
+        let drift = report.query_drift.unwrap();
+        assert!(drift.drifted);
+        assert!(drift.centroid_shift > 0.9);
+        assert!(drift.histogram_distance > 0.9);
+        assert!(report.drifted);
+    }
+}
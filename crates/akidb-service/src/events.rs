@@ -0,0 +1,184 @@
+//! Events of background components.
+//!
+//! Background work (replication shipments, scheduled and compliance jobs,
+//! growth sampling, tenant purges and suspension refreshes) has no caller to
+//! report its problems to. Besides being logged, they are published as
+//! `ServiceEvent`s that operators can list or follow to alert on them. The
+//! last `EVENT_HISTORY_LIMIT` events are kept in memory.
+
+use akidb_core::CollectionId;
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::VecDeque;
+use std::sync::Mutex;
+use tokio::sync::broadcast;
+
+/// Events kept for the events API.
+pub const EVENT_HISTORY_LIMIT: usize = 500;
+
+/// Events buffered per subscriber before slow subscribers start lagging.
+pub const EVENT_BUFFER: usize = 64;
+
+/// Severity of an event.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum EventLevel {
+    Info,
+    /// Work failed and is retried later.
+    Warning,
+    /// Work failed for good.
+    Error,
+}
+
+/// Background component publishing an event.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum EventComponent {
+    Replication,
+    Scheduler,
+    Compliance,
+    GrowthSampler,
+    TenantPurger,
+    SuspensionRefresher,
+}
+
+/// A problem (or notable outcome) of background work.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ServiceEvent {
+    /// Increasing from 1 since startup.
+    pub id: u64,
+    pub level: EventLevel,
+    pub component: EventComponent,
+    pub collection_id: Option<CollectionId>,
+    pub message: String,
+    pub at: DateTime<Utc>,
+}
+
+struct EventLogState {
+    events: VecDeque<ServiceEvent>,
+    next_id: u64,
+}
+
+/// Keeps the recent events and pushes new ones to subscribers.
+pub struct EventLog {
+    state: Mutex<EventLogState>,
+    sender: broadcast::Sender<ServiceEvent>,
+}
+
+impl EventLog {
+    pub fn new() -> Self {
+        let (sender, _) = broadcast::channel(EVENT_BUFFER);
+        Self {
+            state: Mutex::new(EventLogState {
+                events: VecDeque::new(),
+                next_id: 1,
+            }),
+            sender,
+        }
+    }
+
+    /// Log an event and push it to subscribers.
+    pub fn publish(
+        &self,
+        level: EventLevel,
+        component: EventComponent,
+        collection_id: Option<CollectionId>,
+        message: String,
+    ) -> ServiceEvent {
+        match level {
+            EventLevel::Info => tracing::info!("{:?}: {}", component, message),
+            EventLevel::Warning => tracing::warn!("{:?}: {}", component, message),
+            EventLevel::Error => tracing::error!("{:?}: {}", component, message),
+        }
+
+        let mut state = self.state.lock().unwrap();
+        let event = ServiceEvent {
+            id: state.next_id,
+            level,
+            component,
+            collection_id,
+            message,
+            at: Utc::now(),
+        };
+        state.next_id += 1;
+        state.events.push_back(event.clone());
+        while state.events.len() > EVENT_HISTORY_LIMIT {
+            state.events.pop_front();
+        }
+        // No subscribers is fine
+        let _ = self.sender.send(event.clone());
+        event
+    }
+
+    /// Events with an ID greater than `after` (all kept events when None)
+    /// and at least `min_level`, oldest first.
+    pub fn events(&self, after: Option<u64>, min_level: EventLevel) -> Vec<ServiceEvent> {
+        let state = self.state.lock().unwrap();
+        state
+            .events
+            .iter()
+            .filter(|event| after.map_or(true, |after| event.id > after))
+            .filter(|event| event.level >= min_level)
+            .cloned()
+            .collect()
+    }
+
+    /// Stream of events published from now on.
+    pub fn subscribe(&self) -> broadcast::Receiver<ServiceEvent> {
+        self.sender.subscribe()
+    }
+}
+
+impl Default for EventLog {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_event_log() {
+        let log = EventLog::new();
+        for i in 0..EVENT_HISTORY_LIMIT + 10 {
+            let level = if i % 2 == 0 {
+                EventLevel::Info
+            } else {
+                EventLevel::Warning
+            };
+            log.publish(level, EventComponent::Scheduler, None, format!("run {}", i));
+        }
+
+        let mut updates = log.subscribe();
+        let collection_id = Some(CollectionId::new());
+        log.publish(
+            EventLevel::Error,
+            EventComponent::Replication,
+            collection_id,
+            "target unreachable".to_string(),
+        );
+        let event = updates.try_recv().unwrap();
+        assert_eq!(event.id, 511);
+        assert_eq!(event.collection_id, collection_id);
+
+        // Only the last EVENT_HISTORY_LIMIT are kept
+        let kept = log.events(None, EventLevel::Info);
+        assert_eq!(kept.len(), EVENT_HISTORY_LIMIT);
+        assert_eq!(kept[0].id, 12);
+
+        let after = log.events(Some(505), EventLevel::Info);
+        assert_eq!(
+            after.iter().map(|e| e.id).collect::<Vec<_>>(),
+            [506, 507, 508, 509, 510, 511]
+        );
+        let warnings = log.events(Some(505), EventLevel::Warning);
+        assert_eq!(
+            warnings.iter().map(|e| e.id).collect::<Vec<_>>(),
+            [506, 508, 510, 511]
+        );
+        let errors = log.events(None, EventLevel::Error);
+        assert_eq!(errors.len(), 1);
+    }
+}
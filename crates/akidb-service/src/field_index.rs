@@ -0,0 +1,407 @@
+//! Metadata field indexes.
+//!
+//! A field index maps the values of one top-level metadata field to the
+//! documents holding them, like a payload index. Filters are otherwise
+//! evaluated against every document (or every search candidate), which is
+//! slow and, for searches, misses matches of selective filters on
+//! high-cardinality fields such as `user_id`. With an index, the `$eq` and
+//! `$in` conditions on the field (also inside `$and` and `$or`) select the
+//! candidate documents up front; the whole filter is still checked on each
+//! candidate.
+//!
+//! An index has a type and only indexes values of that type (array fields:
+//! each element); conditions on values of another type do not use it.
+//! Index definitions are persisted when a metadata store is configured; the
+//! indexes themselves are kept in memory and rebuilt from the documents at
+//! startup (see `CollectionService::load_field_indexes`).
+
+use akidb_core::{
+    CoreError, CoreResult, DistanceMetric, DocumentId, SearchResult, VectorDocument, VectorIndex,
+};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::str::FromStr;
+
+use crate::filter::{FieldCondition, MetadataFilter};
+
+/// Maximum field indexes per collection.
+pub const MAX_FIELD_INDEXES: usize = 16;
+
+/// Searches whose filter selects at most this many documents through the
+/// field indexes score them exactly instead of searching the vector index.
+pub const MAX_EXACT_CANDIDATES: usize = 10_000;
+
+/// Value type of an indexed field.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum FieldIndexType {
+    /// Strings, e.g. IDs and tags.
+    Keyword,
+    Integer,
+    Float,
+    Bool,
+}
+
+impl FieldIndexType {
+    pub const fn as_str(&self) -> &'static str {
+        match self {
+            Self::Keyword => "keyword",
+            Self::Integer => "integer",
+            Self::Float => "float",
+            Self::Bool => "bool",
+        }
+    }
+
+    fn accepts(self, value: &JsonValue) -> bool {
+        match self {
+            Self::Keyword => value.is_string(),
+            Self::Integer => value.is_i64() || value.is_u64(),
+            Self::Float => value.is_number(),
+            Self::Bool => value.is_boolean(),
+        }
+    }
+}
+
+impl FromStr for FieldIndexType {
+    type Err = ();
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "keyword" => Ok(Self::Keyword),
+            "integer" => Ok(Self::Integer),
+            "float" => Ok(Self::Float),
+            "bool" => Ok(Self::Bool),
+            _ => Err(()),
+        }
+    }
+}
+
+/// An index and its size.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FieldIndexInfo {
+    pub field: String,
+    pub field_type: FieldIndexType,
+
+    /// Distinct values indexed.
+    pub values: usize,
+
+    /// Documents with at least one indexed value.
+    pub documents: usize,
+}
+
+/// Postings of one field.
+#[derive(Debug)]
+struct FieldIndex {
+    field_type: FieldIndexType,
+    postings: HashMap<String, HashSet<DocumentId>>,
+    /// Keys of each document, for removal.
+    keys: HashMap<DocumentId, Vec<String>>,
+}
+
+impl FieldIndex {
+    fn new(field_type: FieldIndexType) -> Self {
+        Self {
+            field_type,
+            postings: HashMap::new(),
+            keys: HashMap::new(),
+        }
+    }
+
+    fn key(value: &JsonValue) -> String {
+        value.to_string()
+    }
+
+    fn insert(&mut self, doc_id: DocumentId, value: Option<&JsonValue>) {
+        self.remove(doc_id);
+        let values: Vec<&JsonValue> = match value {
+            Some(JsonValue::Array(elements)) => elements.iter().collect(),
+            Some(value) => vec![value],
+            None => Vec::new(),
+        };
+        let mut keys: Vec<String> = values
+            .into_iter()
+            .filter(|value| self.field_type.accepts(value))
+            .map(Self::key)
+            .collect();
+        keys.sort();
+        keys.dedup();
+        if keys.is_empty() {
+            return;
+        }
+        for key in &keys {
+            self.postings.entry(key.clone()).or_default().insert(doc_id);
+        }
+        self.keys.insert(doc_id, keys);
+    }
+
+    fn remove(&mut self, doc_id: DocumentId) {
+        for key in self.keys.remove(&doc_id).unwrap_or_default() {
+            if let Some(docs) = self.postings.get_mut(&key) {
+                docs.remove(&doc_id);
+                if docs.is_empty() {
+                    self.postings.remove(&key);
+                }
+            }
+        }
+    }
+
+    /// Documents holding any of `values`, or None if a value is not of the
+    /// index type.
+    fn lookup(&self, values: &[JsonValue]) -> Option<HashSet<DocumentId>> {
+        let mut docs = HashSet::new();
+        for value in values {
+            if !self.field_type.accepts(value) {
+                return None;
+            }
+            if let Some(postings) = self.postings.get(&Self::key(value)) {
+                docs.extend(postings);
+            }
+        }
+        Some(docs)
+    }
+}
+
+/// The field indexes of a collection.
+#[derive(Debug, Default)]
+pub struct FieldIndexes {
+    indexes: BTreeMap<String, FieldIndex>,
+}
+
+impl FieldIndexes {
+    /// Index `field` of `docs`.
+    pub fn create<'a>(
+        &mut self,
+        field: &str,
+        field_type: FieldIndexType,
+        docs: impl IntoIterator<Item = &'a VectorDocument>,
+    ) -> CoreResult<FieldIndexInfo> {
+        if field.is_empty() || field.starts_with('$') {
+            return Err(CoreError::ValidationError(format!(
+                "invalid field name '{}'",
+                field
+            )));
+        }
+        if self.indexes.contains_key(field) {
+            return Err(CoreError::already_exists("Field index", field));
+        }
+        if self.indexes.len() >= MAX_FIELD_INDEXES {
+            return Err(CoreError::ValidationError(format!(
+                "a collection can have at most {} field indexes",
+                MAX_FIELD_INDEXES
+            )));
+        }
+        let mut index = FieldIndex::new(field_type);
+        for doc in docs {
+            index.insert(doc.doc_id, field_value(doc.metadata.as_ref(), field));
+        }
+        let info = info(field, &index);
+        self.indexes.insert(field.to_string(), index);
+        Ok(info)
+    }
+
+    /// Drop the index of `field`; false if there is none.
+    /// Returns true if `field` is indexed.
+    pub fn contains(&self, field: &str) -> bool {
+        self.indexes.contains_key(field)
+    }
+
+    pub fn remove_index(&mut self, field: &str) -> bool {
+        self.indexes.remove(field).is_some()
+    }
+
+    pub fn list(&self) -> Vec<FieldIndexInfo> {
+        self.indexes
+            .iter()
+            .map(|(field, index)| info(field, index))
+            .collect()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.indexes.is_empty()
+    }
+
+    /// Index (or re-index) a document's metadata.
+    pub fn insert(&mut self, doc_id: DocumentId, metadata: Option<&JsonValue>) {
+        for (field, index) in &mut self.indexes {
+            index.insert(doc_id, field_value(metadata, field));
+        }
+    }
+
+    pub fn remove(&mut self, doc_id: DocumentId) {
+        for index in self.indexes.values_mut() {
+            index.remove(doc_id);
+        }
+    }
+
+    /// A superset of the documents matching `filter`, or None when the
+    /// indexes cannot narrow it down.
+    pub fn candidates(&self, filter: &MetadataFilter) -> Option<HashSet<DocumentId>> {
+        match filter {
+            MetadataFilter::Field { field, condition } => {
+                let index = self.indexes.get(field)?;
+                match condition {
+                    FieldCondition::Eq(value) => index.lookup(std::slice::from_ref(value)),
+                    FieldCondition::In(values) => index.lookup(values),
+                    _ => None,
+                }
+            }
+            // Any indexed clause narrows a conjunction
+            MetadataFilter::And(filters) => filters
+                .iter()
+                .filter_map(|filter| self.candidates(filter))
+                .reduce(|a, b| a.intersection(&b).copied().collect()),
+            // Every clause of a disjunction must be indexed
+            MetadataFilter::Or(filters) => {
+                let mut docs = HashSet::new();
+                for filter in filters {
+                    docs.extend(self.candidates(filter)?);
+                }
+                Some(docs)
+            }
+            MetadataFilter::Not(_) => None,
+        }
+    }
+}
+
+/// Exact k-NN over `candidates`, keeping those matching `filter`, best
+/// first.
+pub async fn search_candidates(
+    index: &dyn VectorIndex,
+    candidates: HashSet<DocumentId>,
+    query_vector: &[f32],
+    top_k: usize,
+    metric: DistanceMetric,
+    filter: &MetadataFilter,
+) -> CoreResult<Vec<SearchResult>> {
+    let mut results = Vec::new();
+    for doc_id in candidates {
+        let Some(doc) = index.get(doc_id).await? else {
+            continue;
+        };
+        if doc.vector.len() != query_vector.len() || !filter.matches(doc.metadata.as_ref()) {
+            continue;
+        }
+        let mut result = SearchResult::new(doc_id, metric.compute(query_vector, &doc.vector));
+        result.external_id = doc.external_id;
+        result.metadata = doc.metadata;
+        results.push(result);
+    }
+    // L2 scores are distances: lower is better
+    results.sort_by(|a, b| {
+        let order = match metric {
+            DistanceMetric::L2 => a.score.total_cmp(&b.score),
+            DistanceMetric::Cosine | DistanceMetric::Dot => b.score.total_cmp(&a.score),
+        };
+        order.then_with(|| a.doc_id.as_uuid().cmp(&b.doc_id.as_uuid()))
+    });
+    results.truncate(top_k);
+    Ok(results)
+}
+
+fn field_value<'a>(metadata: Option<&'a JsonValue>, field: &str) -> Option<&'a JsonValue> {
+    metadata
+        .and_then(JsonValue::as_object)
+        .and_then(|fields| fields.get(field))
+}
+
+fn info(field: &str, index: &FieldIndex) -> FieldIndexInfo {
+    FieldIndexInfo {
+        field: field.to_string(),
+        field_type: index.field_type,
+        values: index.postings.len(),
+        documents: index.keys.len(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_field_index_candidates() {
+        let docs: Vec<VectorDocument> = [
+            json!({ "user_id": "u1", "tags": ["a", "b"] }),
+            json!({ "user_id": "u2", "tags": ["b"] }),
+            json!({ "user_id": 7 }),
+        ]
+        .into_iter()
+        .map(|metadata| {
+            VectorDocument::new(DocumentId::new(), vec![1.0; 16]).with_metadata(metadata)
+        })
+        .collect();
+        let ids: Vec<DocumentId> = docs.iter().map(|doc| doc.doc_id).collect();
+
+        let mut indexes = FieldIndexes::default();
+        let info = indexes
+            .create("user_id", FieldIndexType::Keyword, &docs)
+            .unwrap();
+        assert_eq!((info.values, info.documents), (2, 2));
+        indexes
+            .create("tags", FieldIndexType::Keyword, &docs)
+            .unwrap();
+        assert!(indexes
+            .create("tags", FieldIndexType::Keyword, &docs)
+            .is_err());
+
+        let candidates = |filter: MetadataFilter| indexes.candidates(&filter);
+        assert_eq!(
+            candidates(MetadataFilter::eq("user_id", "u1")),
+            Some(HashSet::from([ids[0]]))
+        );
+        assert_eq!(
+            candidates(MetadataFilter::and([
+                MetadataFilter::eq("tags", "b"),
+                MetadataFilter::gt("year", 2020),
+            ])),
+            Some(HashSet::from([ids[0], ids[1]]))
+        );
+        assert_eq!(
+            candidates(MetadataFilter::or([
+                MetadataFilter::eq("user_id", "u2"),
+                MetadataFilter::is_in("tags", ["a"]),
+            ])),
+            Some(HashSet::from([ids[0], ids[1]]))
+        );
+        // Unindexed field, value of another type, or negation
+        assert_eq!(candidates(MetadataFilter::eq("lang", "en")), None);
+        assert_eq!(candidates(MetadataFilter::eq("user_id", 7)), None);
+        assert_eq!(
+            candidates(MetadataFilter::not(MetadataFilter::eq("user_id", "u1"))),
+            None
+        );
+
+        // Re-indexed on update, dropped on delete
+        indexes.insert(ids[0], Some(&json!({ "user_id": "u2" })));
+        indexes.remove(ids[1]);
+        assert_eq!(
+            indexes.candidates(&MetadataFilter::eq("user_id", "u2")),
+            Some(HashSet::from([ids[0]]))
+        );
+        assert_eq!(
+            indexes.candidates(&MetadataFilter::eq("tags", "b")),
+            Some(HashSet::new())
+        );
+        assert!(indexes.remove_index("tags"));
+        assert!(!indexes.contains("tags"));
+        assert_eq!(indexes.list().len(), 1);
+    }
+
+    #[test]
+    fn test_field_index_type_names() {
+        for field_type in [
+            FieldIndexType::Keyword,
+            FieldIndexType::Integer,
+            FieldIndexType::Float,
+            FieldIndexType::Bool,
+        ] {
+            assert_eq!(field_type.as_str().parse(), Ok(field_type));
+            assert_eq!(
+                serde_json::to_value(field_type).unwrap(),
+                field_type.as_str()
+            );
+        }
+        assert!("text".parse::<FieldIndexType>().is_err());
+    }
+}
@@ -0,0 +1,498 @@
+//! Metadata filters.
+//!
+//! A filter selects documents by their metadata, e.g. to delete every chunk
+//! of a source document. See `CollectionService::delete_by_filter`.
+//!
+//! Filters are JSON objects. A plain field value requires that exact value,
+//! so `{"lang": "en", "page": 2}` keeps documents with both. A field may
+//! instead map to operators, and filters combine with `$and`, `$or` and
+//! `$not`:
+//!
+//! ```json
+//! {"$and": [
+//!   {"lang": "en"},
+//!   {"year": {"$gte": 2020}},
+//!   {"$or": [{"tag": {"$in": ["rust", "go"]}}, {"pinned": true}]}
+//! ]}
+//! ```
+//!
+//! Field operators: `$eq`, `$ne` (also true when the field is missing),
+//! `$gt`, `$gte`, `$lt`, `$lte` (numbers compare numerically, strings
+//! lexicographically, e.g. ISO 8601 dates; other values never match), `$in`,
+//! `$nin` (against a list of values; an array field matches `$in` if any of
+//! its elements is listed) and `$exists`. The builders (`MetadataFilter::eq`,
+//! `MetadataFilter::and`, ...) produce the same JSON.
+
+use akidb_core::{CoreError, CoreResult};
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+use serde_json::{Map, Value as JsonValue};
+use std::cmp::Ordering;
+
+/// Maximum nesting of `$and`, `$or` and `$not`.
+pub const MAX_FILTER_DEPTH: usize = 8;
+
+/// Maximum values of an `$in` or `$nin` list.
+pub const MAX_FILTER_VALUES: usize = 1024;
+
+/// A condition on one metadata field.
+#[derive(Debug, Clone, PartialEq)]
+pub enum FieldCondition {
+    Eq(JsonValue),
+    Ne(JsonValue),
+    Gt(JsonValue),
+    Gte(JsonValue),
+    Lt(JsonValue),
+    Lte(JsonValue),
+    In(Vec<JsonValue>),
+    NotIn(Vec<JsonValue>),
+    Exists(bool),
+}
+
+impl FieldCondition {
+    fn operator(&self) -> &'static str {
+        match self {
+            Self::Eq(_) => "$eq",
+            Self::Ne(_) => "$ne",
+            Self::Gt(_) => "$gt",
+            Self::Gte(_) => "$gte",
+            Self::Lt(_) => "$lt",
+            Self::Lte(_) => "$lte",
+            Self::In(_) => "$in",
+            Self::NotIn(_) => "$nin",
+            Self::Exists(_) => "$exists",
+        }
+    }
+
+    fn parse(operator: &str, operand: &JsonValue) -> Result<Self, String> {
+        let list = || match operand {
+            JsonValue::Array(values) => Ok(values.clone()),
+            _ => Err(format!("{} takes a list of values", operator)),
+        };
+        Ok(match operator {
+            "$eq" => Self::Eq(operand.clone()),
+            "$ne" => Self::Ne(operand.clone()),
+            "$gt" => Self::Gt(operand.clone()),
+            "$gte" => Self::Gte(operand.clone()),
+            "$lt" => Self::Lt(operand.clone()),
+            "$lte" => Self::Lte(operand.clone()),
+            "$in" => Self::In(list()?),
+            "$nin" => Self::NotIn(list()?),
+            "$exists" => Self::Exists(
+                operand
+                    .as_bool()
+                    .ok_or_else(|| "$exists takes true or false".to_string())?,
+            ),
+            _ => return Err(format!("unknown filter operator '{}'", operator)),
+        })
+    }
+
+    fn operand(&self) -> JsonValue {
+        match self {
+            Self::Eq(value)
+            | Self::Ne(value)
+            | Self::Gt(value)
+            | Self::Gte(value)
+            | Self::Lt(value)
+            | Self::Lte(value) => value.clone(),
+            Self::In(values) | Self::NotIn(values) => JsonValue::Array(values.clone()),
+            Self::Exists(exists) => JsonValue::Bool(*exists),
+        }
+    }
+
+    fn validate(&self, field: &str) -> CoreResult<()> {
+        match self {
+            Self::Gt(bound) | Self::Gte(bound) | Self::Lt(bound) | Self::Lte(bound)
+                if !(bound.is_number() || bound.is_string()) =>
+            {
+                Err(CoreError::ValidationError(format!(
+                    "field '{}': {} takes a number or a string",
+                    field,
+                    self.operator()
+                )))
+            }
+            Self::In(values) | Self::NotIn(values)
+                if values.is_empty() || values.len() > MAX_FILTER_VALUES =>
+            {
+                Err(CoreError::ValidationError(format!(
+                    "field '{}': {} takes 1 to {} values (got {})",
+                    field,
+                    self.operator(),
+                    MAX_FILTER_VALUES,
+                    values.len()
+                )))
+            }
+            _ => Ok(()),
+        }
+    }
+
+    fn matches(&self, value: Option<&JsonValue>) -> bool {
+        let range = |bound: &JsonValue, accept: fn(Ordering) -> bool| {
+            value
+                .and_then(|value| compare(value, bound))
+                .map_or(false, accept)
+        };
+        match self {
+            Self::Eq(expected) => value == Some(expected),
+            Self::Ne(expected) => value != Some(expected),
+            Self::Gt(bound) => range(bound, Ordering::is_gt),
+            Self::Gte(bound) => range(bound, Ordering::is_ge),
+            Self::Lt(bound) => range(bound, Ordering::is_lt),
+            Self::Lte(bound) => range(bound, Ordering::is_le),
+            Self::In(values) => value.map_or(false, |value| is_listed(value, values)),
+            Self::NotIn(values) => !value.map_or(false, |value| is_listed(value, values)),
+            Self::Exists(exists) => value.is_some() == *exists,
+        }
+    }
+}
+
+/// Orders numbers numerically and strings lexicographically; other pairs
+/// are not comparable.
+fn compare(value: &JsonValue, bound: &JsonValue) -> Option<Ordering> {
+    match (value, bound) {
+        (JsonValue::Number(a), JsonValue::Number(b)) => a.as_f64()?.partial_cmp(&b.as_f64()?),
+        (JsonValue::String(a), JsonValue::String(b)) => Some(a.cmp(b)),
+        _ => None,
+    }
+}
+
+/// Whether `value`, or one of its elements if it is an array, is listed.
+fn is_listed(value: &JsonValue, values: &[JsonValue]) -> bool {
+    values.contains(value)
+        || value.as_array().map_or(false, |elements| {
+            elements.iter().any(|e| values.contains(e))
+        })
+}
+
+/// A filter on document metadata (see the module documentation).
+#[derive(Debug, Clone, PartialEq)]
+pub enum MetadataFilter {
+    /// Every filter holds (none: an empty filter, rejected by `validate`).
+    And(Vec<MetadataFilter>),
+    /// At least one filter holds.
+    Or(Vec<MetadataFilter>),
+    Not(Box<MetadataFilter>),
+    Field {
+        field: String,
+        condition: FieldCondition,
+    },
+}
+
+impl Default for MetadataFilter {
+    fn default() -> Self {
+        Self::And(Vec::new())
+    }
+}
+
+impl MetadataFilter {
+    fn field(field: impl Into<String>, condition: FieldCondition) -> Self {
+        Self::Field {
+            field: field.into(),
+            condition,
+        }
+    }
+
+    pub fn eq(field: impl Into<String>, value: impl Into<JsonValue>) -> Self {
+        Self::field(field, FieldCondition::Eq(value.into()))
+    }
+
+    pub fn ne(field: impl Into<String>, value: impl Into<JsonValue>) -> Self {
+        Self::field(field, FieldCondition::Ne(value.into()))
+    }
+
+    pub fn gt(field: impl Into<String>, bound: impl Into<JsonValue>) -> Self {
+        Self::field(field, FieldCondition::Gt(bound.into()))
+    }
+
+    pub fn gte(field: impl Into<String>, bound: impl Into<JsonValue>) -> Self {
+        Self::field(field, FieldCondition::Gte(bound.into()))
+    }
+
+    pub fn lt(field: impl Into<String>, bound: impl Into<JsonValue>) -> Self {
+        Self::field(field, FieldCondition::Lt(bound.into()))
+    }
+
+    pub fn lte(field: impl Into<String>, bound: impl Into<JsonValue>) -> Self {
+        Self::field(field, FieldCondition::Lte(bound.into()))
+    }
+
+    /// The field has one of `values` (`$in`).
+    pub fn is_in<V: Into<JsonValue>>(
+        field: impl Into<String>,
+        values: impl IntoIterator<Item = V>,
+    ) -> Self {
+        let values = values.into_iter().map(Into::into).collect();
+        Self::field(field, FieldCondition::In(values))
+    }
+
+    /// The field has none of `values` (`$nin`).
+    pub fn not_in<V: Into<JsonValue>>(
+        field: impl Into<String>,
+        values: impl IntoIterator<Item = V>,
+    ) -> Self {
+        let values = values.into_iter().map(Into::into).collect();
+        Self::field(field, FieldCondition::NotIn(values))
+    }
+
+    pub fn exists(field: impl Into<String>) -> Self {
+        Self::field(field, FieldCondition::Exists(true))
+    }
+
+    pub fn and(filters: impl IntoIterator<Item = MetadataFilter>) -> Self {
+        Self::And(filters.into_iter().collect())
+    }
+
+    pub fn or(filters: impl IntoIterator<Item = MetadataFilter>) -> Self {
+        Self::Or(filters.into_iter().collect())
+    }
+
+    pub fn not(filter: MetadataFilter) -> Self {
+        Self::Not(Box::new(filter))
+    }
+
+    /// Number of field conditions, checked against the tenant's filter limit.
+    pub fn clauses(&self) -> usize {
+        match self {
+            Self::And(filters) | Self::Or(filters) => filters.iter().map(Self::clauses).sum(),
+            Self::Not(filter) => filter.clauses(),
+            Self::Field { .. } => 1,
+        }
+    }
+
+    /// Reject filters matching every document, malformed operands and
+    /// filters nested deeper than `MAX_FILTER_DEPTH`.
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.clauses() == 0 {
+            return Err(CoreError::ValidationError(
+                "filter must have at least one field".to_string(),
+            ));
+        }
+        self.validate_at(0)
+    }
+
+    fn validate_at(&self, depth: usize) -> CoreResult<()> {
+        if depth > MAX_FILTER_DEPTH {
+            return Err(CoreError::ValidationError(format!(
+                "filters can be nested at most {} levels deep",
+                MAX_FILTER_DEPTH
+            )));
+        }
+        match self {
+            Self::And(filters) | Self::Or(filters) => {
+                if filters.is_empty() && depth > 0 {
+                    return Err(CoreError::ValidationError(
+                        "$and and $or take at least one filter".to_string(),
+                    ));
+                }
+                filters.iter().try_for_each(|f| f.validate_at(depth + 1))
+            }
+            Self::Not(filter) => filter.validate_at(depth + 1),
+            Self::Field { field, condition } => {
+                if field.is_empty() {
+                    return Err(CoreError::ValidationError(
+                        "filter field names cannot be empty".to_string(),
+                    ));
+                }
+                condition.validate(field)
+            }
+        }
+    }
+
+    /// Returns true if `metadata` satisfies the filter.
+    pub fn matches(&self, metadata: Option<&JsonValue>) -> bool {
+        match self {
+            Self::And(filters) => filters.iter().all(|f| f.matches(metadata)),
+            Self::Or(filters) => filters.iter().any(|f| f.matches(metadata)),
+            Self::Not(filter) => !filter.matches(metadata),
+            Self::Field { field, condition } => {
+                let value = metadata
+                    .and_then(JsonValue::as_object)
+                    .and_then(|fields| fields.get(field));
+                condition.matches(value)
+            }
+        }
+    }
+
+    /// Parse the JSON form of a filter.
+    pub fn from_json(value: &JsonValue) -> CoreResult<Self> {
+        Self::parse(value).map_err(CoreError::ValidationError)
+    }
+
+    fn parse(value: &JsonValue) -> Result<Self, String> {
+        let JsonValue::Object(entries) = value else {
+            return Err("a filter must be a JSON object".to_string());
+        };
+        let mut filters = Vec::with_capacity(entries.len());
+        for (key, value) in entries {
+            match key.as_str() {
+                "$and" | "$or" => {
+                    let JsonValue::Array(items) = value else {
+                        return Err(format!("{} takes a list of filters", key));
+                    };
+                    let items = items.iter().map(Self::parse).collect::<Result<_, _>>()?;
+                    filters.push(if key == "$and" {
+                        Self::And(items)
+                    } else {
+                        Self::Or(items)
+                    });
+                }
+                "$not" => filters.push(Self::not(Self::parse(value)?)),
+                operator if operator.starts_with('$') => {
+                    return Err(format!("unknown filter operator '{}'", operator));
+                }
+                field => match value {
+                    // An object of operators; other objects are literal values
+                    JsonValue::Object(operators)
+                        if !operators.is_empty()
+                            && operators.keys().all(|k| k.starts_with('$')) =>
+                    {
+                        for (operator, operand) in operators {
+                            let condition = FieldCondition::parse(operator, operand)?;
+                            filters.push(Self::field(field, condition));
+                        }
+                    }
+                    _ => filters.push(Self::eq(field, value.clone())),
+                },
+            }
+        }
+        Ok(match filters.len() {
+            1 => filters.remove(0),
+            _ => Self::And(filters),
+        })
+    }
+
+    /// The JSON form of the filter (plain field values for equality, an
+    /// object per filter otherwise).
+    pub fn to_json(&self) -> JsonValue {
+        let mut object = Map::new();
+        match self {
+            Self::And(filters) => {
+                // Conditions on distinct fields merge into one object
+                let mut merged = Map::new();
+                for filter in filters {
+                    let Self::Field { field, .. } = filter else {
+                        break;
+                    };
+                    if merged.contains_key(field) {
+                        break;
+                    }
+                    if let JsonValue::Object(entry) = filter.to_json() {
+                        merged.extend(entry);
+                    }
+                }
+                if merged.len() == filters.len() {
+                    return JsonValue::Object(merged);
+                }
+                let items = filters.iter().map(Self::to_json).collect();
+                object.insert("$and".to_string(), JsonValue::Array(items));
+            }
+            Self::Or(filters) => {
+                let items = filters.iter().map(Self::to_json).collect();
+                object.insert("$or".to_string(), JsonValue::Array(items));
+            }
+            Self::Not(filter) => {
+                object.insert("$not".to_string(), filter.to_json());
+            }
+            Self::Field { field, condition } => {
+                let value = match condition {
+                    // Objects written as-is would read back as operators
+                    FieldCondition::Eq(value) if !value.is_object() => value.clone(),
+                    condition => {
+                        let mut operator = Map::new();
+                        operator.insert(condition.operator().to_string(), condition.operand());
+                        JsonValue::Object(operator)
+                    }
+                };
+                object.insert(field.clone(), value);
+            }
+        }
+        JsonValue::Object(object)
+    }
+}
+
+impl Serialize for MetadataFilter {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        self.to_json().serialize(serializer)
+    }
+}
+
+impl<'de> Deserialize<'de> for MetadataFilter {
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        let value = JsonValue::deserialize(deserializer)?;
+        Self::parse(&value).map_err(serde::de::Error::custom)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_filter_matches_all_fields() {
+        let filter: MetadataFilter =
+            serde_json::from_value(json!({"source": "a.md", "page": 2})).unwrap();
+        assert_eq!(filter.clauses(), 2);
+        assert!(filter.matches(Some(&json!({"source": "a.md", "page": 2, "x": 1}))));
+        assert!(!filter.matches(Some(&json!({"source": "a.md", "page": 3}))));
+        assert!(!filter.matches(None));
+        assert!(MetadataFilter::default().validate().is_err());
+    }
+
+    #[test]
+    fn test_filter_operators() {
+        let filter = MetadataFilter::and([
+            MetadataFilter::eq("lang", "en"),
+            MetadataFilter::gte("year", 2020),
+            MetadataFilter::or([
+                MetadataFilter::is_in("tag", ["rust", "go"]),
+                MetadataFilter::eq("pinned", true),
+            ]),
+        ]);
+        filter.validate().unwrap();
+        assert_eq!(filter.clauses(), 4);
+        assert!(filter.matches(Some(&json!({"lang": "en", "year": 2021, "tag": "go"}))));
+        // Array fields match $in on any element
+        assert!(filter.matches(Some(
+            &json!({"lang": "en", "year": 2020.0, "tag": ["c", "rust"]})
+        )));
+        assert!(filter.matches(Some(&json!({"lang": "en", "year": 2024, "pinned": true}))));
+        assert!(!filter.matches(Some(&json!({"lang": "en", "year": 2019, "tag": "go"}))));
+        assert!(!filter.matches(Some(&json!({"lang": "en", "year": "2021", "tag": "go"}))));
+
+        // Same filter in its JSON form
+        let json = json!({"$and": [
+            {"lang": "en"},
+            {"year": {"$gte": 2020}},
+            {"$or": [{"tag": {"$in": ["rust", "go"]}}, {"pinned": true}]}
+        ]});
+        let parsed: MetadataFilter = serde_json::from_value(json.clone()).unwrap();
+        assert_eq!(parsed, filter);
+        assert_eq!(serde_json::to_value(&filter).unwrap(), json);
+
+        let negated = MetadataFilter::not(MetadataFilter::exists("deleted_at"));
+        assert!(negated.matches(Some(&json!({"a": 1}))));
+        assert!(MetadataFilter::ne("lang", "en").matches(None));
+        assert!(
+            MetadataFilter::lt("date", "2026-01-01").matches(Some(&json!({"date": "2025-12-31"})))
+        );
+
+        // Objects without operators are literal values
+        let literal: MetadataFilter = serde_json::from_value(json!({"meta": {"a": 1}})).unwrap();
+        assert!(literal.matches(Some(&json!({"meta": {"a": 1}}))));
+        assert_eq!(
+            serde_json::to_value(&literal).unwrap(),
+            json!({"meta": {"$eq": {"a": 1}}})
+        );
+
+        assert!(serde_json::from_value::<MetadataFilter>(json!({"a": {"$like": "x"}})).is_err());
+        assert!(MetadataFilter::gt("a", json!([1])).validate().is_err());
+        assert!(MetadataFilter::is_in("a", Vec::<i32>::new())
+            .validate()
+            .is_err());
+        let mut deep = MetadataFilter::eq("a", 1);
+        for _ in 0..=MAX_FILTER_DEPTH {
+            deep = MetadataFilter::not(deep);
+        }
+        assert!(deep.validate().is_err());
+    }
+}
@@ -0,0 +1,532 @@
+//! Geo-aware routing across regional endpoints.
+//!
+//! A multi-region deployment serves every collection from several regions
+//! (see the replication module), with one region designated primary.
+//! [`RegionRouter`] sends reads to the nearest healthy region and writes to
+//! the primary. Regions are probed periodically (`spawn_prober`); a probe's
+//! round trip feeds a smoothed latency per region, and `failure_threshold`
+//! consecutive failed probes or requests mark a region unhealthy until it
+//! answers again. `execute` runs a request against the routed regions in
+//! order, failing over to the next one on transient errors; writes fail over
+//! from the primary only when `write_failover` is set, since the target of a
+//! failed-over write must accept writes (e.g. after promoting it).
+
+use akidb_core::{CoreError, CoreResult};
+use async_trait::async_trait;
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+use std::future::Future;
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, Instant};
+use tokio::task::{JoinHandle, JoinSet};
+
+use crate::bulk::is_transient;
+
+/// Default interval between probes of each region.
+pub const DEFAULT_PROBE_INTERVAL: Duration = Duration::from_secs(10);
+
+/// Default timeout of a probe.
+pub const DEFAULT_PROBE_TIMEOUT: Duration = Duration::from_secs(2);
+
+/// Weight of the latest probe in the smoothed latency.
+const LATENCY_SMOOTHING: f64 = 0.3;
+
+fn default_failure_threshold() -> u32 {
+    3
+}
+
+/// An endpoint of the deployment in one region.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct RegionEndpoint {
+    pub region: String,
+
+    /// Base URL of the region's API, e.g. "http://akidb.eu-west-1.internal:8080".
+    pub endpoint: String,
+
+    /// Whether writes go to this region.
+    #[serde(default)]
+    pub primary: bool,
+}
+
+impl RegionEndpoint {
+    pub fn new(region: impl Into<String>, endpoint: impl Into<String>) -> Self {
+        Self {
+            region: region.into(),
+            endpoint: endpoint.into(),
+            primary: false,
+        }
+    }
+
+    pub fn primary(mut self) -> Self {
+        self.primary = true;
+        self
+    }
+}
+
+/// Router settings.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RegionRouterConfig {
+    /// Regional endpoints; exactly one is primary.
+    pub endpoints: Vec<RegionEndpoint>,
+
+    /// Consecutive failures after which a region counts as unhealthy.
+    #[serde(default = "default_failure_threshold")]
+    pub failure_threshold: u32,
+
+    /// Send writes to the nearest healthy other region while the primary is
+    /// unhealthy.
+    #[serde(default)]
+    pub write_failover: bool,
+}
+
+impl RegionRouterConfig {
+    pub fn new(endpoints: Vec<RegionEndpoint>) -> Self {
+        Self {
+            endpoints,
+            failure_threshold: default_failure_threshold(),
+            write_failover: false,
+        }
+    }
+
+    pub fn with_failure_threshold(mut self, failure_threshold: u32) -> Self {
+        self.failure_threshold = failure_threshold;
+        self
+    }
+
+    pub fn with_write_failover(mut self) -> Self {
+        self.write_failover = true;
+        self
+    }
+
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.endpoints.is_empty() {
+            return Err(CoreError::ValidationError(
+                "at least one regional endpoint is required".to_string(),
+            ));
+        }
+        let primaries = self.endpoints.iter().filter(|e| e.primary).count();
+        if primaries != 1 {
+            return Err(CoreError::ValidationError(format!(
+                "exactly one region must be primary (got {})",
+                primaries
+            )));
+        }
+        let mut regions = HashSet::new();
+        for endpoint in &self.endpoints {
+            if !regions.insert(endpoint.region.as_str()) {
+                return Err(CoreError::ValidationError(format!(
+                    "region '{}' is listed more than once",
+                    endpoint.region
+                )));
+            }
+            let url = endpoint.endpoint.as_str();
+            if !(url.starts_with("http://") || url.starts_with("https://")) {
+                return Err(CoreError::ValidationError(format!(
+                    "endpoint of region '{}' must be an http:// or https:// URL (got '{}')",
+                    endpoint.region, url
+                )));
+            }
+        }
+        if self.failure_threshold == 0 {
+            return Err(CoreError::ValidationError(
+                "failure_threshold must be greater than 0".to_string(),
+            ));
+        }
+        Ok(())
+    }
+}
+
+/// Checks whether a regional endpoint answers.
+#[async_trait]
+pub trait EndpointProbe: Send + Sync {
+    /// Succeeds if `endpoint` is reachable and serving; the router times the
+    /// call.
+    async fn probe(&self, endpoint: &str) -> CoreResult<()>;
+}
+
+/// Probes an endpoint by opening a TCP connection to it.
+#[derive(Debug, Clone)]
+pub struct TcpProbe {
+    timeout: Duration,
+}
+
+impl Default for TcpProbe {
+    fn default() -> Self {
+        Self {
+            timeout: DEFAULT_PROBE_TIMEOUT,
+        }
+    }
+}
+
+impl TcpProbe {
+    pub fn new(timeout: Duration) -> Self {
+        Self { timeout }
+    }
+}
+
+/// `host:port` of an http(s) URL, with the scheme's default port if none is
+/// given.
+fn socket_address(endpoint: &str) -> CoreResult<String> {
+    let (rest, default_port) = if let Some(rest) = endpoint.strip_prefix("https://") {
+        (rest, 443)
+    } else if let Some(rest) = endpoint.strip_prefix("http://") {
+        (rest, 80)
+    } else {
+        return Err(CoreError::ValidationError(format!(
+            "unsupported endpoint '{}'",
+            endpoint
+        )));
+    };
+    let authority = rest.split('/').next().unwrap_or_default();
+    // The port follows the last ':' outside an IPv6 literal
+    let host_end = authority.rfind(']').map_or(0, |i| i + 1);
+    if authority[host_end..].contains(':') {
+        Ok(authority.to_string())
+    } else {
+        Ok(format!("{}:{}", authority, default_port))
+    }
+}
+
+#[async_trait]
+impl EndpointProbe for TcpProbe {
+    async fn probe(&self, endpoint: &str) -> CoreResult<()> {
+        let address = socket_address(endpoint)?;
+        match tokio::time::timeout(self.timeout, tokio::net::TcpStream::connect(&address)).await {
+            Ok(Ok(_)) => Ok(()),
+            Ok(Err(e)) => Err(CoreError::IoError(e)),
+            Err(_) => Err(CoreError::internal(format!(
+                "probe of {} timed out",
+                address
+            ))),
+        }
+    }
+}
+
+/// Kind of request being routed.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum RouteKind {
+    Read,
+    Write,
+}
+
+/// Observed health of a region.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RegionHealth {
+    #[serde(flatten)]
+    pub endpoint: RegionEndpoint,
+
+    pub healthy: bool,
+
+    /// Smoothed probe round trip (unknown until a probe succeeds).
+    pub latency_ms: Option<f64>,
+
+    pub consecutive_failures: u32,
+    pub last_error: Option<String>,
+    pub last_probe_at: Option<DateTime<Utc>>,
+}
+
+/// Routes requests across regional endpoints by health and latency.
+pub struct RegionRouter {
+    config: RegionRouterConfig,
+    probe: Arc<dyn EndpointProbe>,
+    health: Mutex<Vec<RegionHealth>>,
+}
+
+impl RegionRouter {
+    pub fn new(config: RegionRouterConfig, probe: Arc<dyn EndpointProbe>) -> CoreResult<Self> {
+        config.validate()?;
+        let health = config
+            .endpoints
+            .iter()
+            .map(|endpoint| RegionHealth {
+                endpoint: endpoint.clone(),
+                healthy: true,
+                latency_ms: None,
+                consecutive_failures: 0,
+                last_error: None,
+                last_probe_at: None,
+            })
+            .collect();
+        Ok(Self {
+            config,
+            probe,
+            health: Mutex::new(health),
+        })
+    }
+
+    pub fn config(&self) -> &RegionRouterConfig {
+        &self.config
+    }
+
+    /// Health of every region, in configuration order.
+    pub fn health(&self) -> Vec<RegionHealth> {
+        self.health.lock().unwrap().clone()
+    }
+
+    /// Regions to try for a request, best first.
+    ///
+    /// Reads go to healthy regions by ascending latency (regions not probed
+    /// yet after measured ones), then to unhealthy ones as a last resort.
+    /// Writes go to the primary; with `write_failover`, healthy other regions
+    /// by latency follow it, and lead while the primary is unhealthy.
+    pub fn route(&self, kind: RouteKind) -> Vec<RegionEndpoint> {
+        let mut regions = self.health();
+        let latency = |r: &RegionHealth| r.latency_ms.unwrap_or(f64::INFINITY);
+        regions.sort_by(|a, b| {
+            b.healthy
+                .cmp(&a.healthy)
+                .then(latency(a).total_cmp(&latency(b)))
+        });
+        match kind {
+            RouteKind::Read => regions.into_iter().map(|r| r.endpoint).collect(),
+            RouteKind::Write => {
+                let (primary, others): (Vec<_>, Vec<_>) =
+                    regions.into_iter().partition(|r| r.endpoint.primary);
+                let primary = primary.into_iter().next();
+                if !self.config.write_failover {
+                    return primary.map(|r| r.endpoint).into_iter().collect();
+                }
+                let fallbacks = others.into_iter().filter(|r| r.healthy);
+                match primary {
+                    Some(primary) if primary.healthy => std::iter::once(primary)
+                        .chain(fallbacks)
+                        .map(|r| r.endpoint)
+                        .collect(),
+                    primary => fallbacks.chain(primary).map(|r| r.endpoint).collect(),
+                }
+            }
+        }
+    }
+
+    /// Record a successful probe or request and its round trip.
+    pub fn report_success(&self, region: &str, latency: Option<Duration>) {
+        self.update(region, |health| {
+            health.healthy = true;
+            health.consecutive_failures = 0;
+            if let Some(latency) = latency {
+                let ms = latency.as_secs_f64() * 1000.0;
+                health.latency_ms = Some(match health.latency_ms {
+                    Some(smoothed) => smoothed + LATENCY_SMOOTHING * (ms - smoothed),
+                    None => ms,
+                });
+            }
+        });
+    }
+
+    /// Record a failed probe or request.
+    pub fn report_failure(&self, region: &str, error: &CoreError) {
+        let threshold = self.config.failure_threshold;
+        self.update(region, |health| {
+            health.consecutive_failures += 1;
+            health.last_error = Some(error.to_string());
+            if health.consecutive_failures >= threshold && health.healthy {
+                health.healthy = false;
+                tracing::warn!(
+                    "Region {} marked unhealthy after {} failures: {}",
+                    health.endpoint.region,
+                    health.consecutive_failures,
+                    error
+                );
+            }
+        });
+    }
+
+    fn update(&self, region: &str, f: impl FnOnce(&mut RegionHealth)) {
+        let mut health = self.health.lock().unwrap();
+        if let Some(health) = health.iter_mut().find(|h| h.endpoint.region == region) {
+            f(health);
+        }
+    }
+
+    /// Probe every region once, concurrently.
+    pub async fn probe_all(&self) {
+        let mut probes = JoinSet::new();
+        for endpoint in &self.config.endpoints {
+            let probe = Arc::clone(&self.probe);
+            let endpoint = endpoint.clone();
+            probes.spawn(async move {
+                let start = Instant::now();
+                let result = probe.probe(&endpoint.endpoint).await;
+                (endpoint.region, result, start.elapsed())
+            });
+        }
+        while let Some(joined) = probes.join_next().await {
+            let Ok((region, result, elapsed)) = joined else {
+                continue;
+            };
+            match result {
+                Ok(()) => self.report_success(&region, Some(elapsed)),
+                Err(e) => self.report_failure(&region, &e),
+            }
+            self.update(&region, |health| health.last_probe_at = Some(Utc::now()));
+        }
+    }
+
+    /// Probe every region every `interval`.
+    pub fn spawn_prober(self: &Arc<Self>, interval: Duration) -> JoinHandle<()> {
+        let router = Arc::clone(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            loop {
+                ticker.tick().await;
+                router.probe_all().await;
+            }
+        })
+    }
+
+    /// Run `request` against the routed regions in order until one succeeds.
+    ///
+    /// Transient failures (internal, I/O or storage errors) are recorded
+    /// against the region and the next one is tried; other errors are
+    /// returned at once.
+    pub async fn execute<T, F, Fut>(&self, kind: RouteKind, request: F) -> CoreResult<T>
+    where
+        F: Fn(RegionEndpoint) -> Fut,
+        Fut: Future<Output = CoreResult<T>>,
+    {
+        let mut last_error = None;
+        for endpoint in self.route(kind) {
+            let region = endpoint.region.clone();
+            match request(endpoint).await {
+                Ok(value) => {
+                    // Request times include server work; only probes set latency
+                    self.report_success(&region, None);
+                    return Ok(value);
+                }
+                Err(e) if is_transient(&e) => {
+                    tracing::debug!("Request to region {} failed, failing over: {}", region, e);
+                    self.report_failure(&region, &e);
+                    last_error = Some(e);
+                }
+                Err(e) => return Err(e),
+            }
+        }
+        Err(last_error
+            .unwrap_or_else(|| CoreError::invalid_state("no region is available for this request")))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::collections::HashMap;
+
+    /// Probe answering from a table of per-endpoint outcomes.
+    #[derive(Default)]
+    struct FakeProbe {
+        delays: Mutex<HashMap<String, Option<Duration>>>,
+    }
+
+    impl FakeProbe {
+        fn set(&self, endpoint: &str, delay: Option<Duration>) {
+            self.delays
+                .lock()
+                .unwrap()
+                .insert(endpoint.to_string(), delay);
+        }
+    }
+
+    #[async_trait]
+    impl EndpointProbe for FakeProbe {
+        async fn probe(&self, endpoint: &str) -> CoreResult<()> {
+            let delay = self.delays.lock().unwrap().get(endpoint).copied().flatten();
+            match delay {
+                Some(delay) => {
+                    tokio::time::sleep(delay).await;
+                    Ok(())
+                }
+                None => Err(CoreError::internal("unreachable")),
+            }
+        }
+    }
+
+    fn regions(endpoints: &[RegionEndpoint]) -> Vec<&str> {
+        endpoints.iter().map(|e| e.region.as_str()).collect()
+    }
+
+    #[tokio::test]
+    async fn test_routing_and_failover() {
+        let probe = Arc::new(FakeProbe::default());
+        probe.set("http://us", Some(Duration::from_millis(40)));
+        probe.set("http://eu", Some(Duration::from_millis(1)));
+        probe.set("http://ap", Some(Duration::from_millis(20)));
+        let config = RegionRouterConfig::new(vec![
+            RegionEndpoint::new("us", "http://us").primary(),
+            RegionEndpoint::new("eu", "http://eu"),
+            RegionEndpoint::new("ap", "http://ap"),
+        ])
+        .with_failure_threshold(1);
+        let router = RegionRouter::new(config.clone(), probe.clone()).unwrap();
+
+        router.probe_all().await;
+        assert_eq!(regions(&router.route(RouteKind::Read)), ["eu", "ap", "us"]);
+        assert_eq!(regions(&router.route(RouteKind::Write)), ["us"]);
+
+        // An unhealthy region is only read from as a last resort
+        probe.set("http://eu", None);
+        router.probe_all().await;
+        assert_eq!(regions(&router.route(RouteKind::Read)), ["ap", "us", "eu"]);
+
+        // Reads fail over past failing regions
+        let region = router
+            .execute(RouteKind::Read, |endpoint| async move {
+                match endpoint.region.as_str() {
+                    "ap" => Err(CoreError::internal("connection reset")),
+                    region => Ok(region.to_string()),
+                }
+            })
+            .await
+            .unwrap();
+        assert_eq!(region, "us");
+        assert!(
+            !router
+                .health()
+                .iter()
+                .find(|h| h.endpoint.region == "ap")
+                .unwrap()
+                .healthy
+        );
+
+        // Writes leave the primary only with write_failover
+        probe.set("http://us", None);
+        probe.set("http://eu", Some(Duration::from_millis(1)));
+        router.probe_all().await;
+        assert_eq!(regions(&router.route(RouteKind::Write)), ["us"]);
+        let failover = RegionRouter::new(config.with_write_failover(), probe).unwrap();
+        failover.probe_all().await;
+        assert_eq!(
+            regions(&failover.route(RouteKind::Write)),
+            ["eu", "ap", "us"]
+        );
+
+        // Non-transient errors are not retried elsewhere
+        let result: CoreResult<()> = failover
+            .execute(RouteKind::Write, |_| async {
+                Err(CoreError::ValidationError("bad request".to_string()))
+            })
+            .await;
+        assert!(matches!(result, Err(CoreError::ValidationError(_))));
+    }
+
+    #[test]
+    fn test_config_and_addresses() {
+        let two_primaries = RegionRouterConfig::new(vec![
+            RegionEndpoint::new("us", "http://us").primary(),
+            RegionEndpoint::new("eu", "http://eu").primary(),
+        ]);
+        assert!(two_primaries.validate().is_err());
+        assert!(RegionRouterConfig::new(Vec::new()).validate().is_err());
+
+        assert_eq!(
+            socket_address("http://db.internal").unwrap(),
+            "db.internal:80"
+        );
+        assert_eq!(
+            socket_address("https://db.internal:8443/api").unwrap(),
+            "db.internal:8443"
+        );
+        assert_eq!(socket_address("http://[::1]").unwrap(), "[::1]:80");
+    }
+}
@@ -0,0 +1,138 @@
+//! Per-collection handles for embedded use.
+//!
+//! A `CollectionHandle` binds a collection so applications working with one
+//! collection do not pass its ID to every call, and carries search options
+//! applied to each of its searches. Options left unset on the handle fall
+//! back to the collection's search defaults, then to the tenant policy. See
+//! `CollectionService::collection` and `CollectionService::collection_named`.
+//!
+//! A handle can also be bound to a task with `CollectionHandle::scope`, e.g.
+//! by middleware routing each request of a multi-tenant server to its
+//! collection; code running in the task reaches it through
+//! `CollectionHandle::current` instead of being passed it at every call.
+
+use akidb_core::{CollectionId, CoreResult, DocumentId, SearchResult, VectorDocument};
+use std::future::Future;
+use std::sync::Arc;
+
+use crate::collection_service::CollectionService;
+use crate::post_processing::PostProcessingPipeline;
+
+tokio::task_local! {
+    static CURRENT_HANDLE: CollectionHandle;
+}
+
+/// A collection bound to its service, with default search options.
+#[derive(Clone)]
+pub struct CollectionHandle {
+    service: Arc<CollectionService>,
+    collection_id: CollectionId,
+    top_k: Option<usize>,
+    min_score: Option<f32>,
+    pipeline: Option<PostProcessingPipeline>,
+}
+
+impl CollectionHandle {
+    pub(crate) fn new(service: Arc<CollectionService>, collection_id: CollectionId) -> Self {
+        Self {
+            service,
+            collection_id,
+            top_k: None,
+            min_score: None,
+            pipeline: None,
+        }
+    }
+
+    pub fn collection_id(&self) -> CollectionId {
+        self.collection_id
+    }
+
+    /// Handle bound to the current task by `scope`, if any.
+    pub fn current() -> Option<Self> {
+        CURRENT_HANDLE.try_with(Clone::clone).ok()
+    }
+
+    /// Run `f` with this handle bound to the task (see `current`). Scopes
+    /// nest: the innermost handle is current.
+    pub async fn scope<F: Future>(self, f: F) -> F::Output {
+        CURRENT_HANDLE.scope(self, f).await
+    }
+
+    /// Results returned by `search`.
+    pub fn with_top_k(mut self, top_k: usize) -> Self {
+        self.top_k = Some(top_k);
+        self
+    }
+
+    /// Score threshold of every search.
+    pub fn with_min_score(mut self, min_score: f32) -> Self {
+        self.min_score = Some(min_score);
+        self
+    }
+
+    /// Post-processing of every search, replacing the service's default
+    /// pipeline.
+    pub fn with_pipeline(mut self, pipeline: PostProcessingPipeline) -> Self {
+        self.pipeline = Some(pipeline);
+        self
+    }
+
+    pub async fn insert(&self, doc: VectorDocument) -> CoreResult<DocumentId> {
+        self.service.insert(self.collection_id, doc).await
+    }
+
+    /// Insert or replace a document; returns false if it replaced one.
+    pub async fn upsert(&self, doc: VectorDocument) -> CoreResult<bool> {
+        self.service.upsert(self.collection_id, doc).await
+    }
+
+    pub async fn get(&self, doc_id: DocumentId) -> CoreResult<Option<VectorDocument>> {
+        self.service.get(self.collection_id, doc_id).await
+    }
+
+    pub async fn delete(&self, doc_id: DocumentId) -> CoreResult<()> {
+        self.service.delete(self.collection_id, doc_id).await
+    }
+
+    /// Search with the handle's options.
+    pub async fn search(&self, query_vector: Vec<f32>) -> CoreResult<Vec<SearchResult>> {
+        self.search_top_k(query_vector, None).await
+    }
+
+    /// Search for `top_k` results (None: the handle's, then the collection's
+    /// and the tenant's default), with the handle's other options.
+    pub async fn search_top_k(
+        &self,
+        query_vector: Vec<f32>,
+        top_k: Option<usize>,
+    ) -> CoreResult<Vec<SearchResult>> {
+        let defaults = self.service.search_defaults(self.collection_id).await?;
+        let top_k = self
+            .service
+            .resolve_top_k(top_k.or(self.top_k).or(defaults.top_k))
+            .await?;
+        let min_score = self.min_score.or(defaults.min_score);
+
+        let pipeline = match (&self.pipeline, min_score) {
+            (Some(pipeline), Some(min_score)) => Some(pipeline.clone().with_threshold(min_score)),
+            (Some(pipeline), None) => Some(pipeline.clone()),
+            (None, Some(min_score)) => {
+                let pipeline = self.service.post_processing().await.unwrap_or_default();
+                Some(pipeline.with_threshold(min_score))
+            }
+            (None, None) => None,
+        };
+        match pipeline {
+            Some(pipeline) => {
+                self.service
+                    .query_with_pipeline(self.collection_id, query_vector, top_k, &pipeline)
+                    .await
+            }
+            None => {
+                self.service
+                    .query(self.collection_id, query_vector, top_k)
+                    .await
+            }
+        }
+    }
+}
@@ -0,0 +1,233 @@
+//! Hybrid dense + sparse search.
+//!
+//! A hybrid query runs the dense k-NN search and a BM25 keyword search over a
+//! metadata text field, then fuses the two ranked lists: reciprocal rank
+//! fusion (RRF) uses only positions, so the retrievers' score scales do not
+//! matter; weighted fusion min-max normalizes both lists and mixes the scores.
+//! The keyword side is given as text (encoded with the collection's analyzer)
+//! or as a sparse vector: a BM25 vector built by the client from the
+//! collection's IDF stats, or a query for the collection's sparse index.
+//! See `CollectionService::hybrid_search`.
+
+use akidb_core::{CoreError, CoreResult, DocumentId, SearchResult};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+
+use crate::sparse::{SparseVector, DEFAULT_TEXT_FIELD};
+
+/// Default RRF rank constant.
+pub const DEFAULT_RRF_K: f32 = 60.0;
+
+/// How the dense and sparse result lists are combined.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "method", rename_all = "snake_case")]
+pub enum FusionStrategy {
+    /// Reciprocal rank fusion: `sum(1 / (k + rank))` over both lists, ranks
+    /// 1-based.
+    Rrf {
+        #[serde(default = "default_rrf_k")]
+        k: f32,
+    },
+    /// `dense_weight * dense + (1 - dense_weight) * sparse`, each min-max
+    /// normalized into [0, 1]; a document missing from a list scores 0 there.
+    Weighted {
+        #[serde(default = "default_dense_weight")]
+        dense_weight: f32,
+    },
+}
+
+impl Default for FusionStrategy {
+    fn default() -> Self {
+        Self::Rrf { k: DEFAULT_RRF_K }
+    }
+}
+
+fn default_rrf_k() -> f32 {
+    DEFAULT_RRF_K
+}
+
+fn default_dense_weight() -> f32 {
+    0.5
+}
+
+fn default_text_field() -> String {
+    DEFAULT_TEXT_FIELD.to_string()
+}
+
+/// Keyword side of a hybrid query.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct HybridQuery {
+    /// Query text, encoded server-side with BM25.
+    #[serde(default)]
+    pub text: Option<String>,
+
+    /// Sparse query vector (instead of `text`): matched against the stored
+    /// sparse vectors if the collection has a sparse index, otherwise a BM25
+    /// query vector built by the client.
+    #[serde(default)]
+    pub sparse_vector: Option<SparseVector>,
+
+    /// Metadata field holding document text (default: "text").
+    #[serde(default = "default_text_field")]
+    pub text_field: String,
+
+    #[serde(default)]
+    pub fusion: FusionStrategy,
+
+    /// Results taken from each retriever before fusion (default: 4 * top_k).
+    #[serde(default)]
+    pub candidates: Option<usize>,
+}
+
+impl HybridQuery {
+    /// A query fusing BM25 over `text` with RRF.
+    pub fn text(text: impl Into<String>) -> Self {
+        Self {
+            text: Some(text.into()),
+            sparse_vector: None,
+            text_field: default_text_field(),
+            fusion: FusionStrategy::default(),
+            candidates: None,
+        }
+    }
+
+    pub fn with_fusion(mut self, fusion: FusionStrategy) -> Self {
+        self.fusion = fusion;
+        self
+    }
+
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.text.is_some() == self.sparse_vector.is_some() {
+            return Err(CoreError::ValidationError(
+                "hybrid query needs either text or sparse_vector".to_string(),
+            ));
+        }
+        match self.fusion {
+            FusionStrategy::Rrf { k } if !k.is_finite() || k < 0.0 => {
+                Err(CoreError::ValidationError(format!(
+                    "RRF k must be a non-negative number (got {})",
+                    k
+                )))
+            }
+            FusionStrategy::Weighted { dense_weight } if !(0.0..=1.0).contains(&dense_weight) => {
+                Err(CoreError::ValidationError(format!(
+                    "dense_weight must be between 0 and 1 (got {})",
+                    dense_weight
+                )))
+            }
+            _ => Ok(()),
+        }
+    }
+
+    /// Results taken from each retriever for `top_k` fused results.
+    pub fn candidates(&self, top_k: usize) -> usize {
+        self.candidates.unwrap_or(top_k * 4).max(top_k)
+    }
+}
+
+impl FusionStrategy {
+    /// Fuse two result lists, each ordered best first with higher scores
+    /// better, into the best `top_k`.
+    pub fn fuse(
+        &self,
+        dense: Vec<SearchResult>,
+        sparse: Vec<SearchResult>,
+        top_k: usize,
+    ) -> Vec<SearchResult> {
+        let (dense_scores, sparse_scores) = match *self {
+            Self::Rrf { k } => (rrf(&dense, k), rrf(&sparse, k)),
+            Self::Weighted { dense_weight } => (
+                min_max(&dense, dense_weight),
+                min_max(&sparse, 1.0 - dense_weight),
+            ),
+        };
+
+        let mut fused: HashMap<DocumentId, SearchResult> = HashMap::new();
+        for (result, score) in dense
+            .into_iter()
+            .zip(dense_scores)
+            .chain(sparse.into_iter().zip(sparse_scores))
+        {
+            fused
+                .entry(result.doc_id)
+                .and_modify(|r| r.score += score)
+                .or_insert(SearchResult { score, ..result });
+        }
+        let mut fused: Vec<_> = fused.into_values().collect();
+        fused.sort_by(|a, b| {
+            b.score
+                .total_cmp(&a.score)
+                .then_with(|| a.doc_id.as_uuid().cmp(&b.doc_id.as_uuid()))
+        });
+        fused.truncate(top_k);
+        fused
+    }
+}
+
+fn rrf(results: &[SearchResult], k: f32) -> Vec<f32> {
+    (1..=results.len())
+        .map(|rank| 1.0 / (k + rank as f32))
+        .collect()
+}
+
+fn min_max(results: &[SearchResult], weight: f32) -> Vec<f32> {
+    let (min, max) = results
+        .iter()
+        .fold((f32::INFINITY, f32::NEG_INFINITY), |(lo, hi), r| {
+            (lo.min(r.score), hi.max(r.score))
+        });
+    let range = max - min;
+    results
+        .iter()
+        .map(|r| {
+            let scaled = if range <= f32::EPSILON {
+                1.0
+            } else {
+                (r.score - min) / range
+            };
+            weight * scaled
+        })
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn ranked(ids: &[DocumentId], scores: &[f32]) -> Vec<SearchResult> {
+        ids.iter()
+            .zip(scores)
+            .map(|(id, s)| SearchResult::new(*id, *s))
+            .collect()
+    }
+
+    #[test]
+    fn test_rrf_rewards_agreement() {
+        let (a, b, c) = (DocumentId::new(), DocumentId::new(), DocumentId::new());
+        let dense = ranked(&[a, b], &[0.9, 0.8]);
+        let sparse = ranked(&[c, b], &[12.0, 3.0]);
+        let fused = FusionStrategy::default().fuse(dense, sparse, 2);
+        assert_eq!(fused[0].doc_id, b);
+        assert!((fused[0].score - (1.0 / 62.0 + 1.0 / 62.0)).abs() < 1e-6);
+        assert_eq!(fused.len(), 2);
+    }
+
+    #[test]
+    fn test_weighted_fusion() {
+        let (a, b) = (DocumentId::new(), DocumentId::new());
+        let dense = ranked(&[a, b], &[0.9, 0.5]);
+        let sparse = ranked(&[b, a], &[8.0, 2.0]);
+        let keyword_heavy = FusionStrategy::Weighted { dense_weight: 0.2 };
+        let fused = keyword_heavy.fuse(dense.clone(), sparse.clone(), 2);
+        assert_eq!(fused[0].doc_id, b);
+        assert!((fused[0].score - 0.8).abs() < 1e-6);
+
+        let fused = FusionStrategy::Weighted { dense_weight: 0.8 }.fuse(dense, sparse, 2);
+        assert_eq!(fused[0].doc_id, a);
+
+        let mut query = HybridQuery::text("fox").with_fusion(keyword_heavy);
+        query.validate().unwrap();
+        query.sparse_vector = Some(SparseVector::default());
+        assert!(query.validate().is_err());
+    }
+}
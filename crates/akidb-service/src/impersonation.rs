@@ -0,0 +1,238 @@
+//! Admin impersonation for support tooling.
+//!
+//! Support staff reproduce what a tenant sees by presenting the admin key
+//! together with the tenant's ID (the `X-Act-As-Tenant` header on REST)
+//! instead of the tenant's own credentials. Impersonation is disabled until
+//! an admin key is configured and only grants read access. Every attempt,
+//! granted or refused, is audited: the last `IMPERSONATION_HISTORY_LIMIT`
+//! records are kept for the audit API and each one is logged.
+
+use akidb_core::{CoreError, CoreResult, TenantId};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::VecDeque;
+use std::sync::Mutex;
+
+/// Audit records kept for the audit API.
+pub const IMPERSONATION_HISTORY_LIMIT: usize = 1_000;
+
+/// Minimum admin key length in bytes.
+pub const MIN_ADMIN_KEY_LEN: usize = 32;
+
+/// An impersonated request, as seen by the server.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct ImpersonationRequest {
+    /// Tenant to act as.
+    pub tenant_id: String,
+
+    /// Who is impersonating (e.g. a support engineer's email), as claimed by
+    /// the caller.
+    pub actor: Option<String>,
+
+    /// Why, e.g. a ticket reference.
+    pub reason: Option<String>,
+
+    pub method: String,
+    pub path: String,
+    pub source_ip: Option<String>,
+
+    /// Whether the request only reads data.
+    pub read_only: bool,
+}
+
+/// Audit record of an impersonation attempt.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ImpersonationRecord {
+    /// Increasing sequence number; pass as `after` to fetch newer records.
+    pub id: u64,
+
+    #[serde(flatten)]
+    pub request: ImpersonationRequest,
+
+    pub granted: bool,
+
+    /// Why the attempt was refused.
+    pub denial: Option<String>,
+
+    pub at: DateTime<Utc>,
+}
+
+#[derive(Default)]
+struct ImpersonationState {
+    key_digest: Option<[u8; 32]>,
+    tenant_id: Option<TenantId>,
+    records: VecDeque<ImpersonationRecord>,
+    next_id: u64,
+}
+
+/// Checks impersonation attempts and keeps their audit trail.
+#[derive(Default)]
+pub struct Impersonation {
+    state: Mutex<ImpersonationState>,
+}
+
+fn digest(key: &str) -> [u8; 32] {
+    Sha256::digest(key.as_bytes()).into()
+}
+
+impl Impersonation {
+    /// Enable impersonation of `tenant_id` (the tenant this server serves)
+    /// with `admin_key`, or disable it when the key is None.
+    pub fn configure(&self, tenant_id: TenantId, admin_key: Option<&str>) -> CoreResult<()> {
+        if let Some(key) = admin_key {
+            if key.len() < MIN_ADMIN_KEY_LEN {
+                return Err(CoreError::ValidationError(format!(
+                    "admin key must be at least {} bytes",
+                    MIN_ADMIN_KEY_LEN
+                )));
+            }
+        }
+        let mut state = self.state.lock().unwrap();
+        state.key_digest = admin_key.map(digest);
+        state.tenant_id = Some(tenant_id);
+        Ok(())
+    }
+
+    pub fn is_enabled(&self) -> bool {
+        self.state.lock().unwrap().key_digest.is_some()
+    }
+
+    /// Check an attempt and audit it.
+    ///
+    /// Fails with `InvalidState` when impersonation is disabled or the
+    /// request writes, `ValidationError` for a missing or wrong admin key and
+    /// `NotFound` for a tenant this server does not serve.
+    pub fn authorize(
+        &self,
+        admin_key: Option<&str>,
+        request: ImpersonationRequest,
+    ) -> CoreResult<ImpersonationRecord> {
+        let mut state = self.state.lock().unwrap();
+        let result = match (state.key_digest, admin_key) {
+            (None, _) => Err(CoreError::invalid_state(
+                "impersonation is disabled (no admin key is configured)",
+            )),
+            (Some(expected), Some(key)) if keys_match(&expected, &digest(key)) => {
+                match state.tenant_id {
+                    Some(tenant_id) if tenant_id.to_string() == request.tenant_id => {
+                        if request.read_only {
+                            Ok(())
+                        } else {
+                            Err(CoreError::invalid_state(
+                                "impersonated requests are read-only",
+                            ))
+                        }
+                    }
+                    _ => Err(CoreError::not_found("Tenant", request.tenant_id.clone())),
+                }
+            }
+            (Some(_), _) => Err(CoreError::ValidationError(
+                "missing or invalid admin key".to_string(),
+            )),
+        };
+
+        // IDs start at 1
+        state.next_id += 1;
+        let record = ImpersonationRecord {
+            id: state.next_id,
+            request,
+            granted: result.is_ok(),
+            denial: result.as_ref().err().map(ToString::to_string),
+            at: Utc::now(),
+        };
+        if state.records.len() == IMPERSONATION_HISTORY_LIMIT {
+            state.records.pop_front();
+        }
+        state.records.push_back(record.clone());
+        drop(state);
+
+        let request = &record.request;
+        match &record.denial {
+            None => tracing::warn!(
+                "Impersonation of tenant {} granted to {} ({}): {} {}",
+                request.tenant_id,
+                request.actor.as_deref().unwrap_or("unknown actor"),
+                request.reason.as_deref().unwrap_or("no reason given"),
+                request.method,
+                request.path
+            ),
+            Some(denial) => tracing::warn!(
+                "Impersonation of tenant {} refused for {}: {} {}: {}",
+                request.tenant_id,
+                request.actor.as_deref().unwrap_or("unknown actor"),
+                request.method,
+                request.path,
+                denial
+            ),
+        }
+        result.map(|_| record)
+    }
+
+    /// Audit records with an ID greater than `after` (all kept records when
+    /// None), oldest first.
+    pub fn records(&self, after: Option<u64>) -> Vec<ImpersonationRecord> {
+        let state = self.state.lock().unwrap();
+        state
+            .records
+            .iter()
+            .filter(|record| after.map_or(true, |after| record.id > after))
+            .cloned()
+            .collect()
+    }
+}
+
+/// Compares digests in constant time.
+fn keys_match(a: &[u8; 32], b: &[u8; 32]) -> bool {
+    a.iter().zip(b).fold(0u8, |diff, (x, y)| diff | (x ^ y)) == 0
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const KEY: &str = "0123456789abcdef0123456789abcdef";
+
+    #[test]
+    fn test_impersonation_is_audited() {
+        let impersonation = Impersonation::default();
+        let tenant_id = TenantId::new();
+        let request = ImpersonationRequest {
+            tenant_id: tenant_id.to_string(),
+            actor: Some("support@example.com".to_string()),
+            method: "POST".to_string(),
+            path: "/api/v1/collections/x/query".to_string(),
+            read_only: true,
+            ..Default::default()
+        };
+        // Disabled until a key is configured
+        assert!(impersonation.authorize(Some(KEY), request.clone()).is_err());
+        assert!(impersonation.configure(tenant_id, Some("short")).is_err());
+        impersonation.configure(tenant_id, Some(KEY)).unwrap();
+
+        let record = impersonation.authorize(Some(KEY), request.clone()).unwrap();
+        assert!(record.granted);
+        assert!(impersonation
+            .authorize(Some("wrong"), request.clone())
+            .is_err());
+        assert!(impersonation.authorize(None, request.clone()).is_err());
+        let write = ImpersonationRequest {
+            read_only: false,
+            ..request.clone()
+        };
+        assert!(impersonation.authorize(Some(KEY), write).is_err());
+        let other = ImpersonationRequest {
+            tenant_id: TenantId::new().to_string(),
+            ..request
+        };
+        assert!(matches!(
+            impersonation.authorize(Some(KEY), other),
+            Err(CoreError::NotFound { .. })
+        ));
+
+        let records = impersonation.records(None);
+        assert_eq!(records.len(), 6);
+        assert_eq!(records.iter().filter(|r| r.granted).count(), 1);
+        assert_eq!(impersonation.records(Some(record.id)).len(), 4);
+    }
+}
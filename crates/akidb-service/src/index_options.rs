@@ -0,0 +1,95 @@
+//! Index parameters chosen when a collection is created.
+//!
+//! The index type is fixed for the life of the collection. The HNSW
+//! parameters can be changed later (see `CollectionUpdate`), but only take
+//! effect for indexes built afterwards, and `ef_search` becomes the
+//! collection's `ef_search` search default.
+
+use akidb_core::{CoreError, CoreResult, IndexType};
+use serde::{Deserialize, Serialize};
+
+use crate::collection_update::CollectionUpdate;
+use crate::search_defaults::SearchDefaults;
+
+/// Index configuration of a new collection; unset fields use server
+/// defaults.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct IndexOptions {
+    /// Index structure (default: chosen by document limit).
+    #[serde(default)]
+    pub index_type: IndexType,
+
+    /// HNSW graph degree (default: 32).
+    #[serde(default)]
+    pub hnsw_m: Option<u32>,
+
+    /// HNSW construction EF (default: 200).
+    #[serde(default)]
+    pub hnsw_ef_construction: Option<u32>,
+
+    /// Default HNSW search breadth (default: 128).
+    #[serde(default)]
+    pub ef_search: Option<usize>,
+}
+
+impl IndexOptions {
+    pub fn validate(&self) -> CoreResult<()> {
+        let hnsw_params = self.hnsw_m.is_some()
+            || self.hnsw_ef_construction.is_some()
+            || self.ef_search.is_some();
+        if self.index_type == IndexType::Flat && hnsw_params {
+            return Err(CoreError::ValidationError(
+                "HNSW parameters do not apply to a flat index".to_string(),
+            ));
+        }
+        CollectionUpdate {
+            hnsw_m: self.hnsw_m,
+            hnsw_ef_construction: self.hnsw_ef_construction,
+            ..Default::default()
+        }
+        .validate()?;
+        self.search_defaults().validate()
+    }
+
+    /// Search defaults set on the new collection.
+    pub fn search_defaults(&self) -> SearchDefaults {
+        SearchDefaults {
+            ef_search: self.ef_search,
+            ..Default::default()
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_validate_index_options() {
+        let options: IndexOptions = serde_json::from_value(serde_json::json!({
+            "index_type": "hnsw",
+            "hnsw_m": 16,
+            "ef_search": 64,
+        }))
+        .unwrap();
+        options.validate().unwrap();
+        assert_eq!(options.search_defaults().ef_search, Some(64));
+        IndexOptions::default().validate().unwrap();
+
+        let flat_with_hnsw = IndexOptions {
+            index_type: IndexType::Flat,
+            hnsw_m: Some(16),
+            ..Default::default()
+        };
+        assert!(flat_with_hnsw.validate().is_err());
+        let zero_ef = IndexOptions {
+            ef_search: Some(0),
+            ..Default::default()
+        };
+        assert!(zero_ef.validate().is_err());
+        assert!(serde_json::from_value::<IndexOptions>(serde_json::json!({
+            "index_type": "ivf",
+        }))
+        .is_err());
+    }
+}
@@ -0,0 +1,127 @@
+//! Legal holds.
+//!
+//! A legal hold preserves a collection's documents for litigation or an
+//! investigation. While a hold is in place, the documents it covers cannot be
+//! deleted: direct deletes are rejected, scheduled expiry jobs and compliance
+//! purges skip them, and the collection itself cannot be deleted. A hold
+//! covers the whole collection or, with a metadata filter, only the documents
+//! matching it. See `CollectionService::place_legal_hold`.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, LegalHoldId};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value as JsonValue};
+
+/// Legal hold request.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct LegalHoldSpec {
+    /// Why the documents are held, e.g. a case or ticket reference.
+    pub reason: String,
+
+    /// Metadata fields held documents have, with exactly these values.
+    /// Without a filter the hold covers the whole collection.
+    #[serde(default)]
+    pub filter: Option<Map<String, JsonValue>>,
+}
+
+impl LegalHoldSpec {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.reason.trim().is_empty() {
+            return Err(CoreError::ValidationError(
+                "legal hold reason must not be empty".to_string(),
+            ));
+        }
+        if self.filter.as_ref().map_or(false, Map::is_empty) {
+            return Err(CoreError::ValidationError(
+                "legal hold filter must not be empty; omit it to hold the whole collection"
+                    .to_string(),
+            ));
+        }
+        Ok(())
+    }
+}
+
+/// A legal hold on a collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct LegalHold {
+    pub id: LegalHoldId,
+    pub collection_id: CollectionId,
+
+    #[serde(flatten)]
+    pub spec: LegalHoldSpec,
+
+    pub placed_at: DateTime<Utc>,
+}
+
+impl LegalHold {
+    pub fn new(collection_id: CollectionId, spec: LegalHoldSpec) -> CoreResult<Self> {
+        spec.validate()?;
+        Ok(Self {
+            id: LegalHoldId::new(),
+            collection_id,
+            spec,
+            placed_at: Utc::now(),
+        })
+    }
+
+    /// Returns true if the hold covers every document of the collection.
+    pub fn is_collection_wide(&self) -> bool {
+        self.spec.filter.is_none()
+    }
+
+    /// Returns true if the hold covers a document with `metadata`.
+    pub fn covers(&self, metadata: Option<&JsonValue>) -> bool {
+        let Some(filter) = &self.spec.filter else {
+            return true;
+        };
+        let fields = metadata.and_then(JsonValue::as_object);
+        filter
+            .iter()
+            .all(|(key, value)| fields.and_then(|f| f.get(key)) == Some(value))
+    }
+}
+
+/// First of `holds` covering a document with `metadata`, if any.
+pub fn held_by<'a>(holds: &'a [LegalHold], metadata: Option<&JsonValue>) -> Option<&'a LegalHold> {
+    holds.iter().find(|hold| hold.covers(metadata))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn spec(filter: Option<JsonValue>) -> LegalHoldSpec {
+        LegalHoldSpec {
+            reason: "case 42".to_string(),
+            filter: filter.map(|f| f.as_object().unwrap().clone()),
+        }
+    }
+
+    #[test]
+    fn test_covers() {
+        let collection_id = CollectionId::new();
+        let wide = LegalHold::new(collection_id, spec(None)).unwrap();
+        assert!(wide.is_collection_wide());
+        assert!(wide.covers(None));
+
+        let custodian =
+            LegalHold::new(collection_id, spec(Some(json!({"owner": "alice"})))).unwrap();
+        assert!(custodian.covers(Some(&json!({"owner": "alice", "lang": "en"}))));
+        assert!(!custodian.covers(Some(&json!({"owner": "bob"}))));
+        assert!(!custodian.covers(None));
+
+        let holds = vec![custodian];
+        assert!(held_by(&holds, Some(&json!({"owner": "alice"}))).is_some());
+        assert!(held_by(&holds, Some(&json!({"owner": "bob"}))).is_none());
+    }
+
+    #[test]
+    fn test_validate() {
+        let mut blank = spec(None);
+        blank.reason = " ".to_string();
+        assert!(blank.validate().is_err());
+        assert!(spec(Some(json!({}))).validate().is_err());
+        assert!(spec(Some(json!({"owner": "alice"}))).validate().is_ok());
+    }
+}
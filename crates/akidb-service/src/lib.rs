@@ -1,16 +1,228 @@
 //! Service layer for AkiDB 2.0.
 //! Shared business logic for gRPC and REST APIs.
 
+mod adaptive;
+mod allowlist;
+mod analysis;
+mod anomaly;
+mod backfill;
+mod batch;
+mod batch_search;
+mod bulk;
+mod capacity;
+mod cassette;
+mod chaos;
+mod changes;
+mod cloning;
+mod coalesce;
 mod collection_service;
+mod collection_update;
+mod columns;
+mod compliance;
+mod composition;
 mod config;
+mod cost;
+mod drift;
 mod embedding_manager;
+mod events;
+mod field_index;
+mod filter;
+mod geo_routing;
+mod handle;
+mod hybrid;
+mod impersonation;
+mod index_options;
+mod legal_hold;
+mod manifest;
+mod matrix;
+mod memory;
+mod named_vectors;
+mod ordering;
 pub mod metrics;
+mod parent_retrieval;
+mod patch;
+mod post_processing;
+mod progress;
+mod provisioning;
+mod query_log;
+mod range;
+mod recommend;
+mod reembed;
+mod reindex;
+mod replication;
+mod schedule;
+mod search_defaults;
+mod scoring;
+mod semcache;
+mod snapshot;
+mod sparse;
+mod sparse_index;
+mod standing;
+mod transaction;
+mod transforms;
+mod upload;
+mod usage;
+mod vector_codec;
+mod whoami;
+mod write_locks;
 
-pub use collection_service::{CollectionService, DLQRetryResult, ServiceMetrics};
+pub use adaptive::{
+    AdaptiveSearch, AdaptiveSearchConfig, AdaptiveSearchStatus, DEFAULT_EF_SEARCH,
+    HEADROOM_FRACTION,
+};
+pub use allowlist::{
+    AllowlistEvaluation, AllowlistScope, AllowlistSpec, IpAllowlist, IpNetwork, ScopeEvaluation,
+    MAX_ALLOWLIST_NETWORKS,
+};
+pub use analysis::{
+    AnalyzedToken, Analyzer, AnalyzerSettings, Language, NgramConfig, TextAnalysis, Tokenizer,
+};
+pub use anomaly::{
+    AccessMonitor, AnomalyConfig, AnomalyEvent, AnomalyKind, ANOMALY_BUFFER, ANOMALY_HISTORY_LIMIT,
+};
+pub use backfill::{
+    BackfillBatch, BackfillJob, BackfillPatch, BackfillProgress, BackfillRecord, BackfillStatus,
+    MAX_BACKFILL_BATCH,
+};
+pub use batch::{
+    check_batch_size, check_delete_batch_size, repeated_ids, BatchError, BatchInsertReport,
+    ContentIdSpec, DeleteFailure, DeleteReport, DuplicateAction, DuplicateId, EXTERNAL_ID_FIELD,
+    MAX_BATCH_ERRORS, MAX_BATCH_SIZE, MAX_DELETE_BATCH_SIZE, MAX_ID_FIELDS,
+};
+pub use batch_search::{
+    BatchQuery, BatchSearchOptions, DEFAULT_BATCH_SEARCH_CONCURRENCY, MAX_BATCH_QUERIES,
+    MAX_BATCH_SEARCH_BODY_BYTES, MAX_BATCH_SEARCH_CONCURRENCY,
+};
+pub use bulk::{BulkErrorFn, BulkRecordError, BulkWriter, BulkWriterConfig, BulkWriterReport};
+pub use capacity::{
+    estimate_memory_bytes, ForecastLimits, GrowthForecast, GrowthSample, GrowthTrend,
+    GROWTH_HISTORY_LIMIT, GROWTH_SAMPLE_INTERVAL, PER_VECTOR_OVERHEAD_BYTES,
+};
+pub use cassette::{
+    scrub_endpoint, Cassette, RecordedShipment, RecordingTransport, ReplayTransport,
+};
+pub use changes::{ChangeEvent, ChangeSubscription, CHANGE_LOG_CAPACITY, CHANGE_STREAM_BUFFER};
+pub use chaos::{ChaosStats, ChaosTransport, FaultProfile, LatencyDistribution};
+pub use cloning::{CloneOptions, CloneReport};
+pub use coalesce::{CoalescingConfig, CoalescingStats, MAX_COALESCING_WINDOW_MS};
+pub use collection_service::{
+    CollectionService, DLQRetryResult, DocumentCount, ServiceMetrics, COUNT_SAMPLE_SIZE,
+};
+pub use collection_update::{CollectionUpdate, MAX_COLLECTION_METADATA_BYTES, MAX_DESCRIPTION_LEN};
+pub use columns::{validate_column_fields, ResultColumns, ResultLayout, MAX_COLUMN_FIELDS};
+pub use compliance::{
+    export_path, CollectionTally, ComplianceAction, ComplianceJob, ComplianceReport,
+    ComplianceRequest, ComplianceStatus, ExportRecord, SubjectFilter,
+};
+pub use composition::{QueryComposition, QueryTerm, MAX_COMPOSITION_TERMS};
 pub use config::{
     Config, ConfigError, DatabaseConfig, FeaturesConfig, HnswConfig, LoggingConfig, ServerConfig,
 };
+pub use cost::{
+    estimate_import, estimate_search, ImportCostEstimate, IndexKind, SearchCostEstimate,
+    BRUTE_FORCE_MAX_DOCS, OPS_PER_COMPUTE_UNIT, READ_UNIT_BYTES, WRITE_UNIT_BYTES,
+};
+pub use drift::{
+    DriftConfig, DriftMetrics, DriftMonitor, DriftReport, VectorStats, NORM_BUCKETS,
+    NORM_BUCKET_WIDTH,
+};
 pub use embedding_manager::EmbeddingManager;
+pub use events::{
+    EventComponent, EventLevel, EventLog, ServiceEvent, EVENT_BUFFER, EVENT_HISTORY_LIMIT,
+};
+pub use field_index::{FieldIndexInfo, FieldIndexType, MAX_FIELD_INDEXES};
+pub use filter::{FieldCondition, MetadataFilter, MAX_FILTER_DEPTH, MAX_FILTER_VALUES};
+pub use geo_routing::{
+    EndpointProbe, RegionEndpoint, RegionHealth, RegionRouter, RegionRouterConfig, RouteKind,
+    TcpProbe, DEFAULT_PROBE_INTERVAL, DEFAULT_PROBE_TIMEOUT,
+};
+pub use handle::CollectionHandle;
+pub use hybrid::{FusionStrategy, HybridQuery, DEFAULT_RRF_K};
+pub use impersonation::{
+    Impersonation, ImpersonationRecord, ImpersonationRequest, IMPERSONATION_HISTORY_LIMIT,
+    MIN_ADMIN_KEY_LEN,
+};
+pub use index_options::IndexOptions;
+pub use legal_hold::{held_by, LegalHold, LegalHoldSpec};
+pub use manifest::{ExportManifest, ManifestPart, EXPORT_PART_BYTES};
+pub use matrix::VectorMatrix;
+pub use memory::{MemoryConfig, MemoryHit, MemoryQuery, MemoryStore, MemoryTurn};
+pub use named_vectors::{
+    validate_named_vectors, NamedVectorConfig, NamedVectors, MAX_NAMED_VECTORS, MAX_VECTOR_NAME_LEN,
+};
+pub use ordering::{
+    ListOrder, Listable, MetadataSort, Page, Partition, SortDirection, SortField,
+    DEFAULT_PAGE_SIZE, MAX_PAGE_SIZE, MAX_PARTITIONS,
+};
+pub use parent_retrieval::{
+    group_by_parent, GroupBy, ParentResult, ParentSearchOptions, DEFAULT_PARENT_KEY, MAX_GROUP_SIZE,
+};
+pub use patch::{apply_patch, MetadataPatch};
+pub use post_processing::{
+    Deduplicator, MetadataEnricher, MetadataSorter, PostProcessContext, PostProcessingPipeline,
+    PostProcessor, ScoreModifiers, ScoreNormalization, ScoreNormalizer, ScoreThreshold,
+    SimilarityConverter, TemperatureScaling,
+};
+pub use progress::{ImportProgress, ProgressChannels, IMPORT_PROGRESS_INTERVAL, PROGRESS_BUFFER};
+pub use provisioning::{
+    BatchCreateTenantsReport, DeleteTenantOptions, TenantCreateResult, TenantCreateStatus,
+    TenantDeleteMode, TenantFilter, DEFAULT_TENANT_RETENTION_DAYS, MAX_TENANT_BATCH,
+    MAX_TENANT_RETENTION_DAYS, TENANT_BATCH_CONCURRENCY, TENANT_PURGE_INTERVAL,
+    TENANT_SUSPENSION_REFRESH_INTERVAL,
+};
+pub use query_log::{
+    validate_prime_limit, PrimeReport, QueryLog, QueryPattern, MAX_PRIME_QUERIES,
+    QUERY_LOG_CAPACITY,
+};
+pub use range::{
+    RangeQuery, RangeSearchResult, DEFAULT_RANGE_LIMIT, MAX_RANGE_LIMIT, RANGE_INITIAL_K,
+};
+pub use recommend::{RecommendRequest, MAX_RECOMMEND_EXAMPLES};
+pub use reembed::{
+    Reembed, ReembedCheckpoint, ReembedCheckpointFn, ReembedConfig, ReembedEstimate, ReembedReport,
+};
+pub use reindex::{
+    DocumentTransform, ReindexPlan, ReindexProgress, ReindexProgressFn, ReindexReport,
+    REINDEX_PROGRESS_INTERVAL,
+};
+pub use replication::{
+    Replication, ReplicationBatch, ReplicationClientConfig, ReplicationConfig, ReplicationHealth,
+    ReplicationOp, ReplicationProtocol, ReplicationStatus, ReplicationTransport,
+    DEFAULT_LAG_SLO_SECS, MAX_REPLICATION_BODY_BYTES, MAX_REPLICATION_QUEUE,
+    REPLICATION_BATCH_SIZE, REPLICATION_TICK,
+};
+pub use schedule::{
+    is_expired, CronSchedule, JobRun, JobRunStatus, ScheduledAction, ScheduledJob,
+    ScheduledJobSpec, JOB_HISTORY_LIMIT, SCHEDULER_TICK,
+};
+pub use scoring::{
+    validate_modifiers, DecayFunction, DecayKind, FieldBoost, ScoreModifier, MAX_SCORE_MODIFIERS,
+};
+pub use search_defaults::{SearchDefaults, FILTER_OVERFETCH};
+pub use semcache::{CacheHit, SemanticCache, SemanticCacheConfig, SemanticCacheStats};
+pub use snapshot::{snapshot_path, CollectionSnapshot, RestoreReport};
+pub use sparse::{Bm25Encoder, IdfStats, SparseEncoder, SparseVector, DEFAULT_TEXT_FIELD};
+pub use sparse_index::{SparseIndex, SparseIndexConfig, DEFAULT_MAX_NNZ};
+pub use standing::{
+    StandingQuery, StandingQueryInfo, StandingQueryMatch, StandingQuerySpec,
+    MAX_STANDING_QUERY_TOP_K, SUBSCRIPTION_BUFFER,
+};
+pub use transforms::{Transform, TransformSpec};
+pub use transaction::{
+    CommitReport, Transaction, TransactionInfo, TransactionOp, DEFAULT_TRANSACTION_TIMEOUT,
+    MAX_OPEN_TRANSACTIONS, MAX_TRANSACTION_OPS, MAX_TRANSACTION_TIMEOUT,
+};
+pub use upload::{
+    ImportError, ImportRecord, ImportReport, SignedUploadUrl, Upload, UploadPart, UploadStatus,
+    DEFAULT_UPLOAD_URL_EXPIRY, MAX_IMPORT_ERRORS, MAX_UPLOAD_PARTS, MAX_UPLOAD_PART_BYTES,
+};
+pub use usage::{
+    CollectionUsage, UsageGranularity, UsageHistory, UsageMeter, UsageMonth, UsagePoint,
+    UsageReport, UsageReportRow, MAX_USAGE_HISTORY_POINTS, USAGE_CSV_HEADER,
+    USAGE_HISTORY_RETENTION_HOURS, USAGE_RETENTION_MONTHS,
+};
+pub use vector_codec::{EncodedVector, PackedVector, VectorCodec};
+pub use whoami::{CollectionPermissions, Identity, Principal, SCOPE_READ, SCOPE_WRITE};
 
 // Re-export ModelInfo from akidb_embedding
 pub use akidb_embedding::ModelInfo;
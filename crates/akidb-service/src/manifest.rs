@@ -0,0 +1,252 @@
+//! Export manifests: proof that an import received a whole export file.
+//!
+//! An export file is cut into parts at line boundaries, each at most
+//! `EXPORT_PART_BYTES` unless a single record is larger. The manifest lists
+//! every part's offset, size, record count and SHA-256, plus those of the
+//! whole file, and is included in the signed compliance report.
+//!
+//! The parts can be sent as-is as the parts of an upload. An upload created
+//! with the manifest refuses to import unless every part (or the whole file,
+//! for signed URL uploads) matches it, so a truncated or corrupted copy of an
+//! export is never restored halfway.
+
+use akidb_core::{CoreError, CoreResult};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+
+use crate::upload::{MAX_UPLOAD_PARTS, MAX_UPLOAD_PART_BYTES};
+
+/// Target size of an export part (half the upload part limit, leaving room
+/// for the last record).
+pub const EXPORT_PART_BYTES: u64 = MAX_UPLOAD_PART_BYTES as u64 / 2;
+
+/// One part of an export file.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ManifestPart {
+    /// 1-based, as the part number of an upload.
+    pub part_number: u32,
+
+    /// Byte offset of the part in the export file.
+    pub offset: u64,
+
+    pub size_bytes: u64,
+    pub records: u64,
+
+    /// SHA-256 (hex) of the part.
+    pub sha256: String,
+}
+
+/// Parts, record count and checksums of an export file.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ExportManifest {
+    pub parts: Vec<ManifestPart>,
+    pub records: u64,
+    pub size_bytes: u64,
+
+    /// SHA-256 (hex) of the whole file.
+    pub sha256: String,
+}
+
+impl ExportManifest {
+    /// Checks that the parts are numbered 1 to N and add up to the file.
+    pub fn validate(&self) -> CoreResult<()> {
+        let invalid = |message: String| Err(CoreError::ValidationError(message));
+        if self.parts.len() > MAX_UPLOAD_PARTS as usize {
+            return invalid(format!(
+                "manifest lists {} parts (max {})",
+                self.parts.len(),
+                MAX_UPLOAD_PARTS
+            ));
+        }
+        let mut offset = 0;
+        for (i, part) in self.parts.iter().enumerate() {
+            if part.part_number as usize != i + 1 {
+                return invalid(format!(
+                    "manifest part {} is numbered {}",
+                    i + 1,
+                    part.part_number
+                ));
+            }
+            if part.offset != offset {
+                return invalid(format!(
+                    "manifest part {} starts at byte {}, expected {}",
+                    part.part_number, part.offset, offset
+                ));
+            }
+            offset += part.size_bytes;
+        }
+        if offset != self.size_bytes {
+            return invalid(format!(
+                "manifest parts add up to {} bytes, not {}",
+                offset, self.size_bytes
+            ));
+        }
+        let records: u64 = self.parts.iter().map(|p| p.records).sum();
+        if records != self.records {
+            return invalid(format!(
+                "manifest parts add up to {} records, not {}",
+                records, self.records
+            ));
+        }
+        Ok(())
+    }
+
+    /// Checks one part of the file against the manifest.
+    pub fn verify_part(&self, part_number: u32, data: &[u8]) -> CoreResult<()> {
+        let part = part_number
+            .checked_sub(1)
+            .and_then(|i| self.parts.get(i as usize))
+            .ok_or_else(|| {
+                CoreError::ValidationError(format!(
+                    "part {} is not in the export manifest ({} parts)",
+                    part_number,
+                    self.parts.len()
+                ))
+            })?;
+        verify(
+            &format!("part {}", part_number),
+            part.size_bytes,
+            part.records,
+            &part.sha256,
+            data,
+        )
+    }
+
+    /// Checks the whole file against the manifest.
+    pub fn verify_file(&self, data: &[u8]) -> CoreResult<()> {
+        verify("file", self.size_bytes, self.records, &self.sha256, data)
+    }
+}
+
+fn verify(what: &str, size_bytes: u64, records: u64, sha256: &str, data: &[u8]) -> CoreResult<()> {
+    let mismatch = |detail: String| {
+        Err(CoreError::ValidationError(format!(
+            "{} does not match the export manifest: {}",
+            what, detail
+        )))
+    };
+    if data.len() as u64 != size_bytes {
+        return mismatch(format!("{} bytes, expected {}", data.len(), size_bytes));
+    }
+    let found = count_records(data);
+    if found != records {
+        return mismatch(format!("{} records, expected {}", found, records));
+    }
+    let digest = hex::encode(Sha256::digest(data));
+    if !digest.eq_ignore_ascii_case(sha256) {
+        return mismatch(format!("SHA-256 {}, expected {}", digest, sha256));
+    }
+    Ok(())
+}
+
+/// Non-blank lines, as counted by an import.
+fn count_records(data: &[u8]) -> u64 {
+    data.split(|&b| b == b'\n')
+        .filter(|line| !line.iter().all(u8::is_ascii_whitespace))
+        .count() as u64
+}
+
+/// Builds the manifest of an export file from its lines as they are written.
+pub(crate) struct ManifestBuilder {
+    part_bytes: u64,
+    parts: Vec<ManifestPart>,
+    part: Sha256,
+    part_size: u64,
+    part_records: u64,
+    file: Sha256,
+    size_bytes: u64,
+}
+
+impl ManifestBuilder {
+    pub(crate) fn new(part_bytes: u64) -> Self {
+        Self {
+            part_bytes,
+            parts: Vec::new(),
+            part: Sha256::new(),
+            part_size: 0,
+            part_records: 0,
+            file: Sha256::new(),
+            size_bytes: 0,
+        }
+    }
+
+    /// Adds one record, including its trailing newline.
+    pub(crate) fn push(&mut self, line: &[u8]) {
+        let len = line.len() as u64;
+        if self.part_size > 0 && self.part_size + len > self.part_bytes {
+            self.close_part();
+        }
+        self.part.update(line);
+        self.file.update(line);
+        self.part_size += len;
+        self.part_records += 1;
+        self.size_bytes += len;
+    }
+
+    fn close_part(&mut self) {
+        let part = std::mem::replace(&mut self.part, Sha256::new());
+        self.parts.push(ManifestPart {
+            part_number: self.parts.len() as u32 + 1,
+            offset: self.size_bytes - self.part_size,
+            size_bytes: self.part_size,
+            records: self.part_records,
+            sha256: hex::encode(part.finalize()),
+        });
+        self.part_size = 0;
+        self.part_records = 0;
+    }
+
+    pub(crate) fn finish(mut self) -> ExportManifest {
+        if self.part_size > 0 {
+            self.close_part();
+        }
+        ExportManifest {
+            records: self.parts.iter().map(|p| p.records).sum(),
+            parts: self.parts,
+            size_bytes: self.size_bytes,
+            sha256: hex::encode(self.file.finalize()),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_manifest_detects_truncation() {
+        let lines: Vec<Vec<u8>> = (0..10)
+            .map(|i| format!("{{\"id\": {}}}\n", i).into_bytes())
+            .collect();
+        let mut builder = ManifestBuilder::new(40);
+        for line in &lines {
+            builder.push(line);
+        }
+        let manifest = builder.finish();
+        manifest.validate().unwrap();
+        assert_eq!(manifest.records, 10);
+        assert!(manifest.parts.len() > 1);
+
+        let file = lines.concat();
+        manifest.verify_file(&file).unwrap();
+        for part in &manifest.parts {
+            let start = part.offset as usize;
+            let data = &file[start..start + part.size_bytes as usize];
+            manifest.verify_part(part.part_number, data).unwrap();
+        }
+
+        // A file cut at a line boundary still parses, but is refused
+        let cut = file.len() - lines[9].len();
+        assert!(manifest.verify_file(&file[..cut]).is_err());
+        let mut corrupted = file.clone();
+        corrupted[2] = b'x';
+        assert!(manifest.verify_file(&corrupted).is_err());
+        assert!(manifest
+            .verify_part(manifest.parts.len() as u32 + 1, b"")
+            .is_err());
+
+        let mut reordered = manifest.clone();
+        reordered.parts.swap(0, 1);
+        assert!(reordered.validate().is_err());
+    }
+}
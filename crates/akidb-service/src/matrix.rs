@@ -0,0 +1,116 @@
+//! Dense matrix interop for analysis tooling.
+//!
+//! `VectorMatrix` holds vectors as one contiguous row-major buffer, the
+//! layout linear algebra and dataframe libraries take without copying (e.g.
+//! `ndarray::ArrayView2::from_shape((rows, cols), matrix.as_slice())`). It is
+//! built from scrolled or exported documents, or from search results in the
+//! `columns` layout, and converts back into documents for batch inserts (see
+//! also `ResultColumns::into_documents`).
+
+use akidb_core::{CoreError, CoreResult, DocumentId, VectorDocument};
+use serde::{Deserialize, Serialize};
+
+use crate::columns::ResultColumns;
+
+/// Vectors as a dense row-major matrix, one row per vector.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct VectorMatrix {
+    rows: usize,
+    cols: usize,
+    data: Vec<f32>,
+}
+
+impl VectorMatrix {
+    /// Builds a matrix from vectors of the same dimension.
+    pub fn from_rows<'a>(vectors: impl IntoIterator<Item = &'a [f32]>) -> CoreResult<Self> {
+        let mut matrix = Self::default();
+        for vector in vectors {
+            if matrix.rows == 0 {
+                matrix.cols = vector.len();
+            } else if vector.len() != matrix.cols {
+                return Err(CoreError::ValidationError(format!(
+                    "row {} has dimension {} but expected {}",
+                    matrix.rows,
+                    vector.len(),
+                    matrix.cols
+                )));
+            }
+            matrix.data.extend_from_slice(vector);
+            matrix.rows += 1;
+        }
+        Ok(matrix)
+    }
+
+    /// Matrix of the documents' vectors, in order.
+    pub fn from_documents(docs: &[VectorDocument]) -> CoreResult<Self> {
+        Self::from_rows(docs.iter().map(|doc| doc.vector.as_slice()))
+    }
+
+    /// Matrix of the matches' vectors (search with `include_vectors`).
+    pub fn from_columns(columns: &ResultColumns) -> CoreResult<Self> {
+        let vectors = columns.vectors.as_ref().ok_or_else(|| {
+            CoreError::ValidationError("results do not include vectors".to_string())
+        })?;
+        Self::from_rows(vectors.iter().map(Vec::as_slice))
+    }
+
+    pub fn rows(&self) -> usize {
+        self.rows
+    }
+
+    pub fn cols(&self) -> usize {
+        self.cols
+    }
+
+    /// The vector in row `i`.
+    pub fn row(&self, i: usize) -> Option<&[f32]> {
+        (i < self.rows).then(|| &self.data[i * self.cols..(i + 1) * self.cols])
+    }
+
+    /// All values, row after row.
+    pub fn as_slice(&self) -> &[f32] {
+        &self.data
+    }
+
+    /// Documents for inserting the rows, with new IDs.
+    pub fn into_documents(self) -> Vec<VectorDocument> {
+        self.data
+            .chunks_exact(self.cols.max(1))
+            .take(self.rows)
+            .map(|row| VectorDocument::new(DocumentId::new(), row.to_vec()))
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_vector_matrix_round_trip() {
+        let docs = vec![
+            VectorDocument::new(DocumentId::new(), vec![1.0, 2.0, 3.0]),
+            VectorDocument::new(DocumentId::new(), vec![4.0, 5.0, 6.0]),
+        ];
+        let matrix = VectorMatrix::from_documents(&docs).unwrap();
+        assert_eq!((matrix.rows(), matrix.cols()), (2, 3));
+        assert_eq!(matrix.row(1), Some(&[4.0, 5.0, 6.0][..]));
+        assert_eq!(matrix.row(2), None);
+        assert_eq!(matrix.as_slice(), [1.0, 2.0, 3.0, 4.0, 5.0, 6.0]);
+
+        let rows: Vec<_> = matrix
+            .into_documents()
+            .into_iter()
+            .map(|d| d.vector)
+            .collect();
+        assert_eq!(rows, [docs[0].vector.clone(), docs[1].vector.clone()]);
+
+        let ragged = [vec![1.0, 2.0], vec![3.0]];
+        assert!(VectorMatrix::from_rows(ragged.iter().map(Vec::as_slice)).is_err());
+        assert!(VectorMatrix::from_columns(&ResultColumns::default()).is_err());
+        assert!(VectorMatrix::from_documents(&[])
+            .unwrap()
+            .into_documents()
+            .is_empty());
+    }
+}
@@ -0,0 +1,401 @@
+//! Conversation memory store built on collections.
+//!
+//! Stores chat turns as vectors with session/user metadata and retrieves
+//! relevant memories by blending vector similarity with recency. Turns older
+//! than the configured TTL are ignored on recall and removed by `expire()`.
+//!
+//! Embeddings are supplied by the caller (e.g., from `EmbeddingManager`).
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DistanceMetric, DocumentId, VectorDocument};
+use chrono::{DateTime, Duration, Utc};
+use serde_json::{json, Value as JsonValue};
+use std::sync::Arc;
+
+use crate::collection_service::CollectionService;
+
+/// Metadata marker identifying documents written by the memory store.
+const MEMORY_KIND: &str = "memory";
+
+/// Configuration for a [`MemoryStore`].
+#[derive(Debug, Clone)]
+pub struct MemoryConfig {
+    /// Turns older than this are expired (None = keep forever).
+    pub ttl: Option<Duration>,
+
+    /// Weight of recency in the final score (0.0 = similarity only, 1.0 = recency only).
+    pub recency_weight: f32,
+
+    /// Age at which the recency component decays to 0.5.
+    pub recency_half_life: Duration,
+
+    /// Candidates fetched per requested result before session/user filtering.
+    pub candidate_multiplier: usize,
+}
+
+impl Default for MemoryConfig {
+    fn default() -> Self {
+        Self {
+            ttl: Some(Duration::days(30)),
+            recency_weight: 0.3,
+            recency_half_life: Duration::hours(24),
+            candidate_multiplier: 5,
+        }
+    }
+}
+
+/// A chat turn to store.
+#[derive(Debug, Clone)]
+pub struct MemoryTurn {
+    /// Conversation/session identifier.
+    pub session_id: String,
+    /// Optional end-user identifier (for cross-session recall).
+    pub user_id: Option<String>,
+    /// Speaker role (e.g., "user", "assistant").
+    pub role: String,
+    /// Turn text.
+    pub content: String,
+    /// Embedding of `content`.
+    pub embedding: Vec<f32>,
+}
+
+/// Filters for memory recall.
+#[derive(Debug, Clone, Default)]
+pub struct MemoryQuery {
+    /// Only recall turns from this session.
+    pub session_id: Option<String>,
+    /// Only recall turns from this user.
+    pub user_id: Option<String>,
+    /// Maximum number of memories to return.
+    pub top_k: usize,
+}
+
+/// A recalled memory.
+#[derive(Debug, Clone)]
+pub struct MemoryHit {
+    pub doc_id: DocumentId,
+    pub session_id: String,
+    pub user_id: Option<String>,
+    pub role: String,
+    pub content: String,
+    pub created_at: DateTime<Utc>,
+    /// Vector similarity mapped into [0, 1].
+    pub similarity: f32,
+    /// Final score blending similarity and recency (higher is better).
+    pub score: f32,
+}
+
+/// Conversation memory backed by a single collection.
+pub struct MemoryStore {
+    service: Arc<CollectionService>,
+    collection_id: CollectionId,
+    config: MemoryConfig,
+}
+
+impl MemoryStore {
+    /// Creates a memory store over an existing collection.
+    pub fn new(
+        service: Arc<CollectionService>,
+        collection_id: CollectionId,
+        config: MemoryConfig,
+    ) -> Self {
+        Self {
+            service,
+            collection_id,
+            config,
+        }
+    }
+
+    /// Stores a chat turn and returns its document ID.
+    pub async fn remember(&self, turn: MemoryTurn) -> CoreResult<DocumentId> {
+        if turn.session_id.is_empty() {
+            return Err(CoreError::ValidationError(
+                "session_id cannot be empty".to_string(),
+            ));
+        }
+
+        let now = Utc::now();
+        let metadata = json!({
+            "kind": MEMORY_KIND,
+            "session_id": turn.session_id,
+            "user_id": turn.user_id,
+            "role": turn.role,
+            "content": turn.content,
+            "created_at": now.to_rfc3339(),
+        });
+
+        let doc = VectorDocument::new(DocumentId::new(), turn.embedding)
+            .with_metadata(metadata)
+            .with_timestamp(now);
+        self.service.insert(self.collection_id, doc).await
+    }
+
+    /// Recalls the most relevant memories for `query_embedding`.
+    ///
+    /// Results are ordered by the blended similarity/recency score. Expired
+    /// turns are skipped even if `expire()` has not run yet.
+    pub async fn recall(
+        &self,
+        query_embedding: Vec<f32>,
+        query: &MemoryQuery,
+    ) -> CoreResult<Vec<MemoryHit>> {
+        if query.top_k == 0 {
+            return Err(CoreError::ValidationError(
+                "top_k must be greater than 0".to_string(),
+            ));
+        }
+
+        let metric = self
+            .service
+            .get_collection(self.collection_id)
+            .await?
+            .metric;
+        let candidates = query
+            .top_k
+            .saturating_mul(self.config.candidate_multiplier.max(1))
+            .min(10_000);
+        let results = self
+            .service
+            .search_index(self.collection_id, query_embedding, candidates)
+            .await?;
+
+        let now = Utc::now();
+        let mut hits: Vec<MemoryHit> = results
+            .into_iter()
+            .filter_map(|r| {
+                let hit = parse_hit(
+                    r.doc_id,
+                    r.metadata.as_ref()?,
+                    r.score,
+                    metric,
+                    now,
+                    &self.config,
+                )?;
+                let session_ok = query
+                    .session_id
+                    .as_ref()
+                    .map_or(true, |s| *s == hit.session_id);
+                let user_ok = query
+                    .user_id
+                    .as_ref()
+                    .map_or(true, |u| hit.user_id.as_ref() == Some(u));
+                (session_ok && user_ok && !self.is_expired(hit.created_at, now)).then_some(hit)
+            })
+            .collect();
+
+        hits.sort_by(|a, b| {
+            b.score
+                .partial_cmp(&a.score)
+                .unwrap_or(std::cmp::Ordering::Equal)
+        });
+        hits.truncate(query.top_k);
+        Ok(hits)
+    }
+
+    /// Deletes every stored turn of a session. Returns the number removed.
+    pub async fn forget_session(&self, session_id: &str) -> CoreResult<usize> {
+        self.delete_where(|metadata, _| {
+            metadata.get("session_id").and_then(JsonValue::as_str) == Some(session_id)
+        })
+        .await
+    }
+
+    /// Deletes turns older than the TTL. Returns the number removed.
+    pub async fn expire(&self) -> CoreResult<usize> {
+        let now = Utc::now();
+        self.delete_where(|_, created_at| self.is_expired(created_at, now))
+            .await
+    }
+
+    fn is_expired(&self, created_at: DateTime<Utc>, now: DateTime<Utc>) -> bool {
+        self.config
+            .ttl
+            .map_or(false, |ttl| now.signed_duration_since(created_at) > ttl)
+    }
+
+    async fn delete_where<F>(&self, predicate: F) -> CoreResult<usize>
+    where
+        F: Fn(&JsonValue, DateTime<Utc>) -> bool,
+    {
+        let docs = self.service.list_documents(self.collection_id).await?;
+        let mut removed = 0;
+        for doc in docs {
+            let Some(metadata) = doc.metadata.as_ref() else {
+                continue;
+            };
+            if metadata.get("kind").and_then(JsonValue::as_str) != Some(MEMORY_KIND) {
+                continue;
+            }
+            let created_at = created_at_of(metadata).unwrap_or(doc.inserted_at);
+            if predicate(metadata, created_at) {
+                self.service.delete(self.collection_id, doc.doc_id).await?;
+                removed += 1;
+            }
+        }
+        Ok(removed)
+    }
+}
+
+/// Maps a raw search score into [0, 1] (higher is more similar).
+pub fn similarity_from_score(metric: DistanceMetric, score: f32) -> f32 {
+    match metric {
+        DistanceMetric::Cosine => score.clamp(0.0, 1.0),
+        DistanceMetric::Dot => 1.0 / (1.0 + (-score).exp()),
+        DistanceMetric::L2 => 1.0 / (1.0 + score.max(0.0)),
+    }
+}
+
+/// Exponential recency decay: 1.0 for a brand-new turn, 0.5 at `half_life`.
+pub fn recency_decay(age: Duration, half_life: Duration) -> f32 {
+    let half_life_secs = half_life.num_milliseconds().max(1) as f64 / 1000.0;
+    let age_secs = age.num_milliseconds().max(0) as f64 / 1000.0;
+    0.5_f64.powf(age_secs / half_life_secs) as f32
+}
+
+/// The `created_at` timestamp stored in an entry's metadata.
+pub(crate) fn created_at_of(metadata: &JsonValue) -> Option<DateTime<Utc>> {
+    metadata
+        .get("created_at")
+        .and_then(JsonValue::as_str)
+        .and_then(|s| DateTime::parse_from_rfc3339(s).ok())
+        .map(|dt| dt.with_timezone(&Utc))
+}
+
+fn parse_hit(
+    doc_id: DocumentId,
+    metadata: &JsonValue,
+    score: f32,
+    metric: DistanceMetric,
+    now: DateTime<Utc>,
+    config: &MemoryConfig,
+) -> Option<MemoryHit> {
+    if metadata.get("kind").and_then(JsonValue::as_str) != Some(MEMORY_KIND) {
+        return None;
+    }
+
+    let created_at = created_at_of(metadata).unwrap_or(now);
+    let similarity = similarity_from_score(metric, score);
+    let recency = recency_decay(
+        now.signed_duration_since(created_at),
+        config.recency_half_life,
+    );
+    let weight = config.recency_weight.clamp(0.0, 1.0);
+
+    Some(MemoryHit {
+        doc_id,
+        session_id: metadata.get("session_id")?.as_str()?.to_string(),
+        user_id: metadata
+            .get("user_id")
+            .and_then(JsonValue::as_str)
+            .map(str::to_string),
+        role: metadata
+            .get("role")
+            .and_then(JsonValue::as_str)
+            .unwrap_or_default()
+            .to_string(),
+        content: metadata
+            .get("content")
+            .and_then(JsonValue::as_str)
+            .unwrap_or_default()
+            .to_string(),
+        created_at,
+        similarity,
+        score: (1.0 - weight) * similarity + weight * recency,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn turn(session: &str, content: &str, embedding: Vec<f32>) -> MemoryTurn {
+        MemoryTurn {
+            session_id: session.to_string(),
+            user_id: Some("user-1".to_string()),
+            role: "user".to_string(),
+            content: content.to_string(),
+            embedding,
+        }
+    }
+
+    async fn setup(config: MemoryConfig) -> MemoryStore {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("memory".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        MemoryStore::new(service, collection_id, config)
+    }
+
+    fn axis(i: usize) -> Vec<f32> {
+        let mut v = vec![0.01; 16];
+        v[i] = 1.0;
+        v
+    }
+
+    #[test]
+    fn test_recency_decay() {
+        let half_life = Duration::hours(1);
+        assert!((recency_decay(Duration::zero(), half_life) - 1.0).abs() < 1e-6);
+        assert!((recency_decay(Duration::hours(1), half_life) - 0.5).abs() < 1e-6);
+        assert!((recency_decay(Duration::hours(2), half_life) - 0.25).abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_similarity_from_score() {
+        assert_eq!(similarity_from_score(DistanceMetric::Cosine, -0.5), 0.0);
+        assert_eq!(similarity_from_score(DistanceMetric::L2, 0.0), 1.0);
+        assert!((similarity_from_score(DistanceMetric::Dot, 0.0) - 0.5).abs() < 1e-6);
+    }
+
+    #[tokio::test]
+    async fn test_remember_and_recall_filters_by_session() {
+        let store = setup(MemoryConfig::default()).await;
+        store
+            .remember(turn("s1", "likes tea", axis(0)))
+            .await
+            .unwrap();
+        store
+            .remember(turn("s2", "likes coffee", axis(0)))
+            .await
+            .unwrap();
+        store
+            .remember(turn("s1", "lives in Oslo", axis(1)))
+            .await
+            .unwrap();
+
+        let query = MemoryQuery {
+            session_id: Some("s1".to_string()),
+            top_k: 1,
+            ..Default::default()
+        };
+        let hits = store.recall(axis(0), &query).await.unwrap();
+
+        assert_eq!(hits.len(), 1);
+        assert_eq!(hits[0].content, "likes tea");
+        assert_eq!(hits[0].role, "user");
+    }
+
+    #[tokio::test]
+    async fn test_expire_and_forget_session() {
+        let store = setup(MemoryConfig {
+            ttl: Some(Duration::zero()),
+            ..Default::default()
+        })
+        .await;
+        store.remember(turn("s1", "old", axis(0))).await.unwrap();
+        tokio::time::sleep(std::time::Duration::from_millis(5)).await;
+
+        let query = MemoryQuery {
+            top_k: 5,
+            ..Default::default()
+        };
+        assert!(store.recall(axis(0), &query).await.unwrap().is_empty());
+        assert_eq!(store.expire().await.unwrap(), 1);
+
+        let store = setup(MemoryConfig::default()).await;
+        store.remember(turn("s1", "a", axis(0))).await.unwrap();
+        store.remember(turn("s2", "b", axis(1))).await.unwrap();
+        assert_eq!(store.forget_session("s1").await.unwrap(), 1);
+        assert_eq!(store.recall(axis(0), &query).await.unwrap().len(), 1);
+    }
+}
@@ -118,6 +118,17 @@ lazy_static! {
         &["worker_type", "status"]
     )
     .unwrap();
+
+    // ========== Upstream Metrics (1 metric) ==========
+
+    /// Responses from services we call that mark the endpoint deprecated,
+    /// by endpoint and whether a sunset date was announced
+    pub static ref UPSTREAM_DEPRECATED_RESPONSES_TOTAL: CounterVec = register_counter_vec!(
+        "akidb_upstream_deprecated_responses_total",
+        "Upstream responses carrying Deprecation or Sunset headers",
+        &["endpoint", "sunset"]
+    )
+    .unwrap();
 }
 
 /// Initialize all metrics by accessing them once
@@ -148,6 +159,7 @@ pub fn init_metrics() {
     let _ = &*S3_OPERATION_DURATION_SECONDS;
     let _ = &*MEMORY_USAGE_BYTES;
     let _ = &*BACKGROUND_WORKER_RUNS_TOTAL;
+    let _ = &*UPSTREAM_DEPRECATED_RESPONSES_TOTAL;
 }
 
 /// Exports all metrics in Prometheus text format
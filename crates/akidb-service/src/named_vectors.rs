@@ -0,0 +1,325 @@
+//! Named vectors.
+//!
+//! Besides its primary vector, a document may carry vectors in named spaces
+//! declared on its collection, e.g. "title" and "body" embeddings of
+//! different dimensions or from different models. Each space has its own
+//! dimension and metric, and a search `using` a space ranks documents by
+//! their vector in that space (an exact scan; documents without one are
+//! skipped). The vectors are stored with their documents and the spaces
+//! with the collection, so they are re-indexed when the collection is
+//! loaded. See `CollectionService::set_named_vector_configs` and
+//! `CollectionService::named_vector_search`.
+
+use akidb_core::{CollectionDescriptor, CoreError, CoreResult, DistanceMetric, DocumentId};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap, HashSet};
+
+/// Maximum named vector spaces per collection.
+pub const MAX_NAMED_VECTORS: usize = 16;
+
+/// Maximum length of a vector space name.
+pub const MAX_VECTOR_NAME_LEN: usize = 64;
+
+fn default_metric() -> DistanceMetric {
+    DistanceMetric::Cosine
+}
+
+/// Settings of a named vector space.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct NamedVectorConfig {
+    pub dimension: u32,
+
+    /// Metric used when searching this space (default: cosine).
+    #[serde(default = "default_metric")]
+    pub metric: DistanceMetric,
+}
+
+impl NamedVectorConfig {
+    pub fn new(dimension: u32, metric: DistanceMetric) -> Self {
+        Self { dimension, metric }
+    }
+}
+
+/// Validate a set of named vector spaces.
+pub fn validate_named_vectors(configs: &BTreeMap<String, NamedVectorConfig>) -> CoreResult<()> {
+    if configs.len() > MAX_NAMED_VECTORS {
+        return Err(CoreError::ValidationError(format!(
+            "a collection can have at most {} named vectors",
+            MAX_NAMED_VECTORS
+        )));
+    }
+    for (name, config) in configs {
+        let valid_name = !name.is_empty()
+            && name.len() <= MAX_VECTOR_NAME_LEN
+            && name
+                .chars()
+                .all(|c| c.is_ascii_alphanumeric() || c == '_' || c == '-');
+        if !valid_name {
+            return Err(CoreError::ValidationError(format!(
+                "invalid vector name '{}': use 1-{} letters, digits, '_' or '-'",
+                name, MAX_VECTOR_NAME_LEN
+            )));
+        }
+        let dimensions = CollectionDescriptor::MIN_DIMENSION..=CollectionDescriptor::MAX_DIMENSION;
+        if !dimensions.contains(&config.dimension) {
+            return Err(CoreError::ValidationError(format!(
+                "vector '{}': dimension must be between {} and {} (got {})",
+                name,
+                CollectionDescriptor::MIN_DIMENSION,
+                CollectionDescriptor::MAX_DIMENSION,
+                config.dimension
+            )));
+        }
+    }
+    Ok(())
+}
+
+#[derive(Debug, Clone)]
+struct VectorSpace {
+    config: NamedVectorConfig,
+    vectors: HashMap<DocumentId, Vec<f32>>,
+}
+
+/// The named vector spaces of a collection and their stored vectors.
+#[derive(Debug, Clone, Default)]
+pub struct NamedVectors {
+    spaces: BTreeMap<String, VectorSpace>,
+}
+
+impl NamedVectors {
+    pub fn new(configs: BTreeMap<String, NamedVectorConfig>) -> CoreResult<Self> {
+        let mut named = Self::default();
+        named.set_configs(configs)?;
+        Ok(named)
+    }
+
+    pub fn configs(&self) -> BTreeMap<String, NamedVectorConfig> {
+        self.spaces
+            .iter()
+            .map(|(name, space)| (name.clone(), space.config))
+            .collect()
+    }
+
+    pub fn config(&self, name: &str) -> Option<NamedVectorConfig> {
+        self.spaces.get(name).map(|space| space.config)
+    }
+
+    /// Check that replacing the declared spaces with `configs` is allowed:
+    /// they are valid and no space holding vectors changes its dimension or
+    /// metric.
+    pub fn check_configs(&self, configs: &BTreeMap<String, NamedVectorConfig>) -> CoreResult<()> {
+        validate_named_vectors(configs)?;
+        for (name, config) in configs {
+            if let Some(space) = self.spaces.get(name) {
+                if space.config != *config && !space.vectors.is_empty() {
+                    return Err(CoreError::invalid_state(format!(
+                        "vector '{}' holds {} vectors; drop it before changing its dimension or metric",
+                        name,
+                        space.vectors.len()
+                    )));
+                }
+            }
+        }
+        Ok(())
+    }
+
+    /// Replace the declared spaces. Spaces left out are dropped with their
+    /// vectors; changing the dimension or metric of a space that holds
+    /// vectors fails.
+    pub fn set_configs(&mut self, configs: BTreeMap<String, NamedVectorConfig>) -> CoreResult<()> {
+        self.check_configs(&configs)?;
+        let mut old = std::mem::take(&mut self.spaces);
+        self.spaces = configs
+            .into_iter()
+            .map(|(name, config)| {
+                let vectors = old
+                    .remove(&name)
+                    .map(|space| space.vectors)
+                    .unwrap_or_default();
+                (name, VectorSpace { config, vectors })
+            })
+            .collect();
+        Ok(())
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.spaces.is_empty()
+    }
+
+    /// Reject vectors for undeclared spaces or of the wrong dimension.
+    pub fn check(&self, vectors: &HashMap<String, Vec<f32>>) -> CoreResult<()> {
+        for (name, vector) in vectors {
+            let space = self.spaces.get(name).ok_or_else(|| {
+                CoreError::ValidationError(format!("collection has no vector named '{}'", name))
+            })?;
+            if vector.len() != space.config.dimension as usize {
+                return Err(CoreError::ValidationError(format!(
+                    "vector '{}' must have dimension {} (got {})",
+                    name,
+                    space.config.dimension,
+                    vector.len()
+                )));
+            }
+            if vector.iter().any(|v| !v.is_finite()) {
+                return Err(CoreError::ValidationError(format!(
+                    "vector '{}' contains a non-finite value",
+                    name
+                )));
+            }
+        }
+        Ok(())
+    }
+
+    /// Store a document's named vectors, replacing all previous ones.
+    pub fn insert(
+        &mut self,
+        doc_id: DocumentId,
+        vectors: HashMap<String, Vec<f32>>,
+    ) -> CoreResult<()> {
+        self.check(&vectors)?;
+        self.remove(doc_id);
+        for (name, vector) in vectors {
+            if let Some(space) = self.spaces.get_mut(&name) {
+                space.vectors.insert(doc_id, vector);
+            }
+        }
+        Ok(())
+    }
+
+    /// A document's named vectors (empty if it has none).
+    pub fn get(&self, doc_id: DocumentId) -> HashMap<String, Vec<f32>> {
+        self.spaces
+            .iter()
+            .filter_map(|(name, space)| Some((name.clone(), space.vectors.get(&doc_id)?.clone())))
+            .collect()
+    }
+
+    /// IDs of the documents holding a vector in a space not in `keep`.
+    pub fn doc_ids_outside(
+        &self,
+        keep: &BTreeMap<String, NamedVectorConfig>,
+    ) -> HashSet<DocumentId> {
+        self.spaces
+            .iter()
+            .filter(|(name, _)| !keep.contains_key(*name))
+            .flat_map(|(_, space)| space.vectors.keys().copied())
+            .collect()
+    }
+
+    pub fn remove(&mut self, doc_id: DocumentId) {
+        for space in self.spaces.values_mut() {
+            space.vectors.remove(&doc_id);
+        }
+    }
+
+    /// The `top_k` documents nearest to `query` in space `name`, best first.
+    pub fn search(
+        &self,
+        name: &str,
+        query: &[f32],
+        top_k: usize,
+    ) -> CoreResult<Vec<(DocumentId, f32)>> {
+        let space = self
+            .spaces
+            .get(name)
+            .ok_or_else(|| CoreError::not_found("Named vector", name.to_string()))?;
+        if query.len() != space.config.dimension as usize {
+            return Err(CoreError::ValidationError(format!(
+                "query vector for '{}' must have dimension {} (got {})",
+                name,
+                space.config.dimension,
+                query.len()
+            )));
+        }
+        let metric = space.config.metric;
+        let mut ranked: Vec<(DocumentId, f32)> = space
+            .vectors
+            .iter()
+            .map(|(doc_id, vector)| (*doc_id, metric.compute(query, vector)))
+            .collect();
+        // L2 scores are distances: lower is better
+        ranked.sort_by(|a, b| {
+            let order = match metric {
+                DistanceMetric::L2 => a.1.total_cmp(&b.1),
+                DistanceMetric::Cosine | DistanceMetric::Dot => b.1.total_cmp(&a.1),
+            };
+            order.then_with(|| a.0.as_uuid().cmp(&b.0.as_uuid()))
+        });
+        ranked.truncate(top_k);
+        Ok(ranked)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn spaces() -> BTreeMap<String, NamedVectorConfig> {
+        BTreeMap::from([
+            (
+                "title".to_string(),
+                NamedVectorConfig::new(16, DistanceMetric::Cosine),
+            ),
+            (
+                "body".to_string(),
+                NamedVectorConfig::new(32, DistanceMetric::L2),
+            ),
+        ])
+    }
+
+    fn unit(dimension: usize, hot: usize) -> Vec<f32> {
+        let mut vector = vec![0.0; dimension];
+        vector[hot] = 1.0;
+        vector
+    }
+
+    #[test]
+    fn test_named_vector_search() {
+        let mut named = NamedVectors::new(spaces()).unwrap();
+        let (a, b) = (DocumentId::new(), DocumentId::new());
+        named
+            .insert(
+                a,
+                HashMap::from([
+                    ("title".to_string(), unit(16, 0)),
+                    ("body".to_string(), unit(32, 0)),
+                ]),
+            )
+            .unwrap();
+        named
+            .insert(b, HashMap::from([("body".to_string(), unit(32, 1))]))
+            .unwrap();
+
+        // Only documents with a vector in the space are ranked
+        let title = named.search("title", &unit(16, 0), 10).unwrap();
+        assert_eq!(title.len(), 1);
+        assert_eq!(title[0].0, a);
+        // L2 ranks the smallest distance first
+        let body = named.search("body", &unit(32, 1), 10).unwrap();
+        assert_eq!(body[0], (b, 0.0));
+        assert_eq!(body[1].0, a);
+
+        let wrong_dimension = HashMap::from([("title".to_string(), unit(32, 0))]);
+        assert!(named.insert(b, wrong_dimension).is_err());
+        let unknown = HashMap::from([("summary".to_string(), unit(16, 0))]);
+        assert!(named.insert(b, unknown).is_err());
+        assert!(named.search("summary", &unit(16, 0), 10).is_err());
+
+        // A space holding vectors keeps its settings
+        let mut changed = spaces();
+        changed.insert(
+            "body".to_string(),
+            NamedVectorConfig::new(64, DistanceMetric::L2),
+        );
+        assert!(named.set_configs(changed).is_err());
+        named.remove(a);
+        assert!(named.get(a).is_empty());
+        assert_eq!(named.get(b).len(), 1);
+
+        let invalid = BTreeMap::from([(
+            "no spaces".to_string(),
+            NamedVectorConfig::new(16, DistanceMetric::Cosine),
+        )]);
+        assert!(validate_named_vectors(&invalid).is_err());
+    }
+}
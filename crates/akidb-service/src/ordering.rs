@@ -0,0 +1,499 @@
+//! Deterministic listing order and keyset pagination.
+//!
+//! Listings are sorted by a field and then by ID, so the order is total and
+//! the same on every call. A page's cursor records the sort key and ID of its
+//! last item; the next page starts strictly after them instead of at an
+//! offset. Items inserted or deleted while paging therefore never shift
+//! later pages: every item present for the whole walk is returned exactly
+//! once, and items added meanwhile appear only if they sort after the cursor.
+//! See `CollectionService::list_collections_page`,
+//! `CollectionService::scroll_documents` and `CollectionService::list_tenants`.
+//!
+//! Documents can also be sorted by a metadata field (`MetadataSort`), see
+//! `CollectionService::find_documents`.
+
+use akidb_core::{CollectionDescriptor, CoreError, CoreResult, TenantDescriptor, VectorDocument};
+use chrono::{DateTime, SecondsFormat, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+use std::cmp::{Ordering, Reverse};
+
+/// Default items per page.
+pub const DEFAULT_PAGE_SIZE: usize = 100;
+
+/// Maximum items per page.
+pub const MAX_PAGE_SIZE: usize = 1_000;
+
+/// Field a listing is sorted by (ties are broken by ID).
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SortField {
+    #[default]
+    Id,
+    CreatedAt,
+    /// Collection or tenant name, or a document's external ID.
+    Name,
+}
+
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SortDirection {
+    #[default]
+    Asc,
+    Desc,
+}
+
+/// Order of a listing.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ListOrder {
+    #[serde(default)]
+    pub sort: SortField,
+    #[serde(default)]
+    pub direction: SortDirection,
+}
+
+/// Something that can be listed in a `ListOrder`.
+pub trait Listable {
+    /// Key for `field`; keys compare as strings.
+    fn sort_key(&self, field: SortField) -> String;
+
+    /// Unique tie-breaker.
+    fn sort_id(&self) -> String;
+}
+
+fn timestamp_key(t: DateTime<Utc>) -> String {
+    // Fixed width, so string order is time order
+    t.to_rfc3339_opts(SecondsFormat::Nanos, true)
+}
+
+impl Listable for CollectionDescriptor {
+    fn sort_key(&self, field: SortField) -> String {
+        match field {
+            SortField::Id => self.sort_id(),
+            SortField::CreatedAt => timestamp_key(self.created_at),
+            SortField::Name => self.name.clone(),
+        }
+    }
+
+    fn sort_id(&self) -> String {
+        self.collection_id.to_string()
+    }
+}
+
+impl Listable for TenantDescriptor {
+    fn sort_key(&self, field: SortField) -> String {
+        match field {
+            SortField::Id => self.sort_id(),
+            SortField::CreatedAt => timestamp_key(self.created_at),
+            SortField::Name => self.name.clone(),
+        }
+    }
+
+    fn sort_id(&self) -> String {
+        self.tenant_id.to_string()
+    }
+}
+
+impl Listable for VectorDocument {
+    fn sort_key(&self, field: SortField) -> String {
+        match field {
+            SortField::Id => self.sort_id(),
+            SortField::CreatedAt => timestamp_key(self.inserted_at),
+            SortField::Name => self.external_id.clone().unwrap_or_default(),
+        }
+    }
+
+    fn sort_id(&self) -> String {
+        self.doc_id.to_string()
+    }
+}
+
+/// Position after the last item of a page.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+struct Cursor {
+    order: ListOrder,
+    key: String,
+    id: String,
+}
+
+impl Cursor {
+    fn encode(&self) -> String {
+        hex::encode(serde_json::to_vec(self).unwrap_or_default())
+    }
+
+    fn decode(cursor: &str, order: ListOrder) -> CoreResult<Self> {
+        let invalid = || CoreError::ValidationError(format!("invalid cursor '{}'", cursor));
+        let bytes = hex::decode(cursor).map_err(|_| invalid())?;
+        let cursor: Self = serde_json::from_slice(&bytes).map_err(|_| invalid())?;
+        if cursor.order != order {
+            return Err(CoreError::ValidationError(
+                "cursor was issued for a different sort order".to_string(),
+            ));
+        }
+        Ok(cursor)
+    }
+}
+
+/// One page of a listing.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct Page<T> {
+    pub items: Vec<T>,
+
+    /// Cursor for the next page; absent on the last page.
+    pub next_cursor: Option<String>,
+}
+
+impl ListOrder {
+    /// Sort `items` in this order (each item's key is computed once).
+    pub fn sort<T: Listable>(&self, items: &mut [T]) {
+        match self.direction {
+            SortDirection::Asc => {
+                items.sort_by_cached_key(|item| (item.sort_key(self.sort), item.sort_id()))
+            }
+            SortDirection::Desc => {
+                items.sort_by_cached_key(|item| Reverse((item.sort_key(self.sort), item.sort_id())))
+            }
+        }
+    }
+
+    /// Compare (sort key, ID) pairs in this order.
+    fn compare_keys(&self, a: (&str, &str), b: (&str, &str)) -> Ordering {
+        match self.direction {
+            SortDirection::Asc => a.cmp(&b),
+            SortDirection::Desc => b.cmp(&a),
+        }
+    }
+
+    /// Whether this is the default order (ascending IDs), in which indexes
+    /// can be paged through directly (see `VectorIndex::list_after`).
+    pub fn is_id_order(&self) -> bool {
+        *self == Self::default()
+    }
+
+    /// ID of the last item before `cursor`, which must have been issued for
+    /// this order.
+    pub fn cursor_id(&self, cursor: &str) -> CoreResult<String> {
+        Ok(Cursor::decode(cursor, *self)?.id)
+    }
+
+    /// The page of up to `limit` items following `cursor` (the first page
+    /// without one).
+    ///
+    /// Each item's sort key is computed once, and only the items on the page
+    /// are sorted, so a page costs O(n + limit log limit).
+    pub fn paginate<T: Listable>(
+        &self,
+        items: Vec<T>,
+        cursor: Option<&str>,
+        limit: usize,
+    ) -> CoreResult<Page<T>> {
+        check_page_limit(limit)?;
+        let mut keyed: Vec<(String, String, T)> = items
+            .into_iter()
+            .map(|item| (item.sort_key(self.sort), item.sort_id(), item))
+            .collect();
+        if let Some(cursor) = cursor {
+            let cursor = Cursor::decode(cursor, *self)?;
+            let after = (cursor.key.as_str(), cursor.id.as_str());
+            keyed.retain(|(key, id, _)| self.compare_keys((key, id), after) == Ordering::Greater);
+        }
+
+        // One more than a page tells whether another page follows
+        if keyed.len() > limit + 1 {
+            keyed.select_nth_unstable_by(limit, |a, b| {
+                self.compare_keys((&a.0, &a.1), (&b.0, &b.1))
+            });
+            keyed.truncate(limit + 1);
+        }
+        keyed.sort_unstable_by(|a, b| self.compare_keys((&a.0, &a.1), (&b.0, &b.1)));
+        let items = keyed.into_iter().map(|(_, _, item)| item).collect();
+        Ok(self.page(items, limit))
+    }
+
+    /// The page of the first `limit` of `items`, which are in this order
+    /// and follow the previous page's cursor. Pass at least `limit + 1`
+    /// items when more follow, so the page gets a cursor.
+    pub fn page<T: Listable>(&self, mut items: Vec<T>, limit: usize) -> Page<T> {
+        let next_cursor = if items.len() > limit {
+            items.truncate(limit);
+            items.last().map(|last| {
+                Cursor {
+                    order: *self,
+                    key: last.sort_key(self.sort),
+                    id: last.sort_id(),
+                }
+                .encode()
+            })
+        } else {
+            None
+        };
+        Page { items, next_cursor }
+    }
+}
+
+/// Check a requested page size.
+pub fn check_page_limit(limit: usize) -> CoreResult<()> {
+    if limit == 0 || limit > MAX_PAGE_SIZE {
+        return Err(CoreError::ValidationError(format!(
+            "limit must be between 1 and {} (got {})",
+            MAX_PAGE_SIZE, limit
+        )));
+    }
+    Ok(())
+}
+
+/// Sort documents by a top-level metadata field, ties broken by ID (or, for
+/// search results, by rank).
+///
+/// Numbers compare numerically and strings lexically; values of different
+/// types order as booleans, numbers, strings, then arrays and objects.
+/// Documents without the field come last in either direction.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MetadataSort {
+    pub field: String,
+    #[serde(default)]
+    pub direction: SortDirection,
+}
+
+impl MetadataSort {
+    /// The field's value in a metadata object (None if missing or null).
+    fn value<'a>(&self, metadata: Option<&'a JsonValue>) -> Option<&'a JsonValue> {
+        metadata
+            .and_then(|m| m.get(&self.field))
+            .filter(|v| !v.is_null())
+    }
+
+    /// Compare two metadata objects by the field only.
+    pub fn compare_metadata(&self, a: Option<&JsonValue>, b: Option<&JsonValue>) -> Ordering {
+        match (self.value(a), self.value(b)) {
+            (Some(x), Some(y)) => {
+                let ordering = compare_json(x, y);
+                match self.direction {
+                    SortDirection::Asc => ordering,
+                    SortDirection::Desc => ordering.reverse(),
+                }
+            }
+            (Some(_), None) => Ordering::Less,
+            (None, Some(_)) => Ordering::Greater,
+            (None, None) => Ordering::Equal,
+        }
+    }
+
+    pub fn compare(&self, a: &VectorDocument, b: &VectorDocument) -> Ordering {
+        self.compare_metadata(a.metadata.as_ref(), b.metadata.as_ref())
+            .then_with(|| a.doc_id.cmp(&b.doc_id))
+    }
+
+    /// Sort `docs` in this order.
+    pub fn sort(&self, docs: &mut [VectorDocument]) {
+        docs.sort_by(|a, b| self.compare(a, b));
+    }
+}
+
+/// Maximum partitions a scroll can be split into.
+pub const MAX_PARTITIONS: u32 = 1_024;
+
+/// One of `count` disjoint parts of a collection, chosen by a stable hash
+/// of the document ID. Scrolling every partition (e.g. concurrently, each
+/// with its own cursor) visits every document exactly once.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Partition {
+    pub index: u32,
+    pub count: u32,
+}
+
+impl Partition {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.count == 0 || self.count > MAX_PARTITIONS {
+            return Err(CoreError::ValidationError(format!(
+                "partition count must be between 1 and {} (got {})",
+                MAX_PARTITIONS, self.count
+            )));
+        }
+        if self.index >= self.count {
+            return Err(CoreError::ValidationError(format!(
+                "partition index must be below the count {} (got {})",
+                self.count, self.index
+            )));
+        }
+        Ok(())
+    }
+
+    pub fn contains(&self, doc: &VectorDocument) -> bool {
+        // FNV-1a: unlike the std hasher, stable across releases. Hashing
+        // spreads time-ordered IDs evenly over the partitions.
+        let hash = doc
+            .doc_id
+            .as_uuid()
+            .as_bytes()
+            .iter()
+            .fold(0xcbf2_9ce4_8422_2325u64, |hash, byte| {
+                (hash ^ *byte as u64).wrapping_mul(0x0100_0000_01b3)
+            });
+        hash % self.count as u64 == self.index as u64
+    }
+}
+
+fn compare_json(a: &JsonValue, b: &JsonValue) -> Ordering {
+    fn rank(v: &JsonValue) -> u8 {
+        match v {
+            JsonValue::Null => 0,
+            JsonValue::Bool(_) => 1,
+            JsonValue::Number(_) => 2,
+            JsonValue::String(_) => 3,
+            JsonValue::Array(_) | JsonValue::Object(_) => 4,
+        }
+    }
+    match (a, b) {
+        (JsonValue::Bool(x), JsonValue::Bool(y)) => x.cmp(y),
+        (JsonValue::Number(x), JsonValue::Number(y)) => {
+            let (x, y) = (x.as_f64().unwrap_or(0.0), y.as_f64().unwrap_or(0.0));
+            x.total_cmp(&y)
+        }
+        (JsonValue::String(x), JsonValue::String(y)) => x.cmp(y),
+        _ => rank(a)
+            .cmp(&rank(b))
+            .then_with(|| a.to_string().cmp(&b.to_string())),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DocumentId;
+
+    fn doc(external_id: &str) -> VectorDocument {
+        VectorDocument::new(DocumentId::new(), vec![1.0; 4])
+            .with_external_id(external_id.to_string())
+    }
+
+    #[test]
+    fn test_pages_survive_concurrent_inserts() {
+        let order = ListOrder {
+            sort: SortField::Name,
+            direction: SortDirection::Asc,
+        };
+        let mut docs: Vec<_> = ["d", "b", "a", "c"].into_iter().map(doc).collect();
+
+        let page = order.paginate(docs.clone(), None, 2).unwrap();
+        let names: Vec<_> = page.items.iter().map(|d| d.sort_key(order.sort)).collect();
+        assert_eq!(names, vec!["a", "b"]);
+
+        // An item sorting before the cursor does not shift the next page
+        docs.push(doc("0"));
+        let page = order
+            .paginate(docs.clone(), page.next_cursor.as_deref(), 2)
+            .unwrap();
+        let names: Vec<_> = page.items.iter().map(|d| d.sort_key(order.sort)).collect();
+        assert_eq!(names, vec!["c", "d"]);
+        assert!(page.next_cursor.is_none());
+    }
+
+    #[test]
+    fn test_sort_matches_pages() {
+        let order = ListOrder {
+            sort: SortField::Name,
+            direction: SortDirection::Desc,
+        };
+        let docs: Vec<_> = ["b", "d", "a", "b", "c"].into_iter().map(doc).collect();
+        let mut sorted = docs.clone();
+        order.sort(&mut sorted);
+
+        let mut paged = Vec::new();
+        let mut cursor = None;
+        loop {
+            let page = order.paginate(docs.clone(), cursor.as_deref(), 2).unwrap();
+            paged.extend(page.items);
+            cursor = page.next_cursor;
+            if cursor.is_none() {
+                break;
+            }
+        }
+        let ids = |docs: &[VectorDocument]| -> Vec<DocumentId> {
+            docs.iter().map(|d| d.doc_id).collect()
+        };
+        assert_eq!(ids(&paged), ids(&sorted));
+        assert_eq!(sorted[0].sort_key(order.sort), "d");
+    }
+
+    #[test]
+    fn test_partitions_are_disjoint_and_complete() {
+        let docs: Vec<_> = (0..200).map(|i| doc(&i.to_string())).collect();
+        let count = 4;
+        let mut seen = 0;
+        for index in 0..count {
+            let partition = Partition { index, count };
+            partition.validate().unwrap();
+            let size = docs.iter().filter(|d| partition.contains(d)).count();
+            assert!(size > 0);
+            seen += size;
+        }
+        assert_eq!(seen, docs.len());
+        for d in &docs {
+            let owners = (0..count)
+                .filter(|&index| Partition { index, count }.contains(d))
+                .count();
+            assert_eq!(owners, 1);
+        }
+        assert!(Partition { index: 4, count }.validate().is_err());
+        assert!(Partition { index: 0, count: 0 }.validate().is_err());
+    }
+
+    #[test]
+    fn test_metadata_sort() {
+        let docs = [
+            serde_json::json!({"chunk": 10}),
+            serde_json::json!({}),
+            serde_json::json!({"chunk": 2}),
+            serde_json::json!({"chunk": "x"}),
+        ];
+        let mut docs: Vec<_> = docs
+            .into_iter()
+            .map(|m| VectorDocument::new(DocumentId::new(), vec![1.0; 4]).with_metadata(m))
+            .collect();
+        let chunks = |docs: &[VectorDocument]| -> Vec<JsonValue> {
+            docs.iter()
+                .map(|d| d.metadata.as_ref().unwrap()["chunk"].clone())
+                .collect()
+        };
+
+        let mut sort = MetadataSort {
+            field: "chunk".to_string(),
+            direction: SortDirection::Asc,
+        };
+        sort.sort(&mut docs);
+        let expected = serde_json::json!([2, 10, "x", null]);
+        assert_eq!(chunks(&docs), expected.as_array().unwrap().clone());
+
+        // Missing values stay last when descending
+        sort.direction = SortDirection::Desc;
+        sort.sort(&mut docs);
+        let expected = serde_json::json!(["x", 10, 2, null]);
+        assert_eq!(chunks(&docs), expected.as_array().unwrap().clone());
+    }
+
+    #[test]
+    fn test_cursor_bound_to_order() {
+        let docs: Vec<_> = ["a", "a", "a"].into_iter().map(doc).collect();
+        let desc = ListOrder {
+            sort: SortField::Name,
+            direction: SortDirection::Desc,
+        };
+        let first = desc.paginate(docs.clone(), None, 2).unwrap();
+        let rest = desc
+            .paginate(docs.clone(), first.next_cursor.as_deref(), 2)
+            .unwrap();
+        // Equal names are ordered by ID, so pages neither overlap nor skip
+        assert_eq!(first.items.len() + rest.items.len(), 3);
+        assert!(!first
+            .items
+            .iter()
+            .any(|d| rest.items.iter().any(|r| r.doc_id == d.doc_id)));
+
+        let asc = ListOrder::default();
+        assert!(asc
+            .paginate(docs.clone(), first.next_cursor.as_deref(), 2)
+            .is_err());
+        assert!(asc.paginate(docs, Some("zz"), 2).is_err());
+    }
+}
@@ -0,0 +1,333 @@
+//! Grouped parent-document retrieval.
+//!
+//! Chunk-based RAG indexes store one vector per chunk, with the owning
+//! document's ID in a metadata field (`parent_id` by default). This module
+//! groups chunk hits by that field so callers get parent-level results
+//! instead of reimplementing the grouping for every index. [`GroupBy`] is
+//! the same grouping as a search option, keyed on any metadata field.
+
+use akidb_core::{CoreError, CoreResult, DistanceMetric, DocumentId, SearchResult};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+use std::collections::HashMap;
+use std::str::FromStr;
+
+/// Default metadata field holding a chunk's parent document ID.
+pub const DEFAULT_PARENT_KEY: &str = "parent_id";
+
+/// Options for parent-level search.
+#[derive(Debug, Clone)]
+pub struct ParentSearchOptions {
+    /// Metadata field holding the parent ID on each chunk.
+    pub parent_key: String,
+
+    /// Maximum number of parents to return.
+    pub top_parents: usize,
+
+    /// Number of chunk hits to retrieve before grouping.
+    /// Should be larger than `top_parents` since several chunks may share a parent.
+    pub chunk_top_k: usize,
+
+    /// Maximum chunks kept per parent (best first).
+    pub chunks_per_parent: usize,
+}
+
+impl ParentSearchOptions {
+    /// Creates options returning up to `top_parents` parents, searching
+    /// 4x as many chunks and keeping 3 chunks per parent.
+    pub fn new(top_parents: usize) -> Self {
+        Self {
+            parent_key: DEFAULT_PARENT_KEY.to_string(),
+            top_parents,
+            chunk_top_k: top_parents.saturating_mul(4),
+            chunks_per_parent: 3,
+        }
+    }
+
+    /// Sets the metadata field holding the parent ID.
+    pub fn with_parent_key(mut self, parent_key: impl Into<String>) -> Self {
+        self.parent_key = parent_key.into();
+        self
+    }
+
+    /// Sets the number of chunk hits retrieved before grouping.
+    pub fn with_chunk_top_k(mut self, chunk_top_k: usize) -> Self {
+        self.chunk_top_k = chunk_top_k;
+        self
+    }
+
+    /// Sets the maximum chunks kept per parent.
+    pub fn with_chunks_per_parent(mut self, chunks_per_parent: usize) -> Self {
+        self.chunks_per_parent = chunks_per_parent;
+        self
+    }
+}
+
+/// Maximum hits returned per group.
+pub const MAX_GROUP_SIZE: usize = 100;
+
+fn default_group_size() -> usize {
+    3
+}
+
+/// Search option returning the best groups of hits sharing a metadata value
+/// instead of individual hits.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GroupBy {
+    /// Metadata field keying the groups, e.g. "document_id". Hits without a
+    /// string or number in it are left out.
+    pub field: String,
+
+    /// Hits returned per group, best first (default: 3).
+    #[serde(default = "default_group_size")]
+    pub group_size: usize,
+
+    /// Hits retrieved before grouping (default: 4 * groups * group_size).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub candidates: Option<usize>,
+}
+
+impl GroupBy {
+    pub fn new(field: impl Into<String>) -> Self {
+        Self {
+            field: field.into(),
+            group_size: default_group_size(),
+            candidates: None,
+        }
+    }
+
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.field.is_empty() {
+            return Err(CoreError::ValidationError(
+                "group_by field cannot be empty".to_string(),
+            ));
+        }
+        if self.group_size == 0 || self.group_size > MAX_GROUP_SIZE {
+            return Err(CoreError::ValidationError(format!(
+                "group_size must be between 1 and {} (got {})",
+                MAX_GROUP_SIZE, self.group_size
+            )));
+        }
+        Ok(())
+    }
+
+    /// Hits to retrieve for `groups` groups.
+    pub fn candidates(&self, groups: usize) -> usize {
+        self.candidates
+            .unwrap_or_else(|| groups.saturating_mul(self.group_size).saturating_mul(4))
+            .max(groups)
+    }
+
+    /// Group `hits` (best first) into at most `groups` groups, in the order
+    /// of their best hits.
+    pub fn group(&self, hits: Vec<SearchResult>, groups: usize) -> Vec<ParentResult> {
+        let options = ParentSearchOptions::new(groups)
+            .with_parent_key(self.field.clone())
+            .with_chunks_per_parent(self.group_size);
+        let mut grouped = group_by_parent(hits, &options, DistanceMetric::Cosine);
+        // Hits come best first on any score scale (raw distances or
+        // normalized scores), so a group's score is its first hit's
+        for group in &mut grouped {
+            if let Some(best) = group.chunks.first() {
+                group.score = best.score;
+            }
+        }
+        grouped
+    }
+}
+
+/// A parent document assembled from its matching chunks.
+#[derive(Debug, Clone)]
+pub struct ParentResult {
+    /// Parent ID as stored in chunk metadata.
+    pub parent_id: String,
+
+    /// Best chunk score for this parent (same scale as chunk scores).
+    pub score: f32,
+
+    /// Parent document metadata from the batched Get
+    /// (None if the parent was not found or its ID is not a document ID).
+    pub metadata: Option<JsonValue>,
+
+    /// Matching chunks, best first.
+    pub chunks: Vec<SearchResult>,
+}
+
+/// Extracts the parent ID from a chunk's metadata.
+///
+/// String values are used as-is; numbers are converted to their string form.
+pub fn parent_id_of(result: &SearchResult, parent_key: &str) -> Option<String> {
+    match result.metadata.as_ref()?.get(parent_key)? {
+        JsonValue::String(s) => Some(s.clone()),
+        JsonValue::Number(n) => Some(n.to_string()),
+        _ => None,
+    }
+}
+
+/// Groups chunk hits by parent ID.
+///
+/// `chunks` must be sorted best first (as returned by search); groups keep
+/// that order, so the first group holds the best-scoring chunk. Chunks without
+/// a parent ID are skipped. Parent metadata is left empty for the caller to fill.
+pub fn group_by_parent(
+    chunks: Vec<SearchResult>,
+    options: &ParentSearchOptions,
+    metric: DistanceMetric,
+) -> Vec<ParentResult> {
+    let lower_is_better = matches!(metric, DistanceMetric::L2);
+    let mut groups: Vec<ParentResult> = Vec::new();
+    let mut positions: HashMap<String, usize> = HashMap::new();
+
+    for chunk in chunks {
+        let Some(parent_id) = parent_id_of(&chunk, &options.parent_key) else {
+            continue;
+        };
+
+        match positions.get(&parent_id) {
+            Some(&pos) => {
+                let group = &mut groups[pos];
+                let better = if lower_is_better {
+                    chunk.score < group.score
+                } else {
+                    chunk.score > group.score
+                };
+                if better {
+                    group.score = chunk.score;
+                }
+                if group.chunks.len() < options.chunks_per_parent {
+                    group.chunks.push(chunk);
+                }
+            }
+            None => {
+                if groups.len() >= options.top_parents {
+                    continue;
+                }
+                positions.insert(parent_id.clone(), groups.len());
+                groups.push(ParentResult {
+                    parent_id,
+                    score: chunk.score,
+                    metadata: None,
+                    chunks: if options.chunks_per_parent > 0 {
+                        vec![chunk]
+                    } else {
+                        Vec::new()
+                    },
+                });
+            }
+        }
+    }
+
+    groups
+}
+
+/// Parses parent IDs that are valid document IDs (for the batched Get).
+pub fn parent_document_ids(parents: &[ParentResult]) -> Vec<Option<DocumentId>> {
+    parents
+        .iter()
+        .map(|p| DocumentId::from_str(&p.parent_id).ok())
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn chunk(parent: &str, score: f32) -> SearchResult {
+        SearchResult::new(DocumentId::new(), score).with_metadata(json!({ "parent_id": parent }))
+    }
+
+    #[test]
+    fn test_group_by_parent_preserves_rank_order() {
+        let chunks = vec![
+            chunk("a", 0.9),
+            chunk("b", 0.8),
+            chunk("a", 0.7),
+            chunk("c", 0.6),
+        ];
+        let groups = group_by_parent(
+            chunks,
+            &ParentSearchOptions::new(10),
+            DistanceMetric::Cosine,
+        );
+
+        let ids: Vec<_> = groups.iter().map(|g| g.parent_id.as_str()).collect();
+        assert_eq!(ids, vec!["a", "b", "c"]);
+        assert_eq!(groups[0].chunks.len(), 2);
+        assert!((groups[0].score - 0.9).abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_group_by_parent_limits() {
+        let chunks = vec![
+            chunk("a", 0.1),
+            chunk("a", 0.2),
+            chunk("b", 0.3),
+            chunk("c", 0.4),
+        ];
+        let options = ParentSearchOptions::new(2).with_chunks_per_parent(1);
+        let groups = group_by_parent(chunks, &options, DistanceMetric::L2);
+
+        assert_eq!(groups.len(), 2);
+        assert_eq!(groups[0].chunks.len(), 1);
+        assert!((groups[0].score - 0.1).abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_group_by_parent_custom_key_and_missing_parent() {
+        let chunks = vec![
+            SearchResult::new(DocumentId::new(), 0.9).with_metadata(json!({ "doc": 42 })),
+            SearchResult::new(DocumentId::new(), 0.8),
+        ];
+        let options = ParentSearchOptions::new(5).with_parent_key("doc");
+        let groups = group_by_parent(chunks, &options, DistanceMetric::Dot);
+
+        assert_eq!(groups.len(), 1);
+        assert_eq!(groups[0].parent_id, "42");
+    }
+
+    #[test]
+    fn test_group_by_field() {
+        let hit = |document: &str, score: f32| {
+            SearchResult::new(DocumentId::new(), score)
+                .with_metadata(json!({ "document_id": document }))
+        };
+        let hits = vec![
+            hit("a", 0.9),
+            hit("a", 0.8),
+            hit("a", 0.7),
+            hit("b", 0.6),
+            hit("c", 0.5),
+        ];
+        let group_by = GroupBy {
+            group_size: 2,
+            ..GroupBy::new("document_id")
+        };
+        group_by.validate().unwrap();
+        assert_eq!(group_by.candidates(2), 16);
+
+        let groups = group_by.group(hits, 2);
+        let keys: Vec<_> = groups.iter().map(|g| g.parent_id.as_str()).collect();
+        assert_eq!(keys, vec!["a", "b"]);
+        assert_eq!(groups[0].chunks.len(), 2);
+        assert_eq!(groups[1].score, 0.6);
+
+        assert!(GroupBy::new("").validate().is_err());
+        let too_large = GroupBy {
+            group_size: MAX_GROUP_SIZE + 1,
+            ..GroupBy::new("document_id")
+        };
+        assert!(too_large.validate().is_err());
+    }
+
+    #[test]
+    fn test_parent_document_ids() {
+        let doc_id = DocumentId::new();
+        let parents = group_by_parent(
+            vec![chunk(&doc_id.to_string(), 0.9), chunk("not-a-uuid", 0.5)],
+            &ParentSearchOptions::new(5),
+            DistanceMetric::Cosine,
+        );
+        assert_eq!(parent_document_ids(&parents), vec![Some(doc_id), None]);
+    }
+}
@@ -0,0 +1,88 @@
+//! Partial metadata updates.
+//!
+//! A patch changes some metadata fields of a stored document without
+//! resending its vector. It follows JSON Merge Patch (RFC 7396): fields in
+//! the patch replace stored ones, nested objects are merged recursively and
+//! `null` removes a field. See `CollectionService::update_metadata`.
+
+use akidb_core::{CoreError, CoreResult, DocumentId};
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value as JsonValue};
+
+/// A metadata patch for one document.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct MetadataPatch {
+    pub doc_id: DocumentId,
+    pub patch: Map<String, JsonValue>,
+}
+
+/// Apply `patch` to `metadata`, returning the patched metadata (`None` if
+/// no field is left).
+pub fn apply_patch(
+    metadata: Option<JsonValue>,
+    patch: &Map<String, JsonValue>,
+) -> CoreResult<Option<JsonValue>> {
+    if patch.is_empty() {
+        return Err(CoreError::ValidationError(
+            "metadata patch must have at least one field".to_string(),
+        ));
+    }
+    let mut target = match metadata {
+        Some(JsonValue::Object(fields)) => JsonValue::Object(fields),
+        Some(JsonValue::Null) | None => JsonValue::Object(Map::new()),
+        Some(_) => {
+            return Err(CoreError::invalid_state(
+                "stored metadata is not an object and cannot be patched",
+            ))
+        }
+    };
+    merge(&mut target, &JsonValue::Object(patch.clone()));
+    Ok(match target {
+        JsonValue::Object(fields) if fields.is_empty() => None,
+        target => Some(target),
+    })
+}
+
+fn merge(target: &mut JsonValue, patch: &JsonValue) {
+    let JsonValue::Object(patch) = patch else {
+        *target = patch.clone();
+        return;
+    };
+    if !target.is_object() {
+        *target = JsonValue::Object(Map::new());
+    }
+    let JsonValue::Object(fields) = target else {
+        return;
+    };
+    for (key, value) in patch {
+        if value.is_null() {
+            fields.remove(key);
+        } else {
+            merge(fields.entry(key.clone()).or_insert(JsonValue::Null), value);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_merge_patch() {
+        let metadata = json!({"title": "Old", "tags": ["a"], "meta": {"x": 1, "y": 2}});
+        let patch =
+            json!({"title": "New", "tags": ["b"], "meta": {"y": null, "z": 3}, "gone": null});
+        let patched = apply_patch(Some(metadata), patch.as_object().unwrap()).unwrap();
+        assert_eq!(
+            patched,
+            Some(json!({"title": "New", "tags": ["b"], "meta": {"x": 1, "z": 3}}))
+        );
+
+        let remove_all = json!({"title": null});
+        let patched = apply_patch(Some(json!({"title": "x"})), remove_all.as_object().unwrap());
+        assert_eq!(patched.unwrap(), None);
+        assert!(apply_patch(None, &Map::new()).is_err());
+        assert!(apply_patch(Some(json!([1])), patch.as_object().unwrap()).is_err());
+    }
+}
@@ -0,0 +1,708 @@
+//! Search result post-processing pipeline.
+//!
+//! Post-processors run after k-NN search and before results are returned to the
+//! caller. Common RAG glue (score normalization, metadata enrichment, threshold
+//! trimming) lives here so every API surface applies it the same way.
+//!
+//! Score normalization makes thresholds behave consistently across collections
+//! with different metrics; see `ScoreNormalization` for the methods.
+//!
+//! Results can also be re-ordered by a metadata field (`MetadataSorter`), e.g.
+//! "most recent relevant items": a threshold keeps the relevant matches and
+//! the sorter orders them by recency.
+//!
+//! Score modifiers (`ScoreModifiers`) multiply similarity by metadata-derived
+//! factors such as field boosts and time decay, then re-rank.
+//!
+//! `Deduplicator` keeps only the best result per value of a metadata field,
+//! e.g. one chunk per source URL.
+//!
+//! A pipeline can be installed service-wide via
+//! `CollectionService::set_post_processing()` or supplied per call via
+//! `CollectionService::query_with_pipeline()`.
+
+use crate::ordering::MetadataSort;
+use crate::scoring::{validate_modifiers, ScoreModifier};
+use akidb_core::{CoreError, CoreResult, DistanceMetric, SearchResult};
+use chrono::Utc;
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+use std::fmt;
+use std::sync::Arc;
+
+/// State shared between stages while a pipeline runs.
+#[derive(Debug, Clone, Copy)]
+pub struct PostProcessContext {
+    /// Distance metric of the searched collection.
+    pub metric: DistanceMetric,
+
+    /// Whether a larger score currently means a better match.
+    ///
+    /// Starts as `false` for L2 (raw distances) and `true` for Cosine/Dot.
+    /// Normalization rewrites scores so that higher is always better.
+    pub higher_is_better: bool,
+
+    /// Whether a normalization stage has rewritten the raw scores.
+    pub normalized: bool,
+}
+
+impl PostProcessContext {
+    /// Creates a context for raw scores produced by `metric`.
+    pub fn new(metric: DistanceMetric) -> Self {
+        Self {
+            metric,
+            higher_is_better: !matches!(metric, DistanceMetric::L2),
+            normalized: false,
+        }
+    }
+}
+
+/// A single post-processing stage.
+pub trait PostProcessor: Send + Sync {
+    /// Short stage name (used in logs and `Debug` output).
+    fn name(&self) -> &str;
+
+    /// Transforms search results. Stages may reorder, drop, or mutate results.
+    fn process(
+        &self,
+        results: Vec<SearchResult>,
+        ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult>;
+}
+
+/// Min-max normalizes scores into `[0.0, 1.0]` where 1.0 is the best match.
+///
+/// For L2 the scale is inverted so the closest vector scores 1.0. When every
+/// result has the same score, all scores become 1.0.
+#[derive(Debug, Clone, Copy, Default)]
+pub struct ScoreNormalizer;
+
+impl PostProcessor for ScoreNormalizer {
+    fn name(&self) -> &str {
+        "normalize"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        let (min, max) = results
+            .iter()
+            .filter(|r| r.score.is_finite())
+            .fold((f32::INFINITY, f32::NEG_INFINITY), |(lo, hi), r| {
+                (lo.min(r.score), hi.max(r.score))
+            });
+        let range = max - min;
+
+        for result in &mut results {
+            result.score = if !result.score.is_finite() {
+                0.0
+            } else if range <= f32::EPSILON {
+                1.0
+            } else {
+                let scaled = (result.score - min) / range;
+                if ctx.higher_is_better {
+                    scaled
+                } else {
+                    1.0 - scaled
+                }
+            };
+        }
+
+        ctx.higher_is_better = true;
+        ctx.normalized = true;
+        results
+    }
+}
+
+/// Converts raw scores to a similarity in `[0.0, 1.0]` (1.0 = identical)
+/// using only the metric, so scores are comparable across result sets and
+/// collections:
+/// - Cosine: `(1 + s) / 2`
+/// - L2: `1 / (1 + d)`
+/// - Dot: `1 / (1 + e^-s)` (logistic)
+#[derive(Debug, Clone, Copy, Default)]
+pub struct SimilarityConverter;
+
+impl PostProcessor for SimilarityConverter {
+    fn name(&self) -> &str {
+        "similarity"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        for result in &mut results {
+            let s = result.score;
+            result.score = if !s.is_finite() {
+                0.0
+            } else {
+                match ctx.metric {
+                    DistanceMetric::Cosine => ((1.0 + s) / 2.0).clamp(0.0, 1.0),
+                    DistanceMetric::L2 => 1.0 / (1.0 + s.max(0.0)),
+                    DistanceMetric::Dot => 1.0 / (1.0 + (-s).exp()),
+                }
+            };
+        }
+
+        ctx.higher_is_better = true;
+        ctx.normalized = true;
+        results
+    }
+}
+
+/// Softmax over the result set: scores become probabilities summing to 1.0.
+///
+/// Lower temperatures sharpen the distribution towards the best match, higher
+/// ones flatten it. L2 distances are negated first so the closest vector gets
+/// the largest share.
+#[derive(Debug, Clone, Copy)]
+pub struct TemperatureScaling {
+    temperature: f32,
+}
+
+impl TemperatureScaling {
+    /// Creates a softmax stage. `temperature` must be positive.
+    pub fn new(temperature: f32) -> CoreResult<Self> {
+        if !temperature.is_finite() || temperature <= 0.0 {
+            return Err(CoreError::ValidationError(format!(
+                "temperature must be a positive number (got {})",
+                temperature
+            )));
+        }
+        Ok(Self { temperature })
+    }
+}
+
+impl PostProcessor for TemperatureScaling {
+    fn name(&self) -> &str {
+        "softmax"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        let sign = if ctx.higher_is_better { 1.0 } else { -1.0 };
+        let logits: Vec<f32> = results
+            .iter()
+            .map(|r| {
+                if r.score.is_finite() {
+                    sign * r.score / self.temperature
+                } else {
+                    f32::NEG_INFINITY
+                }
+            })
+            .collect();
+        // Subtract the max logit for numerical stability
+        let max = logits.iter().copied().fold(f32::NEG_INFINITY, f32::max);
+        let weights: Vec<f32> = logits
+            .iter()
+            .map(|l| {
+                if max.is_finite() {
+                    (l - max).exp()
+                } else {
+                    0.0
+                }
+            })
+            .collect();
+        let total: f32 = weights.iter().sum();
+
+        for (result, weight) in results.iter_mut().zip(weights) {
+            result.score = if total > 0.0 { weight / total } else { 0.0 };
+        }
+
+        ctx.higher_is_better = true;
+        ctx.normalized = true;
+        results
+    }
+}
+
+/// Score normalization method, selectable per query.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "method", rename_all = "snake_case")]
+pub enum ScoreNormalization {
+    /// Min-max within the result set ([`ScoreNormalizer`]).
+    MinMax,
+    /// Metric-aware conversion to a similarity ([`SimilarityConverter`]).
+    Similarity,
+    /// Softmax with a temperature ([`TemperatureScaling`]).
+    Softmax { temperature: f32 },
+}
+
+/// Drops results that do not meet a score threshold.
+///
+/// When higher scores are better (Cosine, Dot, or after normalization) results
+/// with `score < threshold` are removed. For raw L2 distances results with
+/// `score > threshold` are removed.
+#[derive(Debug, Clone, Copy)]
+pub struct ScoreThreshold {
+    threshold: f32,
+}
+
+impl ScoreThreshold {
+    /// Creates a threshold stage.
+    pub fn new(threshold: f32) -> Self {
+        Self { threshold }
+    }
+}
+
+impl PostProcessor for ScoreThreshold {
+    fn name(&self) -> &str {
+        "threshold"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        if ctx.higher_is_better {
+            results.retain(|r| r.score >= self.threshold);
+        } else {
+            results.retain(|r| r.score <= self.threshold);
+        }
+        results
+    }
+}
+
+/// Orders results by a metadata field, keeping the first `keep`.
+///
+/// The sort is stable, so results with equal values stay in rank order.
+/// Search for more than `keep` candidates so the sort has results to choose
+/// from.
+#[derive(Debug, Clone)]
+pub struct MetadataSorter {
+    sort: MetadataSort,
+    keep: usize,
+}
+
+impl MetadataSorter {
+    /// Creates a sorting stage.
+    pub fn new(sort: MetadataSort, keep: usize) -> Self {
+        Self { sort, keep }
+    }
+}
+
+impl PostProcessor for MetadataSorter {
+    fn name(&self) -> &str {
+        "sort"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        _ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        results.sort_by(|a, b| {
+            self.sort
+                .compare_metadata(a.metadata.as_ref(), b.metadata.as_ref())
+        });
+        results.truncate(self.keep);
+        results
+    }
+}
+
+/// Multiplies each score by its [`ScoreModifier`] factors, re-ranks by the
+/// result and keeps the first `keep`.
+///
+/// Raw scores are first converted to similarities ([`SimilarityConverter`])
+/// so the factors scale a non-negative, higher-is-better score whatever the
+/// metric. Search for more than `keep` candidates so boosted matches can move
+/// up from below the cut.
+#[derive(Debug, Clone)]
+pub struct ScoreModifiers {
+    modifiers: Vec<ScoreModifier>,
+    keep: usize,
+}
+
+impl ScoreModifiers {
+    /// Creates a modifier stage; fails on invalid modifiers.
+    pub fn new(modifiers: Vec<ScoreModifier>, keep: usize) -> CoreResult<Self> {
+        validate_modifiers(&modifiers)?;
+        Ok(Self { modifiers, keep })
+    }
+}
+
+impl PostProcessor for ScoreModifiers {
+    fn name(&self) -> &str {
+        "modify"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        if !ctx.normalized {
+            results = SimilarityConverter.process(results, ctx);
+        }
+        let now = Utc::now().timestamp_millis() as f64 / 1000.0;
+        for result in &mut results {
+            let factor: f64 = self
+                .modifiers
+                .iter()
+                .map(|m| m.factor(result.metadata.as_ref(), now))
+                .product();
+            result.score = (result.score as f64 * factor) as f32;
+        }
+        results.sort_by(|a, b| b.score.total_cmp(&a.score));
+        results.truncate(self.keep);
+        results
+    }
+}
+
+/// Keeps the first (best-ranked) result per value of a metadata field, up to
+/// `keep` results.
+///
+/// Results without the field are all kept. Search for more than `keep`
+/// candidates so duplicates can be replaced by the next distinct values.
+#[derive(Debug, Clone)]
+pub struct Deduplicator {
+    field: String,
+    keep: usize,
+}
+
+impl Deduplicator {
+    /// Creates a deduplication stage.
+    pub fn new(field: impl Into<String>, keep: usize) -> Self {
+        Self {
+            field: field.into(),
+            keep,
+        }
+    }
+}
+
+impl PostProcessor for Deduplicator {
+    fn name(&self) -> &str {
+        "dedupe"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        _ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        let mut seen = HashSet::new();
+        results.retain(
+            |r| match r.metadata.as_ref().and_then(|m| m.get(&self.field)) {
+                Some(value) if !value.is_null() => seen.insert(value.to_string()),
+                _ => true,
+            },
+        );
+        results.truncate(self.keep);
+        results
+    }
+}
+
+/// Enriches each result through a user-supplied callback
+/// (e.g., to attach metadata looked up from an external store).
+#[derive(Clone)]
+pub struct MetadataEnricher {
+    callback: Arc<dyn Fn(&mut SearchResult) + Send + Sync>,
+}
+
+impl MetadataEnricher {
+    /// Creates an enricher from a callback invoked once per result.
+    pub fn new<F>(callback: F) -> Self
+    where
+        F: Fn(&mut SearchResult) + Send + Sync + 'static,
+    {
+        Self {
+            callback: Arc::new(callback),
+        }
+    }
+}
+
+impl PostProcessor for MetadataEnricher {
+    fn name(&self) -> &str {
+        "enrich"
+    }
+
+    fn process(
+        &self,
+        mut results: Vec<SearchResult>,
+        _ctx: &mut PostProcessContext,
+    ) -> Vec<SearchResult> {
+        for result in &mut results {
+            (self.callback)(result);
+        }
+        results
+    }
+}
+
+/// Ordered list of post-processing stages.
+#[derive(Clone, Default)]
+pub struct PostProcessingPipeline {
+    stages: Vec<Arc<dyn PostProcessor>>,
+}
+
+impl PostProcessingPipeline {
+    /// Creates an empty pipeline (results pass through unchanged).
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Appends an arbitrary stage.
+    pub fn with_stage<P: PostProcessor + 'static>(mut self, stage: P) -> Self {
+        self.stages.push(Arc::new(stage));
+        self
+    }
+
+    /// Appends a [`ScoreNormalizer`] stage.
+    pub fn with_normalization(self) -> Self {
+        self.with_stage(ScoreNormalizer)
+    }
+
+    /// Appends the stage implementing a normalization method.
+    pub fn with_score_normalization(self, method: ScoreNormalization) -> CoreResult<Self> {
+        Ok(match method {
+            ScoreNormalization::MinMax => self.with_normalization(),
+            ScoreNormalization::Similarity => self.with_stage(SimilarityConverter),
+            ScoreNormalization::Softmax { temperature } => {
+                self.with_stage(TemperatureScaling::new(temperature)?)
+            }
+        })
+    }
+
+    /// Appends a [`ScoreThreshold`] stage.
+    pub fn with_threshold(self, threshold: f32) -> Self {
+        self.with_stage(ScoreThreshold::new(threshold))
+    }
+
+    /// Appends a [`Deduplicator`] stage.
+    pub fn with_dedupe(self, field: impl Into<String>, keep: usize) -> Self {
+        self.with_stage(Deduplicator::new(field, keep))
+    }
+
+    /// Appends a [`MetadataSorter`] stage.
+    pub fn with_metadata_sort(self, sort: MetadataSort, keep: usize) -> Self {
+        self.with_stage(MetadataSorter::new(sort, keep))
+    }
+
+    /// Appends a [`ScoreModifiers`] stage.
+    pub fn with_score_modifiers(
+        self,
+        modifiers: Vec<ScoreModifier>,
+        keep: usize,
+    ) -> CoreResult<Self> {
+        Ok(self.with_stage(ScoreModifiers::new(modifiers, keep)?))
+    }
+
+    /// Appends a [`MetadataEnricher`] stage.
+    pub fn with_enricher<F>(self, callback: F) -> Self
+    where
+        F: Fn(&mut SearchResult) + Send + Sync + 'static,
+    {
+        self.with_stage(MetadataEnricher::new(callback))
+    }
+
+    /// Number of stages.
+    pub fn len(&self) -> usize {
+        self.stages.len()
+    }
+
+    /// Returns true if the pipeline has no stages.
+    pub fn is_empty(&self) -> bool {
+        self.stages.is_empty()
+    }
+
+    /// Runs every stage in order over `results`.
+    pub fn apply(&self, results: Vec<SearchResult>, metric: DistanceMetric) -> Vec<SearchResult> {
+        let mut ctx = PostProcessContext::new(metric);
+        self.stages
+            .iter()
+            .fold(results, |acc, stage| stage.process(acc, &mut ctx))
+    }
+}
+
+impl fmt::Debug for PostProcessingPipeline {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_list()
+            .entries(self.stages.iter().map(|s| s.name()))
+            .finish()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DocumentId;
+
+    fn results(scores: &[f32]) -> Vec<SearchResult> {
+        scores
+            .iter()
+            .map(|&s| SearchResult::new(DocumentId::new(), s))
+            .collect()
+    }
+
+    fn scores(results: &[SearchResult]) -> Vec<f32> {
+        results.iter().map(|r| r.score).collect()
+    }
+
+    #[test]
+    fn test_empty_pipeline_passes_through() {
+        let pipeline = PostProcessingPipeline::new();
+        let out = pipeline.apply(results(&[0.9, 0.5]), DistanceMetric::Cosine);
+        assert_eq!(scores(&out), vec![0.9, 0.5]);
+    }
+
+    #[test]
+    fn test_normalize_similarity_scores() {
+        let pipeline = PostProcessingPipeline::new().with_normalization();
+        let out = pipeline.apply(results(&[0.9, 0.7, 0.5]), DistanceMetric::Cosine);
+        let s = scores(&out);
+        assert!((s[0] - 1.0).abs() < 1e-6);
+        assert!((s[1] - 0.5).abs() < 1e-6);
+        assert!(s[2].abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_normalize_inverts_l2_distances() {
+        let pipeline = PostProcessingPipeline::new().with_normalization();
+        let out = pipeline.apply(results(&[1.0, 2.0, 3.0]), DistanceMetric::L2);
+        let s = scores(&out);
+        assert!((s[0] - 1.0).abs() < 1e-6);
+        assert!(s[2].abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_normalize_identical_scores() {
+        let pipeline = PostProcessingPipeline::new().with_normalization();
+        let out = pipeline.apply(results(&[0.4, 0.4]), DistanceMetric::Dot);
+        assert_eq!(scores(&out), vec![1.0, 1.0]);
+    }
+
+    #[test]
+    fn test_similarity_conversion_is_metric_aware() {
+        let pipeline = PostProcessingPipeline::new()
+            .with_score_normalization(ScoreNormalization::Similarity)
+            .unwrap();
+        let out = pipeline.apply(results(&[1.0, 0.0, -1.0]), DistanceMetric::Cosine);
+        assert_eq!(scores(&out), vec![1.0, 0.5, 0.0]);
+
+        let out = pipeline.apply(results(&[0.0, 1.0, 3.0]), DistanceMetric::L2);
+        assert_eq!(scores(&out), vec![1.0, 0.5, 0.25]);
+
+        let out = pipeline.apply(results(&[0.0]), DistanceMetric::Dot);
+        assert_eq!(scores(&out), vec![0.5]);
+    }
+
+    #[test]
+    fn test_softmax_temperature() {
+        let softmax = |temperature| {
+            PostProcessingPipeline::new()
+                .with_score_normalization(ScoreNormalization::Softmax { temperature })
+                .unwrap()
+        };
+        let out = softmax(1.0).apply(results(&[1.0, 2.0, 3.0]), DistanceMetric::L2);
+        let s = scores(&out);
+        assert!((s.iter().sum::<f32>() - 1.0).abs() < 1e-6);
+        assert!(s[0] > s[1] && s[1] > s[2]);
+
+        // Lower temperature sharpens towards the best match
+        let sharp = scores(&softmax(0.1).apply(results(&[0.9, 0.8]), DistanceMetric::Cosine));
+        let flat = scores(&softmax(10.0).apply(results(&[0.9, 0.8]), DistanceMetric::Cosine));
+        assert!(sharp[0] > flat[0]);
+
+        assert!(PostProcessingPipeline::new()
+            .with_score_normalization(ScoreNormalization::Softmax { temperature: 0.0 })
+            .is_err());
+    }
+
+    #[test]
+    fn test_threshold_respects_metric_direction() {
+        let pipeline = PostProcessingPipeline::new().with_threshold(0.6);
+        let out = pipeline.apply(results(&[0.9, 0.6, 0.3]), DistanceMetric::Cosine);
+        assert_eq!(scores(&out), vec![0.9, 0.6]);
+
+        let out = pipeline.apply(results(&[0.2, 0.6, 1.5]), DistanceMetric::L2);
+        assert_eq!(scores(&out), vec![0.2, 0.6]);
+    }
+
+    #[test]
+    fn test_threshold_after_normalization_uses_normalized_scale() {
+        let pipeline = PostProcessingPipeline::new()
+            .with_normalization()
+            .with_threshold(0.5);
+        let out = pipeline.apply(results(&[1.0, 2.0, 3.0]), DistanceMetric::L2);
+        assert_eq!(out.len(), 2);
+    }
+
+    #[test]
+    fn test_enricher_attaches_metadata() {
+        let pipeline = PostProcessingPipeline::new().with_enricher(|r| {
+            r.metadata = Some(serde_json::json!({ "rank_score": r.score }));
+        });
+        let out = pipeline.apply(results(&[0.8]), DistanceMetric::Cosine);
+        assert_eq!(
+            out[0].metadata.as_ref().unwrap()["rank_score"],
+            0.8f32 as f64
+        );
+        assert_eq!(format!("{:?}", pipeline), "[\"enrich\"]");
+    }
+
+    #[test]
+    fn test_most_recent_relevant() {
+        let mut input = results(&[0.9, 0.8, 0.7, 0.2]);
+        for (result, year) in input.iter_mut().zip([2019, 2023, 2021, 2024]) {
+            result.metadata = Some(serde_json::json!({ "year": year }));
+        }
+        let sort = MetadataSort {
+            field: "year".to_string(),
+            direction: crate::ordering::SortDirection::Desc,
+        };
+        let pipeline = PostProcessingPipeline::new()
+            .with_threshold(0.5)
+            .with_metadata_sort(sort, 2);
+        let out = pipeline.apply(input, DistanceMetric::Cosine);
+        assert_eq!(scores(&out), vec![0.8, 0.7]);
+    }
+
+    #[test]
+    fn test_dedupe_keeps_best_per_value() {
+        let mut input = results(&[0.9, 0.8, 0.7, 0.6, 0.5]);
+        let urls = [Some("a"), Some("a"), None, Some("b"), Some("c")];
+        for (result, url) in input.iter_mut().zip(urls) {
+            result.metadata = url.map(|url| serde_json::json!({ "url": url }));
+        }
+        let pipeline = PostProcessingPipeline::new().with_dedupe("url", 3);
+        let out = pipeline.apply(input, DistanceMetric::Cosine);
+        assert_eq!(scores(&out), vec![0.9, 0.7, 0.6]);
+    }
+
+    #[test]
+    fn test_score_modifiers_rerank() {
+        let mut input = results(&[0.9, 0.8, 0.7]);
+        for (result, tier) in input.iter_mut().zip(["free", "free", "gold"]) {
+            result.metadata = Some(serde_json::json!({ "tier": tier }));
+        }
+        let boost: ScoreModifier = serde_json::from_value(serde_json::json!({
+            "type": "boost", "field": "tier", "value": "gold", "weight": 1.5,
+        }))
+        .unwrap();
+        let pipeline = PostProcessingPipeline::new()
+            .with_score_normalization(ScoreNormalization::Similarity)
+            .unwrap()
+            .with_score_modifiers(vec![boost.clone()], 2)
+            .unwrap();
+        let out = pipeline.apply(input.clone(), DistanceMetric::Cosine);
+        let tiers: Vec<_> = out
+            .iter()
+            .map(|r| r.metadata.as_ref().unwrap()["tier"].clone())
+            .collect();
+        assert_eq!(tiers, vec!["gold", "free"]);
+        assert!((out[0].score - 0.85 * 1.5).abs() < 1e-6);
+
+        // Raw L2 distances are converted to similarities before boosting
+        let pipeline = PostProcessingPipeline::new()
+            .with_score_modifiers(vec![boost], 3)
+            .unwrap();
+        let out = pipeline.apply(input, DistanceMetric::L2);
+        assert!(out.iter().all(|r| r.score > 0.0 && r.score <= 1.5));
+    }
+}
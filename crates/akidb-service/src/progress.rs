@@ -0,0 +1,117 @@
+//! Progress event streams for long-running operations.
+//!
+//! Dashboards watching an import or backfill subscribe to its progress
+//! events instead of polling. Channels are created on first subscription, so
+//! operations nobody watches publish nothing, and are closed when the
+//! operation finishes, which ends every subscriber's stream.
+
+use akidb_core::UploadId;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::hash::Hash;
+use std::sync::Mutex;
+use tokio::sync::broadcast;
+
+use crate::upload::{ImportReport, UploadStatus};
+
+/// Events buffered per operation before slow subscribers start lagging.
+pub const PROGRESS_BUFFER: usize = 64;
+
+/// Records imported between upload progress events.
+pub const IMPORT_PROGRESS_INTERVAL: u64 = 1000;
+
+/// Progress of an upload's import.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ImportProgress {
+    pub upload_id: UploadId,
+    pub status: UploadStatus,
+
+    /// Non-empty lines processed so far.
+    pub records: u64,
+    pub inserted: u64,
+    pub failed: u64,
+}
+
+impl ImportProgress {
+    pub fn new(upload_id: UploadId, status: UploadStatus, report: Option<&ImportReport>) -> Self {
+        Self {
+            upload_id,
+            status,
+            records: report.map_or(0, |r| r.records),
+            inserted: report.map_or(0, |r| r.inserted),
+            failed: report.map_or(0, |r| r.failed),
+        }
+    }
+}
+
+/// Broadcast channels of progress events, one per operation.
+#[derive(Debug)]
+pub struct ProgressChannels<K, T> {
+    senders: Mutex<HashMap<K, broadcast::Sender<T>>>,
+}
+
+impl<K, T> Default for ProgressChannels<K, T> {
+    fn default() -> Self {
+        Self {
+            senders: Mutex::new(HashMap::new()),
+        }
+    }
+}
+
+impl<K: Eq + Hash, T: Clone> ProgressChannels<K, T> {
+    /// Receives the operation's events from now on.
+    pub fn subscribe(&self, key: K) -> broadcast::Receiver<T> {
+        let mut senders = self.senders.lock().unwrap();
+        senders
+            .entry(key)
+            .or_insert_with(|| broadcast::channel(PROGRESS_BUFFER).0)
+            .subscribe()
+    }
+
+    /// Sends an event to the operation's subscribers, if any.
+    pub fn publish(&self, key: &K, event: T) {
+        let mut senders = self.senders.lock().unwrap();
+        if let Some(sender) = senders.get(key) {
+            if sender.send(event).is_err() {
+                // Every subscriber disconnected
+                senders.remove(key);
+            }
+        }
+    }
+
+    /// Ends the operation's streams after its final event.
+    pub fn close(&self, key: &K) {
+        self.senders.lock().unwrap().remove(key);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tokio::sync::broadcast::error::TryRecvError;
+
+    #[test]
+    fn test_publish_and_close() {
+        let channels: ProgressChannels<u32, u64> = ProgressChannels::default();
+
+        // Nobody subscribed yet
+        channels.publish(&1, 10);
+
+        let mut rx = channels.subscribe(1);
+        channels.publish(&1, 20);
+        channels.publish(&2, 30);
+        assert_eq!(rx.try_recv().unwrap(), 20);
+        assert_eq!(rx.try_recv(), Err(TryRecvError::Empty));
+
+        channels.close(&1);
+        assert_eq!(rx.try_recv(), Err(TryRecvError::Closed));
+    }
+
+    #[test]
+    fn test_dropped_subscribers_release_channel() {
+        let channels: ProgressChannels<u32, u64> = ProgressChannels::default();
+        drop(channels.subscribe(1));
+        channels.publish(&1, 10);
+        assert!(channels.senders.lock().unwrap().is_empty());
+    }
+}
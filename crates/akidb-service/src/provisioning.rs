@@ -0,0 +1,213 @@
+//! Batch tenant provisioning, tenant listing, suspension and deletion.
+//!
+//! Onboarding creates hundreds of tenants at once. A batch creates each
+//! tenant on its own (a failed item does not fail the batch) with bounded
+//! concurrency, and reports the outcome of every item in request order.
+//!
+//! Batches are idempotent by slug: a tenant that already exists with the
+//! requested name and quotas is reported as `existing` rather than failing,
+//! so a batch interrupted half-way can simply be sent again. A slug taken by
+//! a different tenant fails that item.
+//!
+//! Tenants are listed server-side with a `TenantFilter` (status, metadata
+//! values, creation time), so finding e.g. every enterprise-tier tenant does
+//! not mean paging through all of them. See `CollectionService::list_tenants`.
+//!
+//! Suspending a tenant (e.g. a delinquent account) keeps its data but makes
+//! the nodes serving it reject its requests until it is resumed. Nodes
+//! re-read the suspension every `TENANT_SUSPENSION_REFRESH_INTERVAL`.
+//!
+//! Deleting a tenant is soft by default: the tenant is decommissioned and
+//! kept for a retention period (`DEFAULT_TENANT_RETENTION_DAYS`) during which
+//! it can be undeleted, so an accidental deprovisioning can be reverted.
+//! Tenants past their retention are purged in the background (see
+//! `CollectionService::spawn_tenant_purger`). Hard deletes remove the tenant
+//! at once.
+
+use akidb_core::{CoreError, CoreResult, TenantDescriptor, TenantId, TenantStatus};
+use chrono::{DateTime, Duration, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value};
+
+/// Maximum tenants per batch.
+pub const MAX_TENANT_BATCH: usize = 1_000;
+
+/// Tenants of a batch created concurrently.
+pub const TENANT_BATCH_CONCURRENCY: usize = 16;
+
+/// Days a soft-deleted tenant is kept when the request does not say.
+pub const DEFAULT_TENANT_RETENTION_DAYS: u32 = 30;
+
+/// Longest retention of a soft-deleted tenant.
+pub const MAX_TENANT_RETENTION_DAYS: u32 = 365;
+
+/// How often soft-deleted tenants past their retention are purged.
+pub const TENANT_PURGE_INTERVAL: std::time::Duration = std::time::Duration::from_secs(3600);
+
+/// How often a node re-reads whether the tenant it serves is suspended.
+pub const TENANT_SUSPENSION_REFRESH_INTERVAL: std::time::Duration =
+    std::time::Duration::from_secs(60);
+
+/// Outcome of one item of a batch.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TenantCreateStatus {
+    Created,
+    /// The tenant already existed with the same name and quotas.
+    Existing,
+    Failed,
+}
+
+/// Result of one item of a batch.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TenantCreateResult {
+    /// Position of the item in the request.
+    pub index: usize,
+    pub slug: String,
+    pub status: TenantCreateStatus,
+
+    /// The created or existing tenant.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub tenant_id: Option<TenantId>,
+
+    /// Why the item failed.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+impl TenantCreateResult {
+    pub fn succeeded(
+        index: usize,
+        slug: String,
+        status: TenantCreateStatus,
+        tenant_id: TenantId,
+    ) -> Self {
+        Self {
+            index,
+            slug,
+            status,
+            tenant_id: Some(tenant_id),
+            error: None,
+        }
+    }
+
+    pub fn failed(index: usize, slug: String, error: &CoreError) -> Self {
+        Self {
+            index,
+            slug,
+            status: TenantCreateStatus::Failed,
+            tenant_id: None,
+            error: Some(error.to_string()),
+        }
+    }
+}
+
+/// Outcome of a batch, with one result per item in request order.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BatchCreateTenantsReport {
+    pub created: usize,
+    pub existing: usize,
+    pub failed: usize,
+    pub results: Vec<TenantCreateResult>,
+}
+
+impl BatchCreateTenantsReport {
+    /// Builds the report from results in any order.
+    pub fn from_results(mut results: Vec<TenantCreateResult>) -> Self {
+        results.sort_by_key(|result| result.index);
+        let count = |status| results.iter().filter(|r| r.status == status).count();
+        Self {
+            created: count(TenantCreateStatus::Created),
+            existing: count(TenantCreateStatus::Existing),
+            failed: count(TenantCreateStatus::Failed),
+            results,
+        }
+    }
+}
+
+/// Which tenants a listing returns; every condition set must hold.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct TenantFilter {
+    #[serde(default)]
+    pub status: Option<TenantStatus>,
+
+    /// Top-level metadata fields and the values they must equal.
+    #[serde(default)]
+    pub metadata: Map<String, Value>,
+
+    /// Only tenants created strictly after this time.
+    #[serde(default)]
+    pub created_after: Option<DateTime<Utc>>,
+}
+
+impl TenantFilter {
+    /// Whether `tenant` passes the filter.
+    pub fn matches(&self, tenant: &TenantDescriptor) -> bool {
+        self.status.map_or(true, |status| tenant.status == status)
+            && self
+                .created_after
+                .map_or(true, |after| tenant.created_at > after)
+            && self
+                .metadata
+                .iter()
+                .all(|(key, value)| tenant.metadata.get(key) == Some(value))
+    }
+}
+
+/// How a tenant is deleted.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TenantDeleteMode {
+    /// Decommission the tenant and keep it until its retention ends.
+    #[default]
+    Soft,
+    /// Remove the tenant and its metadata at once.
+    Hard,
+}
+
+/// How to delete a tenant.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DeleteTenantOptions {
+    #[serde(default)]
+    pub mode: TenantDeleteMode,
+
+    /// Days a soft-deleted tenant is kept (default:
+    /// `DEFAULT_TENANT_RETENTION_DAYS`).
+    #[serde(default)]
+    pub retention_days: Option<u32>,
+}
+
+impl DeleteTenantOptions {
+    pub fn validate(&self) -> CoreResult<()> {
+        match (self.mode, self.retention_days) {
+            (TenantDeleteMode::Hard, Some(_)) => Err(CoreError::ValidationError(
+                "retention_days only applies to soft deletes".to_string(),
+            )),
+            (_, Some(days)) if days == 0 || days > MAX_TENANT_RETENTION_DAYS => {
+                Err(CoreError::ValidationError(format!(
+                    "retention_days must be between 1 and {} (got {})",
+                    MAX_TENANT_RETENTION_DAYS, days
+                )))
+            }
+            _ => Ok(()),
+        }
+    }
+
+    /// How long a soft-deleted tenant is kept.
+    pub fn retention(&self) -> Duration {
+        Duration::days(i64::from(
+            self.retention_days.unwrap_or(DEFAULT_TENANT_RETENTION_DAYS),
+        ))
+    }
+}
+
+/// Reject empty batches and those over `MAX_TENANT_BATCH` tenants.
+pub fn check_tenant_batch_size(size: usize) -> CoreResult<()> {
+    if size == 0 || size > MAX_TENANT_BATCH {
+        return Err(CoreError::ValidationError(format!(
+            "batch must contain between 1 and {} tenants (got {})",
+            MAX_TENANT_BATCH, size
+        )));
+    }
+    Ok(())
+}
@@ -0,0 +1,188 @@
+//! Recent query patterns, for priming caches after a restart.
+//!
+//! The service keeps, per collection, the last `QUERY_LOG_CAPACITY` distinct
+//! searches (query vector and top_k) with how often each was run. After a
+//! deploy or restore, replaying the most frequent ones (see
+//! `CollectionService::prime`) loads the tier and index pages they touch
+//! before user traffic arrives, instead of the first users paying for cold
+//! reads.
+//!
+//! The log is in memory and starts empty on a new process, so a client saves
+//! the patterns before a restart and passes them back to `prime` afterwards.
+
+use akidb_core::{CollectionId, CoreError, CoreResult};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::hash_map::DefaultHasher;
+use std::collections::HashMap;
+use std::hash::{Hash, Hasher};
+use std::sync::Mutex;
+
+/// Distinct query patterns kept per collection (least recent evicted first).
+pub const QUERY_LOG_CAPACITY: usize = 256;
+
+/// Maximum number of patterns fetched or replayed at once.
+pub const MAX_PRIME_QUERIES: usize = 1_000;
+
+/// A search run against a collection.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct QueryPattern {
+    pub query_vector: Vec<f32>,
+    pub top_k: usize,
+
+    /// Times the search was run while in the log.
+    #[serde(default)]
+    pub count: u64,
+
+    #[serde(default = "Utc::now")]
+    pub last_seen: DateTime<Utc>,
+}
+
+/// Outcome of replaying query patterns.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PrimeReport {
+    /// Patterns replayed.
+    pub queries: usize,
+
+    /// Patterns whose search failed (e.g. a vector of the wrong dimension).
+    pub failed: usize,
+
+    pub duration_ms: u64,
+}
+
+/// Checks the number of patterns to fetch or replay.
+pub fn validate_prime_limit(limit: usize) -> CoreResult<()> {
+    if limit == 0 || limit > MAX_PRIME_QUERIES {
+        return Err(CoreError::ValidationError(format!(
+            "limit must be between 1 and {} (got {})",
+            MAX_PRIME_QUERIES, limit
+        )));
+    }
+    Ok(())
+}
+
+/// Recent query patterns per collection.
+#[derive(Debug)]
+pub struct QueryLog {
+    capacity: usize,
+    patterns: Mutex<HashMap<CollectionId, HashMap<u64, QueryPattern>>>,
+}
+
+impl Default for QueryLog {
+    fn default() -> Self {
+        Self::new(QUERY_LOG_CAPACITY)
+    }
+}
+
+impl QueryLog {
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            capacity,
+            patterns: Mutex::new(HashMap::new()),
+        }
+    }
+
+    /// Records one search.
+    pub fn record(
+        &self,
+        collection_id: CollectionId,
+        query_vector: &[f32],
+        top_k: usize,
+        now: DateTime<Utc>,
+    ) {
+        let key = pattern_key(query_vector, top_k);
+        let mut all = self.patterns.lock().unwrap();
+        let patterns = all.entry(collection_id).or_default();
+        if let Some(pattern) = patterns.get_mut(&key) {
+            if pattern.top_k == top_k && pattern.query_vector == query_vector {
+                pattern.count += 1;
+                pattern.last_seen = now;
+                return;
+            }
+        }
+        if !patterns.contains_key(&key) && patterns.len() >= self.capacity {
+            let oldest = patterns
+                .iter()
+                .min_by_key(|(_, pattern)| pattern.last_seen)
+                .map(|(key, _)| *key);
+            if let Some(oldest) = oldest {
+                patterns.remove(&oldest);
+            }
+        }
+        patterns.insert(
+            key,
+            QueryPattern {
+                query_vector: query_vector.to_vec(),
+                top_k,
+                count: 1,
+                last_seen: now,
+            },
+        );
+    }
+
+    /// Up to `limit` patterns of a collection, most frequent first (most
+    /// recent first among equals).
+    pub fn top(&self, collection_id: CollectionId, limit: usize) -> Vec<QueryPattern> {
+        let all = self.patterns.lock().unwrap();
+        let mut patterns: Vec<QueryPattern> = all
+            .get(&collection_id)
+            .map(|patterns| patterns.values().cloned().collect())
+            .unwrap_or_default();
+        patterns.sort_by(|a, b| {
+            b.count
+                .cmp(&a.count)
+                .then_with(|| b.last_seen.cmp(&a.last_seen))
+        });
+        patterns.truncate(limit);
+        patterns
+    }
+
+    /// Drops the patterns of a deleted collection.
+    pub fn forget(&self, collection_id: CollectionId) {
+        self.patterns.lock().unwrap().remove(&collection_id);
+    }
+}
+
+fn pattern_key(query_vector: &[f32], top_k: usize) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    top_k.hash(&mut hasher);
+    for value in query_vector {
+        value.to_bits().hash(&mut hasher);
+    }
+    hasher.finish()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::Duration;
+
+    #[test]
+    fn test_query_log_ranks_and_evicts() {
+        let log = QueryLog::new(2);
+        let collection_id = CollectionId::new();
+        let start = Utc::now();
+        let at = |secs| start + Duration::seconds(secs);
+
+        log.record(collection_id, &[1.0, 0.0], 10, at(0));
+        log.record(collection_id, &[0.0, 1.0], 10, at(1));
+        log.record(collection_id, &[1.0, 0.0], 10, at(2));
+        let top = log.top(collection_id, 10);
+        assert_eq!(top.len(), 2);
+        assert_eq!(top[0].query_vector, vec![1.0, 0.0]);
+        assert_eq!(top[0].count, 2);
+        assert_eq!(top[0].last_seen, at(2));
+
+        // Same vector, other top_k: a new pattern evicting the least recent
+        log.record(collection_id, &[1.0, 0.0], 5, at(3));
+        let top = log.top(collection_id, 10);
+        assert_eq!(top.len(), 2);
+        assert!(top.iter().all(|p| p.query_vector == vec![1.0, 0.0]));
+        assert_eq!(log.top(collection_id, 1).len(), 1);
+
+        log.forget(collection_id);
+        assert!(log.top(collection_id, 10).is_empty());
+        assert!(validate_prime_limit(0).is_err());
+        assert!(validate_prime_limit(MAX_PRIME_QUERIES + 1).is_err());
+    }
+}
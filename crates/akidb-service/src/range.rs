@@ -0,0 +1,164 @@
+//! Range (radius) search.
+//!
+//! Instead of a fixed number of neighbours, a range search returns every
+//! document within a distance of the query: at most `max_distance` away in an
+//! L2 collection, or scoring at least `min_score` in a cosine or dot product
+//! collection. Near-duplicate detection and deduplication need "everything
+//! closer than X" rather than top-K. The index only answers k-NN queries, so
+//! the search widens k until a match falls outside the radius, up to `limit`
+//! matches. See `CollectionService::range_search`.
+
+use akidb_core::{CoreError, CoreResult, DistanceMetric, SearchResult};
+use serde::{Deserialize, Serialize};
+
+/// Default maximum matches of a range search.
+pub const DEFAULT_RANGE_LIMIT: usize = 1_000;
+
+/// Maximum `limit` of a range search.
+pub const MAX_RANGE_LIMIT: usize = 10_000;
+
+/// Neighbours fetched by the first round of a range search.
+pub const RANGE_INITIAL_K: usize = 64;
+
+fn default_limit() -> usize {
+    DEFAULT_RANGE_LIMIT
+}
+
+/// Radius of a range search (exactly one of `max_distance` and `min_score`).
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct RangeQuery {
+    /// Largest L2 distance of a match (L2 collections).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub max_distance: Option<f32>,
+
+    /// Smallest similarity of a match (cosine and dot product collections).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub min_score: Option<f32>,
+
+    /// Maximum matches returned (safety limit).
+    #[serde(default = "default_limit")]
+    pub limit: usize,
+}
+
+impl RangeQuery {
+    pub fn max_distance(max_distance: f32) -> Self {
+        Self {
+            max_distance: Some(max_distance),
+            min_score: None,
+            limit: DEFAULT_RANGE_LIMIT,
+        }
+    }
+
+    pub fn min_score(min_score: f32) -> Self {
+        Self {
+            max_distance: None,
+            min_score: Some(min_score),
+            limit: DEFAULT_RANGE_LIMIT,
+        }
+    }
+
+    pub fn with_limit(mut self, limit: usize) -> Self {
+        self.limit = limit;
+        self
+    }
+
+    /// Reject radii missing, not finite or of the wrong kind for `metric`.
+    pub fn validate(&self, metric: DistanceMetric) -> CoreResult<()> {
+        if self.limit == 0 || self.limit > MAX_RANGE_LIMIT {
+            return Err(CoreError::ValidationError(format!(
+                "range limit must be between 1 and {} (got {})",
+                MAX_RANGE_LIMIT, self.limit
+            )));
+        }
+        match (self.max_distance, self.min_score) {
+            (Some(_), Some(_)) | (None, None) => Err(CoreError::ValidationError(
+                "set exactly one of max_distance and min_score".to_string(),
+            )),
+            (Some(max_distance), None) => {
+                if !(max_distance.is_finite() && max_distance >= 0.0) {
+                    return Err(CoreError::ValidationError(
+                        "max_distance must be a finite number of at least 0".to_string(),
+                    ));
+                }
+                if metric != DistanceMetric::L2 {
+                    return Err(CoreError::ValidationError(format!(
+                        "max_distance applies to L2 collections; use min_score for {} collections",
+                        metric.as_str()
+                    )));
+                }
+                Ok(())
+            }
+            (None, Some(min_score)) => {
+                if !min_score.is_finite() {
+                    return Err(CoreError::ValidationError(
+                        "min_score must be a finite number".to_string(),
+                    ));
+                }
+                if metric == DistanceMetric::L2 {
+                    return Err(CoreError::ValidationError(
+                        "min_score applies to cosine and dot product collections; use \
+                         max_distance for L2 collections"
+                            .to_string(),
+                    ));
+                }
+                Ok(())
+            }
+        }
+    }
+
+    /// Whether a match with `score` lies within the radius.
+    pub fn contains(&self, score: f32) -> bool {
+        match (self.max_distance, self.min_score) {
+            (Some(max_distance), _) => score <= max_distance,
+            (None, Some(min_score)) => score >= min_score,
+            (None, None) => false,
+        }
+    }
+}
+
+/// Matches of a range search.
+#[derive(Debug, Clone, Default)]
+pub struct RangeSearchResult {
+    /// Matches within the radius, best first.
+    pub results: Vec<SearchResult>,
+
+    /// Set when `limit` cut off further matches within the radius.
+    pub truncated: bool,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_range_query_validation() {
+        let l2 = RangeQuery::max_distance(0.5);
+        l2.validate(DistanceMetric::L2).unwrap();
+        assert!(l2.validate(DistanceMetric::Cosine).is_err());
+        assert!(l2.contains(0.5));
+        assert!(!l2.contains(0.51));
+
+        let cosine = RangeQuery::min_score(0.9);
+        cosine.validate(DistanceMetric::Cosine).unwrap();
+        assert!(cosine.validate(DistanceMetric::L2).is_err());
+        assert!(cosine.contains(0.95));
+        assert!(!cosine.contains(0.8));
+
+        assert!(RangeQuery::max_distance(-1.0)
+            .validate(DistanceMetric::L2)
+            .is_err());
+        assert!(RangeQuery::min_score(f32::NAN)
+            .validate(DistanceMetric::Dot)
+            .is_err());
+        assert!(l2
+            .clone()
+            .with_limit(MAX_RANGE_LIMIT + 1)
+            .validate(DistanceMetric::L2)
+            .is_err());
+        let both = RangeQuery {
+            min_score: Some(0.9),
+            ..l2
+        };
+        assert!(both.validate(DistanceMetric::L2).is_err());
+    }
+}
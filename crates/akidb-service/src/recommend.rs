@@ -0,0 +1,134 @@
+//! Recommendations by example.
+//!
+//! "More like these, less like those": the query vector is built server-side
+//! from stored documents, so clients recommend by ID without fetching
+//! embeddings. With `P` the mean of the positive examples' vectors and `N`
+//! the mean of the negative ones, the query is `P + (P - N)` (just `P`
+//! without negatives): it moves from the negatives' centroid past the
+//! positives' one. Examples themselves are never recommended.
+//! See `CollectionService::recommend`.
+
+use akidb_core::{CoreError, CoreResult, DocumentId};
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+
+use crate::composition::{QueryComposition, QueryTerm};
+use crate::filter::MetadataFilter;
+
+/// Maximum positive plus negative examples of one request.
+pub const MAX_RECOMMEND_EXAMPLES: usize = 64;
+
+/// A recommendation request.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct RecommendRequest {
+    /// Documents to find more of (at least one).
+    pub positive_ids: Vec<DocumentId>,
+
+    /// Documents to steer away from.
+    #[serde(default)]
+    pub negative_ids: Vec<DocumentId>,
+
+    pub top_k: usize,
+
+    /// Metadata recommended documents must match.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub filter: Option<MetadataFilter>,
+}
+
+impl RecommendRequest {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.positive_ids.is_empty() {
+            return Err(CoreError::ValidationError(
+                "positive_ids must name at least one document".to_string(),
+            ));
+        }
+        let examples = self.positive_ids.len() + self.negative_ids.len();
+        if examples > MAX_RECOMMEND_EXAMPLES {
+            return Err(CoreError::ValidationError(format!(
+                "at most {} positive and negative examples are allowed (got {})",
+                MAX_RECOMMEND_EXAMPLES, examples
+            )));
+        }
+        let positives: HashSet<_> = self.positive_ids.iter().collect();
+        if let Some(doc_id) = self.negative_ids.iter().find(|id| positives.contains(id)) {
+            return Err(CoreError::ValidationError(format!(
+                "document {} is both a positive and a negative example",
+                doc_id
+            )));
+        }
+        if let Some(filter) = &self.filter {
+            filter.validate()?;
+        }
+        Ok(())
+    }
+
+    /// IDs of all examples, excluded from the results.
+    pub fn examples(&self) -> HashSet<DocumentId> {
+        self.positive_ids
+            .iter()
+            .chain(&self.negative_ids)
+            .copied()
+            .collect()
+    }
+
+    /// The query vector recipe: `2P - N` with negatives, `P` without.
+    pub fn composition(&self) -> QueryComposition {
+        let positive_weight = if self.negative_ids.is_empty() {
+            1.0
+        } else {
+            2.0
+        };
+        let positives = self.positive_ids.iter().map(|&doc_id| QueryTerm {
+            doc_id,
+            weight: positive_weight / self.positive_ids.len() as f32,
+        });
+        let negatives = self.negative_ids.iter().map(|&doc_id| QueryTerm {
+            doc_id,
+            weight: -1.0 / self.negative_ids.len() as f32,
+        });
+        QueryComposition {
+            terms: positives.chain(negatives).collect(),
+            literal: None,
+            normalize: false,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_recommend_composition() {
+        let (a, b, c) = (DocumentId::new(), DocumentId::new(), DocumentId::new());
+        let request = RecommendRequest {
+            positive_ids: vec![a, b],
+            negative_ids: vec![c],
+            top_k: 10,
+            filter: None,
+        };
+        request.validate().unwrap();
+        let vectors = vec![vec![1.0, 0.0], vec![0.0, 1.0], vec![1.0, 0.0]];
+        // 2 * mean([1, 0], [0, 1]) - [1, 0]
+        let query = request.composition().compose(&vectors, 2).unwrap();
+        assert_eq!(query, vec![0.0, 1.0]);
+        assert_eq!(request.examples().len(), 3);
+
+        let positives_only = RecommendRequest {
+            negative_ids: Vec::new(),
+            ..request.clone()
+        };
+        let query = positives_only
+            .composition()
+            .compose(&vectors[..2], 2)
+            .unwrap();
+        assert_eq!(query, vec![0.5, 0.5]);
+
+        let conflicting = RecommendRequest {
+            negative_ids: vec![a],
+            ..request.clone()
+        };
+        assert!(conflicting.validate().is_err());
+        assert!(RecommendRequest::default().validate().is_err());
+    }
+}
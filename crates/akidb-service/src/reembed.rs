@@ -0,0 +1,460 @@
+//! Re-embedding migration for embedding model upgrades.
+//!
+//! [`Reembed`] reads the source text stored in a metadata field of every
+//! document, embeds it with a new provider/model and writes the result into a
+//! target collection under the same document IDs (with the source metadata).
+//! Batches are embedded concurrently, a cost estimate is available before
+//! starting, and a checkpoint is reported after every wave of batches so an
+//! interrupted migration can resume where it stopped.
+//!
+//! Pair with `CollectionService::set_alias` (or `reindex`) to switch readers
+//! to the target once the migration completes.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, VectorDocument};
+use akidb_embedding::{BatchEmbeddingRequest, EmbeddingProvider};
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+use tokio::task::JoinSet;
+
+use crate::collection_service::CollectionService;
+use crate::sparse::DEFAULT_TEXT_FIELD;
+
+/// Rough characters-per-token ratio used for cost estimates.
+const CHARS_PER_TOKEN: usize = 4;
+
+/// Checkpoint callback, invoked after each wave of batches is written.
+pub type ReembedCheckpointFn = Arc<dyn Fn(&ReembedCheckpoint) + Send + Sync>;
+
+/// Re-embedding settings.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReembedConfig {
+    /// Collection holding the documents and their source text.
+    pub source: CollectionId,
+
+    /// Collection receiving the new embeddings (dimension must match the model).
+    pub target: CollectionId,
+
+    /// Model name passed to the provider.
+    pub model: String,
+
+    /// Metadata field holding the text to embed (default: "text").
+    pub text_field: String,
+
+    /// Texts per embedding request.
+    pub batch_size: usize,
+
+    /// Embedding requests in flight at once.
+    pub concurrency: usize,
+
+    /// Provider price per million tokens, for cost estimates.
+    pub cost_per_million_tokens: Option<f64>,
+}
+
+impl ReembedConfig {
+    /// Creates a config with 32-text batches and 4 concurrent requests.
+    pub fn new(source: CollectionId, target: CollectionId, model: impl Into<String>) -> Self {
+        Self {
+            source,
+            target,
+            model: model.into(),
+            text_field: DEFAULT_TEXT_FIELD.to_string(),
+            batch_size: 32,
+            concurrency: 4,
+            cost_per_million_tokens: None,
+        }
+    }
+
+    pub fn with_text_field(mut self, text_field: impl Into<String>) -> Self {
+        self.text_field = text_field.into();
+        self
+    }
+
+    pub fn with_batch_size(mut self, batch_size: usize) -> Self {
+        self.batch_size = batch_size;
+        self
+    }
+
+    pub fn with_concurrency(mut self, concurrency: usize) -> Self {
+        self.concurrency = concurrency;
+        self
+    }
+
+    pub fn with_cost_per_million_tokens(mut self, cost: f64) -> Self {
+        self.cost_per_million_tokens = Some(cost);
+        self
+    }
+
+    fn validate(&self) -> CoreResult<()> {
+        if self.batch_size == 0 || self.concurrency == 0 {
+            return Err(CoreError::ValidationError(
+                "batch_size and concurrency must be greater than 0".to_string(),
+            ));
+        }
+        if self.source == self.target {
+            return Err(CoreError::ValidationError(
+                "re-embedding source and target must be different collections".to_string(),
+            ));
+        }
+        Ok(())
+    }
+}
+
+/// Pre-flight estimate of a re-embedding run.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReembedEstimate {
+    /// Documents that still need embedding (after the checkpoint, not in the target).
+    pub documents: usize,
+
+    /// Of those, documents without text in the configured field (will be skipped).
+    pub missing_text: usize,
+
+    /// Embedding requests needed (approximate).
+    pub batches: usize,
+
+    /// Estimated tokens (~4 characters per token).
+    pub estimated_tokens: u64,
+
+    /// Estimated cost, if a price was configured.
+    pub estimated_cost: Option<f64>,
+}
+
+/// Resume point for an interrupted run. Serializable so callers can persist it.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct ReembedCheckpoint {
+    /// Every document up to this ID (in ID order) has been processed.
+    pub last_doc_id: Option<DocumentId>,
+
+    pub embedded: usize,
+    pub skipped: usize,
+
+    /// Tokens reported by the provider so far.
+    pub tokens: u64,
+}
+
+/// Outcome of a re-embedding run.
+#[derive(Debug, Clone)]
+pub struct ReembedReport {
+    /// Documents written to the target (including earlier runs when resumed).
+    pub embedded: usize,
+
+    /// Documents skipped for missing text.
+    pub skipped: usize,
+
+    pub tokens: u64,
+    pub checkpoint: ReembedCheckpoint,
+    pub duration: Duration,
+}
+
+/// Re-embeds a collection's stored text into a target collection.
+pub struct Reembed {
+    service: Arc<CollectionService>,
+    provider: Arc<dyn EmbeddingProvider + Send + Sync>,
+    config: ReembedConfig,
+    checkpoint: ReembedCheckpoint,
+    on_checkpoint: Option<ReembedCheckpointFn>,
+}
+
+impl Reembed {
+    pub fn new(
+        service: Arc<CollectionService>,
+        provider: Arc<dyn EmbeddingProvider + Send + Sync>,
+        config: ReembedConfig,
+    ) -> Self {
+        Self {
+            service,
+            provider,
+            config,
+            checkpoint: ReembedCheckpoint::default(),
+            on_checkpoint: None,
+        }
+    }
+
+    /// Resumes from a checkpoint saved by an earlier run.
+    pub fn resume_from(mut self, checkpoint: ReembedCheckpoint) -> Self {
+        self.checkpoint = checkpoint;
+        self
+    }
+
+    /// Sets a callback receiving a checkpoint after each wave of batches.
+    pub fn on_checkpoint<F>(mut self, callback: F) -> Self
+    where
+        F: Fn(&ReembedCheckpoint) + Send + Sync + 'static,
+    {
+        self.on_checkpoint = Some(Arc::new(callback));
+        self
+    }
+
+    /// Estimates tokens, requests and cost for the remaining work.
+    pub async fn estimate(&self) -> CoreResult<ReembedEstimate> {
+        self.config.validate()?;
+        let pending = self.pending().await?;
+
+        let mut missing_text = 0;
+        let mut chars = 0;
+        for (_, text) in &pending {
+            match text {
+                Some(text) => chars += text.chars().count(),
+                None => missing_text += 1,
+            }
+        }
+        let with_text = pending.len() - missing_text;
+        let estimated_tokens = chars.div_ceil(CHARS_PER_TOKEN) as u64;
+
+        Ok(ReembedEstimate {
+            documents: pending.len(),
+            missing_text,
+            batches: with_text.div_ceil(self.config.batch_size),
+            estimated_tokens,
+            estimated_cost: self
+                .config
+                .cost_per_million_tokens
+                .map(|price| estimated_tokens as f64 / 1_000_000.0 * price),
+        })
+    }
+
+    /// Runs (or resumes) the migration.
+    ///
+    /// Documents already present in the target are skipped, so re-running
+    /// without a checkpoint is also safe. An embedding error stops the run;
+    /// the last reported checkpoint marks where to resume.
+    pub async fn run(&self) -> CoreResult<ReembedReport> {
+        let start = Instant::now();
+        self.config.validate()?;
+
+        let target = self.service.get_collection(self.config.target).await?;
+        let model = self
+            .provider
+            .model_info()
+            .await
+            .map_err(|e| CoreError::internal(format!("failed to get model info: {}", e)))?;
+        if model.dimension != target.dimension {
+            return Err(CoreError::ValidationError(format!(
+                "model '{}' produces {}-dimensional vectors but target collection expects {}",
+                self.config.model, model.dimension, target.dimension
+            )));
+        }
+
+        let pending = self.pending().await?;
+        let mut checkpoint = self.checkpoint.clone();
+        let wave_size = self.config.batch_size * self.config.concurrency;
+
+        for wave in pending.chunks(wave_size) {
+            let mut tasks = JoinSet::new();
+            for (index, batch) in wave.chunks(self.config.batch_size).enumerate() {
+                let texts: Vec<String> = batch.iter().filter_map(|(_, t)| t.clone()).collect();
+                if texts.is_empty() {
+                    continue;
+                }
+                let provider = Arc::clone(&self.provider);
+                let request = BatchEmbeddingRequest {
+                    model: self.config.model.clone(),
+                    inputs: texts,
+                    normalize: true,
+                };
+                tasks.spawn(async move { (index, provider.embed_batch(request).await) });
+            }
+
+            let mut results = Vec::new();
+            while let Some(joined) = tasks.join_next().await {
+                let (index, response) = joined
+                    .map_err(|e| CoreError::internal(format!("embedding task failed: {}", e)))?;
+                let response = response
+                    .map_err(|e| CoreError::internal(format!("embedding failed: {}", e)))?;
+                checkpoint.tokens += response.usage.total_tokens as u64;
+                results.push((index, response.embeddings));
+            }
+            results.sort_by_key(|(index, _)| *index);
+
+            let mut embeddings = results.into_iter().flat_map(|(_, embeddings)| embeddings);
+            let mut docs = Vec::new();
+            for (source, text) in wave {
+                if text.is_none() {
+                    checkpoint.skipped += 1;
+                    continue;
+                }
+                let vector = embeddings.next().ok_or_else(|| {
+                    CoreError::internal("provider returned fewer embeddings than inputs")
+                })?;
+                let mut doc = VectorDocument::new(source.doc_id, vector);
+                doc.external_id = source.external_id.clone();
+                doc.metadata = source.metadata.clone();
+                docs.push(doc);
+            }
+
+            for doc in docs {
+                self.service.insert(self.config.target, doc).await?;
+                checkpoint.embedded += 1;
+            }
+            checkpoint.last_doc_id = wave.last().map(|(doc, _)| doc.doc_id);
+            if let Some(callback) = &self.on_checkpoint {
+                callback(&checkpoint);
+            }
+        }
+
+        tracing::info!(
+            "Re-embedded collection {} into {} with model '{}' ({} embedded, {} skipped, {} tokens)",
+            self.config.source,
+            self.config.target,
+            self.config.model,
+            checkpoint.embedded,
+            checkpoint.skipped,
+            checkpoint.tokens
+        );
+
+        Ok(ReembedReport {
+            embedded: checkpoint.embedded,
+            skipped: checkpoint.skipped,
+            tokens: checkpoint.tokens,
+            checkpoint,
+            duration: start.elapsed(),
+        })
+    }
+
+    /// Source documents after the checkpoint that are not yet in the target,
+    /// in ID order, paired with their text.
+    async fn pending(&self) -> CoreResult<Vec<(VectorDocument, Option<String>)>> {
+        let present: HashSet<DocumentId> = self
+            .service
+            .list_documents(self.config.target)
+            .await?
+            .into_iter()
+            .map(|doc| doc.doc_id)
+            .collect();
+        let mut docs = self.service.list_documents(self.config.source).await?;
+        docs.sort_by_key(|doc| doc.doc_id.as_uuid());
+
+        let after = self.checkpoint.last_doc_id.map(|id| id.as_uuid());
+        Ok(docs
+            .into_iter()
+            .filter(|doc| after.map_or(true, |a| doc.doc_id.as_uuid() > a))
+            .filter(|doc| !present.contains(&doc.doc_id))
+            .map(|doc| {
+                let text = doc
+                    .metadata
+                    .as_ref()
+                    .and_then(|m| m.get(&self.config.text_field))
+                    .and_then(|v| v.as_str())
+                    .map(str::to_string);
+                (doc, text)
+            })
+            .collect())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DistanceMetric;
+    use akidb_embedding::MockEmbeddingProvider;
+    use serde_json::json;
+    use std::sync::Mutex;
+
+    const MODEL: &str = "mock-embed-v2";
+
+    async fn setup(docs: usize) -> (Arc<CollectionService>, CollectionId, CollectionId) {
+        let service = Arc::new(CollectionService::new());
+        let source = service
+            .create_collection("reembed-src".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let target = service
+            .create_collection("reembed-dst".to_string(), 32, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        for i in 0..docs {
+            // Every third document has no text
+            let metadata = if i % 3 == 2 {
+                json!({ "id": i })
+            } else {
+                json!({ "id": i, "text": format!("document number {}", i) })
+            };
+            let doc = VectorDocument::new(DocumentId::new(), vec![1.0; 16]).with_metadata(metadata);
+            service.insert(source, doc).await.unwrap();
+        }
+        (service, source, target)
+    }
+
+    fn provider() -> Arc<dyn EmbeddingProvider + Send + Sync> {
+        Arc::new(MockEmbeddingProvider::with_model(MODEL, 32))
+    }
+
+    #[tokio::test]
+    async fn test_estimate_and_run() {
+        let (service, source, target) = setup(6).await;
+        let config = ReembedConfig::new(source, target, MODEL)
+            .with_batch_size(2)
+            .with_concurrency(2)
+            .with_cost_per_million_tokens(0.02);
+        let reembed = Reembed::new(Arc::clone(&service), provider(), config);
+
+        let estimate = reembed.estimate().await.unwrap();
+        assert_eq!((estimate.documents, estimate.missing_text), (6, 2));
+        assert_eq!(estimate.batches, 2);
+        assert!(estimate.estimated_tokens > 0);
+        assert!(estimate.estimated_cost.unwrap() > 0.0);
+
+        let report = reembed.run().await.unwrap();
+        assert_eq!((report.embedded, report.skipped), (4, 2));
+        assert!(report.tokens > 0);
+        assert_eq!(service.get_count(target).await.unwrap(), 4);
+
+        // Nothing left to do on a second run
+        assert_eq!(reembed.estimate().await.unwrap().documents, 2);
+        assert_eq!(reembed.run().await.unwrap().embedded, 0);
+    }
+
+    #[tokio::test]
+    async fn test_resume_from_checkpoint() {
+        let (service, source, target) = setup(9).await;
+        let config = ReembedConfig::new(source, target, MODEL)
+            .with_batch_size(3)
+            .with_concurrency(1);
+
+        let checkpoints = Arc::new(Mutex::new(Vec::new()));
+        let log = Arc::clone(&checkpoints);
+        Reembed::new(Arc::clone(&service), provider(), config.clone())
+            .on_checkpoint(move |c| log.lock().unwrap().push(c.clone()))
+            .run()
+            .await
+            .unwrap();
+        assert_eq!(checkpoints.lock().unwrap().len(), 3);
+
+        // Pretend the run stopped after the first wave
+        let first = checkpoints.lock().unwrap()[0].clone();
+        assert_eq!((first.embedded, first.skipped), (2, 1));
+        let fresh = service
+            .create_collection("reembed-dst2".to_string(), 32, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        let mut resumed_config = config;
+        resumed_config.target = fresh;
+        let report = Reembed::new(Arc::clone(&service), provider(), resumed_config)
+            .resume_from(first)
+            .run()
+            .await
+            .unwrap();
+
+        assert_eq!((report.embedded, report.skipped), (6, 3));
+        // Only the documents after the checkpoint were written
+        assert_eq!(service.get_count(fresh).await.unwrap(), 4);
+    }
+
+    #[tokio::test]
+    async fn test_dimension_mismatch() {
+        let (service, source, _) = setup(1).await;
+        let small = service
+            .create_collection(
+                "reembed-small".to_string(),
+                16,
+                DistanceMetric::Cosine,
+                None,
+            )
+            .await
+            .unwrap();
+        let config = ReembedConfig::new(source, small, MODEL);
+        let err = Reembed::new(service, provider(), config).run().await;
+        assert!(matches!(err, Err(CoreError::ValidationError(_))));
+    }
+}
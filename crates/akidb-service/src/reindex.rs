@@ -0,0 +1,168 @@
+//! Alias-based zero-downtime reindexing.
+//!
+//! A reindex copies a collection into a new shadow collection (optionally
+//! transforming each document and changing dimension, metric or embedding
+//! model), validates the document counts, then atomically points an alias at
+//! the shadow. Readers that address the collection through the alias switch
+//! over without downtime. See `CollectionService::reindex`.
+
+use akidb_core::{CollectionId, CoreResult, DistanceMetric, VectorDocument};
+use std::sync::Arc;
+use std::time::Duration;
+
+use crate::transforms::TransformSpec;
+
+/// Per-document transform applied while copying.
+///
+/// Returning `Ok(None)` skips the document; an error aborts the reindex.
+pub type DocumentTransform =
+    Arc<dyn Fn(VectorDocument) -> CoreResult<Option<VectorDocument>> + Send + Sync>;
+
+/// Progress callback invoked periodically during the copy.
+pub type ReindexProgressFn = Arc<dyn Fn(ReindexProgress) + Send + Sync>;
+
+/// Number of documents copied between progress callbacks.
+pub const REINDEX_PROGRESS_INTERVAL: usize = 1000;
+
+/// Copy progress reported to [`ReindexPlan::on_progress`] callbacks.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct ReindexProgress {
+    /// Documents written to the shadow collection so far.
+    pub copied: usize,
+
+    /// Documents dropped by the transform so far.
+    pub skipped: usize,
+
+    /// Documents in the source collection when the reindex started.
+    pub total: usize,
+}
+
+/// Describes a reindex: source, alias to swap, and the new collection's settings.
+#[derive(Clone)]
+pub struct ReindexPlan {
+    /// Alias to point at the new collection. Must be unset or currently point
+    /// at `source`.
+    pub alias: String,
+
+    /// Collection to copy from.
+    pub source: CollectionId,
+
+    /// Name of the shadow collection.
+    pub target_name: String,
+
+    /// New vector dimension (defaults to the source's). A transform must
+    /// produce vectors of this dimension.
+    pub dimension: Option<u32>,
+
+    /// New distance metric (defaults to the source's).
+    pub metric: Option<DistanceMetric>,
+
+    /// New embedding model (defaults to the source's).
+    pub embedding_model: Option<String>,
+
+    pub transform: Option<DocumentTransform>,
+
+    pub progress: Option<ReindexProgressFn>,
+
+    /// Delete the source collection after the alias swap.
+    pub delete_source: bool,
+}
+
+impl ReindexPlan {
+    /// Creates a plan copying `source` unchanged into `target_name`.
+    pub fn new(
+        alias: impl Into<String>,
+        source: CollectionId,
+        target_name: impl Into<String>,
+    ) -> Self {
+        Self {
+            alias: alias.into(),
+            source,
+            target_name: target_name.into(),
+            dimension: None,
+            metric: None,
+            embedding_model: None,
+            transform: None,
+            progress: None,
+            delete_source: false,
+        }
+    }
+
+    pub fn with_dimension(mut self, dimension: u32) -> Self {
+        self.dimension = Some(dimension);
+        self
+    }
+
+    pub fn with_metric(mut self, metric: DistanceMetric) -> Self {
+        self.metric = Some(metric);
+        self
+    }
+
+    pub fn with_embedding_model(mut self, embedding_model: impl Into<String>) -> Self {
+        self.embedding_model = Some(embedding_model.into());
+        self
+    }
+
+    /// Sets the per-document transform.
+    pub fn with_transform<F>(mut self, transform: F) -> Self
+    where
+        F: Fn(VectorDocument) -> CoreResult<Option<VectorDocument>> + Send + Sync + 'static,
+    {
+        self.transform = Some(Arc::new(transform));
+        self
+    }
+
+    /// Sets declarative transforms (see [`TransformSpec`]).
+    ///
+    /// If the spec truncates vectors and no dimension was set, the new
+    /// collection uses the truncated dimension.
+    pub fn with_transforms(mut self, spec: TransformSpec) -> Self {
+        if self.dimension.is_none() {
+            self.dimension = spec.output_dimension();
+        }
+        self.transform = Some(spec.into_document_transform());
+        self
+    }
+
+    /// Sets a callback receiving copy progress.
+    pub fn on_progress<F>(mut self, progress: F) -> Self
+    where
+        F: Fn(ReindexProgress) + Send + Sync + 'static,
+    {
+        self.progress = Some(Arc::new(progress));
+        self
+    }
+
+    /// Deletes the source collection once the alias points at the new one.
+    pub fn delete_source_after_swap(mut self) -> Self {
+        self.delete_source = true;
+        self
+    }
+}
+
+impl std::fmt::Debug for ReindexPlan {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("ReindexPlan")
+            .field("alias", &self.alias)
+            .field("source", &self.source)
+            .field("target_name", &self.target_name)
+            .field("dimension", &self.dimension)
+            .field("metric", &self.metric)
+            .field("embedding_model", &self.embedding_model)
+            .field("transform", &self.transform.is_some())
+            .field("delete_source", &self.delete_source)
+            .finish()
+    }
+}
+
+/// Outcome of a completed reindex.
+#[derive(Debug, Clone)]
+pub struct ReindexReport {
+    pub alias: String,
+    pub source: CollectionId,
+    pub target: CollectionId,
+    pub copied: usize,
+    pub skipped: usize,
+    pub source_deleted: bool,
+    pub duration: Duration,
+}
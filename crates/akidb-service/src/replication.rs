@@ -0,0 +1,654 @@
+//! Cross-region asynchronous replication.
+//!
+//! A collection with a replication target queues its writes (inserts,
+//! replacements and deletes) once they are stored locally, and the replicator
+//! ships the queue, oldest first and in batches, to a collection in another
+//! region's cluster through a [`ReplicationTransport`]. Local writes never
+//! wait for the target, so it lags behind; the status reports the lag against
+//! the target's SLO so operators can alert on it.
+//!
+//! A new target first receives a full copy of the collection. So does a
+//! target whose queue overflowed (`MAX_REPLICATION_QUEUE` pending writes, e.g.
+//! during a long outage); a full copy only upserts, so documents deleted in
+//! the meantime stay on the target. Writes applied from another region are
+//! not queued again, so two regions can replicate to each other. Sparse and
+//! named vectors are not replicated.
+//!
+//! Writes are shipped over HTTP with JSON bodies by default. Targets
+//! configured with the `grpc` protocol receive them over gRPC instead, with
+//! vectors as packed float32 arrays, which is cheaper to encode and decode
+//! for bulk copies. Each protocol has its own transport.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, VectorDocument};
+use async_trait::async_trait;
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, VecDeque};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+tokio::task_local! {
+    // Set while applying writes replicated from another region
+    static REPLICATED_WRITE: ();
+}
+
+/// Run `f` as writes replicated from another region, which are not queued
+/// for this region's targets.
+pub(crate) async fn as_replicated_writes<F: std::future::Future>(f: F) -> F::Output {
+    REPLICATED_WRITE.scope((), f).await
+}
+
+/// Writes shipped per request to the target.
+pub const REPLICATION_BATCH_SIZE: usize = 500;
+
+/// Pending writes per target before the queue is dropped in favor of a full
+/// copy.
+pub const MAX_REPLICATION_QUEUE: usize = 100_000;
+
+/// Maximum size of a shipped batch as received by the target.
+pub const MAX_REPLICATION_BODY_BYTES: usize = 64 * 1024 * 1024;
+
+/// Default replication lag SLO in seconds.
+pub const DEFAULT_LAG_SLO_SECS: u64 = 60;
+
+/// How often the replicator ships pending writes.
+pub const REPLICATION_TICK: Duration = Duration::from_secs(1);
+
+fn default_lag_slo_secs() -> u64 {
+    DEFAULT_LAG_SLO_SECS
+}
+
+/// How writes are shipped to a target.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ReplicationProtocol {
+    /// JSON over HTTP (`POST /api/v1/collections/{id}/replication/apply`).
+    #[default]
+    Http,
+    /// Protobuf over gRPC (`CollectionService/ApplyReplication`).
+    Grpc,
+}
+
+impl ReplicationProtocol {
+    pub const fn as_str(&self) -> &'static str {
+        match self {
+            Self::Http => "http",
+            Self::Grpc => "grpc",
+        }
+    }
+}
+
+/// Connection pool and socket settings of the HTTP transport's client.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ReplicationClientConfig {
+    /// Idle connections kept open per target (default: 8).
+    #[serde(default = "default_max_idle_per_host")]
+    pub max_idle_per_host: usize,
+
+    /// Seconds an idle connection is kept open (default: 90).
+    #[serde(default = "default_idle_timeout_secs")]
+    pub idle_timeout_secs: u64,
+
+    /// TCP keep-alive interval in seconds, 0 to disable (default: 60).
+    #[serde(default = "default_tcp_keepalive_secs")]
+    pub tcp_keepalive_secs: u64,
+
+    /// Connect timeout in seconds (default: 10).
+    #[serde(default = "default_connect_timeout_secs")]
+    pub connect_timeout_secs: u64,
+
+    /// Speak HTTP/2 to targets without upgrade negotiation (default: false).
+    #[serde(default)]
+    pub http2_only: bool,
+}
+
+fn default_max_idle_per_host() -> usize {
+    8
+}
+
+fn default_idle_timeout_secs() -> u64 {
+    90
+}
+
+fn default_tcp_keepalive_secs() -> u64 {
+    60
+}
+
+fn default_connect_timeout_secs() -> u64 {
+    10
+}
+
+impl Default for ReplicationClientConfig {
+    fn default() -> Self {
+        Self {
+            max_idle_per_host: default_max_idle_per_host(),
+            idle_timeout_secs: default_idle_timeout_secs(),
+            tcp_keepalive_secs: default_tcp_keepalive_secs(),
+            connect_timeout_secs: default_connect_timeout_secs(),
+            http2_only: false,
+        }
+    }
+}
+
+impl ReplicationClientConfig {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.connect_timeout_secs == 0 {
+            return Err(CoreError::ValidationError(
+                "replication_client connect_timeout_secs must be at least 1".to_string(),
+            ));
+        }
+        Ok(())
+    }
+}
+
+/// Replication target of a collection.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ReplicationConfig {
+    /// Region of the target cluster, e.g. "eu-west-1".
+    pub target_region: String,
+
+    /// Base URL of the target cluster's API, e.g.
+    /// "http://akidb.eu-west-1.internal:8080" (its gRPC server for the
+    /// `grpc` protocol).
+    pub target_endpoint: String,
+
+    /// How writes are shipped (default: http).
+    #[serde(default)]
+    pub protocol: ReplicationProtocol,
+
+    /// Collection on the target (default: the source collection's ID).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub target_collection_id: Option<CollectionId>,
+
+    /// Lag above which the target counts as lagging (default: 60).
+    #[serde(default = "default_lag_slo_secs")]
+    pub lag_slo_secs: u64,
+
+    /// Stop shipping writes; they keep queueing.
+    #[serde(default)]
+    pub paused: bool,
+}
+
+impl ReplicationConfig {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.target_region.trim().is_empty() {
+            return Err(CoreError::ValidationError(
+                "target_region cannot be empty".to_string(),
+            ));
+        }
+        let endpoint = self.target_endpoint.as_str();
+        if !(endpoint.starts_with("http://") || endpoint.starts_with("https://")) {
+            return Err(CoreError::ValidationError(format!(
+                "target_endpoint must be an http:// or https:// URL (got '{}')",
+                endpoint
+            )));
+        }
+        if self.lag_slo_secs == 0 {
+            return Err(CoreError::ValidationError(
+                "lag_slo_secs must be greater than 0".to_string(),
+            ));
+        }
+        Ok(())
+    }
+
+    /// Whether both configs ship to the same collection.
+    fn same_target(&self, other: &Self) -> bool {
+        self.target_endpoint == other.target_endpoint
+            && self.target_collection_id == other.target_collection_id
+    }
+}
+
+/// A write shipped to the target.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(tag = "op", rename_all = "snake_case")]
+pub enum ReplicationOp {
+    Upsert { document: VectorDocument },
+    Delete { doc_id: DocumentId },
+}
+
+/// Ships writes to another cluster.
+#[async_trait]
+pub trait ReplicationTransport: Send + Sync {
+    /// Apply `ops`, in order, to collection `collection_id` of the cluster
+    /// at `endpoint`.
+    async fn ship(
+        &self,
+        endpoint: &str,
+        collection_id: CollectionId,
+        ops: &[ReplicationOp],
+    ) -> CoreResult<()>;
+}
+
+/// Replication health of a collection.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ReplicationHealth {
+    /// Lag within the SLO.
+    Healthy,
+    /// Lag above the SLO.
+    Lagging,
+    /// The last shipment failed.
+    Failing,
+    Paused,
+}
+
+/// Replication status of a collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReplicationStatus {
+    pub collection_id: CollectionId,
+    pub config: ReplicationConfig,
+    pub health: ReplicationHealth,
+
+    /// Writes waiting to be shipped.
+    pub pending: usize,
+
+    /// Whether a full copy of the collection is due.
+    pub full_sync_pending: bool,
+
+    /// Age in seconds of the oldest write not yet shipped (0 when caught up).
+    pub lag_secs: u64,
+
+    /// Writes shipped so far (documents, for full copies).
+    pub shipped: u64,
+
+    pub last_shipped_at: Option<DateTime<Utc>>,
+    pub last_error: Option<String>,
+    pub last_error_at: Option<DateTime<Utc>>,
+}
+
+/// Next writes to ship for a collection.
+#[derive(Debug, Clone)]
+pub struct ReplicationBatch {
+    pub endpoint: String,
+    pub protocol: ReplicationProtocol,
+
+    /// Collection on the target.
+    pub target: CollectionId,
+
+    /// Set when the whole collection must be copied instead of `ops`.
+    pub full_sync: bool,
+
+    pub ops: Vec<ReplicationOp>,
+
+    // Last queued write covered by the batch, or the full copy generation
+    last_seq: u64,
+}
+
+#[derive(Debug)]
+struct QueuedOp {
+    seq: u64,
+    queued_at: DateTime<Utc>,
+    op: ReplicationOp,
+}
+
+#[derive(Debug, Clone, Copy)]
+struct FullSync {
+    requested_at: DateTime<Utc>,
+    generation: u64,
+    // Once the copy is being shipped, later writes are queued after it
+    started: bool,
+}
+
+#[derive(Debug)]
+struct Target {
+    config: ReplicationConfig,
+    ops: VecDeque<QueuedOp>,
+    next_seq: u64,
+    full_sync: Option<FullSync>,
+    full_sync_generation: u64,
+    shipped: u64,
+    last_shipped_at: Option<DateTime<Utc>>,
+    last_error: Option<(String, DateTime<Utc>)>,
+}
+
+impl Target {
+    fn new(config: ReplicationConfig) -> Self {
+        let mut target = Self {
+            config,
+            ops: VecDeque::new(),
+            next_seq: 0,
+            full_sync: None,
+            full_sync_generation: 0,
+            shipped: 0,
+            last_shipped_at: None,
+            last_error: None,
+        };
+        target.request_full_sync();
+        target
+    }
+
+    /// Replace pending writes with a full copy (a copy already being
+    /// shipped may miss them, so it is superseded).
+    fn request_full_sync(&mut self) {
+        self.ops.clear();
+        if self.full_sync.map_or(true, |sync| sync.started) {
+            self.full_sync_generation += 1;
+            self.full_sync = Some(FullSync {
+                requested_at: self
+                    .full_sync
+                    .map_or_else(Utc::now, |sync| sync.requested_at),
+                generation: self.full_sync_generation,
+                started: false,
+            });
+        }
+    }
+
+    fn status(&self, collection_id: CollectionId, now: DateTime<Utc>) -> ReplicationStatus {
+        let oldest = match self.full_sync {
+            Some(sync) => Some(sync.requested_at),
+            None => self.ops.front().map(|op| op.queued_at),
+        };
+        let lag_secs = oldest.map_or(0, |at| (now - at).num_seconds().max(0) as u64);
+        let health = if self.config.paused {
+            ReplicationHealth::Paused
+        } else if self.last_error.is_some() {
+            ReplicationHealth::Failing
+        } else if lag_secs > self.config.lag_slo_secs {
+            ReplicationHealth::Lagging
+        } else {
+            ReplicationHealth::Healthy
+        };
+        ReplicationStatus {
+            collection_id,
+            config: self.config.clone(),
+            health,
+            pending: self.ops.len(),
+            full_sync_pending: self.full_sync.is_some(),
+            lag_secs,
+            shipped: self.shipped,
+            last_shipped_at: self.last_shipped_at,
+            last_error: self.last_error.as_ref().map(|(error, _)| error.clone()),
+            last_error_at: self.last_error.as_ref().map(|(_, at)| *at),
+        }
+    }
+}
+
+/// Replication targets and their pending writes.
+#[derive(Default)]
+pub struct Replication {
+    targets: Mutex<HashMap<CollectionId, Target>>,
+    transports: Mutex<HashMap<ReplicationProtocol, Arc<dyn ReplicationTransport>>>,
+}
+
+impl Replication {
+    /// Set how writes reach other clusters over `protocol` (nothing is
+    /// shipped to targets using a protocol until its transport is set).
+    pub fn set_transport(
+        &self,
+        protocol: ReplicationProtocol,
+        transport: Arc<dyn ReplicationTransport>,
+    ) {
+        self.transports.lock().unwrap().insert(protocol, transport);
+    }
+
+    pub fn transport(
+        &self,
+        protocol: ReplicationProtocol,
+    ) -> Option<Arc<dyn ReplicationTransport>> {
+        self.transports.lock().unwrap().get(&protocol).cloned()
+    }
+
+    /// Transport of the protocol a collection's target uses.
+    pub fn target_transport(
+        &self,
+        collection_id: CollectionId,
+    ) -> Option<Arc<dyn ReplicationTransport>> {
+        let protocol = self
+            .targets
+            .lock()
+            .unwrap()
+            .get(&collection_id)?
+            .config
+            .protocol;
+        self.transport(protocol)
+    }
+
+    /// Set a collection's target. A new target (endpoint or collection)
+    /// starts with a full copy; otherwise pending writes are kept.
+    pub fn configure(
+        &self,
+        collection_id: CollectionId,
+        config: ReplicationConfig,
+    ) -> CoreResult<()> {
+        config.validate()?;
+        let mut targets = self.targets.lock().unwrap();
+        match targets.get_mut(&collection_id) {
+            Some(target) if target.config.same_target(&config) => target.config = config,
+            _ => {
+                targets.insert(collection_id, Target::new(config));
+            }
+        }
+        Ok(())
+    }
+
+    pub fn config(&self, collection_id: CollectionId) -> Option<ReplicationConfig> {
+        let targets = self.targets.lock().unwrap();
+        targets
+            .get(&collection_id)
+            .map(|target| target.config.clone())
+    }
+
+    /// Stop replicating a collection, dropping its pending writes.
+    pub fn remove(&self, collection_id: CollectionId) -> bool {
+        self.targets
+            .lock()
+            .unwrap()
+            .remove(&collection_id)
+            .is_some()
+    }
+
+    pub fn is_replicated(&self, collection_id: CollectionId) -> bool {
+        self.targets.lock().unwrap().contains_key(&collection_id)
+    }
+
+    /// Collections with a target.
+    pub fn collections(&self) -> Vec<CollectionId> {
+        self.targets.lock().unwrap().keys().copied().collect()
+    }
+
+    /// Queue a stored write (ignored unless the collection has a target, or
+    /// for replicated writes).
+    pub fn record(&self, collection_id: CollectionId, op: ReplicationOp) {
+        if REPLICATED_WRITE.try_with(|_| ()).is_ok() {
+            return;
+        }
+        let mut targets = self.targets.lock().unwrap();
+        let Some(target) = targets.get_mut(&collection_id) else {
+            return;
+        };
+        // A full copy yet to be shipped includes the write
+        if target.full_sync.map_or(false, |sync| !sync.started) {
+            return;
+        }
+        if target.ops.len() >= MAX_REPLICATION_QUEUE {
+            tracing::warn!(
+                "Replication queue of collection {} overflowed; a full copy will be shipped to {}",
+                collection_id,
+                target.config.target_region
+            );
+            target.request_full_sync();
+            return;
+        }
+        target.next_seq += 1;
+        let seq = target.next_seq;
+        target.ops.push_back(QueuedOp {
+            seq,
+            queued_at: Utc::now(),
+            op,
+        });
+    }
+
+    /// The next writes to ship for a collection (None if paused or caught
+    /// up). Writes stay queued until `finish_batch` reports them shipped.
+    pub fn next_batch(&self, collection_id: CollectionId) -> Option<ReplicationBatch> {
+        let mut targets = self.targets.lock().unwrap();
+        let target = targets.get_mut(&collection_id)?;
+        if target.config.paused {
+            return None;
+        }
+        let endpoint = target.config.target_endpoint.clone();
+        let protocol = target.config.protocol;
+        let target_collection = target.config.target_collection_id.unwrap_or(collection_id);
+        if let Some(sync) = &mut target.full_sync {
+            sync.started = true;
+            return Some(ReplicationBatch {
+                endpoint,
+                protocol,
+                target: target_collection,
+                full_sync: true,
+                ops: Vec::new(),
+                last_seq: sync.generation,
+            });
+        }
+        let queued: Vec<&QueuedOp> = target.ops.iter().take(REPLICATION_BATCH_SIZE).collect();
+        let last_seq = queued.last()?.seq;
+        Some(ReplicationBatch {
+            endpoint,
+            protocol,
+            target: target_collection,
+            full_sync: false,
+            ops: queued.into_iter().map(|queued| queued.op.clone()).collect(),
+            last_seq,
+        })
+    }
+
+    /// Record the outcome of shipping a batch (`shipped` writes or
+    /// documents on success).
+    pub fn finish_batch(
+        &self,
+        collection_id: CollectionId,
+        batch: &ReplicationBatch,
+        result: &CoreResult<usize>,
+    ) {
+        let mut targets = self.targets.lock().unwrap();
+        // Reconfigured or removed meanwhile
+        let Some(target) = targets.get_mut(&collection_id) else {
+            return;
+        };
+        let shipped = match result {
+            Ok(shipped) => *shipped,
+            Err(e) => {
+                target.last_error = Some((e.to_string(), Utc::now()));
+                return;
+            }
+        };
+        if batch.full_sync {
+            if target.full_sync.map(|sync| sync.generation) == Some(batch.last_seq) {
+                target.full_sync = None;
+            }
+        } else {
+            while target
+                .ops
+                .front()
+                .map_or(false, |op| op.seq <= batch.last_seq)
+            {
+                target.ops.pop_front();
+            }
+        }
+        target.shipped += shipped as u64;
+        target.last_shipped_at = Some(Utc::now());
+        target.last_error = None;
+    }
+
+    pub fn status(&self, collection_id: CollectionId) -> Option<ReplicationStatus> {
+        let targets = self.targets.lock().unwrap();
+        targets
+            .get(&collection_id)
+            .map(|target| target.status(collection_id, Utc::now()))
+    }
+
+    /// Status of every replicated collection.
+    pub fn statuses(&self) -> Vec<ReplicationStatus> {
+        let now = Utc::now();
+        let targets = self.targets.lock().unwrap();
+        let mut statuses: Vec<ReplicationStatus> = targets
+            .iter()
+            .map(|(collection_id, target)| target.status(*collection_id, now))
+            .collect();
+        statuses.sort_by_key(|status| status.collection_id.as_uuid());
+        statuses
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn config() -> ReplicationConfig {
+        ReplicationConfig {
+            target_region: "eu-west-1".to_string(),
+            target_endpoint: "http://akidb.eu-west-1.internal:8080".to_string(),
+            protocol: ReplicationProtocol::Http,
+            target_collection_id: None,
+            lag_slo_secs: DEFAULT_LAG_SLO_SECS,
+            paused: false,
+        }
+    }
+
+    fn delete() -> ReplicationOp {
+        ReplicationOp::Delete {
+            doc_id: DocumentId::new(),
+        }
+    }
+
+    #[test]
+    fn test_replication_queue() {
+        let replication = Replication::default();
+        let collection_id = CollectionId::new();
+        // Not replicated: nothing is queued
+        replication.record(collection_id, delete());
+        assert!(replication.next_batch(collection_id).is_none());
+
+        replication.configure(collection_id, config()).unwrap();
+        // A new target starts with a full copy, which covers earlier writes
+        replication.record(collection_id, delete());
+        let batch = replication.next_batch(collection_id).unwrap();
+        assert!(batch.full_sync);
+        assert_eq!(batch.target, collection_id);
+        // Writes made while the copy is shipped follow it
+        replication.record(collection_id, delete());
+        replication.finish_batch(collection_id, &batch, &Ok(10));
+        assert_eq!(replication.status(collection_id).unwrap().pending, 1);
+
+        replication.record(collection_id, delete());
+        let batch = replication.next_batch(collection_id).unwrap();
+        assert!(!batch.full_sync);
+        assert_eq!(batch.ops.len(), 2);
+        // A write queued while the batch is in flight stays queued
+        replication.record(collection_id, delete());
+        replication.finish_batch(
+            collection_id,
+            &batch,
+            &Err(CoreError::internal("unreachable")),
+        );
+        let status = replication.status(collection_id).unwrap();
+        assert_eq!(status.health, ReplicationHealth::Failing);
+        assert_eq!(status.pending, 3);
+
+        replication.finish_batch(collection_id, &batch, &Ok(2));
+        let status = replication.status(collection_id).unwrap();
+        assert_eq!(status.health, ReplicationHealth::Healthy);
+        assert_eq!(status.pending, 1);
+        assert_eq!(status.shipped, 12);
+
+        // Pausing keeps the queue; changing the target starts over
+        let paused = ReplicationConfig {
+            paused: true,
+            ..config()
+        };
+        replication.configure(collection_id, paused).unwrap();
+        assert!(replication.next_batch(collection_id).is_none());
+        let moved = ReplicationConfig {
+            target_collection_id: Some(CollectionId::new()),
+            ..config()
+        };
+        replication.configure(collection_id, moved).unwrap();
+        assert!(replication.next_batch(collection_id).unwrap().full_sync);
+
+        let invalid = ReplicationConfig {
+            target_endpoint: "akidb.internal".to_string(),
+            ..config()
+        };
+        assert!(invalid.validate().is_err());
+    }
+}
@@ -0,0 +1,467 @@
+//! Scheduled housekeeping jobs.
+//!
+//! A scheduled job runs a maintenance action (e.g. deleting expired records
+//! nightly, compacting storage weekly) on a cron schedule, so deployments do
+//! not need an external scheduler. Each job keeps its last runs for
+//! inspection. See `CollectionService::create_scheduled_job`.
+//!
+//! Schedules are standard five-field cron expressions evaluated in UTC:
+//! `minute hour day-of-month month day-of-week`. Fields accept `*`, values,
+//! ranges (`1-5`), lists (`1,15`) and steps (`*/15`, `0-30/10`). As in cron,
+//! when both day fields are restricted a day matching either one matches.
+//! The shortcuts `@hourly`, `@daily`, `@weekly`, `@monthly` and `@yearly` are
+//! also accepted.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, JobId};
+use chrono::{DateTime, Datelike, Duration, NaiveDate, NaiveTime, TimeZone, Timelike, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+use std::collections::VecDeque;
+
+/// Runs kept per job (oldest dropped first).
+pub const JOB_HISTORY_LIMIT: usize = 20;
+
+/// Default interval between checks for due jobs.
+pub const SCHEDULER_TICK: std::time::Duration = std::time::Duration::from_secs(30);
+
+/// How far ahead to look for the next matching time before giving up
+/// (e.g. `0 0 30 2 *` never matches).
+const MAX_LOOKAHEAD_YEARS: i32 = 5;
+
+/// A parsed cron expression.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CronSchedule {
+    minutes: u64,
+    hours: u64,
+    days: u64,
+    months: u64,
+    weekdays: u64,
+    any_day: bool,
+    any_weekday: bool,
+}
+
+impl CronSchedule {
+    /// Parses a five-field cron expression or shortcut.
+    pub fn parse(expr: &str) -> CoreResult<Self> {
+        let expr = match expr.trim() {
+            "@hourly" => "0 * * * *",
+            "@daily" | "@midnight" => "0 0 * * *",
+            "@weekly" => "0 0 * * 0",
+            "@monthly" => "0 0 1 * *",
+            "@yearly" | "@annually" => "0 0 1 1 *",
+            expr => expr,
+        };
+        let fields: Vec<&str> = expr.split_whitespace().collect();
+        let &[minute, hour, day, month, weekday] = fields.as_slice() else {
+            return Err(CoreError::ValidationError(format!(
+                "schedule '{}' must have 5 fields (minute hour day month weekday)",
+                expr
+            )));
+        };
+
+        // Sunday may be written as 0 or 7
+        let mut weekdays = parse_field(weekday, 0, 7)?;
+        if weekdays & (1 << 7) != 0 {
+            weekdays = (weekdays | 1) & !(1 << 7);
+        }
+        Ok(Self {
+            minutes: parse_field(minute, 0, 59)?,
+            hours: parse_field(hour, 0, 23)?,
+            days: parse_field(day, 1, 31)?,
+            months: parse_field(month, 1, 12)?,
+            weekdays,
+            any_day: day == "*",
+            any_weekday: weekday == "*",
+        })
+    }
+
+    /// First matching minute strictly after `after`, if any within a few years.
+    pub fn next_after(&self, after: DateTime<Utc>) -> Option<DateTime<Utc>> {
+        let start = after.with_second(0)?.with_nanosecond(0)? + Duration::minutes(1);
+        let limit = start.year() + MAX_LOOKAHEAD_YEARS;
+        let mut t = start;
+
+        while t.year() <= limit {
+            if !bit(self.months, t.month()) {
+                let (year, month) = if t.month() == 12 {
+                    (t.year() + 1, 1)
+                } else {
+                    (t.year(), t.month() + 1)
+                };
+                t = midnight(NaiveDate::from_ymd_opt(year, month, 1)?);
+            } else if !self.matches_day(t) {
+                t = midnight(t.date_naive().succ_opt()?);
+            } else if !bit(self.hours, t.hour()) {
+                t = t.with_minute(0)? + Duration::hours(1);
+            } else if !bit(self.minutes, t.minute()) {
+                t += Duration::minutes(1);
+            } else {
+                return Some(t);
+            }
+        }
+        None
+    }
+
+    fn matches_day(&self, t: DateTime<Utc>) -> bool {
+        let day = bit(self.days, t.day());
+        let weekday = bit(self.weekdays, t.weekday().num_days_from_sunday());
+        match (self.any_day, self.any_weekday) {
+            (true, true) => true,
+            (true, false) => weekday,
+            (false, true) => day,
+            (false, false) => day || weekday,
+        }
+    }
+}
+
+fn bit(set: u64, value: u32) -> bool {
+    set & (1 << value) != 0
+}
+
+fn midnight(date: NaiveDate) -> DateTime<Utc> {
+    Utc.from_utc_datetime(&date.and_time(NaiveTime::MIN))
+}
+
+/// Parses one cron field into a bitset of the values it allows.
+fn parse_field(field: &str, min: u32, max: u32) -> CoreResult<u64> {
+    let invalid = || {
+        CoreError::ValidationError(format!(
+            "invalid schedule field '{}' (values {}-{})",
+            field, min, max
+        ))
+    };
+    let number = |s: &str| -> CoreResult<u32> {
+        s.parse::<u32>()
+            .ok()
+            .filter(|n| (min..=max).contains(n))
+            .ok_or_else(invalid)
+    };
+
+    let mut set = 0u64;
+    for part in field.split(',') {
+        let (range, step) = match part.split_once('/') {
+            Some((range, step)) => (range, Some(step)),
+            None => (part, None),
+        };
+        let step = match step {
+            Some(step) => step
+                .parse::<u32>()
+                .ok()
+                .filter(|&s| s > 0)
+                .ok_or_else(invalid)?,
+            None => 1,
+        };
+        let (start, end) = if range == "*" {
+            (min, max)
+        } else if let Some((start, end)) = range.split_once('-') {
+            (number(start)?, number(end)?)
+        } else if step > 1 {
+            // `5/15` means every 15 starting at 5
+            (number(range)?, max)
+        } else {
+            let value = number(range)?;
+            (value, value)
+        };
+        if start > end {
+            return Err(invalid());
+        }
+        for value in (start..=end).step_by(step as usize) {
+            set |= 1 << value;
+        }
+    }
+    Ok(set)
+}
+
+/// Maintenance action run by a scheduled job.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "snake_case")]
+pub enum ScheduledAction {
+    /// Delete documents whose metadata `field` holds a time in the past, as an
+    /// RFC 3339 string or Unix seconds. Documents without the field are kept.
+    DeleteExpired {
+        collection_id: CollectionId,
+        field: String,
+    },
+
+    /// Snapshot the collection's storage and truncate its write-ahead log.
+    Compact { collection_id: CollectionId },
+}
+
+impl ScheduledAction {
+    /// Collection the action operates on.
+    pub fn collection_id(&self) -> CollectionId {
+        match self {
+            Self::DeleteExpired { collection_id, .. } | Self::Compact { collection_id } => {
+                *collection_id
+            }
+        }
+    }
+}
+
+/// Returns true if `metadata[field]` is a time at or before `now`.
+pub fn is_expired(metadata: Option<&JsonValue>, field: &str, now: DateTime<Utc>) -> bool {
+    let expires_at = match metadata.and_then(|m| m.get(field)) {
+        Some(JsonValue::String(s)) => DateTime::parse_from_rfc3339(s)
+            .ok()
+            .map(|t| t.with_timezone(&Utc)),
+        Some(JsonValue::Number(n)) => n
+            .as_i64()
+            .or_else(|| n.as_f64().map(|f| f as i64))
+            .and_then(|secs| Utc.timestamp_opt(secs, 0).single()),
+        _ => None,
+    };
+    expires_at.map_or(false, |t| t <= now)
+}
+
+/// Scheduled job definition, as created or updated by callers.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ScheduledJobSpec {
+    pub name: String,
+
+    /// Cron expression (UTC).
+    pub schedule: String,
+
+    pub action: ScheduledAction,
+
+    /// Disabled jobs keep their definition and history but never run on schedule.
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+/// Outcome of one run.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum JobRunStatus {
+    Succeeded,
+    Failed,
+}
+
+/// One run of a scheduled job.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct JobRun {
+    pub started_at: DateTime<Utc>,
+    pub finished_at: DateTime<Utc>,
+    pub status: JobRunStatus,
+
+    /// Documents deleted (or otherwise affected) by the run.
+    pub affected: u64,
+
+    pub error: Option<String>,
+
+    /// True if started by a caller rather than the schedule.
+    pub manual: bool,
+}
+
+/// A scheduled job and its recent runs.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ScheduledJob {
+    pub id: JobId,
+
+    #[serde(flatten)]
+    pub spec: ScheduledJobSpec,
+
+    /// Next scheduled run (None while disabled).
+    pub next_run_at: Option<DateTime<Utc>>,
+
+    /// Most recent runs, oldest first.
+    pub history: VecDeque<JobRun>,
+
+    pub created_at: DateTime<Utc>,
+    pub updated_at: DateTime<Utc>,
+}
+
+impl ScheduledJob {
+    /// Creates a job, validating its schedule.
+    pub fn new(spec: ScheduledJobSpec) -> CoreResult<Self> {
+        let now = Utc::now();
+        let mut job = Self {
+            id: JobId::new(),
+            spec,
+            next_run_at: None,
+            history: VecDeque::new(),
+            created_at: now,
+            updated_at: now,
+        };
+        job.update(job.spec.clone(), now)?;
+        Ok(job)
+    }
+
+    /// Replaces the definition and recomputes the next run.
+    pub fn update(&mut self, spec: ScheduledJobSpec, now: DateTime<Utc>) -> CoreResult<()> {
+        if spec.name.trim().is_empty() {
+            return Err(CoreError::ValidationError(
+                "job name must not be empty".to_string(),
+            ));
+        }
+        let schedule = CronSchedule::parse(&spec.schedule)?;
+        self.next_run_at = if spec.enabled {
+            let next = schedule.next_after(now);
+            if next.is_none() {
+                return Err(CoreError::ValidationError(format!(
+                    "schedule '{}' never runs",
+                    spec.schedule
+                )));
+            }
+            next
+        } else {
+            None
+        };
+        self.spec = spec;
+        self.updated_at = now;
+        Ok(())
+    }
+
+    /// The most recent run.
+    pub fn last_run(&self) -> Option<&JobRun> {
+        self.history.back()
+    }
+
+    /// Returns true if the job should run at `now`.
+    pub fn is_due(&self, now: DateTime<Utc>) -> bool {
+        self.next_run_at.map_or(false, |t| t <= now)
+    }
+
+    /// Advances `next_run_at` past `now` (called when a scheduled run starts).
+    pub fn advance(&mut self, now: DateTime<Utc>) {
+        self.next_run_at = CronSchedule::parse(&self.spec.schedule)
+            .ok()
+            .and_then(|schedule| schedule.next_after(now));
+    }
+
+    /// Appends a run to the history.
+    pub fn record(&mut self, run: JobRun) {
+        if self.history.len() >= JOB_HISTORY_LIMIT {
+            self.history.pop_front();
+        }
+        self.history.push_back(run);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn at(s: &str) -> DateTime<Utc> {
+        DateTime::parse_from_rfc3339(s).unwrap().with_timezone(&Utc)
+    }
+
+    #[test]
+    fn test_next_after() {
+        let daily = CronSchedule::parse("30 2 * * *").unwrap();
+        assert_eq!(
+            daily.next_after(at("2024-03-10T01:00:00Z")),
+            Some(at("2024-03-10T02:30:00Z"))
+        );
+        assert_eq!(
+            daily.next_after(at("2024-03-10T02:30:00Z")),
+            Some(at("2024-03-11T02:30:00Z"))
+        );
+
+        let quarter = CronSchedule::parse("*/15 * * * *").unwrap();
+        assert_eq!(
+            quarter.next_after(at("2024-12-31T23:50:10Z")),
+            Some(at("2025-01-01T00:00:00Z"))
+        );
+
+        // Sundays (2024-03-10 was a Sunday), written as 7
+        let weekly = CronSchedule::parse("0 4 * * 7").unwrap();
+        assert_eq!(weekly, CronSchedule::parse("0 4 * * 0").unwrap());
+        assert_eq!(
+            weekly.next_after(at("2024-03-06T00:00:00Z")),
+            Some(at("2024-03-10T04:00:00Z"))
+        );
+
+        // Day of month OR weekday: the 15th or any Monday
+        let either = CronSchedule::parse("0 0 15 * 1").unwrap();
+        assert_eq!(
+            either.next_after(at("2024-03-12T00:00:00Z")),
+            Some(at("2024-03-15T00:00:00Z"))
+        );
+        assert_eq!(
+            either.next_after(at("2024-03-15T00:00:00Z")),
+            Some(at("2024-03-18T00:00:00Z"))
+        );
+
+        assert_eq!(
+            CronSchedule::parse("0 0 29 2 *")
+                .unwrap()
+                .next_after(at("2024-03-01T00:00:00Z")),
+            Some(at("2028-02-29T00:00:00Z"))
+        );
+        assert_eq!(
+            CronSchedule::parse("0 0 30 2 *")
+                .unwrap()
+                .next_after(at("2024-03-01T00:00:00Z")),
+            None
+        );
+    }
+
+    #[test]
+    fn test_parse_errors() {
+        for expr in [
+            "",
+            "* * * *",
+            "60 * * * *",
+            "* * 0 * *",
+            "*/0 * * * *",
+            "5-1 * * * *",
+        ] {
+            assert!(CronSchedule::parse(expr).is_err(), "{}", expr);
+        }
+        assert!(CronSchedule::parse("0,30 9-17 * 1-6/2 mon").is_err());
+        assert!(CronSchedule::parse("0,30 9-17 * 1-6/2 1-5").is_ok());
+    }
+
+    #[test]
+    fn test_is_expired() {
+        let now = at("2024-03-10T00:00:00Z");
+        let past = json!({"expires_at": "2024-03-09T00:00:00Z"});
+        let future = json!({"expires_at": now.timestamp() + 60});
+        assert!(is_expired(Some(&past), "expires_at", now));
+        assert!(!is_expired(Some(&future), "expires_at", now));
+        assert!(!is_expired(Some(&past), "ttl", now));
+        assert!(!is_expired(None, "expires_at", now));
+    }
+
+    #[test]
+    fn test_history_is_capped() {
+        let mut job = ScheduledJob::new(ScheduledJobSpec {
+            name: "nightly".to_string(),
+            schedule: "@daily".to_string(),
+            action: ScheduledAction::Compact {
+                collection_id: CollectionId::new(),
+            },
+            enabled: true,
+        })
+        .unwrap();
+        let now = Utc::now();
+        for affected in 0..JOB_HISTORY_LIMIT as u64 + 5 {
+            job.record(JobRun {
+                started_at: now,
+                finished_at: now,
+                status: JobRunStatus::Succeeded,
+                affected,
+                error: None,
+                manual: false,
+            });
+        }
+        assert_eq!(job.history.len(), JOB_HISTORY_LIMIT);
+        assert_eq!(
+            job.last_run().unwrap().affected,
+            JOB_HISTORY_LIMIT as u64 + 4
+        );
+
+        // Stored as JSON across restarts
+        let stored: ScheduledJob =
+            serde_json::from_value(serde_json::to_value(&job).unwrap()).unwrap();
+        assert_eq!(stored.id, job.id);
+        assert_eq!(stored.spec.action, job.spec.action);
+        assert_eq!(stored.next_run_at, job.next_run_at);
+        assert_eq!(stored.history.len(), JOB_HISTORY_LIMIT);
+    }
+}
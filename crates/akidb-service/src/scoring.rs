@@ -0,0 +1,275 @@
+//! Score modifiers.
+//!
+//! Modifiers combine vector similarity with metadata in one query, e.g. for
+//! freshness-aware ranking. A field boost multiplies the score of matches
+//! whose field has a given value; a decay function multiplies it by a factor
+//! in `(0, 1]` that falls as a numeric or timestamp field moves away from an
+//! origin (Gaussian, exponential or linear, as in Elasticsearch's
+//! `function_score`). See `ScoreModifiers` in the post-processing pipeline.
+
+use akidb_core::{CoreError, CoreResult};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+
+/// Maximum modifiers per query.
+pub const MAX_SCORE_MODIFIERS: usize = 16;
+
+/// One factor applied to a match's score.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "snake_case")]
+pub enum ScoreModifier {
+    Boost(FieldBoost),
+    Decay(DecayFunction),
+}
+
+/// Multiplies the score by `weight` when a metadata field equals `value`
+/// (or, for an array field, contains it).
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct FieldBoost {
+    pub field: String,
+    pub value: JsonValue,
+    pub weight: f32,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum DecayKind {
+    Gauss,
+    Exp,
+    Linear,
+}
+
+/// Multiplies the score by a factor falling with the distance of a metadata
+/// field from `origin`.
+///
+/// Field values are numbers or RFC 3339 timestamps (compared in seconds).
+/// Within `offset` of the origin the factor is 1; at `offset + scale` it is
+/// `decay`. `scale` and `offset` are numbers or, for timestamps, durations
+/// such as `"7d"` (units `s`, `m`, `h`, `d`, `w`). Matches without a usable
+/// value keep their score.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct DecayFunction {
+    pub field: String,
+    pub function: DecayKind,
+
+    /// Number or RFC 3339 timestamp (default: now).
+    #[serde(default)]
+    pub origin: Option<JsonValue>,
+
+    pub scale: JsonValue,
+
+    #[serde(default)]
+    pub offset: Option<JsonValue>,
+
+    /// Factor at `offset + scale` from the origin (default: 0.5).
+    #[serde(default = "default_decay")]
+    pub decay: f64,
+}
+
+fn default_decay() -> f64 {
+    0.5
+}
+
+impl ScoreModifier {
+    fn field(&self) -> &str {
+        match self {
+            Self::Boost(boost) => &boost.field,
+            Self::Decay(decay) => &decay.field,
+        }
+    }
+
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.field().is_empty() {
+            return Err(CoreError::ValidationError(
+                "score modifier field must not be empty".to_string(),
+            ));
+        }
+        match self {
+            Self::Boost(boost) => {
+                if !boost.weight.is_finite() || boost.weight < 0.0 {
+                    return Err(CoreError::ValidationError(format!(
+                        "boost weight for '{}' must be a non-negative number",
+                        boost.field
+                    )));
+                }
+            }
+            Self::Decay(decay) => {
+                decay.resolve(0.0)?;
+            }
+        }
+        Ok(())
+    }
+
+    /// The factor for a match with `metadata`, `now` in Unix seconds.
+    pub fn factor(&self, metadata: Option<&JsonValue>, now: f64) -> f64 {
+        let value = metadata.and_then(|m| m.get(self.field()));
+        match self {
+            Self::Boost(boost) => {
+                let matched = match value {
+                    Some(JsonValue::Array(items)) => items.contains(&boost.value),
+                    Some(value) => *value == boost.value,
+                    None => false,
+                };
+                if matched {
+                    boost.weight as f64
+                } else {
+                    1.0
+                }
+            }
+            Self::Decay(decay) => match (value.and_then(as_seconds), decay.resolve(now)) {
+                (Some(x), Ok((origin, scale, offset))) => {
+                    decay
+                        .function
+                        .factor((x - origin).abs() - offset, scale, decay.decay)
+                }
+                _ => 1.0,
+            },
+        }
+    }
+}
+
+/// Reject empty-field, negative or malformed modifiers and oversized lists.
+pub fn validate_modifiers(modifiers: &[ScoreModifier]) -> CoreResult<()> {
+    if modifiers.len() > MAX_SCORE_MODIFIERS {
+        return Err(CoreError::ValidationError(format!(
+            "at most {} score modifiers are allowed (got {})",
+            MAX_SCORE_MODIFIERS,
+            modifiers.len()
+        )));
+    }
+    modifiers.iter().try_for_each(ScoreModifier::validate)
+}
+
+impl DecayFunction {
+    /// Origin, scale and offset as numbers.
+    fn resolve(&self, now: f64) -> CoreResult<(f64, f64, f64)> {
+        let origin = match &self.origin {
+            None => now,
+            Some(origin) => as_seconds(origin).ok_or_else(|| {
+                CoreError::ValidationError(format!(
+                    "decay origin for '{}' must be a number or RFC 3339 timestamp",
+                    self.field
+                ))
+            })?,
+        };
+        let scale = parse_span(&self.scale).filter(|s| *s > 0.0);
+        let offset = match &self.offset {
+            None => Some(0.0),
+            Some(offset) => parse_span(offset).filter(|o| *o >= 0.0),
+        };
+        let (Some(scale), Some(offset)) = (scale, offset) else {
+            return Err(CoreError::ValidationError(format!(
+                "decay scale for '{}' must be positive and offset non-negative \
+                 (numbers or durations like \"7d\")",
+                self.field
+            )));
+        };
+        if !(self.decay > 0.0 && self.decay < 1.0) {
+            return Err(CoreError::ValidationError(format!(
+                "decay for '{}' must be between 0 and 1 (got {})",
+                self.field, self.decay
+            )));
+        }
+        Ok((origin, scale, offset))
+    }
+}
+
+impl DecayKind {
+    /// Factor at `distance` beyond the offset, `decay` at `scale`.
+    fn factor(self, distance: f64, scale: f64, decay: f64) -> f64 {
+        let d = distance.max(0.0);
+        match self {
+            Self::Gauss => decay.powf((d / scale).powi(2)),
+            Self::Exp => decay.powf(d / scale),
+            Self::Linear => (1.0 - d * (1.0 - decay) / scale).max(0.0),
+        }
+    }
+}
+
+/// A number, or an RFC 3339 timestamp in Unix seconds.
+fn as_seconds(value: &JsonValue) -> Option<f64> {
+    match value {
+        JsonValue::Number(n) => n.as_f64(),
+        JsonValue::String(s) => DateTime::parse_from_rfc3339(s)
+            .ok()
+            .map(|t| t.with_timezone(&Utc).timestamp_millis() as f64 / 1000.0),
+        _ => None,
+    }
+}
+
+/// A number, or a duration like `"36h"` in seconds.
+fn parse_span(value: &JsonValue) -> Option<f64> {
+    match value {
+        JsonValue::Number(n) => n.as_f64(),
+        JsonValue::String(s) => {
+            let unit = match s.chars().last()? {
+                's' => 1.0,
+                'm' => 60.0,
+                'h' => 3_600.0,
+                'd' => 86_400.0,
+                'w' => 604_800.0,
+                _ => return None,
+            };
+            let amount: f64 = s[..s.len() - 1].parse().ok()?;
+            amount.is_finite().then_some(amount * unit)
+        }
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_decay_functions() {
+        let decay: ScoreModifier = serde_json::from_value(json!({
+            "type": "decay",
+            "field": "published_at",
+            "function": "gauss",
+            "origin": "2024-06-30T00:00:00Z",
+            "scale": "10d",
+            "offset": "1d",
+        }))
+        .unwrap();
+        decay.validate().unwrap();
+        let factor = |date: &str| decay.factor(Some(&json!({ "published_at": date })), 0.0);
+        assert_eq!(factor("2024-06-29T12:00:00Z"), 1.0);
+        assert!((factor("2024-06-19T00:00:00Z") - 0.5).abs() < 1e-9);
+        assert!(factor("2024-05-01T00:00:00Z") < 0.01);
+        // Missing or unparseable values keep the score
+        assert_eq!(
+            decay.factor(Some(&json!({ "published_at": "soon" })), 0.0),
+            1.0
+        );
+        assert_eq!(decay.factor(None, 0.0), 1.0);
+
+        let linear = DecayKind::Linear;
+        assert!((linear.factor(5.0, 10.0, 0.5) - 0.75).abs() < 1e-9);
+        assert_eq!(linear.factor(30.0, 10.0, 0.5), 0.0);
+        assert!((DecayKind::Exp.factor(20.0, 10.0, 0.5) - 0.25).abs() < 1e-9);
+    }
+
+    #[test]
+    fn test_boost_and_validation() {
+        let boost = ScoreModifier::Boost(FieldBoost {
+            field: "tags".to_string(),
+            value: json!("featured"),
+            weight: 2.0,
+        });
+        assert_eq!(
+            boost.factor(Some(&json!({"tags": ["new", "featured"]})), 0.0),
+            2.0
+        );
+        assert_eq!(boost.factor(Some(&json!({"tags": "new"})), 0.0), 1.0);
+
+        let bad_decay: ScoreModifier = serde_json::from_value(json!({
+            "type": "decay", "field": "price", "function": "exp", "origin": 10, "scale": 0,
+        }))
+        .unwrap();
+        assert!(bad_decay.validate().is_err());
+        assert!(validate_modifiers(&vec![boost; MAX_SCORE_MODIFIERS + 1]).is_err());
+    }
+}
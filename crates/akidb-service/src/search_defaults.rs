@@ -0,0 +1,90 @@
+//! Per-collection default search parameters.
+//!
+//! Tuned defaults stored on a collection let lightweight clients search with
+//! only a query vector. `top_k` and `min_score` fill in what a request leaves
+//! out; `ef_search` and `filter` apply to every search of the collection.
+//! See `CollectionService::set_search_defaults`.
+
+use akidb_core::{CoreError, CoreResult};
+use serde::{Deserialize, Serialize};
+
+use crate::filter::MetadataFilter;
+
+/// Candidates fetched per requested result when a default filter is set, so
+/// filtering still leaves enough results.
+pub const FILTER_OVERFETCH: usize = 4;
+
+/// Default search parameters of a collection (unset fields fall back to the
+/// request, then to the tenant policy).
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct SearchDefaults {
+    /// Results returned when a request sets no `top_k`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub top_k: Option<usize>,
+
+    /// Score threshold applied when a request sets no `min_score`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub min_score: Option<f32>,
+
+    /// HNSW search breadth (ignored by brute-force indexes).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub ef_search: Option<usize>,
+
+    /// Metadata every result must match, e.g. `{"status": "published"}`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub filter: Option<MetadataFilter>,
+}
+
+impl SearchDefaults {
+    pub fn is_empty(&self) -> bool {
+        *self == Self::default()
+    }
+
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.top_k == Some(0) || self.ef_search == Some(0) {
+            return Err(CoreError::ValidationError(
+                "top_k and ef_search defaults must be greater than 0".to_string(),
+            ));
+        }
+        if let Some(min_score) = self.min_score {
+            if !min_score.is_finite() {
+                return Err(CoreError::ValidationError(
+                    "min_score default must be a finite number".to_string(),
+                ));
+            }
+        }
+        if let Some(filter) = &self.filter {
+            filter.validate()?;
+        }
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_validate_defaults() {
+        let defaults: SearchDefaults = serde_json::from_value(serde_json::json!({
+            "top_k": 5,
+            "ef_search": 256,
+            "filter": {"status": "published"},
+        }))
+        .unwrap();
+        defaults.validate().unwrap();
+        assert!(!defaults.is_empty());
+        assert!(SearchDefaults::default().is_empty());
+
+        let zero = SearchDefaults {
+            top_k: Some(0),
+            ..Default::default()
+        };
+        assert!(zero.validate().is_err());
+        let empty_filter = SearchDefaults {
+            filter: Some(MetadataFilter::default()),
+            ..Default::default()
+        };
+        assert!(empty_filter.validate().is_err());
+    }
+}
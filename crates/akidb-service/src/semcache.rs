@@ -0,0 +1,378 @@
+//! Semantic cache for LLM responses.
+//!
+//! Stores (prompt embedding → response) pairs in a collection and returns a
+//! cached response when a new prompt embedding is within the configured
+//! similarity threshold. Entries expire after a TTL and can be invalidated
+//! individually, by similarity to a prompt, or all at once.
+
+use akidb_core::{CollectionId, CoreResult, DistanceMetric, DocumentId, VectorDocument};
+use chrono::{DateTime, Duration, Utc};
+use serde_json::{json, Value as JsonValue};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Arc;
+
+use crate::collection_service::{CollectionService, MAX_TOP_K};
+use crate::memory::{created_at_of, similarity_from_score};
+
+/// Metadata marker identifying documents written by the semantic cache.
+const SEMCACHE_KIND: &str = "semcache";
+
+/// Number of nearest entries inspected first per lookup, doubled while the
+/// ones inspected are all expired or not cache entries.
+const LOOKUP_CANDIDATES: usize = 5;
+
+/// Configuration for a [`SemanticCache`].
+#[derive(Debug, Clone)]
+pub struct SemanticCacheConfig {
+    /// Minimum similarity (0.0-1.0, see `similarity_from_score`) for a cache hit.
+    pub similarity_threshold: f32,
+
+    /// Entry lifetime (None = never expires).
+    pub ttl: Option<Duration>,
+}
+
+impl Default for SemanticCacheConfig {
+    fn default() -> Self {
+        Self {
+            similarity_threshold: 0.95,
+            ttl: Some(Duration::hours(1)),
+        }
+    }
+}
+
+/// A cached response returned by [`SemanticCache::get`].
+#[derive(Debug, Clone)]
+pub struct CacheHit {
+    pub doc_id: DocumentId,
+    /// Prompt that produced the cached response.
+    pub prompt: String,
+    pub response: String,
+    /// Similarity between the lookup and the cached prompt (0.0-1.0).
+    pub similarity: f32,
+    pub created_at: DateTime<Utc>,
+}
+
+/// Hit/miss counters for a [`SemanticCache`].
+#[derive(Debug, Clone, Copy, Default)]
+pub struct SemanticCacheStats {
+    pub hits: u64,
+    pub misses: u64,
+}
+
+impl SemanticCacheStats {
+    /// Hit rate (0.0 when there have been no lookups).
+    pub fn hit_rate(&self) -> f64 {
+        let total = self.hits + self.misses;
+        if total == 0 {
+            0.0
+        } else {
+            self.hits as f64 / total as f64
+        }
+    }
+}
+
+/// Semantic cache backed by a single collection.
+pub struct SemanticCache {
+    service: Arc<CollectionService>,
+    collection_id: CollectionId,
+    config: SemanticCacheConfig,
+    hits: AtomicU64,
+    misses: AtomicU64,
+}
+
+impl SemanticCache {
+    /// Creates a semantic cache over an existing collection.
+    pub fn new(
+        service: Arc<CollectionService>,
+        collection_id: CollectionId,
+        config: SemanticCacheConfig,
+    ) -> Self {
+        Self {
+            service,
+            collection_id,
+            config,
+            hits: AtomicU64::new(0),
+            misses: AtomicU64::new(0),
+        }
+    }
+
+    /// Stores a response for a prompt and returns the entry's document ID.
+    pub async fn put(
+        &self,
+        prompt: &str,
+        prompt_embedding: Vec<f32>,
+        response: &str,
+    ) -> CoreResult<DocumentId> {
+        let now = Utc::now();
+        let metadata = json!({
+            "kind": SEMCACHE_KIND,
+            "prompt": prompt,
+            "response": response,
+            "created_at": now.to_rfc3339(),
+        });
+
+        let doc = VectorDocument::new(DocumentId::new(), prompt_embedding)
+            .with_metadata(metadata)
+            .with_timestamp(now);
+        self.service.insert(self.collection_id, doc).await
+    }
+
+    /// Looks up a cached response for a prompt embedding.
+    ///
+    /// Returns the most similar non-expired entry at or above the similarity
+    /// threshold, or `None` on a miss.
+    pub async fn get(&self, prompt_embedding: Vec<f32>) -> CoreResult<Option<CacheHit>> {
+        let hit = self.nearest(prompt_embedding).await?;
+
+        match hit {
+            Some(_) => self.hits.fetch_add(1, Ordering::Relaxed),
+            None => self.misses.fetch_add(1, Ordering::Relaxed),
+        };
+        Ok(hit)
+    }
+
+    /// Removes a single cache entry.
+    pub async fn invalidate(&self, doc_id: DocumentId) -> CoreResult<()> {
+        self.service.delete(self.collection_id, doc_id).await
+    }
+
+    /// Removes every entry whose prompt is at least `threshold` similar to
+    /// `prompt_embedding`, expired or not. Returns the number removed.
+    ///
+    /// Any number of entries may match, so this scans the whole collection.
+    pub async fn invalidate_similar(
+        &self,
+        prompt_embedding: Vec<f32>,
+        threshold: f32,
+    ) -> CoreResult<usize> {
+        let metric = self.metric().await?;
+        self.delete_where(|vector, _| {
+            vector.len() == prompt_embedding.len()
+                && similarity_from_score(metric, metric.compute(&prompt_embedding, vector))
+                    >= threshold
+        })
+        .await
+    }
+
+    /// Removes all cache entries. Returns the number removed.
+    pub async fn clear(&self) -> CoreResult<usize> {
+        self.delete_where(|_, _| true).await
+    }
+
+    /// Removes expired entries. Returns the number removed.
+    pub async fn purge_expired(&self) -> CoreResult<usize> {
+        let now = Utc::now();
+        self.delete_where(|_, created_at| self.is_expired(created_at, now))
+            .await
+    }
+
+    /// Returns hit/miss counters since creation.
+    pub fn stats(&self) -> SemanticCacheStats {
+        SemanticCacheStats {
+            hits: self.hits.load(Ordering::Relaxed),
+            misses: self.misses.load(Ordering::Relaxed),
+        }
+    }
+
+    fn is_expired(&self, created_at: DateTime<Utc>, now: DateTime<Utc>) -> bool {
+        self.config
+            .ttl
+            .map_or(false, |ttl| now.signed_duration_since(created_at) > ttl)
+    }
+
+    async fn metric(&self) -> CoreResult<DistanceMetric> {
+        Ok(self
+            .service
+            .get_collection(self.collection_id)
+            .await?
+            .metric)
+    }
+
+    /// The most similar non-expired entry at or above the similarity
+    /// threshold.
+    ///
+    /// Expired entries and other documents of the collection can outrank it,
+    /// so the search widens until it reaches an entry, drops below the
+    /// threshold or runs out of documents.
+    async fn nearest(&self, prompt_embedding: Vec<f32>) -> CoreResult<Option<CacheHit>> {
+        let metric = self.metric().await?;
+        let threshold = self.config.similarity_threshold;
+        let cap = match self.service.collection_policy().await.limits.max_top_k {
+            Some(max_top_k) => (max_top_k as usize).min(MAX_TOP_K),
+            None => MAX_TOP_K,
+        };
+
+        let mut k = LOOKUP_CANDIDATES.min(cap);
+        loop {
+            let results = self
+                .service
+                .search_index(self.collection_id, prompt_embedding.clone(), k)
+                .await?;
+            let now = Utc::now();
+            for r in &results {
+                let similarity = similarity_from_score(metric, r.score);
+                if similarity < threshold {
+                    return Ok(None);
+                }
+                let hit = r
+                    .metadata
+                    .as_ref()
+                    .and_then(|metadata| cache_hit(r.doc_id, metadata, similarity));
+                if let Some(hit) = hit.filter(|hit| !self.is_expired(hit.created_at, now)) {
+                    return Ok(Some(hit));
+                }
+            }
+            if results.len() < k || k >= cap {
+                return Ok(None);
+            }
+            k = (k * 2).min(cap);
+        }
+    }
+
+    async fn delete_where<F>(&self, predicate: F) -> CoreResult<usize>
+    where
+        F: Fn(&[f32], DateTime<Utc>) -> bool,
+    {
+        let docs = self.service.list_documents(self.collection_id).await?;
+        let mut removed = 0;
+        for doc in docs {
+            let Some(metadata) = doc.metadata.as_ref() else {
+                continue;
+            };
+            if metadata.get("kind").and_then(JsonValue::as_str) != Some(SEMCACHE_KIND) {
+                continue;
+            }
+            let created_at = created_at_of(metadata).unwrap_or(doc.inserted_at);
+            if predicate(&doc.vector, created_at) {
+                self.service.delete(self.collection_id, doc.doc_id).await?;
+                removed += 1;
+            }
+        }
+        Ok(removed)
+    }
+}
+
+/// The cache entry stored in a document's metadata, if it is one.
+fn cache_hit(doc_id: DocumentId, metadata: &JsonValue, similarity: f32) -> Option<CacheHit> {
+    if metadata.get("kind").and_then(JsonValue::as_str) != Some(SEMCACHE_KIND) {
+        return None;
+    }
+    Some(CacheHit {
+        doc_id,
+        prompt: metadata.get("prompt")?.as_str()?.to_string(),
+        response: metadata.get("response")?.as_str()?.to_string(),
+        similarity,
+        created_at: created_at_of(metadata)?,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DistanceMetric;
+
+    async fn setup(config: SemanticCacheConfig) -> SemanticCache {
+        let service = Arc::new(CollectionService::new());
+        let collection_id = service
+            .create_collection("semcache".to_string(), 16, DistanceMetric::Cosine, None)
+            .await
+            .unwrap();
+        SemanticCache::new(service, collection_id, config)
+    }
+
+    fn embedding(i: usize, jitter: f32) -> Vec<f32> {
+        let mut v = vec![0.0; 16];
+        v[i] = 1.0;
+        v[(i + 1) % 16] = jitter;
+        v
+    }
+
+    #[tokio::test]
+    async fn test_hit_within_threshold_and_miss_outside() {
+        let cache = setup(SemanticCacheConfig::default()).await;
+        cache
+            .put("What is AkiDB?", embedding(0, 0.0), "A vector database.")
+            .await
+            .unwrap();
+
+        let hit = cache.get(embedding(0, 0.05)).await.unwrap().unwrap();
+        assert_eq!(hit.response, "A vector database.");
+        assert!(hit.similarity >= 0.95);
+
+        assert!(cache.get(embedding(3, 0.0)).await.unwrap().is_none());
+
+        let stats = cache.stats();
+        assert_eq!((stats.hits, stats.misses), (1, 1));
+        assert!((stats.hit_rate() - 0.5).abs() < 1e-9);
+    }
+
+    #[tokio::test]
+    async fn test_invalidation() {
+        let cache = setup(SemanticCacheConfig::default()).await;
+        let id = cache.put("a", embedding(0, 0.0), "A").await.unwrap();
+        cache.put("b", embedding(1, 0.0), "B").await.unwrap();
+        cache.put("c", embedding(2, 0.0), "C").await.unwrap();
+
+        cache.invalidate(id).await.unwrap();
+        assert!(cache.get(embedding(0, 0.0)).await.unwrap().is_none());
+
+        assert_eq!(
+            cache
+                .invalidate_similar(embedding(1, 0.0), 0.9)
+                .await
+                .unwrap(),
+            1
+        );
+        assert_eq!(cache.clear().await.unwrap(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_lookups_past_nearest_candidates() {
+        let cache = setup(SemanticCacheConfig::default()).await;
+
+        // Other documents outranking the only entry
+        for _ in 0..2 * LOOKUP_CANDIDATES {
+            let doc = VectorDocument::new(DocumentId::new(), embedding(0, 0.0))
+                .with_metadata(json!({"kind": "other"}));
+            cache
+                .service
+                .insert(cache.collection_id, doc)
+                .await
+                .unwrap();
+        }
+        cache.put("a", embedding(0, 0.1), "A").await.unwrap();
+        let hit = cache.get(embedding(0, 0.0)).await.unwrap().unwrap();
+        assert_eq!(hit.response, "A");
+
+        for i in 0..3 * LOOKUP_CANDIDATES {
+            let prompt = format!("b{}", i);
+            cache
+                .put(&prompt, embedding(1, i as f32 * 0.01), "B")
+                .await
+                .unwrap();
+        }
+        assert_eq!(
+            cache
+                .invalidate_similar(embedding(1, 0.0), 0.9)
+                .await
+                .unwrap(),
+            3 * LOOKUP_CANDIDATES
+        );
+        assert!(cache.get(embedding(1, 0.0)).await.unwrap().is_none());
+        assert_eq!(cache.clear().await.unwrap(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_expired_entries_are_misses() {
+        let cache = setup(SemanticCacheConfig {
+            ttl: Some(Duration::zero()),
+            ..Default::default()
+        })
+        .await;
+        cache.put("a", embedding(0, 0.0), "A").await.unwrap();
+        tokio::time::sleep(std::time::Duration::from_millis(5)).await;
+
+        assert!(cache.get(embedding(0, 0.0)).await.unwrap().is_none());
+        assert_eq!(cache.purge_expired().await.unwrap(), 1);
+    }
+}
@@ -0,0 +1,81 @@
+//! Collection snapshots for backup and restore.
+//!
+//! A snapshot is a point-in-time copy of one collection: its settings and
+//! search defaults, plus an NDJSON file with one `ExportRecord` per document
+//! (the compliance export format). Restoring creates a new collection from
+//! it, which an alias can then be switched to; the file can also be
+//! downloaded and imported into another server with an upload created with
+//! the snapshot's manifest.
+//!
+//! Snapshot files are kept in the local temp directory, each next to a JSON
+//! file describing the snapshot, from which the list of snapshots is rebuilt
+//! at startup. The temp directory may be cleared when the host reboots, so
+//! snapshots that must be kept are downloaded.
+
+use akidb_core::{CollectionDescriptor, CollectionId, SnapshotId};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::path::PathBuf;
+
+use crate::manifest::ExportManifest;
+use crate::search_defaults::SearchDefaults;
+
+/// A point-in-time copy of a collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CollectionSnapshot {
+    pub snapshot_id: SnapshotId,
+
+    /// Collection settings when the snapshot was taken.
+    pub collection: CollectionDescriptor,
+
+    #[serde(default)]
+    pub search_defaults: SearchDefaults,
+
+    /// Parts, record count and checksums of the snapshot file.
+    pub manifest: ExportManifest,
+
+    pub created_at: DateTime<Utc>,
+}
+
+impl CollectionSnapshot {
+    pub fn collection_id(&self) -> CollectionId {
+        self.collection.collection_id
+    }
+
+    /// Name of a collection restored without an explicit name, e.g.
+    /// `docs-20260101-120000`.
+    pub fn default_restore_name(&self) -> String {
+        format!(
+            "{}-{}",
+            self.collection.name,
+            self.created_at.format("%Y%m%d-%H%M%S")
+        )
+    }
+}
+
+/// Outcome of restoring a snapshot.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RestoreReport {
+    pub snapshot_id: SnapshotId,
+
+    /// The collection created by the restore.
+    pub collection_id: CollectionId,
+    pub name: String,
+
+    pub documents: u64,
+}
+
+/// Directory holding the snapshot files.
+pub fn snapshot_dir() -> PathBuf {
+    std::env::temp_dir().join("akidb-snapshots")
+}
+
+/// Location of a snapshot's NDJSON file.
+pub fn snapshot_path(snapshot_id: SnapshotId) -> PathBuf {
+    snapshot_dir().join(format!("{}.ndjson", snapshot_id))
+}
+
+/// Location of the JSON file describing a snapshot (a `CollectionSnapshot`).
+pub fn snapshot_info_path(snapshot_id: SnapshotId) -> PathBuf {
+    snapshot_dir().join(format!("{}.json", snapshot_id))
+}
@@ -0,0 +1,244 @@
+//! Sparse embedding helpers for hybrid search.
+//!
+//! Provides per-collection IDF statistics and a BM25 encoder producing
+//! [`SparseVector`]s. Token IDs are a stable hash of the token text, so a
+//! client holding the same analyzer settings and a copy of the server's IDF
+//! stats produces identical vectors without a shared vocabulary file.
+//!
+//! Learned sparse models (e.g. SPLADE) can be plugged in by implementing
+//! [`SparseEncoder`].
+
+pub use akidb_core::SparseVector;
+
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+
+use crate::analysis::{Analyzer, AnalyzerSettings, Tokenizer};
+
+/// Default metadata field holding document text.
+pub const DEFAULT_TEXT_FIELD: &str = "text";
+
+/// Document-frequency statistics for a collection's text field.
+///
+/// Served per collection so clients can build BM25 query vectors locally.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct IdfStats {
+    /// Metadata field the stats were computed from.
+    pub field: String,
+
+    /// Analyzer used to compute the stats (clients must use the same one).
+    pub analyzer: Analyzer,
+
+    /// Number of documents with text in `field`.
+    pub doc_count: u64,
+
+    /// Average document length in tokens.
+    pub avg_doc_len: f32,
+
+    /// Number of documents containing each token.
+    pub doc_freq: HashMap<String, u64>,
+}
+
+impl IdfStats {
+    /// Computes stats over a set of document texts.
+    pub fn from_texts<'a>(
+        field: impl Into<String>,
+        analyzer: Analyzer,
+        texts: impl IntoIterator<Item = &'a str>,
+    ) -> Self {
+        let mut doc_count = 0u64;
+        let mut total_len = 0u64;
+        let mut doc_freq: HashMap<String, u64> = HashMap::new();
+
+        for text in texts {
+            let tokens = analyzer.terms(text);
+            doc_count += 1;
+            total_len += tokens.len() as u64;
+
+            let mut seen: Vec<&String> = tokens.iter().collect();
+            seen.sort();
+            seen.dedup();
+            for token in seen {
+                *doc_freq.entry(token.clone()).or_insert(0) += 1;
+            }
+        }
+
+        let avg_doc_len = if doc_count == 0 {
+            0.0
+        } else {
+            total_len as f32 / doc_count as f32
+        };
+
+        Self {
+            field: field.into(),
+            analyzer,
+            doc_count,
+            avg_doc_len,
+            doc_freq,
+        }
+    }
+
+    /// BM25 inverse document frequency (always positive).
+    pub fn idf(&self, token: &str) -> f32 {
+        let n = self.doc_count as f32;
+        let df = self.doc_freq.get(token).copied().unwrap_or(0) as f32;
+        (1.0 + (n - df + 0.5) / (df + 0.5)).ln()
+    }
+}
+
+/// Produces sparse vectors from text.
+pub trait SparseEncoder: Send + Sync {
+    /// Encodes text for storage alongside a document.
+    fn encode_document(&self, text: &str) -> SparseVector;
+
+    /// Encodes text for use as a query.
+    fn encode_query(&self, text: &str) -> SparseVector;
+}
+
+/// BM25 sparse encoder.
+///
+/// Document vectors carry the BM25 term-frequency component; query vectors
+/// carry IDF weights, so their dot product is the BM25 score.
+#[derive(Debug, Clone)]
+pub struct Bm25Encoder {
+    stats: IdfStats,
+    k1: f32,
+    b: f32,
+}
+
+impl Bm25Encoder {
+    /// Creates an encoder with the standard parameters (k1 = 1.2, b = 0.75).
+    pub fn new(stats: IdfStats) -> Self {
+        Self {
+            stats,
+            k1: 1.2,
+            b: 0.75,
+        }
+    }
+
+    /// Overrides the BM25 `k1` and `b` parameters.
+    pub fn with_params(mut self, k1: f32, b: f32) -> Self {
+        self.k1 = k1;
+        self.b = b;
+        self
+    }
+
+    pub fn stats(&self) -> &IdfStats {
+        &self.stats
+    }
+
+    /// Encodes a query with overridden analysis settings (e.g. a different
+    /// stemming language for this query). Stopwords and synonyms still apply.
+    pub fn encode_query_with(&self, text: &str, settings: &AnalyzerSettings) -> SparseVector {
+        let analyzer = self.stats.analyzer.clone().with_settings(settings.clone());
+        self.query_vector(analyzer.terms(text))
+    }
+
+    fn query_vector(&self, mut tokens: Vec<String>) -> SparseVector {
+        tokens.sort();
+        tokens.dedup();
+        SparseVector::from_pairs(
+            tokens
+                .iter()
+                .map(|token| (Tokenizer::token_id(token), self.stats.idf(token))),
+        )
+    }
+}
+
+impl SparseEncoder for Bm25Encoder {
+    fn encode_document(&self, text: &str) -> SparseVector {
+        let tokens = self.stats.analyzer.terms(text);
+        let doc_len = tokens.len() as f32;
+        let avg_len = if self.stats.avg_doc_len > 0.0 {
+            self.stats.avg_doc_len
+        } else {
+            doc_len.max(1.0)
+        };
+
+        let mut tf: HashMap<&str, f32> = HashMap::new();
+        for token in &tokens {
+            *tf.entry(token.as_str()).or_insert(0.0) += 1.0;
+        }
+
+        let norm = self.k1 * (1.0 - self.b + self.b * doc_len / avg_len);
+        SparseVector::from_pairs(tf.into_iter().map(|(token, freq)| {
+            (
+                Tokenizer::token_id(token),
+                freq * (self.k1 + 1.0) / (freq + norm),
+            )
+        }))
+    }
+
+    fn encode_query(&self, text: &str) -> SparseVector {
+        self.query_vector(self.stats.analyzer.terms(text))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analysis::Language;
+
+    fn stats() -> IdfStats {
+        IdfStats::from_texts(
+            DEFAULT_TEXT_FIELD,
+            Analyzer::default(),
+            ["the quick brown fox", "the lazy dog", "the quick dog jumps"],
+        )
+    }
+
+    #[test]
+    fn test_idf_stats() {
+        let stats = stats();
+        assert_eq!(stats.doc_count, 3);
+        assert!((stats.avg_doc_len - 11.0 / 3.0).abs() < 1e-6);
+        assert_eq!(stats.doc_freq["the"], 3);
+        assert!(stats.idf("fox") > stats.idf("quick"));
+        assert!(stats.idf("quick") > stats.idf("the"));
+        assert!(stats.idf("the") > 0.0);
+    }
+
+    #[test]
+    fn test_sparse_vector_from_pairs_and_dot() {
+        let a = SparseVector::from_pairs([(5, 1.0), (1, 2.0), (5, 0.5), (9, 0.0)]);
+        assert_eq!(a.indices, vec![1, 5]);
+        assert_eq!(a.values, vec![2.0, 1.5]);
+
+        let b = SparseVector::from_pairs([(5, 2.0), (7, 3.0)]);
+        assert!((a.dot(&b) - 3.0).abs() < 1e-6);
+
+        a.validate().unwrap();
+        let unsorted = SparseVector {
+            indices: vec![5, 1],
+            values: vec![1.0, 1.0],
+        };
+        assert!(unsorted.validate().is_err());
+    }
+
+    #[test]
+    fn test_bm25_ranks_matching_documents() {
+        let encoder = Bm25Encoder::new(stats());
+        let query = encoder.encode_query("quick fox");
+        let fox = encoder.encode_document("the quick brown fox");
+        let dog = encoder.encode_document("the lazy dog");
+
+        assert_eq!(query.len(), 2);
+        assert!(query.dot(&fox) > query.dot(&dog));
+        assert_eq!(query.dot(&dog), 0.0);
+    }
+
+    #[test]
+    fn test_query_analyzer_override() {
+        let encoder = Bm25Encoder::new(stats());
+        let settings = AnalyzerSettings {
+            language: Language::English,
+            ..Default::default()
+        };
+        let query = encoder.encode_query_with("jumping dogs", &settings);
+        let doc = encoder.encode_document("the quick dog jumps");
+
+        assert!(encoder.encode_query("jumping dogs").dot(&doc) == 0.0);
+        // "dogs" stems to the indexed "dog"
+        assert!(query.dot(&doc) > 0.0);
+    }
+}
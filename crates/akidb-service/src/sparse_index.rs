@@ -0,0 +1,229 @@
+//! Stored sparse vectors.
+//!
+//! A collection with a sparse index keeps one [`SparseVector`] per document
+//! (e.g. a SPLADE or BM25 embedding computed by the client) next to its dense
+//! vector. Vectors are held in an inverted index from dimension to postings,
+//! so a search only touches the postings of the query's non-zero dimensions
+//! and scores each document by its dot product with the query. The vectors
+//! themselves are stored with their documents and the settings with the
+//! collection, so the index is rebuilt when the collection is loaded. See
+//! `CollectionService::set_sparse_index` and `CollectionService::sparse_search`.
+
+use akidb_core::{CoreError, CoreResult, DocumentId};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+
+use crate::sparse::SparseVector;
+
+/// Default maximum non-zero entries per stored vector.
+pub const DEFAULT_MAX_NNZ: usize = 1_024;
+
+fn default_max_nnz() -> usize {
+    DEFAULT_MAX_NNZ
+}
+
+/// Sparse index settings of a collection.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SparseIndexConfig {
+    /// Exclusive upper bound on indices, e.g. the model's vocabulary size
+    /// (unbounded if unset).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub dimension: Option<u32>,
+
+    /// Maximum non-zero entries per vector.
+    #[serde(default = "default_max_nnz")]
+    pub max_nnz: usize,
+}
+
+impl Default for SparseIndexConfig {
+    fn default() -> Self {
+        Self {
+            dimension: None,
+            max_nnz: DEFAULT_MAX_NNZ,
+        }
+    }
+}
+
+impl SparseIndexConfig {
+    pub fn validate(&self) -> CoreResult<()> {
+        if self.dimension == Some(0) || self.max_nnz == 0 {
+            return Err(CoreError::ValidationError(
+                "sparse index dimension and max_nnz must be greater than 0".to_string(),
+            ));
+        }
+        Ok(())
+    }
+
+    /// Reject malformed vectors and vectors exceeding these settings.
+    pub fn check(&self, vector: &SparseVector) -> CoreResult<()> {
+        vector.validate()?;
+        if vector.len() > self.max_nnz {
+            return Err(CoreError::ValidationError(format!(
+                "sparse vector has {} non-zero entries (max {})",
+                vector.len(),
+                self.max_nnz
+            )));
+        }
+        if let (Some(dimension), Some(&last)) = (self.dimension, vector.indices.last()) {
+            if last >= dimension {
+                return Err(CoreError::ValidationError(format!(
+                    "sparse vector index {} is out of range (dimension {})",
+                    last, dimension
+                )));
+            }
+        }
+        Ok(())
+    }
+}
+
+/// Inverted index over a collection's sparse vectors.
+#[derive(Debug, Clone, Default)]
+pub struct SparseIndex {
+    config: SparseIndexConfig,
+    vectors: HashMap<DocumentId, SparseVector>,
+    postings: HashMap<u32, HashMap<DocumentId, f32>>,
+}
+
+impl SparseIndex {
+    pub fn new(config: SparseIndexConfig) -> Self {
+        Self {
+            config,
+            ..Default::default()
+        }
+    }
+
+    pub fn config(&self) -> &SparseIndexConfig {
+        &self.config
+    }
+
+    /// Check that settings are valid and every stored vector satisfies them.
+    pub fn check_config(&self, config: &SparseIndexConfig) -> CoreResult<()> {
+        config.validate()?;
+        for (doc_id, vector) in &self.vectors {
+            config.check(vector).map_err(|e| {
+                CoreError::ValidationError(format!("stored document {}: {}", doc_id, e))
+            })?;
+        }
+        Ok(())
+    }
+
+    /// Replace the settings; fails if a stored vector violates them.
+    pub fn set_config(&mut self, config: SparseIndexConfig) -> CoreResult<()> {
+        self.check_config(&config)?;
+        self.config = config;
+        Ok(())
+    }
+
+    /// Number of documents with a sparse vector.
+    pub fn len(&self) -> usize {
+        self.vectors.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.vectors.is_empty()
+    }
+
+    pub fn get(&self, doc_id: DocumentId) -> Option<&SparseVector> {
+        self.vectors.get(&doc_id)
+    }
+
+    /// IDs of the documents with a sparse vector.
+    pub fn doc_ids(&self) -> impl Iterator<Item = DocumentId> + '_ {
+        self.vectors.keys().copied()
+    }
+
+    /// Store a document's vector, replacing any previous one. An empty
+    /// vector removes it.
+    pub fn insert(&mut self, doc_id: DocumentId, vector: SparseVector) -> CoreResult<()> {
+        self.config.check(&vector)?;
+        self.remove(doc_id);
+        if vector.is_empty() {
+            return Ok(());
+        }
+        for (&index, &value) in vector.indices.iter().zip(&vector.values) {
+            self.postings
+                .entry(index)
+                .or_default()
+                .insert(doc_id, value);
+        }
+        self.vectors.insert(doc_id, vector);
+        Ok(())
+    }
+
+    pub fn remove(&mut self, doc_id: DocumentId) -> Option<SparseVector> {
+        let vector = self.vectors.remove(&doc_id)?;
+        for index in &vector.indices {
+            if let Some(postings) = self.postings.get_mut(index) {
+                postings.remove(&doc_id);
+                if postings.is_empty() {
+                    self.postings.remove(index);
+                }
+            }
+        }
+        Some(vector)
+    }
+
+    /// The `top_k` documents sharing a dimension with `query`, by descending
+    /// dot product.
+    pub fn search(&self, query: &SparseVector, top_k: usize) -> Vec<(DocumentId, f32)> {
+        let mut scores: HashMap<DocumentId, f32> = HashMap::new();
+        for (index, weight) in query.indices.iter().zip(&query.values) {
+            if let Some(postings) = self.postings.get(index) {
+                for (doc_id, value) in postings {
+                    *scores.entry(*doc_id).or_insert(0.0) += weight * value;
+                }
+            }
+        }
+        let mut ranked: Vec<_> = scores.into_iter().collect();
+        ranked.sort_by(|a, b| {
+            b.1.total_cmp(&a.1)
+                .then_with(|| a.0.as_uuid().cmp(&b.0.as_uuid()))
+        });
+        ranked.truncate(top_k);
+        ranked
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_sparse_index_search_and_remove() {
+        let mut index = SparseIndex::new(SparseIndexConfig {
+            dimension: Some(100),
+            max_nnz: 3,
+        });
+        let (a, b) = (DocumentId::new(), DocumentId::new());
+        index
+            .insert(a, SparseVector::from_pairs([(1, 1.0), (7, 2.0)]))
+            .unwrap();
+        index
+            .insert(b, SparseVector::from_pairs([(7, 0.5), (42, 3.0)]))
+            .unwrap();
+
+        let query = SparseVector::from_pairs([(7, 1.0), (42, 1.0)]);
+        assert_eq!(index.search(&query, 10), vec![(b, 3.5), (a, 2.0)]);
+        assert_eq!(index.search(&query, 1).len(), 1);
+
+        // Replacing a vector drops its old postings
+        index
+            .insert(a, SparseVector::from_pairs([(1, 1.0)]))
+            .unwrap();
+        assert_eq!(index.search(&query, 10), vec![(b, 3.5)]);
+        assert!(index.remove(b).is_some());
+        assert!(index.search(&query, 10).is_empty());
+        assert_eq!(index.len(), 1);
+
+        let out_of_range = SparseVector::from_pairs([(100, 1.0)]);
+        assert!(index.insert(b, out_of_range).is_err());
+        let too_dense = SparseVector::from_pairs([(1, 1.0), (2, 1.0), (3, 1.0), (4, 1.0)]);
+        assert!(index.insert(b, too_dense).is_err());
+        assert!(index
+            .set_config(SparseIndexConfig {
+                dimension: Some(1),
+                max_nnz: 3,
+            })
+            .is_err());
+    }
+}
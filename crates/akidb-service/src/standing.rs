@@ -0,0 +1,255 @@
+//! Standing queries (continuous query subscriptions).
+//!
+//! A standing query registers a query vector, `top_k` and an optional metadata
+//! filter against a collection. Every insert into the collection is scored
+//! against it, and a match is pushed to subscribers whenever the new document
+//! would rank in the query's current top-K, e.g. to alert on new content
+//! similar to a known item. See `CollectionService::create_standing_query`.
+//!
+//! The top-K is computed when the query is registered and updated as matching
+//! documents arrive; deleting a document does not free its slot.
+
+use akidb_core::{
+    CollectionId, CoreError, CoreResult, DistanceMetric, DocumentId, SubscriptionId, VectorDocument,
+};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value as JsonValue};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Mutex;
+use tokio::sync::broadcast;
+
+/// Maximum `top_k` of a standing query.
+pub const MAX_STANDING_QUERY_TOP_K: usize = 1000;
+
+/// Matches buffered per subscription before slow subscribers start lagging.
+pub const SUBSCRIPTION_BUFFER: usize = 256;
+
+/// Standing query registration.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct StandingQuerySpec {
+    pub vector: Vec<f32>,
+    pub top_k: usize,
+
+    /// Metadata fields new documents must have, with exactly these values.
+    #[serde(default)]
+    pub filter: Option<Map<String, JsonValue>>,
+}
+
+impl StandingQuerySpec {
+    /// Returns true if `metadata` satisfies the filter.
+    pub fn matches_filter(&self, metadata: Option<&JsonValue>) -> bool {
+        let Some(filter) = &self.filter else {
+            return true;
+        };
+        let fields = metadata.and_then(JsonValue::as_object);
+        filter
+            .iter()
+            .all(|(key, value)| fields.and_then(|f| f.get(key)) == Some(value))
+    }
+}
+
+/// A registered standing query.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct StandingQueryInfo {
+    pub id: SubscriptionId,
+    pub collection_id: CollectionId,
+    pub spec: StandingQuerySpec,
+
+    /// Matches pushed so far.
+    pub matches: u64,
+
+    pub created_at: DateTime<Utc>,
+}
+
+/// A newly inserted document that entered a standing query's top-K.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct StandingQueryMatch {
+    pub subscription_id: SubscriptionId,
+    pub collection_id: CollectionId,
+    pub doc_id: DocumentId,
+    pub external_id: Option<String>,
+    pub score: f32,
+
+    /// 1-based position in the top-K.
+    pub rank: usize,
+
+    pub metadata: Option<JsonValue>,
+    pub matched_at: DateTime<Utc>,
+}
+
+/// A standing query and its subscribers.
+#[derive(Debug)]
+pub struct StandingQuery {
+    id: SubscriptionId,
+    collection_id: CollectionId,
+    spec: StandingQuerySpec,
+    metric: DistanceMetric,
+    created_at: DateTime<Utc>,
+
+    // Current top-K scores, best first
+    top: Mutex<Vec<f32>>,
+    matches: AtomicU64,
+    sender: broadcast::Sender<StandingQueryMatch>,
+}
+
+impl StandingQuery {
+    /// Registers a query against the collection's existing `documents`.
+    pub fn new<'a>(
+        collection_id: CollectionId,
+        spec: StandingQuerySpec,
+        metric: DistanceMetric,
+        documents: impl IntoIterator<Item = &'a VectorDocument>,
+    ) -> CoreResult<Self> {
+        if spec.top_k == 0 || spec.top_k > MAX_STANDING_QUERY_TOP_K {
+            return Err(CoreError::ValidationError(format!(
+                "top_k must be between 1 and {}",
+                MAX_STANDING_QUERY_TOP_K
+            )));
+        }
+
+        let mut top: Vec<f32> = documents
+            .into_iter()
+            .filter(|doc| spec.matches_filter(doc.metadata.as_ref()))
+            .map(|doc| metric.compute(&spec.vector, &doc.vector))
+            .collect();
+        let higher_is_better = !matches!(metric, DistanceMetric::L2);
+        top.sort_by(|a, b| {
+            let order = a.partial_cmp(b).unwrap_or(std::cmp::Ordering::Equal);
+            if higher_is_better {
+                order.reverse()
+            } else {
+                order
+            }
+        });
+        top.truncate(spec.top_k);
+
+        let (sender, _) = broadcast::channel(SUBSCRIPTION_BUFFER);
+        Ok(Self {
+            id: SubscriptionId::new(),
+            collection_id,
+            spec,
+            metric,
+            created_at: Utc::now(),
+            top: Mutex::new(top),
+            matches: AtomicU64::new(0),
+            sender,
+        })
+    }
+
+    pub fn id(&self) -> SubscriptionId {
+        self.id
+    }
+
+    pub fn collection_id(&self) -> CollectionId {
+        self.collection_id
+    }
+
+    pub fn info(&self) -> StandingQueryInfo {
+        StandingQueryInfo {
+            id: self.id,
+            collection_id: self.collection_id,
+            spec: self.spec.clone(),
+            matches: self.matches.load(Ordering::Relaxed),
+            created_at: self.created_at,
+        }
+    }
+
+    /// Receives matches from now on.
+    pub fn subscribe(&self) -> broadcast::Receiver<StandingQueryMatch> {
+        self.sender.subscribe()
+    }
+
+    /// Scores a newly inserted document, pushing and returning a match if it
+    /// enters the top-K.
+    pub fn check(&self, doc: &VectorDocument) -> Option<StandingQueryMatch> {
+        if doc.vector.len() != self.spec.vector.len()
+            || !self.spec.matches_filter(doc.metadata.as_ref())
+        {
+            return None;
+        }
+        let score = self.metric.compute(&self.spec.vector, &doc.vector);
+        let higher_is_better = !matches!(self.metric, DistanceMetric::L2);
+        let better = |a: f32, b: f32| if higher_is_better { a > b } else { a < b };
+
+        let rank = {
+            let mut top = self.top.lock().unwrap();
+            let position = top
+                .iter()
+                .position(|&s| better(score, s))
+                .unwrap_or(top.len());
+            if position >= self.spec.top_k {
+                return None;
+            }
+            top.insert(position, score);
+            top.truncate(self.spec.top_k);
+            position + 1
+        };
+
+        self.matches.fetch_add(1, Ordering::Relaxed);
+        let matched = StandingQueryMatch {
+            subscription_id: self.id,
+            collection_id: self.collection_id,
+            doc_id: doc.doc_id,
+            external_id: doc.external_id.clone(),
+            score,
+            rank,
+            metadata: doc.metadata.clone(),
+            matched_at: Utc::now(),
+        };
+        // No subscribers connected is fine; the match is dropped
+        let _ = self.sender.send(matched.clone());
+        Some(matched)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn doc(vector: Vec<f32>) -> VectorDocument {
+        VectorDocument::new(DocumentId::new(), vector)
+    }
+
+    fn spec(top_k: usize) -> StandingQuerySpec {
+        StandingQuerySpec {
+            vector: vec![1.0, 0.0],
+            top_k,
+            filter: None,
+        }
+    }
+
+    #[test]
+    fn test_enters_top_k() {
+        let existing = [doc(vec![1.0, 0.1]), doc(vec![0.0, 1.0])];
+        let query = StandingQuery::new(CollectionId::new(), spec(2), DistanceMetric::L2, &existing)
+            .unwrap();
+        let mut rx = query.subscribe();
+
+        // Farther than both existing documents
+        assert!(query.check(&doc(vec![-1.0, 0.0])).is_none());
+
+        let matched = query.check(&doc(vec![1.0, 0.0])).unwrap();
+        assert_eq!(matched.rank, 1);
+        assert_eq!(rx.try_recv().unwrap().doc_id, matched.doc_id);
+
+        // The top-2 is now [0.0, 0.1]; 0.5 no longer qualifies
+        assert!(query.check(&doc(vec![1.0, 0.5])).is_none());
+        assert_eq!(query.info().matches, 1);
+    }
+
+    #[test]
+    fn test_filter() {
+        let mut spec = spec(5);
+        spec.filter = Some(json!({"lang": "en"}).as_object().unwrap().clone());
+        let query =
+            StandingQuery::new(CollectionId::new(), spec, DistanceMetric::Cosine, []).unwrap();
+
+        assert!(query.check(&doc(vec![1.0, 0.0])).is_none());
+        let en = doc(vec![1.0, 0.0]).with_metadata(json!({"lang": "en"}));
+        assert!(query.check(&en).is_some());
+        let fr = doc(vec![1.0, 0.0]).with_metadata(json!({"lang": "fr"}));
+        assert!(query.check(&fr).is_none());
+    }
+}
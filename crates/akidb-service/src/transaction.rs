@@ -0,0 +1,183 @@
+//! Write transactions.
+//!
+//! A transaction buffers inserts and deletes across several calls, possibly
+//! spanning collections. Nothing is visible to readers until the transaction
+//! is committed, and then all of its writes appear at once; a rollback
+//! discards them. This makes multi-step changes, such as replacing a
+//! document (delete + insert), atomic from readers' perspective. Commits
+//! are not atomic across crashes: the writes are logged one by one (see
+//! `CollectionService::commit_transaction`).
+//!
+//! Transactions are bounded: at most `MAX_TRANSACTION_OPS` writes, and a
+//! transaction not committed within its timeout (default
+//! `DEFAULT_TRANSACTION_TIMEOUT`) expires and is discarded.
+//! See `CollectionService::begin_transaction`.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, TransactionId, VectorDocument};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use std::time::Duration;
+
+/// Maximum writes buffered in one transaction.
+pub const MAX_TRANSACTION_OPS: usize = 10_000;
+
+/// Maximum transactions open at once.
+pub const MAX_OPEN_TRANSACTIONS: usize = 1_000;
+
+/// Time a transaction may stay open when no timeout is requested.
+pub const DEFAULT_TRANSACTION_TIMEOUT: Duration = Duration::from_secs(60);
+
+/// Longest timeout a transaction may request.
+pub const MAX_TRANSACTION_TIMEOUT: Duration = Duration::from_secs(15 * 60);
+
+/// A buffered write.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(tag = "op", rename_all = "snake_case")]
+pub enum TransactionOp {
+    Insert {
+        collection_id: CollectionId,
+        document: VectorDocument,
+    },
+    Delete {
+        collection_id: CollectionId,
+        doc_id: DocumentId,
+    },
+}
+
+impl TransactionOp {
+    pub fn collection_id(&self) -> CollectionId {
+        match self {
+            Self::Insert { collection_id, .. } | Self::Delete { collection_id, .. } => {
+                *collection_id
+            }
+        }
+    }
+
+    pub fn doc_id(&self) -> DocumentId {
+        match self {
+            Self::Insert { document, .. } => document.doc_id,
+            Self::Delete { doc_id, .. } => *doc_id,
+        }
+    }
+}
+
+/// An open transaction.
+#[derive(Debug, Clone)]
+pub struct Transaction {
+    pub id: TransactionId,
+    pub ops: Vec<TransactionOp>,
+    pub created_at: DateTime<Utc>,
+    pub expires_at: DateTime<Utc>,
+}
+
+impl Transaction {
+    /// Start a transaction expiring after `timeout` (capped at
+    /// `MAX_TRANSACTION_TIMEOUT`).
+    pub fn new(timeout: Option<Duration>) -> CoreResult<Self> {
+        let timeout = timeout.unwrap_or(DEFAULT_TRANSACTION_TIMEOUT);
+        if timeout.is_zero() || timeout > MAX_TRANSACTION_TIMEOUT {
+            return Err(CoreError::ValidationError(format!(
+                "transaction timeout must be between 1s and {}s",
+                MAX_TRANSACTION_TIMEOUT.as_secs()
+            )));
+        }
+        let created_at = Utc::now();
+        Ok(Self {
+            id: TransactionId::new(),
+            ops: Vec::new(),
+            created_at,
+            expires_at: created_at
+                + chrono::Duration::from_std(timeout).unwrap_or(chrono::Duration::zero()),
+        })
+    }
+
+    pub fn is_expired(&self, now: DateTime<Utc>) -> bool {
+        now >= self.expires_at
+    }
+
+    /// Buffer a write.
+    pub fn push(&mut self, op: TransactionOp) -> CoreResult<()> {
+        if self.ops.len() >= MAX_TRANSACTION_OPS {
+            return Err(CoreError::ValidationError(format!(
+                "transaction {} exceeds {} operations",
+                self.id, MAX_TRANSACTION_OPS
+            )));
+        }
+        self.ops.push(op);
+        Ok(())
+    }
+
+    pub fn info(&self) -> TransactionInfo {
+        TransactionInfo {
+            id: self.id,
+            inserts: self
+                .ops
+                .iter()
+                .filter(|op| matches!(op, TransactionOp::Insert { .. }))
+                .count(),
+            deletes: self
+                .ops
+                .iter()
+                .filter(|op| matches!(op, TransactionOp::Delete { .. }))
+                .count(),
+            created_at: self.created_at,
+            expires_at: self.expires_at,
+        }
+    }
+}
+
+/// Summary of an open transaction.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct TransactionInfo {
+    pub id: TransactionId,
+
+    /// Buffered inserts and deletes.
+    pub inserts: usize,
+    pub deletes: usize,
+
+    pub created_at: DateTime<Utc>,
+    pub expires_at: DateTime<Utc>,
+}
+
+/// Result of a committed transaction.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CommitReport {
+    pub id: TransactionId,
+    pub inserted: usize,
+    pub deleted: usize,
+    pub committed_at: DateTime<Utc>,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_timeout_bounds() {
+        assert!(Transaction::new(None).is_ok());
+        assert!(Transaction::new(Some(Duration::ZERO)).is_err());
+        assert!(Transaction::new(Some(MAX_TRANSACTION_TIMEOUT + Duration::from_secs(1))).is_err());
+
+        let tx = Transaction::new(Some(Duration::from_secs(5))).unwrap();
+        assert!(!tx.is_expired(tx.created_at));
+        assert!(tx.is_expired(tx.created_at + chrono::Duration::seconds(5)));
+    }
+
+    #[test]
+    fn test_push_counts_ops() {
+        let mut tx = Transaction::new(None).unwrap();
+        let collection_id = CollectionId::new();
+        tx.push(TransactionOp::Delete {
+            collection_id,
+            doc_id: DocumentId::new(),
+        })
+        .unwrap();
+        tx.push(TransactionOp::Insert {
+            collection_id,
+            document: VectorDocument::new(DocumentId::new(), vec![1.0; 4]),
+        })
+        .unwrap();
+        let info = tx.info();
+        assert_eq!((info.inserts, info.deletes), (1, 1));
+    }
+}
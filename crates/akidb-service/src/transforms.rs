@@ -0,0 +1,176 @@
+//! Declarative document transforms for server-side copy and reindex.
+//!
+//! Transforms describe schema migrations (renaming or dropping metadata
+//! fields, truncating vectors to a smaller dimension) as data, so they can be
+//! sent to the server and applied during a copy without round-tripping
+//! documents through the client.
+
+use akidb_core::{CoreError, CoreResult, VectorDocument};
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+
+use crate::reindex::DocumentTransform;
+
+/// A single document transform. Metadata fields are top-level keys.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "op", rename_all = "snake_case")]
+pub enum Transform {
+    /// Rename a metadata field (overwrites `to` if present; no-op if `from` is missing).
+    RenameField { from: String, to: String },
+
+    /// Remove a metadata field.
+    DropField { field: String },
+
+    /// Keep the first `dimension` vector components (Matryoshka-style
+    /// embeddings), optionally re-normalizing to unit length.
+    TruncateDimension {
+        dimension: u32,
+        #[serde(default)]
+        normalize: bool,
+    },
+}
+
+impl Transform {
+    /// Applies the transform to a document.
+    pub fn apply(&self, doc: &mut VectorDocument) -> CoreResult<()> {
+        match self {
+            Self::RenameField { from, to } => {
+                if let Some(metadata) = doc.metadata.as_mut().and_then(|m| m.as_object_mut()) {
+                    if let Some(value) = metadata.remove(from) {
+                        metadata.insert(to.clone(), value);
+                    }
+                }
+            }
+            Self::DropField { field } => {
+                if let Some(metadata) = doc.metadata.as_mut().and_then(|m| m.as_object_mut()) {
+                    metadata.remove(field);
+                }
+            }
+            Self::TruncateDimension {
+                dimension,
+                normalize,
+            } => {
+                let dimension = *dimension as usize;
+                if dimension == 0 || dimension > doc.vector.len() {
+                    return Err(CoreError::ValidationError(format!(
+                        "cannot truncate {}-dimensional vector to {} dimensions",
+                        doc.vector.len(),
+                        dimension
+                    )));
+                }
+                doc.vector.truncate(dimension);
+                if *normalize {
+                    let norm = doc.vector.iter().map(|x| x * x).sum::<f32>().sqrt();
+                    if norm > 0.0 {
+                        doc.vector.iter_mut().for_each(|x| *x /= norm);
+                    }
+                }
+            }
+        }
+        Ok(())
+    }
+}
+
+/// An ordered list of transforms applied to every copied document.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(transparent)]
+pub struct TransformSpec(pub Vec<Transform>);
+
+impl TransformSpec {
+    pub fn new(transforms: Vec<Transform>) -> Self {
+        Self(transforms)
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.0.is_empty()
+    }
+
+    /// Applies all transforms in order.
+    pub fn apply(&self, mut doc: VectorDocument) -> CoreResult<VectorDocument> {
+        for transform in &self.0 {
+            transform.apply(&mut doc)?;
+        }
+        Ok(doc)
+    }
+
+    /// Output dimension after the last truncation, if the spec truncates.
+    pub fn output_dimension(&self) -> Option<u32> {
+        self.0.iter().rev().find_map(|t| match t {
+            Transform::TruncateDimension { dimension, .. } => Some(*dimension),
+            _ => None,
+        })
+    }
+
+    /// Converts the spec into a reindex document transform.
+    pub fn into_document_transform(self) -> DocumentTransform {
+        Arc::new(move |doc| self.apply(doc).map(Some))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use akidb_core::DocumentId;
+    use serde_json::json;
+
+    fn doc() -> VectorDocument {
+        VectorDocument::new(DocumentId::new(), vec![3.0, 4.0, 12.0])
+            .with_metadata(json!({ "title": "a", "legacy": true }))
+    }
+
+    #[test]
+    fn test_metadata_transforms() {
+        let spec = TransformSpec::new(vec![
+            Transform::RenameField {
+                from: "title".to_string(),
+                to: "name".to_string(),
+            },
+            Transform::DropField {
+                field: "legacy".to_string(),
+            },
+            Transform::RenameField {
+                from: "missing".to_string(),
+                to: "other".to_string(),
+            },
+        ]);
+
+        let out = spec.apply(doc()).unwrap();
+        assert_eq!(out.metadata, Some(json!({ "name": "a" })));
+    }
+
+    #[test]
+    fn test_truncate_dimension() {
+        let spec = TransformSpec::new(vec![Transform::TruncateDimension {
+            dimension: 2,
+            normalize: true,
+        }]);
+        assert_eq!(spec.output_dimension(), Some(2));
+
+        let out = spec.apply(doc()).unwrap();
+        assert_eq!(out.vector, vec![0.6, 0.8]);
+
+        let too_large = Transform::TruncateDimension {
+            dimension: 8,
+            normalize: false,
+        };
+        assert!(too_large.apply(&mut doc()).is_err());
+    }
+
+    #[test]
+    fn test_spec_deserializes_from_json() {
+        let spec: TransformSpec = serde_json::from_value(json!([
+            { "op": "rename_field", "from": "a", "to": "b" },
+            { "op": "drop_field", "field": "c" },
+            { "op": "truncate_dimension", "dimension": 256 }
+        ]))
+        .unwrap();
+        assert_eq!(spec.0.len(), 3);
+        assert_eq!(
+            spec.0[2],
+            Transform::TruncateDimension {
+                dimension: 256,
+                normalize: false
+            }
+        );
+    }
+}
@@ -0,0 +1,331 @@
+//! Resumable chunked uploads for import files.
+//!
+//! Import files too large for one request (or for memory) are sent as
+//! numbered parts: create an upload, send each part (in any order, in
+//! parallel, retrying any that fail), then complete it. Parts are spooled to
+//! disk and the file is imported in a single streaming pass on completion.
+//! An upload lists the parts that arrived, so an interrupted client only
+//! re-sends the missing ones. See `CollectionService::create_upload`.
+//!
+//! Alternatively, with an import bucket configured, the client gets a signed
+//! URL and PUTs the whole file straight to object storage, keeping large
+//! payloads off the API servers; completing the upload then imports the object
+//! (see `CollectionService::create_upload_url`).
+//!
+//! The file format is NDJSON, one `ImportRecord` per line. Records may span
+//! part boundaries. Vectors are JSON arrays or packed by a `VectorCodec`, as
+//! in export files.
+//!
+//! An upload of an export file can carry the export's manifest. Completion
+//! then checks every part (or the uploaded object) against its record count
+//! and checksum, and imports nothing if one is missing, cut short or altered.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, DocumentId, UploadId, VectorDocument};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use serde_json::Value as JsonValue;
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+use std::time::Duration;
+
+use crate::manifest::ExportManifest;
+use crate::vector_codec::EncodedVector;
+
+/// Maximum size of one part.
+pub const MAX_UPLOAD_PART_BYTES: usize = 64 * 1024 * 1024;
+
+/// Maximum number of parts per upload (part numbers are 1-based).
+pub const MAX_UPLOAD_PARTS: u32 = 10_000;
+
+/// Maximum number of per-record errors kept in an import report.
+pub const MAX_IMPORT_ERRORS: usize = 100;
+
+/// Default validity of a signed upload URL.
+pub const DEFAULT_UPLOAD_URL_EXPIRY: Duration = Duration::from_secs(3600);
+
+/// Lifecycle state of an upload.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum UploadStatus {
+    /// Accepting parts.
+    Open,
+
+    /// Completion in progress; parts are being imported.
+    Importing,
+
+    /// All records were processed (see the report for failures).
+    Completed,
+
+    /// Cancelled by a caller; parts were discarded.
+    Aborted,
+}
+
+/// A part received for an upload.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct UploadPart {
+    pub part_number: u32,
+    pub size_bytes: u64,
+    pub uploaded_at: DateTime<Utc>,
+}
+
+/// One line of an import file.
+#[derive(Debug, Clone, Deserialize)]
+pub struct ImportRecord {
+    /// Document ID (generated if absent).
+    #[serde(default)]
+    pub id: Option<DocumentId>,
+
+    #[serde(default)]
+    pub external_id: Option<String>,
+
+    pub vector: EncodedVector,
+
+    #[serde(default)]
+    pub metadata: Option<JsonValue>,
+}
+
+impl ImportRecord {
+    /// The document to insert; fails if the vector cannot be decoded.
+    pub fn into_document(self) -> CoreResult<VectorDocument> {
+        let vector = self.vector.decode()?;
+        let mut doc = VectorDocument::new(self.id.unwrap_or_else(DocumentId::new), vector);
+        if let Some(external_id) = self.external_id {
+            doc = doc.with_external_id(external_id);
+        }
+        if let Some(metadata) = self.metadata {
+            doc = doc.with_metadata(metadata);
+        }
+        Ok(doc)
+    }
+}
+
+/// A record that could not be imported.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ImportError {
+    /// 1-based line number in the import file.
+    pub line: u64,
+    pub message: String,
+}
+
+/// Outcome of completing an upload.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct ImportReport {
+    /// Non-empty lines processed.
+    pub records: u64,
+    pub inserted: u64,
+    pub failed: u64,
+
+    /// First `MAX_IMPORT_ERRORS` failures.
+    pub errors: Vec<ImportError>,
+}
+
+impl ImportReport {
+    pub(crate) fn record_error(&mut self, line: u64, message: String) {
+        self.failed += 1;
+        if self.errors.len() < MAX_IMPORT_ERRORS {
+            self.errors.push(ImportError { line, message });
+        }
+    }
+}
+
+/// A resumable upload of an import file into a collection.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct Upload {
+    pub id: UploadId,
+    pub collection_id: CollectionId,
+    pub status: UploadStatus,
+
+    /// Parts received so far, by part number.
+    pub parts: BTreeMap<u32, UploadPart>,
+
+    /// Set once the upload is completed.
+    pub report: Option<ImportReport>,
+
+    /// Object the client uploads to with a signed URL (instead of sending parts).
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub object_key: Option<String>,
+
+    /// Manifest of the export file being uploaded; the data is checked
+    /// against it before anything is imported.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub manifest: Option<ExportManifest>,
+
+    pub created_at: DateTime<Utc>,
+    pub updated_at: DateTime<Utc>,
+}
+
+impl Upload {
+    /// Creates an open upload with no parts.
+    pub fn new(collection_id: CollectionId) -> Self {
+        let now = Utc::now();
+        Self {
+            id: UploadId::new(),
+            collection_id,
+            status: UploadStatus::Open,
+            parts: BTreeMap::new(),
+            report: None,
+            object_key: None,
+            manifest: None,
+            created_at: now,
+            updated_at: now,
+        }
+    }
+
+    /// Total bytes received.
+    pub fn size_bytes(&self) -> u64 {
+        self.parts.values().map(|p| p.size_bytes).sum()
+    }
+
+    /// Part numbers missing below the highest part received.
+    pub fn missing_parts(&self) -> Vec<u32> {
+        let last = self.parts.keys().next_back().copied().unwrap_or(0);
+        (1..=last).filter(|n| !self.parts.contains_key(n)).collect()
+    }
+
+    /// Directory holding the spooled parts.
+    pub fn dir(&self) -> PathBuf {
+        upload_dir(self.id)
+    }
+
+    /// Path of a spooled part.
+    pub fn part_path(&self, part_number: u32) -> PathBuf {
+        self.dir().join(format!("part-{:05}", part_number))
+    }
+}
+
+/// A URL the client can PUT an import file to directly.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SignedUploadUrl {
+    pub upload: Upload,
+
+    /// Presigned object storage URL (HTTP PUT).
+    pub url: String,
+
+    pub expires_at: DateTime<Utc>,
+}
+
+/// Object key for an upload sent with a signed URL.
+pub fn upload_object_key(collection_id: CollectionId, upload_id: UploadId) -> String {
+    format!("{}/{}.ndjson", collection_id, upload_id)
+}
+
+/// Splits NDJSON data arriving in arbitrary chunks into numbered lines.
+#[derive(Debug, Default)]
+pub(crate) struct LineSplitter {
+    // Bytes of a line that continues into the next chunk
+    carry: Vec<u8>,
+    line: u64,
+}
+
+impl LineSplitter {
+    /// Appends a chunk and returns the lines it completes (1-based numbers).
+    pub(crate) fn push(&mut self, data: &[u8]) -> Vec<(u64, Vec<u8>)> {
+        self.carry.extend_from_slice(data);
+        let Some(end) = self.carry.iter().rposition(|&b| b == b'\n') else {
+            return Vec::new();
+        };
+        let rest = self.carry.split_off(end + 1);
+        let complete = std::mem::replace(&mut self.carry, rest);
+        complete[..end]
+            .split(|&b| b == b'\n')
+            .map(|line| {
+                self.line += 1;
+                (self.line, line.to_vec())
+            })
+            .collect()
+    }
+
+    /// Returns the last line if the data did not end with a newline.
+    pub(crate) fn finish(self) -> Option<(u64, Vec<u8>)> {
+        (!self.carry.is_empty()).then(|| (self.line + 1, self.carry))
+    }
+}
+
+/// Directory holding the spooled parts of an upload.
+pub fn upload_dir(upload_id: UploadId) -> PathBuf {
+    std::env::temp_dir()
+        .join("akidb-uploads")
+        .join(upload_id.to_string())
+}
+
+/// Validates a part number and size.
+pub fn validate_part(part_number: u32, size: usize) -> CoreResult<()> {
+    if !(1..=MAX_UPLOAD_PARTS).contains(&part_number) {
+        return Err(CoreError::ValidationError(format!(
+            "part number must be between 1 and {} (got {})",
+            MAX_UPLOAD_PARTS, part_number
+        )));
+    }
+    if size == 0 || size > MAX_UPLOAD_PART_BYTES {
+        return Err(CoreError::ValidationError(format!(
+            "part size must be between 1 and {} bytes (got {})",
+            MAX_UPLOAD_PART_BYTES, size
+        )));
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn part(part_number: u32) -> UploadPart {
+        UploadPart {
+            part_number,
+            size_bytes: 10,
+            uploaded_at: Utc::now(),
+        }
+    }
+
+    #[test]
+    fn test_missing_parts() {
+        let mut upload = Upload::new(CollectionId::new());
+        assert!(upload.missing_parts().is_empty());
+
+        for n in [1, 2, 5] {
+            upload.parts.insert(n, part(n));
+        }
+        assert_eq!(upload.missing_parts(), vec![3, 4]);
+        assert_eq!(upload.size_bytes(), 30);
+    }
+
+    #[test]
+    fn test_validate_part() {
+        assert!(validate_part(1, 1).is_ok());
+        assert!(validate_part(0, 1).is_err());
+        assert!(validate_part(MAX_UPLOAD_PARTS + 1, 1).is_err());
+        assert!(validate_part(1, 0).is_err());
+        assert!(validate_part(1, MAX_UPLOAD_PART_BYTES + 1).is_err());
+    }
+
+    #[test]
+    fn test_line_splitter() {
+        let mut lines = LineSplitter::default();
+        assert!(lines.push(b"{\"a\"").is_empty());
+        assert_eq!(
+            lines.push(b": 1}\n\n{\"b\""),
+            vec![(1, b"{\"a\": 1}".to_vec()), (2, Vec::new())]
+        );
+        assert_eq!(lines.push(b": 2}\n"), vec![(3, b"{\"b\": 2}".to_vec())]);
+        assert_eq!(lines.finish(), None);
+
+        let mut lines = LineSplitter::default();
+        lines.push(b"x\ny");
+        assert_eq!(lines.finish(), Some((2, b"y".to_vec())));
+    }
+
+    #[test]
+    fn test_import_record() {
+        let line = r#"{"external_id": "a", "vector": [0.5, 1.0], "metadata": {"k": 1}}"#;
+        let record: ImportRecord = serde_json::from_str(line).unwrap();
+        let doc = record.into_document().unwrap();
+        assert_eq!(doc.external_id.as_deref(), Some("a"));
+        assert_eq!(doc.vector, vec![0.5, 1.0]);
+        assert!(doc.metadata.is_some());
+
+        // Packed as in export files
+        let line = r#"{"vector": {"codec": "f32", "data": "AAAAPwAAgD8="}}"#;
+        let record: ImportRecord = serde_json::from_str(line).unwrap();
+        assert_eq!(record.into_document().unwrap().vector, vec![0.5, 1.0]);
+    }
+}
@@ -0,0 +1,484 @@
+//! Usage metering for billing.
+//!
+//! The service meters, per collection and calendar month (UTC): searches
+//! against the collection index, ingested vectors and bytes, and stored bytes.
+//! Storage is sampled with the growth samples (see
+//! `CollectionService::record_growth_samples`) and billed as the average and
+//! peak of the month's samples. The last `USAGE_RETENTION_MONTHS` months are
+//! kept in memory; `UsageReport::to_csv` renders one month for the finance
+//! pipeline.
+//!
+//! The meter also keeps the tenant's activity hour by hour (searches,
+//! ingestion, and the stored vectors and bytes at each growth sample) for
+//! `USAGE_HISTORY_RETENTION_HOURS`. A `UsageHistory` rolls the hours up into
+//! a time series by hour or day, e.g. to bill by usage within a month.
+
+use akidb_core::{CollectionId, CoreError, CoreResult, TenantId};
+use chrono::{DateTime, Datelike, Duration, TimeZone, Utc};
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap};
+use std::fmt;
+use std::str::FromStr;
+use std::sync::Mutex;
+
+/// Months of usage kept (the current month included).
+pub const USAGE_RETENTION_MONTHS: usize = 24;
+
+/// Hours of tenant activity kept for usage histories (90 days).
+pub const USAGE_HISTORY_RETENTION_HOURS: usize = 24 * 90;
+
+/// Maximum points in a usage history.
+pub const MAX_USAGE_HISTORY_POINTS: usize = USAGE_HISTORY_RETENTION_HOURS;
+
+/// Header of the CSV usage report.
+pub const USAGE_CSV_HEADER: &str = "month,tenant_id,collection_id,collection_name,queries,\
+                                    vectors_ingested,bytes_ingested,avg_storage_bytes,\
+                                    peak_storage_bytes";
+
+/// A calendar month (UTC), written `YYYY-MM`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash)]
+pub struct UsageMonth {
+    year: i32,
+    month: u32,
+}
+
+impl UsageMonth {
+    pub fn new(year: i32, month: u32) -> CoreResult<Self> {
+        if !(1..=12).contains(&month) {
+            return Err(CoreError::ValidationError(format!(
+                "month must be between 1 and 12 (got {})",
+                month
+            )));
+        }
+        Ok(Self { year, month })
+    }
+
+    /// The month containing `timestamp`.
+    pub fn of(timestamp: DateTime<Utc>) -> Self {
+        Self {
+            year: timestamp.year(),
+            month: timestamp.month(),
+        }
+    }
+
+    pub fn current() -> Self {
+        Self::of(Utc::now())
+    }
+}
+
+impl fmt::Display for UsageMonth {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{:04}-{:02}", self.year, self.month)
+    }
+}
+
+impl FromStr for UsageMonth {
+    type Err = CoreError;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let invalid = || CoreError::ValidationError(format!("invalid month '{}': use YYYY-MM", s));
+        let (year, month) = s.split_once('-').ok_or_else(invalid)?;
+        if year.len() != 4 || month.len() != 2 {
+            return Err(invalid());
+        }
+        let year = year.parse().map_err(|_| invalid())?;
+        let month = month.parse().map_err(|_| invalid())?;
+        Self::new(year, month)
+    }
+}
+
+/// Usage of one collection in one month.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CollectionUsage {
+    /// Searches against the collection index.
+    pub queries: u64,
+
+    /// Vectors written (inserts and replacements).
+    pub vectors_ingested: u64,
+
+    /// Bytes written: vector components plus serialized metadata.
+    pub bytes_ingested: u64,
+
+    /// Storage samples taken during the month.
+    pub storage_samples: u64,
+
+    /// Sum of the sampled storage bytes.
+    pub storage_byte_sum: u64,
+
+    /// Largest sampled storage bytes.
+    pub peak_storage_bytes: u64,
+}
+
+impl CollectionUsage {
+    /// Average sampled storage bytes (0 without samples).
+    pub fn avg_storage_bytes(&self) -> u64 {
+        self.storage_byte_sum
+            .checked_div(self.storage_samples)
+            .unwrap_or(0)
+    }
+}
+
+/// Length of the intervals of a usage history.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum UsageGranularity {
+    #[default]
+    Hour,
+    Day,
+}
+
+impl UsageGranularity {
+    pub fn duration(self) -> Duration {
+        match self {
+            Self::Hour => Duration::hours(1),
+            Self::Day => Duration::days(1),
+        }
+    }
+
+    /// Start of the interval containing `at` (UTC).
+    pub fn truncate(self, at: DateTime<Utc>) -> DateTime<Utc> {
+        let step = self.duration().num_seconds();
+        Utc.timestamp_opt(at.timestamp().div_euclid(step) * step, 0)
+            .unwrap()
+    }
+}
+
+/// Tenant activity in one hour.
+#[derive(Debug, Clone, Copy, Default)]
+struct HourlyActivity {
+    queries: u64,
+    vectors_ingested: u64,
+    bytes_ingested: u64,
+
+    /// Vectors and bytes stored at the hour's last storage sample.
+    stored: Option<(u64, u64)>,
+}
+
+/// Tenant activity in one interval of a usage history.
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+pub struct UsagePoint {
+    /// Start of the interval.
+    pub start: DateTime<Utc>,
+
+    pub queries: u64,
+
+    /// Average searches per second over the interval.
+    pub queries_per_second: f64,
+
+    pub vectors_ingested: u64,
+    pub bytes_ingested: u64,
+
+    /// Vectors stored at the interval's last storage sample (None without
+    /// samples).
+    pub vector_count: Option<u64>,
+
+    /// Estimated bytes stored at the interval's last storage sample.
+    pub storage_bytes: Option<u64>,
+}
+
+impl UsagePoint {
+    fn empty(start: DateTime<Utc>) -> Self {
+        Self {
+            start,
+            queries: 0,
+            queries_per_second: 0.0,
+            vectors_ingested: 0,
+            bytes_ingested: 0,
+            vector_count: None,
+            storage_bytes: None,
+        }
+    }
+}
+
+/// A tenant's usage over time, one point per interval from `from` (rounded
+/// down to the interval) until `to`, intervals without activity included.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct UsageHistory {
+    pub tenant_id: TenantId,
+    pub granularity: UsageGranularity,
+    pub from: DateTime<Utc>,
+    pub to: DateTime<Utc>,
+    pub points: Vec<UsagePoint>,
+}
+
+/// Usage counters by month and collection, and tenant activity by hour.
+#[derive(Debug, Default)]
+pub struct UsageMeter {
+    months: Mutex<BTreeMap<UsageMonth, HashMap<CollectionId, CollectionUsage>>>,
+    hours: Mutex<BTreeMap<DateTime<Utc>, HourlyActivity>>,
+}
+
+impl UsageMeter {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    fn update(
+        &self,
+        collection_id: CollectionId,
+        at: DateTime<Utc>,
+        f: impl FnOnce(&mut CollectionUsage),
+    ) {
+        let mut months = self.months.lock().unwrap();
+        f(months
+            .entry(UsageMonth::of(at))
+            .or_default()
+            .entry(collection_id)
+            .or_default());
+        while months.len() > USAGE_RETENTION_MONTHS {
+            months.pop_first();
+        }
+    }
+
+    fn update_hour(&self, at: DateTime<Utc>, f: impl FnOnce(&mut HourlyActivity)) {
+        let mut hours = self.hours.lock().unwrap();
+        f(hours
+            .entry(UsageGranularity::Hour.truncate(at))
+            .or_default());
+        while hours.len() > USAGE_HISTORY_RETENTION_HOURS {
+            hours.pop_first();
+        }
+    }
+
+    pub fn record_query(&self, collection_id: CollectionId, at: DateTime<Utc>) {
+        self.update(collection_id, at, |usage| usage.queries += 1);
+        self.update_hour(at, |activity| activity.queries += 1);
+    }
+
+    pub fn record_ingest(&self, collection_id: CollectionId, bytes: u64, at: DateTime<Utc>) {
+        self.update(collection_id, at, |usage| {
+            usage.vectors_ingested += 1;
+            usage.bytes_ingested += bytes;
+        });
+        self.update_hour(at, |activity| {
+            activity.vectors_ingested += 1;
+            activity.bytes_ingested += bytes;
+        });
+    }
+
+    pub fn record_storage(&self, collection_id: CollectionId, bytes: u64, at: DateTime<Utc>) {
+        self.update(collection_id, at, |usage| {
+            usage.storage_samples += 1;
+            usage.storage_byte_sum = usage.storage_byte_sum.saturating_add(bytes);
+            usage.peak_storage_bytes = usage.peak_storage_bytes.max(bytes);
+        });
+    }
+
+    /// Record the vectors and bytes stored by the whole tenant.
+    pub fn record_tenant_storage(&self, vector_count: u64, bytes: u64, at: DateTime<Utc>) {
+        self.update_hour(at, |activity| activity.stored = Some((vector_count, bytes)));
+    }
+
+    /// Usage of every collection metered in `month`.
+    pub fn usage(&self, month: UsageMonth) -> HashMap<CollectionId, CollectionUsage> {
+        let months = self.months.lock().unwrap();
+        months.get(&month).cloned().unwrap_or_default()
+    }
+
+    /// Tenant activity from `from` (rounded down to `granularity`) until
+    /// `to`, one point per interval.
+    pub fn history(
+        &self,
+        from: DateTime<Utc>,
+        to: DateTime<Utc>,
+        granularity: UsageGranularity,
+    ) -> CoreResult<Vec<UsagePoint>> {
+        if from >= to {
+            return Err(CoreError::ValidationError(
+                "from must be before to".to_string(),
+            ));
+        }
+        let first = granularity.truncate(from);
+        let step = granularity.duration().num_seconds();
+        let intervals = ((to - first).num_seconds() + step - 1) / step;
+        if intervals > MAX_USAGE_HISTORY_POINTS as i64 {
+            return Err(CoreError::ValidationError(format!(
+                "range spans {} intervals; at most {} are returned",
+                intervals, MAX_USAGE_HISTORY_POINTS
+            )));
+        }
+
+        let mut points: Vec<UsagePoint> = (0..intervals)
+            .map(|i| UsagePoint::empty(first + Duration::seconds(i * step)))
+            .collect();
+        let hours = self.hours.lock().unwrap();
+        for (hour, activity) in hours.range(first..to) {
+            let point = &mut points[((*hour - first).num_seconds() / step) as usize];
+            point.queries += activity.queries;
+            point.vectors_ingested += activity.vectors_ingested;
+            point.bytes_ingested += activity.bytes_ingested;
+            if let Some((vector_count, bytes)) = activity.stored {
+                point.vector_count = Some(vector_count);
+                point.storage_bytes = Some(bytes);
+            }
+        }
+        for point in &mut points {
+            point.queries_per_second = point.queries as f64 / step as f64;
+        }
+        Ok(points)
+    }
+}
+
+/// Usage of one collection in a report.
+#[derive(Debug, Clone, Serialize)]
+pub struct UsageReportRow {
+    pub collection_id: CollectionId,
+
+    /// Collection name (empty for collections deleted since).
+    pub collection_name: String,
+
+    #[serde(flatten)]
+    pub usage: CollectionUsage,
+}
+
+/// Usage of a tenant's collections in one month.
+#[derive(Debug, Clone, Serialize)]
+pub struct UsageReport {
+    #[serde(serialize_with = "serialize_month")]
+    pub month: UsageMonth,
+
+    /// Tenant served by this node (None if not configured).
+    pub tenant_id: Option<TenantId>,
+
+    /// One row per collection, by collection name.
+    pub rows: Vec<UsageReportRow>,
+}
+
+fn serialize_month<S: serde::Serializer>(month: &UsageMonth, s: S) -> Result<S::Ok, S::Error> {
+    s.collect_str(month)
+}
+
+impl UsageReport {
+    /// Render the report as CSV (RFC 4180), one line per collection after
+    /// `USAGE_CSV_HEADER`.
+    pub fn to_csv(&self) -> String {
+        let tenant_id = self
+            .tenant_id
+            .map(|tenant_id| tenant_id.to_string())
+            .unwrap_or_default();
+        let mut csv = format!("{}\r\n", USAGE_CSV_HEADER);
+        for row in &self.rows {
+            csv.push_str(&format!(
+                "{},{},{},{},{},{},{},{},{}\r\n",
+                self.month,
+                tenant_id,
+                row.collection_id,
+                csv_field(&row.collection_name),
+                row.usage.queries,
+                row.usage.vectors_ingested,
+                row.usage.bytes_ingested,
+                row.usage.avg_storage_bytes(),
+                row.usage.peak_storage_bytes
+            ));
+        }
+        csv
+    }
+}
+
+/// Quote a CSV field containing separators, quotes or line breaks.
+fn csv_field(value: &str) -> String {
+    if value.contains([',', '"', '\r', '\n']) {
+        format!("\"{}\"", value.replace('"', "\"\""))
+    } else {
+        value.to_string()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::TimeZone;
+
+    #[test]
+    fn test_usage_report_csv() {
+        let meter = UsageMeter::new();
+        let collection_id = CollectionId::new();
+        let october = Utc.with_ymd_and_hms(2026, 10, 5, 12, 0, 0).unwrap();
+        let november = Utc.with_ymd_and_hms(2026, 11, 1, 0, 0, 0).unwrap();
+        meter.record_query(collection_id, october);
+        meter.record_query(collection_id, october);
+        meter.record_ingest(collection_id, 512, october);
+        meter.record_storage(collection_id, 1000, october);
+        meter.record_storage(collection_id, 3000, october);
+        meter.record_query(collection_id, november);
+
+        let month: UsageMonth = "2026-10".parse().unwrap();
+        let usage = meter.usage(month)[&collection_id];
+        assert_eq!(usage.queries, 2);
+        assert_eq!(usage.bytes_ingested, 512);
+        assert_eq!(usage.avg_storage_bytes(), 2000);
+        assert_eq!(usage.peak_storage_bytes, 3000);
+
+        let report = UsageReport {
+            month,
+            tenant_id: None,
+            rows: vec![UsageReportRow {
+                collection_id,
+                collection_name: "docs, \"v2\"".to_string(),
+                usage,
+            }],
+        };
+        let csv = report.to_csv();
+        let mut lines = csv.lines();
+        assert_eq!(lines.next(), Some(USAGE_CSV_HEADER));
+        assert_eq!(
+            lines.next().unwrap(),
+            format!(
+                "2026-10,,{},\"docs, \"\"v2\"\"\",2,1,512,2000,3000",
+                collection_id
+            )
+        );
+
+        assert!("2026-13".parse::<UsageMonth>().is_err());
+        assert!("202610".parse::<UsageMonth>().is_err());
+    }
+
+    #[test]
+    fn test_usage_history() {
+        let meter = UsageMeter::new();
+        let collection_id = CollectionId::new();
+        let at = |day, hour, minute| {
+            Utc.with_ymd_and_hms(2026, 10, day, hour, minute, 0)
+                .unwrap()
+        };
+        meter.record_query(collection_id, at(5, 10, 15));
+        meter.record_query(collection_id, at(5, 10, 45));
+        meter.record_ingest(collection_id, 512, at(5, 10, 30));
+        meter.record_tenant_storage(10, 1000, at(5, 10, 0));
+        meter.record_tenant_storage(20, 2000, at(5, 12, 0));
+        meter.record_query(collection_id, at(6, 1, 0));
+
+        let hourly = meter
+            .history(at(5, 10, 30), at(5, 13, 0), UsageGranularity::Hour)
+            .unwrap();
+        assert_eq!(hourly.len(), 3);
+        assert_eq!(hourly[0].start, at(5, 10, 0));
+        assert_eq!(hourly[0].queries, 2);
+        assert_eq!(hourly[0].queries_per_second, 2.0 / 3600.0);
+        assert_eq!(hourly[0].bytes_ingested, 512);
+        assert_eq!(hourly[0].vector_count, Some(10));
+        assert_eq!(hourly[1].queries, 0);
+        assert_eq!(hourly[1].storage_bytes, None);
+        assert_eq!(hourly[2].storage_bytes, Some(2000));
+
+        let daily = meter
+            .history(at(5, 0, 0), at(7, 0, 0), UsageGranularity::Day)
+            .unwrap();
+        assert_eq!(daily.len(), 2);
+        assert_eq!(daily[0].queries, 2);
+        assert_eq!(daily[0].vector_count, Some(20));
+        assert_eq!(daily[1].queries, 1);
+        assert_eq!(daily[1].vector_count, None);
+
+        assert!(meter
+            .history(at(5, 0, 0), at(5, 0, 0), UsageGranularity::Hour)
+            .is_err());
+        assert!(meter
+            .history(
+                at(1, 0, 0),
+                at(1, 0, 0) + Duration::days(91),
+                UsageGranularity::Hour
+            )
+            .is_err());
+    }
+}
@@ -0,0 +1,250 @@
+//! Vector encodings for export and import files.
+//!
+//! Written as JSON arrays, vectors dominate the size of export files: a
+//! float takes 10 or more characters. An export job can instead pack each
+//! vector into base64 with a `VectorCodec`:
+//! - `f32`: the exact components (lossless), about half the size,
+//! - `f16`: half precision (about 3 significant digits), about a quarter,
+//! - `int8`: 256 evenly spaced levels between the vector's smallest and
+//!   largest component, about an eighth.
+//!
+//! Packed vectors describe their codec, e.g.
+//! `{"codec": "f16", "data": "ADwAvA=="}`, so import files may mix plain and
+//! packed vectors (see `ImportRecord`).
+
+use akidb_core::{CoreError, CoreResult};
+use base64::engine::general_purpose::STANDARD as BASE64;
+use base64::Engine;
+use serde::{Deserialize, Serialize};
+
+/// Encoding of the vectors of an export file.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum VectorCodec {
+    /// JSON array of floats.
+    #[default]
+    Json,
+
+    /// Little-endian 32-bit floats (lossless).
+    F32,
+
+    /// Little-endian IEEE 754 half-precision floats.
+    F16,
+
+    /// One byte per component, scaled between the vector's minimum
+    /// (`offset`) and maximum.
+    Int8,
+}
+
+impl VectorCodec {
+    /// Returns true if decoding restores the exact components.
+    pub fn is_lossless(self) -> bool {
+        matches!(self, Self::Json | Self::F32)
+    }
+
+    pub fn encode(self, vector: &[f32]) -> EncodedVector {
+        let packed = |data: Vec<u8>, offset, scale| {
+            EncodedVector::Packed(PackedVector {
+                codec: self,
+                data: BASE64.encode(data),
+                offset,
+                scale,
+            })
+        };
+        match self {
+            Self::Json => EncodedVector::Plain(vector.to_vec()),
+            Self::F32 => packed(
+                vector.iter().flat_map(|x| x.to_le_bytes()).collect(),
+                None,
+                None,
+            ),
+            Self::F16 => packed(
+                vector
+                    .iter()
+                    .flat_map(|x| f32_to_f16(*x).to_le_bytes())
+                    .collect(),
+                None,
+                None,
+            ),
+            Self::Int8 => {
+                let min = vector.iter().copied().fold(f32::INFINITY, f32::min);
+                let max = vector.iter().copied().fold(f32::NEG_INFINITY, f32::max);
+                let (offset, scale) = if vector.is_empty() {
+                    (0.0, 0.0)
+                } else {
+                    (min, (max - min) / 255.0)
+                };
+                let data = vector
+                    .iter()
+                    .map(|x| {
+                        if scale > 0.0 {
+                            ((x - offset) / scale).round().clamp(0.0, 255.0) as u8
+                        } else {
+                            0
+                        }
+                    })
+                    .collect();
+                packed(data, Some(offset), Some(scale))
+            }
+        }
+    }
+}
+
+/// A vector as written in an export or import file.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(untagged)]
+pub enum EncodedVector {
+    Plain(Vec<f32>),
+    Packed(PackedVector),
+}
+
+/// A vector packed by a `VectorCodec` other than `json`.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct PackedVector {
+    pub codec: VectorCodec,
+
+    /// Base64 of the encoded components.
+    pub data: String,
+
+    /// `int8`: value of level 0.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub offset: Option<f32>,
+
+    /// `int8`: step between levels.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub scale: Option<f32>,
+}
+
+impl EncodedVector {
+    pub fn decode(self) -> CoreResult<Vec<f32>> {
+        let packed = match self {
+            Self::Plain(vector) => return Ok(vector),
+            Self::Packed(packed) => packed,
+        };
+        let data = BASE64.decode(&packed.data).map_err(|e| {
+            CoreError::ValidationError(format!("invalid {:?} vector data: {}", packed.codec, e))
+        })?;
+        let misaligned = |width: usize| {
+            CoreError::ValidationError(format!(
+                "{:?} vector data must be a multiple of {} bytes (got {})",
+                packed.codec,
+                width,
+                data.len()
+            ))
+        };
+        match packed.codec {
+            VectorCodec::Json => Err(CoreError::ValidationError(
+                "json vectors are written as arrays".to_string(),
+            )),
+            VectorCodec::F32 if data.len() % 4 == 0 => Ok(data
+                .chunks_exact(4)
+                .map(|b| f32::from_le_bytes([b[0], b[1], b[2], b[3]]))
+                .collect()),
+            VectorCodec::F16 if data.len() % 2 == 0 => Ok(data
+                .chunks_exact(2)
+                .map(|b| f16_to_f32(u16::from_le_bytes([b[0], b[1]])))
+                .collect()),
+            VectorCodec::F32 => Err(misaligned(4)),
+            VectorCodec::F16 => Err(misaligned(2)),
+            VectorCodec::Int8 => {
+                let (Some(offset), Some(scale)) = (packed.offset, packed.scale) else {
+                    return Err(CoreError::ValidationError(
+                        "int8 vectors need offset and scale".to_string(),
+                    ));
+                };
+                Ok(data.iter().map(|q| offset + *q as f32 * scale).collect())
+            }
+        }
+    }
+}
+
+/// Nearest half-precision float (ties to even); out of range values become
+/// infinities.
+fn f32_to_f16(x: f32) -> u16 {
+    let bits = x.to_bits();
+    let sign = ((bits >> 16) & 0x8000) as u16;
+    let exponent = ((bits >> 23) & 0xff) as i32;
+    let mantissa = bits & 0x7f_ffff;
+    if exponent == 0xff {
+        let nan = if mantissa != 0 { 0x200 } else { 0 };
+        return sign | 0x7c00 | nan;
+    }
+
+    // Rounds away `shift` low bits; a carry correctly bumps the exponent
+    let round = |value: u32, shift: u32| {
+        let kept = value >> shift;
+        let rest = value & ((1 << shift) - 1);
+        let halfway = 1 << (shift - 1);
+        if rest > halfway || (rest == halfway && kept & 1 == 1) {
+            kept + 1
+        } else {
+            kept
+        }
+    };
+    let half_exponent = exponent - 112;
+    if half_exponent >= 0x1f {
+        sign | 0x7c00
+    } else if half_exponent > 0 {
+        sign | round(((half_exponent as u32) << 23) | mantissa, 13) as u16
+    } else if half_exponent >= -10 {
+        // Subnormal
+        sign | round(mantissa | 0x80_0000, (14 - half_exponent) as u32) as u16
+    } else {
+        sign
+    }
+}
+
+fn f16_to_f32(half: u16) -> f32 {
+    let sign = ((half & 0x8000) as u32) << 16;
+    let exponent = ((half >> 10) & 0x1f) as u32;
+    let mantissa = (half & 0x3ff) as u32;
+    match exponent {
+        0 => {
+            // Zero or subnormal: mantissa * 2^-24
+            let magnitude = mantissa as f32 / 16_777_216.0;
+            if sign != 0 {
+                -magnitude
+            } else {
+                magnitude
+            }
+        }
+        0x1f => f32::from_bits(sign | 0x7f80_0000 | (mantissa << 13)),
+        _ => f32::from_bits(sign | ((exponent + 112) << 23) | (mantissa << 13)),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_codecs_round_trip() {
+        let vector = vec![0.1234567, -0.98, 0.0, 1e-6, 3.5, -65504.0];
+        for codec in [VectorCodec::Json, VectorCodec::F32] {
+            let json = serde_json::to_string(&codec.encode(&vector)).unwrap();
+            let decoded: EncodedVector = serde_json::from_str(&json).unwrap();
+            assert_eq!(decoded.decode().unwrap(), vector);
+        }
+
+        let decoded = VectorCodec::F16.encode(&vector).decode().unwrap();
+        for (x, y) in vector.iter().zip(&decoded) {
+            assert!((x - y).abs() <= x.abs() / 2048.0 + 1e-7, "{} vs {}", x, y);
+        }
+        assert_eq!(f32_to_f16(1.0), 0x3c00);
+        assert_eq!(f32_to_f16(-2.0), 0xc000);
+        assert_eq!(f32_to_f16(1e6), 0x7c00);
+        assert_eq!(f16_to_f32(0x0001), 2f32.powi(-24));
+
+        let unit = [0.5, -0.25, 0.125, 1.0, -1.0];
+        let decoded = VectorCodec::Int8.encode(&unit).decode().unwrap();
+        for (x, y) in unit.iter().zip(&decoded) {
+            assert!((x - y).abs() <= 2.0 / 255.0 / 2.0 + 1e-6, "{} vs {}", x, y);
+        }
+        let constant = VectorCodec::Int8.encode(&[0.5; 4]).decode().unwrap();
+        assert_eq!(constant, vec![0.5; 4]);
+
+        let truncated: EncodedVector =
+            serde_json::from_value(serde_json::json!({"codec": "f32", "data": "AAA="})).unwrap();
+        assert!(truncated.decode().is_err());
+    }
+}
@@ -0,0 +1,83 @@
+//! Permission introspection ("what can this caller do?").
+//!
+//! `CollectionService::whoami` describes the caller of a request: how it was
+//! authenticated, the tenant it acts for, its scopes, what it may do with
+//! each collection, and the tenant's request limits. Services call it at
+//! startup to fail fast when deployed with the wrong credentials, instead of
+//! on their first write.
+//!
+//! Requests are not authenticated with API keys yet: a request without
+//! impersonation headers acts as the tenant the server serves, with every
+//! scope. Impersonated requests only read.
+
+use akidb_core::{CollectionId, CollectionLimits, TenantId};
+use serde::{Deserialize, Serialize};
+
+/// Scope to search and read documents.
+pub const SCOPE_READ: &str = "collection::read";
+
+/// Scope to write documents and manage collections.
+pub const SCOPE_WRITE: &str = "collection::write";
+
+/// How the caller was authenticated.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(tag = "kind", rename_all = "snake_case")]
+pub enum Principal {
+    /// No credentials: the server's own tenant.
+    Anonymous,
+
+    /// The admin key acting as the tenant.
+    Impersonation {
+        /// Who is impersonating, as claimed by the caller.
+        actor: Option<String>,
+    },
+}
+
+/// What the caller may do with a collection.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CollectionPermissions {
+    pub collection_id: CollectionId,
+    pub name: String,
+
+    pub read: bool,
+
+    /// Insert, update and delete documents (false while the collection is
+    /// read-only).
+    pub write: bool,
+
+    /// Delete the collection (false while it is read-only or under a legal
+    /// hold).
+    pub delete: bool,
+}
+
+/// The caller of a request and its permissions.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct Identity {
+    pub principal: Principal,
+
+    /// Tenant the caller acts for (None until the server's tenant is set).
+    pub tenant_id: Option<TenantId>,
+
+    pub scopes: Vec<String>,
+
+    /// Collections, oldest first.
+    pub collections: Vec<CollectionPermissions>,
+
+    /// Request limits of the tenant; there are no request rate limits.
+    pub limits: CollectionLimits,
+}
+
+impl Identity {
+    /// Whether the caller holds `scope`.
+    pub fn has_scope(&self, scope: &str) -> bool {
+        self.scopes.iter().any(|s| s == scope)
+    }
+}
+
+/// Scopes of a principal.
+pub fn scopes(principal: &Principal) -> Vec<String> {
+    match principal {
+        Principal::Anonymous => vec![SCOPE_READ.to_string(), SCOPE_WRITE.to_string()],
+        Principal::Impersonation { .. } => vec![SCOPE_READ.to_string()],
+    }
+}
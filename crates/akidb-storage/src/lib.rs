@@ -96,7 +96,7 @@ pub use dlq::{DLQConfig, DLQEntry, DLQMetrics, DeadLetterQueue};
 pub use object_store::{
     CallHistoryEntry, MockFailure, MockS3Config, MockS3ObjectStore, ObjectStore,
 };
-pub use storage_backend::{CacheStats, RetryConfig, StorageBackend, StorageMetrics};
+pub use storage_backend::{BatchWrite, CacheStats, RetryConfig, StorageBackend, StorageMetrics};
 pub use tiering::{CompactionConfig, CompressionType, StorageConfig, TieringPolicy};
 pub use wal::{FileWAL, FileWALConfig, LogEntry, LogSequenceNumber, WriteAheadLog};
 
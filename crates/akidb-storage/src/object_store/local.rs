@@ -399,4 +399,15 @@ mod tests {
         let result = store.put("", Bytes::from("data")).await;
         assert!(matches!(result, Err(CoreError::ValidationError(_))));
     }
+
+    #[tokio::test]
+    async fn test_local_store_presign_unsupported() {
+        let temp_dir = TempDir::new().unwrap();
+        let store = LocalObjectStore::new(temp_dir.path()).await.unwrap();
+
+        let result = store
+            .presign_put("test.txt", std::time::Duration::from_secs(60))
+            .await;
+        assert!(matches!(result, Err(CoreError::StorageError(_))));
+    }
 }
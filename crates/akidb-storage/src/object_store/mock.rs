@@ -495,6 +495,24 @@ impl ObjectStore for MockS3ObjectStore {
 
         Ok(())
     }
+
+    async fn presign_put(&self, key: &str, expires_in: Duration) -> CoreResult<String> {
+        self.record_call("presign_put", key, true);
+        Ok(format!(
+            "mock://bucket/{}?method=PUT&expires_in={}",
+            key,
+            expires_in.as_secs()
+        ))
+    }
+
+    async fn presign_get(&self, key: &str, expires_in: Duration) -> CoreResult<String> {
+        self.record_call("presign_get", key, true);
+        Ok(format!(
+            "mock://bucket/{}?method=GET&expires_in={}",
+            key,
+            expires_in.as_secs()
+        ))
+    }
 }
 
 #[cfg(test)]
@@ -13,11 +13,15 @@ pub use local::LocalObjectStore;
 pub use mock::{CallHistoryEntry, MockFailure, MockS3Config, MockS3ObjectStore};
 pub use s3::{S3Config, S3ObjectStore};
 
-use akidb_core::CoreResult;
+use akidb_core::{CoreError, CoreResult};
 use async_trait::async_trait;
 use bytes::Bytes;
 use chrono::{DateTime, Utc};
 use serde::{Deserialize, Serialize};
+use std::time::Duration;
+
+/// Longest validity accepted for presigned URLs (the S3 SigV4 limit).
+pub const MAX_PRESIGN_EXPIRY: Duration = Duration::from_secs(7 * 24 * 3600);
 
 /// Object metadata returned by list/head operations
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -151,6 +155,38 @@ pub trait ObjectStore: Send + Sync {
     /// - `CoreError::StorageError` if the operation fails
     /// - `CoreError::ValidationError` if total size exceeds backend limits
     async fn put_multipart(&self, key: &str, parts: Vec<Bytes>) -> CoreResult<()>;
+
+    /// Presigned URL for uploading an object with HTTP PUT
+    ///
+    /// Lets clients (including browsers) send data straight to the backend
+    /// without passing it through the server. The URL is valid for
+    /// `expires_in` (at most `MAX_PRESIGN_EXPIRY`).
+    ///
+    /// # Errors
+    ///
+    /// - `CoreError::StorageError` if the backend cannot presign URLs or
+    ///   signing fails
+    async fn presign_put(&self, key: &str, expires_in: Duration) -> CoreResult<String> {
+        let _ = (key, expires_in);
+        Err(CoreError::StorageError(
+            "Presigned URLs are not supported by this backend".to_string(),
+        ))
+    }
+
+    /// Presigned URL for downloading an object with HTTP GET
+    ///
+    /// Same semantics as `presign_put`.
+    ///
+    /// # Errors
+    ///
+    /// - `CoreError::StorageError` if the backend cannot presign URLs or
+    ///   signing fails
+    async fn presign_get(&self, key: &str, expires_in: Duration) -> CoreResult<String> {
+        let _ = (key, expires_in);
+        Err(CoreError::StorageError(
+            "Presigned URLs are not supported by this backend".to_string(),
+        ))
+    }
 }
 
 #[cfg(test)]
@@ -7,10 +7,13 @@ use super::{ObjectMetadata, ObjectStore};
 use akidb_core::{CoreError, CoreResult};
 use async_trait::async_trait;
 use aws_config::BehaviorVersion;
-use aws_sdk_s3::{config::Credentials, primitives::ByteStream, Client, Config};
+use aws_sdk_s3::{
+    config::Credentials, presigning::PresigningConfig, primitives::ByteStream, Client, Config,
+};
 use bytes::Bytes;
 use chrono::Utc;
 use serde::{Deserialize, Serialize};
+use std::time::Duration;
 
 /// S3 configuration
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -170,6 +173,17 @@ impl S3ObjectStore {
         }
     }
 
+    fn presigning_config(expires_in: Duration) -> CoreResult<PresigningConfig> {
+        if expires_in.is_zero() || expires_in > super::MAX_PRESIGN_EXPIRY {
+            return Err(CoreError::ValidationError(format!(
+                "Presigned URL expiry must be between 1s and {}s",
+                super::MAX_PRESIGN_EXPIRY.as_secs()
+            )));
+        }
+        PresigningConfig::expires_in(expires_in)
+            .map_err(|e| CoreError::StorageError(format!("S3 presign failed: {}", e)))
+    }
+
     /// Strip prefix from key if configured
     fn strip_prefix(&self, key: &str) -> String {
         if let Some(prefix) = &self.prefix {
@@ -369,6 +383,38 @@ impl ObjectStore for S3ObjectStore {
             ))
         }
     }
+
+    async fn presign_put(&self, key: &str, expires_in: Duration) -> CoreResult<String> {
+        if key.is_empty() {
+            return Err(CoreError::ValidationError(
+                "Key cannot be empty".to_string(),
+            ));
+        }
+
+        let request = self
+            .client
+            .put_object()
+            .bucket(&self.bucket)
+            .key(self.full_key(key))
+            .presigned(Self::presigning_config(expires_in)?)
+            .await
+            .map_err(|e| CoreError::StorageError(format!("S3 presign failed: {}", e)))?;
+
+        Ok(request.uri().to_string())
+    }
+
+    async fn presign_get(&self, key: &str, expires_in: Duration) -> CoreResult<String> {
+        let request = self
+            .client
+            .get_object()
+            .bucket(&self.bucket)
+            .key(self.full_key(key))
+            .presigned(Self::presigning_config(expires_in)?)
+            .await
+            .map_err(|e| CoreError::StorageError(format!("S3 presign failed: {}", e)))?;
+
+        Ok(request.uri().to_string())
+    }
 }
 
 #[cfg(test)]
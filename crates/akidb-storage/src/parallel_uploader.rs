@@ -221,6 +221,8 @@ mod tests {
             vector,
             metadata: None,
             inserted_at: Utc::now(),
+            sparse_vector: None,
+            named_vectors: None,
         }
     }
 
@@ -328,6 +328,8 @@ impl ParquetEncoder {
                     vector,
                     metadata,
                     inserted_at,
+                    sparse_vector: None,
+                    named_vectors: None,
                 });
             }
         }
@@ -353,6 +355,8 @@ mod tests {
                 vector: vec![1.0, 2.0, 3.0],
                 metadata: None,
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             },
             VectorDocument {
                 doc_id: DocumentId::new(),
@@ -360,6 +364,8 @@ mod tests {
                 vector: vec![4.0, 5.0, 6.0],
                 metadata: Some(serde_json::json!({"tag": "test"})),
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             },
         ];
 
@@ -385,6 +391,8 @@ mod tests {
                 vector: vec![i as f32; 512], // 512-dim
                 metadata: None,
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             })
             .collect();
 
@@ -419,6 +427,8 @@ mod tests {
                 vector: vec![1.0, 2.0, 3.0],
                 metadata: None,
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             },
             VectorDocument {
                 doc_id: DocumentId::new(),
@@ -426,6 +436,8 @@ mod tests {
                 vector: vec![1.0, 2.0], // Wrong dimension!
                 metadata: None,
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             },
         ];
 
@@ -454,6 +466,8 @@ mod tests {
                 vector: vec![1.0, 2.0, 3.0],
                 metadata: Some(serde_json::json!({"tag": "test"})),
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             },
             VectorDocument {
                 doc_id: DocumentId::new(),
@@ -461,6 +475,8 @@ mod tests {
                 vector: vec![4.0, 5.0, 6.0],
                 metadata: None,
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             },
         ];
 
@@ -496,6 +512,8 @@ mod tests {
                 vector: vec![i as f32; 128],
                 metadata: Some(serde_json::json!({"index": i})),
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             })
             .collect();
 
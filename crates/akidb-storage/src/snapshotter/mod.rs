@@ -120,6 +120,32 @@ pub struct SnapshotMetadata {
     pub compression: CompressionCodec,
     /// Snapshot format (JSON or Parquet)
     pub format: SnapshotFormat,
+    /// MD5 (hex) of the stored snapshot data, verified on restore
+    /// (None for snapshots written before checksums were recorded)
+    #[serde(default)]
+    pub checksum: Option<String>,
+}
+
+/// Checksum recorded for snapshot data (MD5, hex-encoded).
+pub fn snapshot_checksum(data: &[u8]) -> String {
+    format!("{:x}", md5::compute(data))
+}
+
+/// Fails if `data` does not match the checksum recorded in `metadata`.
+pub(crate) fn verify_checksum(metadata: &SnapshotMetadata, data: &[u8]) -> CoreResult<()> {
+    match &metadata.checksum {
+        Some(expected) => {
+            let actual = snapshot_checksum(data);
+            if &actual != expected {
+                return Err(CoreError::StorageError(format!(
+                    "Snapshot {} checksum mismatch: expected {}, got {}",
+                    metadata.snapshot_id, expected, actual
+                )));
+            }
+            Ok(())
+        }
+        None => Ok(()),
+    }
 }
 
 /// Compression codec for snapshot storage
@@ -202,7 +228,8 @@ pub trait Snapshotter: Send + Sync {
 
     /// Verify snapshot integrity
     ///
-    /// Returns true if both snapshot data and metadata exist.
+    /// Returns true if both snapshot data and metadata exist and the data
+    /// matches the recorded checksum.
     ///
     /// # Errors
     ///
@@ -305,6 +332,7 @@ impl Snapshotter for JsonSnapshotter {
         // Compress
         let compressed_data = self.compress(json_data)?;
         let size_bytes = compressed_data.len() as u64;
+        let checksum = snapshot_checksum(&compressed_data);
 
         // Upload to object store
         let snapshot_key = self.snapshot_key(snapshot_id);
@@ -322,6 +350,7 @@ impl Snapshotter for JsonSnapshotter {
             size_bytes,
             compression: self.compression,
             format: SnapshotFormat::Json,
+            checksum: Some(checksum),
         };
 
         let metadata_json = serde_json::to_vec(&metadata)?;
@@ -338,6 +367,10 @@ impl Snapshotter for JsonSnapshotter {
         let snapshot_key = self.snapshot_key(snapshot_id);
         let compressed_data = self.object_store.get(&snapshot_key).await?;
 
+        // Detect corrupt or truncated downloads
+        let metadata = self.get_metadata(snapshot_id).await?;
+        verify_checksum(&metadata, &compressed_data)?;
+
         // Decompress
         let json_data = self.decompress(compressed_data.to_vec())?;
 
@@ -396,8 +429,13 @@ impl Snapshotter for JsonSnapshotter {
 
         let snapshot_exists = self.object_store.exists(&snapshot_key).await?;
         let metadata_exists = self.object_store.exists(&metadata_key).await?;
+        if !(snapshot_exists && metadata_exists) {
+            return Ok(false);
+        }
 
-        Ok(snapshot_exists && metadata_exists)
+        let metadata = self.get_metadata(snapshot_id).await?;
+        let data = self.object_store.get(&snapshot_key).await?;
+        Ok(verify_checksum(&metadata, &data).is_ok())
     }
 }
 
@@ -416,6 +454,8 @@ mod tests {
                 vector: vec![i as f32; dimension],
                 metadata: Some(serde_json::json!({"index": i})),
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             })
             .collect()
     }
@@ -484,6 +524,31 @@ mod tests {
         assert!(metadata.size_bytes > 0);
     }
 
+    #[tokio::test]
+    async fn test_restore_detects_corruption() {
+        let temp_dir = TempDir::new().unwrap();
+        let store = Arc::new(LocalObjectStore::new(temp_dir.path()).await.unwrap());
+        let snapshotter = JsonSnapshotter::new(store.clone(), CompressionCodec::None);
+
+        let vectors = create_test_vectors(3, 16);
+        let snapshot_id = snapshotter
+            .create_snapshot(CollectionId::new(), vectors)
+            .await
+            .unwrap();
+        let metadata = snapshotter.get_metadata(snapshot_id).await.unwrap();
+        assert!(metadata.checksum.is_some());
+
+        // Flip a byte in the stored data
+        let key = snapshotter.snapshot_key(snapshot_id);
+        let mut data = store.get(&key).await.unwrap().to_vec();
+        data[1] ^= 0x01;
+        store.put(&key, Bytes::from(data)).await.unwrap();
+
+        let result = snapshotter.restore_snapshot(snapshot_id).await;
+        assert!(matches!(result, Err(CoreError::StorageError(_))));
+        assert!(!snapshotter.verify_snapshot(snapshot_id).await.unwrap());
+    }
+
     #[tokio::test]
     async fn test_list_snapshots() {
         let temp_dir = TempDir::new().unwrap();
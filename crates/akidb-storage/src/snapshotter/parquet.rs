@@ -2,7 +2,10 @@
 //!
 //! Provides 2-3x better compression than JSON and 90% reduction in S3 API calls.
 
-use super::{CompressionCodec, SnapshotFormat, SnapshotId, SnapshotMetadata, Snapshotter};
+use super::{
+    snapshot_checksum, verify_checksum, CompressionCodec, SnapshotFormat, SnapshotId,
+    SnapshotMetadata, Snapshotter,
+};
 use crate::object_store::ObjectStore;
 use crate::parquet_encoder::{ParquetConfig, ParquetEncoder};
 use akidb_core::{CollectionId, CoreError, CoreResult, VectorDocument};
@@ -137,6 +140,7 @@ impl Snapshotter for ParquetSnapshotter {
             size_bytes: parquet_bytes.len() as u64,
             compression: self.config.to_compression_codec(),
             format: SnapshotFormat::Parquet,
+            checksum: Some(snapshot_checksum(&parquet_bytes)),
         };
 
         let metadata_json = serde_json::to_vec(&metadata)?;
@@ -155,6 +159,7 @@ impl Snapshotter for ParquetSnapshotter {
         // Download Parquet file from object store
         let parquet_key = self.snapshot_key(metadata.collection_id, snapshot_id);
         let parquet_bytes = self.store.get(&parquet_key).await?;
+        verify_checksum(&metadata, &parquet_bytes)?;
 
         // Decode Parquet to vectors
         let vectors = self.encoder.decode_batch(&parquet_bytes)?;
@@ -238,8 +243,12 @@ impl Snapshotter for ParquetSnapshotter {
 
                 let snapshot_exists = self.store.exists(&snapshot_key).await?;
                 let metadata_exists = self.store.exists(&metadata_key).await?;
+                if !(snapshot_exists && metadata_exists) {
+                    return Ok(false);
+                }
 
-                Ok(snapshot_exists && metadata_exists)
+                let data = self.store.get(&snapshot_key).await?;
+                Ok(verify_checksum(&metadata, &data).is_ok())
             }
             Err(_) => Ok(false),
         }
@@ -261,6 +270,8 @@ mod tests {
                 vector: vec![i as f32; dimension],
                 metadata: Some(serde_json::json!({"index": i})),
                 inserted_at: Utc::now(),
+                sparse_vector: None,
+                named_vectors: None,
             })
             .collect()
     }
@@ -318,6 +329,8 @@ mod tests {
             vector: vec![0.0; 32], // Wrong dimension!
             metadata: None,
             inserted_at: Utc::now(),
+            sparse_vector: None,
+            named_vectors: None,
         });
 
         let collection_id = CollectionId::new();
@@ -40,6 +40,16 @@ struct S3RetryTask {
     last_error: String,
 }
 
+/// A write applied by [`StorageBackend::write_batch`].
+#[derive(Clone, Debug)]
+pub enum BatchWrite {
+    /// Insert or replace a document
+    Upsert(VectorDocument),
+
+    /// Delete a document
+    Delete(DocumentId),
+}
+
 /// Configuration for S3 retry behavior.
 #[derive(Clone, Debug)]
 pub struct RetryConfig {
@@ -1208,29 +1218,114 @@ impl StorageBackend {
     /// - S3 upload fails (S3Only policy only, MemoryS3 fails silently)
     pub async fn insert(&self, doc: VectorDocument) -> CoreResult<()> {
         // 1. Append to WAL (all policies)
+        let entry_size_bytes = Self::entry_size(&doc);
+        self.wal.append(self.upsert_entry(&doc)).await?;
+        self.wal.flush().await?;
+
+        // Track WAL size
+        self.metrics.write().wal_size_bytes += entry_size_bytes as u64;
+
+        // 2. Handle tiering policy
+        self.store_document(doc).await?;
+
+        // Update metrics
+        self.metrics.write().inserts += 1;
+
+        Ok(())
+    }
+
+    /// Insert and delete documents, logged as one WAL entry
+    ///
+    /// Recovery replays all of the writes or none of them. They are applied
+    /// to storage in order once logged.
+    ///
+    /// # Errors
+    ///
+    /// Returns error if the WAL append fails (nothing is applied then) or if
+    /// an S3 upload fails (S3Only policy only)
+    pub async fn write_batch(&self, writes: Vec<BatchWrite>) -> CoreResult<()> {
+        if writes.is_empty() {
+            return Ok(());
+        }
+
+        // 1. Append to WAL as a single entry
+        let mut entry_size_bytes = 0;
+        let entries: Vec<LogEntry> = writes
+            .iter()
+            .map(|write| match write {
+                BatchWrite::Upsert(doc) => {
+                    entry_size_bytes += Self::entry_size(doc);
+                    self.upsert_entry(doc)
+                }
+                BatchWrite::Delete(doc_id) => self.delete_entry(doc_id),
+            })
+            .collect();
+        self.wal
+            .append(LogEntry::Batch {
+                entries,
+                timestamp: Utc::now(),
+            })
+            .await?;
+        self.wal.flush().await?;
+        self.metrics.write().wal_size_bytes += entry_size_bytes as u64;
+
+        // 2. Apply to storage in order
+        for write in writes {
+            match write {
+                BatchWrite::Upsert(doc) => {
+                    self.store_document(doc).await?;
+                    self.metrics.write().inserts += 1;
+                }
+                BatchWrite::Delete(doc_id) => {
+                    self.remove_document(&doc_id).await;
+                    self.metrics.write().deletes += 1;
+                }
+            }
+        }
+
+        Ok(())
+    }
+
+    /// WAL entry for inserting or replacing a document
+    fn upsert_entry(&self, doc: &VectorDocument) -> LogEntry {
         // FIX BUG #16: Use real collection_id instead of generating random ones
-        let log_entry = LogEntry::Upsert {
+        LogEntry::Upsert {
             collection_id: self.collection_id, // Now using the real collection_id!
             doc_id: doc.doc_id.clone(),
             vector: doc.vector.clone(),
             external_id: doc.external_id.clone(),
             metadata: doc.metadata.clone(),
+            sparse_vector: doc.sparse_vector.clone(),
+            named_vectors: doc.named_vectors.clone(),
             timestamp: doc.inserted_at,
-        };
+        }
+    }
+
+    /// WAL entry for deleting a document
+    fn delete_entry(&self, doc_id: &DocumentId) -> LogEntry {
+        // FIX BUG #16: Use real collection_id
+        LogEntry::Delete {
+            collection_id: self.collection_id, // Now using the real collection_id!
+            doc_id: doc_id.clone(),
+            timestamp: Utc::now(),
+        }
+    }
 
+    /// Estimated WAL bytes of an upsert entry
+    fn entry_size(doc: &VectorDocument) -> usize {
         // FIX BUG #6: Track WAL size for compaction threshold
         // Estimate entry size: UUID (16) + vector (dim * 4) + metadata overhead (~100)
-        let entry_size_bytes = 16 + (doc.vector.len() * 4) + 100
+        16 + (doc.vector.len() * 4) + 100
             + doc.external_id.as_ref().map_or(0, |s| s.len())
-            + doc.metadata.as_ref().map_or(0, |_| 200); // JSON metadata estimate
-
-        self.wal.append(log_entry).await?;
-        self.wal.flush().await?;
-
-        // Track WAL size
-        self.metrics.write().wal_size_bytes += entry_size_bytes as u64;
+            + doc.metadata.as_ref().map_or(0, |_| 200) // JSON metadata estimate
+            + doc.sparse_vector.as_ref().map_or(0, |v| v.len() * 8)
+            + doc.named_vectors.as_ref().map_or(0, |named| {
+                named.iter().map(|(name, v)| name.len() + v.len() * 4).sum()
+            })
+    }
 
-        // 2. Handle tiering policy
+    /// Store a logged document according to the tiering policy
+    async fn store_document(&self, doc: VectorDocument) -> CoreResult<()> {
         match self.config.tiering_policy {
             TieringPolicy::Memory => {
                 // Store in HashMap
@@ -1278,9 +1373,6 @@ impl StorageBackend {
             }
         }
 
-        // Update metrics
-        self.metrics.write().inserts += 1;
-
         Ok(())
     }
 
@@ -1374,17 +1466,20 @@ impl StorageBackend {
     /// Returns error if WAL append fails or S3 delete fails
     pub async fn delete(&self, doc_id: &DocumentId) -> CoreResult<()> {
         // 1. Append to WAL
-        // FIX BUG #16: Use real collection_id
-        let log_entry = LogEntry::Delete {
-            collection_id: self.collection_id, // Now using the real collection_id!
-            doc_id: doc_id.clone(),
-            timestamp: Utc::now(),
-        };
-
-        self.wal.append(log_entry).await?;
+        self.wal.append(self.delete_entry(doc_id)).await?;
         self.wal.flush().await?;
 
         // 2. Delete from storage
+        self.remove_document(doc_id).await;
+
+        // Update metrics
+        self.metrics.write().deletes += 1;
+
+        Ok(())
+    }
+
+    /// Remove a logged delete's document from storage
+    async fn remove_document(&self, doc_id: &DocumentId) {
         match self.config.tiering_policy {
             TieringPolicy::Memory | TieringPolicy::MemoryS3 => {
                 self.vector_store.write().remove(doc_id);
@@ -1404,11 +1499,6 @@ impl StorageBackend {
                 }
             }
         }
-
-        // Update metrics
-        self.metrics.write().deletes += 1;
-
-        Ok(())
     }
 
     /// Get count of vectors in storage
@@ -1504,62 +1594,77 @@ impl StorageBackend {
         let entries = self.wal.replay(LogSequenceNumber::ZERO).await?;
 
         for (_lsn, entry) in entries {
-            match entry {
-                LogEntry::Upsert {
-                    doc_id,
-                    vector,
-                    external_id,
-                    metadata,
-                    timestamp,
-                    ..
-                } => {
-                    // Reconstruct VectorDocument
-                    let mut doc = VectorDocument::new(doc_id.clone(), vector);
-                    if let Some(ext_id) = external_id {
-                        doc = doc.with_external_id(ext_id);
-                    }
-                    if let Some(meta) = metadata {
-                        doc = doc.with_metadata(meta);
-                    }
-                    // Update timestamp
-                    doc.inserted_at = timestamp;
+            self.replay_entry(entry);
+        }
 
-                    // Apply to storage
-                    match self.config.tiering_policy {
-                        TieringPolicy::Memory | TieringPolicy::MemoryS3 => {
-                            self.vector_store.write().insert(doc_id, doc);
-                        }
-                        TieringPolicy::S3Only => {
-                            // For S3Only, only add to cache (S3 is source of truth)
-                            if let Some(cache) = &self.vector_cache {
-                                cache.write().put(doc_id, doc);
-                            }
+        Ok(())
+    }
+
+    /// Apply a replayed WAL entry to storage
+    fn replay_entry(&self, entry: LogEntry) {
+        match entry {
+            LogEntry::Upsert {
+                doc_id,
+                vector,
+                external_id,
+                metadata,
+                sparse_vector,
+                named_vectors,
+                timestamp,
+                ..
+            } => {
+                // Reconstruct VectorDocument
+                let mut doc = VectorDocument::new(doc_id.clone(), vector);
+                if let Some(ext_id) = external_id {
+                    doc = doc.with_external_id(ext_id);
+                }
+                if let Some(meta) = metadata {
+                    doc = doc.with_metadata(meta);
+                }
+                doc.sparse_vector = sparse_vector;
+                doc.named_vectors = named_vectors;
+                // Update timestamp
+                doc.inserted_at = timestamp;
+
+                // Apply to storage
+                match self.config.tiering_policy {
+                    TieringPolicy::Memory | TieringPolicy::MemoryS3 => {
+                        self.vector_store.write().insert(doc_id, doc);
+                    }
+                    TieringPolicy::S3Only => {
+                        // For S3Only, only add to cache (S3 is source of truth)
+                        if let Some(cache) = &self.vector_cache {
+                            cache.write().put(doc_id, doc);
                         }
                     }
                 }
+            }
 
-                LogEntry::Delete { doc_id, .. } => {
-                    // Apply deletion
-                    match self.config.tiering_policy {
-                        TieringPolicy::Memory | TieringPolicy::MemoryS3 => {
-                            self.vector_store.write().remove(&doc_id);
-                        }
-                        TieringPolicy::S3Only => {
-                            if let Some(cache) = &self.vector_cache {
-                                cache.write().pop(&doc_id);
-                            }
+            LogEntry::Delete { doc_id, .. } => {
+                // Apply deletion
+                match self.config.tiering_policy {
+                    TieringPolicy::Memory | TieringPolicy::MemoryS3 => {
+                        self.vector_store.write().remove(&doc_id);
+                    }
+                    TieringPolicy::S3Only => {
+                        if let Some(cache) = &self.vector_cache {
+                            cache.write().pop(&doc_id);
                         }
                     }
                 }
+            }
 
-                // Ignore collection-level operations for now
-                LogEntry::CreateCollection { .. }
-                | LogEntry::DeleteCollection { .. }
-                | LogEntry::Checkpoint { .. } => {}
+            LogEntry::Batch { entries, .. } => {
+                for entry in entries {
+                    self.replay_entry(entry);
+                }
             }
-        }
 
-        Ok(())
+            // Ignore collection-level operations for now
+            LogEntry::CreateCollection { .. }
+            | LogEntry::DeleteCollection { .. }
+            | LogEntry::Checkpoint { .. } => {}
+        }
     }
 
     /// Compact storage by creating snapshot and truncating WAL
@@ -2124,6 +2229,47 @@ mod tests {
         }
     }
 
+    #[tokio::test]
+    async fn test_write_batch_recovery() {
+        let temp_dir = TempDir::new().unwrap();
+        let wal_path = temp_dir.path().join("test.wal");
+        let snapshot_dir = temp_dir.path().join("snapshots");
+        std::fs::create_dir_all(&snapshot_dir).unwrap();
+
+        let config = StorageConfig::memory(&wal_path);
+        let mut config = config;
+        config.snapshot_dir = snapshot_dir.clone();
+
+        let kept = VectorDocument::new(DocumentId::new(), vec![1.0; 128]);
+        let replaced = VectorDocument::new(DocumentId::new(), vec![2.0; 128]);
+
+        // Create backend, then replace one document with another in a batch
+        {
+            let backend = StorageBackend::new(config.clone()).await.unwrap();
+            backend.insert(kept.clone()).await.unwrap();
+            backend.insert(replaced.clone()).await.unwrap();
+
+            let added = VectorDocument::new(DocumentId::new(), vec![3.0; 128]);
+            backend
+                .write_batch(vec![
+                    BatchWrite::Delete(replaced.doc_id),
+                    BatchWrite::Upsert(added),
+                ])
+                .await
+                .unwrap();
+            assert_eq!(backend.count(), 2);
+            assert_eq!(backend.metrics().deletes, 1);
+        }
+
+        // Recover and verify
+        {
+            let backend = StorageBackend::new(config).await.unwrap();
+            assert_eq!(backend.count(), 2);
+            assert!(backend.get(&kept.doc_id).await.unwrap().is_some());
+            assert!(backend.get(&replaced.doc_id).await.unwrap().is_none());
+        }
+    }
+
     #[tokio::test]
     async fn test_compaction() {
         let temp_dir = TempDir::new().unwrap();
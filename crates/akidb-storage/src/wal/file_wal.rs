@@ -460,6 +460,8 @@ mod tests {
                 vector: vec![1.0, 2.0, 3.0],
                 external_id: None,
                 metadata: None,
+                sparse_vector: None,
+                named_vectors: None,
                 timestamp: chrono::Utc::now(),
             },
         ];
@@ -482,6 +484,8 @@ mod tests {
                 vector: vec![i as f32],
                 external_id: Some(format!("doc-{}", i)),
                 metadata: None,
+                sparse_vector: None,
+                named_vectors: None,
                 timestamp: chrono::Utc::now(),
             };
             wal.append(entry).await.unwrap();
@@ -515,6 +519,8 @@ mod tests {
                     vector: vec![i as f32],
                     external_id: None,
                     metadata: None,
+                    sparse_vector: None,
+                    named_vectors: None,
                     timestamp: chrono::Utc::now(),
                 };
                 wal.append(entry).await.unwrap();
@@ -537,6 +543,42 @@ mod tests {
         }
     }
 
+    #[tokio::test]
+    async fn test_file_wal_torn_batch_is_skipped() {
+        let temp_dir = TempDir::new().unwrap();
+        let dir_path = temp_dir.path().to_path_buf();
+        let batch = |n: usize| LogEntry::Batch {
+            entries: (0..n)
+                .map(|_| LogEntry::Delete {
+                    collection_id: CollectionId::new(),
+                    doc_id: DocumentId::new(),
+                    timestamp: chrono::Utc::now(),
+                })
+                .collect(),
+            timestamp: chrono::Utc::now(),
+        };
+
+        {
+            let wal = FileWAL::new(&dir_path, FileWALConfig::default())
+                .await
+                .unwrap();
+            wal.append(batch(2)).await.unwrap();
+
+            // A crash while writing the second batch leaves half its line
+            let line = serde_json::to_string(&(LogSequenceNumber::new(2), batch(3))).unwrap();
+            let path = wal.current_log_path.read().clone();
+            let mut file = OpenOptions::new().append(true).open(path).unwrap();
+            file.write_all(&line.as_bytes()[..line.len() / 2]).unwrap();
+        }
+
+        let wal = FileWAL::new(&dir_path, FileWALConfig::default())
+            .await
+            .unwrap();
+        let entries = wal.replay(LogSequenceNumber::ZERO).await.unwrap();
+        assert_eq!(entries.len(), 1);
+        assert!(matches!(&entries[0].1, LogEntry::Batch { entries, .. } if entries.len() == 2));
+    }
+
     #[tokio::test]
     async fn test_file_wal_checkpoint() {
         let (wal, _dir) = create_test_wal().await;
@@ -549,6 +591,8 @@ mod tests {
                 vector: vec![i as f32],
                 external_id: None,
                 metadata: None,
+                sparse_vector: None,
+                named_vectors: None,
                 timestamp: chrono::Utc::now(),
             };
             wal.append(entry).await.unwrap();
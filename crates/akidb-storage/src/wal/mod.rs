@@ -8,10 +8,11 @@ mod file_wal;
 
 pub use file_wal::{FileWAL, FileWALConfig};
 
-use akidb_core::{CollectionId, CoreResult, DocumentId};
+use akidb_core::{CollectionId, CoreResult, DocumentId, SparseVector};
 use async_trait::async_trait;
 use chrono::{DateTime, Utc};
 use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
 use std::fmt;
 
 /// Log Sequence Number - monotonically increasing identifier for WAL entries
@@ -89,6 +90,12 @@ pub enum LogEntry {
         #[serde(skip_serializing_if = "Option::is_none")]
         /// Optional JSON metadata
         metadata: Option<serde_json::Value>,
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        /// Optional sparse vector (absent in entries written before it existed)
+        sparse_vector: Option<SparseVector>,
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        /// Optional vectors in named vector spaces
+        named_vectors: Option<HashMap<String, Vec<f32>>>,
         /// Operation timestamp
         timestamp: DateTime<Utc>,
     },
@@ -121,6 +128,15 @@ pub enum LogEntry {
         timestamp: DateTime<Utc>,
     },
 
+    /// Writes logged together on one line: replay sees all of them, or none
+    /// if a crash tore the line
+    Batch {
+        /// Upsert and delete entries, in the order they apply
+        entries: Vec<LogEntry>,
+        /// Batch timestamp
+        timestamp: DateTime<Utc>,
+    },
+
     /// Checkpoint marker - all entries before this LSN are safe to discard
     Checkpoint {
         /// Log sequence number of checkpoint
@@ -138,17 +154,19 @@ impl LogEntry {
             | LogEntry::Delete { timestamp, .. }
             | LogEntry::CreateCollection { timestamp, .. }
             | LogEntry::DeleteCollection { timestamp, .. }
+            | LogEntry::Batch { timestamp, .. }
             | LogEntry::Checkpoint { timestamp, .. } => *timestamp,
         }
     }
 
-    /// Get the collection ID if applicable
+    /// Get the collection ID if applicable (a batch's is its first entry's)
     pub fn collection_id(&self) -> Option<CollectionId> {
         match self {
             LogEntry::Upsert { collection_id, .. }
             | LogEntry::Delete { collection_id, .. }
             | LogEntry::CreateCollection { collection_id, .. }
             | LogEntry::DeleteCollection { collection_id, .. } => Some(*collection_id),
+            LogEntry::Batch { entries, .. } => entries.first().and_then(LogEntry::collection_id),
             LogEntry::Checkpoint { .. } => None,
         }
     }
@@ -264,6 +282,8 @@ mod tests {
             vector: vec![1.0, 2.0, 3.0],
             external_id: None,
             metadata: None,
+            sparse_vector: None,
+            named_vectors: None,
             timestamp: now,
         };
 
@@ -286,6 +306,84 @@ mod tests {
         assert!(matches!(deserialized, LogEntry::CreateCollection { .. }));
     }
 
+    #[test]
+    fn test_upsert_extra_vectors_serialization() {
+        let sparse = SparseVector::from_pairs([(3, 0.5), (7, 1.0)]);
+        let named = HashMap::from([("title".to_string(), vec![0.5, 0.25])]);
+        let entry = LogEntry::Upsert {
+            collection_id: CollectionId::new(),
+            doc_id: DocumentId::new(),
+            vector: vec![1.0, 2.0],
+            external_id: None,
+            metadata: None,
+            sparse_vector: Some(sparse.clone()),
+            named_vectors: Some(named.clone()),
+            timestamp: Utc::now(),
+        };
+
+        let mut json: serde_json::Value = serde_json::to_value(&entry).unwrap();
+        let deserialized: LogEntry = serde_json::from_value(json.clone()).unwrap();
+        assert!(matches!(
+            deserialized,
+            LogEntry::Upsert {
+                sparse_vector: Some(s),
+                named_vectors: Some(n),
+                ..
+            } if s == sparse && n == named
+        ));
+
+        // Entries written before sparse and named vectors existed still replay
+        let fields = json.as_object_mut().unwrap();
+        fields.remove("sparse_vector");
+        fields.remove("named_vectors");
+        let deserialized: LogEntry = serde_json::from_value(json).unwrap();
+        assert!(matches!(
+            deserialized,
+            LogEntry::Upsert {
+                sparse_vector: None,
+                named_vectors: None,
+                ..
+            }
+        ));
+    }
+
+    #[test]
+    fn test_batch_entry_serialization() {
+        let collection_id = CollectionId::new();
+        let doc_id = DocumentId::new();
+        let entry = LogEntry::Batch {
+            entries: vec![
+                LogEntry::Delete {
+                    collection_id,
+                    doc_id,
+                    timestamp: Utc::now(),
+                },
+                LogEntry::Upsert {
+                    collection_id,
+                    doc_id,
+                    vector: vec![1.0, 2.0],
+                    external_id: None,
+                    metadata: None,
+                    sparse_vector: None,
+                    named_vectors: None,
+                    timestamp: Utc::now(),
+                },
+            ],
+            timestamp: Utc::now(),
+        };
+        assert_eq!(entry.collection_id(), Some(collection_id));
+
+        let json = serde_json::to_string(&entry).unwrap();
+        let deserialized: LogEntry = serde_json::from_str(&json).unwrap();
+        match deserialized {
+            LogEntry::Batch { entries, .. } => {
+                assert!(matches!(entries[0], LogEntry::Delete { .. }));
+                assert!(matches!(entries[1], LogEntry::Upsert { .. }));
+            }
+            other => panic!("expected a batch, got {:?}", other),
+        }
+    }
+
     #[test]
     fn test_checkpoint_entry() {
         let checkpoint = LogEntry::Checkpoint {
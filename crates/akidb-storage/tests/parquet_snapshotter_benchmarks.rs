@@ -23,6 +23,8 @@ fn create_test_vectors(count: usize, dimension: usize) -> Vec<VectorDocument> {
             vector: vec![i as f32; dimension],
             metadata: Some(serde_json::json!({"index": i, "category": "test"})),
             inserted_at: Utc::now(),
+            sparse_vector: None,
+            named_vectors: None,
         })
         .collect()
 }